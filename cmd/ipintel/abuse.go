@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/template"
+	"time"
+
+	"api-client/internal/aggregator"
+	"api-client/internal/cli"
+	"api-client/internal/config"
+	"api-client/internal/model"
+	"api-client/internal/provider/whois"
+)
+
+// runAbuse implements the "ipintel abuse" subcommand. It exits non-zero
+// when no provider reports an abuse contact for the address.
+func runAbuse(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseAbuseCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ip, err := model.ParseAddr(cmd.IPAddress)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	tmpl, err := abuseTemplate(cmd.Template)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	eff, err := loadConfig(cmd.ConfigPath, config.Overrides{})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	// Whois has the registries' abuse contacts whatever else is enabled
+	if !slices.Contains(eff.Providers.Value, whois.ProviderName) {
+		eff.Providers.Value = append(slices.Clone(eff.Providers.Value), whois.ProviderName)
+	}
+
+	providers, err := buildProviders(eff, &http.Client{Timeout: cmd.Timeout}, cli.Config{Timeout: cmd.Timeout}, nil)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	report := aggregator.New(providers...).Lookup(context.Background(), ip)
+
+	contact, ok := cli.FindAbuseContact(report)
+	if !ok {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: no abuse contact found for %s\n", ip)
+		for _, e := range report.Errors() {
+			_, _ = fmt.Fprintf(os.Stderr, "  %v\n", e)
+		}
+		return 1
+	}
+
+	if !cmd.Email {
+		err = cli.PrintAbuseContact(os.Stdout, ip, contact)
+	} else {
+		err = cli.WriteAbuseEmail(os.Stdout, tmpl, cli.AbuseReport{
+			IP:      ip,
+			Time:    time.Now().UTC(),
+			From:    cmd.From,
+			Contact: contact,
+			Report:  report,
+		})
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// abuseTemplate parses the abuse report template at path, or the default
+// one when path is empty.
+func abuseTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New("abuse").Parse(cli.DefaultAbuseTemplate)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tmpl, nil
+}
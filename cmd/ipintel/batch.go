@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"api-client/internal/aggregator"
+	"api-client/internal/alert"
+	"api-client/internal/anycast"
+	"api-client/internal/batch"
+	"api-client/internal/cli"
+	"api-client/internal/config"
+	"api-client/internal/countries"
+	"api-client/internal/hook"
+	"api-client/internal/model"
+	"api-client/internal/networks"
+	"api-client/internal/policy"
+	"api-client/internal/provider"
+	"api-client/internal/push"
+	"api-client/internal/remote"
+)
+
+// batchInput is the input of a run: the positional addresses followed by
+// the records of the input file, if any. The file is read twice, once to
+// validate it and once, streaming, to look its records up, so that inputs
+// of any size can be processed without holding them in memory; so is the
+// input at a URL, streamed twice rather than downloaded.
+type batchInput struct {
+	addresses []batch.Record
+	name      string
+	file      io.ReadSeekCloser
+	spool     string // the temporary copy of standard input, if any
+	format    batch.InputFormat
+	column    string
+	stats     batch.Stats
+}
+
+// openInput parses the positional addresses and validates the input file,
+// which is standard input when named "-", or read from an s3://, http:// or
+// https:// URL. The input file is validated in
+// full before any lookup starts; invalid lines abort the run unless
+// --skip-invalid is set, in which case they are reported as warnings.
+// Standard input is first copied to a temporary file unless it can be
+// read again as is.
+func openInput(cfg cli.Config) (_ *batchInput, err error) {
+	in := &batchInput{format: cfg.InputFormat, column: cfg.Column}
+	for _, addr := range cfg.Addresses {
+		ip, err := model.ParseAddr(addr)
+		if err != nil {
+			return nil, err
+		}
+		in.addresses = append(in.addresses, batch.Record{IP: ip})
+	}
+
+	if cfg.InputFile == "" {
+		return in, nil
+	}
+
+	defer func() {
+		if err != nil {
+			_ = in.Close()
+		}
+	}()
+
+	name := "stdin"
+	if cfg.InputFile == "-" {
+		f, err := spoolStdin()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if in.file = f; f != os.Stdin {
+			in.spool = f.Name()
+		}
+	} else if remote.IsURL(cfg.InputFile) {
+		if in.file, err = remote.Open(cfg.InputFile); err != nil {
+			return nil, err
+		}
+		// The query of a presigned URL changes from one run to the next
+		name = remote.Redact(cfg.InputFile)
+	} else {
+		f, err := os.Open(cfg.InputFile)
+		if err != nil {
+			return nil, err
+		}
+		in.file = f
+		name = cfg.InputFile
+	}
+	in.name = name
+
+	src, err := batch.NewSource(in.file, in.format, in.column)
+	if err == nil {
+		in.stats, err = batch.Scan(src)
+	}
+
+	var verr *batch.ValidationError
+	if errors.As(err, &verr) {
+		label := "Error"
+		if cfg.SkipInvalid {
+			label = "Warning"
+		}
+		for _, line := range verr.Lines {
+			if line.Text == "" {
+				_, _ = fmt.Fprintf(os.Stderr, "%s: %s:%d: %v\n", label, name, line.Line, line.Err)
+				continue
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s:%d: invalid IP address %q\n", label, name, line.Line, line.Text)
+		}
+
+		if !cfg.SkipInvalid {
+			return nil, fmt.Errorf("%s: %d invalid line(s); fix them or use --skip-invalid", name, len(verr.Lines))
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: skipped %d invalid line(s)\n", len(verr.Lines))
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return in, nil
+}
+
+// spoolStdin returns standard input if it is a regular file, which can be
+// rewound, and otherwise a temporary file holding a copy of it.
+func spoolStdin() (*os.File, error) {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode().IsRegular() {
+		return os.Stdin, nil
+	}
+
+	f, err := os.CreateTemp("", "ipintel-input-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, os.Stdin); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// Len returns the number of valid records.
+func (in *batchInput) Len() int {
+	return len(in.addresses) + in.stats.Records
+}
+
+// Source returns a Source reading all valid records from the start.
+func (in *batchInput) Source() (batch.Source, error) {
+	addresses := batch.SliceSource(in.addresses)
+	if in.file == nil {
+		return &addresses, nil
+	}
+
+	if _, err := in.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, err := batch.NewSource(in.file, in.format, in.column)
+	if err != nil {
+		return nil, err
+	}
+	return batch.MultiSource(&addresses, batch.SkipInvalid(src)), nil
+}
+
+// First returns the first valid record.
+func (in *batchInput) First() (batch.Record, error) {
+	src, err := in.Source()
+	if err != nil {
+		return batch.Record{}, err
+	}
+	return src.Next()
+}
+
+// Close closes the input file, removing it if it was a copy of standard
+// input.
+func (in *batchInput) Close() error {
+	if in.file == nil || in.file == os.Stdin {
+		return nil
+	}
+	err := in.file.Close()
+	if in.spool != "" {
+		_ = os.Remove(in.spool)
+	}
+	return err
+}
+
+// runBatch looks up every record with looker and writes each report as
+// soon as those before it have been written, then runs the after hooks on
+// it and checks it against the alert rules. With a policy, the exit code
+// is that of the most severe decision. On SIGINT or SIGTERM, the reports
+// completed so far are written, the checkpoint is saved and
+// exitInterrupted is returned. Whichever way the run ends, its metrics are
+// pushed with --push-metrics. Each report written is published with
+// --publish; failing to publish aborts the run. A run completing is
+// summarized by email with --email-to. When the quotas of the providers
+// pace the run, how long it will take is estimated up front.
+func runBatch(cfg cli.Config, agg *aggregator.Aggregator, looker hook.Looker, anycastList *anycast.List, engine *policy.Engine, hooks *hook.Runner, alerts *alert.Engine, mailer *reportMailer, quotas map[string]config.Quota, input *batchInput, formatter *cli.Formatter) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills the process as usual
+		<-ctx.Done()
+		stop()
+	}()
+
+	runner := batch.New(looker,
+		batch.WithWorkers(cfg.Concurrency),
+		batch.WithFailFast(cfg.FailFast),
+	)
+
+	src, err := input.Source()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var pusher push.Pusher
+	if cfg.PushMetrics != "" {
+		if pusher, err = push.New(cfg.PushMetrics); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --push-metrics: %v\n", err)
+			return 1
+		}
+	}
+
+	publisher, err := openPublisher(cfg, formatter, input.stats.Fields)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	checkpoint := batch.Checkpoint{Input: input.name, Records: input.Len()}
+	if cfg.Checkpoint != "" {
+		saved, ok, err := batch.LoadCheckpoint(cfg.Checkpoint)
+		if err == nil && ok {
+			if err = saved.Resumes(checkpoint.Input, checkpoint.Records); err == nil {
+				checkpoint.Completed = saved.Completed
+				err = batch.Skip(src, saved.Completed)
+			}
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --checkpoint: %v\n", err)
+			return 1
+		}
+		if ok {
+			_, _ = fmt.Fprintf(os.Stderr, "Resuming after %d of %d records\n", checkpoint.Completed, checkpoint.Records)
+		}
+	}
+
+	w, err := formatter.NewBatchWriter(cfg.Format, input.Len()-checkpoint.Completed, input.stats.Fields)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		return 1
+	}
+
+	if d, name := quotaEstimate(quotas, input.Len()-checkpoint.Completed); d > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Estimated duration: at least %s, paced by the quota of %s (%s)\n", d, name, quotas[name])
+	}
+
+	meta := newMeta(cfg, agg)
+	var metrics push.Metrics
+	var tally *countries.Tally
+	if cfg.CountrySummary != "" {
+		tally = countries.NewTally()
+	}
+	var networkTally *networks.Tally
+	if cfg.NetworkSummary != "" {
+		networkTally = networks.NewTally()
+	}
+	start := time.Now()
+	var emailReport *cli.EmailReport
+	if mailer != nil {
+		title := input.name
+		if title == "" {
+			title = fmt.Sprintf("%d addresses", input.Len())
+		}
+		emailReport = cli.NewEmailReport(title, start)
+	}
+	anyFailed := false
+	hookFailed := false
+	exitCode := 0
+	var writeErr, publishErr error
+
+	runErr := runner.Stream(ctx, src, func(report model.Report) error {
+		report.Meta = meta
+		report.IsAnycast = anycastList.Contains(report.IP)
+		if report.AllFailed() {
+			anyFailed = true
+		}
+		metrics.Add(report)
+		if tally != nil {
+			tally.Add(report)
+		}
+		if networkTally != nil {
+			networkTally.Add(report)
+		}
+		if engine != nil {
+			decision := engine.Evaluate(report)
+			report.Policy = &decision
+			exitCode = max(exitCode, policy.ExitCode(decision.Action))
+		}
+		matched := cfg.Filter == nil || cfg.Filter.Match(report)
+		if emailReport != nil {
+			emailReport.Add(report, matched)
+		}
+		if !matched {
+			w.Skip()
+		} else if writeErr = w.Write(report); writeErr != nil {
+			return writeErr
+		} else if publisher != nil {
+			if publishErr = publisher.Publish(report); publishErr != nil {
+				return publishErr
+			}
+		}
+		checkpoint.Completed++
+		if hooks != nil {
+			if err := hooks.After(ctx, report); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				hookFailed = true
+			}
+		}
+		if alerts != nil {
+			alerts.Observe(ctx, report)
+		}
+		return nil
+	})
+
+	if pusher != nil {
+		metrics.Duration = time.Since(start)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+		if err := pusher.Push(ctx, metrics); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: pushing metrics: %v\n", err)
+		}
+		cancel()
+	}
+
+	if publisher != nil {
+		if err := publisher.Close(); publishErr == nil {
+			publishErr = err
+		}
+	}
+
+	if writeErr == nil {
+		writeErr = w.Close()
+	}
+	if writeErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", writeErr)
+		return 1
+	}
+	if publishErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", publishErr)
+		return 1
+	}
+
+	if errors.Is(runErr, context.Canceled) {
+		return interrupted(cfg, checkpoint)
+	}
+
+	if runErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", runErr)
+		return 1
+	}
+
+	if tally != nil {
+		if err := writeCountrySummary(cfg.CountrySummary, tally); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --country-summary: %v\n", err)
+			return 1
+		}
+	}
+
+	if networkTally != nil {
+		if err := writeNetworkSummary(cfg.NetworkSummary, networkTally); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --network-summary: %v\n", err)
+			return 1
+		}
+	}
+
+	if emailReport != nil {
+		emailReport.Finish(time.Since(start))
+		if err := mailer.Send(emailReport); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if cfg.Checkpoint != "" {
+		if err := os.Remove(cfg.Checkpoint); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: removing checkpoint: %v\n", err)
+		}
+	}
+
+	// Return non-zero if any lookup failed on every provider or an after
+	// hook failed
+	if anyFailed || hookFailed {
+		return 1
+	}
+
+	return exitCode
+}
+
+// quotaEstimate returns how long looking up records addresses takes at
+// least within quotas, and the provider whose quota paces the run; zero
+// when none of them does.
+func quotaEstimate(quotas map[string]config.Quota, records int) (time.Duration, string) {
+	var longest time.Duration
+	var pacing string
+	for name, q := range quotas {
+		d := provider.QuotaDuration(records, q.Requests, q.Per)
+		if d > longest || d == longest && d > 0 && name < pacing {
+			longest, pacing = d, name
+		}
+	}
+	return longest, pacing
+}
+
+// writeCountrySummary writes the countries of a completed run to path.
+func writeCountrySummary(path string, tally *countries.Tally) error {
+	format, err := cli.CountrySummaryFormat(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cli.PrintCountrySummary(f, tally, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeNetworkSummary writes the networks of a completed run to path.
+func writeNetworkSummary(path string, tally *networks.Tally) error {
+	format, err := cli.NetworkSummaryFormat(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cli.PrintNetworkSummary(f, tally, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exitInterrupted is the exit code of an interrupted batch run, that of a
+// process killed by SIGINT.
+const exitInterrupted = 130
+
+// interrupted reports how far an interrupted run got and saves its
+// checkpoint, if one was requested.
+func interrupted(cfg cli.Config, checkpoint batch.Checkpoint) int {
+	_, _ = fmt.Fprintf(os.Stderr, "Interrupted after %d of %d records\n", checkpoint.Completed, checkpoint.Records)
+
+	if cfg.Checkpoint == "" {
+		return exitInterrupted
+	}
+	if err := checkpoint.Save(cfg.Checkpoint); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: saving checkpoint: %v\n", err)
+		return exitInterrupted
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Progress saved to %s; run the same command again to resume\n", cfg.Checkpoint)
+	return exitInterrupted
+}
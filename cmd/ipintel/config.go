@@ -0,0 +1,359 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"api-client/internal/alert"
+	"api-client/internal/cli"
+	"api-client/internal/config"
+	"api-client/internal/dataset"
+	"api-client/internal/hook"
+	"api-client/internal/latency"
+	"api-client/internal/policy"
+	"api-client/internal/provider"
+	"api-client/internal/provider/bogon"
+	"api-client/internal/provider/country"
+	"api-client/internal/provider/custom"
+	"api-client/internal/provider/httpcache"
+	"api-client/internal/provider/option"
+	"api-client/internal/provider/registry"
+)
+
+// runConfig implements the "ipintel config" subcommand.
+func runConfig(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseConfigCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if cmd.Config.ShowHelp {
+		parser.PrintUsage()
+		return 0
+	}
+	if cmd.Config.ShowVersion {
+		parser.PrintVersion(Version)
+		return 0
+	}
+
+	// -f picks the format config show prints in, not the lookup format
+	o := overrides(parser, cmd.Config)
+	o.Format = nil
+	eff, err := loadConfig(cmd.ConfigPath, o)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := cli.PrintConfig(os.Stdout, eff, cmd.Format); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// loadConfig resolves the effective configuration on top of the built-in defaults.
+func loadConfig(path string, overrides config.Overrides) (config.Config, error) {
+	return config.Load(config.Options{
+		Path: path,
+		Defaults: config.Defaults{
+			Format:    string(cli.FormatText),
+			Timeout:   cli.DefaultTimeout,
+			Providers: registry.Defaults(),
+		},
+		Overrides: overrides,
+	})
+}
+
+// loadPolicy compiles the policy rules of the configuration. It returns
+// nil when there are none.
+func loadPolicy(eff config.Config) (*policy.Engine, error) {
+	if len(eff.Policy.Value) == 0 {
+		return nil, nil
+	}
+	rules := make([]policy.Rule, len(eff.Policy.Value))
+	for i, r := range eff.Policy.Value {
+		rules[i] = policy.Rule{Name: r.Name, When: r.When, Action: r.Action}
+	}
+	return policy.New(rules)
+}
+
+// loadHooks returns the runner of the hooks configured, or nil when there
+// are none.
+func loadHooks(eff config.Config) (*hook.Runner, error) {
+	if len(eff.Hooks.Value) == 0 {
+		return nil, nil
+	}
+	hooks := make([]hook.Hook, len(eff.Hooks.Value))
+	for i, h := range eff.Hooks.Value {
+		hooks[i] = hook.Hook{
+			Name:      h.Name,
+			Stage:     h.Stage,
+			Command:   h.Command,
+			Timeout:   time.Duration(h.Timeout),
+			OnFailure: h.OnFailure,
+		}
+	}
+	return hook.New(hooks, os.Stderr)
+}
+
+// loadAlerts returns the engine of the alert rules configured, comparing
+// reports with those of the state at path, or at its default location when
+// empty, encrypted with key when set. It returns nil when there are no
+// rules, and an error when offline and a rule notifies a webhook or syslog
+// server.
+func loadAlerts(eff config.Config, path string, offline bool, key []byte) (*alert.Engine, *alert.State, error) {
+	if len(eff.Alerts.Value) == 0 {
+		return nil, nil, nil
+	}
+	if offline {
+		for _, r := range eff.Alerts.Value {
+			if r.Webhook != "" || r.Syslog != "" {
+				return nil, nil, fmt.Errorf("alert %q notifies over the network, which --offline rules out", r.Name)
+			}
+		}
+	}
+	if path == "" {
+		var err error
+		if path, err = alert.DefaultStatePath(); err != nil {
+			return nil, nil, fmt.Errorf("no location for the alert state: %w; set --alert-state", err)
+		}
+	}
+	var opts []alert.StateOption
+	if key != nil {
+		aead, err := httpcache.NewAEAD(key, "ipintel alert state")
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, alert.WithAEAD(aead))
+	}
+	state, err := alert.LoadState(path, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules := make([]alert.Rule, len(eff.Alerts.Value))
+	for i, r := range eff.Alerts.Value {
+		rules[i] = alert.Rule{
+			Name:    r.Name,
+			Changed: r.Changed,
+			Enters:  r.Enters,
+			Leaves:  r.Leaves,
+			Webhook: r.Webhook,
+			Exec:    r.Exec,
+			Syslog:  r.Syslog,
+		}
+	}
+	engine, err := alert.New(rules, state, os.Stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return engine, state, nil
+}
+
+// newDialer returns the dialer of provider connections configured in the
+// dialer section.
+func newDialer(eff config.Config) *net.Dialer {
+	return &net.Dialer{
+		Timeout:       time.Duration(eff.Dialer.Timeout.Value),
+		FallbackDelay: time.Duration(eff.Dialer.FallbackDelay.Value),
+		KeepAlive:     time.Duration(eff.Dialer.KeepAlive.Value),
+	}
+}
+
+// overrides collects the settings given explicitly on the command line.
+func overrides(parser *cli.Parser, cfg cli.Config) config.Overrides {
+	var o config.Overrides
+	if parser.IsSet("format") {
+		format := string(cfg.Format)
+		o.Format = &format
+	}
+	if parser.IsSet("timeout") {
+		timeout := cfg.Timeout
+		o.Timeout = &timeout
+	}
+	return o
+}
+
+const (
+	// retryBackoff is the wait before the first retry of a provider query
+	retryBackoff = 500 * time.Millisecond
+
+	// resultCacheSize bounds the addresses whose results --result-cache
+	// keeps for each provider
+	resultCacheSize = 100000
+)
+
+// buildProviders constructs the enabled providers from the effective
+// configuration, bounding each by cfg.Timeout and asking those that can
+// localize place names for cfg.Language. The local providers come first:
+// bogon, which classifies special-purpose addresses the remote providers
+// get wrong, and country, from the downloaded or bundled table. With a
+// latency history, providers without a configured timeout are bounded by
+// the timeout derived from it instead, when shorter. Providers with a quota
+// or a rate limit are held to it, waiting for their turn before their
+// timeout starts; each retry waits for a turn of its own, and results
+// reused by --result-cache take none.
+func buildProviders(eff config.Config, requester provider.HttpRequester, cfg cli.Config, latencies *latency.Store) ([]provider.Provider, error) {
+	var logger *slog.Logger
+	if cfg.Debug {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	providers := make([]provider.Provider, 0, len(eff.Providers.Value)+2)
+	providers = append(providers, bogon.New())
+
+	countries, err := loadCountryTable(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	providers = append(providers, countries)
+
+	for _, name := range eff.Providers.Value {
+		opts := []option.Option{option.WithRequester(requester), option.WithLanguage(cfg.Language)}
+
+		pc := eff.Provider[name]
+		if pc.APIKey.Value != "" {
+			opts = append(opts, option.WithAPIKey(pc.APIKey.Value))
+		}
+		if pc.BaseURL.Value != "" {
+			opts = append(opts, option.WithBaseURL(pc.BaseURL.Value))
+		}
+		timeout := cfg.Timeout
+		if pc.Timeout.Value != 0 {
+			opts = append(opts, option.WithTimeout(time.Duration(pc.Timeout.Value)))
+		} else if latencies != nil {
+			if derived, ok := latencies.Timeout(name); ok && derived < timeout {
+				timeout = derived
+			}
+		}
+
+		p, err := newProvider(name, pc, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		retries := cfg.Retries
+		if pc.Retries.Source != config.SourceDefault {
+			retries = pc.Retries.Value
+		}
+
+		p = provider.WithLogging(provider.WithTimeout(p, timeout), logger)
+		if l := pc.RateLimit.Value; l.Requests > 0 {
+			p = provider.WithRateLimit(p, l.Requests, l.Per)
+		}
+		if q := pc.Quota.Value; q.Requests > 0 {
+			p = provider.WithQuota(p, q.Requests, q.Per)
+		}
+		p = provider.WithRetry(p, retries+1, retryBackoff)
+		p = provider.WithCache(p, cfg.ResultCache, resultCacheSize)
+		providers = append(providers, p)
+	}
+
+	return providers, nil
+}
+
+// providerQuotas returns the quotas of the providers named, leaving out
+// those without one.
+func providerQuotas(eff config.Config, names []string) map[string]config.Quota {
+	quotas := make(map[string]config.Quota)
+	for _, name := range names {
+		if q := eff.Provider[name].Quota.Value; q.Requests > 0 {
+			quotas[name] = q
+		}
+	}
+	return quotas
+}
+
+// newProvider constructs the provider name: a custom provider when the
+// configuration file defines it, a built-in one otherwise.
+func newProvider(name string, pc config.ProviderConfig, opts []option.Option) (provider.Provider, error) {
+	if pc.Custom == nil {
+		return registry.New(name, opts...)
+	}
+	if _, ok := registry.Lookup(name); ok {
+		return nil, fmt.Errorf("provider %q is built in and cannot be redefined (%s)", name, pc.Custom.Source)
+	}
+
+	return custom.New(name, custom.Definition{
+		URL:     pc.Custom.Value.URL,
+		Headers: pc.Custom.Value.Headers,
+		Fields:  pc.Custom.Value.Fields,
+	}, opts...)
+}
+
+// loadCountryTable reads the ip2country tables of the data directory, or
+// returns the compact table bundled with the binary when none has been
+// downloaded.
+func loadCountryTable(dataDir string) (*country.Client, error) {
+	dir, err := dataset.Dir(dataDir)
+	if err != nil {
+		// Without a data directory there is simply no downloaded data
+		return country.New(), nil
+	}
+
+	var readers []io.Reader
+	for _, name := range []string{"ip2country-v4", "ip2country-v6"} {
+		d, _ := dataset.Find(name)
+		f, err := os.Open(filepath.Join(dir, d.File))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		readers = append(readers, f)
+	}
+	if len(readers) == 0 {
+		return country.New(), nil
+	}
+
+	table, err := country.Parse(readers...)
+	if err != nil {
+		return nil, fmt.Errorf("reading the country table in %s: %w; run 'ipintel update-data'", dir, err)
+	}
+	return table, nil
+}
+
+// limitRemote keeps the local providers and the first n others, in the
+// configured order, so that no address is sent to more than n third parties.
+func limitRemote(providers []provider.Provider, n int) []provider.Provider {
+	limited := make([]provider.Provider, 0, len(providers))
+	remote := 0
+	for _, p := range providers {
+		if !provider.IsLocal(p) {
+			if remote == n {
+				continue
+			}
+			remote++
+		}
+		limited = append(limited, p)
+	}
+	return limited
+}
+
+// checkHTTPS fails if any of providers is queried in cleartext, naming
+// them all so they can be reconfigured or disabled at once.
+func checkHTTPS(providers []provider.Provider) error {
+	var plain []string
+	for _, p := range providers {
+		if provider.UsesPlainHTTP(p) {
+			plain = append(plain, p.Name())
+		}
+	}
+	if len(plain) == 0 {
+		return nil
+	}
+	return fmt.Errorf("--require-https: providers queried in cleartext: %s; configure an HTTPS base_url or, for ip-api, an api_key, or disable them",
+		strings.Join(plain, ", "))
+}
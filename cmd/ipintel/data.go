@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"api-client/internal/cli"
+	"api-client/internal/dataset"
+)
+
+// runUpdateData implements the "ipintel update-data" subcommand.
+func runUpdateData(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseUpdateDataCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	dir, err := dataset.Dir(cmd.DataDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: no data directory: %v\n", err)
+		return 1
+	}
+
+	datasets, err := selectDatasets(cmd.Datasets)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	manager := dataset.NewManager(dir, &http.Client{Timeout: cmd.Timeout})
+	exitCode := 0
+
+	if !cmd.StatusOnly {
+		for _, d := range datasets {
+			ctx, cancel := context.WithTimeout(context.Background(), cmd.Timeout)
+			_, err := manager.Update(ctx, d)
+			cancel()
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				exitCode = 1
+			}
+		}
+	}
+
+	statuses := make([]dataset.Status, 0, len(datasets))
+	for _, d := range datasets {
+		st, err := manager.Status(d)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		statuses = append(statuses, st)
+	}
+
+	if err := cli.PrintDatasetStatus(os.Stdout, dir, statuses); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		return 1
+	}
+
+	for i, st := range statuses {
+		switch st.State {
+		case dataset.StateStale:
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %s is stale: last updated %s, it should be refreshed every %s\n",
+				st.Name, st.Entry.Updated.Format("2006-01-02"), datasets[i].MaxAge)
+		case dataset.StateCorrupt:
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %s does not match its recorded checksum; run 'ipintel update-data %s'\n",
+				st.Name, st.Name)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
+
+// selectDatasets returns the datasets named, or all of them when names is
+// empty.
+func selectDatasets(names []string) ([]dataset.Dataset, error) {
+	all := dataset.Builtin()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	byName := make(map[string]dataset.Dataset, len(all))
+	known := make([]string, len(all))
+	for i, d := range all {
+		byName[d.Name] = d
+		known[i] = d.Name
+	}
+
+	selected := make([]dataset.Dataset, 0, len(names))
+	for _, name := range names {
+		d, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown dataset %q: expected one of %s", name, strings.Join(known, ", "))
+		}
+		selected = append(selected, d)
+	}
+	return selected, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api-client/internal/cli"
+	"api-client/internal/config"
+	"api-client/internal/dataset"
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+const (
+	// doctorProbe is the address looked up to check each provider: any
+	// public address every provider knows about will do.
+	doctorProbe = "8.8.8.8"
+
+	// maxClockSkew and badClockSkew are the clock offsets, from the time
+	// reported by the providers, beyond which doctor warns and fails.
+	maxClockSkew = 30 * time.Second
+	badClockSkew = 5 * time.Minute
+)
+
+// runDoctor implements the "ipintel doctor" subcommand. It exits non-zero
+// when any check fails.
+func runDoctor(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseDoctorCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var sections []cli.DoctorSection
+
+	eff, err := loadConfig(cmd.ConfigPath, config.Overrides{})
+	if err != nil {
+		sections = append(sections, cli.DoctorSection{Title: "CONFIGURATION", Checks: []cli.DoctorCheck{{
+			Name:   "config",
+			Status: cli.DoctorFail,
+			Detail: err.Error(),
+			Fix:    "fix the configuration file, or see 'ipintel config show'",
+		}}})
+	} else {
+		clock := &clockRecorder{next: &http.Client{Timeout: cmd.Timeout}}
+		sections = append(sections,
+			cli.DoctorSection{Title: "PROVIDERS", Checks: checkProviders(eff, clock, cmd)},
+			cli.DoctorSection{Title: "CLOCK", Checks: []cli.DoctorCheck{clock.check()}},
+		)
+	}
+
+	sections = append(sections, cli.DoctorSection{Title: "OFFLINE DATA", Checks: checkDatasets(cmd.DataDir)})
+
+	if err := cli.PrintDoctor(os.Stdout, sections); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		return 1
+	}
+
+	for _, section := range sections {
+		for _, c := range section.Checks {
+			if c.Status == cli.DoctorFail {
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// checkProviders looks up doctorProbe with every enabled remote provider,
+// concurrently, and explains each failure.
+func checkProviders(eff config.Config, requester provider.HttpRequester, cmd cli.DoctorCommand) []cli.DoctorCheck {
+	providers, err := buildProviders(eff, requester, cli.Config{Timeout: cmd.Timeout, DataDir: cmd.DataDir}, nil)
+	if err != nil {
+		return []cli.DoctorCheck{{Name: "providers", Status: cli.DoctorFail, Detail: err.Error()}}
+	}
+	providers = remoteOnly(providers)
+	if len(providers) == 0 {
+		return []cli.DoctorCheck{{
+			Name:   "providers",
+			Status: cli.DoctorWarn,
+			Detail: "no remote provider is enabled",
+			Fix:    "list providers in the configuration file or IPINTEL_PROVIDERS",
+		}}
+	}
+
+	ip := model.MustParseAddr(doctorProbe)
+	checks := make([]cli.DoctorCheck, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			_, err := p.Check(context.Background(), ip)
+			elapsed := time.Since(start)
+
+			checks[i] = providerCheck(p.Name(), eff.Provider[p.Name()], elapsed, err)
+			if provider.UsesPlainHTTP(p) && checks[i].Status == cli.DoctorOK {
+				checks[i].Detail += ", in cleartext"
+			}
+		}()
+	}
+	wg.Wait()
+
+	return checks
+}
+
+// remoteOnly drops the local providers, which need no checking here.
+func remoteOnly(providers []provider.Provider) []provider.Provider {
+	remote := make([]provider.Provider, 0, len(providers))
+	for _, p := range providers {
+		if !provider.IsLocal(p) {
+			remote = append(remote, p)
+		}
+	}
+	return remote
+}
+
+// providerCheck turns the outcome of a lookup by the named provider into a
+// check, with the fix for the usual causes of failure.
+func providerCheck(name string, pc config.ProviderConfig, elapsed time.Duration, err error) cli.DoctorCheck {
+	c := cli.DoctorCheck{Name: name, Status: cli.DoctorOK, Detail: fmt.Sprintf("answered in %dms", elapsed.Milliseconds())}
+	if pc.APIKey.Value != "" {
+		c.Detail += ", API key accepted"
+	}
+	if err == nil {
+		return c
+	}
+
+	c.Status, c.Detail = cli.DoctorFail, err.Error()
+	env := config.EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+
+	var statusErr provider.StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr) && statusErr.Unauthorized():
+		if pc.APIKey.Value != "" {
+			c.Detail = fmt.Sprintf("API key rejected (%s)", err)
+			c.Fix = fmt.Sprintf("check provider.%s.api_key or %s_API_KEY, from %s", name, env, pc.APIKey.Source)
+		} else {
+			c.Fix = fmt.Sprintf("set an API key in provider.%s.api_key or %s_API_KEY", name, env)
+		}
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests:
+		c.Status = cli.DoctorWarn
+		c.Detail = "rate limited"
+		c.Fix = fmt.Sprintf("wait for the quota to reset, or set an API key for a higher limit (provider.%s.api_key)", name)
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		c.Fix = fmt.Sprintf("raise --timeout, or provider.%s.timeout / %s_TIMEOUT", name, env)
+	case errors.As(err, &netErr):
+		c.Fix = "check network connectivity, DNS and any HTTPS_PROXY setting"
+		if pc.BaseURL.Value != "" {
+			c.Fix = fmt.Sprintf("check provider.%s.base_url (%s) and network connectivity", name, pc.BaseURL.Value)
+		}
+	}
+	return c
+}
+
+// checkDatasets reports the state of every offline dataset. Missing
+// datasets are optional and not counted as problems.
+func checkDatasets(dataDir string) []cli.DoctorCheck {
+	dir, err := dataset.Dir(dataDir)
+	if err != nil {
+		return []cli.DoctorCheck{{Name: "data dir", Status: cli.DoctorWarn, Detail: err.Error(), Fix: "set --data-dir or IPINTEL_DATA_DIR"}}
+	}
+
+	datasets, _ := selectDatasets(nil)
+	manager := dataset.NewManager(dir, nil)
+	checks := make([]cli.DoctorCheck, 0, len(datasets))
+	for _, d := range datasets {
+		st, err := manager.Status(d)
+		if err != nil {
+			return []cli.DoctorCheck{{Name: "data dir", Status: cli.DoctorFail, Detail: err.Error(), Fix: "run 'ipintel update-data' to rewrite " + dir}}
+		}
+
+		c := cli.DoctorCheck{Name: d.Name}
+		switch st.State {
+		case dataset.StateOK:
+			c.Status, c.Detail = cli.DoctorOK, "updated "+st.Entry.Updated.Format("2006-01-02")
+		case dataset.StateMissing:
+			c.Status, c.Detail = cli.DoctorNone, "not downloaded"
+		case dataset.StateStale:
+			c.Status = cli.DoctorWarn
+			c.Detail = fmt.Sprintf("stale: updated %s, refreshed every %s", st.Entry.Updated.Format("2006-01-02"), d.MaxAge)
+			c.Fix = "run 'ipintel update-data " + d.Name + "'"
+		case dataset.StateCorrupt:
+			c.Status, c.Detail = cli.DoctorFail, fmt.Sprintf("corrupt: %v", st.Err)
+			c.Fix = "run 'ipintel update-data " + d.Name + "'"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// clockRecorder is a requester noting, for every response carrying a Date
+// header, how far the local clock is from the server's.
+type clockRecorder struct {
+	next provider.HttpRequester
+
+	mu      sync.Mutex
+	offsets []time.Duration
+}
+
+func (c *clockRecorder) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.next.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		// The server stamped the response somewhere during the request
+		local := start.Add(time.Since(start) / 2)
+		c.mu.Lock()
+		c.offsets = append(c.offsets, local.Sub(date))
+		c.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// check compares the local clock with the median of the server clocks,
+// which Date headers give to the second.
+func (c *clockRecorder) check() cli.DoctorCheck {
+	c.mu.Lock()
+	offsets := append([]time.Duration(nil), c.offsets...)
+	c.mu.Unlock()
+
+	if len(offsets) == 0 {
+		return cli.DoctorCheck{Name: "skew", Status: cli.DoctorNone, Detail: "no provider reported its time"}
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	skew := offsets[len(offsets)/2].Round(time.Second)
+
+	direction := "ahead of"
+	if skew < 0 {
+		direction, skew = "behind", -skew
+	}
+	detail := fmt.Sprintf("%s %s the providers", skew, direction)
+
+	switch {
+	case skew > badClockSkew:
+		return cli.DoctorCheck{Name: "skew", Status: cli.DoctorFail, Detail: detail,
+			Fix: "enable time synchronization (NTP); TLS and cache validation fail with a wrong clock"}
+	case skew > maxClockSkew:
+		return cli.DoctorCheck{Name: "skew", Status: cli.DoctorWarn, Detail: detail,
+			Fix: "enable time synchronization (NTP)"}
+	default:
+		return cli.DoctorCheck{Name: "skew", Status: cli.DoctorOK, Detail: detail}
+	}
+}
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-client/internal/config"
+	"api-client/internal/model"
+	"api-client/internal/providertest"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the end-to-end tests")
+
+// e2eCase runs ipintel with args against the fake provider server, and
+// compares its output with testdata/golden/<name>.golden.
+type e2eCase struct {
+	name  string
+	args  []string
+	stdin string
+	modes map[string]providertest.Mode
+	want  int
+}
+
+var e2eCases = []e2eCase{
+	{name: "text", args: []string{"8.8.8.8"}},
+	{name: "json", args: []string{"-f", "json", "8.8.8.8"}},
+	{name: "json-camel-sorted", args: []string{"-f", "json", "--json-style", "camel", "--sort-keys", "1.1.1.1"}},
+
+	// Batch runs look up one address at a time, so that the quotas the
+	// server reports are deterministic
+	{name: "csv", args: []string{"-f", "csv", "--concurrency", "1", "8.8.8.8", "1.1.1.1"}},
+	{name: "batch-text", args: []string{"--concurrency", "1", "8.8.8.8", "1.1.1.1", "192.0.2.1"}},
+	{name: "batch-json-array", args: []string{"-f", "json", "--json-array", "--concurrency", "1", "8.8.8.8", "1.1.1.1"}},
+	{
+		name:  "batch-csv-input",
+		args:  []string{"-f", "csv", "--concurrency", "1", "--column", "src", "-i", "-"},
+		stdin: "user,src\nalice,8.8.8.8\nbob,1.1.1.1\n",
+	},
+	{name: "query", args: []string{"--query", "results[?error == null].provider", "8.8.8.8"}},
+	{
+		name:  "partial-failure",
+		args:  []string{"8.8.8.8"},
+		modes: map[string]providertest.Mode{providertest.IPAPI: providertest.ModeError, providertest.IPInfo: providertest.ModeThrottle},
+	},
+	{
+		name:  "all-failed",
+		args:  []string{"-f", "json", "8.8.8.8"},
+		modes: map[string]providertest.Mode{providertest.IPAPI: providertest.ModeError, providertest.IPInfo: providertest.ModeError, providertest.IPWhois: providertest.ModeThrottle},
+		want:  1,
+	},
+}
+
+// volatile matches the parts of the output that change from run to run,
+// replaced before comparing with the golden files.
+var volatile = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?(Z|[+-]\d\d:\d\d)`), "<time>"},
+	{regexp.MustCompile(`"(duration_ms|total_duration_ms|durationMs|totalDurationMs)":\s?\d+`), `"$1": 0`},
+	{regexp.MustCompile(`[\d,]+ms\b`), "<ms>"},
+	// Latency columns are as wide as the durations they hold
+	{regexp.MustCompile(` +<ms>`), " <ms>"},
+	{regexp.MustCompile(` +p50 +p90 +p99`), " p50 p90 p99"},
+	{regexp.MustCompile(`127\.0\.0\.1:\d+`), "<server>"},
+}
+
+func TestRun_Golden(t *testing.T) {
+	for _, tc := range e2eCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := providertest.NewServer()
+			defer s.Close()
+			for provider, mode := range tc.modes {
+				s.SetMode(provider, mode)
+			}
+
+			args := append([]string{"--config", writeE2EConfig(t, s), "--no-emoji", "--data-dir", t.TempDir()}, tc.args...)
+			stdout, stderr, code := runCaptured(t, args, tc.stdin)
+			if code != tc.want {
+				t.Fatalf("run() = %d, want %d; stderr:\n%s", code, tc.want, stderr)
+			}
+
+			got := stdout
+			for _, v := range volatile {
+				got = v.re.ReplaceAllString(got, v.repl)
+			}
+
+			golden := filepath.Join("testdata", "golden", tc.name+".golden")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run 'go test ./cmd/ipintel -run TestRun_Golden -update' to create it", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s; run with -update and review the diff\ngot:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestRun_GzipOutput(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "results.json.gz")
+	args := []string{"--config", writeE2EConfig(t, s), "--data-dir", t.TempDir(), "-f", "json", "--concurrency", "1", "-o", path, "8.8.8.8", "1.1.1.1"}
+	stdout, stderr, code := runCaptured(t, args, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want the output in %s only", stdout, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("output is not gzip-compressed: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading the compressed output: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], `{"ip":"1.1.1.1"`) {
+		t.Errorf("decompressed output = %s, want a report per address", data)
+	}
+}
+
+func TestRun_UploadOutput(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	var body []byte
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer storage.Close()
+
+	// The name of a presigned URL ends before its query
+	args := []string{"--config", writeE2EConfig(t, s), "--data-dir", t.TempDir(), "-f", "json", "--concurrency", "1",
+		"-o", storage.URL + "/results.json.gz?X-Amz-Signature=0", "8.8.8.8", "1.1.1.1"}
+	stdout, stderr, code := runCaptured(t, args, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want the output uploaded only", stdout)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("uploaded output is not gzip-compressed: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading the uploaded output: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 {
+		t.Errorf("uploaded output = %s, want a report per address", data)
+	}
+}
+
+func TestRun_RemoteInput(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	var requests int
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("8.8.8.8\n1.1.1.1\n"))
+	}))
+	defer storage.Close()
+
+	args := []string{"--config", writeE2EConfig(t, s), "--data-dir", t.TempDir(), "-f", "csv", "--concurrency", "1",
+		"-i", storage.URL + "/ips.txt?X-Amz-Signature=0"}
+	stdout, stderr, code := runCaptured(t, args, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 3 {
+		t.Errorf("stdout = %s, want a row per address", stdout)
+	}
+	if requests < 2 {
+		t.Errorf("%d requests, want the input streamed once to validate it, again to look it up", requests)
+	}
+}
+
+func TestRun_Publish(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	// A NATS server answering pings and recording what is published
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	published := make(chan string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.WriteString(conn, "INFO {}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 1 && fields[0] == "PING":
+				_, _ = io.WriteString(conn, "PONG\r\n")
+			case len(fields) == 3 && fields[0] == "PUB":
+				n, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, n+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return
+				}
+				published <- fields[1] + " " + string(payload[:n])
+			}
+		}
+	}()
+
+	args := []string{"--config", writeE2EConfig(t, s), "--data-dir", t.TempDir(), "-f", "csv", "--concurrency", "1",
+		"--publish", "nats://" + l.Addr().String() + "/ipintel.reports", "--publish-format", "flat", "8.8.8.8", "1.1.1.1"}
+	_, stderr, code := runCaptured(t, args, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+
+	close(published)
+	var messages []string
+	for message := range published {
+		messages = append(messages, message)
+	}
+	if len(messages) != 2 ||
+		!strings.HasPrefix(messages[0], `ipintel.reports {"ip":"8.8.8.8",`) ||
+		!strings.HasPrefix(messages[1], `ipintel.reports {"ip":"1.1.1.1",`) {
+		t.Errorf("published %q, want a message per address, in order", messages)
+	}
+}
+
+func TestRun_Alerts(t *testing.T) {
+	alerts := make(chan map[string]any, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		alerts <- a
+	}))
+	defer webhook.Close()
+
+	state := filepath.Join(t.TempDir(), "alerts.json")
+	lookup := func(s *providertest.Server) {
+		t.Helper()
+		path := writeE2EConfig(t, s)
+		file := s.Config()
+		file.Alerts = []config.AlertRule{{Name: "asn", Changed: []string{"asn"}, Webhook: webhook.URL}}
+		data, err := json.Marshal(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		args := []string{"--config", path, "--data-dir", t.TempDir(), "--alert-state", state, "-f", "csv", "--concurrency", "1", "8.8.8.8", "1.1.1.1"}
+		if _, stderr, code := runCaptured(t, args, ""); code != 0 {
+			t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+		}
+	}
+
+	// The first run records the reports, the second compares with them
+	s := providertest.NewServer()
+	defer s.Close()
+	lookup(s)
+	lookup(s)
+
+	moved := providertest.DefaultPlaces[model.MustParseAddr("8.8.8.8")]
+	moved.ASN = "AS64500"
+	s = providertest.NewServer(providertest.WithPlace(model.MustParseAddr("8.8.8.8"), moved))
+	defer s.Close()
+	lookup(s)
+
+	close(alerts)
+	var got []map[string]any
+	for a := range alerts {
+		got = append(got, a)
+	}
+	if len(got) != 1 || got[0]["ip"] != "8.8.8.8" || got[0]["rule"] != "asn" {
+		t.Fatalf("alerts = %v, want one for the ASN of 8.8.8.8", got)
+	}
+	changes, _ := json.Marshal(got[0]["changes"])
+	if want := `[{"field":"asn","from":"AS15169","to":"AS64500"}]`; string(changes) != want {
+		t.Errorf("changes = %s, want %s", changes, want)
+	}
+}
+
+func TestRun_ConfigShow(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+	path := writeE2EConfig(t, s)
+
+	stdout, stderr, code := runCaptured(t, []string{"config", "show", "--config", path, "-f", "json", "--quorum", "2", "--timeout", "3s"}, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	var eff struct {
+		Format  config.Value[string]          `json:"format"`
+		Timeout config.Value[config.Duration] `json:"timeout"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &eff); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if eff.Timeout.Value != config.Duration(3*time.Second) || eff.Timeout.Source != "flag:--timeout" {
+		t.Errorf("timeout = %+v, want 3s from --timeout", eff.Timeout)
+	}
+	// -f is the format of config show itself
+	if eff.Format.Value != "text" || strings.HasPrefix(eff.Format.Source, "flag:") {
+		t.Errorf("format = %+v, want the configured text format", eff.Format)
+	}
+
+	_, stderr, code = runCaptured(t, []string{"config", "show", "--help"}, "")
+	if code != 0 || !strings.HasPrefix(stderr, "ipintel - IP Intelligence Lookup Tool") {
+		t.Errorf("run(config show --help) = %d, stderr %q; want the usage", code, stderr)
+	}
+}
+
+func TestRun_SelfUpdate_RequiresReleaseKey(t *testing.T) {
+	var downloads atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/lotabytes/api-client/releases/latest" {
+			downloads.Add(1)
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"tag_name": "v99.0.0",
+			"assets": []map[string]string{
+				{"name": "checksums.txt", "browser_download_url": server.URL + "/checksums.txt"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("IPINTEL_UPDATE_URL", server.URL)
+	defer func(v string) { Version = v }(Version)
+	Version = "1.0.0"
+
+	_, stderr, code := runCaptured(t, []string{"self-update"}, "")
+	if code != 1 || !strings.Contains(stderr, "no release key") || !strings.Contains(stderr, "--insecure") {
+		t.Errorf("run(self-update) = %d, stderr %q; want a refusal without a release key", code, stderr)
+	}
+	if n := downloads.Load(); n != 0 {
+		t.Errorf("self-update downloaded %d files, want none", n)
+	}
+}
+
+func TestRun_Offline(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+	path := writeE2EConfig(t, s)
+
+	country := func(dataDir string) string {
+		t.Helper()
+		stdout, stderr, code := runCaptured(t, []string{"--config", path, "--data-dir", dataDir, "--offline", "-f", "json", "8.8.8.8"}, "")
+		if code != 0 {
+			t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+		}
+		var report struct {
+			Results []struct {
+				Provider string `json:"provider"`
+				Result   struct {
+					CountryCode string `json:"country_code"`
+				} `json:"result"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+			t.Fatalf("decoding %q: %v", stdout, err)
+		}
+		if len(report.Results) != 2 || report.Results[1].Provider != "country" {
+			t.Fatalf("results = %+v, want bogon and the country table alone", report.Results)
+		}
+		return report.Results[1].Result.CountryCode
+	}
+
+	// Out of the box, the bundled table answers
+	if got := country(t.TempDir()); got != "US" {
+		t.Errorf("country = %q, want US from the bundled table", got)
+	}
+
+	// Once downloaded, the full table replaces it
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ip2country-v4.tsv"), []byte("8.8.8.0\t8.8.8.255\tCH\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := country(dir); got != "CH" {
+		t.Errorf("country = %q, want CH from the downloaded table", got)
+	}
+
+	if n := s.Requests("ipinfo"); n != 0 {
+		t.Errorf("ipinfo got %d requests offline, want none", n)
+	}
+}
+
+func TestRun_MaxProviders(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+	path := writeE2EConfig(t, s)
+
+	// The country table does not spare the remote providers
+	_, stderr, code := runCaptured(t, []string{"--config", path, "--max-providers", "2", "-f", "json", "8.8.8.8"}, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	for name, want := range map[string]int{providertest.IPAPI: 1, providertest.IPInfo: 1, providertest.IPWhois: 0} {
+		if n := s.Requests(name); n != want {
+			t.Errorf("%s got %d requests, want %d", name, n, want)
+		}
+	}
+
+	// Special-use addresses are not sent anywhere
+	_, stderr, code = runCaptured(t, []string{"--config", path, "--max-providers", "2", "-f", "json", "10.0.0.1"}, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if n := s.Requests(providertest.IPAPI) + s.Requests(providertest.IPInfo); n != 2 {
+		t.Errorf("providers got %d requests in all, want none for 10.0.0.1", n)
+	}
+}
+
+func TestRun_Offline_RefusesNetwork(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte("8.8.8.8\n"))
+	}))
+	defer server.Close()
+
+	s := providertest.NewServer()
+	defer s.Close()
+	path := writeE2EConfig(t, s)
+
+	_, stderr, code := runCaptured(t, []string{"--config", path, "--offline", "--input-file", server.URL + "/ips.txt"}, "")
+	if code != 1 || !strings.Contains(stderr, "uses the network, which --offline rules out") {
+		t.Errorf("run() = %d, stderr %q; want the URL input refused", code, stderr)
+	}
+
+	file := s.Config()
+	file.Alerts = []config.AlertRule{{Name: "asn", Changed: []string{"asn"}, Webhook: server.URL}}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--config", path, "--data-dir", t.TempDir(), "--alert-state", filepath.Join(t.TempDir(), "alerts.json"), "--offline", "8.8.8.8"}
+	_, stderr, code = runCaptured(t, args, "")
+	if code != 1 || !strings.Contains(stderr, `alert "asn" notifies over the network`) {
+		t.Errorf("run() = %d, stderr %q; want the alert webhook refused", code, stderr)
+	}
+
+	if n := requests.Load(); n != 0 || s.Requests("ipinfo") != 0 {
+		t.Errorf("offline runs made %d requests, want none", n)
+	}
+}
+
+func TestRun_EmailTo(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	// An SMTP server answering every command and recording the message
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = io.WriteString(conn, "220 fake\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch strings.ToUpper(strings.TrimSpace(line)) {
+			case "DATA":
+				_, _ = io.WriteString(conn, "354 go ahead\r\n")
+				var msg strings.Builder
+				for line, err = r.ReadString('\n'); err == nil && line != ".\r\n"; line, err = r.ReadString('\n') {
+					msg.WriteString(line)
+				}
+				messages <- msg.String()
+				_, _ = io.WriteString(conn, "250 queued\r\n")
+			case "QUIT":
+				_, _ = io.WriteString(conn, "221 bye\r\n")
+				return
+			default:
+				_, _ = io.WriteString(conn, "250 ok\r\n")
+			}
+		}
+	}()
+
+	path := writeE2EConfig(t, s)
+	file := s.Config()
+	file.SMTP = config.SMTPFile{Host: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port, From: "ipintel@example.com"}
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"--config", path, "--data-dir", t.TempDir(), "-f", "csv", "--concurrency", "1",
+		"--filter", `country_code != "US"`, "--email-to", "soc@example.com", "8.8.8.8", "1.1.1.1"}
+	if _, stderr, code := runCaptured(t, args, ""); code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+
+	select {
+	case msg := <-messages:
+		for _, want := range []string{"To: soc@example.com", "Subject: ipintel report: 2 addresses, ", "| Australia (AU) | 1 |"} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("message missing %q:\n%s", want, msg)
+			}
+		}
+	default:
+		t.Fatal("no message sent")
+	}
+}
+
+func TestRun_Quota(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	path := writeE2EConfig(t, s)
+	t.Setenv("IPINTEL_IPINFO_QUOTA", "2/200ms")
+	t.Setenv("IPINTEL_IP_API_QUOTA", "3/100ms")
+
+	args := []string{"--config", path, "--data-dir", t.TempDir(), "-f", "csv", "--concurrency", "3", "8.8.8.8", "1.1.1.1", "9.9.9.9"}
+	start := time.Now()
+	_, stderr, code := runCaptured(t, args, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if want := "Estimated duration: at least 200ms, paced by the quota of ipinfo (2/200ms)\n"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("run took %s, want the third ipinfo lookup held back 200ms", elapsed)
+	}
+	if got := s.Requests("ipinfo"); got != 3 {
+		t.Errorf("ipinfo requests = %d, want 3", got)
+	}
+}
+
+func TestRun_Retries(t *testing.T) {
+	s := providertest.NewServer(
+		providertest.WithMode("ipinfo", providertest.ModeThrottle),
+		providertest.WithMode("ipwhois", providertest.ModeThrottle),
+	)
+	defer s.Close()
+
+	path := writeE2EConfig(t, s)
+	t.Setenv("IPINTEL_IPWHOIS_RETRIES", "0")
+
+	args := []string{"--config", path, "--data-dir", t.TempDir(), "-f", "json", "--retries", "1", "8.8.8.8"}
+	if _, stderr, code := runCaptured(t, args, ""); code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if got := s.Requests("ipinfo"); got != 2 {
+		t.Errorf("ipinfo requests = %d, want 2 with a retry", got)
+	}
+	if got := s.Requests("ipwhois"); got != 1 {
+		t.Errorf("ipwhois requests = %d, want 1 as its retries are configured", got)
+	}
+}
+
+func TestRun_ResultCache(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	path := writeE2EConfig(t, s)
+	args := []string{"--config", path, "--data-dir", t.TempDir(), "-f", "csv", "--concurrency", "1",
+		"--result-cache", "1m", "--debug", "8.8.8.8", "8.8.8.8"}
+	_, stderr, code := runCaptured(t, args, "")
+	if code != 0 {
+		t.Fatalf("run() = %d; stderr:\n%s", code, stderr)
+	}
+	if got := s.Requests("ipinfo"); got != 1 {
+		t.Errorf("ipinfo requests = %d, want the second lookup answered from memory", got)
+	}
+	if want := `msg="check succeeded" provider=ipinfo ip=8.8.8.8`; strings.Count(stderr, want) != 1 {
+		t.Errorf("stderr = %q, want %q logged once", stderr, want)
+	}
+}
+
+// writeE2EConfig writes a configuration file pointing the providers at s,
+// and points the user directories at temporary ones so that no state is
+// read from or left in the real ones.
+func writeE2EConfig(t *testing.T, s *providertest.Server) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv("IPINTEL_NO_UPDATE_CHECK", "1")
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); strings.HasPrefix(name, "IPINTEL_") && name != "IPINTEL_NO_UPDATE_CHECK" {
+			t.Setenv(name, "")
+		}
+	}
+
+	data, err := json.Marshal(s.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(home, "config.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runCaptured calls run with args, feeding it stdin and capturing what it
+// writes to the standard outputs.
+func runCaptured(t *testing.T, args []string, stdin string) (stdout, stderr string, code int) {
+	t.Helper()
+
+	in, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = in.Close() }()
+
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldIn, oldOut, oldErr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = in, outW, errW
+	defer func() { os.Stdin, os.Stdout, os.Stderr = oldIn, oldOut, oldErr }()
+
+	var outBuf, errBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); _, _ = io.Copy(&outBuf, outR) }()
+	go func() { defer wg.Done(); _, _ = io.Copy(&errBuf, errR) }()
+
+	code = run(args)
+	_ = outW.Close()
+	_ = errW.Close()
+	wg.Wait()
+	return outBuf.String(), errBuf.String(), code
+}
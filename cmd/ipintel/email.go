@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"api-client/internal/cli"
+	"api-client/internal/config"
+	"api-client/internal/mail"
+)
+
+// reportMailer mails the summary report of a batch run with --email-to.
+type reportMailer struct {
+	server  mail.Server
+	from    string
+	to      []string
+	subject string
+}
+
+// openMailer returns the mailer of --email-to, through the smtp server of
+// the configuration, or nil without --email-to.
+func openMailer(cfg cli.Config, eff config.Config) (*reportMailer, error) {
+	if len(cfg.EmailTo) == 0 {
+		return nil, nil
+	}
+	smtp := eff.SMTP
+	if smtp.Host.Value == "" || smtp.From.Value == "" {
+		return nil, fmt.Errorf("--email-to: the smtp section of the configuration needs a host and a from address")
+	}
+	return &reportMailer{
+		server: mail.Server{
+			Host:     smtp.Host.Value,
+			Port:     smtp.Port.Value,
+			Username: smtp.Username.Value,
+			Password: smtp.Password.Value,
+		},
+		from:    smtp.From.Value,
+		to:      cfg.EmailTo,
+		subject: cfg.EmailSubject,
+	}, nil
+}
+
+// Send mails report.
+func (m *reportMailer) Send(report *cli.EmailReport) error {
+	subject := m.subject
+	if subject == "" {
+		subject = report.Subject()
+	}
+
+	var msg bytes.Buffer
+	header := cli.EmailHeader{From: m.from, To: m.to, Subject: subject, Date: time.Now()}
+	if err := cli.WriteReportEmail(&msg, header, report); err != nil {
+		return fmt.Errorf("--email-to: %w", err)
+	}
+	if err := mail.Send(m.server, m.from, m.to, msg.Bytes()); err != nil {
+		return fmt.Errorf("--email-to: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"api-client/internal/aggregator"
+	"api-client/internal/batch"
+	"api-client/internal/cli"
+	"api-client/internal/config"
+	"api-client/internal/evaluate"
+	"api-client/internal/model"
+)
+
+// runEvaluate implements the "ipintel evaluate" subcommand.
+func runEvaluate(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseEvaluateCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	eff, err := loadConfig(cmd.ConfigPath, config.Overrides{})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !slices.Contains(eff.Providers.Value, cmd.Reference) {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: --reference: %q is not an enabled provider (enabled: %s)\n",
+			cmd.Reference, strings.Join(eff.Providers.Value, ", "))
+		return 1
+	}
+
+	input, err := openInput(cli.Config{
+		Addresses:   cmd.Addresses,
+		InputFile:   cmd.InputFile,
+		InputFormat: batch.InputText,
+		SkipInvalid: cmd.SkipInvalid,
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer func() { _ = input.Close() }()
+
+	src, err := input.Source()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	cfg := cli.Config{Timeout: cmd.Timeout}
+	providers, err := buildProviders(eff, &http.Client{Timeout: cmd.Timeout}, cfg, nil)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Every provider is asked, whatever its tier, so that all are scored
+	agg := aggregator.New(providers...)
+	runner := batch.New(agg, batch.WithWorkers(cmd.Concurrency))
+
+	scorecard := evaluate.New(cmd.Reference)
+	err = runner.Stream(context.Background(), src, func(report model.Report) error {
+		scorecard.Add(report)
+		return nil
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := cli.PrintScorecard(os.Stdout, scorecard, cmd.Format); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+		return 1
+	}
+
+	if scorecard.ReferenceFailed == scorecard.Lookups {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %s answered none of the lookups\n", cmd.Reference)
+		return 1
+	}
+	return 0
+}
@@ -8,14 +8,22 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
+
+	"golang.org/x/text/language"
 
 	"api-client/internal/aggregator"
+	"api-client/internal/anycast"
 	"api-client/internal/cli"
+	"api-client/internal/dnscache"
+	"api-client/internal/hook"
+	"api-client/internal/latency"
 	"api-client/internal/model"
+	"api-client/internal/policy"
 	"api-client/internal/provider"
-	"api-client/internal/provider/ipapi"
-	"api-client/internal/provider/ipinfo"
-	"api-client/internal/provider/ipwhois"
+	"api-client/internal/provider/httpcache"
+	"api-client/internal/sign"
+	"api-client/internal/transition"
 )
 
 // Version is set at build time via -ldflags.
@@ -28,6 +36,27 @@ func main() {
 func run(args []string) int {
 	parser := cli.NewParser()
 
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+			return runConfig(parser, args[1:])
+		case "update-data":
+			return runUpdateData(parser, args[1:])
+		case "doctor":
+			return runDoctor(parser, args[1:])
+		case "self-update":
+			return runSelfUpdate(parser, args[1:])
+		case "abuse":
+			return runAbuse(parser, args[1:])
+		case "evaluate":
+			return runEvaluate(parser, args[1:])
+		case "verify":
+			return runVerify(parser, args[1:])
+		case "run":
+			return runScheduled(parser, args[1:])
+		}
+	}
+
 	cfg, err := parser.Parse(args)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -41,9 +70,28 @@ func run(args []string) int {
 
 	if cfg.ShowVersion {
 		parser.PrintVersion(Version)
+		noticeNewVersion()
 		return 0
 	}
 
+	return lookup(parser, cfg)
+}
+
+// lookup looks up the addresses of cfg, parsed by parser, and writes their
+// reports, returning the exit code.
+func lookup(parser *cli.Parser, cfg cli.Config) (code int) {
+	eff, err := loadConfig(cfg.ConfigPath, overrides(parser, cfg))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if cfg.Format, err = cli.ParseFormat(eff.Format.Value); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	cfg.Timeout = time.Duration(eff.Timeout.Value)
+
 	if cfg.IPAddress == "-" {
 		scanner := bufio.NewScanner(os.Stdin)
 		if !scanner.Scan() {
@@ -65,44 +113,338 @@ func run(args []string) int {
 		return 1
 	}
 
-	// Parse and validate the IP address
-	ip, err := model.ParseAddr(cfg.IPAddress)
+	input, err := openInput(cfg)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return 1
 	}
+	defer func() { _ = input.Close() }()
+
+	batchMode := cfg.InputFile != "" || input.Len() > 1
+	if input.Len() == 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: no IP addresses to look up\n")
+		return 1
+	}
+
+	first, err := input.First()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	ip := first.IP
 
 	// Warn if IP is not globally routable
-	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() {
+	if !batchMode && (ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified()) {
 		_, _ = fmt.Fprintf(os.Stderr, "Warning: %s is not a globally routable address. Results may be limited.\n\n", ip)
 	}
 
-	httpClient := &http.Client{Timeout: cfg.Timeout}
+	dialer := newDialer(eff)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	var resolver *dnscache.Resolver
+	if cfg.DNSTTL > 0 {
+		resolver = dnscache.New(cfg.DNSTTL, dialer)
+		transport.DialContext = resolver.DialContext
+	}
+	var requester provider.HttpRequester = &http.Client{Timeout: cfg.Timeout, Transport: transport}
+	if cfg.RequireHTTPS {
+		requester = provider.WithHTTPSOnly(requester)
+	}
+	// The limit sits below the cache so that cache hits never wait for a slot
+	requester = provider.WithMaxInflight(requester, cfg.MaxInflight)
 
-	providers := []provider.Provider{
-		ipapi.New(httpClient),
-		ipinfo.New(httpClient),
-		ipwhois.New(httpClient),
+	var cacheKey []byte
+	if cfg.CacheKey != "" {
+		if cacheKey, err = loadCacheKey(cfg.CacheKey); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --cache-key: %v\n", err)
+			return 1
+		}
+	}
+
+	var cache *httpcache.Requester
+	if cfg.CacheDir != "" {
+		var storeOpts []httpcache.DirOption
+		if cacheKey != nil {
+			storeOpts = append(storeOpts, httpcache.WithKey(cacheKey))
+		}
+		store, err := httpcache.NewDirStore(cfg.CacheDir, storeOpts...)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		cache = httpcache.New(requester, store)
+		requester = cache
+	}
+
+	var latencies *latency.Store
+	if cfg.AutoTimeout {
+		if latencies, err = loadLatencies(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer func() {
+			if err := latencies.Save(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: saving latency history: %v\n", err)
+			}
+		}()
+	}
+
+	providers, err := buildProviders(eff, requester, cfg, latencies)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	switch {
+	case cfg.Offline:
+		providers = limitRemote(providers, 0)
+	case cfg.MaxProviders > 0:
+		providers = limitRemote(providers, cfg.MaxProviders)
+	}
+	if cfg.RequireHTTPS {
+		if err := checkHTTPS(providers); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if cfg.DryRun {
+		target := ip
+		if t, ok := transition.Detect(ip); ok && (cfg.LookupEmbedded || t.Mechanism == transition.NAT64) {
+			target = t.IPv4
+		}
+
+		descriptions := make([]provider.Description, len(providers))
+		for i, p := range providers {
+			descriptions[i] = provider.Describe(p, target)
+		}
+		if err := cli.PrintDryRun(os.Stdout, cfg, ip, descriptions); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	anycastList, err := loadAnycastList(cfg.AnycastList)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	engine, err := loadPolicy(eff)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	hooks, err := loadHooks(eff)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
 	}
 
-	agg := aggregator.New(providers...)
+	mailer, err := openMailer(cfg, eff)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
-	defer cancel()
+	alerts, alertState, err := loadAlerts(eff, cfg.AlertState, cfg.Offline, cacheKey)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if alerts != nil {
+		defer func() {
+			_ = alerts.Close()
+			if err := alertState.Save(); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: saving alert state: %v\n", err)
+			}
+		}()
+	}
 
-	report := agg.Lookup(ctx, ip)
+	aggOpts := []aggregator.Option{
+		aggregator.WithQuorum(cfg.Quorum),
+		aggregator.WithHedgeDelay(cfg.HedgeDelay),
+		aggregator.WithConsensusOptions(model.ConsensusOptions{
+			MinAgreement: cfg.MinAgreement,
+			Language:     cfg.Language,
+			Risk:         cfg.Risk,
+			Disabled:     cfg.NoConsensus,
+		}),
+	}
+	if len(eff.Secondary.Value) > 0 {
+		aggOpts = append(aggOpts, aggregator.WithSecondary(eff.Secondary.Value...))
+	}
+	if len(eff.Shadow.Value) > 0 {
+		aggOpts = append(aggOpts, aggregator.WithShadow(eff.Shadow.Value...))
+	}
+	if cfg.MaxProviders > 0 {
+		aggOpts = append(aggOpts, aggregator.WithLocalFirst())
+	}
+	if latencies != nil {
+		aggOpts = append(aggOpts, aggregator.WithLatencyRecorder(latencies))
+	}
+	agg := aggregator.NewWithOptions(providers, aggOpts...)
 
-	// Format and output the report
-	formatter := cli.NewFormatter(os.Stdout)
-	if err := formatter.Format(report, cfg.Format); err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+	formatterOpts := []cli.FormatterOption{
+		cli.WithWide(cfg.Wide),
+		cli.WithVerbose(cfg.Verbose),
+		cli.WithMap(cfg.Map),
+		cli.WithGroupBy(cfg.GroupBy),
+		cli.WithNoEmoji(cfg.NoEmoji),
+		cli.WithJSONStyle(cfg.JSONStyle),
+		cli.WithSortedKeys(cfg.SortKeys),
+		cli.WithJSONArray(cfg.JSONArray),
+		cli.WithTiming(cfg.Timing),
+		cli.WithQuery(cfg.Query),
+	}
+	if cfg.Language != "" {
+		formatterOpts = append(formatterOpts, cli.WithLanguage(language.Make(cfg.Language)))
+	}
+	if cfg.SignKey != "" {
+		key, err := sign.LoadPrivateKey(cfg.SignKey)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --sign-key: %v\n", err)
+			return 1
+		}
+		formatterOpts = append(formatterOpts, cli.WithSigner(sign.NewSigner(key, Version)))
+	}
+	out, err := openOutput(cfg)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return 1
 	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			code = max(code, 1)
+		}
+	}()
+	formatter := cli.NewFormatter(out, formatterOpts...)
+
+	var looker hook.Looker = transition.NewResolver(agg, cfg.LookupEmbedded)
+	if hooks != nil {
+		looker = hooks.Wrap(looker)
+	}
+
+	if batchMode {
+		// A provider whose name does not resolve is reported once, up front
+		if resolver != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+			if err := resolver.Prime(ctx, provider.Hosts(providers)...); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			cancel()
+		}
+		quotas := providerQuotas(eff, agg.ProviderNames())
+		return runBatch(cfg, agg, looker, anycastList, engine, hooks, alerts, mailer, quotas, input, formatter)
+	}
+
+	report := looker.Lookup(context.Background(), ip)
+	report.Meta = newMeta(cfg, agg)
+	if cache != nil {
+		report.Meta.CacheHits = cache.Hits()
+	}
+	report.IsAnycast = anycastList.Contains(ip)
+	if engine != nil {
+		decision := engine.Evaluate(report)
+		report.Policy = &decision
+	}
+
+	// Format and output the report, unless filtered out, and publish it
+	if cfg.Filter == nil || cfg.Filter.Match(report) {
+		if err := formatter.Format(report, cfg.Format); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error formatting output: %v\n", err)
+			return 1
+		}
+		publisher, err := openPublisher(cfg, formatter, nil)
+		if err == nil && publisher != nil {
+			if err = publisher.Publish(report); err == nil {
+				err = publisher.Close()
+			} else {
+				_ = publisher.Close()
+			}
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if hooks != nil {
+		if err := hooks.After(context.Background(), report); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if alerts != nil {
+		alerts.Observe(context.Background(), report)
+	}
 
 	// Return non-zero if all checkers failed
-	if report.SuccessCount() == 0 && len(report.Results) > 0 {
+	if report.AllFailed() {
 		return 1
 	}
 
+	if report.Policy != nil {
+		return policy.ExitCode(report.Policy.Action)
+	}
 	return 0
 }
+
+// loadLatencies reads the latency history from its default location.
+func loadLatencies() (*latency.Store, error) {
+	path, err := latency.DefaultPath()
+	if err != nil {
+		return nil, fmt.Errorf("no location for the latency history: %w", err)
+	}
+	return latency.Load(path)
+}
+
+// loadCacheKey reads the key encrypting the response cache.
+func loadCacheKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return httpcache.ParseKey(data)
+}
+
+// loadAnycastList returns the bundled anycast prefixes, extended with those
+// in path if set.
+func loadAnycastList(path string) (*anycast.List, error) {
+	list := anycast.Default()
+	if path == "" {
+		return list, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	extra, err := anycast.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	list.Merge(extra)
+
+	return list, nil
+}
+
+// newMeta describes the run configuration for inclusion in reports.
+func newMeta(cfg cli.Config, agg *aggregator.Aggregator) *model.Meta {
+	strategy := model.ConsensusMajority
+	if cfg.NoConsensus {
+		strategy = model.ConsensusNone
+	}
+	return &model.Meta{
+		Version:           Version,
+		Providers:         agg.ProviderNames(),
+		ConsensusStrategy: strategy,
+		Timeout:           cfg.Timeout,
+		MinAgreement:      cfg.MinAgreement,
+		Language:          cfg.Language,
+	}
+}
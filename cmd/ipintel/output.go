@@ -0,0 +1,65 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"api-client/internal/cli"
+	"api-client/internal/remote"
+)
+
+// output is where reports are written: standard output, the --output file
+// or an upload to the --output URL, compressed with gzip for --gzip or a
+// name ending in .gz.
+type output struct {
+	io.Writer
+	file io.WriteCloser
+	gz   *gzip.Writer
+}
+
+// openOutput opens the output of cfg, creating or truncating its file, or
+// starting its upload.
+func openOutput(cfg cli.Config) (*output, error) {
+	out := &output{Writer: os.Stdout}
+	name := cfg.Output
+	if remote.IsURL(cfg.Output) {
+		w, err := remote.Create(cfg.Output)
+		if err != nil {
+			return nil, err
+		}
+		out.file, out.Writer = w, w
+
+		// The query of a presigned URL follows the object name
+		u, _ := url.Parse(cfg.Output)
+		name = u.Path
+	} else if cfg.Output != "" {
+		f, err := os.Create(cfg.Output)
+		if err != nil {
+			return nil, err
+		}
+		out.file, out.Writer = f, f
+	}
+
+	if cfg.Gzip || strings.HasSuffix(name, ".gz") {
+		out.gz = gzip.NewWriter(out.Writer)
+		out.Writer = out.gz
+	}
+	return out, nil
+}
+
+// Close ends the gzip stream and closes the file or completes the upload,
+// if any. Standard output is left open.
+func (o *output) Close() error {
+	var errs []error
+	if o.gz != nil {
+		errs = append(errs, o.gz.Close())
+	}
+	if o.file != nil {
+		errs = append(errs, o.file.Close())
+	}
+	return errors.Join(errs...)
+}
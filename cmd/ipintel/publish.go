@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"api-client/internal/cli"
+	"api-client/internal/model"
+	"api-client/internal/publish"
+)
+
+// reportPublisher publishes reports with --publish, serialized by the
+// formatter of the output.
+type reportPublisher struct {
+	publisher    publish.Publisher
+	formatter    *cli.Formatter
+	format       cli.PublishFormat
+	inputColumns []string
+}
+
+// openPublisher connects to the --publish URL of cfg, returning nil if there
+// is none. inputColumns are the passthrough fields of the batch input.
+func openPublisher(cfg cli.Config, formatter *cli.Formatter, inputColumns []string) (*reportPublisher, error) {
+	if cfg.Publish == "" {
+		return nil, nil
+	}
+	publisher, err := publish.New(cfg.Publish)
+	if err != nil {
+		return nil, fmt.Errorf("--publish: %w", err)
+	}
+	return &reportPublisher{
+		publisher:    publisher,
+		formatter:    formatter,
+		format:       cfg.PublishFormat,
+		inputColumns: inputColumns,
+	}, nil
+}
+
+// Publish queues the message of report.
+func (p *reportPublisher) Publish(report model.Report) error {
+	key, value, err := p.formatter.Message(report, p.format, p.inputColumns)
+	if err != nil {
+		return fmt.Errorf("--publish: %w", err)
+	}
+	if err := p.publisher.Publish(key, value); err != nil {
+		return fmt.Errorf("--publish: %w", err)
+	}
+	return nil
+}
+
+// Close delivers the queued messages and disconnects.
+func (p *reportPublisher) Close() error {
+	if err := p.publisher.Close(); err != nil {
+		return fmt.Errorf("--publish: %w", err)
+	}
+	return nil
+}
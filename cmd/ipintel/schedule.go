@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"api-client/internal/cli"
+	"api-client/internal/cron"
+	"api-client/internal/remote"
+)
+
+// runScheduled implements the "ipintel run" subcommand: it runs the lookup
+// of its options at every time of --schedule, writing the output of each
+// run to a file or URL named after its time, until SIGINT or SIGTERM.
+func runScheduled(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseRunCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if cmd.Config.ShowHelp {
+		parser.PrintUsage()
+		return 0
+	}
+	if cmd.Config.ShowVersion {
+		parser.PrintVersion(Version)
+		return 0
+	}
+	// Errors in the options are reported now rather than at the first run
+	if err := cmd.Config.Validate(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		_, _ = fmt.Fprintf(os.Stderr, "Use --help for usage information.\n")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s := &scheduler{
+		schedule: cmd.Schedule,
+		jitter:   cmd.Jitter,
+		now:      time.Now,
+		wait:     wait,
+		run: func(at time.Time) int {
+			cfg := cmd.Config
+			cfg.Output = timestamped(cfg.Output, at)
+			return lookup(parser, cfg)
+		},
+	}
+	return s.loop(ctx)
+}
+
+// scheduler runs a lookup at every time of a schedule.
+type scheduler struct {
+	schedule *cron.Schedule
+	jitter   time.Duration
+	now      func() time.Time
+	wait     func(ctx context.Context, d time.Duration) error
+	run      func(at time.Time) int
+}
+
+// loop waits for each time of the schedule, delayed by up to the jitter,
+// and runs the lookup of that time, until ctx is done. A run still going
+// at the next time delays it: runs never overlap, and the times missed are
+// skipped. A run failing does not stop the next ones; one interrupted does,
+// and its exit code is returned.
+func (s *scheduler) loop(ctx context.Context) int {
+	for {
+		at := s.schedule.Next(s.now())
+		if at.IsZero() {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --schedule matches no time in the next years\n")
+			return 1
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Next run at %s\n", at.Format(time.RFC3339))
+
+		delay := at.Sub(s.now())
+		if s.jitter > 0 {
+			delay += rand.N(s.jitter)
+		}
+		if err := s.wait(ctx, delay); err != nil {
+			return 0
+		}
+
+		if code := s.run(at); code == exitInterrupted {
+			return code
+		}
+	}
+}
+
+// wait waits for d, or returns the error of ctx once it is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// timestamped returns the output name, a file or URL, with the time of a
+// run inserted before its extensions: results.csv.gz becomes
+// results-20240115T100000Z.csv.gz. Standard output, the empty name, is
+// left as is.
+func timestamped(name string, at time.Time) string {
+	if name == "" {
+		return ""
+	}
+	stamp := at.UTC().Format("20060102T150405Z")
+
+	if remote.IsURL(name) {
+		u, err := url.Parse(name)
+		if err != nil {
+			return name
+		}
+		dir, base := path.Split(u.Path)
+		u.Path, u.RawPath = dir+stampName(base, stamp), ""
+		return u.String()
+	}
+	dir, base := filepath.Split(name)
+	return dir + stampName(base, stamp)
+}
+
+// stampName inserts stamp into the file name base, before its first dot.
+func stampName(base, stamp string) string {
+	if base == "" {
+		return stamp
+	}
+	// The leading dot of a hidden file does not start an extension
+	if i := strings.Index(base[1:], "."); i >= 0 {
+		return base[:i+1] + "-" + stamp + base[i+1:]
+	}
+	return base + "-" + stamp
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"api-client/internal/cron"
+)
+
+func TestTimestamped(t *testing.T) {
+	at := time.Date(2024, 1, 15, 11, 0, 0, 0, time.FixedZone("CET", 3600))
+	tests := map[string]string{
+		"":                             "",
+		"results.csv":                  "results-20240115T100000Z.csv",
+		"results.csv.gz":               "results-20240115T100000Z.csv.gz",
+		"results":                      "results-20240115T100000Z",
+		".results":                     ".results-20240115T100000Z",
+		filepath.Join("out.d", "ips"):  filepath.Join("out.d", "ips-20240115T100000Z"),
+		"s3://bucket/runs/ips.parquet": "s3://bucket/runs/ips-20240115T100000Z.parquet",
+		"https://host/ips.json?a=b":    "https://host/ips-20240115T100000Z.json?a=b",
+	}
+	for name, want := range tests {
+		if got := timestamped(name, at); got != want {
+			t.Errorf("timestamped(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestScheduler_Loop(t *testing.T) {
+	schedule, err := cron.Parse("*/15 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := time.Date(2024, 1, 15, 10, 20, 0, 0, time.UTC)
+	var runs []time.Time
+	s := &scheduler{
+		schedule: schedule,
+		jitter:   time.Minute,
+		now:      func() time.Time { return clock },
+		wait: func(ctx context.Context, d time.Duration) error {
+			if d < 0 {
+				t.Errorf("wait(%v), want a positive delay", d)
+			}
+			clock = clock.Add(d)
+			return ctx.Err()
+		},
+		run: func(at time.Time) int {
+			runs = append(runs, at)
+			if clock.Sub(at) < 0 || clock.Sub(at) >= time.Minute {
+				t.Errorf("run of %v started at %v, want within the jitter", at, clock)
+			}
+			// The second run takes longer than the interval
+			if len(runs) == 2 {
+				clock = clock.Add(20 * time.Minute)
+			}
+			if len(runs) == 3 {
+				cancel()
+			}
+			return 1
+		},
+	}
+
+	if code := s.loop(ctx); code != 0 {
+		t.Errorf("loop() = %d, want 0 once stopped", code)
+	}
+	want := []time.Time{
+		time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 15, 10, 45, 0, 0, time.UTC),
+		time.Date(2024, 1, 15, 11, 15, 0, 0, time.UTC),
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs = %v, want %v", runs, want)
+	}
+	for i := range want {
+		if !runs[i].Equal(want[i]) {
+			t.Errorf("runs = %v, want %v, skipping the time missed", runs, want)
+			break
+		}
+	}
+}
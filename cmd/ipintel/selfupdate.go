@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"api-client/internal/cli"
+	"api-client/internal/update"
+)
+
+// ReleaseKey is the base64 Ed25519 public key release checksums are signed
+// with, set at build time via -ldflags. Without it, self-update refuses to
+// replace the binary unless --insecure is given, as the checksums come from
+// the same place as the binary and prove nothing about who published it.
+var ReleaseKey = ""
+
+// versionCheckTimeout bounds the release check made by --version, which
+// must not hold the user up when GitHub is slow or unreachable.
+const versionCheckTimeout = 2 * time.Second
+
+// runSelfUpdate implements the "ipintel self-update" subcommand.
+func runSelfUpdate(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseSelfUpdateCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var publicKey ed25519.PublicKey
+	if ReleaseKey != "" {
+		if publicKey, err = update.ParsePublicKey(ReleaseKey); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: release key: %v\n", err)
+			return 1
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.Timeout)
+	defer cancel()
+
+	client := update.NewClient(&http.Client{}, os.Getenv("IPINTEL_UPDATE_URL"))
+	release, err := client.Latest(ctx)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if !update.Newer(Version, release.Version()) {
+		_, _ = fmt.Fprintf(os.Stdout, "ipintel %s is up to date (latest release: %s)\n", Version, release.Version())
+		return 0
+	}
+	if cmd.CheckOnly {
+		_, _ = fmt.Fprintf(os.Stdout, "ipintel %s is available (installed: %s): %s\n", release.Version(), Version, release.URL)
+		return 0
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: cannot locate the running executable: %v\n", err)
+		return 1
+	}
+
+	if publicKey == nil {
+		if !cmd.Insecure {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: this build has no release key to verify the signature of %s with; install it by hand, or pass --insecure to trust its checksum alone\n", release.Version())
+			return 1
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: this build has no release key; only the checksum is verified\n")
+	}
+	data, err := client.Download(ctx, release, publicKey)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := update.Replace(exe, data); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: replacing %s: %v\n", exe, err)
+		return 1
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Updated %s from %s to %s\n", exe, Version, release.Version())
+	return 0
+}
+
+// noticeNewVersion tells the user, on stderr, when a newer release is
+// available. It stays silent on any error, for development builds and
+// when IPINTEL_NO_UPDATE_CHECK is set.
+func noticeNewVersion() {
+	if !update.IsRelease(Version) || os.Getenv("IPINTEL_NO_UPDATE_CHECK") != "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+
+	release, err := update.NewClient(&http.Client{}, os.Getenv("IPINTEL_UPDATE_URL")).Latest(ctx)
+	if err != nil || !update.Newer(Version, release.Version()) {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "A new version is available: %s; run 'ipintel self-update' to install it\n", release.Version())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"api-client/internal/cli"
+	"api-client/internal/sign"
+	"api-client/internal/verify"
+)
+
+// runVerify implements the "ipintel verify" subcommand.
+func runVerify(parser *cli.Parser, args []string) int {
+	cmd, err := parser.ParseVerifyCommand(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	opts := verify.Options{AllowUnsigned: cmd.AllowUnsigned}
+	if cmd.KeyFile != "" {
+		if opts.Key, err = loadPublicKey(cmd.KeyFile); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: --key: %v\n", err)
+			return 1
+		}
+	}
+
+	total, failed := 0, 0
+	for _, name := range cmd.Files {
+		n, f, err := verifyFile(name, opts)
+		total += n
+		failed += f
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if err := cli.PrintVerifySummary(os.Stdout, total, failed); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if failed > 0 || total == 0 {
+		return 1
+	}
+	return 0
+}
+
+// verifyFile verifies the reports of the file name, - for standard input,
+// and returns how many there were and how many failed. The reports of a
+// file holding several are named after their position, e.g. "out.json#2".
+func verifyFile(name string, opts verify.Options) (total, failed int, err error) {
+	var r io.Reader = os.Stdin
+	if name == "-" {
+		name = "stdin"
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var report json.RawMessage
+		if err := dec.Decode(&report); err != nil {
+			return total, failed, fmt.Errorf("%s: %w", name, err)
+		}
+		total++
+
+		label := name
+		if total > 1 || dec.More() {
+			label = fmt.Sprintf("%s#%d", name, total)
+		}
+		res := verify.Check(report, opts)
+		if !res.OK() {
+			failed++
+		}
+		if err := cli.PrintVerifyResult(os.Stdout, label, res); err != nil {
+			return total, failed, err
+		}
+	}
+	return total, failed, nil
+}
+
+// loadPublicKey reads the public key reports must be signed with.
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return sign.ParsePublicKey(data)
+}
@@ -1,3 +1,5 @@
 module api-client
 
 go 1.22
+
+require golang.org/x/text v0.22.0
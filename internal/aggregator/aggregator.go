@@ -3,6 +3,7 @@ package aggregator
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -10,16 +11,153 @@ import (
 	"api-client/internal/provider"
 )
 
+// skippedQuorum is the reason recorded for providers that were not needed
+// because the quorum was reached first.
+const skippedQuorum = "quorum reached"
+
+// skippedLocal is the reason recorded for remote providers that were not
+// queried because an authoritative local provider answered, with
+// WithLocalFirst.
+const skippedLocal = "answered locally"
+
+// skippedSecondary is the reason recorded for secondary providers that were
+// not queried because the primary ones answered and agreed, with
+// WithSecondary.
+const skippedSecondary = "primaries agreed"
+
 // Aggregator coordinates concurrent lookups across multiple Providers.
 type Aggregator struct {
-	providers []provider.Provider
+	providers  []provider.Provider
+	quorum     int
+	hedgeDelay time.Duration
+	latency    *latencyTracker
+	consensus  model.ConsensusOptions
+	localFirst bool
+	recorder   LatencyRecorder
+
+	// secondary names the providers of the secondary tier, and shadow the
+	// providers queried for evaluation only
+	secondary, shadow map[string]bool
+
+	// local are the indexes of the providers queried first, with
+	// WithLocalFirst, rest those of the providers queried next, and
+	// backup those of the secondary providers, queried last if at all.
+	// shadows are the indexes of the shadow providers, queried alongside
+	// rest, and advisory those of the advisory providers, queried before
+	// all others.
+	local, rest, backup, shadows, advisory []int
+}
+
+// Option configures an Aggregator.
+type Option func(*Aggregator)
+
+// WithQuorum ends each lookup as soon as n providers have succeeded. Only n
+// providers are queried at first, the historically fastest ones; the others
+// are only queried to replace failures or, with WithHedgeDelay, when the
+// first ones are slow. Values outside 1 to the number of providers query
+// every provider at once.
+func WithQuorum(n int) Option {
+	return func(a *Aggregator) {
+		a.quorum = n
+	}
+}
+
+// WithHedgeDelay starts the next provider whenever d passes without the
+// quorum being reached. Zero disables hedging.
+func WithHedgeDelay(d time.Duration) Option {
+	return func(a *Aggregator) {
+		a.hedgeDelay = d
+	}
+}
+
+// WithConsensusOptions sets how the consensus of each report is computed.
+func WithConsensusOptions(opts model.ConsensusOptions) Option {
+	return func(a *Aggregator) {
+		a.consensus = opts
+	}
+}
+
+// LatencyRecorder receives the latency of every successful check, e.g. to
+// keep a history across runs.
+type LatencyRecorder interface {
+	Observe(provider string, d time.Duration)
+}
+
+// WithLatencyRecorder reports the latency of every successful check to r.
+func WithLatencyRecorder(r LatencyRecorder) Option {
+	return func(a *Aggregator) {
+		a.recorder = r
+	}
+}
+
+// WithLocalFirst queries the local providers, those answering without any
+// request, before the remote ones, and skips the remote ones altogether when
+// an authoritative local provider succeeds, so that addresses such as
+// private ones are never sent to third parties. Answers of other local
+// providers, which only hold data, do not spare the remote ones.
+func WithLocalFirst() Option {
+	return func(a *Aggregator) {
+		a.localFirst = true
+	}
+}
+
+// WithSecondary puts the named providers in a secondary tier, queried only
+// when the other providers all fail or disagree on the country, so as to
+// spare the quota of paid providers while keeping them as a backup, or the
+// other way round. Names of providers that are not configured are ignored.
+func WithSecondary(names ...string) Option {
+	return func(a *Aggregator) {
+		a.secondary = make(map[string]bool, len(names))
+		for _, name := range names {
+			a.secondary[name] = true
+		}
+	}
+}
+
+// WithShadow makes the named providers shadow providers, queried alongside
+// the others but left out of the consensus, so that a new provider can be
+// evaluated before being trusted: each of their results is compared with
+// the consensus of the others instead. Shadow providers are never secondary
+// ones. Names of providers that are not configured are ignored.
+func WithShadow(names ...string) Option {
+	return func(a *Aggregator) {
+		a.shadow = make(map[string]bool, len(names))
+		for _, name := range names {
+			a.shadow[name] = true
+		}
+	}
 }
 
 // New creates a new Aggregator with the given providers.
 func New(providers ...provider.Provider) *Aggregator {
-	return &Aggregator{
+	return NewWithOptions(providers)
+}
+
+// NewWithOptions creates a new Aggregator with the given providers and options.
+func NewWithOptions(providers []provider.Provider, opts ...Option) *Aggregator {
+	a := &Aggregator{
 		providers: providers,
+		latency:   newLatencyTracker(),
+	}
+	for _, opt := range opts {
+		opt(a)
 	}
+
+	a.rest = make([]int, len(providers))
+	for i := range a.rest {
+		a.rest[i] = i
+	}
+	// Advisory providers only advise remote ones; without any, as offline,
+	// they answer like the others
+	if _, remote := a.partition(a.rest, provider.IsLocal); len(remote) > 0 {
+		a.advisory, a.rest = a.partition(a.rest, provider.IsAdvisory)
+	}
+	if a.localFirst {
+		a.local, a.rest = a.partition(a.rest, provider.IsLocal)
+	}
+	a.rest, a.shadows = a.split(a.rest, a.shadow)
+	a.rest, a.backup = a.split(a.rest, a.secondary)
+	return a
 }
 
 // Lookup queries all providers concurrently and returns an aggregated report.
@@ -32,36 +170,259 @@ func (a *Aggregator) Lookup(ctx context.Context, ip model.IPAddress) model.Repor
 		Results:   make([]model.ProviderResult, len(a.providers)),
 	}
 
+	a.lookupAll(ctx, ip, a.advisory, report.Results)
+	for _, idx := range a.advisory {
+		report.Results[idx].Advisory = true
+	}
+
+	remaining, backup, shadows := a.rest, a.backup, a.shadows
+	if len(a.local) > 0 {
+		a.lookupAll(ctx, ip, a.local, report.Results)
+		if a.answeredLocally(report.Results) {
+			a.skip(remaining, skippedLocal, report.Results)
+			a.skip(backup, skippedLocal, report.Results)
+			a.skip(shadows, skippedLocal, report.Results)
+			remaining, backup, shadows = nil, nil, nil
+		}
+	}
+
 	var wg sync.WaitGroup
-	wg.Add(len(a.providers))
+	if len(shadows) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.lookupAll(ctx, ip, shadows, report.Results)
+		}()
+	}
+
+	a.lookupTier(ctx, ip, remaining, report.Results)
+	if len(backup) > 0 {
+		if agreed(report.Results, remaining) {
+			a.skip(backup, skippedSecondary, report.Results)
+		} else {
+			a.lookupTier(ctx, ip, backup, report.Results)
+		}
+	}
+	wg.Wait()
+
+	report.TotalDuration = time.Since(start)
+	for _, idx := range a.shadows {
+		report.Results[idx].Shadow = true
+	}
+	report.SetConsensusOptions(a.consensus)
+	report.CompareShadows()
 
-	for i, checker := range a.providers {
-		go func(idx int, p provider.Provider) {
+	return report
+}
+
+// answeredLocally reports whether an authoritative local provider succeeded
+// in results.
+func (a *Aggregator) answeredLocally(results []model.ProviderResult) bool {
+	for _, idx := range a.local {
+		if results[idx].Success() && provider.IsAuthoritative(a.providers[idx]) {
+			return true
+		}
+	}
+	return false
+}
+
+// partition separates the indexes of the providers matching from the
+// others, keeping their order.
+func (a *Aggregator) partition(idxs []int, matches func(provider.Provider) bool) (matching, others []int) {
+	for _, i := range idxs {
+		if matches(a.providers[i]) {
+			matching = append(matching, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+	return matching, others
+}
+
+// split separates the indexes of the providers named in names from idxs,
+// keeping their order.
+func (a *Aggregator) split(idxs []int, names map[string]bool) (others, named []int) {
+	others = make([]int, 0, len(idxs))
+	for _, i := range idxs {
+		if names[a.providers[i].Name()] {
+			named = append(named, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+	return others, named
+}
+
+// agreed reports whether any of the providers at idxs succeeded and all
+// those that did, and named a country, named the same one.
+func agreed(results []model.ProviderResult, idxs []int) bool {
+	succeeded := false
+	country := ""
+	for _, idx := range idxs {
+		pr := results[idx]
+		if !pr.Success() {
+			continue
+		}
+		succeeded = true
+		if code := pr.Result.CountryCode; code != "" {
+			if country != "" && code != country {
+				return false
+			}
+			country = code
+		}
+	}
+	return succeeded
+}
+
+// skip records the providers at idxs as skipped for reason.
+func (a *Aggregator) skip(idxs []int, reason string, results []model.ProviderResult) {
+	for _, idx := range idxs {
+		results[idx] = model.ProviderResult{
+			Provider: a.providers[idx].Name(),
+			Error:    reason,
+			Skipped:  true,
+		}
+	}
+}
+
+// lookupTier queries the providers at idxs, until the quorum is reached
+// when one is set.
+func (a *Aggregator) lookupTier(ctx context.Context, ip model.IPAddress, idxs []int, results []model.ProviderResult) {
+	if a.quorum > 0 && a.quorum < len(idxs) {
+		a.lookupQuorum(ctx, ip, idxs, results)
+	} else {
+		a.lookupAll(ctx, ip, idxs, results)
+	}
+}
+
+// lookupAll queries the providers at idxs at once.
+func (a *Aggregator) lookupAll(ctx context.Context, ip model.IPAddress, idxs []int, results []model.ProviderResult) {
+	var wg sync.WaitGroup
+	wg.Add(len(idxs))
+
+	for _, i := range idxs {
+		go func(idx int) {
 			defer wg.Done()
+			results[idx] = a.check(ctx, idx, ip)
+		}(i)
+	}
 
-			providerStart := time.Now()
-			result, err := p.Check(ctx, ip)
-			duration := time.Since(providerStart)
+	wg.Wait()
+}
 
-			pr := model.ProviderResult{
-				Provider: p.Name(),
-				Duration: duration,
+// lookupQuorum queries the providers at idxs from fastest to slowest until
+// the quorum is reached, starting a further provider for every failure and,
+// when hedging, every time the hedge delay passes.
+func (a *Aggregator) lookupQuorum(ctx context.Context, ip model.IPAddress, idxs []int, results []model.ProviderResult) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	names := make([]string, len(idxs))
+	for i, idx := range idxs {
+		names[i] = a.providers[idx].Name()
+	}
+	order := make([]int, len(idxs))
+	for i, j := range a.latency.order(names) {
+		order[i] = idxs[j]
+	}
+
+	type outcome struct {
+		idx int
+		pr  model.ProviderResult
+	}
+	outcomes := make(chan outcome, len(order))
+
+	next, inflight, succeeded := 0, 0, 0
+	launch := func() {
+		idx := order[next]
+		next++
+		inflight++
+		go func() {
+			outcomes <- outcome{idx, a.check(ctx, idx, ip)}
+		}()
+	}
+
+	for next < a.quorum {
+		launch()
+	}
+
+	var hedge <-chan time.Time
+	if a.hedgeDelay > 0 {
+		ticker := time.NewTicker(a.hedgeDelay)
+		defer ticker.Stop()
+		hedge = ticker.C
+	}
+
+	for inflight > 0 {
+		select {
+		case o := <-outcomes:
+			inflight--
+			if succeeded >= a.quorum && !o.pr.Success() {
+				o.pr.Error = skippedQuorum
+				o.pr.Skipped = true
 			}
+			results[o.idx] = o.pr
 
-			if err != nil {
-				pr.Error = err.Error()
-			} else {
-				pr.Result = &result
+			if o.pr.Success() {
+				succeeded++
+				if succeeded == a.quorum {
+					cancel()
+				}
+			} else if succeeded < a.quorum && next < len(order) {
+				launch()
 			}
+		case <-hedge:
+			if succeeded < a.quorum && next < len(order) {
+				launch()
+			}
+		}
+	}
+
+	a.skip(order[next:], skippedQuorum, results)
+}
+
+// check queries a single provider and records its latency, leaving out
+// the time the check waited for its turn under a rate limit or quota.
+func (a *Aggregator) check(ctx context.Context, idx int, ip model.IPAddress) model.ProviderResult {
+	p := a.providers[idx]
+
+	var quota *model.Quota
+	var conns provider.ConnTracker
+	var wait time.Duration
+	ctx = provider.WithConnTracker(provider.WithQuotaRecorder(ctx, &quota), &conns)
+	ctx = provider.WithWaitRecorder(ctx, &wait)
+	providerStart := time.Now()
+	result, err := p.Check(ctx, ip)
+	duration := time.Since(providerStart) - wait
+	providerStart = providerStart.Add(wait)
+	err = conns.Classify(err)
 
-			report.Results[idx] = pr
-		}(i, checker)
+	pr := model.ProviderResult{
+		Provider: p.Name(),
+		Start:    providerStart,
+		Duration: duration,
+		Quota:    quota,
 	}
 
-	wg.Wait()
-	report.TotalDuration = time.Since(start)
+	var notApplicable provider.NotApplicableError
+	if errors.As(err, &notApplicable) {
+		pr.Error = err.Error()
+		pr.Skipped = true
+	} else if err != nil {
+		pr.Error = err.Error()
+		var timeout provider.TimeoutError
+		if errors.As(err, &timeout) {
+			pr.Timeout = timeout.Phase
+		}
+	} else {
+		pr.Result = &result
+		a.latency.observe(pr.Provider, duration)
+		if a.recorder != nil {
+			a.recorder.Observe(pr.Provider, duration)
+		}
+	}
 
-	return report
+	return pr
 }
 
 // ProviderCount returns the number of configured providers.
@@ -77,3 +438,9 @@ func (a *Aggregator) ProviderNames() []string {
 	}
 	return names
 }
+
+// Latencies returns the rolling average latency of each provider that has
+// succeeded at least once, by name.
+func (a *Aggregator) Latencies() map[string]time.Duration {
+	return a.latency.snapshot()
+}
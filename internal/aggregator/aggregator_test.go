@@ -3,6 +3,9 @@ package aggregator
 import (
 	"context"
 	"errors"
+	"net"
+	"net/http"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -278,4 +281,472 @@ func TestAggregator_Lookup_Duration(t *testing.T) {
 	if report.TotalDuration < 40*time.Millisecond || report.TotalDuration > 100*time.Millisecond {
 		t.Errorf("TotalDuration = %v, expected around 50ms", report.TotalDuration)
 	}
+
+	// The checker started with the lookup
+	if start := report.Results[0].Start; start.Before(report.Timestamp) || start.Sub(report.Timestamp) > 10*time.Millisecond {
+		t.Errorf("Checker Start = %v, expected right after %v", start, report.Timestamp)
+	}
+}
+
+func TestAggregator_Lookup_DurationExcludesQuotaWait(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	p := provider.NewTestProvider("throttled", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		time.Sleep(20 * time.Millisecond)
+		return model.Geolocation{IP: ip}, nil
+	}))
+
+	agg := New(provider.WithQuota(p, 1, 150*time.Millisecond))
+	agg.Lookup(context.Background(), ip)
+	// The second check waits for the quota before it starts
+	report := agg.Lookup(context.Background(), ip)
+
+	if d := report.Results[0].Duration; d < 15*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("Checker Duration = %v, want around 20ms without the wait", d)
+	}
+	if wait := report.Results[0].Start.Sub(report.Timestamp); wait < 100*time.Millisecond {
+		t.Errorf("Checker Start = %v after the lookup, want after the wait", wait)
+	}
+	if avg := agg.Latencies()["throttled"]; avg > 100*time.Millisecond {
+		t.Errorf("Latencies() = %v, want around 20ms without the wait", avg)
+	}
+}
+
+func TestAggregator_Lookup_Quota(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	limited := provider.NewTestProvider("limited", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		h := http.Header{}
+		h.Set("X-Rl", "7")
+		provider.RecordQuota(ctx, h)
+		return model.Geolocation{IP: ip}, nil
+	}))
+	unlimited := provider.NewTestProvider("unlimited", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{IP: ip}, nil
+	}))
+
+	agg := New(provider.WithTimeout(limited, time.Second), unlimited)
+	report := agg.Lookup(context.Background(), ip)
+
+	if q := report.Results[0].Quota; q == nil || q.Remaining != 7 {
+		t.Errorf("Results[0].Quota = %v, want 7 remaining", q)
+	}
+
+	if report.Results[1].Quota != nil {
+		t.Errorf("Results[1].Quota = %v, want nil", report.Results[1].Quota)
+	}
+}
+
+func TestAggregator_Lookup_Timeout(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	filtered := provider.NewTestProvider("filtered", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}
+	}))
+	failing := provider.NewTestProvider("failing", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, errors.New("boom")
+	}))
+
+	report := New(filtered, failing).Lookup(context.Background(), ip)
+
+	if pr := report.Results[0]; pr.Timeout != provider.TimeoutConnect || !strings.HasPrefix(pr.Error, "connect timeout: ") {
+		t.Errorf("Results[0] = %q (%s), want a connect timeout", pr.Timeout, pr.Error)
+	}
+	if pr := report.Results[1]; pr.Timeout != "" {
+		t.Errorf("Results[1].Timeout = %q, want none", pr.Timeout)
+	}
+}
+
+// delayedProvider returns a provider answering after delay, or failing
+// when fail is set, and counts its calls.
+func delayedProvider(name string, delay time.Duration, fail bool, calls *int32) provider.Provider {
+	return provider.NewTestProvider(name, provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		atomic.AddInt32(calls, 1)
+		select {
+		case <-ctx.Done():
+			return model.Geolocation{}, ctx.Err()
+		case <-time.After(delay):
+		}
+		if fail {
+			return model.Geolocation{}, errors.New("unavailable")
+		}
+		return model.Geolocation{IP: ip, Country: "United States"}, nil
+	}))
+}
+
+func TestAggregator_Lookup_QuorumPrefersFastest(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	var slowCalls, fastCalls int32
+	agg := NewWithOptions([]provider.Provider{
+		delayedProvider("slow", 40*time.Millisecond, false, &slowCalls),
+		delayedProvider("fast", time.Millisecond, false, &fastCalls),
+	}, WithQuorum(1))
+
+	// Without samples providers are tried in configured order
+	report := agg.Lookup(context.Background(), ip)
+	if !report.Results[0].Success() || !report.Results[1].Skipped {
+		t.Fatalf("first lookup results = %+v, want slow answered and fast skipped", report.Results)
+	}
+
+	// Once measured, the fast provider is tried first
+	agg.latency.observe("fast", time.Millisecond)
+
+	report = agg.Lookup(context.Background(), ip)
+	if !report.Results[1].Success() {
+		t.Errorf("fast result = %+v, want success", report.Results[1])
+	}
+	if !report.Results[0].Skipped || report.Results[0].Error != skippedQuorum {
+		t.Errorf("slow result = %+v, want skipped", report.Results[0])
+	}
+	if got := atomic.LoadInt32(&slowCalls); got != 1 {
+		t.Errorf("slow provider calls = %d, want 1", got)
+	}
+	if report.ErrorCount() != 0 {
+		t.Errorf("ErrorCount() = %d, want 0 (skipped providers are not errors)", report.ErrorCount())
+	}
+}
+
+func TestAggregator_Lookup_QuorumReplacesFailures(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	var calls [3]int32
+	agg := NewWithOptions([]provider.Provider{
+		delayedProvider("broken", time.Millisecond, true, &calls[0]),
+		delayedProvider("ok", time.Millisecond, false, &calls[1]),
+		delayedProvider("spare", time.Millisecond, false, &calls[2]),
+	}, WithQuorum(1))
+
+	report := agg.Lookup(context.Background(), ip)
+
+	if report.Results[0].Success() || report.Results[0].Skipped {
+		t.Errorf("broken result = %+v, want failure", report.Results[0])
+	}
+	if !report.Results[1].Success() {
+		t.Errorf("ok result = %+v, want success", report.Results[1])
+	}
+	if !report.Results[2].Skipped {
+		t.Errorf("spare result = %+v, want skipped", report.Results[2])
+	}
+	if got := atomic.LoadInt32(&calls[2]); got != 0 {
+		t.Errorf("spare provider calls = %d, want 0", got)
+	}
+}
+
+func TestAggregator_Lookup_QuorumHedges(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	var slowCalls, backupCalls int32
+	agg := NewWithOptions([]provider.Provider{
+		delayedProvider("slow", time.Second, false, &slowCalls),
+		delayedProvider("backup", time.Millisecond, false, &backupCalls),
+	}, WithQuorum(1), WithHedgeDelay(20*time.Millisecond))
+
+	start := time.Now()
+	report := agg.Lookup(context.Background(), ip)
+	elapsed := time.Since(start)
+
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("elapsed = %v, want the hedge to answer well before the slow provider", elapsed)
+	}
+	if !report.Results[1].Success() {
+		t.Errorf("backup result = %+v, want success", report.Results[1])
+	}
+	if !report.Results[0].Skipped {
+		t.Errorf("slow result = %+v, want skipped after cancellation", report.Results[0])
+	}
+	if got := atomic.LoadInt32(&slowCalls); got != 1 {
+		t.Errorf("slow provider calls = %d, want 1", got)
+	}
+}
+
+func TestAggregator_Lookup_QuorumOfAll(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	var calls int32
+	agg := NewWithOptions([]provider.Provider{
+		delayedProvider("p1", time.Millisecond, false, &calls),
+		delayedProvider("p2", time.Millisecond, false, &calls),
+	}, WithQuorum(2))
+
+	report := agg.Lookup(context.Background(), ip)
+
+	if report.SuccessCount() != 2 || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("SuccessCount() = %d, calls = %d, want both providers queried", report.SuccessCount(), calls)
+	}
+}
+
+func TestAggregator_Latencies(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	var calls int32
+	agg := New(
+		delayedProvider("ok", 5*time.Millisecond, false, &calls),
+		delayedProvider("broken", time.Millisecond, true, &calls),
+	)
+	agg.Lookup(context.Background(), ip)
+
+	latencies := agg.Latencies()
+	if d, ok := latencies["ok"]; !ok || d < 5*time.Millisecond {
+		t.Errorf("Latencies()[ok] = %v, %v; want at least 5ms", d, ok)
+	}
+	if _, ok := latencies["broken"]; ok {
+		t.Error("failed checks should not be recorded as latencies")
+	}
+}
+
+func TestLatencyTracker_Order(t *testing.T) {
+	tracker := newLatencyTracker()
+	tracker.observe("a", 30*time.Millisecond)
+	tracker.observe("b", 10*time.Millisecond)
+	tracker.observe("b", 100*time.Millisecond)
+
+	// b: 0.3*100 + 0.7*10 = 37ms
+	if got := tracker.snapshot()["b"]; got != 37*time.Millisecond {
+		t.Errorf("average of b = %v, want 37ms", got)
+	}
+
+	order := tracker.order([]string{"a", "b", "c"})
+	want := []int{2, 0, 1}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestAggregator_Lookup_NotApplicable(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+
+	local := provider.NewTestProvider("local", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, provider.NotApplicableError{Reason: "not covered"}
+	}))
+	remote := provider.NewTestProvider("remote", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, errors.New("unavailable")
+	}))
+
+	report := New(local, remote).Lookup(context.Background(), ip)
+
+	if !report.Results[0].Skipped || report.Results[0].Error != "not covered" {
+		t.Errorf("local result = %+v, want skipped with its reason", report.Results[0])
+	}
+	if report.ErrorCount() != 1 || report.SkippedCount() != 1 {
+		t.Errorf("ErrorCount() = %d, SkippedCount() = %d; want 1 and 1", report.ErrorCount(), report.SkippedCount())
+	}
+	if !report.AllFailed() {
+		t.Error("a skipped provider should not keep the lookup from failing")
+	}
+}
+
+// localProvider describes itself as answering without any request, and
+// authoritatively if so set.
+type localProvider struct {
+	provider.Provider
+	authoritative bool
+}
+
+func (p localProvider) Describe(model.IPAddress) provider.Description {
+	return provider.Description{Name: p.Name(), Local: true, Authoritative: p.authoritative}
+}
+
+func TestAggregator_Lookup_LocalFirst(t *testing.T) {
+	bogon := localProvider{provider.NewTestProvider("bogon", provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		if ip.IsPrivate() {
+			return model.Geolocation{IP: ip}, nil
+		}
+		return model.Geolocation{}, provider.NotApplicableError{Reason: "not covered"}
+	})), true}
+
+	var calls int32
+	remote := delayedProvider("remote", 0, false, &calls)
+	agg := NewWithOptions([]provider.Provider{remote, bogon}, WithLocalFirst())
+
+	report := agg.Lookup(context.Background(), model.MustParseAddr("10.0.0.1"))
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatal("remote provider was queried for an address answered locally")
+	}
+	if !report.Results[0].Skipped || report.Results[0].Error != skippedLocal {
+		t.Errorf("remote result = %+v, want skipped as answered locally", report.Results[0])
+	}
+	if !report.Results[1].Success() {
+		t.Errorf("local result = %+v, want success", report.Results[1])
+	}
+
+	report = agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if atomic.LoadInt32(&calls) != 1 || !report.Results[0].Success() {
+		t.Errorf("remote result = %+v after %d calls, want queried once", report.Results[0], calls)
+	}
+}
+
+// advisoryProvider describes itself as a local provider that only advises.
+type advisoryProvider struct {
+	provider.Provider
+}
+
+func (p advisoryProvider) Describe(model.IPAddress) provider.Description {
+	return provider.Description{Name: p.Name(), Local: true, Advisory: true}
+}
+
+func TestAggregator_Lookup_Advisory(t *testing.T) {
+	var calls int32
+	table := advisoryProvider{countryProvider("table", "US", &calls)}
+
+	agg := NewWithOptions([]provider.Provider{countryProvider("remote", "", &calls), table}, WithLocalFirst())
+	report := agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if calls != 2 {
+		t.Fatalf("calls = %d, want the remote provider queried despite the table", calls)
+	}
+	if !report.Results[1].Advisory || !report.Results[1].Success() {
+		t.Errorf("table result = %+v, want an advisory success", report.Results[1])
+	}
+	if !report.AllFailed() {
+		t.Error("an advisory success should not keep the lookup from failing")
+	}
+
+	agg = NewWithOptions([]provider.Provider{table})
+	report = agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if report.Results[0].Advisory || report.SuccessCount() != 1 {
+		t.Errorf("table result = %+v, want it to answer as the only provider", report.Results[0])
+	}
+}
+
+func TestAggregator_Lookup_LocalFirst_DataOnly(t *testing.T) {
+	var calls, localCalls int32
+	table := localProvider{countryProvider("table", "US", &localCalls), false}
+	agg := NewWithOptions([]provider.Provider{countryProvider("remote", "US", &calls), table}, WithLocalFirst())
+
+	report := agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if calls != 1 || localCalls != 1 {
+		t.Fatalf("calls = %d remote, %d local, want both queried", calls, localCalls)
+	}
+	if !report.Results[0].Success() {
+		t.Errorf("remote result = %+v, want success despite the local answer", report.Results[0])
+	}
+}
+
+type recorderFunc func(provider string, d time.Duration)
+
+func (f recorderFunc) Observe(provider string, d time.Duration) {
+	f(provider, d)
+}
+
+func TestAggregator_Lookup_LatencyRecorder(t *testing.T) {
+	var observed []string
+	recorder := recorderFunc(func(provider string, d time.Duration) {
+		observed = append(observed, provider)
+	})
+
+	var calls int32
+	agg := NewWithOptions([]provider.Provider{
+		delayedProvider("ok", 0, false, &calls),
+		delayedProvider("failing", 0, true, &calls),
+	}, WithLatencyRecorder(recorder))
+
+	agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+
+	if len(observed) != 1 || observed[0] != "ok" {
+		t.Errorf("observed = %v, want only the successful provider", observed)
+	}
+}
+
+// countryProvider answers with the given country code, or fails when it is
+// empty, counting its calls.
+func countryProvider(name, code string, calls *int32) provider.Provider {
+	return provider.NewTestProvider(name, provider.CheckerFunc(func(ctx context.Context,
+		ip model.IPAddress) (model.Geolocation, error) {
+		atomic.AddInt32(calls, 1)
+		if code == "" {
+			return model.Geolocation{}, errors.New("unavailable")
+		}
+		return model.Geolocation{IP: ip, CountryCode: code}, nil
+	}))
+}
+
+func TestAggregator_Lookup_Secondary(t *testing.T) {
+	tests := []struct {
+		name            string
+		first, second   string
+		wantBackupCalls int32
+	}{
+		{"primaries agree", "US", "US", 0},
+		{"primaries disagree", "US", "CA", 1},
+		{"one primary fails", "US", "", 0},
+		{"all primaries fail", "", "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls, backupCalls int32
+			agg := NewWithOptions([]provider.Provider{
+				countryProvider("paid", "US", &backupCalls),
+				countryProvider("free1", tt.first, &calls),
+				countryProvider("free2", tt.second, &calls),
+			}, WithSecondary("paid", "unknown"))
+
+			report := agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+			if calls != 2 || backupCalls != tt.wantBackupCalls {
+				t.Fatalf("calls = %d primary, %d secondary, want 2, %d", calls, backupCalls, tt.wantBackupCalls)
+			}
+
+			backup := report.Results[0]
+			if tt.wantBackupCalls == 0 && (!backup.Skipped || backup.Error != skippedSecondary) {
+				t.Errorf("secondary result = %+v, want skipped as the primaries agreed", backup)
+			}
+			if tt.wantBackupCalls == 1 && !backup.Success() {
+				t.Errorf("secondary result = %+v, want success", backup)
+			}
+		})
+	}
+}
+
+func TestAggregator_Lookup_Shadow(t *testing.T) {
+	var calls int32
+	agg := NewWithOptions([]provider.Provider{
+		countryProvider("trusted", "US", &calls),
+		countryProvider("candidate", "CA", &calls),
+	}, WithShadow("candidate"))
+
+	report := agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if calls != 2 {
+		t.Fatalf("calls = %d, want both providers queried", calls)
+	}
+	if got := report.Consensus().CountryCode; got != "US" {
+		t.Errorf("consensus country = %q, want US, ignoring the shadow provider", got)
+	}
+	if report.SuccessCount() != 1 {
+		t.Errorf("SuccessCount() = %d, want 1", report.SuccessCount())
+	}
+
+	shadow := report.Results[1]
+	if !shadow.Shadow || shadow.Comparison == nil || shadow.Comparison.CountryMatch == nil || *shadow.Comparison.CountryMatch {
+		t.Errorf("shadow result = %+v, want a shadow result compared as not matching", shadow)
+	}
+	if report.Results[0].Shadow || report.Results[0].Comparison != nil {
+		t.Errorf("trusted result = %+v, want no comparison", report.Results[0])
+	}
+}
+
+func TestAggregator_Lookup_ShadowDoesNotCount(t *testing.T) {
+	var calls int32
+	agg := NewWithOptions([]provider.Provider{
+		countryProvider("trusted", "", &calls),
+		countryProvider("candidate", "US", &calls),
+	}, WithShadow("candidate"))
+
+	report := agg.Lookup(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if !report.AllFailed() {
+		t.Error("a successful shadow provider should not keep the lookup from failing")
+	}
+	if report.Results[1].Comparison != nil {
+		t.Error("there is no consensus to compare the shadow result with")
+	}
 }
@@ -0,0 +1,64 @@
+package aggregator
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWeight is the weight of the newest sample in the rolling average.
+const latencyWeight = 0.3
+
+// latencyTracker keeps an exponentially weighted moving average of the
+// latency of each provider across lookups.
+type latencyTracker struct {
+	mu      sync.Mutex
+	average map[string]time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{average: make(map[string]time.Duration)}
+}
+
+// observe records the latency of a successful check.
+func (t *latencyTracker) observe(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	avg, ok := t.average[name]
+	if !ok {
+		t.average[name] = d
+		return
+	}
+	t.average[name] = time.Duration(latencyWeight*float64(d) + (1-latencyWeight)*float64(avg))
+}
+
+// order returns the indexes of names sorted from fastest to slowest.
+// Providers without samples come first, so that each gets measured, and
+// ties keep their configured order.
+func (t *latencyTracker) order(names []string) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return t.average[names[order[i]]] < t.average[names[order[j]]]
+	})
+	return order
+}
+
+// snapshot returns a copy of the rolling averages.
+func (t *latencyTracker) snapshot() map[string]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	averages := make(map[string]time.Duration, len(t.average))
+	for name, d := range t.average {
+		averages[name] = d
+	}
+	return averages
+}
@@ -0,0 +1,237 @@
+// Package alert compares each report of an address with the previous one,
+// kept across runs, and notifies the changes that rules such as "the ASN
+// changed" or "the country left [DE, FR, ...]" look for, by webhook,
+// command or syslog, so that scheduled runs can watch a list of addresses.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/policy"
+)
+
+// Rule alerts on one change between two reports of an address: of a field
+// of Changed, into the condition Enters or out of the condition Leaves.
+// Conditions and fields are those of policy rules. The alerts are sent to
+// each of Webhook, an http(s) URL they are POSTed to, Exec, a command
+// reading them on its standard input, and Syslog, a syslog server URL.
+type Rule struct {
+	Name    string
+	Changed []string
+	Enters  string
+	Leaves  string
+	Webhook string
+	Exec    []string
+	Syslog  string
+}
+
+type compiledRule struct {
+	Rule
+	cond      *policy.Condition
+	notifiers []notifier
+}
+
+// Alert is the payload of a notification: the change of the address, from
+// its previous report to the current one.
+type Alert struct {
+	Rule    string    `json:"rule"`
+	IP      string    `json:"ip"`
+	Time    time.Time `json:"time"`
+	Since   time.Time `json:"since"`
+	Changes []Change  `json:"changes"`
+
+	// Previous and Current are the values of the two reports
+	Previous map[string]any `json:"previous"`
+	Current  map[string]any `json:"current"`
+}
+
+// Change is a field whose value differs between two reports; a value
+// missing from either is null.
+type Change struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// runFields are the values of a lookup rather than of its address, left
+// out of comparisons.
+var runFields = []string{"providers_succeeded", "providers_failed"}
+
+// Engine checks reports against rules, and notifies their alerts.
+type Engine struct {
+	rules []compiledRule
+	state *State
+
+	// mu serializes the writes to stderr of notifications sent
+	// concurrently
+	mu     sync.Mutex
+	stderr io.Writer
+}
+
+// New compiles rules, failing on the first invalid one, and connects to
+// their syslog servers. Reports are compared with those of state, and the
+// warnings about notifications failing are written to stderr.
+func New(rules []Rule, state *State, stderr io.Writer) (*Engine, error) {
+	e := &Engine{state: state, stderr: stderr}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("#%d", i+1)
+		}
+		cr, err := e.compile(r)
+		if err != nil {
+			_ = e.Close()
+			return nil, fmt.Errorf("alert rule %s: %w", r.Name, err)
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+func (e *Engine) compile(r Rule) (compiledRule, error) {
+	cr := compiledRule{Rule: r}
+
+	triggers := 0
+	for _, set := range []bool{len(r.Changed) > 0, r.Enters != "", r.Leaves != ""} {
+		if set {
+			triggers++
+		}
+	}
+	if triggers != 1 {
+		return cr, fmt.Errorf("must have one of changed, enters or leaves")
+	}
+
+	fields := slices.DeleteFunc(policy.Fields(), func(name string) bool { return slices.Contains(runFields, name) })
+	for _, name := range r.Changed {
+		if !slices.Contains(fields, name) {
+			return cr, fmt.Errorf("unknown field %q; valid fields: %s", name, strings.Join(fields, ", "))
+		}
+	}
+	if cond := r.Enters + r.Leaves; cond != "" {
+		var err error
+		if cr.cond, err = policy.Compile(cond); err != nil {
+			return cr, err
+		}
+	}
+
+	var err error
+	if cr.notifiers, err = newNotifiers(r, e.write); err != nil {
+		return cr, err
+	}
+	if len(cr.notifiers) == 0 {
+		return cr, fmt.Errorf("no webhook, exec or syslog to notify")
+	}
+	return cr, nil
+}
+
+// Observe compares report with the previous one of its address, records
+// it in place of the previous one, and notifies the alerts of the rules
+// the change matches, which it returns. The first report of an address is
+// only recorded, and a report failing on every provider is ignored, so
+// that an outage does not read as a change.
+func (e *Engine) Observe(ctx context.Context, report model.Report) []Alert {
+	if report.AllFailed() {
+		return nil
+	}
+
+	current := policy.Values(report)
+	for _, name := range runFields {
+		delete(current, name)
+	}
+	prev, ok := e.state.swap(report.IP.String(), report.Timestamp, current)
+	if !ok {
+		return nil
+	}
+	changes := diff(prev.Values, current)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var alerts []Alert
+	for _, r := range e.rules {
+		if !r.matches(prev.Values, current, changes) {
+			continue
+		}
+		a := Alert{
+			Rule:     r.Name,
+			IP:       report.IP.String(),
+			Time:     report.Timestamp,
+			Since:    prev.Time,
+			Changes:  changes,
+			Previous: prev.Values,
+			Current:  current,
+		}
+		for _, n := range r.notifiers {
+			if err := n.notify(ctx, a); err != nil {
+				e.write(fmt.Appendf(nil, "Warning: alert %s: %s: %v\n", r.Name, n.name(), err))
+			}
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts
+}
+
+// matches reports whether the change from previous to current, with the
+// fields changes, is the one r looks for.
+func (r compiledRule) matches(previous, current map[string]any, changes []Change) bool {
+	switch {
+	case r.Enters != "":
+		return !r.cond.MatchValues(previous) && r.cond.MatchValues(current)
+	case r.Leaves != "":
+		return r.cond.MatchValues(previous) && !r.cond.MatchValues(current)
+	}
+	for _, c := range changes {
+		if slices.Contains(r.Changed, c.Field) {
+			return true
+		}
+	}
+	return false
+}
+
+// diff returns the fields whose values differ, sorted by name.
+func diff(previous, current map[string]any) []Change {
+	var changes []Change
+	for name, from := range previous {
+		if to, ok := current[name]; !ok || to != from {
+			changes = append(changes, Change{Field: name, From: from, To: current[name]})
+		}
+	}
+	for name, to := range current {
+		if _, ok := previous[name]; !ok {
+			changes = append(changes, Change{Field: name, To: to})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// write copies p to stderr at once, so that the warnings and command
+// output of notifications sent concurrently do not interleave.
+func (e *Engine) write(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.stderr.Write(p)
+}
+
+// Close closes the connections to the syslog servers.
+func (e *Engine) Close() error {
+	var err error
+	for _, r := range e.rules {
+		for _, n := range r.notifiers {
+			if cerr := n.close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
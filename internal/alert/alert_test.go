@@ -0,0 +1,303 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func makeReport(country, asn string, at time.Time) model.Report {
+	ip := model.MustParseAddr("192.0.2.1")
+	return model.Report{
+		IP:        ip,
+		Timestamp: at,
+		Results: []model.ProviderResult{
+			{Provider: "ipinfo", Result: &model.Geolocation{IP: ip, CountryCode: country, ASN: asn}},
+		},
+	}
+}
+
+func newEngine(t *testing.T, rules ...Rule) (*Engine, *State) {
+	t.Helper()
+	state, err := LoadState(filepath.Join(t.TempDir(), "alerts.json"))
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	e, err := New(rules, state, io.Discard)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	return e, state
+}
+
+// succeed is a notifier command that succeeds without output.
+var succeed = []string{"true"}
+
+func TestEngine_Observe(t *testing.T) {
+	eu := "country in [AT, BE, DE, FR]"
+	tests := []struct {
+		name  string
+		rule  Rule
+		from  model.Report
+		to    model.Report
+		alert bool
+	}{
+		{"changed", Rule{Changed: []string{"asn"}, Exec: succeed}, makeReport("DE", "AS1", time.Time{}), makeReport("DE", "AS2", time.Time{}), true},
+		{"changed other field", Rule{Changed: []string{"asn"}, Exec: succeed}, makeReport("DE", "AS1", time.Time{}), makeReport("FR", "AS1", time.Time{}), false},
+		{"unchanged", Rule{Changed: []string{"asn"}, Exec: succeed}, makeReport("DE", "AS1", time.Time{}), makeReport("DE", "AS1", time.Time{}), false},
+		{"leaves", Rule{Leaves: eu, Exec: succeed}, makeReport("DE", "AS1", time.Time{}), makeReport("US", "AS1", time.Time{}), true},
+		{"moves within", Rule{Leaves: eu, Exec: succeed}, makeReport("DE", "AS1", time.Time{}), makeReport("FR", "AS1", time.Time{}), false},
+		{"enters", Rule{Enters: eu, Exec: succeed}, makeReport("US", "AS1", time.Time{}), makeReport("DE", "AS1", time.Time{}), true},
+		{"enters from within", Rule{Enters: eu, Exec: succeed}, makeReport("BE", "AS1", time.Time{}), makeReport("DE", "AS1", time.Time{}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newEngine(t, tt.rule)
+			if alerts := e.Observe(context.Background(), tt.from); alerts != nil {
+				t.Fatalf("first Observe() = %v, want none", alerts)
+			}
+			alerts := e.Observe(context.Background(), tt.to)
+			if got := len(alerts) > 0; got != tt.alert {
+				t.Errorf("Observe() = %v, want alert %v", alerts, tt.alert)
+			}
+		})
+	}
+}
+
+func TestEngine_Observe_IgnoresFailures(t *testing.T) {
+	e, _ := newEngine(t, Rule{Changed: []string{"country"}, Exec: succeed})
+	e.Observe(context.Background(), makeReport("DE", "AS1", time.Time{}))
+
+	failed := model.Report{IP: model.MustParseAddr("192.0.2.1"), Results: []model.ProviderResult{{Provider: "ipinfo", Error: "timeout"}}}
+	if alerts := e.Observe(context.Background(), failed); alerts != nil {
+		t.Errorf("Observe(failed) = %v, want none", alerts)
+	}
+	// The failure did not replace the last report
+	if alerts := e.Observe(context.Background(), makeReport("DE", "AS1", time.Time{})); alerts != nil {
+		t.Errorf("Observe() after failure = %v, want none", alerts)
+	}
+}
+
+func TestEngine_Webhook(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	e, _ := newEngine(t, Rule{Name: "asn", Changed: []string{"asn"}, Webhook: srv.URL})
+	since := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	e.Observe(context.Background(), makeReport("DE", "AS1", since))
+	e.Observe(context.Background(), makeReport("DE", "AS2", since.Add(time.Hour)))
+
+	if got.Rule != "asn" || got.IP != "192.0.2.1" || !got.Since.Equal(since) {
+		t.Errorf("alert = %+v", got)
+	}
+	want := []Change{{Field: "asn", From: "AS1", To: "AS2"}}
+	if len(got.Changes) != 1 || got.Changes[0] != want[0] {
+		t.Errorf("changes = %v, want %v", got.Changes, want)
+	}
+}
+
+func TestEngine_Exec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert.json")
+	var stderr bytes.Buffer
+	state, _ := LoadState(filepath.Join(t.TempDir(), "alerts.json"))
+	e, err := New([]Rule{
+		{Name: "moved", Changed: []string{"country"}, Exec: []string{"sh", "-c", `echo "$IPINTEL_ALERT $IPINTEL_IP"; cat > "$0"`, out}},
+		{Name: "failing", Changed: []string{"country"}, Exec: []string{"sh", "-c", "exit 3"}},
+	}, state, &stderr)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	e.Observe(context.Background(), makeReport("DE", "AS1", time.Time{}))
+	if alerts := e.Observe(context.Background(), makeReport("US", "AS1", time.Time{})); len(alerts) != 2 {
+		t.Fatalf("Observe() = %d alerts, want 2", len(alerts))
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Alert
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("command input %q: %v", data, err)
+	}
+	if got.Previous["country"] != "DE" || got.Current["country"] != "US" {
+		t.Errorf("alert = %+v", got)
+	}
+	for _, want := range []string{"moved 192.0.2.1\n", "Warning: alert failing: exec: exit status 3"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, want %q", stderr.String(), want)
+		}
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		want string
+	}{
+		{"no trigger", Rule{Exec: succeed}, "one of changed, enters or leaves"},
+		{"two triggers", Rule{Changed: []string{"asn"}, Leaves: "is_vpn", Exec: succeed}, "one of changed, enters or leaves"},
+		{"unknown field", Rule{Changed: []string{"colour"}, Exec: succeed}, `unknown field "colour"`},
+		{"run field", Rule{Changed: []string{"providers_failed"}, Exec: succeed}, "unknown field"},
+		{"invalid condition", Rule{Enters: "country ==", Exec: succeed}, "alert rule #1"},
+		{"no notifier", Rule{Changed: []string{"asn"}}, "no webhook, exec or syslog"},
+		{"invalid webhook", Rule{Changed: []string{"asn"}, Webhook: "ftp://example.com"}, "not an http(s) URL"},
+		{"invalid syslog", Rule{Changed: []string{"asn"}, Syslog: "smtp://example.com"}, "unsupported scheme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]Rule{tt.rule}, nil, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New() error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestState_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipintel", "alerts.json")
+	at := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	e, state := newEngine(t, Rule{Changed: []string{"asn"}, Exec: succeed})
+	state.path = path
+	state.now = func() time.Time { return at }
+	e.Observe(context.Background(), makeReport("DE", "AS1", at))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	e, err = New([]Rule{{Changed: []string{"asn"}, Exec: succeed}}, loaded, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Values read back compare equal to those of a new report
+	if alerts := e.Observe(context.Background(), makeReport("DE", "AS1", at.Add(time.Hour))); alerts != nil {
+		t.Errorf("Observe() unchanged after reload = %v, want none", alerts)
+	}
+	alerts := e.Observe(context.Background(), makeReport("DE", "AS2", at.Add(2*time.Hour)))
+	if len(alerts) != 1 || !alerts[0].Since.Equal(at.Add(time.Hour)) {
+		t.Errorf("Observe() after reload = %v, want one alert since the last report", alerts)
+	}
+}
+
+func TestState_Save_Private(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipintel", "alerts.json")
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.swap("192.0.2.1", time.Now(), map[string]any{"asn": "AS1"})
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	for p, want := range map[string]os.FileMode{path: 0o600, filepath.Dir(path): 0o700} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("mode of %s = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestState_Save_Prune(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	state, err := LoadState(filepath.Join(t.TempDir(), "alerts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.now = func() time.Time { return now }
+
+	state.swap("192.0.2.1", now.Add(-maxAge-time.Hour), nil)
+	for i := range maxEntries + 1 {
+		state.swap(fmt.Sprintf("10.%d.%d.%d", i>>16, i>>8&0xff, i&0xff), now.Add(time.Duration(i)*time.Second), nil)
+	}
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if len(state.entries) != maxEntries {
+		t.Errorf("%d entries left, want %d", len(state.entries), maxEntries)
+	}
+	for _, ip := range []string{"192.0.2.1", "10.0.0.0"} {
+		if _, ok := state.entries[ip]; ok {
+			t.Errorf("%s is still kept, want it forgotten", ip)
+		}
+	}
+}
+
+func TestState_Encrypted(t *testing.T) {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	state, err := LoadState(path, WithAEAD(aead))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.swap("192.0.2.1", time.Now(), map[string]any{"asn": "AS1"})
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("192.0.2.1")) {
+		t.Error("the saved state reveals the address looked up")
+	}
+
+	loaded, err := LoadState(path, WithAEAD(aead))
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if _, ok := loaded.entries["192.0.2.1"]; !ok {
+		t.Error("the address is missing once loaded back")
+	}
+	if _, err := LoadState(path); err == nil {
+		t.Error("LoadState() without the key succeeded, want an error")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"addresses": {}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(path, WithAEAD(aead)); err == nil || !strings.Contains(err.Error(), "not encrypted") {
+		t.Errorf("LoadState() of a plain state error = %v, want not encrypted", err)
+	}
+}
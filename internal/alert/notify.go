@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+
+	"api-client/internal/syslog"
+)
+
+// timeout bounds each notification.
+const timeout = 10 * time.Second
+
+// notifier sends the alerts of a rule to one destination.
+type notifier interface {
+	name() string
+	notify(ctx context.Context, a Alert) error
+	close() error
+}
+
+// newNotifiers returns the notifiers of r; the output of its command is
+// passed to write.
+func newNotifiers(r Rule, write func([]byte)) ([]notifier, error) {
+	var notifiers []notifier
+	if r.Webhook != "" {
+		u, err := url.Parse(r.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %q: not an http(s) URL", r.Webhook)
+		}
+		notifiers = append(notifiers, &webhook{url: r.Webhook, client: &http.Client{Timeout: timeout}})
+	}
+	if len(r.Exec) > 0 {
+		if r.Exec[0] == "" {
+			return nil, fmt.Errorf("exec: no command")
+		}
+		notifiers = append(notifiers, &command{args: r.Exec, write: write})
+	}
+	if r.Syslog != "" {
+		w, err := syslog.Dial(r.Syslog)
+		if err != nil {
+			for _, n := range notifiers {
+				_ = n.close()
+			}
+			return nil, fmt.Errorf("syslog: %w", err)
+		}
+		notifiers = append(notifiers, &syslogger{w: w})
+	}
+	return notifiers, nil
+}
+
+// webhook POSTs alerts as JSON to a URL.
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhook) name() string { return "webhook" }
+
+func (n *webhook) notify(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (n *webhook) close() error { return nil }
+
+// command runs a command with each alert as JSON on its standard input,
+// and IPINTEL_ALERT and IPINTEL_IP set in its environment.
+type command struct {
+	args  []string
+	write func([]byte)
+}
+
+func (n *command) name() string { return "exec" }
+
+func (n *command) notify(ctx context.Context, a Alert) error {
+	input, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, n.args[0], n.args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env = append(os.Environ(), "IPINTEL_ALERT="+a.Rule, "IPINTEL_IP="+a.IP)
+	// Do not wait for the children of a killed command to close its output
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	n.write(out.Bytes())
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+func (n *command) close() error { return nil }
+
+// syslogger sends alerts as JSON in warning messages of ID "alert".
+type syslogger struct {
+	w *syslog.Writer
+}
+
+func (n *syslogger) name() string { return "syslog" }
+
+func (n *syslogger) notify(_ context.Context, a Alert) error {
+	msg, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return n.w.Send(syslog.Warning, "alert", string(msg))
+}
+
+func (n *syslogger) close() error { return n.w.Close() }
@@ -0,0 +1,184 @@
+package alert
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The state forgets addresses not looked up for maxAge, and the least
+// recently looked up ones beyond maxEntries, so that it does not keep a
+// record of every address ever looked up.
+const (
+	maxAge     = 90 * 24 * time.Hour
+	maxEntries = 100_000
+)
+
+// State is the last report of each address, by address, that the next
+// ones are compared with. It is safe for concurrent use.
+type State struct {
+	path string
+	aead cipher.AEAD
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+	dirty   bool
+}
+
+// StateOption configures a State.
+type StateOption func(*State)
+
+// WithAEAD encrypts the state with aead, so that it does not reveal which
+// addresses were looked up. A state written without it, or with another
+// key, cannot be loaded.
+func WithAEAD(aead cipher.AEAD) StateOption {
+	return func(s *State) {
+		s.aead = aead
+	}
+}
+
+// entry is the last report of an address: when it was looked up and the
+// values of policy.Values.
+type entry struct {
+	Time   time.Time      `json:"time"`
+	Values map[string]any `json:"values"`
+}
+
+// file is the on-disk format of a State.
+type file struct {
+	Addresses map[string]entry `json:"addresses"`
+}
+
+// DefaultStatePath returns the default location of the state inside the
+// user's cache directory.
+func DefaultStatePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ipintel", "alerts.json"), nil
+}
+
+// LoadState reads the state at path. A missing file yields an empty State,
+// created by the first Save.
+func LoadState(path string, opts ...StateOption) (*State, error) {
+	s := &State{path: path, now: time.Now, entries: make(map[string]entry)}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.aead != nil {
+		if data, err = s.open(data); err != nil {
+			return nil, fmt.Errorf("alert state %s is not encrypted with the cache key; remove it to start over", path)
+		}
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing alert state %s: %w", path, err)
+	}
+	if f.Addresses != nil {
+		s.entries = f.Addresses
+	}
+	return s, nil
+}
+
+// open decrypts data as written by Save.
+func (s *State) open(data []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("truncated")
+	}
+	return s.aead.Open(nil, data[:n], data[n:], nil)
+}
+
+// swap records the values of the report of ip at t, returning those of the
+// previous one, if any.
+func (s *State) swap(ip string, t time.Time, values map[string]any) (entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.entries[ip]
+	s.entries[ip] = entry{Time: t, Values: values}
+	s.dirty = true
+	return prev, ok
+}
+
+// prune forgets the addresses past maxAge, then the least recently looked
+// up ones beyond maxEntries.
+func (s *State) prune() {
+	cutoff := s.now().Add(-maxAge)
+	for ip, e := range s.entries {
+		if e.Time.Before(cutoff) {
+			delete(s.entries, ip)
+		}
+	}
+	if len(s.entries) <= maxEntries {
+		return
+	}
+
+	ips := make([]string, 0, len(s.entries))
+	for ip := range s.entries {
+		ips = append(ips, ip)
+	}
+	slices.SortFunc(ips, func(a, b string) int {
+		return s.entries[a].Time.Compare(s.entries[b].Time)
+	})
+	for _, ip := range ips[:len(ips)-maxEntries] {
+		delete(s.entries, ip)
+	}
+}
+
+// Save writes the state back if any report was recorded since it was
+// loaded, readable by the user alone.
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	s.prune()
+	data, err := json.Marshal(file{Addresses: s.entries})
+	if err != nil {
+		return err
+	}
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(data)+s.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		data = s.aead.Seal(nonce, nonce, data, nil)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package anycast detects addresses in well-known anycast prefixes, whose
+// geolocation describes only one of the many sites announcing them.
+package anycast
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"api-client/internal/model"
+)
+
+//go:embed prefixes.txt
+var bundled string
+
+// List is a set of anycast prefixes.
+type List struct {
+	prefixes []netip.Prefix
+}
+
+// Default returns the list bundled with the binary.
+func Default() *List {
+	l, err := Parse(strings.NewReader(bundled))
+	if err != nil {
+		panic(fmt.Sprintf("anycast: invalid bundled list: %v", err))
+	}
+	return l
+}
+
+// Parse reads one CIDR prefix per line from r. Blank lines and lines
+// starting with '#' are ignored.
+func Parse(r io.Reader) (*List, error) {
+	l := &List{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		l.prefixes = append(l.prefixes, prefix.Masked())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Merge adds the prefixes of other to the list.
+func (l *List) Merge(other *List) {
+	l.prefixes = append(l.prefixes, other.prefixes...)
+}
+
+// Len returns the number of prefixes in the list.
+func (l *List) Len() int {
+	return len(l.prefixes)
+}
+
+// Contains reports whether ip falls within any prefix of the list.
+// IPv4-mapped IPv6 addresses are matched as IPv4.
+func (l *List) Contains(ip model.IPAddress) bool {
+	ip = ip.Unmap()
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
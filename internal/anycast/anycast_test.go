@@ -0,0 +1,81 @@
+package anycast
+
+import (
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestDefault_Contains(t *testing.T) {
+	list := Default()
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+		{"198.41.0.4", true},
+		{"2001:500:2f::f", true},
+		{"::ffff:9.9.9.9", true},
+		{"151.101.1.69", true},
+		{"93.184.215.14", false},
+		{"192.168.1.1", false},
+		{"2a00:1450:4001::1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := list.Contains(model.MustParseAddr(tt.ip)); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	list, err := Parse(strings.NewReader("# internal anycast\n\n10.53.0.0/16\n  fd00:53::/32  \n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if list.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", list.Len())
+	}
+
+	if !list.Contains(model.MustParseAddr("10.53.1.1")) {
+		t.Error("Contains(10.53.1.1) = false, want true")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse(strings.NewReader("10.53.0.0/16\n10.53.0.0\n"))
+	if err == nil {
+		t.Fatal("Parse() expected error for an address without a prefix length")
+	}
+
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error = %v, should mention the line number", err)
+	}
+}
+
+func TestList_Merge(t *testing.T) {
+	list := Default()
+	n := list.Len()
+
+	extra, err := Parse(strings.NewReader("10.53.0.0/16\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	list.Merge(extra)
+
+	if list.Len() != n+1 {
+		t.Errorf("Len() = %d, want %d", list.Len(), n+1)
+	}
+
+	if !list.Contains(model.MustParseAddr("10.53.0.1")) {
+		t.Error("merged prefix should be matched")
+	}
+}
@@ -0,0 +1,216 @@
+// Package batch runs lookups for many IP addresses using a pool of workers.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"api-client/internal/model"
+)
+
+// DefaultWorkers is the number of concurrent lookups used when none is configured.
+const DefaultWorkers = 4
+
+// ErrAborted is returned by a fail-fast run that stopped after a lookup
+// failed on every provider.
+var ErrAborted = errors.New("batch aborted")
+
+// Looker performs a single aggregated lookup. *aggregator.Aggregator implements it.
+type Looker interface {
+	Lookup(ctx context.Context, ip model.IPAddress) model.Report
+}
+
+// Runner looks up a list of IP addresses concurrently.
+type Runner struct {
+	looker   Looker
+	workers  int
+	failFast bool
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithWorkers sets the number of lookups performed concurrently.
+func WithWorkers(n int) Option {
+	return func(r *Runner) {
+		if n > 0 {
+			r.workers = n
+		}
+	}
+}
+
+// WithFailFast makes the run abort as soon as any lookup fails on every
+// provider, instead of continuing with the remaining addresses.
+func WithFailFast(failFast bool) Option {
+	return func(r *Runner) {
+		r.failFast = failFast
+	}
+}
+
+// New creates a Runner that performs lookups with looker.
+func New(looker Looker, opts ...Option) *Runner {
+	r := &Runner{
+		looker:  looker,
+		workers: DefaultWorkers,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Stream looks up the address of every record read from src and passes the
+// reports to emit in input order, as soon as all earlier ones have been
+// emitted. Only a small window of records, proportional to the number of
+// workers, is held in memory at any time, so inputs of any size can be
+// processed.
+//
+// Reading stops at the first error from src other than io.EOF, which is
+// returned. An error from emit cancels the run and is returned as is.
+// When fail-fast is enabled and a lookup fails on every provider, the
+// remaining lookups are cancelled: the reports completed before the
+// failure, including the failed one, are emitted in input order, followed
+// by an error wrapping ErrAborted.
+//
+// When ctx is cancelled, as on an interrupt, the lookups in flight are
+// abandoned and their incomplete reports dropped. The reports completed
+// before the first abandoned lookup are still emitted, so that the number
+// of reports emitted tells where to resume, and ctx.Err() is returned.
+func (r *Runner) Stream(ctx context.Context, src Source, emit func(model.Report) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		idx int
+		rec Record
+	}
+	type result struct {
+		idx    int
+		report model.Report
+	}
+
+	jobs := make(chan job)
+	results := make(chan result)
+
+	// window bounds the records read but not yet emitted, so that a slow
+	// lookup cannot make reports pile up behind it
+	window := make(chan struct{}, 2*r.workers)
+
+	var readErr error
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		defer close(jobs)
+
+		for idx := 0; ; idx++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			rec, err := src.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				// Lookups already started still complete and are emitted
+				readErr = err
+				return
+			}
+
+			select {
+			case jobs <- job{idx, rec}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < r.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				report := r.looker.Lookup(ctx, j.rec.IP)
+				if ctx.Err() != nil {
+					// Cut short: the report is incomplete
+					continue
+				}
+				report.Input = j.rec.Fields
+				results <- result{j.idx, report}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]model.Report)
+	next := 0
+	var runErr error
+
+	for res := range results {
+		if runErr != nil {
+			// Drain the lookups still in flight; their reports are dropped
+			continue
+		}
+
+		pending[res.idx] = res.report
+
+		if r.failFast && res.report.AllFailed() {
+			runErr = fmt.Errorf("%w: all providers failed for %s", ErrAborted, res.report.IP)
+			cancel()
+
+			// Emit everything completed so far, skipping gaps left by the
+			// lookups that were still running
+			indexes := make([]int, 0, len(pending))
+			for idx := range pending {
+				indexes = append(indexes, idx)
+			}
+			sort.Ints(indexes)
+			for _, idx := range indexes {
+				if err := emit(pending[idx]); err != nil {
+					runErr = err
+					break
+				}
+			}
+			continue
+		}
+
+		for {
+			report, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if err := emit(report); err != nil {
+				runErr = err
+				cancel()
+				break
+			}
+			<-window
+		}
+	}
+
+	<-dispatched
+
+	if runErr != nil {
+		return runErr
+	}
+	if readErr != nil {
+		return readErr
+	}
+	return ctx.Err()
+}
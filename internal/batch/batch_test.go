@@ -0,0 +1,772 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// lookerFunc adapts a function to the Looker interface.
+type lookerFunc func(ctx context.Context, ip model.IPAddress) model.Report
+
+func (f lookerFunc) Lookup(ctx context.Context, ip model.IPAddress) model.Report {
+	return f(ctx, ip)
+}
+
+func successReport(ip model.IPAddress) model.Report {
+	return model.Report{
+		IP:      ip,
+		Results: []model.ProviderResult{{Provider: "p", Result: &model.Geolocation{IP: ip}}},
+	}
+}
+
+func failedReport(ip model.IPAddress) model.Report {
+	return model.Report{
+		IP:      ip,
+		Results: []model.ProviderResult{{Provider: "p", Error: "boom"}},
+	}
+}
+
+func parseRecords(addrs ...string) []Record {
+	records := make([]Record, len(addrs))
+	for i, addr := range addrs {
+		records[i] = Record{IP: model.MustParseAddr(addr)}
+	}
+	return records
+}
+
+// stream runs r over records, collecting the reports emitted.
+func stream(r *Runner, records []Record) ([]model.Report, error) {
+	src := SliceSource(records)
+	var reports []model.Report
+	err := r.Stream(context.Background(), &src, func(report model.Report) error {
+		reports = append(reports, report)
+		return nil
+	})
+	return reports, err
+}
+
+// readAll reads every record of src, collecting the malformed entries in a
+// *ValidationError returned along with the valid records.
+func readAll(src Source) ([]Record, error) {
+	var records []Record
+	var invalid []InvalidLine
+	for {
+		rec, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		var line InvalidLine
+		if errors.As(err, &line) {
+			invalid = append(invalid, line)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if len(invalid) > 0 {
+		return records, &ValidationError{Lines: invalid}
+	}
+	return records, nil
+}
+
+func TestRunner_Stream_PreservesOrder(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9", "1.0.0.1")
+
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		// Later addresses finish first
+		if ip == records[0].IP {
+			time.Sleep(30 * time.Millisecond)
+		}
+		return successReport(ip)
+	})
+
+	reports, err := stream(New(looker, WithWorkers(4)), records)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if len(reports) != len(records) {
+		t.Fatalf("Stream() returned %d reports, want %d", len(reports), len(records))
+	}
+
+	for i, report := range reports {
+		if report.IP != records[i].IP {
+			t.Errorf("reports[%d].IP = %v, want %v", i, report.IP, records[i].IP)
+		}
+	}
+}
+
+func TestRunner_Stream_ContinuesOnError(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9")
+
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		if ip == records[1].IP {
+			return failedReport(ip)
+		}
+		return successReport(ip)
+	})
+
+	reports, err := stream(New(looker), records)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("Stream() returned %d reports, want 3", len(reports))
+	}
+
+	if !reports[1].AllFailed() {
+		t.Error("reports[1] should be a failed lookup")
+	}
+}
+
+func TestRunner_Stream_FailFast(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9", "1.0.0.1", "8.8.4.4")
+
+	var calls int32
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		atomic.AddInt32(&calls, 1)
+		if ip == records[1].IP {
+			return failedReport(ip)
+		}
+		return successReport(ip)
+	})
+
+	reports, err := stream(New(looker, WithWorkers(1), WithFailFast(true)), records)
+	if !errors.Is(err, ErrAborted) {
+		t.Fatalf("Stream() error = %v, want ErrAborted", err)
+	}
+
+	if !strings.Contains(err.Error(), "8.8.8.8") {
+		t.Errorf("error should name the failed address, got %v", err)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("Stream() returned %d reports, want the 2 completed before the abort", len(reports))
+	}
+
+	if !reports[1].AllFailed() {
+		t.Error("the failed report should be included")
+	}
+
+	if n := atomic.LoadInt32(&calls); n > 3 {
+		t.Errorf("looker called %d times, expected the run to stop after the failure", n)
+	}
+}
+
+func TestRunner_Stream_Concurrency(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9", "1.0.0.1")
+
+	var current, maxConcurrent int32
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		c := atomic.AddInt32(&current, 1)
+		for {
+			m := atomic.LoadInt32(&maxConcurrent)
+			if c <= m || atomic.CompareAndSwapInt32(&maxConcurrent, m, c) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		return successReport(ip)
+	})
+
+	if _, err := stream(New(looker, WithWorkers(2)), records); err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if m := atomic.LoadInt32(&maxConcurrent); m != 2 {
+		t.Errorf("max concurrent lookups = %d, want 2", m)
+	}
+}
+
+func TestTextSource(t *testing.T) {
+	ips, err := readAll(NewTextSource(strings.NewReader("8.8.8.8\n  1.1.1.1  \n2001:4860:4860::8888\n")))
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+
+	if len(ips) != 3 {
+		t.Fatalf("readAll() returned %d addresses, want 3", len(ips))
+	}
+
+	if ips[1].IP != model.MustParseAddr("1.1.1.1") {
+		t.Errorf("ips[1] = %v, want 1.1.1.1", ips[1].IP)
+	}
+}
+
+func TestTextSource_CommentsAndBlankLines(t *testing.T) {
+	input := "# office egress addresses\n8.8.8.8   \n\n   \n  # indented comment\n1.1.1.1\r\n"
+
+	ips, err := readAll(NewTextSource(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+
+	if len(ips) != 2 {
+		t.Fatalf("readAll() returned %d addresses, want 2", len(ips))
+	}
+}
+
+func TestNewSource_WindowsFiles(t *testing.T) {
+	const bom = "\xef\xbb\xbf"
+	inputs := map[InputFormat]string{
+		InputText: bom + "# notepad\r\n8.8.8.8\r\n\r\n1.1.1.1\r\n",
+		InputCSV:  bom + "ip,owner\r\n8.8.8.8,alice\r\n1.1.1.1,bob\r\n",
+		InputJSON: bom + "{\"ip\": \"8.8.8.8\"}\r\n{\"ip\": \"1.1.1.1\"}\r\n",
+	}
+
+	for format, input := range inputs {
+		src, err := NewSource(strings.NewReader(input), format, "ip")
+		if err != nil {
+			t.Fatalf("NewSource(%s) error = %v", format, err)
+		}
+		records, err := readAll(src)
+		if err != nil {
+			t.Fatalf("readAll(%s) error = %v", format, err)
+		}
+		if len(records) != 2 || records[1].IP != model.MustParseAddr("1.1.1.1") {
+			t.Errorf("readAll(%s) = %v, want 2 records", format, records)
+		}
+		if format == InputCSV && (len(records[0].Fields) != 1 || string(records[0].Fields[0].Value) != `"alice"`) {
+			t.Errorf("CSV fields = %v, want owner alice", records[0].Fields)
+		}
+	}
+}
+
+func TestTextSource_Invalid(t *testing.T) {
+	ips, err := readAll(NewTextSource(strings.NewReader("8.8.8.8\nnot-an-ip\n1.1.1.1\n300.1.1.1\n")))
+	if err == nil {
+		t.Fatal("readAll() expected error")
+	}
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %T, want *ValidationError", err)
+	}
+
+	if len(verr.Lines) != 2 {
+		t.Fatalf("ValidationError has %d lines, want every invalid line reported", len(verr.Lines))
+	}
+
+	if verr.Lines[0].Line != 2 || verr.Lines[0].Text != "not-an-ip" {
+		t.Errorf("Lines[0] = %+v, want line 2 'not-an-ip'", verr.Lines[0])
+	}
+
+	if verr.Lines[1].Line != 4 {
+		t.Errorf("Lines[1].Line = %d, want 4", verr.Lines[1].Line)
+	}
+
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error should mention the line number, got %v", err)
+	}
+
+	if len(ips) != 2 {
+		t.Errorf("readAll() returned %d valid addresses, want 2", len(ips))
+	}
+}
+
+func TestCSVSource(t *testing.T) {
+	input := "user,Src_IP,when\n# exported from the SSO log\nalice,8.8.8.8,2024-01-15\n\nbob, 1.1.1.1 ,2024-01-16\n"
+
+	src, err := NewCSVSource(strings.NewReader(input), "src_ip")
+	if err != nil {
+		t.Fatalf("NewCSVSource() error = %v", err)
+	}
+	ips, err := readAll(src)
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+
+	want := parseRecords("8.8.8.8", "1.1.1.1")
+	if len(ips) != len(want) {
+		t.Fatalf("readAll() returned %d addresses, want %d", len(ips), len(want))
+	}
+
+	for i := range want {
+		if ips[i].IP != want[i].IP {
+			t.Errorf("ips[%d] = %v, want %v", i, ips[i].IP, want[i].IP)
+		}
+	}
+
+	fields := ips[1].Fields
+	if len(fields) != 2 || fields[0].Name != "user" || fields[1].Name != "when" {
+		t.Fatalf("Fields = %+v, want the user and when columns", fields)
+	}
+
+	if fields[1].Text() != "2024-01-16" {
+		t.Errorf("when = %q, want 2024-01-16", fields[1].Text())
+	}
+}
+
+func TestCSVSource_Invalid(t *testing.T) {
+	input := "name,ip\nalice,8.8.8.8\nbob,not-an-ip\ncarol\n"
+
+	src, err := NewCSVSource(strings.NewReader(input), "ip")
+	if err != nil {
+		t.Fatalf("NewCSVSource() error = %v", err)
+	}
+	ips, err := readAll(src)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want *ValidationError", err)
+	}
+
+	if len(verr.Lines) != 2 {
+		t.Fatalf("ValidationError has %d lines, want 2", len(verr.Lines))
+	}
+
+	if verr.Lines[0].Line != 3 || verr.Lines[1].Line != 4 {
+		t.Errorf("invalid lines = %d, %d, want 3, 4", verr.Lines[0].Line, verr.Lines[1].Line)
+	}
+
+	if len(ips) != 1 {
+		t.Errorf("readAll() returned %d valid addresses, want 1", len(ips))
+	}
+}
+
+func TestCSVSource_MissingColumn(t *testing.T) {
+	_, err := NewCSVSource(strings.NewReader("name,addr\nalice,8.8.8.8\n"), "ip")
+	if err == nil {
+		t.Fatal("NewCSVSource() expected error for missing column")
+	}
+
+	if !strings.Contains(err.Error(), `column "ip" not found`) {
+		t.Errorf("error = %v, should mention the missing column", err)
+	}
+}
+
+func TestRunner_Stream_PassesThroughFields(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8")
+	records[1].Fields = model.Fields{{Name: "user_id", Value: json.RawMessage(`42`)}}
+
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		return successReport(ip)
+	})
+
+	reports, err := stream(New(looker), records)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if reports[0].Input != nil {
+		t.Errorf("reports[0].Input = %v, want nil", reports[0].Input)
+	}
+
+	if got, _ := reports[1].Input.Get("user_id"); string(got) != "42" {
+		t.Errorf("reports[1].Input[user_id] = %q, want 42", got)
+	}
+}
+
+func TestJSONSource_Array(t *testing.T) {
+	input := `[
+  {"user": "alice", "IP": "8.8.8.8", "tags": ["sso"]},
+  {"user": "bob", "ip": " 1.1.1.1 "}
+]`
+
+	records, err := readAll(NewJSONSource(strings.NewReader(input), "ip"))
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("readAll() returned %d records, want 2", len(records))
+	}
+
+	if records[0].IP != model.MustParseAddr("8.8.8.8") {
+		t.Errorf("records[0].IP = %v, want 8.8.8.8", records[0].IP)
+	}
+
+	if _, ok := records[0].Fields.Get("IP"); ok {
+		t.Error("the IP field should not be passed through")
+	}
+
+	if names := []string{records[0].Fields[0].Name, records[0].Fields[1].Name}; names[0] != "user" || names[1] != "tags" {
+		t.Errorf("field names = %v, want input order [user tags]", names)
+	}
+
+	if got, _ := records[0].Fields.Get("tags"); string(got) != `["sso"]` {
+		t.Errorf("records[0].Fields[tags] = %s, want [\"sso\"]", got)
+	}
+
+	if got, _ := records[1].Fields.Get("user"); string(got) != `"bob"` {
+		t.Errorf("records[1].Fields[user] = %s, want \"bob\"", got)
+	}
+}
+
+func TestJSONSource_NDJSON(t *testing.T) {
+	input := "{\"addr\": \"8.8.8.8\", \"n\": 1}\n\n{\"addr\": \"2001:4860:4860::8888\", \"n\": 2}\n"
+
+	records, err := readAll(NewJSONSource(strings.NewReader(input), "addr"))
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("readAll() returned %d records, want 2", len(records))
+	}
+
+	if got, _ := records[1].Fields.Get("n"); string(got) != "2" {
+		t.Errorf("records[1].Fields[n] = %s, want 2", got)
+	}
+}
+
+func TestJSONSource_Invalid(t *testing.T) {
+	input := "{\"ip\": \"8.8.8.8\"}\n{\"ip\": \"not-an-ip\"}\n{\"ip\": 42}\n{\"user\": \"carol\"}\n[1]\n"
+
+	records, err := readAll(NewJSONSource(strings.NewReader(input), "ip"))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want *ValidationError", err)
+	}
+
+	if len(verr.Lines) != 4 {
+		t.Fatalf("ValidationError has %d lines, want 4", len(verr.Lines))
+	}
+
+	for i, want := range []int{2, 3, 4, 5} {
+		if verr.Lines[i].Line != want {
+			t.Errorf("Lines[%d].Line = %d, want %d", i, verr.Lines[i].Line, want)
+		}
+	}
+
+	if verr.Lines[0].Text != "not-an-ip" {
+		t.Errorf("Lines[0].Text = %q, want not-an-ip", verr.Lines[0].Text)
+	}
+
+	if len(records) != 1 {
+		t.Errorf("readAll() returned %d valid records, want 1", len(records))
+	}
+}
+
+func TestJSONSource_SyntaxError(t *testing.T) {
+	_, err := readAll(NewJSONSource(strings.NewReader("{\"ip\": \"8.8.8.8\"}\n{\"ip\": \n"), "ip"))
+	if err == nil {
+		t.Fatal("readAll() expected error for truncated input")
+	}
+}
+
+// countingSource yields n records without holding them in memory, tracking
+// how many have been read.
+type countingSource struct {
+	n    int64
+	read atomic.Int64
+	err  error
+}
+
+func (s *countingSource) Next() (Record, error) {
+	read := s.read.Load()
+	if read == s.n {
+		if s.err != nil {
+			return Record{}, s.err
+		}
+		return Record{}, io.EOF
+	}
+	read = s.read.Add(1)
+	return Record{IP: model.MustParseAddr(fmt.Sprintf("10.0.%d.%d", read/256%256, read%256))}, nil
+}
+
+func TestRunner_Stream_BoundsWindow(t *testing.T) {
+	src := &countingSource{n: 1000}
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		return successReport(ip)
+	})
+
+	workers := 3
+	emitted := 0
+	err := New(looker, WithWorkers(workers)).Stream(context.Background(), src, func(report model.Report) error {
+		emitted++
+		// Records are read at most one window ahead of the emitted reports
+		if ahead := int(src.read.Load()) - emitted; ahead > 2*workers {
+			t.Fatalf("read %d records ahead of output, want at most %d", ahead, 2*workers)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	if emitted != 1000 {
+		t.Errorf("emitted %d reports, want 1000", emitted)
+	}
+}
+
+func TestRunner_Stream_PreservesOrderWithSlowLookups(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9", "1.0.0.1", "8.8.4.4")
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		if ip == records[0].IP {
+			time.Sleep(30 * time.Millisecond)
+		}
+		return successReport(ip)
+	})
+
+	src := SliceSource(records)
+	var got []model.IPAddress
+	err := New(looker, WithWorkers(3)).Stream(context.Background(), &src, func(report model.Report) error {
+		got = append(got, report.IP)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+	for i, ip := range got {
+		if ip != records[i].IP {
+			t.Fatalf("report %d is for %s, want %s", i, ip, records[i].IP)
+		}
+	}
+}
+
+func TestRunner_Stream_SourceError(t *testing.T) {
+	readErr := errors.New("disk on fire")
+	src := &countingSource{n: 3, err: readErr}
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		return successReport(ip)
+	})
+
+	emitted := 0
+	err := New(looker).Stream(context.Background(), src, func(model.Report) error {
+		emitted++
+		return nil
+	})
+	if !errors.Is(err, readErr) {
+		t.Fatalf("Stream() error = %v, want %v", err, readErr)
+	}
+	if emitted != 3 {
+		t.Errorf("emitted %d reports, want the 3 read before the error", emitted)
+	}
+}
+
+func TestRunner_Stream_EmitError(t *testing.T) {
+	writeErr := errors.New("broken pipe")
+	src := &countingSource{n: 100}
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		return successReport(ip)
+	})
+
+	err := New(looker).Stream(context.Background(), src, func(model.Report) error {
+		return writeErr
+	})
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("Stream() error = %v, want %v", err, writeErr)
+	}
+	if src.read.Load() == 100 {
+		t.Error("reading should stop once output fails")
+	}
+}
+
+func TestRunner_Stream_Cancelled(t *testing.T) {
+	records := parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9", "1.0.0.1", "8.8.4.4")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		switch ip {
+		case records[0].IP:
+		case records[1].IP:
+			// Interrupted while this lookup is in flight
+			cancel()
+			<-ctx.Done()
+		default:
+			<-ctx.Done()
+		}
+		return successReport(ip)
+	})
+
+	src := SliceSource(records)
+	var got []model.IPAddress
+	err := New(looker, WithWorkers(3)).Stream(ctx, &src, func(report model.Report) error {
+		got = append(got, report.IP)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Stream() error = %v, want %v", err, context.Canceled)
+	}
+	// Only the reports before the first abandoned lookup are emitted
+	if len(got) > 1 || len(got) == 1 && got[0] != records[0].IP {
+		t.Errorf("emitted %v, want at most %s", got, records[0].IP)
+	}
+}
+
+func TestCheckpoint_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+
+	if _, ok, err := LoadCheckpoint(path); err != nil || ok {
+		t.Fatalf("LoadCheckpoint() = %v, %v, want no checkpoint", ok, err)
+	}
+
+	want := Checkpoint{Input: "ips.txt", Records: 10, Completed: 4}
+	if err := want.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, ok, err := LoadCheckpoint(path)
+	if err != nil || !ok || got != want {
+		t.Fatalf("LoadCheckpoint() = %+v, %v, %v, want %+v", got, ok, err, want)
+	}
+
+	if err := got.Resumes("ips.txt", 10); err != nil {
+		t.Errorf("Resumes() error = %v", err)
+	}
+	if err := got.Resumes("other.txt", 10); err == nil {
+		t.Error("Resumes() should reject another input")
+	}
+	if err := got.Resumes("ips.txt", 11); err == nil {
+		t.Error("Resumes() should reject an input that changed")
+	}
+}
+
+func TestSkip(t *testing.T) {
+	src := SliceSource(parseRecords("1.1.1.1", "8.8.8.8", "9.9.9.9"))
+	if err := Skip(&src, 2); err != nil {
+		t.Fatalf("Skip() error = %v", err)
+	}
+	records, err := readAll(&src)
+	if err != nil || len(records) != 1 || records[0].IP.String() != "9.9.9.9" {
+		t.Fatalf("remaining records = %v, %v, want 9.9.9.9", records, err)
+	}
+
+	src = SliceSource(parseRecords("1.1.1.1"))
+	if err := Skip(&src, 2); !errors.Is(err, io.EOF) {
+		t.Errorf("Skip() past the end error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestScan(t *testing.T) {
+	input := `{"ip": "8.8.8.8", "user": "alice"}
+{"ip": "bogus"}
+{"ip": "1.1.1.1", "host": "one", "user": "bob"}
+`
+	stats, err := Scan(NewJSONSource(strings.NewReader(input), "ip"))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Lines) != 1 || verr.Lines[0].Line != 2 {
+		t.Fatalf("Scan() error = %v, want one invalid line at line 2", err)
+	}
+	if stats.Records != 2 {
+		t.Errorf("Records = %d, want 2", stats.Records)
+	}
+	if got := strings.Join(stats.Fields, ","); got != "user,host" {
+		t.Errorf("Fields = %q, want user,host", got)
+	}
+}
+
+func TestMultiSource(t *testing.T) {
+	first := SliceSource(parseRecords("1.1.1.1"))
+	records, err := readAll(MultiSource(&first, NewTextSource(strings.NewReader("8.8.8.8\n9.9.9.9\n"))))
+	if err != nil {
+		t.Fatalf("readAll() error = %v", err)
+	}
+	if len(records) != 3 || records[0].IP.String() != "1.1.1.1" || records[2].IP.String() != "9.9.9.9" {
+		t.Errorf("records = %v, want 1.1.1.1, 8.8.8.8, 9.9.9.9", records)
+	}
+}
+
+func benchmarkLooker() Looker {
+	return lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		return successReport(ip)
+	})
+}
+
+func BenchmarkRunner_Stream(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		err := New(benchmarkLooker()).Stream(context.Background(), &countingSource{n: 10000}, func(model.Report) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONSource(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&sb, "{\"ip\": \"10.0.%d.%d\", \"user\": \"u%d\"}\n", i/256%256, i%256, i)
+	}
+	input := sb.String()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Scan(NewJSONSource(strings.NewReader(input), "ip")); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// fuzzSource reads every record of an input in format, checking that
+// arbitrary input never makes a Source panic, loop or yield invalid records.
+func fuzzSource(f *testing.F, format InputFormat, seeds ...string) {
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		src, err := NewSource(strings.NewReader(string(data)), format, DefaultColumn)
+		if err != nil {
+			return
+		}
+
+		// Each call consumes input, so a Source cannot yield more entries
+		// than the input has bytes
+		for n := 0; n <= len(data)+1; n++ {
+			rec, err := src.Next()
+			if err == io.EOF {
+				return
+			}
+
+			var line InvalidLine
+			if errors.As(err, &line) {
+				if line.Line < 1 {
+					t.Fatalf("invalid entry at line %d", line.Line)
+				}
+				continue
+			}
+			if err != nil {
+				// Final
+				return
+			}
+			if !rec.IP.IsValid() {
+				t.Fatalf("record without a valid address: %+v", rec)
+			}
+		}
+		t.Fatalf("no end after %d records from %d bytes", len(data)+2, len(data))
+	})
+}
+
+func FuzzTextSource(f *testing.F) {
+	fuzzSource(f, InputText,
+		"8.8.8.8\n1.1.1.1\n",
+		"\xef\xbb\xbf# comment\r\n\r\n2001:db8::1\r\n",
+		"not an address\n",
+	)
+}
+
+func FuzzCSVSource(f *testing.F) {
+	fuzzSource(f, InputCSV,
+		"ip,user\n8.8.8.8,alice\n1.1.1.1,bob\n",
+		"user,IP\n\"quoted, name\",  8.8.8.8\n# comment\nshort\n",
+		"ip\n\"unterminated\n",
+	)
+}
+
+func FuzzJSONSource(f *testing.F) {
+	fuzzSource(f, InputJSON,
+		`[{"ip": "8.8.8.8", "user": "alice"}, {"IP": "1.1.1.1"}]`,
+		"{\"ip\": \"8.8.8.8\"}\n{\"ip\": 42}\n[1]\n{}\n",
+		`[{"ip": "8.8.8.8"},`,
+	)
+}
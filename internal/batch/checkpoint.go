@@ -0,0 +1,80 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Checkpoint records how far an interrupted run got, so that it can be
+// resumed without looking up the same addresses again.
+type Checkpoint struct {
+	// Input names the input of the run: the input file, "stdin", or ""
+	// when the addresses were given as arguments
+	Input string `json:"input"`
+
+	// Records is the number of valid records of the input
+	Records int `json:"records"`
+
+	// Completed is the number of records, from the start of the input,
+	// whose reports were written
+	Completed int `json:"completed"`
+}
+
+// LoadCheckpoint reads the checkpoint at path. It reports false when there
+// is none.
+func LoadCheckpoint(path string) (Checkpoint, bool, error) {
+	var c Checkpoint
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, false, nil
+	}
+	if err != nil {
+		return c, false, err
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, false, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, true, nil
+}
+
+// Resumes checks that the checkpoint was saved by a run over the same
+// input, of records valid records.
+func (c Checkpoint) Resumes(input string, records int) error {
+	if c.Input != input || c.Records != records {
+		return fmt.Errorf("checkpoint is for another input (%q, %d records); remove it to start over", c.Input, c.Records)
+	}
+	if c.Completed < 0 || c.Completed > c.Records {
+		return fmt.Errorf("checkpoint has %d of %d records completed", c.Completed, c.Records)
+	}
+	return nil
+}
+
+// Save writes the checkpoint to path, replacing any previous one.
+func (c Checkpoint) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Skip reads and discards the first n records of src, those of a run being
+// resumed.
+func Skip(src Source, n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := src.Next(); err != nil {
+			return fmt.Errorf("skipping completed records: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"api-client/internal/model"
+)
+
+// Record is a single entry of batch input: the address to look up and the
+// fields that accompanied it, which are passed through to its report.
+type Record struct {
+	IP     model.IPAddress
+	Fields model.Fields
+}
+
+// InvalidLine describes a line of input that is not a valid IP address.
+type InvalidLine struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (l InvalidLine) Error() string {
+	return fmt.Sprintf("line %d: %v", l.Line, l.Err)
+}
+
+// ValidationError lists every malformed line found in an input.
+type ValidationError struct {
+	Lines []InvalidLine
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Lines) == 1 {
+		return e.Lines[0].Error()
+	}
+	return fmt.Sprintf("%d invalid lines, first at %v", len(e.Lines), e.Lines[0])
+}
+
+// InputFormat identifies the format of a batch input file.
+type InputFormat string
+
+const (
+	// InputText is one IP address per line.
+	InputText InputFormat = "text"
+	// InputCSV is a CSV file with a header row naming its columns.
+	InputCSV InputFormat = "csv"
+	// InputJSON is a JSON array, or a stream of newline-delimited JSON
+	// values, of objects holding an IP address field.
+	InputJSON InputFormat = "json"
+)
+
+// DefaultColumn is the CSV column or JSON field holding IP addresses when none
+// is configured.
+const DefaultColumn = "ip"
+
+// ParseInputFormat converts an input format name into an InputFormat.
+func ParseInputFormat(format string) (InputFormat, error) {
+	switch format {
+	case "text", "":
+		return InputText, nil
+	case "csv":
+		return InputCSV, nil
+	case "json":
+		return InputJSON, nil
+	default:
+		return "", fmt.Errorf("invalid input format %q: must be 'text', 'csv' or 'json'", format)
+	}
+}
+
+// Source yields the records of a batch input one at a time, so that inputs
+// of any size can be processed without holding them in memory. Next returns
+// io.EOF after the last record. A malformed entry is reported as an
+// InvalidLine error, after which reading may continue; any other error is
+// final.
+type Source interface {
+	Next() (Record, error)
+}
+
+// SliceSource is a Source over records already in memory.
+type SliceSource []Record
+
+// Next implements Source.
+func (s *SliceSource) Next() (Record, error) {
+	if len(*s) == 0 {
+		return Record{}, io.EOF
+	}
+	rec := (*s)[0]
+	*s = (*s)[1:]
+	return rec, nil
+}
+
+// MultiSource returns a Source that reads each of sources in turn.
+func MultiSource(sources ...Source) Source {
+	return &multiSource{sources: sources}
+}
+
+type multiSource struct {
+	sources []Source
+}
+
+func (m *multiSource) Next() (Record, error) {
+	for len(m.sources) > 0 {
+		rec, err := m.sources[0].Next()
+		if err != io.EOF {
+			return rec, err
+		}
+		m.sources = m.sources[1:]
+	}
+	return Record{}, io.EOF
+}
+
+// SkipInvalid returns a Source that reads src, silently skipping malformed
+// entries. It suits a second pass over an input whose invalid lines were
+// already reported by Scan.
+func SkipInvalid(src Source) Source {
+	return skipInvalid{src}
+}
+
+type skipInvalid struct {
+	Source
+}
+
+func (s skipInvalid) Next() (Record, error) {
+	for {
+		rec, err := s.Source.Next()
+		var line InvalidLine
+		if !errors.As(err, &line) {
+			return rec, err
+		}
+	}
+}
+
+// NewSource returns a Source reading r in the given format. The column names
+// the CSV column or JSON field holding the IP address.
+func NewSource(r io.Reader, format InputFormat, column string) (Source, error) {
+	switch format {
+	case InputCSV:
+		return NewCSVSource(r, column)
+	case InputJSON:
+		return NewJSONSource(r, column), nil
+	default:
+		return NewTextSource(r), nil
+	}
+}
+
+// skipBOM returns a reader of r past the UTF-8 byte order mark that
+// Windows editors such as Notepad write at the start of text files.
+func skipBOM(r io.Reader) *bufio.Reader {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = br.Discard(3)
+	}
+	return br
+}
+
+// NewTextSource returns a Source of one IP address per line. Blank lines and
+// lines starting with '#' are ignored, as is surrounding whitespace, so
+// that Windows (CRLF) line endings are accepted.
+func NewTextSource(r io.Reader) Source {
+	return &textSource{scanner: bufio.NewScanner(skipBOM(r))}
+}
+
+type textSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *textSource) Next() (Record, error) {
+	for s.scanner.Scan() {
+		s.line++
+		text := strings.TrimSpace(s.scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		ip, err := model.ParseAddr(text)
+		if err != nil {
+			return Record{}, InvalidLine{Line: s.line, Text: text, Err: err}
+		}
+		return Record{IP: ip}, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// NewCSVSource returns a Source of IP addresses from the named column of CSV
+// data whose first record is a header row, which is read immediately. The
+// column name is matched case-insensitively and the remaining columns are
+// kept in each Record as string fields named by the header. Blank lines and
+// lines starting with '#' are ignored.
+func NewCSVSource(r io.Reader, column string) (Source, error) {
+	cr := csv.NewReader(skipBOM(r))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return &SliceSource{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	header = append([]string(nil), header...)
+
+	col := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("column %q not found in CSV header", column)
+	}
+
+	return &csvSource{reader: cr, header: header, column: column, col: col}, nil
+}
+
+type csvSource struct {
+	reader *csv.Reader
+	header []string
+	column string
+	col    int
+}
+
+func (s *csvSource) Next() (Record, error) {
+	row, err := s.reader.Read()
+	if err != nil {
+		return Record{}, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	if s.col >= len(row) {
+		return Record{}, InvalidLine{Line: line, Err: fmt.Errorf("missing column %q", s.column)}
+	}
+
+	text := strings.TrimSpace(row[s.col])
+	ip, err := model.ParseAddr(text)
+	if err != nil {
+		return Record{}, InvalidLine{Line: line, Text: text, Err: err}
+	}
+	return Record{IP: ip, Fields: csvFields(s.header, row, s.col)}, nil
+}
+
+// csvFields converts the cells of row, other than the address column, into
+// string fields named by the header. Cells beyond the header are dropped.
+func csvFields(header, row []string, col int) model.Fields {
+	var fields model.Fields
+	for i, cell := range row {
+		if i == col || i >= len(header) {
+			continue
+		}
+
+		value, err := json.Marshal(cell)
+		if err != nil {
+			continue
+		}
+		fields = append(fields, model.Field{Name: strings.TrimSpace(header[i]), Value: value})
+	}
+	return fields
+}
+
+// NewJSONSource returns a Source of IP addresses from the named field of JSON
+// objects, given either as a single array or as a stream of newline-delimited
+// values. The field name is matched case-insensitively and its value must be
+// a string. All other fields of an object are kept in its Record so they can
+// be passed through to the output. Syntax errors are final.
+func NewJSONSource(r io.Reader, field string) Source {
+	return &jsonSource{input: skipBOM(r), field: field}
+}
+
+type jsonSource struct {
+	input *bufio.Reader
+	field string
+
+	dec     *json.Decoder
+	lines   *lineCounter
+	array   bool
+	started bool
+	done    bool
+}
+
+// start skips leading whitespace to find out whether the input is an array.
+func (s *jsonSource) start() error {
+	s.started = true
+
+	newlines := 0
+	for {
+		b, err := s.input.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if b == '\n' {
+			newlines++
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			_ = s.input.UnreadByte()
+			s.array = b == '['
+			break
+		}
+	}
+
+	s.lines = &lineCounter{r: s.input, lines: newlines}
+	s.dec = json.NewDecoder(s.lines)
+	if s.array {
+		if _, err := s.dec.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonSource) Next() (Record, error) {
+	if !s.started {
+		if err := s.start(); err != nil {
+			s.done = true
+			return Record{}, err
+		}
+	}
+	if s.done {
+		return Record{}, io.EOF
+	}
+
+	if !s.dec.More() {
+		s.done = true
+		if s.array {
+			if _, err := s.dec.Token(); err != nil {
+				return Record{}, err
+			}
+		}
+		return Record{}, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := s.dec.Decode(&raw); err != nil {
+		s.done = true
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return Record{}, fmt.Errorf("line %d: %w", s.lines.at(syntaxErr.Offset), err)
+		}
+		return Record{}, err
+	}
+	line := s.lines.at(s.dec.InputOffset() - int64(len(raw)))
+
+	rec, text, err := parseJSONRecord(raw, s.field)
+	if err != nil {
+		return Record{}, InvalidLine{Line: line, Text: text, Err: err}
+	}
+	return rec, nil
+}
+
+// Stats summarizes a batch input without keeping its records.
+type Stats struct {
+	// Records is the number of valid records.
+	Records int
+	// Fields lists the names of the passthrough fields of all records, in
+	// the order they were first seen.
+	Fields []string
+}
+
+// Scan reads src to the end, counting its records and collecting their field
+// names, so that an input can be validated and described before a streaming
+// run reads it again. Every malformed entry is reported in a
+// *ValidationError returned along with the Stats of the valid records.
+func Scan(src Source) (Stats, error) {
+	var stats Stats
+	var invalid []InvalidLine
+	seen := make(map[string]bool)
+
+	for {
+		rec, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		var line InvalidLine
+		if errors.As(err, &line) {
+			invalid = append(invalid, line)
+			continue
+		}
+		if err != nil {
+			return Stats{}, err
+		}
+
+		stats.Records++
+		for _, field := range rec.Fields {
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				stats.Fields = append(stats.Fields, field.Name)
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return stats, &ValidationError{Lines: invalid}
+	}
+
+	return stats, nil
+}
+
+// parseJSONRecord extracts the address held in field from a JSON object,
+// keeping the other fields in order. It also returns the text of the
+// offending value when the object is invalid.
+func parseJSONRecord(raw json.RawMessage, field string) (Record, string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return Record{}, string(raw), errors.New("not a JSON object")
+	}
+
+	var rec Record
+	var text string
+	found := false
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return Record{}, string(raw), err
+		}
+		name := tok.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return Record{}, string(raw), err
+		}
+
+		if found || !strings.EqualFold(name, field) {
+			rec.Fields = append(rec.Fields, model.Field{Name: name, Value: value})
+			continue
+		}
+
+		if err := json.Unmarshal(value, &text); err != nil {
+			return Record{}, string(value), fmt.Errorf("field %q is not a string", name)
+		}
+		found = true
+	}
+
+	if !found {
+		return Record{}, "", fmt.Errorf("missing field %q", field)
+	}
+
+	text = strings.TrimSpace(text)
+	ip, err := model.ParseAddr(text)
+	if err != nil {
+		return Record{}, text, err
+	}
+	rec.IP = ip
+
+	return rec, "", nil
+}
+
+// lineCounter tracks line numbers in a stream read through it. It keeps
+// only the bytes past the last offset asked about, so its memory use is
+// bounded by how far the reader looks ahead.
+type lineCounter struct {
+	r     io.Reader
+	buf   []byte
+	base  int64
+	lines int
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.buf = append(c.buf, p[:n]...)
+	return n, err
+}
+
+// at returns the 1-based line number of the byte at offset. Offsets must
+// not decrease between calls.
+func (c *lineCounter) at(offset int64) int {
+	n := min(max(offset-c.base, 0), int64(len(c.buf)))
+	c.lines += bytes.Count(c.buf[:n], []byte("\n"))
+	c.buf = c.buf[n:]
+	c.base += n
+	return c.lines + 1
+}
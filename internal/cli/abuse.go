@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// AbuseContact is the abuse contact of the network holding an address, as
+// reported by one provider.
+type AbuseContact struct {
+	Provider     string
+	Registration model.Registration
+}
+
+// FindAbuseContact returns the first registration in report, in provider
+// order, that names an abuse email or phone number.
+func FindAbuseContact(report model.Report) (AbuseContact, bool) {
+	for _, pr := range report.Results {
+		if !pr.Success() || pr.Result.Registration == nil {
+			continue
+		}
+		reg := *pr.Result.Registration
+		if reg.AbuseEmail != "" || reg.AbusePhone != "" {
+			return AbuseContact{Provider: pr.Provider, Registration: reg}, true
+		}
+	}
+	return AbuseContact{}, false
+}
+
+// PrintAbuseContact writes the abuse contact of ip and the network it
+// was found for.
+func PrintAbuseContact(w io.Writer, ip model.IPAddress, contact AbuseContact) error {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("ABUSE CONTACT FOR %s:\n", ip))
+	sb.WriteString(strings.Repeat("-", 40) + "\n")
+
+	reg := contact.Registration
+	line := func(label, value string) {
+		if value != "" {
+			sb.WriteString(fmt.Sprintf("  %-10s%s\n", label+":", value))
+		}
+	}
+	line("Email", reg.AbuseEmail)
+	line("Phone", reg.AbusePhone)
+	line("Name", reg.AbuseName)
+	network := reg.Network
+	if network != "" && reg.NetName != "" {
+		network += " (" + reg.NetName + ")"
+	}
+	line("Network", network)
+	line("Holder", reg.Company)
+	line("Registry", joinNonEmpty(reg.Registry, reg.Country))
+	line("Source", contact.Provider)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// DefaultAbuseTemplate is the body of abuse reports, a text/template
+// executed with an AbuseReport.
+const DefaultAbuseTemplate = `Hello,
+
+We have observed abusive traffic from {{.IP}}, which your organisation is
+listed as the abuse contact for{{with .Contact.Registration.Network}} (network {{.}}){{end}}.
+
+Date and time (UTC): {{.Time.Format "2006-01-02 15:04:05"}}
+Source address:      {{.IP}}
+{{- with .Contact.Registration.Company}}
+Network holder:      {{.}}{{end}}
+Nature of the abuse: <describe the activity, e.g. SSH brute force>
+
+Log excerpts:
+<paste the relevant log lines, with timestamps and time zone>
+
+The attached ipintel report records the registration and geolocation data
+of the address at the time of the lookup.
+
+Please investigate and take appropriate action.
+
+Regards,
+{{with .From}}{{.}}{{else}}<your name>{{end}}
+`
+
+// AbuseReport is the data abuse report templates are executed with.
+type AbuseReport struct {
+	IP      model.IPAddress
+	Time    time.Time
+	From    string
+	Contact AbuseContact
+	Report  model.Report
+}
+
+// WriteAbuseEmail writes a prefilled abuse report to the contact as a
+// MIME message, ready to be edited and sent: a plain-text body rendered
+// from tmpl, and the lookup report attached as JSON evidence.
+func WriteAbuseEmail(w io.Writer, tmpl *template.Template, data AbuseReport) error {
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return fmt.Errorf("rendering the abuse template: %w", err)
+	}
+
+	evidence, err := json.MarshalIndent(data.Report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+
+	header := func(name, value string) {
+		if value != "" {
+			msg.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	header("From", data.From)
+	header("To", data.Contact.Registration.AbuseEmail)
+	header("Subject", mime.QEncoding.Encode("utf-8", fmt.Sprintf("Abuse report for %s", data.IP)))
+	header("Date", data.Time.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	msg.WriteString("\r\n")
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(crlf(body.Bytes())); err != nil {
+		return err
+	}
+
+	name := "ipintel-" + strings.ReplaceAll(data.IP.String(), ":", "_") + ".json"
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json; charset=utf-8"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(crlf(append(evidence, '\n'))); err != nil {
+		return err
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(msg.Bytes())
+	return err
+}
+
+// crlf converts line endings to CRLF, as mail requires.
+func crlf(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
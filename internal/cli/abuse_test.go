@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestParser_ParseAbuseCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseAbuseCommand([]string{"--email", "--from", "soc@example.com", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("ParseAbuseCommand() error = %v", err)
+	}
+	if cmd.IPAddress != "192.0.2.1" || !cmd.Email || cmd.From != "soc@example.com" || cmd.Timeout != DefaultTimeout {
+		t.Errorf("ParseAbuseCommand() = %+v", cmd)
+	}
+
+	for _, args := range [][]string{{}, {"192.0.2.1", "192.0.2.2"}, {"--from", "x@example.com", "192.0.2.1"}, {"--timeout", "0s", "192.0.2.1"}} {
+		if _, err := p.ParseAbuseCommand(args); err == nil {
+			t.Errorf("ParseAbuseCommand(%v) expected error", args)
+		}
+	}
+}
+
+func makeAbuseReport() model.Report {
+	ip := model.MustParseAddr("192.0.2.1")
+	return model.Report{
+		IP: ip,
+		Results: []model.ProviderResult{
+			{Provider: "ipinfo", Result: &model.Geolocation{IP: ip, Registration: &model.Registration{Company: "Example"}}},
+			{Provider: "ipwhois", Error: "timeout"},
+			{Provider: "whois", Result: &model.Geolocation{IP: ip, Registration: &model.Registration{
+				Registry:   "RIPE NCC",
+				Company:    "Example Networks",
+				Network:    "192.0.2.0 - 192.0.2.255",
+				NetName:    "EXAMPLE-NET",
+				Country:    "NL",
+				AbuseEmail: "abuse@example.net",
+			}}},
+		},
+	}
+}
+
+func TestFindAbuseContact(t *testing.T) {
+	contact, ok := FindAbuseContact(makeAbuseReport())
+	if !ok || contact.Provider != "whois" || contact.Registration.AbuseEmail != "abuse@example.net" {
+		t.Errorf("FindAbuseContact() = %+v, %v", contact, ok)
+	}
+
+	if _, ok := FindAbuseContact(model.Report{}); ok {
+		t.Error("FindAbuseContact() found a contact in an empty report")
+	}
+}
+
+func TestPrintAbuseContact(t *testing.T) {
+	report := makeAbuseReport()
+	contact, _ := FindAbuseContact(report)
+
+	var buf bytes.Buffer
+	if err := PrintAbuseContact(&buf, report.IP, contact); err != nil {
+		t.Fatalf("PrintAbuseContact() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"ABUSE CONTACT FOR 192.0.2.1:",
+		"  Email:    abuse@example.net\n",
+		"  Network:  192.0.2.0 - 192.0.2.255 (EXAMPLE-NET)\n",
+		"  Registry: RIPE NCC, NL\n",
+		"  Source:   whois\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Phone") {
+		t.Errorf("output shows an empty phone:\n%s", buf.String())
+	}
+}
+
+func TestWriteAbuseEmail(t *testing.T) {
+	report := makeAbuseReport()
+	contact, _ := FindAbuseContact(report)
+	tmpl := template.Must(template.New("abuse").Parse(DefaultAbuseTemplate))
+
+	var buf bytes.Buffer
+	err := WriteAbuseEmail(&buf, tmpl, AbuseReport{
+		IP:      report.IP,
+		Time:    time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		From:    "SOC <soc@example.com>",
+		Contact: contact,
+		Report:  report,
+	})
+	if err != nil {
+		t.Fatalf("WriteAbuseEmail() error = %v", err)
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if got := msg.Header.Get("To"); got != "abuse@example.net" {
+		t.Errorf("To = %q", got)
+	}
+	if got := msg.Header.Get("Subject"); got != "Abuse report for 192.0.2.1" {
+		t.Errorf("Subject = %q", got)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	body, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := io.ReadAll(body)
+	for _, want := range []string{"abusive traffic from 192.0.2.1", "(network 192.0.2.0 - 192.0.2.255)", "2024-01-15 10:30:00", "Network holder:      Example Networks", "SOC <soc@example.com>"} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("body missing %q:\n%s", want, text)
+		}
+	}
+
+	attachment, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != "ipintel-192.0.2.1.json" {
+		t.Errorf("attachment name = %q", attachment.FileName())
+	}
+	var evidence model.Report
+	if err := json.NewDecoder(attachment).Decode(&evidence); err != nil || evidence.IP != report.IP {
+		t.Errorf("attachment = %+v, %v; want the report", evidence, err)
+	}
+}
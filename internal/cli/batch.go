@@ -0,0 +1,467 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"api-client/internal/latency"
+	"api-client/internal/model"
+	"api-client/internal/parquet"
+	"api-client/internal/provider"
+)
+
+// GroupBy selects how the summary of batch text output clusters addresses.
+type GroupBy string
+
+const (
+	GroupByNone    GroupBy = ""
+	GroupByASN     GroupBy = "asn"
+	GroupByCountry GroupBy = "country"
+	GroupByISP     GroupBy = "isp"
+)
+
+// ParseGroupBy converts a grouping name into a GroupBy.
+func ParseGroupBy(groupBy string) (GroupBy, error) {
+	switch groupBy {
+	case "":
+		return GroupByNone, nil
+	case "asn":
+		return GroupByASN, nil
+	case "country":
+		return GroupByCountry, nil
+	case "isp":
+		return GroupByISP, nil
+	default:
+		return "", fmt.Errorf("invalid grouping %q: must be 'asn', 'country' or 'isp'", groupBy)
+	}
+}
+
+// summaryGroup is a group of the summary of batch text output: the
+// addresses sharing an ASN, country or ISP.
+type summaryGroup struct {
+	key     string
+	heading string
+	lines   []string
+	failed  int
+}
+
+// BatchWriter writes the reports of a batch run one at a time, as they
+// complete, so that reports need not be held in memory until the run ends.
+// The output is the same as that of FormatBatch.
+type BatchWriter struct {
+	f            *Formatter
+	format       OutputFormat
+	total        int
+	inputColumns []string
+
+	written int
+	skipped int
+	csv     *csv.Writer
+	parquet *parquet.Writer
+
+	// Text output ends with a summary line per address; only those lines
+	// are kept, not the reports. Grouped, the lines are kept by group until
+	// the summary is written.
+	summary bytes.Buffer
+	table   *tabwriter.Writer
+	failed  int
+	groups  map[string]*summaryGroup
+
+	// Latencies of text output, by provider in order of appearance, and
+	// of whole lookups
+	providers []string
+	latencies map[string]*latency.Histogram
+	overall   latency.Histogram
+
+	// Timeouts of text output by provider, by phase
+	timeouts map[string]map[string]int
+}
+
+// NewBatchWriter returns a BatchWriter for a run of total reports. Text
+// output numbers its sections out of total, and CSV and Parquet output have
+// a column for each of inputColumns, the passthrough fields of the batch
+// input.
+func (f *Formatter) NewBatchWriter(format OutputFormat, total int, inputColumns []string) (*BatchWriter, error) {
+	if f.query != nil {
+		// Query results are written one per line, like JSON reports
+		format = FormatJSON
+	}
+	w := &BatchWriter{f: f, format: format, total: total, inputColumns: inputColumns}
+
+	switch format {
+	case FormatJSON:
+	case FormatText:
+		w.table = tabwriter.NewWriter(&w.summary, 0, 0, 2, ' ', 0)
+		w.latencies = make(map[string]*latency.Histogram)
+		w.timeouts = make(map[string]map[string]int)
+		if f.groupBy != GroupByNone {
+			w.groups = make(map[string]*summaryGroup)
+		}
+	case FormatCSV:
+		w.csv = csv.NewWriter(f.w)
+	case FormatKML:
+	case FormatParquet:
+		w.parquet = parquet.NewWriter(f.w, parquetSchema(inputColumns))
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return w, nil
+}
+
+// Skip accounts for a report left out of the output, such as one not
+// matching --filter, so that text sections keep the position of their
+// address in the run.
+func (w *BatchWriter) Skip() {
+	w.skipped++
+}
+
+// Write outputs the next report.
+func (w *BatchWriter) Write(report model.Report) error {
+	w.written++
+
+	switch w.format {
+	case FormatJSON:
+		if w.f.query != nil {
+			return w.f.writeQuery(report, false)
+		}
+		if w.f.array {
+			return w.writeElement(report)
+		}
+		return w.f.writeJSON(w.f.jsonReport(report), false)
+	case FormatCSV:
+		if w.written == 1 {
+			if err := w.csv.Write(csvHeader(w.inputColumns)); err != nil {
+				return err
+			}
+		}
+		if err := w.csv.Write(csvRow(report, w.inputColumns)); err != nil {
+			return err
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case FormatKML:
+		if w.written == 1 {
+			if _, err := io.WriteString(w.f.w, kmlHeader); err != nil {
+				return err
+			}
+		}
+		return w.f.writePlacemark(report)
+	case FormatParquet:
+		return w.parquet.Write(parquetRow(report, w.inputColumns)...)
+	default:
+		return w.writeText(report)
+	}
+}
+
+// writeElement writes report as the next element of the JSON array of the
+// run, opening the array before the first. Separators precede elements
+// rather than follow them, so that Close can end the array whenever the run
+// stops.
+func (w *BatchWriter) writeElement(report model.Report) error {
+	data, err := w.f.signedJSON(w.f.jsonReport(report))
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if w.written == 1 {
+		sep = "[\n"
+	}
+	_, err = w.f.w.Write(append([]byte(sep), data...))
+	return err
+}
+
+func (w *BatchWriter) writeText(report model.Report) error {
+	if w.written > 1 {
+		if _, err := io.WriteString(w.f.w, "\n"); err != nil {
+			return err
+		}
+	}
+
+	if w.total > 1 {
+		section := fmt.Sprintf("### [%d/%d] %s ", w.written+w.skipped, w.total, report.IP)
+		if _, err := io.WriteString(w.f.w, section+strings.Repeat("#", max(50-utf8.RuneCountInString(section), 3))+"\n\n"); err != nil {
+			return err
+		}
+	}
+
+	country := "FAILED"
+	asn := ""
+	var consensus model.Geolocation
+	if report.AllFailed() {
+		w.failed++
+	} else {
+		consensus = report.Consensus()
+		country = w.f.country(consensus)
+		asn = consensus.ASN
+	}
+	line := fmt.Sprintf("  %s\t%s\t%s\t%d/%d\n",
+		report.IP, country, asn, report.SuccessCount(), report.SuccessCount()+report.ErrorCount())
+
+	if w.groups == nil {
+		_, _ = io.WriteString(w.table, line)
+	} else {
+		key, heading := w.f.group(consensus)
+		g, ok := w.groups[key]
+		if !ok {
+			g = &summaryGroup{key: key, heading: heading}
+			w.groups[key] = g
+		}
+		g.lines = append(g.lines, line)
+		if report.AllFailed() {
+			g.failed++
+		}
+	}
+	w.observe(report)
+
+	return w.f.formatText(report)
+}
+
+// observe records the latencies of report: that of each provider queried,
+// whether it answered or failed, and that of the whole lookup; and the
+// phase of the providers that timed out.
+func (w *BatchWriter) observe(report model.Report) {
+	for _, pr := range report.Results {
+		if pr.Skipped || pr.Duration <= 0 {
+			continue
+		}
+		h, ok := w.latencies[pr.Provider]
+		if !ok {
+			h = &latency.Histogram{}
+			w.latencies[pr.Provider] = h
+			w.providers = append(w.providers, pr.Provider)
+		}
+		h.Observe(pr.Duration)
+
+		if pr.Timeout != "" {
+			if w.timeouts[pr.Provider] == nil {
+				w.timeouts[pr.Provider] = make(map[string]int)
+			}
+			w.timeouts[pr.Provider][pr.Timeout]++
+		}
+	}
+	if report.TotalDuration > 0 {
+		w.overall.Observe(report.TotalDuration)
+	}
+}
+
+// Close finishes the output: the end of the JSON array, the CSV header when
+// no report was written, the end of the KML document, the buffered rows and
+// footer of the Parquet file, or the summary across all addresses of text
+// output.
+func (w *BatchWriter) Close() error {
+	switch w.format {
+	case FormatJSON:
+		if !w.f.array || w.f.query != nil {
+			return nil
+		}
+		end := "\n]\n"
+		if w.written == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(w.f.w, end)
+		return err
+	case FormatCSV:
+		if w.written == 0 {
+			if err := w.csv.Write(csvHeader(w.inputColumns)); err != nil {
+				return err
+			}
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case FormatKML:
+		end := kmlFooter
+		if w.written == 0 {
+			end = kmlHeader + end
+		}
+		_, err := io.WriteString(w.f.w, end)
+		return err
+	case FormatParquet:
+		return w.parquet.Close()
+	case FormatText:
+		if w.total < 2 {
+			return nil
+		}
+		_, err := io.WriteString(w.f.w, "\n"+w.formatSummary())
+		return err
+	default:
+		return nil
+	}
+}
+
+// formatSummary renders one line per address with its consensus country,
+// ASN and provider success count, followed by the overall result.
+func (w *BatchWriter) formatSummary() string {
+	_ = w.table.Flush()
+
+	var sb strings.Builder
+	if w.skipped > 0 {
+		sb.WriteString(fmt.Sprintf("SUMMARY (%d addresses, %d filtered out):\n", w.written, w.skipped))
+	} else {
+		sb.WriteString(fmt.Sprintf("SUMMARY (%d addresses):\n", w.written))
+	}
+	sb.WriteString(sectionRule + "\n")
+	if w.groups != nil {
+		w.formatGroups(&sb)
+	} else if w.written > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(w.summary.String(), "\n"), "\n") {
+			w.f.writeLine(&sb, strings.TrimRight(line, " "))
+		}
+	}
+	sb.WriteString(sectionRule + "\n")
+	sb.WriteString(fmt.Sprintf("Total: %d/%d lookups succeeded\n", w.written-w.failed, w.written))
+	w.formatLatencies(&sb)
+	w.formatTimeouts(&sb)
+
+	return sb.String()
+}
+
+// formatTimeouts renders the number of connect and read timeouts of each
+// provider that timed out, if any did: the former point to a firewall or
+// unreachable host, the latter to a slow API.
+func (w *BatchWriter) formatTimeouts(sb *strings.Builder) {
+	if len(w.timeouts) == 0 {
+		return
+	}
+
+	nameWidth := 0
+	for name := range w.timeouts {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(name))
+	}
+
+	sb.WriteString("\nTIMEOUTS:\n")
+	for _, name := range w.providers {
+		counts, ok := w.timeouts[name]
+		if !ok {
+			continue
+		}
+		line := fmt.Sprintf("  %-*s  %d connect, %d read", nameWidth, name, counts[provider.TimeoutConnect], counts[provider.TimeoutRead])
+		w.f.writeLine(sb, line)
+	}
+}
+
+// formatLatencies renders the 50th, 90th and 99th percentile latencies of
+// each provider and of whole lookups, if any were measured, so that a
+// provider slowing down shows from one run to the next.
+func (w *BatchWriter) formatLatencies(sb *strings.Builder) {
+	if w.overall.Count() == 0 && len(w.providers) == 0 {
+		return
+	}
+
+	type row struct {
+		name   string
+		values [3]string
+	}
+	var rows []row
+	add := func(name string, h *latency.Histogram) {
+		r := row{name: name}
+		for i, p := range []float64{50, 90, 99} {
+			r.values[i] = formatMillis(h.Percentile(p))
+		}
+		rows = append(rows, r)
+	}
+	for _, name := range w.providers {
+		add(name, w.latencies[name])
+	}
+	if w.overall.Count() > 0 {
+		add("overall", &w.overall)
+	}
+
+	nameWidth, valueWidth := 0, len("p99")
+	for _, r := range rows {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(r.name))
+		for _, v := range r.values {
+			valueWidth = max(valueWidth, len(v))
+		}
+	}
+
+	sb.WriteString("\nLATENCY:\n")
+	w.f.writeLine(sb, fmt.Sprintf("  %-*s  %*s  %*s  %*s", nameWidth, "", valueWidth, "p50", valueWidth, "p90", valueWidth, "p99"))
+	for _, r := range rows {
+		line := fmt.Sprintf("  %-*s  %*s  %*s  %*s", nameWidth, r.name, valueWidth, r.values[0], valueWidth, r.values[1], valueWidth, r.values[2])
+		w.f.writeLine(sb, line)
+	}
+}
+
+// formatGroups renders the summary lines under a heading per group, with
+// the number of addresses and failed lookups of the group, largest group
+// first and the unknown one last. The lines of all groups are aligned
+// together.
+func (w *BatchWriter) formatGroups(sb *strings.Builder) {
+	groups := make([]*summaryGroup, 0, len(w.groups))
+	for _, g := range w.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].key == "") != (groups[j].key == "") {
+			return groups[j].key == ""
+		}
+		if len(groups[i].lines) != len(groups[j].lines) {
+			return len(groups[i].lines) > len(groups[j].lines)
+		}
+		return groups[i].heading < groups[j].heading
+	})
+
+	// The headings have no cells, so they would end the column blocks of
+	// the tabwriter; they are inserted once the lines are aligned
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	for _, g := range groups {
+		for _, line := range g.lines {
+			_, _ = io.WriteString(tw, line)
+		}
+	}
+	_ = tw.Flush()
+	lines := strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+
+	for i, g := range groups {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		subtotal := fmt.Sprintf("%d address(es)", len(g.lines))
+		if g.failed > 0 {
+			subtotal += fmt.Sprintf(", %d failed", g.failed)
+		}
+		w.f.writeLine(sb, fmt.Sprintf("%s (%s)", g.heading, subtotal))
+
+		for _, line := range lines[:len(g.lines)] {
+			w.f.writeLine(sb, strings.TrimRight(line, " "))
+		}
+		lines = lines[len(g.lines):]
+	}
+}
+
+// group returns the key and heading of the summary group of consensus, by
+// the grouping of the Formatter. Lookups without a value to group by,
+// failed ones among them, are grouped under "Unknown".
+func (f *Formatter) group(consensus model.Geolocation) (key, heading string) {
+	switch f.groupBy {
+	case GroupByASN:
+		if consensus.ASN == "" {
+			return "", "Unknown ASN"
+		}
+		return consensus.ASN, joinNonEmpty(consensus.ASN, consensus.ISP)
+	case GroupByCountry:
+		if consensus.CountryCode == "" && consensus.Country == "" {
+			return "", "Unknown country"
+		}
+		if consensus.CountryCode == "" {
+			return consensus.Country, consensus.Country
+		}
+		return strings.ToUpper(consensus.CountryCode), f.country(consensus)
+	case GroupByISP:
+		if consensus.ISP == "" {
+			return "", "Unknown ISP"
+		}
+		return strings.ToLower(consensus.ISP), consensus.ISP
+	default:
+		return "", ""
+	}
+}
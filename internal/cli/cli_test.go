@@ -2,9 +2,13 @@ package cli
 
 import (
 	"bytes"
+	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"api-client/internal/batch"
 )
 
 func TestParser_Parse_Defaults(t *testing.T) {
@@ -33,6 +37,10 @@ func TestParser_Parse_Defaults(t *testing.T) {
 	if cfg.ShowVersion {
 		t.Error("ShowVersion should be false by default")
 	}
+
+	if cfg.NoEmoji != (runtime.GOOS == "windows") {
+		t.Errorf("NoEmoji = %v, want it set on Windows only", cfg.NoEmoji)
+	}
 }
 
 func TestParser_Parse_FormatText(t *testing.T) {
@@ -189,6 +197,176 @@ func TestParser_Parse_CombinedFlags(t *testing.T) {
 	}
 }
 
+func TestParser_Parse_Batch(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"-i", "ips.txt", "--concurrency", "8", "--fail-fast", "--skip-invalid", "1.1.1.1", "8.8.8.8"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if cfg.InputFile != "ips.txt" {
+		t.Errorf("InputFile = %q, want ips.txt", cfg.InputFile)
+	}
+
+	if cfg.Concurrency != 8 {
+		t.Errorf("Concurrency = %d, want 8", cfg.Concurrency)
+	}
+
+	if !cfg.FailFast {
+		t.Error("FailFast should be true")
+	}
+
+	if !cfg.SkipInvalid {
+		t.Error("SkipInvalid should be true")
+	}
+
+	if len(cfg.Addresses) != 2 || cfg.Addresses[1] != "8.8.8.8" {
+		t.Errorf("Addresses = %v, want [1.1.1.1 8.8.8.8]", cfg.Addresses)
+	}
+
+	if cfg.IPAddress != "1.1.1.1" {
+		t.Errorf("IPAddress = %q, want the first address", cfg.IPAddress)
+	}
+}
+
+func TestParser_Parse_InputFormat(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantFormat batch.InputFormat
+		wantColumn string
+	}{
+		{[]string{"-i", "ips.txt"}, batch.InputText, "ip"},
+		{[]string{"-i", "ips.csv", "--input-format", "csv"}, batch.InputCSV, "ip"},
+		{[]string{"-i", "ips.csv", "--column", "src_ip"}, batch.InputCSV, "src_ip"},
+		{[]string{"-i", "events.json", "--input-format", "json", "--column", "addr"}, batch.InputJSON, "addr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			p := NewParser()
+			cfg, err := p.Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if cfg.InputFormat != tt.wantFormat {
+				t.Errorf("InputFormat = %v, want %v", cfg.InputFormat, tt.wantFormat)
+			}
+
+			if cfg.Column != tt.wantColumn {
+				t.Errorf("Column = %q, want %q", cfg.Column, tt.wantColumn)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_StdinBatch(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"--input-format", "json", "-"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if cfg.InputFile != "-" {
+		t.Errorf("InputFile = %q, want '-'", cfg.InputFile)
+	}
+
+	if cfg.IPAddress != "" || len(cfg.Addresses) != 0 {
+		t.Errorf("IPAddress = %q, Addresses = %v, want none", cfg.IPAddress, cfg.Addresses)
+	}
+
+	// Without a structured input format, "-" still reads a single address
+	cfg, err = NewParser().Parse([]string{"-"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if cfg.IPAddress != "-" || cfg.InputFile != "" {
+		t.Errorf("IPAddress = %q, InputFile = %q, want single-address stdin mode", cfg.IPAddress, cfg.InputFile)
+	}
+}
+
+func TestParser_Parse_DryRun(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"--dry-run", "8.8.8.8"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !cfg.DryRun {
+		t.Error("DryRun should be true")
+	}
+}
+
+func TestParser_Parse_JSONStyle(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"--json-style", "camel", "8.8.8.8"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if cfg.JSONStyle != JSONStyleCamel {
+		t.Errorf("JSONStyle = %v, want JSONStyleCamel", cfg.JSONStyle)
+	}
+
+	p = NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	if _, err := p.Parse([]string{"--json-style", "kebab", "8.8.8.8"}); err == nil {
+		t.Error("Parse() expected error for invalid JSON style")
+	}
+}
+
+func TestParser_Parse_Query(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"--query", "results[*].provider", "8.8.8.8"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.Query == nil || cfg.Query.String() != "results[*].provider" {
+		t.Errorf("Query = %v, want results[*].provider", cfg.Query)
+	}
+
+	p = NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	if _, err := p.Parse([]string{"--query", "results[", "8.8.8.8"}); err == nil || !strings.Contains(err.Error(), "--query") {
+		t.Errorf("Parse() error = %v, want an invalid --query error", err)
+	}
+}
+
+func TestParser_Parse_Filter(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"--filter", `country_code != "US" && is_hosting`, "8.8.8.8"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.Filter == nil {
+		t.Error("Filter = nil, want the compiled condition")
+	}
+
+	p = NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	if _, err := p.Parse([]string{"--filter", "colour == 'red'", "8.8.8.8"}); err == nil || !strings.Contains(err.Error(), "--filter: at 1: unknown field") {
+		t.Errorf("Parse() error = %v, want an invalid --filter error", err)
+	}
+}
+
+func TestParser_Parse_EmailTo(t *testing.T) {
+	p := NewParser()
+	cfg, err := p.Parse([]string{"--email-to", "SOC <soc@example.com>, it@example.com", "-i", "ips.txt"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if want := []string{"soc@example.com", "it@example.com"}; !reflect.DeepEqual(cfg.EmailTo, want) {
+		t.Errorf("EmailTo = %q, want %q", cfg.EmailTo, want)
+	}
+
+	p = NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	if _, err := p.Parse([]string{"--email-to", "soc", "-i", "ips.txt"}); err == nil || !strings.Contains(err.Error(), "--email-to") {
+		t.Errorf("Parse() error = %v, want an invalid --email-to error", err)
+	}
+}
+
 func TestParser_PrintUsage(t *testing.T) {
 	var stdout, stderr bytes.Buffer
 	p := NewParser()
@@ -247,7 +425,7 @@ func TestConfig_Validate(t *testing.T) {
 	}{
 		{
 			name:    "valid config",
-			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second},
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1},
 			wantErr: false,
 		},
 		{
@@ -256,6 +434,129 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "IP address is required",
 		},
+		{
+			name:    "input file without IP address",
+			cfg:     Config{InputFile: "ips.txt", Timeout: 10 * time.Second, Concurrency: 1},
+			wantErr: false,
+		},
+		{
+			name:    "zero concurrency",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second},
+			wantErr: true,
+			errMsg:  "concurrency must be at least 1",
+		},
+		{
+			name:    "negative max-inflight",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, MaxInflight: -1},
+			wantErr: true,
+			errMsg:  "max-inflight must not be negative",
+		},
+		{
+			name:    "negative retries",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Retries: -1},
+			wantErr: true,
+			errMsg:  "retries must not be negative",
+		},
+		{
+			name:    "country summary of unknown format",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, CountrySummary: "countries.txt"},
+			wantErr: true,
+			errMsg:  "--country-summary file must end in .csv or .json",
+		},
+		{
+			name:    "network summary of unknown format",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, NetworkSummary: "networks.txt"},
+			wantErr: true,
+			errMsg:  "--network-summary file must end in .csv or .json",
+		},
+		{
+			name:    "grouped CSV output",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Format: FormatCSV, GroupBy: GroupByASN},
+			wantErr: true,
+			errMsg:  "--group-by requires text output",
+		},
+		{
+			name:    "map in JSON output",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Format: FormatJSON, Map: true},
+			wantErr: true,
+			errMsg:  "--map requires text output",
+		},
+		{
+			name:    "cache key without cache dir",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, CacheKey: "cache.key"},
+			wantErr: true,
+			errMsg:  "--cache-key requires --cache-dir",
+		},
+		{
+			name:    "signed text output",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Format: FormatText, SignKey: "ipintel.key"},
+			wantErr: true,
+			errMsg:  "--sign-key requires JSON output",
+		},
+		{
+			name:    "signed JSON output",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Format: FormatJSON, SignKey: "ipintel.key"},
+			wantErr: false,
+		},
+		{
+			name:    "min-agreement above 1",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, MinAgreement: 1.5},
+			wantErr: true,
+			errMsg:  "min-agreement must be between 0 and 1",
+		},
+		{
+			name:    "negative max-providers",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, MaxProviders: -1},
+			wantErr: true,
+			errMsg:  "max-providers must not be negative",
+		},
+		{
+			name:    "negative quorum",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Quorum: -1},
+			wantErr: true,
+			errMsg:  "quorum must not be negative",
+		},
+		{
+			name:    "negative hedge delay",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, HedgeDelay: -time.Second},
+			wantErr: true,
+			errMsg:  "hedge delay must not be negative",
+		},
+		{
+			name:    "negative dns ttl",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, DNSTTL: -time.Second},
+			wantErr: true,
+			errMsg:  "dns-ttl must not be negative",
+		},
+		{
+			name:    "json array with text output",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Format: FormatText, JSONArray: true},
+			wantErr: true,
+			errMsg:  "--json-array requires JSON output, without --query",
+		},
+		{
+			name:    "no consensus with a map",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Format: FormatText, NoConsensus: true, Map: true},
+			wantErr: true,
+			errMsg:  "--map requires the consensus, which --no-consensus disables",
+		},
+		{
+			name:    "offline with a URL input",
+			cfg:     Config{InputFile: "https://example.com/ips.txt", Timeout: 10 * time.Second, Concurrency: 1, Offline: true},
+			wantErr: true,
+			errMsg:  "--input-file https://example.com/ips.txt uses the network, which --offline rules out",
+		},
+		{
+			name:    "offline with a published report",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Offline: true, Publish: "nats://localhost:4222/ips"},
+			wantErr: true,
+			errMsg:  "--publish uses the network, which --offline rules out",
+		},
+		{
+			name:    "offline with a local input and output",
+			cfg:     Config{InputFile: "ips.txt", Output: "out.json", Timeout: 10 * time.Second, Concurrency: 1, Offline: true},
+			wantErr: false,
+		},
 		{
 			name:    "help flag skips validation",
 			cfg:     Config{ShowHelp: true},
@@ -272,6 +573,17 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "timeout must be at least 100 millisecond",
 		},
+		{
+			name:    "valid language",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Language: "pt-BR"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid language",
+			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 10 * time.Second, Concurrency: 1, Language: "not a language"},
+			wantErr: true,
+			errMsg:  "invalid language",
+		},
 		{
 			name:    "timeout too high",
 			cfg:     Config{IPAddress: "8.8.8.8", Timeout: 100 * time.Second},
@@ -310,3 +622,53 @@ func MustParseDuration(duration string) time.Duration {
 
 	return d
 }
+
+func TestParser_ParseSelfUpdateCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseSelfUpdateCommand([]string{"--check"})
+	if err != nil {
+		t.Fatalf("ParseSelfUpdateCommand() error = %v", err)
+	}
+	if !cmd.CheckOnly || cmd.Timeout != 2*time.Minute {
+		t.Errorf("ParseSelfUpdateCommand() = %+v", cmd)
+	}
+
+	if cmd, err = p.ParseSelfUpdateCommand([]string{"--insecure"}); err != nil || !cmd.Insecure || cmd.CheckOnly {
+		t.Errorf("ParseSelfUpdateCommand(--insecure) = %+v, %v", cmd, err)
+	}
+
+	for _, args := range [][]string{{"--timeout", "0s"}, {"v1.2.3"}} {
+		if _, err := p.ParseSelfUpdateCommand(args); err == nil {
+			t.Errorf("ParseSelfUpdateCommand(%v) expected error", args)
+		}
+	}
+}
+
+func TestParser_ParseRunCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseRunCommand([]string{"--schedule", "0 * * * *", "--jitter", "30s", "--input", "targets.txt", "-o", "out.csv"})
+	if err != nil {
+		t.Fatalf("ParseRunCommand() error = %v", err)
+	}
+	if cmd.Schedule == nil || cmd.Jitter != 30*time.Second || cmd.Config.InputFile != "targets.txt" || cmd.Config.Output != "out.csv" {
+		t.Errorf("ParseRunCommand() = %+v", cmd)
+	}
+
+	for _, args := range [][]string{
+		{"-i", "targets.txt"},
+		{"--schedule", "every hour", "-i", "targets.txt"},
+		{"--schedule", "@hourly", "--jitter", "-1s", "-i", "targets.txt"},
+		{"--schedule", "@hourly", "-i", "-"},
+		{"--schedule", "@hourly", "--checkpoint", "run.json", "-i", "targets.txt"},
+	} {
+		p := NewParser()
+		p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+		if _, err := p.ParseRunCommand(args); err == nil {
+			t.Errorf("ParseRunCommand(%v) expected error", args)
+		}
+	}
+}
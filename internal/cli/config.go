@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"api-client/internal/config"
+)
+
+// PrintConfig writes the effective configuration with the source of each
+// value. API keys are redacted.
+func PrintConfig(w io.Writer, cfg config.Config, format OutputFormat) error {
+	cfg = cfg.Redacted()
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cfg)
+	case FormatText:
+		return printConfigText(w, cfg)
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func printConfigText(w io.Writer, cfg config.Config) error {
+	var sb strings.Builder
+
+	sb.WriteString("EFFECTIVE CONFIGURATION\n")
+	sb.WriteString(strings.Repeat("=", 50) + "\n\n")
+
+	file := cfg.File
+	if file == "" {
+		file = "(none)"
+	}
+	sb.WriteString(fmt.Sprintf("Config file: %s\n\n", file))
+
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	row := func(key, value, source string) {
+		if value == "" {
+			value = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", key, value, source)
+	}
+
+	row("format", cfg.Format.Value, cfg.Format.Source)
+	row("timeout", durationString(cfg.Timeout.Value), cfg.Timeout.Source)
+	row("providers", strings.Join(cfg.Providers.Value, ", "), cfg.Providers.Source)
+	row("secondary", strings.Join(cfg.Secondary.Value, ", "), cfg.Secondary.Source)
+	row("shadow", strings.Join(cfg.Shadow.Value, ", "), cfg.Shadow.Source)
+
+	names := make([]string, 0, len(cfg.Provider))
+	for name := range cfg.Provider {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pc := cfg.Provider[name]
+		prefix := "provider." + name + "."
+		row(prefix+"api_key", pc.APIKey.Value, pc.APIKey.Source)
+		row(prefix+"base_url", pc.BaseURL.Value, pc.BaseURL.Source)
+		row(prefix+"timeout", durationString(pc.Timeout.Value), pc.Timeout.Source)
+		row(prefix+"quota", pc.Quota.Value.String(), pc.Quota.Source)
+		row(prefix+"rate_limit", pc.RateLimit.Value.String(), pc.RateLimit.Source)
+		row(prefix+"retries", strconv.Itoa(pc.Retries.Value), pc.Retries.Source)
+		if pc.Custom != nil {
+			row(prefix+"url", pc.Custom.Value.URL, pc.Custom.Source)
+			row(prefix+"headers", joinMap(pc.Custom.Value.Headers, ": "), pc.Custom.Source)
+			row(prefix+"fields", joinMap(pc.Custom.Value.Fields, "="), pc.Custom.Source)
+		}
+	}
+
+	row("dialer.timeout", durationString(cfg.Dialer.Timeout.Value), cfg.Dialer.Timeout.Source)
+	row("dialer.fallback_delay", durationString(cfg.Dialer.FallbackDelay.Value), cfg.Dialer.FallbackDelay.Source)
+	row("dialer.keep_alive", durationString(cfg.Dialer.KeepAlive.Value), cfg.Dialer.KeepAlive.Source)
+	row("smtp.host", cfg.SMTP.Host.Value, cfg.SMTP.Host.Source)
+	row("smtp.port", fmt.Sprint(cfg.SMTP.Port.Value), cfg.SMTP.Port.Source)
+	row("smtp.username", cfg.SMTP.Username.Value, cfg.SMTP.Username.Source)
+	row("smtp.password", cfg.SMTP.Password.Value, cfg.SMTP.Password.Source)
+	row("smtp.from", cfg.SMTP.From.Value, cfg.SMTP.From.Source)
+
+	for i, r := range cfg.Policy.Value {
+		when := r.When
+		if when == "" {
+			when = "always"
+		}
+		row(fmt.Sprintf("policy[%d]", i), fmt.Sprintf("%s if %s", r.Action, when), cfg.Policy.Source)
+	}
+
+	for i, h := range cfg.Hooks.Value {
+		onFailure := h.OnFailure
+		if onFailure == "" {
+			onFailure = "warn"
+		}
+		row(fmt.Sprintf("hooks[%d]", i), fmt.Sprintf("%s: %s (on failure: %s)", h.Stage, strings.Join(h.Command, " "), onFailure), cfg.Hooks.Source)
+	}
+
+	// Notifiers are shown by kind, their URLs may hold credentials
+	for i, a := range cfg.Alerts.Value {
+		trigger := "changed " + strings.Join(a.Changed, ", ")
+		switch {
+		case a.Enters != "":
+			trigger = "enters " + a.Enters
+		case a.Leaves != "":
+			trigger = "leaves " + a.Leaves
+		}
+		var notifiers []string
+		if a.Webhook != "" {
+			notifiers = append(notifiers, "webhook")
+		}
+		if len(a.Exec) > 0 {
+			notifiers = append(notifiers, "exec")
+		}
+		if a.Syslog != "" {
+			notifiers = append(notifiers, "syslog")
+		}
+		row(fmt.Sprintf("alerts[%d]", i), fmt.Sprintf("%s: %s", trigger, strings.Join(notifiers, ", ")), cfg.Alerts.Source)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func durationString(d config.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return time.Duration(d).String()
+}
+
+// joinMap lists the entries of m sorted by key, each written as the key,
+// sep and the value.
+func joinMap(m map[string]string, sep string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = k + sep + m[k]
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/config"
+)
+
+func makeTestConfig() config.Config {
+	return config.Config{
+		File:      "/etc/ipintel.json",
+		Format:    config.Value[string]{Value: "json", Source: "env:IPINTEL_FORMAT"},
+		Timeout:   config.Value[config.Duration]{Value: config.Duration(5 * time.Second), Source: "flag:--timeout"},
+		Providers: config.Value[[]string]{Value: []string{"ipinfo"}, Source: "default"},
+		Provider: map[string]config.ProviderConfig{
+			"ipinfo": {
+				APIKey:  config.Value[string]{Value: "0123456789abcdef", Source: "file:/etc/ipintel.json"},
+				BaseURL: config.Value[string]{Source: "default"},
+				Timeout: config.Value[config.Duration]{Source: "default"},
+			},
+			"my-geo": {
+				Custom: &config.Value[config.CustomProvider]{Value: config.CustomProvider{
+					URL:     "https://my-geo.internal/{ip}",
+					Headers: map[string]string{"X-Token": "tenant-secret"},
+					Fields:  map[string]string{"latitude": "lat", "country_code": "cc"},
+				}, Source: "file:/etc/ipintel.json"},
+			},
+		},
+	}
+}
+
+func TestPrintConfig_Text(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintConfig(&buf, makeTestConfig(), FormatText); err != nil {
+		t.Fatalf("PrintConfig() error = %v", err)
+	}
+
+	output := buf.String()
+
+	expectedStrings := []string{
+		"EFFECTIVE CONFIGURATION",
+		"Config file: /etc/ipintel.json",
+		"(env:IPINTEL_FORMAT)",
+		"5s",
+		"(flag:--timeout)",
+		"provider.ipinfo.api_key",
+		"****cdef",
+		"provider.my-geo.url",
+		"https://my-geo.internal/{ip}",
+		"X-Token: ****cret",
+		"country_code=cc, latitude=lat",
+	}
+
+	for _, expected := range expectedStrings {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output should contain %q\nGot:\n%s", expected, output)
+		}
+	}
+
+	if strings.Contains(output, "0123456789abcdef") || strings.Contains(output, "tenant-secret") {
+		t.Error("output should not contain the unredacted API key")
+	}
+}
+
+func TestPrintConfig_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintConfig(&buf, makeTestConfig(), FormatJSON); err != nil {
+		t.Fatalf("PrintConfig() error = %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+
+	timeout, ok := decoded["timeout"].(map[string]any)
+	if !ok {
+		t.Fatalf("timeout should be an object, got %T", decoded["timeout"])
+	}
+
+	if timeout["value"] != "5s" || timeout["source"] != "flag:--timeout" {
+		t.Errorf("timeout = %v, want 5s from flag:--timeout", timeout)
+	}
+
+	if strings.Contains(buf.String(), "0123456789abcdef") {
+		t.Error("output should not contain the unredacted API key")
+	}
+}
+
+func TestParser_ParseConfigCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseConfigCommand([]string{"show", "--format", "json", "--config", "/tmp/c.json"})
+	if err != nil {
+		t.Fatalf("ParseConfigCommand() error = %v", err)
+	}
+
+	if cmd.Action != "show" {
+		t.Errorf("Action = %q, want show", cmd.Action)
+	}
+
+	if cmd.Format != FormatJSON {
+		t.Errorf("Format = %v, want FormatJSON", cmd.Format)
+	}
+
+	if cmd.ConfigPath != "/tmp/c.json" {
+		t.Errorf("ConfigPath = %q, want /tmp/c.json", cmd.ConfigPath)
+	}
+}
+
+func TestParser_ParseConfigCommand_LookupFlags(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseConfigCommand([]string{"show", "--quorum", "2", "-t", "3s"})
+	if err != nil {
+		t.Fatalf("ParseConfigCommand() error = %v", err)
+	}
+	if cmd.Config.Quorum != 2 || cmd.Config.Timeout != 3*time.Second || !p.IsSet("timeout") {
+		t.Errorf("Config = %+v, want the lookup flags", cmd.Config)
+	}
+	if cmd.Format != FormatText {
+		t.Errorf("Format = %v, want FormatText", cmd.Format)
+	}
+
+	p = NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	if cmd, err = p.ParseConfigCommand([]string{"show", "--help"}); err != nil || !cmd.Config.ShowHelp {
+		t.Errorf("ParseConfigCommand(--help) = %+v, %v; want help", cmd.Config, err)
+	}
+}
+
+func TestParser_ParseConfigCommand_Errors(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"edit"},
+		{"show", "-f", "xml"},
+		{"show", "-f", "csv"},
+		{"show", "extra"},
+	}
+
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			p := NewParser()
+			p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+			if _, err := p.ParseConfigCommand(args); err == nil {
+				t.Error("ParseConfigCommand() expected error")
+			}
+		})
+	}
+}
+
+func TestParser_IsSet(t *testing.T) {
+	p := NewParser()
+	if _, err := p.Parse([]string{"-t", "5s", "8.8.8.8"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !p.IsSet("timeout") {
+		t.Error("IsSet(timeout) should be true when -t was given")
+	}
+
+	if p.IsSet("format") {
+		t.Error("IsSet(format) should be false when no format flag was given")
+	}
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"api-client/internal/countries"
+)
+
+// countrySummaryJSON is the JSON form of a country tally.
+type countrySummaryJSON struct {
+	Total     int               `json:"total"`
+	Unknown   int               `json:"unknown"`
+	Countries []countries.Count `json:"countries"`
+}
+
+// CountrySummaryFormat returns the format of the --country-summary file
+// path from its extension: CSV for .csv and JSON for .json.
+func CountrySummaryFormat(path string) (OutputFormat, error) {
+	return summaryFormat("--country-summary", path)
+}
+
+// summaryFormat returns the format of the summary file path written for
+// flag, from its extension.
+func summaryFormat(flag, path string) (OutputFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("%s file must end in .csv or .json, got %q", flag, path)
+	}
+}
+
+// PrintCountrySummary writes the number of addresses per country, most
+// frequent first. CSV has one row per country, to be joined with the
+// features of a map; JSON adds the total and the addresses without a
+// country.
+func PrintCountrySummary(w io.Writer, t *countries.Tally, format OutputFormat) error {
+	counts := t.Counts()
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(countrySummaryJSON{Total: t.Total(), Unknown: t.Unknown(), Countries: counts})
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"iso_a2", "iso_a3", "iso_n3", "name", "count"})
+		for _, c := range counts {
+			_ = cw.Write([]string{c.Code, c.ISO3, c.Numeric, c.Name, strconv.Itoa(c.Count)})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"api-client/internal/countries"
+	"api-client/internal/model"
+)
+
+func testTally() *countries.Tally {
+	tally := countries.NewTally()
+	for _, code := range []string{"FR", "GB", "FR", ""} {
+		tally.Add(model.Report{Results: []model.ProviderResult{
+			{Provider: "ipinfo", Result: &model.Geolocation{CountryCode: code}},
+		}})
+	}
+	return tally
+}
+
+func TestPrintCountrySummary_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintCountrySummary(&buf, testTally(), FormatCSV); err != nil {
+		t.Fatalf("PrintCountrySummary() error = %v", err)
+	}
+
+	want := "iso_a2,iso_a3,iso_n3,name,count\n" +
+		"FR,FRA,250,France,2\n" +
+		"GB,GBR,826,United Kingdom,1\n"
+	if buf.String() != want {
+		t.Errorf("PrintCountrySummary() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrintCountrySummary_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintCountrySummary(&buf, testTally(), FormatJSON); err != nil {
+		t.Fatalf("PrintCountrySummary() error = %v", err)
+	}
+
+	for _, want := range []string{`"total": 4`, `"unknown": 1`, `"iso_n3": "250"`, `"count": 2`} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("output missing %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCountrySummaryFormat(t *testing.T) {
+	for path, want := range map[string]OutputFormat{"out/countries.csv": FormatCSV, "countries.JSON": FormatJSON} {
+		if got, err := CountrySummaryFormat(path); err != nil || got != want {
+			t.Errorf("CountrySummaryFormat(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	if _, err := CountrySummaryFormat("countries.topojson"); err == nil {
+		t.Error("CountrySummaryFormat() of an unknown extension expected error")
+	}
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/csv"
+	"strconv"
+
+	"api-client/internal/model"
+)
+
+// csvColumns are the columns written for every report in CSV output, after
+// any passthrough input columns.
+var csvColumns = []string{
+	"ip", "country", "country_code", "region", "city", "latitude", "longitude",
+	"isp", "org", "asn", "hostname", "is_anycast", "providers_succeeded", "providers_total",
+	"granularity",
+}
+
+// formatCSV writes one row per report with its consensus values. Fields
+// passed through from batch input come first, in the order they were first
+// seen, so that ipintel can enrich rows in a pipeline without losing context.
+func (f *Formatter) formatCSV(reports []model.Report) error {
+	w := csv.NewWriter(f.w)
+	inputColumns := InputColumns(reports)
+	if err := w.Write(csvHeader(inputColumns)); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		if err := w.Write(csvRow(report, inputColumns)); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// InputColumns returns the names of the passthrough input fields of reports,
+// in the order they were first seen.
+func InputColumns(reports []model.Report) []string {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, report := range reports {
+		for _, field := range report.Input {
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				columns = append(columns, field.Name)
+			}
+		}
+	}
+	return columns
+}
+
+func csvHeader(inputColumns []string) []string {
+	return append(append([]string{}, inputColumns...), csvColumns...)
+}
+
+// csvRow renders a report under the header returned by csvHeader. Input
+// fields not among inputColumns are dropped.
+func csvRow(report model.Report, inputColumns []string) []string {
+	row := make([]string, 0, len(inputColumns)+len(csvColumns))
+	for _, name := range inputColumns {
+		value, _ := report.Input.Get(name)
+		row = append(row, model.Field{Name: name, Value: value}.Text())
+	}
+
+	consensus := report.Consensus()
+	var lat, lon string
+	if latitude, longitude, ok := consensus.Coordinates(); ok {
+		lat = strconv.FormatFloat(latitude, 'f', 4, 64)
+		lon = strconv.FormatFloat(longitude, 'f', 4, 64)
+	}
+
+	return append(row,
+		report.IP.String(),
+		consensus.Country,
+		consensus.CountryCode,
+		consensus.Region,
+		consensus.City,
+		lat,
+		lon,
+		consensus.ISP,
+		consensus.Org,
+		consensus.ASN,
+		consensus.Hostname,
+		strconv.FormatBool(report.IsAnycast),
+		strconv.Itoa(report.SuccessCount()),
+		strconv.Itoa(report.SuccessCount()+report.ErrorCount()),
+		string(consensus.Granularity),
+	)
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestFormatter_FormatBatch_CSV(t *testing.T) {
+	first := makeTestReport()
+	first.Input = model.Fields{
+		{Name: "user", Value: json.RawMessage(`"alice"`)},
+		{Name: "attempts", Value: json.RawMessage(`3`)},
+	}
+	second := makeTestReportWithError()
+	second.Input = model.Fields{
+		{Name: "session", Value: json.RawMessage(`"s-1"`)},
+		{Name: "user", Value: json.RawMessage(`"bob, jr"`)},
+	}
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.FormatBatch([]model.Report{first, second}, FormatCSV); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("output has %d rows, want a header and one row per report", len(rows))
+	}
+
+	header := rows[0]
+	want := []string{"user", "attempts", "session", "ip", "country"}
+	for i, name := range want {
+		if header[i] != name {
+			t.Errorf("header[%d] = %q, want %q", i, header[i], name)
+		}
+	}
+
+	if got := rows[1][:5]; got[0] != "alice" || got[1] != "3" || got[2] != "" || got[3] != "8.8.8.8" || got[4] != "United States" {
+		t.Errorf("rows[1] = %q, want passthrough values followed by the consensus", got)
+	}
+
+	if got := rows[2][0]; got != "bob, jr" {
+		t.Errorf("rows[2] user = %q, want 'bob, jr'", got)
+	}
+
+	if got := rows[1][len(header)-3:]; got[0] != "2" || got[1] != "2" || got[2] != "city" {
+		t.Errorf("provider counts and granularity = %q, want 2 of 2 at city level", got)
+	}
+}
+
+func TestFormatter_Format_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(makeTestReport(), FormatCSV); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+
+	if len(rows) != 2 || rows[0][0] != "ip" {
+		t.Errorf("rows = %q, want the header without input columns and one row", rows)
+	}
+}
+
+func TestFormatter_Format_CSV_ProvidersTotal(t *testing.T) {
+	report := makeTestReport()
+	report.Results = append(report.Results,
+		model.ProviderResult{Provider: "ripestat", Error: "not announced in BGP", Skipped: true},
+		model.ProviderResult{Provider: "candidate", Result: &model.Geolocation{Country: "France"}, Shadow: true},
+	)
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatCSV); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+
+	// Counted like the "Total: 2/2" of text output
+	if got := rows[1][len(rows[1])-3:]; got[0] != "2" || got[1] != "2" {
+		t.Errorf("provider counts = %q, want 2 of 2 without the skipped and shadow providers", got[:2])
+	}
+}
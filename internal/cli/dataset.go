@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"api-client/internal/dataset"
+)
+
+// PrintDatasetStatus writes the state, age and size of each dataset stored
+// in dir.
+func PrintDatasetStatus(w io.Writer, dir string, statuses []dataset.Status) error {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("DATASETS (%s):\n", dir))
+	sb.WriteString(strings.Repeat("-", 40) + "\n")
+
+	var rows strings.Builder
+	tw := tabwriter.NewWriter(&rows, 0, 0, 2, ' ', 0)
+	for _, st := range statuses {
+		if st.State == dataset.StateMissing {
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\t\t\n", st.Name, st.State)
+			continue
+		}
+		state := string(st.State)
+		if st.Err != nil {
+			state += " (" + st.Err.Error() + ")"
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\tupdated %s ago\t%s\n", st.Name, state, formatAge(st.Age), formatSize(st.Entry.Size))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if rows.Len() > 0 {
+		// Rows of missing datasets end with empty, padded cells
+		for _, line := range strings.Split(strings.TrimSuffix(rows.String(), "\n"), "\n") {
+			sb.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// formatAge renders d in the largest whole unit among minutes, hours and
+// days, e.g. "45m", "5h" or "12d".
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// formatSize renders n bytes in B, KB or MB.
+func formatSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+}
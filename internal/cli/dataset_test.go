@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/dataset"
+)
+
+func TestParser_ParseUpdateDataCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseUpdateDataCommand([]string{"--status", "--data-dir", "/tmp/data", "ip2country-v4", "ip2country-v6"})
+	if err != nil {
+		t.Fatalf("ParseUpdateDataCommand() error = %v", err)
+	}
+
+	if !cmd.StatusOnly {
+		t.Error("StatusOnly = false, want true")
+	}
+	if cmd.DataDir != "/tmp/data" {
+		t.Errorf("DataDir = %q, want /tmp/data", cmd.DataDir)
+	}
+	if strings.Join(cmd.Datasets, ",") != "ip2country-v4,ip2country-v6" {
+		t.Errorf("Datasets = %v, want [ip2country-v4 ip2country-v6]", cmd.Datasets)
+	}
+	if cmd.Timeout != 5*time.Minute {
+		t.Errorf("Timeout = %v, want 5m", cmd.Timeout)
+	}
+
+	if _, err := p.ParseUpdateDataCommand([]string{"--timeout", "0s"}); err == nil {
+		t.Error("ParseUpdateDataCommand() expected error for a zero timeout")
+	}
+}
+
+func TestPrintDatasetStatus(t *testing.T) {
+	statuses := []dataset.Status{
+		{Name: "tor-exits", State: dataset.StateOK, Age: 3 * time.Hour, Entry: dataset.Entry{Size: 12 << 10}},
+		{Name: "aws-ranges", State: dataset.StateStale, Age: 9 * 24 * time.Hour, Entry: dataset.Entry{Size: 3 << 20}},
+		{Name: "bogons-v4", State: dataset.StateCorrupt, Err: errors.New("checksum mismatch"), Age: 30 * time.Minute, Entry: dataset.Entry{Size: 100}},
+		{Name: "geolite2-city", State: dataset.StateMissing},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDatasetStatus(&buf, "/data", statuses); err != nil {
+		t.Fatalf("PrintDatasetStatus() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"DATASETS (/data):",
+		"tor-exits      ok                           updated 3h ago   12.0 KB",
+		"aws-ranges     stale                        updated 9d ago   3.0 MB",
+		"bogons-v4      corrupt (checksum mismatch)  updated 30m ago  100 B",
+		"geolite2-city  missing\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
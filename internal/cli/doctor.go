@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DoctorStatus is the outcome of a diagnostic check.
+type DoctorStatus string
+
+const (
+	DoctorOK   DoctorStatus = "ok"
+	DoctorWarn DoctorStatus = "WARN"
+	DoctorFail DoctorStatus = "FAIL"
+	// DoctorNone marks optional items that are absent, such as datasets
+	// that were never downloaded.
+	DoctorNone DoctorStatus = "-"
+)
+
+// DoctorCheck is the outcome of one diagnostic, with a suggested fix for
+// anything other than DoctorOK.
+type DoctorCheck struct {
+	Name   string
+	Status DoctorStatus
+	Detail string
+	Fix    string
+}
+
+// DoctorSection groups related checks under a title.
+type DoctorSection struct {
+	Title  string
+	Checks []DoctorCheck
+}
+
+// PrintDoctor writes the checks of each section, their fixes and a count
+// of problems.
+func PrintDoctor(w io.Writer, sections []DoctorSection) error {
+	var sb strings.Builder
+
+	sb.WriteString("IPINTEL DOCTOR\n")
+	sb.WriteString(strings.Repeat("=", 50) + "\n")
+
+	failures, warnings := 0, 0
+	for _, section := range sections {
+		sb.WriteString("\n" + section.Title + ":\n")
+		sb.WriteString(strings.Repeat("-", 40) + "\n")
+
+		width := 0
+		for _, c := range section.Checks {
+			width = max(width, len(c.Name))
+		}
+
+		for _, c := range section.Checks {
+			sb.WriteString(strings.TrimRight(fmt.Sprintf("  %-4s  %-*s  %s", c.Status, width, c.Name, c.Detail), " ") + "\n")
+			if c.Fix != "" {
+				sb.WriteString(fmt.Sprintf("        %*s  fix: %s\n", width, "", c.Fix))
+			}
+
+			switch c.Status {
+			case DoctorFail:
+				failures++
+			case DoctorWarn:
+				warnings++
+			}
+		}
+	}
+
+	sb.WriteString("\n")
+	if failures == 0 && warnings == 0 {
+		sb.WriteString("No problems found.\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("%d problem(s), %d warning(s).\n", failures, warnings))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
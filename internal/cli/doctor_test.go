@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_ParseDoctorCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseDoctorCommand([]string{"--config", "c.json", "--data-dir", "/tmp/data"})
+	if err != nil {
+		t.Fatalf("ParseDoctorCommand() error = %v", err)
+	}
+	if cmd.ConfigPath != "c.json" || cmd.DataDir != "/tmp/data" {
+		t.Errorf("ParseDoctorCommand() = %+v", cmd)
+	}
+	if cmd.Timeout != DefaultTimeout {
+		t.Errorf("Timeout = %v, want %v", cmd.Timeout, DefaultTimeout)
+	}
+
+	for _, args := range [][]string{{"--timeout", "0s"}, {"8.8.8.8"}} {
+		if _, err := p.ParseDoctorCommand(args); err == nil {
+			t.Errorf("ParseDoctorCommand(%v) expected error", args)
+		}
+	}
+}
+
+func TestPrintDoctor(t *testing.T) {
+	sections := []DoctorSection{
+		{Title: "PROVIDERS", Checks: []DoctorCheck{
+			{Name: "ip-api", Status: DoctorOK, Detail: "answered in 120ms"},
+			{Name: "ipinfo", Status: DoctorFail, Detail: "unexpected status code: 401", Fix: "check provider.ipinfo.api_key"},
+		}},
+		{Title: "CLOCK", Checks: []DoctorCheck{
+			{Name: "skew", Status: DoctorWarn, Detail: (45 * time.Second).String() + " behind"},
+			{Name: "tz", Status: DoctorNone},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintDoctor(&buf, sections); err != nil {
+		t.Fatalf("PrintDoctor() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"PROVIDERS:",
+		"  ok    ip-api  answered in 120ms\n",
+		"  FAIL  ipinfo  unexpected status code: 401\n",
+		"                fix: check provider.ipinfo.api_key\n",
+		"  WARN  skew  45s behind\n",
+		"  -     tz\n",
+		"1 problem(s), 1 warning(s).",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
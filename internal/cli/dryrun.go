@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+// PrintDryRun writes the resolved configuration and the request each provider
+// would make for ip, without contacting any provider.
+func PrintDryRun(w io.Writer, cfg Config, ip model.IPAddress, providers []provider.Description) error {
+	var sb strings.Builder
+
+	sb.WriteString("DRY RUN (no requests will be sent)\n")
+	sb.WriteString(strings.Repeat("=", 50) + "\n\n")
+
+	sb.WriteString(fmt.Sprintf("  IP address:   %s\n", ip))
+	sb.WriteString(fmt.Sprintf("  Format:       %s\n", cfg.Format))
+	sb.WriteString(fmt.Sprintf("  Timeout:      %s\n\n", cfg.Timeout))
+
+	sb.WriteString(fmt.Sprintf("PROVIDERS (%d):\n", len(providers)))
+	sb.WriteString(strings.Repeat("-", 40) + "\n")
+
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, d := range providers {
+		url := d.URL
+		if d.Local {
+			url = "(local)"
+		} else if url == "" {
+			url = "(unknown)"
+		}
+		timeout := "none"
+		if d.Timeout > 0 {
+			timeout = d.Timeout.String()
+		}
+		key := d.APIKey
+		if key == "" {
+			key = "none"
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\ttimeout=%s\tkey=%s\n", d.Name, url, timeout, key)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
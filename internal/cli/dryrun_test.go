@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+func TestPrintDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{IPAddress: "8.8.8.8", Format: FormatJSON, Timeout: 5 * time.Second}
+	descriptions := []provider.Description{
+		{Name: "ipinfo", URL: "https://ipinfo.io/8.8.8.8/json", Timeout: 5 * time.Second, APIKey: "****abcd"},
+		{Name: "custom"},
+		{Name: "bogon", Local: true},
+	}
+
+	err := PrintDryRun(&buf, cfg, model.MustParseAddr("8.8.8.8"), descriptions)
+	if err != nil {
+		t.Fatalf("PrintDryRun() error = %v", err)
+	}
+
+	output := buf.String()
+
+	expectedStrings := []string{
+		"DRY RUN",
+		"8.8.8.8",
+		"Format:       json",
+		"Timeout:      5s",
+		"PROVIDERS (3):",
+		"https://ipinfo.io/8.8.8.8/json",
+		"timeout=5s",
+		"key=****abcd",
+		"(unknown)",
+		"key=none",
+		"(local)",
+	}
+
+	for _, expected := range expectedStrings {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output should contain %q\nGot:\n%s", expected, output)
+		}
+	}
+}
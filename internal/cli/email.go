@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-client/internal/countries"
+	"api-client/internal/model"
+	"api-client/internal/networks"
+)
+
+const (
+	// maxEmailAddresses bounds the addresses listed in a report email;
+	// the other tables count them all
+	maxEmailAddresses = 500
+
+	// maxEmailNetworks bounds the networks listed in a report email
+	maxEmailNetworks = 10
+)
+
+// EmailReport summarizes a batch run for --email-to: how many addresses
+// were looked up, and the countries, networks, policy decisions and
+// details of those matching --filter, all of them without one.
+type EmailReport struct {
+	title    string
+	start    time.Time
+	duration time.Duration
+
+	records, failed, matched int
+	countries                *countries.Tally
+	networks                 *networks.Tally
+	decisions                map[string]int
+	rows                     [][]string
+}
+
+// emailTable is a table of a report email, rendered alike in Markdown and
+// HTML.
+type emailTable struct {
+	Title  string
+	Header []string
+	Right  []bool // the columns aligned right
+	Rows   [][]string
+	Note   string
+}
+
+// NewEmailReport returns an empty report on the run named title, such as
+// its input file, started at start.
+func NewEmailReport(title string, start time.Time) *EmailReport {
+	return &EmailReport{
+		title:     title,
+		start:     start,
+		countries: countries.NewTally(),
+		networks:  networks.NewTally(),
+		decisions: make(map[string]int),
+	}
+}
+
+// Add counts report, and details it if matched by --filter.
+func (r *EmailReport) Add(report model.Report, matched bool) {
+	r.records++
+	if report.AllFailed() {
+		r.failed++
+	}
+	if !matched {
+		return
+	}
+
+	r.matched++
+	r.countries.Add(report)
+	r.networks.Add(report)
+	if report.Policy != nil {
+		r.decisions[report.Policy.Action]++
+	}
+	if len(r.rows) == maxEmailAddresses {
+		return
+	}
+
+	c := report.Consensus()
+	verdict := ""
+	if risk := report.Risk(); risk != nil {
+		verdict = string(risk.Verdict)
+	}
+	decision := ""
+	if report.Policy != nil {
+		decision = report.Policy.Action
+	}
+	org := c.Org
+	if org == "" {
+		org = c.ISP
+	}
+	r.rows = append(r.rows, []string{report.IP.String(), countryLabel(c.Country, c.CountryCode), c.City, c.ASN, org, verdict, decision})
+}
+
+// Finish records the duration of the run, once complete.
+func (r *EmailReport) Finish(duration time.Duration) {
+	r.duration = duration
+}
+
+// Subject returns the default subject of the email, naming the run and
+// the day it started.
+func (r *EmailReport) Subject() string {
+	return fmt.Sprintf("ipintel report: %s, %s", r.title, r.start.UTC().Format("2006-01-02"))
+}
+
+// facts returns the overview of the run, as labels and values.
+func (r *EmailReport) facts() [][2]string {
+	facts := [][2]string{
+		{"Run", fmt.Sprintf("%s, %s", r.start.UTC().Format("2006-01-02 15:04 MST"), r.duration.Round(time.Second))},
+		{"Addresses", fmt.Sprintf("%d looked up, %d failed on every provider", r.records, r.failed)},
+	}
+	if r.matched != r.records {
+		facts = append(facts, [2]string{"Listed", fmt.Sprintf("%d matching --filter", r.matched)})
+	}
+	return facts
+}
+
+// tables returns the tables of the report, leaving out those without rows.
+func (r *EmailReport) tables() []emailTable {
+	var tables []emailTable
+
+	byCountry := emailTable{Title: "Countries", Header: []string{"Country", "Addresses"}, Right: []bool{false, true}}
+	for _, c := range r.countries.Counts() {
+		byCountry.Rows = append(byCountry.Rows, []string{countryLabel(c.Name, c.Code), strconv.Itoa(c.Count)})
+	}
+	if n := r.countries.Unknown(); n > 0 {
+		byCountry.Rows = append(byCountry.Rows, []string{"Unknown", strconv.Itoa(n)})
+	}
+	tables = append(tables, byCountry)
+
+	byNetwork := emailTable{Title: "Networks", Header: []string{"Network", "ASN", "Holder", "Addresses"}, Right: []bool{false, false, false, true}}
+	nets := r.networks.Networks()
+	for i, n := range nets {
+		if i == maxEmailNetworks {
+			byNetwork.Note = fmt.Sprintf("The %d networks holding the most addresses, of %d.", maxEmailNetworks, len(nets))
+			break
+		}
+		byNetwork.Rows = append(byNetwork.Rows, []string{n.Prefix.String(), n.ASN, n.Holder, strconv.Itoa(n.Count)})
+	}
+	tables = append(tables, byNetwork)
+
+	byDecision := emailTable{Title: "Policy", Header: []string{"Decision", "Addresses"}, Right: []bool{false, true}}
+	for _, action := range []string{"block", "review", "allow"} {
+		if n := r.decisions[action]; n > 0 {
+			byDecision.Rows = append(byDecision.Rows, []string{action, strconv.Itoa(n)})
+		}
+	}
+	tables = append(tables, byDecision)
+
+	addresses := emailTable{
+		Title:  "Addresses",
+		Header: []string{"Address", "Country", "City", "ASN", "Organization", "Risk", "Policy"},
+		Right:  make([]bool, 7),
+		Rows:   r.rows,
+	}
+	if r.matched > len(r.rows) {
+		addresses.Note = fmt.Sprintf("The first %d addresses, of %d.", len(r.rows), r.matched)
+	}
+	tables = append(tables, addresses)
+
+	kept := tables[:0]
+	for _, t := range tables {
+		if len(t.Rows) > 0 {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// Markdown returns the report as Markdown, readable as plain text.
+func (r *EmailReport) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("# ipintel report: " + markdownCell(r.title) + "\n\n")
+	for _, f := range r.facts() {
+		sb.WriteString("- " + f[0] + ": " + f[1] + "\n")
+	}
+
+	for _, t := range r.tables() {
+		sb.WriteString("\n## " + t.Title + "\n\n")
+		row := func(cells []string) {
+			sb.WriteString("|")
+			for _, cell := range cells {
+				sb.WriteString(" " + markdownCell(cell) + " |")
+			}
+			sb.WriteString("\n")
+		}
+		row(t.Header)
+		sb.WriteString("|")
+		for _, right := range t.Right {
+			if right {
+				sb.WriteString(" ---: |")
+			} else {
+				sb.WriteString(" --- |")
+			}
+		}
+		sb.WriteString("\n")
+		for _, cells := range t.Rows {
+			row(cells)
+		}
+		if t.Note != "" {
+			sb.WriteString("\n" + t.Note + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// countryLabel returns the name of a country followed by its code, as in
+// "Germany (DE)", or whichever of them is known.
+func countryLabel(name, code string) string {
+	if name == "" || code == "" {
+		return name + code
+	}
+	return name + " (" + code + ")"
+}
+
+// markdownCell escapes s for a table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+var emailHTML = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ipintel report: {{.Title}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f2f2f2; }
+td.right { text-align: right; }
+</style>
+</head>
+<body>
+<h1>ipintel report: {{.Title}}</h1>
+<ul>
+{{- range .Facts}}
+<li>{{index . 0}}: {{index . 1}}</li>
+{{- end}}
+</ul>
+{{- range .Tables}}
+{{- $right := .Right}}
+<h2>{{.Title}}</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range $i, $cell := .}}<td{{if index $right $i}} class="right"{{end}}>{{$cell}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- with .Note}}
+<p>{{.}}</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML returns the report as an HTML document.
+func (r *EmailReport) HTML() (string, error) {
+	var buf bytes.Buffer
+	err := emailHTML.Execute(&buf, struct {
+		Title  string
+		Facts  [][2]string
+		Tables []emailTable
+	}{r.title, r.facts(), r.tables()})
+	return buf.String(), err
+}
+
+// EmailHeader holds the header fields of a report email.
+type EmailHeader struct {
+	From    string
+	To      []string
+	Subject string
+	Date    time.Time
+}
+
+// WriteReportEmail writes report as a MIME message with header, its
+// Markdown and HTML forms as alternatives, ready to be sent.
+func WriteReportEmail(w io.Writer, header EmailHeader, report *EmailReport) error {
+	html, err := report.HTML()
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+
+	field := func(name, value string) {
+		if value != "" {
+			msg.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	field("From", header.From)
+	field("To", strings.Join(header.To, ", "))
+	field("Subject", mime.QEncoding.Encode("utf-8", header.Subject))
+	field("Date", header.Date.Format(time.RFC1123Z))
+	field("MIME-Version", "1.0")
+	field("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	msg.WriteString("\r\n")
+
+	// Clients show the last alternative they support
+	for _, alt := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", report.Markdown()},
+		{"text/html; charset=utf-8", html},
+	} {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qw := quotedprintable.NewWriter(part)
+		if _, err := qw.Write(crlf([]byte(alt.body))); err != nil {
+			return err
+		}
+		if err := qw.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(msg.Bytes())
+	return err
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func makeEmailReport() *EmailReport {
+	r := NewEmailReport("logins.txt", time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	for _, place := range []struct {
+		ip, country, code, org string
+		matched                bool
+	}{
+		{"192.0.2.1", "Germany", "DE", "Example <GmbH>", true},
+		{"192.0.2.2", "Germany", "DE", "Example | Networks", true},
+		{"198.51.100.1", "United States", "US", "Other", false},
+	} {
+		ip := model.MustParseAddr(place.ip)
+		report := model.Report{
+			IP: ip,
+			Results: []model.ProviderResult{{Provider: "ipinfo", Result: &model.Geolocation{
+				IP: ip, Country: place.country, CountryCode: place.code, City: "Berlin", ASN: "AS64500", Org: place.org,
+			}}},
+			Policy: &model.PolicyDecision{Action: "review"},
+		}
+		r.Add(report, place.matched)
+	}
+	r.Add(model.Report{IP: model.MustParseAddr("203.0.113.1"), Results: []model.ProviderResult{{Provider: "ipinfo", Error: "timeout"}}}, false)
+	r.Finish(90 * time.Second)
+	return r
+}
+
+func TestEmailReport_Markdown(t *testing.T) {
+	md := makeEmailReport().Markdown()
+
+	for _, want := range []string{
+		"# ipintel report: logins.txt\n",
+		"- Run: 2024-01-15 10:00 UTC, 1m30s\n",
+		"- Addresses: 4 looked up, 1 failed on every provider\n",
+		"- Listed: 2 matching --filter\n",
+		"| Country | Addresses |\n| --- | ---: |\n| Germany (DE) | 2 |\n",
+		"| review | 2 |\n",
+		"| 192.0.2.2 | Germany (DE) | Berlin | AS64500 | Example \\| Networks |  | review |\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "198.51.100.1") || strings.Contains(md, "## Networks") {
+		t.Errorf("Markdown() lists an address not matched, or a table without rows:\n%s", md)
+	}
+}
+
+func TestEmailReport_HTML(t *testing.T) {
+	html, err := makeEmailReport().HTML()
+	if err != nil {
+		t.Fatalf("HTML() error = %v", err)
+	}
+	for _, want := range []string{
+		"<h1>ipintel report: logins.txt</h1>",
+		"<td>Example &lt;GmbH&gt;</td>",
+		`<td>Germany (DE)</td><td class="right">2</td>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML() missing %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestWriteReportEmail(t *testing.T) {
+	report := makeEmailReport()
+	var buf bytes.Buffer
+	err := WriteReportEmail(&buf, EmailHeader{
+		From:    "ipintel@example.com",
+		To:      []string{"soc@example.com", "it@example.com"},
+		Subject: report.Subject(),
+		Date:    time.Date(2024, 1, 15, 10, 2, 0, 0, time.UTC),
+	}, report)
+	if err != nil {
+		t.Fatalf("WriteReportEmail() error = %v", err)
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("parsing the message: %v", err)
+	}
+	if got := msg.Header.Get("To"); got != "soc@example.com, it@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	if got := msg.Header.Get("Subject"); got != "ipintel report: logins.txt, 2024-01-15" {
+		t.Errorf("Subject = %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(body, []byte("192.0.2.1")) {
+			t.Errorf("part %s = %q, want the addresses", types[len(types)-1], body)
+		}
+	}
+	if want := []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}; strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("parts = %q, want %q", types, want)
+	}
+}
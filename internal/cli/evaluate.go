@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"api-client/internal/evaluate"
+)
+
+// scorecardJSON is the JSON form of a scorecard.
+type scorecardJSON struct {
+	*evaluate.Scorecard
+	Providers []evaluate.ProviderScore `json:"providers"`
+}
+
+// PrintScorecard writes the score of each provider against the reference,
+// from the highest suggested weight to the lowest.
+func PrintScorecard(w io.Writer, s *evaluate.Scorecard, format OutputFormat) error {
+	scores := s.Scores()
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Weight > scores[j].Weight })
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(scorecardJSON{Scorecard: s, Providers: scores})
+	case FormatText:
+		return printScorecardText(w, s, scores)
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func printScorecardText(w io.Writer, s *evaluate.Scorecard, scores []evaluate.ProviderScore) error {
+	var sb strings.Builder
+
+	sb.WriteString("PROVIDER SCORECARD\n")
+	sb.WriteString(strings.Repeat("=", 50) + "\n\n")
+	sb.WriteString(fmt.Sprintf("Reference: %s (answered %d of %d lookups)\n\n",
+		s.Reference, s.Lookups-s.ReferenceFailed, s.Lookups))
+
+	if len(scores) == 0 {
+		sb.WriteString("No other provider to score.\n")
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "  PROVIDER\tCOVERAGE\tCOUNTRY\tREGION\tCITY\tASN\tMEDIAN\tP90\tWEIGHT")
+	for _, score := range scores {
+		median, p90 := "-", "-"
+		if score.Distance.Compared > 0 {
+			median = fmt.Sprintf("%.0f km", score.Distance.MedianKm)
+			p90 = fmt.Sprintf("%.0f km", score.Distance.P90Km)
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.2f\n",
+			score.Provider, percent(score.Answered, score.Lookups),
+			percent(score.Country.Agreed, score.Country.Compared),
+			percent(score.Region.Agreed, score.Region.Compared),
+			percent(score.City.Agreed, score.City.Compared),
+			percent(score.ASN.Agreed, score.ASN.Compared),
+			median, p90, score.Weight)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	sb.WriteString("\nCoverage is the share of the reference's answers the provider answered\n")
+	sb.WriteString("too; agreement rates count the lookups where both reported the field,\n")
+	sb.WriteString("and distances are between their coordinates.\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// percent formats n out of total as a percentage, or "-" when total is 0.
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
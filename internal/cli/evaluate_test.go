@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"api-client/internal/evaluate"
+	"api-client/internal/model"
+)
+
+func TestParser_ParseEvaluateCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseEvaluateCommand([]string{"--reference", "ipinfo", "-i", "ips.txt", "-f", "json"})
+	if err != nil {
+		t.Fatalf("ParseEvaluateCommand() error = %v", err)
+	}
+	if cmd.Reference != "ipinfo" || cmd.InputFile != "ips.txt" || cmd.Format != FormatJSON || cmd.Concurrency != 4 {
+		t.Errorf("ParseEvaluateCommand() = %+v", cmd)
+	}
+
+	for _, args := range [][]string{{"-i", "ips.txt"}, {"--reference", "ipinfo"}, {"--reference", "ipinfo", "-f", "csv", "8.8.8.8"}, {"--reference", "ipinfo", "--concurrency", "0", "8.8.8.8"}} {
+		if _, err := p.ParseEvaluateCommand(args); err == nil {
+			t.Errorf("ParseEvaluateCommand(%q) should fail", args)
+		}
+	}
+}
+
+func testScorecard() *evaluate.Scorecard {
+	ref := &model.Geolocation{CountryCode: "US", City: "Mountain View", Latitude: model.Float64(37.4), Longitude: model.Float64(-122.1)}
+	other := &model.Geolocation{CountryCode: "US", City: "Palo Alto", Latitude: model.Float64(37.4), Longitude: model.Float64(-122.1)}
+
+	s := evaluate.New("ref")
+	s.Add(model.Report{Results: []model.ProviderResult{
+		{Provider: "ref", Result: ref},
+		{Provider: "close", Result: other},
+		{Provider: "same", Result: ref},
+		{Provider: "down", Error: "timeout"},
+	}})
+	return s
+}
+
+func TestPrintScorecard_Text(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintScorecard(&buf, testScorecard(), FormatText); err != nil {
+		t.Fatalf("PrintScorecard() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "Reference: ref (answered 1 of 1 lookups)") {
+		t.Errorf("output should describe the reference:\n%s", output)
+	}
+	same, close, down := strings.Index(output, "  same "), strings.Index(output, "  close "), strings.Index(output, "  down ")
+	if same < 0 || close < same || down < close {
+		t.Errorf("providers should be listed from the highest weight:\n%s", output)
+	}
+	if !strings.Contains(output, "100.0%    100.0%") || !strings.Contains(output, "0 km") {
+		t.Errorf("output should show rates and distances:\n%s", output)
+	}
+}
+
+func TestPrintScorecard_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintScorecard(&buf, testScorecard(), FormatJSON); err != nil {
+		t.Fatalf("PrintScorecard() error = %v", err)
+	}
+
+	var got struct {
+		Reference string `json:"reference"`
+		Lookups   int    `json:"lookups"`
+		Providers []struct {
+			Provider string  `json:"provider"`
+			Weight   float64 `json:"weight"`
+			City     struct {
+				Rate float64 `json:"rate"`
+			} `json:"city"`
+		} `json:"providers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if got.Reference != "ref" || got.Lookups != 1 || len(got.Providers) != 3 {
+		t.Fatalf("scorecard = %+v", got)
+	}
+	if p := got.Providers[1]; p.Provider != "close" || p.City.Rate != 0 || p.Weight != 0.5 {
+		t.Errorf("providers[1] = %+v, want close with a weight of 0.5", p)
+	}
+}
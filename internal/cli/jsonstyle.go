@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JSONStyle selects the naming convention of JSON object keys.
+type JSONStyle string
+
+const (
+	JSONStyleSnake JSONStyle = "snake"
+	JSONStyleCamel JSONStyle = "camel"
+)
+
+// ParseJSONStyle converts a style name into a JSONStyle.
+func ParseJSONStyle(style string) (JSONStyle, error) {
+	switch style {
+	case "snake", "":
+		return JSONStyleSnake, nil
+	case "camel":
+		return JSONStyleCamel, nil
+	default:
+		return "", fmt.Errorf("invalid JSON style %q: must be 'snake' or 'camel'", style)
+	}
+}
+
+// Timing selects the detail of the timing information in JSON output.
+type Timing string
+
+const (
+	// TimingSimple reports durations in milliseconds
+	TimingSimple Timing = "simple"
+	// TimingDetailed also reports start times, ISO 8601 durations and
+	// durations in nanoseconds
+	TimingDetailed Timing = "detailed"
+)
+
+// ParseTiming converts a timing name into a Timing.
+func ParseTiming(timing string) (Timing, error) {
+	switch timing {
+	case "simple", "":
+		return TimingSimple, nil
+	case "detailed":
+		return TimingDetailed, nil
+	default:
+		return "", fmt.Errorf("invalid timing %q: must be 'simple' or 'detailed'", timing)
+	}
+}
+
+// snakeToCamel converts a snake_case key such as "total_duration_ms" into
+// camelCase, "totalDurationMs".
+func snakeToCamel(key string) string {
+	parts := strings.Split(key, "_")
+
+	var sb strings.Builder
+	sb.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(part)
+		sb.WriteRune(unicode.ToUpper(r))
+		sb.WriteString(part[size:])
+	}
+	return sb.String()
+}
+
+// verbatimKey is the top-level key whose value is copied without renaming:
+// the input fields passed through a batch run belong to the user.
+const verbatimKey = "input"
+
+// renameKeys re-encodes the JSON document in data with every object key
+// passed through rename, except within the top-level verbatimKey. Key order
+// and values are preserved.
+func renameKeys(data []byte, rename func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := renameValue(dec, &buf, rename, true); err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func renameValue(dec *json.Decoder, buf *bytes.Buffer, rename func(string) string, top bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		buf.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, err := json.Marshal(rename(keyTok.(string)))
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+
+			if top && keyTok == verbatimKey {
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return err
+				}
+				buf.Write(raw)
+				continue
+			}
+
+			if err := renameValue(dec, buf, rename, false); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+	case json.Delim('['):
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := renameValue(dec, buf, rename, false); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		buf.WriteByte(']')
+	default:
+		value, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(value)
+	}
+
+	return nil
+}
+
+// sortKeys re-encodes the JSON document in data with the keys of every
+// object, including passthrough input fields, in lexical order. Values,
+// including the precision of numbers, are preserved.
+func sortKeys(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+
+	// Maps are marshalled in key order
+	return json.Marshal(v)
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnakeToCamel(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"ip", "ip"},
+		{"country_code", "countryCode"},
+		{"total_duration_ms", "totalDurationMs"},
+		{"trailing_", "trailing"},
+		{"double__underscore", "doubleUnderscore"},
+	}
+
+	for _, tt := range tests {
+		if got := snakeToCamel(tt.key); got != tt.want {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRenameKeys(t *testing.T) {
+	input := `{"b_key":1,"a_key":{"nested_key":[{"deep_key":"value_with_underscore"}],"n":1.50},"z":null}`
+
+	got, err := renameKeys([]byte(input), snakeToCamel)
+	if err != nil {
+		t.Fatalf("renameKeys() error = %v", err)
+	}
+
+	want := `{"bKey":1,"aKey":{"nestedKey":[{"deepKey":"value_with_underscore"}],"n":1.50},"z":null}`
+	if string(got) != want {
+		t.Errorf("renameKeys() = %s, want %s", got, want)
+	}
+}
+
+func TestRenameKeys_KeepsInputVerbatim(t *testing.T) {
+	input := `{"total_ms":1,"input":{"user_id":7,"raw_tags":{"a_b":1}},"meta":{"input":{"x_y":1}}}`
+
+	got, err := renameKeys([]byte(input), snakeToCamel)
+	if err != nil {
+		t.Fatalf("renameKeys() error = %v", err)
+	}
+
+	want := `{"totalMs":1,"input":{"user_id":7,"raw_tags":{"a_b":1}},"meta":{"input":{"xY":1}}}`
+	if string(got) != want {
+		t.Errorf("renameKeys() = %s, want %s", got, want)
+	}
+}
+
+func TestRenameKeys_Invalid(t *testing.T) {
+	if _, err := renameKeys([]byte(`{"a":`), snakeToCamel); err == nil {
+		t.Error("renameKeys() expected error for truncated JSON")
+	}
+}
+
+func TestParseJSONStyle(t *testing.T) {
+	if style, err := ParseJSONStyle("camel"); err != nil || style != JSONStyleCamel {
+		t.Errorf("ParseJSONStyle(camel) = %v, %v", style, err)
+	}
+
+	if style, err := ParseJSONStyle(""); err != nil || style != JSONStyleSnake {
+		t.Errorf("ParseJSONStyle(\"\") = %v, %v", style, err)
+	}
+
+	if _, err := ParseJSONStyle("kebab"); err == nil {
+		t.Error("ParseJSONStyle(kebab) expected error")
+	}
+}
+
+func TestParseTiming(t *testing.T) {
+	if timing, err := ParseTiming("detailed"); err != nil || timing != TimingDetailed {
+		t.Errorf("ParseTiming(detailed) = %v, %v", timing, err)
+	}
+
+	if timing, err := ParseTiming(""); err != nil || timing != TimingSimple {
+		t.Errorf("ParseTiming(\"\") = %v, %v", timing, err)
+	}
+
+	if _, err := ParseTiming("verbose"); err == nil {
+		t.Error("ParseTiming(verbose) expected error")
+	}
+}
+
+func TestFormatter_FormatJSON_DetailedTiming(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0].Start = report.Timestamp.Add(5 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithTiming(TimingDetailed), WithJSONStyle(JSONStyleCamel)).Format(report, FormatJSON); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	for _, want := range []string{`"duration": "PT0.18S"`, `"durationNs": 100000000`, `"startOffsetNs": 5000000`, `"start": "2024-01-15T10:30:00.005Z"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %s:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := NewFormatter(&buf).Format(report, FormatJSON); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(buf.String(), `"timing"`) {
+		t.Errorf("simple timing output has detailed timings:\n%s", buf.String())
+	}
+}
+
+func TestFormatter_FormatJSON_CamelStyle(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf, WithJSONStyle(JSONStyleCamel))
+
+	if err := f.Format(makeTestReport(), FormatJSON); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+
+	if parsed["totalDurationMs"] != float64(180) {
+		t.Errorf("totalDurationMs = %v, want 180", parsed["totalDurationMs"])
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, `"countryCode": "US"`) {
+		t.Errorf("nested keys should be camelCase, got: %s", output)
+	}
+
+	if strings.Contains(output, "country_code") || strings.Contains(output, "duration_ms") {
+		t.Errorf("output should not contain snake_case keys, got: %s", output)
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	input := `{"b":1,"a":{"z":[{"y":2,"x":"v"}],"n":1.50},"input":{"user":7,"id":1}}`
+
+	got, err := sortKeys([]byte(input))
+	if err != nil {
+		t.Fatalf("sortKeys() error = %v", err)
+	}
+
+	want := `{"a":{"n":1.50,"z":[{"x":"v","y":2}]},"b":1,"input":{"id":1,"user":7}}`
+	if string(got) != want {
+		t.Errorf("sortKeys() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"api-client/internal/model"
+)
+
+const (
+	kmlHeader = xml.Header + `<kml xmlns="http://www.opengis.net/kml/2.2">
+<Document>
+<name>ipintel</name>
+`
+	kmlFooter = "</Document>\n</kml>\n"
+)
+
+// kmlPlacemark is a KML placemark, pinned at the consensus coordinates of a
+// report. Reports without coordinates have no point: they are listed in
+// the document but not shown on the globe.
+type kmlPlacemark struct {
+	XMLName     xml.Name  `xml:"Placemark"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"description"`
+	Point       *kmlPoint `xml:"Point"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// formatKML writes reports as a KML document with one placemark each.
+func (f *Formatter) formatKML(reports []model.Report) error {
+	if _, err := io.WriteString(f.w, kmlHeader); err != nil {
+		return err
+	}
+	for _, report := range reports {
+		if err := f.writePlacemark(report); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(f.w, kmlFooter)
+	return err
+}
+
+// writePlacemark writes the placemark of report, named after its address
+// and described by an HTML table of the answer of each provider.
+func (f *Formatter) writePlacemark(report model.Report) error {
+	pm := kmlPlacemark{Name: report.IP.String(), Description: kmlDescription(report)}
+	if lat, lon, ok := report.Consensus().Coordinates(); ok {
+		// KML puts the longitude first
+		pm.Point = &kmlPoint{Coordinates: strconv.FormatFloat(lon, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64)}
+	}
+
+	data, err := xml.MarshalIndent(pm, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = f.w.Write(append(data, '\n'))
+	return err
+}
+
+// kmlDescription renders the consensus and the provider results of report
+// as the HTML Google Earth shows in the balloon of its placemark.
+func kmlDescription(report model.Report) string {
+	var sb strings.Builder
+
+	row := func(cells ...string) {
+		sb.WriteString("<tr>")
+		for _, cell := range cells {
+			sb.WriteString("<td>" + html.EscapeString(cell) + "</td>")
+		}
+		sb.WriteString("</tr>\n")
+	}
+
+	if !report.AllFailed() {
+		consensus := report.Consensus()
+		sb.WriteString("<p><b>" + html.EscapeString(kmlLocation(consensus)) + "</b>")
+		if network := kmlNetwork(consensus); network != "" {
+			sb.WriteString("<br>" + html.EscapeString(network))
+		}
+		if risk := report.Risk(); risk != nil {
+			sb.WriteString("<br>Risk: " + html.EscapeString(formatRisk(*risk)))
+		}
+		sb.WriteString("</p>\n")
+	}
+
+	sb.WriteString("<table>\n<tr><th>Provider</th><th>Location</th><th>Coordinates</th><th>Network</th></tr>\n")
+	for _, pr := range report.Results {
+		switch {
+		case pr.Skipped:
+			continue
+		case pr.Result == nil:
+			row(pr.Provider, "error: "+pr.Error, "", "")
+		default:
+			coords := ""
+			if lat, lon, ok := pr.Result.Coordinates(); ok {
+				coords = fmt.Sprintf("%.4f, %.4f", lat, lon)
+			}
+			row(pr.Provider, kmlLocation(*pr.Result), coords, kmlNetwork(*pr.Result))
+		}
+	}
+	sb.WriteString("</table>")
+
+	return sb.String()
+}
+
+// kmlLocation describes where geo is, e.g. "Mountain View, California,
+// United States (US)".
+func kmlLocation(geo model.Geolocation) string {
+	country := geo.Country
+	if geo.CountryCode != "" {
+		country = strings.TrimSpace(country + " (" + geo.CountryCode + ")")
+	}
+	return joinNonEmpty(geo.City, geo.Region, country)
+}
+
+// kmlNetwork describes the network of geo, e.g. "AS15169, Google LLC".
+func kmlNetwork(geo model.Geolocation) string {
+	org := geo.ISP
+	if org == "" {
+		org = geo.Org
+	}
+	return joinNonEmpty(geo.ASN, org)
+}
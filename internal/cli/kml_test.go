@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestFormatter_FormatKML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(makeTestReport(), FormatKML); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	out := buf.String()
+
+	var doc struct {
+		Placemarks []struct {
+			Name        string `xml:"name"`
+			Description string `xml:"description"`
+			Coordinates string `xml:"Point>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid KML: %v\n%s", err, out)
+	}
+	if len(doc.Placemarks) != 1 {
+		t.Fatalf("got %d placemarks, want 1", len(doc.Placemarks))
+	}
+
+	pm := doc.Placemarks[0]
+	if pm.Name != "8.8.8.8" {
+		t.Errorf("name = %q, want 8.8.8.8", pm.Name)
+	}
+	if pm.Coordinates != "-122.092,37.393" {
+		t.Errorf("coordinates = %q, want the consensus longitude first", pm.Coordinates)
+	}
+	for _, want := range []string{
+		"<b>Mountain View, California, United States (US)</b>",
+		"<tr><td>provider1</td><td>Mountain View, California, United States (US)</td><td>37.3860, -122.0840</td><td>AS15169, Google LLC</td></tr>",
+	} {
+		if !strings.Contains(pm.Description, want) {
+			t.Errorf("description missing %q:\n%s", want, pm.Description)
+		}
+	}
+}
+
+func TestFormatter_FormatBatch_KML(t *testing.T) {
+	failed := makeTestReportWithError()
+	failed.Results = failed.Results[1:]
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).FormatBatch([]model.Report{makeTestReport(), failed}, FormatKML); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	out := buf.String()
+
+	if strings.Count(out, "<Placemark>") != 2 || strings.Count(out, "<Point>") != 1 {
+		t.Errorf("want two placemarks, only the first placed:\n%s", out)
+	}
+	if !strings.Contains(out, "&lt;td&gt;error: connection timeout&lt;/td&gt;") {
+		t.Errorf("output missing the provider error:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "</Document>\n</kml>\n") {
+		t.Errorf("output does not end the document:\n%s", out)
+	}
+
+	// An empty run is still a valid document
+	buf.Reset()
+	if err := NewFormatter(&buf).FormatBatch(nil, FormatKML); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Errorf("invalid KML: %v\n%s", err, buf.String())
+	}
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"api-client/internal/networks"
+)
+
+// networkSummaryJSON is the JSON form of a network tally.
+type networkSummaryJSON struct {
+	Total    int                `json:"total"`
+	Unknown  int                `json:"unknown"`
+	Networks []networks.Network `json:"networks"`
+}
+
+// NetworkSummaryFormat returns the format of the --network-summary file
+// path from its extension: CSV for .csv and JSON for .json.
+func NetworkSummaryFormat(path string) (OutputFormat, error) {
+	return summaryFormat("--network-summary", path)
+}
+
+// PrintNetworkSummary writes the number of addresses per network, the
+// largest first. CSV has one row per network, its addresses separated by
+// spaces; JSON adds the total and the addresses without a network.
+func PrintNetworkSummary(w io.Writer, t *networks.Tally, format OutputFormat) error {
+	nets := t.Networks()
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(networkSummaryJSON{Total: t.Total(), Unknown: t.Unknown(), Networks: nets})
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"prefix", "asn", "holder", "count", "addresses"})
+		for _, n := range nets {
+			_ = cw.Write([]string{n.Prefix.String(), n.ASN, n.Holder, strconv.Itoa(n.Count), strings.Join(n.Addresses, " ")})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"api-client/internal/model"
+	"api-client/internal/networks"
+)
+
+func testNetworkTally() *networks.Tally {
+	tally := networks.NewTally()
+	for _, ip := range []string{"8.8.8.8", "1.1.1.1", "8.8.4.4", "192.0.2.1"} {
+		geo := &model.Geolocation{}
+		switch ip {
+		case "8.8.8.8", "8.8.4.4":
+			prefix := model.MustParsePrefix("8.8.0.0/16")
+			geo = &model.Geolocation{Prefix: &prefix, ASN: "AS15169", ISP: "Google LLC"}
+		case "1.1.1.1":
+			prefix := model.MustParsePrefix("1.1.1.0/24")
+			geo = &model.Geolocation{Prefix: &prefix, ASN: "AS13335", Org: "Cloudflare, Inc."}
+		}
+		tally.Add(model.Report{IP: model.MustParseAddr(ip), Results: []model.ProviderResult{
+			{Provider: "ripestat", Result: geo},
+		}})
+	}
+	return tally
+}
+
+func TestPrintNetworkSummary_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintNetworkSummary(&buf, testNetworkTally(), FormatCSV); err != nil {
+		t.Fatalf("PrintNetworkSummary() error = %v", err)
+	}
+
+	want := "prefix,asn,holder,count,addresses\n" +
+		"8.8.0.0/16,AS15169,Google LLC,2,8.8.8.8 8.8.4.4\n" +
+		"1.1.1.0/24,AS13335,\"Cloudflare, Inc.\",1,1.1.1.1\n"
+	if buf.String() != want {
+		t.Errorf("PrintNetworkSummary() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrintNetworkSummary_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PrintNetworkSummary(&buf, testNetworkTally(), FormatJSON); err != nil {
+		t.Fatalf("PrintNetworkSummary() error = %v", err)
+	}
+
+	for _, want := range []string{`"total": 4`, `"unknown": 1`, `"prefix": "8.8.0.0/16"`, `"count": 2`, `"8.8.4.4"`} {
+		if !bytes.Contains(buf.Bytes(), []byte(want)) {
+			t.Errorf("output missing %s:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestNetworkSummaryFormat(t *testing.T) {
+	if got, err := NetworkSummaryFormat("networks.csv"); err != nil || got != FormatCSV {
+		t.Errorf("NetworkSummaryFormat() = %q, %v, want csv", got, err)
+	}
+	if _, err := NetworkSummaryFormat("networks.txt"); err == nil {
+		t.Error("NetworkSummaryFormat() of an unknown extension expected error")
+	}
+}
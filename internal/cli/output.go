@@ -1,148 +1,766 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
 
 	"api-client/internal/model"
+	"api-client/internal/provider/country"
+	"api-client/internal/query"
+	"api-client/internal/sign"
 )
 
 // Formatter formats and outputs reports.
 type Formatter struct {
-	w io.Writer
+	w       io.Writer
+	regions display.Namer
+	wide    bool
+	style   JSONStyle
+	sorted  bool
+	array   bool
+	query   *query.Query
+	timing  Timing
+	verbose bool
+	showMap bool
+	groupBy GroupBy
+	noEmoji bool
+	signer  *sign.Signer
+}
+
+// compactWidth is the maximum line width of compact text output.
+const compactWidth = 80
+
+// Typical sizes of a text report, without and per provider result.
+const (
+	textReportSize = 512
+	textResultSize = 256
+)
+
+// Rules under the title (50 wide) and the section headings (40 wide) of
+// text output.
+const (
+	titleRule   = "=================================================="
+	sectionRule = "----------------------------------------"
+)
+
+// FormatterOption configures a Formatter.
+type FormatterOption func(*Formatter)
+
+// WithLanguage renders country names in the given language, derived from
+// the country code reported by providers.
+func WithLanguage(tag language.Tag) FormatterOption {
+	return func(f *Formatter) {
+		f.regions = display.Regions(tag)
+	}
+}
+
+// WithWide disables truncation of long values in text output. By default,
+// lines are shortened with an ellipsis to fit within 80 columns.
+func WithWide(wide bool) FormatterOption {
+	return func(f *Formatter) {
+		f.wide = wide
+	}
+}
+
+// WithNoEmoji leaves out the flag emoji shown before country names in text
+// output, for terminals that cannot render them, such as those of Windows.
+func WithNoEmoji(noEmoji bool) FormatterOption {
+	return func(f *Formatter) {
+		f.noEmoji = noEmoji
+	}
+}
+
+// WithVerbose adds a timeline of the provider queries to text output,
+// showing how they overlapped and where the lookup time went.
+func WithVerbose(verbose bool) FormatterOption {
+	return func(f *Formatter) {
+		f.verbose = verbose
+	}
+}
+
+// WithMap adds a world map to text output, with a marker at the consensus
+// coordinates.
+func WithMap(showMap bool) FormatterOption {
+	return func(f *Formatter) {
+		f.showMap = showMap
+	}
+}
+
+// WithGroupBy groups the summary of batch text output by ASN, country or
+// ISP.
+func WithGroupBy(groupBy GroupBy) FormatterOption {
+	return func(f *Formatter) {
+		f.groupBy = groupBy
+	}
+}
+
+// WithJSONStyle sets the naming convention of keys in JSON output.
+func WithJSONStyle(style JSONStyle) FormatterOption {
+	return func(f *Formatter) {
+		f.style = style
+	}
+}
+
+// WithSortedKeys makes JSON output deterministic, for reports kept in
+// version control or compared across runs: object keys are sorted, and so
+// are provider results and the provider list of the metadata, by name.
+func WithSortedKeys(sorted bool) FormatterOption {
+	return func(f *Formatter) {
+		f.sorted = sorted
+	}
+}
+
+// WithJSONArray writes the JSON reports of a batch run as the elements of a
+// single JSON array, streamed one compact report per line as they complete,
+// rather than as newline-delimited JSON; a single report is written as an
+// array of one.
+func WithJSONArray(array bool) FormatterOption {
+	return func(f *Formatter) {
+		f.array = array
+	}
+}
+
+// WithTiming sets the detail of the timing information in JSON output.
+func WithTiming(timing Timing) FormatterOption {
+	return func(f *Formatter) {
+		f.timing = timing
+	}
+}
+
+// WithQuery replaces the output with the result of evaluating q, a
+// JMESPath expression, against the JSON report, whatever the output format.
+// Strings are written bare, one per line, and other values as JSON.
+func WithQuery(q *query.Query) FormatterOption {
+	return func(f *Formatter) {
+		f.query = q
+	}
+}
+
+// WithSigner embeds a signature, made by s, into each JSON report, so that
+// it can be verified as untampered.
+func WithSigner(s *sign.Signer) FormatterOption {
+	return func(f *Formatter) {
+		f.signer = s
+	}
 }
 
 // NewFormatter creates a new output formatter.
-func NewFormatter(w io.Writer) *Formatter {
-	return &Formatter{w: w}
+func NewFormatter(w io.Writer, opts ...FormatterOption) *Formatter {
+	f := &Formatter{w: w}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 // Format outputs the report in the specified format.
 func (f *Formatter) Format(report model.Report, format OutputFormat) error {
+	if f.query != nil {
+		return f.writeQuery(report, true)
+	}
+
 	switch format {
 	case FormatJSON:
+		if f.array {
+			return f.FormatBatch([]model.Report{report}, format)
+		}
 		return f.formatJSON(report)
 	case FormatText:
 		return f.formatText(report)
+	case FormatCSV:
+		return f.formatCSV([]model.Report{report})
+	case FormatKML:
+		return f.formatKML([]model.Report{report})
+	case FormatParquet:
+		return f.FormatBatch([]model.Report{report}, format)
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
+// FormatBatch outputs the reports of a batch run. JSON output is written as
+// newline-delimited JSON (one compact report per line), or as a JSON array
+// with WithJSONArray; CSV output as one row per report under a single
+// header, KML output as one placemark per report and Parquet output as one
+// row per report; text output renders a numbered section per report
+// followed by a summary across all addresses.
+func (f *Formatter) FormatBatch(reports []model.Report, format OutputFormat) error {
+	w, err := f.NewBatchWriter(format, len(reports), InputColumns(reports))
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		if err := w.Write(report); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
 func (f *Formatter) formatJSON(report model.Report) error {
-	enc := json.NewEncoder(f.w)
-	enc.SetIndent("", "  ")
-	return enc.Encode(report)
+	return f.writeJSON(f.jsonReport(report), true)
 }
 
-func (f *Formatter) formatText(report model.Report) error {
-	var sb strings.Builder
+// jsonReport returns report as it should be serialized: with detailed
+// timings if requested, and with its provider results, and those of its
+// metadata, in name order when keys are sorted.
+func (f *Formatter) jsonReport(report model.Report) model.Report {
+	if f.timing == TimingDetailed {
+		report = report.DetailedTiming()
+	}
+	if !f.sorted {
+		return report
+	}
 
-	// Header
-	sb.WriteString(fmt.Sprintf("IP Intelligence Report for %s\n", report.IP))
-	sb.WriteString(strings.Repeat("=", 50) + "\n\n")
+	report.Results = append([]model.ProviderResult(nil), report.Results...)
+	sort.SliceStable(report.Results, func(i, j int) bool {
+		return report.Results[i].Provider < report.Results[j].Provider
+	})
 
-	// Consensus results
-	consensus := report.Consensus()
-	sb.WriteString("CONSENSUS (aggregated from all providers):\n")
-	sb.WriteString(strings.Repeat("-", 40) + "\n")
+	if report.Meta != nil {
+		meta := *report.Meta
+		meta.Providers = append([]string(nil), meta.Providers...)
+		sort.Strings(meta.Providers)
+		report.Meta = &meta
+	}
+	return report
+}
+
+// writeJSON writes v as a single line of JSON, or indented when indent is set,
+// applying the configured key style and ordering, and signed if configured.
+func (f *Formatter) writeJSON(v any, indent bool) error {
+	data, err := f.signedJSON(v)
+	if err != nil {
+		return err
+	}
+	return f.writeData(data, indent)
+}
+
+// signedJSON marshals v as encodeJSON does, signed when reports are.
+func (f *Formatter) signedJSON(v any) ([]byte, error) {
+	data, err := f.encodeJSON(v)
+	if err != nil || f.signer == nil {
+		return data, err
+	}
+	return f.signer.Sign(data)
+}
 
-	if consensus.Country != "" {
-		sb.WriteString(fmt.Sprintf("  Country:      %s", consensus.Country))
-		if consensus.CountryCode != "" {
-			sb.WriteString(fmt.Sprintf(" (%s)", consensus.CountryCode))
+// encodeJSON marshals v with the configured key style and ordering.
+func (f *Formatter) encodeJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.style == JSONStyleCamel {
+		if data, err = renameKeys(data, snakeToCamel); err != nil {
+			return nil, err
 		}
-		sb.WriteString("\n")
 	}
 
-	if consensus.Region != "" {
-		sb.WriteString(fmt.Sprintf("  Region:       %s\n", consensus.Region))
+	if f.sorted {
+		if data, err = sortKeys(data); err != nil {
+			return nil, err
+		}
 	}
+	return data, nil
+}
 
-	if consensus.City != "" {
-		sb.WriteString(fmt.Sprintf("  City:         %s\n", consensus.City))
+// writeQuery writes the result of the query against the JSON report: a
+// string as is, so that it can be used in shell scripts, and any other
+// value as JSON.
+func (f *Formatter) writeQuery(report model.Report, indent bool) error {
+	data, err := f.encodeJSON(f.jsonReport(report))
+	if err != nil {
+		return err
+	}
+	result, err := f.query.SearchJSON(data)
+	if err != nil {
+		return err
 	}
 
-	if consensus.HasLocation() {
-		sb.WriteString(fmt.Sprintf("  Coordinates:  %.4f, %.4f\n", consensus.Latitude, consensus.Longitude))
+	if s, ok := result.(string); ok {
+		_, err = io.WriteString(f.w, s+"\n")
+		return err
+	}
+	if data, err = json.Marshal(result); err != nil {
+		return err
 	}
+	return f.writeData(data, indent)
+}
 
-	if consensus.ISP != "" {
-		sb.WriteString(fmt.Sprintf("  ISP:          %s\n", consensus.ISP))
+// writeData writes a JSON document on a single line, or indented when
+// indent is set.
+func (f *Formatter) writeData(data []byte, indent bool) error {
+	if !indent {
+		_, err := f.w.Write(append(data, '\n'))
+		return err
 	}
 
-	if consensus.Org != "" {
-		sb.WriteString(fmt.Sprintf("  Organization: %s\n", consensus.Org))
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
 	}
+	out.WriteByte('\n')
 
-	if consensus.ASN != "" {
-		sb.WriteString(fmt.Sprintf("  ASN:          %s\n", consensus.ASN))
+	_, err := f.w.Write(out.Bytes())
+	return err
+}
+
+func (f *Formatter) formatText(report model.Report) error {
+	// Growing the builder once, to the typical size of a report, spares
+	// copying it over and over as it fills up
+	var sb strings.Builder
+	sb.Grow(textReportSize + textResultSize*len(report.Results))
+
+	// Header
+	_, _ = fmt.Fprintf(&sb, "IP Intelligence Report for %s\n", report.IP)
+	sb.WriteString(titleRule + "\n\n")
+
+	if t := report.Transition; t != nil {
+		note := fmt.Sprintf("Note: %s address embedding IPv4 %s", t.Mechanism, t.IPv4)
+		if t.LookedUp {
+			note += "; results are for the IPv4 address"
+		}
+		f.writeLine(&sb, note)
+		sb.WriteString("\n")
 	}
 
-	sb.WriteString("\n")
+	if report.IsAnycast {
+		f.writeLine(&sb, "Note: this is an anycast address served from many locations;")
+		f.writeLine(&sb, "      its geolocation only reflects one of them and is not meaningful.")
+		sb.WriteString("\n")
+	}
+
+	consensus := report.Consensus()
+
+	if offline := offlineCountry(report); offline != "" && consensus.CountryCode != "" && offline != consensus.CountryCode {
+		f.writeLine(&sb, fmt.Sprintf("Note: the offline country table places this address in %s,", offline))
+		f.writeLine(&sb, fmt.Sprintf("      while the online providers report %s.", consensus.CountryCode))
+		sb.WriteString("\n")
+	}
+
+	// Consensus results; without them, only the assessment of the address
+	if report.ConsensusOptions().Disabled {
+		if report.Risk() != nil || report.Policy != nil {
+			sb.WriteString("ASSESSMENT:\n")
+			sb.WriteString(sectionRule + "\n")
+			f.formatAssessment(&sb, report)
+			sb.WriteString("\n")
+		}
+	} else {
+		f.formatConsensus(&sb, report, consensus)
+	}
+
+	if lat, lon, ok := consensus.Coordinates(); ok && f.showMap {
+		sb.WriteString("MAP:\n")
+		sb.WriteString(sectionRule + "\n")
+		formatMap(&sb, lat, lon)
+		sb.WriteString("\n")
+	}
 
 	// Individual provider results
 	sb.WriteString("PROVIDER DETAILS:\n")
-	sb.WriteString(strings.Repeat("-", 40) + "\n")
+	sb.WriteString(sectionRule + "\n")
 
 	for _, result := range report.Results {
-		sb.WriteString(fmt.Sprintf("\n[%s] ", result.Provider))
+		_, _ = fmt.Fprintf(&sb, "\n[%s] ", result.Provider)
+		if result.Shadow {
+			sb.WriteString("SHADOW ")
+		}
+		if result.Advisory {
+			sb.WriteString("ADVISORY ")
+		}
 		if result.Success() {
-			sb.WriteString(fmt.Sprintf("(%.0fms)\n", float64(result.Duration.Milliseconds())))
+			_, _ = fmt.Fprintf(&sb, "(%s)\n", formatMillis(result.Duration))
 			f.formatGeolocation(&sb, result.Result)
+			if c := result.Comparison; c != nil {
+				f.writeLine(&sb, fmt.Sprintf("  Compared: %s", formatComparison(*c)))
+			}
+		} else if result.Skipped {
+			_, _ = fmt.Fprintf(&sb, "SKIPPED (%s)\n", result.Error)
 		} else {
 			sb.WriteString("FAILED\n")
-			sb.WriteString(fmt.Sprintf("  Error: %s\n", result.Error))
+			f.writeField(&sb, "  Error: ", result.Error)
+		}
+
+		if result.Quota != nil {
+			f.writeLine(&sb, fmt.Sprintf("  Quota:   %s", formatQuota(*result.Quota)))
 		}
 	}
 
+	if f.verbose {
+		f.formatTimeline(&sb, report)
+	}
+
 	// Summary
-	sb.WriteString("\n" + strings.Repeat("-", 40) + "\n")
-	sb.WriteString(fmt.Sprintf("Total: %d/%d providers succeeded in %dms\n",
+	sb.WriteString("\n" + sectionRule + "\n")
+	var notes []string
+	if n := report.SkippedCount(); n > 0 {
+		notes = append(notes, fmt.Sprintf("%d skipped", n))
+	}
+	if n := report.ShadowCount(); n > 0 {
+		notes = append(notes, fmt.Sprintf("%d shadow", n))
+	}
+	if n := report.AdvisoryCount(); n > 0 {
+		notes = append(notes, fmt.Sprintf("%d advisory", n))
+	}
+	skipped := ""
+	if len(notes) > 0 {
+		skipped = " (" + strings.Join(notes, ", ") + ")"
+	}
+	_, _ = fmt.Fprintf(&sb, "Total: %d/%d providers succeeded in %s%s\n",
 		report.SuccessCount(),
-		len(report.Results),
-		report.TotalDuration.Milliseconds()))
+		report.SuccessCount()+report.ErrorCount(),
+		formatMillis(report.TotalDuration),
+		skipped)
 
-	_, err := f.w.Write([]byte(sb.String()))
+	_, err := io.WriteString(f.w, sb.String())
 	return err
 }
 
+// formatConsensus renders the consensus section of report: the consensus
+// values, followed by the assessment of the address.
+func (f *Formatter) formatConsensus(sb *strings.Builder, report model.Report, consensus model.Geolocation) {
+	sb.WriteString("CONSENSUS (aggregated from all providers):\n")
+	sb.WriteString(sectionRule + "\n")
+
+	if country := f.country(consensus); country != "" {
+		f.writeField(sb, "  Country:      ", country)
+	}
+
+	if consensus.Region != "" {
+		f.writeField(sb, "  Region:       ", consensus.Region)
+	}
+
+	if consensus.City != "" {
+		f.writeField(sb, "  City:         ", consensus.City)
+	}
+
+	if lat, lon, ok := consensus.Coordinates(); ok {
+		f.writeLine(sb, fmt.Sprintf("  Coordinates:  %.4f, %.4f", lat, lon))
+	}
+
+	if report.ConsensusOptions().MinAgreement > 0 && consensus.Granularity != "" {
+		f.writeField(sb, "  Granularity:  ", string(consensus.Granularity))
+	}
+
+	if consensus.ISP != "" {
+		f.writeField(sb, "  ISP:          ", consensus.ISP)
+	}
+
+	if consensus.Org != "" {
+		f.writeField(sb, "  Organization: ", consensus.Org)
+	}
+
+	if consensus.ASN != "" {
+		f.writeField(sb, "  ASN:          ", consensus.ASN)
+	}
+
+	if consensus.Hostname != "" {
+		f.writeField(sb, "  Hostname:     ", consensus.Hostname)
+	}
+
+	if consensus.Prefix != nil {
+		f.writeField(sb, "  Prefix:       ", consensus.Prefix.String())
+	}
+
+	f.formatExtended(sb, consensus, 14)
+
+	f.formatAssessment(sb, report)
+	sb.WriteString("\n")
+}
+
+// formatAssessment renders the risk of report and the decision of the
+// policy, if any.
+func (f *Formatter) formatAssessment(sb *strings.Builder, report model.Report) {
+	if risk := report.Risk(); risk != nil {
+		f.writeLine(sb, fmt.Sprintf("  Risk:         %s", formatRisk(*risk)))
+	}
+
+	if d := report.Policy; d != nil {
+		policy := d.Action
+		if d.Rule != "" {
+			policy += " (rule " + d.Rule + ")"
+		}
+		f.writeField(sb, "  Policy:       ", policy)
+	}
+}
+
 func (f *Formatter) formatGeolocation(sb *strings.Builder, geo *model.Geolocation) {
 	if geo == nil {
 		return
 	}
 
-	if geo.Country != "" {
-		sb.WriteString(fmt.Sprintf("  Country: %s", geo.Country))
-		if geo.CountryCode != "" {
-			sb.WriteString(fmt.Sprintf(" (%s)", geo.CountryCode))
-		}
-		sb.WriteString("\n")
+	if country := f.country(*geo); country != "" {
+		f.writeField(sb, "  Country: ", country)
 	}
 
 	if geo.Region != "" {
-		sb.WriteString(fmt.Sprintf("  Region:  %s\n", geo.Region))
+		f.writeField(sb, "  Region:  ", geo.Region)
 	}
 
 	if geo.City != "" {
-		sb.WriteString(fmt.Sprintf("  City:    %s\n", geo.City))
+		f.writeField(sb, "  City:    ", geo.City)
 	}
 
-	if geo.HasLocation() {
-		sb.WriteString(fmt.Sprintf("  Coords:  %.4f, %.4f\n", geo.Latitude, geo.Longitude))
+	if lat, lon, ok := geo.Coordinates(); ok {
+		f.writeLine(sb, fmt.Sprintf("  Coords:  %.4f, %.4f", lat, lon))
 	}
 
 	if geo.ISP != "" {
-		sb.WriteString(fmt.Sprintf("  ISP:     %s\n", geo.ISP))
+		f.writeField(sb, "  ISP:     ", geo.ISP)
 	}
 
 	if geo.Org != "" {
-		sb.WriteString(fmt.Sprintf("  Org:     %s\n", geo.Org))
+		f.writeField(sb, "  Org:     ", geo.Org)
 	}
 
 	if geo.ASN != "" {
-		sb.WriteString(fmt.Sprintf("  ASN:     %s\n", geo.ASN))
+		f.writeField(sb, "  ASN:     ", geo.ASN)
+	}
+
+	if geo.Hostname != "" {
+		f.writeField(sb, "  Host:    ", geo.Hostname)
+	}
+
+	if geo.Prefix != nil {
+		f.writeField(sb, "  Prefix:  ", geo.Prefix.String())
+	}
+
+	f.formatExtended(sb, *geo, 9)
+}
+
+// formatExtended writes the security and registration sections of geo, with
+// values aligned after labels padded to width.
+func (f *Formatter) formatExtended(sb *strings.Builder, geo model.Geolocation, width int) {
+	line := func(label, value string) {
+		f.writeLine(sb, fmt.Sprintf("  %-*s%s", width, label+":", value))
+	}
+
+	if geo.SpecialUse != nil {
+		line("Special", geo.SpecialUse.String())
+	}
+
+	if geo.Security != nil {
+		line("Privacy", geo.Security.String())
+	}
+
+	if rep := geo.Reputation; rep != nil {
+		score := strconv.FormatFloat(rep.Score, 'f', -1, 64)
+		if len(rep.Categories) > 0 {
+			score += " (" + strings.Join(rep.Categories, ", ") + ")"
+		}
+		line("Score", score)
+	}
+
+	reg := geo.Registration
+	if reg == nil {
+		return
+	}
+
+	if reg.Company != "" {
+		company := reg.Company
+		if details := joinNonEmpty(reg.CompanyDomain, reg.CompanyType); details != "" {
+			company += " (" + details + ")"
+		}
+		line("Company", company)
+	}
+
+	if reg.Network != "" {
+		network := reg.Network
+		if reg.NetName != "" {
+			network += " (" + reg.NetName + ")"
+		}
+		line("Network", network)
+	}
+
+	if registry := joinNonEmpty(reg.Registry, reg.Country); registry != "" {
+		line("Registry", registry)
 	}
+
+	if abuse := joinNonEmpty(reg.AbuseEmail, reg.AbusePhone); abuse != "" {
+		line("Abuse", abuse)
+	}
+}
+
+// formatRisk summarises a combined risk score, its verdict and the share of
+// each source, e.g. "62.5, suspicious (abuseipdb 40, dnsbl 22.5)".
+func formatRisk(risk model.Risk) string {
+	parts := make([]string, len(risk.Contributions))
+	for i, c := range risk.Contributions {
+		parts[i] = c.Provider + " " + strconv.FormatFloat(c.Contribution, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%s, %s (%s)", strconv.FormatFloat(risk.Score, 'f', -1, 64), risk.Verdict, strings.Join(parts, ", "))
+}
+
+// joinNonEmpty joins the non-empty values with ", ".
+func joinNonEmpty(values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeField writes label followed by value as writeLine does, without
+// building the line unless it must be truncated.
+func (f *Formatter) writeField(sb *strings.Builder, label, value string) {
+	if !f.wide && utf8.RuneCountInString(label)+utf8.RuneCountInString(value) > compactWidth {
+		f.writeLine(sb, label+value)
+		return
+	}
+	sb.WriteString(label)
+	sb.WriteString(value)
+	sb.WriteByte('\n')
+}
+
+// writeLine writes line followed by a newline, truncating it to the compact
+// width unless wide output is enabled.
+func (f *Formatter) writeLine(sb *strings.Builder, line string) {
+	if !f.wide {
+		line = truncate(line, compactWidth)
+	}
+	sb.WriteString(line)
+	sb.WriteString("\n")
+}
+
+// offlineCountry returns the country code reported by the local country
+// provider, or "" if it did not answer.
+func offlineCountry(report model.Report) string {
+	for _, pr := range report.Results {
+		if pr.Provider == country.ProviderName && pr.Success() {
+			return pr.Result.CountryCode
+		}
+	}
+	return ""
+}
+
+// truncate shortens s to at most width runes, replacing the tail with an
+// ellipsis when it is cut.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	return strings.TrimRight(string(runes[:width-1]), " ") + "…"
+}
+
+// country renders the country of geo with its flag emoji and code, e.g.
+// "🇺🇸 United States (US)". When a language is configured, the name is
+// localized from the country code.
+func (f *Formatter) country(geo model.Geolocation) string {
+	name := geo.Country
+	if f.regions != nil && geo.CountryCode != "" {
+		if region, err := language.ParseRegion(geo.CountryCode); err == nil {
+			if localized := f.regions.Name(region); localized != "" {
+				name = localized
+			}
+		}
+	}
+
+	if name == "" {
+		return ""
+	}
+
+	if geo.CountryCode == "" {
+		return name
+	}
+	if flag := flagEmoji(geo.CountryCode); flag != "" && !f.noEmoji {
+		return flag + " " + name + " (" + geo.CountryCode + ")"
+	}
+	return name + " (" + geo.CountryCode + ")"
+}
+
+// flagEmoji converts a two-letter country code into its flag emoji, built
+// from Unicode regional indicator symbols. It returns "" for anything else.
+func flagEmoji(countryCode string) string {
+	if len(countryCode) != 2 {
+		return ""
+	}
+
+	flag := make([]byte, 0, 2*utf8.UTFMax)
+	for _, c := range strings.ToUpper(countryCode) {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		flag = utf8.AppendRune(flag, 0x1F1E6+c-'A')
+	}
+	return string(flag)
+}
+
+// formatQuota describes the remaining requests of a quota, e.g.
+// "44/45 requests left, resets in 1m0s".
+func formatQuota(q model.Quota) string {
+	left := strconv.Itoa(q.Remaining)
+	if q.Limit > 0 {
+		left += "/" + strconv.Itoa(q.Limit)
+	}
+	left += " requests left"
+
+	if q.ResetIn > 0 {
+		left += ", resets in " + q.ResetIn.Round(time.Second).String()
+	}
+	return left
+}
+
+// formatComparison describes how a shadow result compares with the
+// consensus, e.g. "country matches, city differs, 12 km away".
+func formatComparison(c model.Comparison) string {
+	var parts []string
+	for _, field := range []struct {
+		name  string
+		match *bool
+	}{{"country", c.CountryMatch}, {"region", c.RegionMatch}, {"city", c.CityMatch}, {"ASN", c.ASNMatch}} {
+		switch {
+		case field.match == nil:
+		case *field.match:
+			parts = append(parts, field.name+" matches")
+		default:
+			parts = append(parts, field.name+" differs")
+		}
+	}
+	if c.DistanceKm != nil {
+		parts = append(parts, fmt.Sprintf("%.0f km away", *c.DistanceKm))
+	}
+	if len(parts) == 0 {
+		return "nothing in common to compare"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatMillis formats d as whole milliseconds with thousands separators,
+// e.g. "1,234ms".
+func formatMillis(d time.Duration) string {
+	digits := strconv.FormatInt(d.Milliseconds(), 10)
+
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	var sb strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+
+	return sign + sb.String() + "ms"
 }
@@ -2,12 +2,20 @@ package cli
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"encoding/json"
+	"io"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
 
 	"api-client/internal/model"
+	"api-client/internal/provider/country"
+	"api-client/internal/query"
+	"api-client/internal/sign"
 )
 
 func makeTestReport() model.Report {
@@ -24,8 +32,8 @@ func makeTestReport() model.Report {
 					CountryCode: "US",
 					Region:      "California",
 					City:        "Mountain View",
-					Latitude:    37.386,
-					Longitude:   -122.084,
+					Latitude:    model.Float64(37.386),
+					Longitude:   model.Float64(-122.084),
 					ISP:         "Google LLC",
 					Org:         "Google",
 					ASN:         "AS15169",
@@ -40,8 +48,8 @@ func makeTestReport() model.Report {
 					CountryCode: "US",
 					Region:      "California",
 					City:        "Mountain View",
-					Latitude:    37.4,
-					Longitude:   -122.1,
+					Latitude:    model.Float64(37.4),
+					Longitude:   model.Float64(-122.1),
 					ISP:         "Google",
 					Org:         "Google Inc",
 					ASN:         "AS15169",
@@ -184,6 +192,109 @@ func TestFormatter_FormatText_WithError(t *testing.T) {
 	}
 }
 
+func TestFormatter_FormatText_Skipped(t *testing.T) {
+	report := makeTestReport()
+	report.Results[1] = model.ProviderResult{
+		Provider: "provider2",
+		Error:    "quorum reached",
+		Skipped:  true,
+	}
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "[provider2] SKIPPED (quorum reached)") {
+		t.Errorf("output should mark the skipped provider:\n%s", output)
+	}
+	if strings.Contains(output, "FAILED") {
+		t.Errorf("skipped providers should not be shown as failed:\n%s", output)
+	}
+}
+
+func TestFormatter_FormatText_SpecialUse(t *testing.T) {
+	ip := model.MustParseAddr("100.64.0.1")
+	report := model.Report{
+		IP: ip,
+		Results: []model.ProviderResult{
+			{Provider: "bogon", Result: &model.Geolocation{IP: ip, SpecialUse: &model.SpecialUse{
+				Prefix: "100.64.0.0/10", Category: "cgnat", Name: "Shared Address Space", RFC: "RFC6598",
+			}}},
+			{Provider: "remote", Error: "reserved range"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "Special:      Shared Address Space (100.64.0.0/10, RFC6598)") {
+		t.Errorf("output should classify the address:\n%s", output)
+	}
+}
+
+func TestFormatter_FormatText_SkippedNotCounted(t *testing.T) {
+	report := makeTestReport()
+	report.Results = append(report.Results, model.ProviderResult{
+		Provider: "bogon",
+		Error:    "not a special-use address",
+		Skipped:  true,
+	})
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "[bogon] SKIPPED (not a special-use address)") {
+		t.Errorf("output should show the skip reason:\n%s", output)
+	}
+	if !strings.Contains(output, "Total: 2/2 providers succeeded") || !strings.Contains(output, "(1 skipped)") {
+		t.Errorf("skipped providers should be left out of the total:\n%s", output)
+	}
+}
+
+func TestFormatter_FormatText_Shadow(t *testing.T) {
+	report := makeTestReport()
+	report.Results = append(report.Results, model.ProviderResult{
+		Provider: "candidate",
+		Result:   &model.Geolocation{CountryCode: "US", City: "Elsewhere"},
+		Shadow:   true,
+		Comparison: &model.Comparison{
+			CountryMatch: boolPtr(true),
+			CityMatch:    boolPtr(false),
+			DistanceKm:   model.Float64(412.4),
+		},
+	})
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "[candidate] SHADOW (") {
+		t.Errorf("output should mark the shadow provider:\n%s", output)
+	}
+	if !strings.Contains(output, "Compared: country matches, city differs, 412 km away") {
+		t.Errorf("output should show the comparison:\n%s", output)
+	}
+	if !strings.Contains(output, "Total: 2/2 providers succeeded") || !strings.Contains(output, "(1 shadow)") {
+		t.Errorf("shadow providers should be left out of the total:\n%s", output)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
 func TestFormatter_FormatText_EmptyReport(t *testing.T) {
 	ip := model.MustParseAddr("8.8.8.8")
 	report := model.Report{
@@ -240,8 +351,8 @@ func TestFormatter_FormatText_Coordinates(t *testing.T) {
 				Provider: "test",
 				Result: &model.Geolocation{
 					IP:        ip,
-					Latitude:  37.38605,
-					Longitude: -122.08385,
+					Latitude:  model.Float64(37.38605),
+					Longitude: model.Float64(-122.08385),
 				},
 				Duration: 100 * time.Millisecond,
 			},
@@ -281,3 +392,793 @@ func TestFormatter_FormatJSON_Duration(t *testing.T) {
 		t.Errorf("JSON should contain duration in ms, got: %s", buf.String())
 	}
 }
+
+func TestFormatter_FormatJSON_SortedKeys(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0], report.Results[1] = report.Results[1], report.Results[0]
+	report.Meta = &model.Meta{Providers: []string{"provider2", "provider1"}}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithSortedKeys(true)).Format(report, FormatJSON); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "{\n  \"ip\"") || strings.Index(out, `"results"`) > strings.Index(out, `"timestamp"`) {
+		t.Errorf("keys not sorted:\n%s", out)
+	}
+	if strings.Index(out, `"provider1"`) > strings.Index(out, `"provider2"`) {
+		t.Errorf("providers not sorted:\n%s", out)
+	}
+
+	// The report itself is left untouched
+	if report.Results[0].Provider != "provider2" || report.Meta.Providers[0] != "provider2" {
+		t.Error("Format() reordered the caller's report")
+	}
+
+	var again bytes.Buffer
+	if err := NewFormatter(&again, WithSortedKeys(true)).Format(report, FormatJSON); err != nil || again.String() != out {
+		t.Errorf("output not stable across calls: %v", err)
+	}
+}
+
+func TestFormatter_FormatJSON_Signed(t *testing.T) {
+	signer := sign.NewSigner(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)), "1.0.0")
+
+	for _, opts := range [][]FormatterOption{
+		{WithSigner(signer)},
+		{WithSigner(signer), WithJSONStyle(JSONStyleCamel), WithSortedKeys(true)},
+	} {
+		var buf bytes.Buffer
+		if err := NewFormatter(&buf, opts...).Format(makeTestReport(), FormatJSON); err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+
+		sig, err := sign.Verify(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Verify() error = %v\n%s", err, buf.String())
+		}
+		if sig.Version != "1.0.0" {
+			t.Errorf("Version = %q, want 1.0.0", sig.Version)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithSigner(signer)).FormatBatch([]model.Report{makeTestReport(), makeTestReportWithError()}, FormatJSON); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if _, err := sign.Verify([]byte(line)); err != nil {
+			t.Errorf("Verify() error = %v\n%s", err, line)
+		}
+	}
+}
+
+func TestFormatter_Query(t *testing.T) {
+	report := makeTestReport()
+
+	tests := []struct {
+		name  string
+		query string
+		opts  []FormatterOption
+		want  string
+	}{
+		{"string", "results[0].provider", nil, "provider1\n"},
+		{"list", "results[*].provider", nil, "[\n  \"provider1\",\n  \"provider2\"\n]\n"},
+		{"null", "missing", nil, "null\n"},
+		{"camel keys", "totalDurationMs", []FormatterOption{WithJSONStyle(JSONStyleCamel)}, "180\n"},
+		{"user keys kept", "{total_ms: total_duration_ms}", []FormatterOption{WithJSONStyle(JSONStyleCamel)}, "{\n  \"total_ms\": null\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			q, err := query.Compile(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := NewFormatter(&buf, append(tt.opts, WithQuery(q))...).Format(report, FormatText); err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Format() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatter_FormatBatch_Query(t *testing.T) {
+	q, err := query.Compile("{ip: ip, providers: length(results)}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	reports := []model.Report{makeTestReport(), makeTestReport()}
+	if err := NewFormatter(&buf, WithQuery(q)).FormatBatch(reports, FormatCSV); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	line := `{"ip":"8.8.8.8","providers":2}` + "\n"
+	if buf.String() != line+line {
+		t.Errorf("FormatBatch() = %q, want one line per report", buf.String())
+	}
+}
+
+func TestFormatter_FormatText_Risk(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0].Result.Reputation = &model.Reputation{Score: 80, Categories: []string{"ssh-bruteforce"}}
+	report.Results = append(report.Results, model.ProviderResult{
+		Provider: "dnsbl",
+		Result:   &model.Geolocation{IP: report.IP, Reputation: &model.Reputation{Score: 0}},
+	})
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"  Risk:         40, suspicious (provider1 40, dnsbl 0)\n",
+		"  Score:   80 (ssh-bruteforce)\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestFormatter_FormatText_NoConsensus(t *testing.T) {
+	report := makeTestReport()
+	report.SetConsensusOptions(model.ConsensusOptions{Disabled: true})
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	output := buf.String()
+
+	if strings.Contains(output, "CONSENSUS") || strings.Contains(output, "ASSESSMENT") {
+		t.Errorf("output should have no consensus section:\n%s", output)
+	}
+	if !strings.Contains(output, "[provider1] (100ms)") {
+		t.Errorf("output should keep the provider details:\n%s", output)
+	}
+
+	// The assessment of the address is kept
+	report.Policy = &model.PolicyDecision{Action: "review"}
+	buf.Reset()
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "ASSESSMENT:\n"+sectionRule+"\n  Policy:       review\n") {
+		t.Errorf("output should show the policy decision:\n%s", buf.String())
+	}
+}
+
+func TestFormatter_FormatText_CountryFlag(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(makeTestReport(), FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "🇺🇸 United States (US)") {
+		t.Errorf("output should contain the country with its flag, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := NewFormatter(&buf, WithNoEmoji(true)).Format(makeTestReport(), FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Country:      United States (US)") {
+		t.Errorf("output should contain the country without its flag, got: %s", buf.String())
+	}
+}
+
+func TestFormatter_FormatText_Language(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf, WithLanguage(language.German))
+
+	if err := f.Format(makeTestReport(), FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "Vereinigte Staaten (US)") {
+		t.Errorf("output should contain the German country name, got: %s", buf.String())
+	}
+}
+
+func TestFormatter_FormatText_LanguageFromCodeOnly(t *testing.T) {
+	ip := model.MustParseAddr("8.8.8.8")
+	report := model.Report{
+		IP: ip,
+		Results: []model.ProviderResult{
+			{Provider: "codes-only", Result: &model.Geolocation{IP: ip, CountryCode: "FR"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf, WithLanguage(language.English))
+
+	if err := f.Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "France (FR)") {
+		t.Errorf("output should derive the country name from its code, got: %s", buf.String())
+	}
+}
+
+func TestFormatter_FormatText_ThousandsSeparator(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0].Duration = 1234 * time.Millisecond
+	report.TotalDuration = 12345 * time.Millisecond
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+
+	if !strings.Contains(output, "(1,234ms)") {
+		t.Errorf("provider latency should use thousands separators, got: %s", output)
+	}
+
+	if !strings.Contains(output, "in 12,345ms") {
+		t.Errorf("total duration should use thousands separators, got: %s", output)
+	}
+}
+
+func TestFlagEmoji(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"US", "🇺🇸"},
+		{"de", "🇩🇪"},
+		{"", ""},
+		{"USA", ""},
+		{"1A", ""},
+	}
+
+	for _, tt := range tests {
+		if got := flagEmoji(tt.code); got != tt.want {
+			t.Errorf("flagEmoji(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMillis(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0ms"},
+		{999 * time.Millisecond, "999ms"},
+		{1000 * time.Millisecond, "1,000ms"},
+		{1234567 * time.Millisecond, "1,234,567ms"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMillis(tt.d); got != tt.want {
+			t.Errorf("formatMillis(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func makeTestReportWithLongValues() model.Report {
+	report := makeTestReport()
+	report.Results[0].Result.Org = "Example Organization With An Extraordinarily Long Registered Name Incorporated"
+	report.Results[1].Result.Org = report.Results[0].Result.Org
+	report.Results = append(report.Results, model.ProviderResult{
+		Provider: "failure",
+		Error:    `executing request: Get "https://provider.example/8.8.8.8": dial tcp: lookup provider.example: no such host`,
+	})
+	return report
+}
+
+func TestFormatter_FormatText_CompactWidth(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(makeTestReportWithLongValues(), FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if n := utf8.RuneCountInString(line); n > 80 {
+			t.Errorf("line exceeds 80 columns (%d): %q", n, line)
+		}
+	}
+
+	if !strings.Contains(buf.String(), "…") {
+		t.Error("truncated values should end with an ellipsis")
+	}
+}
+
+func TestFormatter_FormatText_Wide(t *testing.T) {
+	report := makeTestReportWithLongValues()
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf, WithWide(true))
+
+	if err := f.Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+
+	if !strings.Contains(output, report.Results[0].Result.Org) {
+		t.Error("wide output should contain the full organization name")
+	}
+
+	if !strings.Contains(output, report.Results[2].Error) {
+		t.Error("wide output should contain the full error message")
+	}
+
+	if strings.Contains(output, "…") {
+		t.Error("wide output should not truncate values")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"this is too long", 10, "this is t…"},
+		{"cut at a space", 8, "cut at…"},
+		{"ünïcödé strings", 8, "ünïcödé…"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestFormatter_FormatText_AnycastCaveat(t *testing.T) {
+	report := makeTestReport()
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "anycast") {
+		t.Error("the caveat should only be shown for anycast addresses")
+	}
+
+	report.IsAnycast = true
+	buf.Reset()
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "Note: this is an anycast address") {
+		t.Errorf("output should warn about anycast geolocation:\n%s", buf.String())
+	}
+}
+
+func TestFormatter_FormatText_OfflineCountry(t *testing.T) {
+	format := func(code string) string {
+		report := makeTestReport()
+		report.Results = append(report.Results, model.ProviderResult{
+			Provider: country.ProviderName,
+			Result:   &model.Geolocation{IP: report.IP, CountryCode: code},
+		})
+
+		var buf bytes.Buffer
+		if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+			t.Fatalf("Format() error = %v", err)
+		}
+		return buf.String()
+	}
+
+	if out := format("US"); strings.Contains(out, "offline country table") {
+		t.Errorf("no note expected when the offline table agrees:\n%s", out)
+	}
+
+	want := "Note: the offline country table places this address in DE,\n      while the online providers report US.\n"
+	if out := format("DE"); !strings.Contains(out, want) {
+		t.Errorf("output missing %q\n%s", want, out)
+	}
+}
+
+func TestFormatter_FormatText_Transition(t *testing.T) {
+	report := makeTestReport()
+	report.Transition = &model.Transition{Mechanism: "6to4", IPv4: model.MustParseAddr("8.8.8.8"), LookedUp: true}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	want := "Note: 6to4 address embedding IPv4 8.8.8.8; results are for the IPv4 address\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("output missing %q\n%s", want, buf.String())
+	}
+}
+
+func TestFormatter_FormatText_Quota(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0].Quota = &model.Quota{Limit: 45, Remaining: 44, ResetIn: 59600 * time.Millisecond}
+	report.Results[1].Quota = &model.Quota{Remaining: 3}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	for _, want := range []string{"  Quota:   44/45 requests left, resets in 1m0s\n", "  Quota:   3 requests left\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestFormatter_FormatText_Hostname(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0].Result.Hostname = "dns.google"
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	for _, want := range []string{"  Hostname:     dns.google\n", "  Host:    dns.google\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+}
+
+func TestFormatter_FormatText_ExtendedSections(t *testing.T) {
+	report := makeTestReport()
+	report.Results[0].Result.Security = &model.Security{VPN: true, Service: "ExampleVPN"}
+	report.Results[0].Result.Registration = &model.Registration{
+		Company:       "Google LLC",
+		CompanyDomain: "google.com",
+		Network:       "8.8.8.0/24",
+		AbuseEmail:    "network-abuse@google.com",
+	}
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{
+		"  Privacy:      vpn (ExampleVPN)\n",
+		"  Company:      Google LLC (google.com)\n",
+		"  Network:      8.8.8.0/24\n",
+		"  Abuse:        network-abuse@google.com\n",
+		"  Privacy: vpn (ExampleVPN)\n",
+		"  Abuse:   network-abuse@google.com\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatter_FormatBatch_JSON(t *testing.T) {
+	reports := []model.Report{makeTestReport(), makeTestReportWithError()}
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.FormatBatch(reports, FormatJSON); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("FormatBatch() wrote %d lines, want one per report", len(lines))
+	}
+
+	for _, line := range lines {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &parsed); err != nil {
+			t.Errorf("line is not valid JSON: %v\nline: %s", err, line)
+		}
+	}
+}
+
+func TestFormatter_FormatBatch_JSONArray(t *testing.T) {
+	reports := []model.Report{makeTestReport(), makeTestReportWithError()}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithJSONArray(true)).FormatBatch(reports, FormatJSON); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	var parsed []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("output is not a JSON document: %v\n%s", err, buf.String())
+	}
+	if len(parsed) != 2 {
+		t.Errorf("array has %d elements, want one per report", len(parsed))
+	}
+	if lines := strings.Split(buf.String(), "\n"); len(lines) != 5 || lines[0] != "[" || lines[3] != "]" {
+		t.Errorf("output should hold a report per line:\n%s", buf.String())
+	}
+
+	// A single report, or none, is an array too
+	buf.Reset()
+	if err := NewFormatter(&buf, WithJSONArray(true)).Format(makeTestReport(), FormatJSON); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil || len(parsed) != 1 {
+		t.Errorf("Format() = %s, want an array of one", buf.String())
+	}
+
+	buf.Reset()
+	if err := NewFormatter(&buf, WithJSONArray(true)).FormatBatch(nil, FormatJSON); err != nil || buf.String() != "[]\n" {
+		t.Errorf("FormatBatch() of no reports = %q, %v, want an empty array", buf.String(), err)
+	}
+}
+
+func TestFormatter_FormatBatch_Text(t *testing.T) {
+	failed := model.Report{
+		IP:      model.MustParseAddr("1.1.1.1"),
+		Results: []model.ProviderResult{{Provider: "provider1", Error: "timeout"}},
+	}
+	reports := []model.Report{makeTestReport(), failed}
+
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.FormatBatch(reports, FormatText); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	output := buf.String()
+	if n := strings.Count(output, "IP Intelligence Report for"); n != 2 {
+		t.Errorf("output contains %d reports, want 2", n)
+	}
+
+	for _, want := range []string{"### [1/2] 8.8.8.8", "### [2/2] 1.1.1.1", "SUMMARY (2 addresses):", "Total: 1/2 lookups succeeded"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+
+	summary := output[strings.Index(output, "SUMMARY"):]
+	if !strings.Contains(summary, "United States (US)") || !strings.Contains(summary, "FAILED") {
+		t.Errorf("summary should list each address with its outcome:\n%s", summary)
+	}
+}
+
+func TestFormatter_FormatBatch_TextGrouped(t *testing.T) {
+	cloudflare := makeTestReport()
+	cloudflare.IP = model.MustParseAddr("1.1.1.1")
+	for _, pr := range cloudflare.Results {
+		pr.Result.ASN, pr.Result.ISP = "AS13335", "Cloudflare"
+	}
+	failed := model.Report{
+		IP:      model.MustParseAddr("9.9.9.9"),
+		Results: []model.ProviderResult{{Provider: "provider1", Error: "timeout"}},
+	}
+	reports := []model.Report{cloudflare, failed, makeTestReport(), makeTestReport()}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithGroupBy(GroupByASN), WithNoEmoji(true)).FormatBatch(reports, FormatText); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	output := buf.String()
+	summary := output[strings.Index(output, "SUMMARY"):]
+
+	want := `SUMMARY (4 addresses):
+----------------------------------------
+AS15169, Google (2 address(es))
+  8.8.8.8  United States (US)  AS15169  2/2
+  8.8.8.8  United States (US)  AS15169  2/2
+
+AS13335, Cloudflare (1 address(es))
+  1.1.1.1  United States (US)  AS13335  2/2
+
+Unknown ASN (1 address(es), 1 failed)
+  9.9.9.9  FAILED                       0/1
+----------------------------------------
+Total: 3/4 lookups succeeded
+
+LATENCY:
+               p50    p90    p99
+  provider1  100ms  100ms  100ms
+  provider2  150ms  150ms  150ms
+  overall    180ms  180ms  180ms
+`
+	if summary != want {
+		t.Errorf("summary =\n%s\nwant\n%s", summary, want)
+	}
+}
+
+func TestFormatter_FormatBatch_TextLatencies(t *testing.T) {
+	var reports []model.Report
+	for i := 1; i <= 10; i++ {
+		report := makeTestReport()
+		report.Results[0].Duration = time.Duration(i) * 100 * time.Millisecond
+		report.Results[1] = model.ProviderResult{Provider: "provider2", Error: "not applicable", Skipped: true}
+		report.TotalDuration = 2 * time.Second
+		reports = append(reports, report)
+	}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithNoEmoji(true)).FormatBatch(reports, FormatText); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	output := buf.String()
+
+	// Percentiles are estimated within 5%, and skipped providers left out
+	want := `
+LATENCY:
+                 p50      p90      p99
+  provider1    515ms    925ms  1,000ms
+  overall    2,000ms  2,000ms  2,000ms
+`
+	if latencies := output[strings.Index(output, "\nLATENCY"):]; latencies != want {
+		t.Errorf("latencies =\n%s\nwant\n%s", latencies, want)
+	}
+}
+
+func TestFormatter_FormatBatch_TextTimeouts(t *testing.T) {
+	var reports []model.Report
+	for _, phase := range []string{"connect", "connect", "read", ""} {
+		report := makeTestReport()
+		report.Results[1] = model.ProviderResult{Provider: "provider2", Error: "timeout", Timeout: phase, Duration: time.Second}
+		reports = append(reports, report)
+	}
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithNoEmoji(true)).FormatBatch(reports, FormatText); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+	output := buf.String()
+
+	want := "\nTIMEOUTS:\n  provider2  2 connect, 1 read\n"
+	if !strings.HasSuffix(output, want) {
+		t.Errorf("output does not end with timeouts %q:\n%s", want, output)
+	}
+}
+
+func TestFormatter_Group(t *testing.T) {
+	geo := model.Geolocation{Country: "Germany", CountryCode: "de", ASN: "AS3320", ISP: "Deutsche Telekom AG"}
+
+	tests := []struct {
+		groupBy      GroupBy
+		geo          model.Geolocation
+		key, heading string
+	}{
+		{GroupByASN, geo, "AS3320", "AS3320, Deutsche Telekom AG"},
+		{GroupByCountry, geo, "DE", "Germany (de)"},
+		{GroupByCountry, model.Geolocation{Country: "Germany"}, "Germany", "Germany"},
+		{GroupByISP, geo, "deutsche telekom ag", "Deutsche Telekom AG"},
+		{GroupByISP, model.Geolocation{}, "", "Unknown ISP"},
+	}
+
+	for _, tt := range tests {
+		f := NewFormatter(io.Discard, WithGroupBy(tt.groupBy), WithNoEmoji(true))
+		if key, heading := f.group(tt.geo); key != tt.key || heading != tt.heading {
+			t.Errorf("group(%s, %+v) = %q, %q, want %q, %q", tt.groupBy, tt.geo, key, heading, tt.key, tt.heading)
+		}
+	}
+}
+
+func TestFormatter_FormatBatch_TextSingle(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.FormatBatch([]model.Report{makeTestReport()}, FormatText); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	if strings.Contains(buf.String(), "SUMMARY") || strings.Contains(buf.String(), "###") {
+		t.Errorf("a single report should be rendered without sections or summary:\n%s", buf.String())
+	}
+}
+
+func TestBatchWriter_WritesAsReportsArrive(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	w, err := f.NewBatchWriter(FormatText, 3, nil)
+	if err != nil {
+		t.Fatalf("NewBatchWriter() error = %v", err)
+	}
+
+	if err := w.Write(makeTestReport()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "### [1/3] 8.8.8.8") {
+		t.Errorf("the first report should be written before the run ends:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "SUMMARY") {
+		t.Error("the summary should only be written on Close")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "SUMMARY (1 addresses)") || !strings.Contains(buf.String(), "Total: 1/1 lookups succeeded") {
+		t.Errorf("the summary should cover the reports written:\n%s", buf.String())
+	}
+}
+
+func TestBatchWriter_Skip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewFormatter(&buf).NewBatchWriter(FormatText, 3, nil)
+	if err != nil {
+		t.Fatalf("NewBatchWriter() error = %v", err)
+	}
+
+	w.Skip()
+	w.Skip()
+	if err := w.Write(makeTestReport()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for _, want := range []string{"### [3/3] 8.8.8.8", "SUMMARY (1 addresses, 2 filtered out):", "Total: 1/1 lookups succeeded"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestBatchWriter_EmptyCSV(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	w, err := f.NewBatchWriter(FormatCSV, 0, []string{"user"})
+	if err != nil {
+		t.Fatalf("NewBatchWriter() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "user,ip,country,") || strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("output = %q, want only the header", buf.String())
+	}
+}
+
+func TestBatchWriter_UnsupportedFormat(t *testing.T) {
+	if _, err := NewFormatter(io.Discard).NewBatchWriter("xml", 1, nil); err == nil {
+		t.Error("NewBatchWriter() should reject unknown formats")
+	}
+}
+
+func BenchmarkFormatter_FormatBatch(b *testing.B) {
+	// A batch run of 10k addresses, with the consensus of each report
+	// cached as the aggregator leaves it
+	reports := make([]model.Report, 10000)
+	for i := range reports {
+		reports[i] = makeTestReport()
+		reports[i].Recompute()
+	}
+
+	for _, format := range []OutputFormat{FormatText, FormatJSON, FormatCSV} {
+		b.Run(string(format), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := NewFormatter(io.Discard).FormatBatch(reports, format); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
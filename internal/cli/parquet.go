@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"api-client/internal/model"
+	"api-client/internal/parquet"
+)
+
+// parquetColumns are the columns of Parquet output: those of CSV output,
+// typed. Values a report lacks are null rather than empty.
+var parquetColumns = []parquet.Column{
+	{Name: "ip", Type: parquet.String},
+	{Name: "country", Type: parquet.String, Optional: true},
+	{Name: "country_code", Type: parquet.String, Optional: true},
+	{Name: "region", Type: parquet.String, Optional: true},
+	{Name: "city", Type: parquet.String, Optional: true},
+	{Name: "latitude", Type: parquet.Double, Optional: true},
+	{Name: "longitude", Type: parquet.Double, Optional: true},
+	{Name: "isp", Type: parquet.String, Optional: true},
+	{Name: "org", Type: parquet.String, Optional: true},
+	{Name: "asn", Type: parquet.String, Optional: true},
+	{Name: "hostname", Type: parquet.String, Optional: true},
+	{Name: "is_anycast", Type: parquet.Boolean},
+	{Name: "providers_succeeded", Type: parquet.Int32},
+	{Name: "providers_total", Type: parquet.Int32},
+	{Name: "granularity", Type: parquet.String, Optional: true},
+}
+
+// parquetSchema returns the columns of Parquet output: passthrough input
+// columns first, as optional strings, then parquetColumns.
+func parquetSchema(inputColumns []string) []parquet.Column {
+	columns := make([]parquet.Column, 0, len(inputColumns)+len(parquetColumns))
+	for _, name := range inputColumns {
+		columns = append(columns, parquet.Column{Name: name, Type: parquet.String, Optional: true})
+	}
+	return append(columns, parquetColumns...)
+}
+
+// parquetRow returns the values of a report under the columns returned by
+// parquetSchema. Input fields not among inputColumns are dropped.
+func parquetRow(report model.Report, inputColumns []string) []any {
+	row := make([]any, 0, len(inputColumns)+len(parquetColumns))
+	for _, name := range inputColumns {
+		if value, ok := report.Input.Get(name); ok && string(value) != "null" {
+			row = append(row, model.Field{Name: name, Value: value}.Text())
+		} else {
+			row = append(row, nil)
+		}
+	}
+
+	consensus := report.Consensus()
+	var lat, lon any
+	if latitude, longitude, ok := consensus.Coordinates(); ok {
+		lat, lon = latitude, longitude
+	}
+
+	return append(row,
+		report.IP.String(),
+		nullable(consensus.Country),
+		nullable(consensus.CountryCode),
+		nullable(consensus.Region),
+		nullable(consensus.City),
+		lat,
+		lon,
+		nullable(consensus.ISP),
+		nullable(consensus.Org),
+		nullable(consensus.ASN),
+		nullable(consensus.Hostname),
+		report.IsAnycast,
+		report.SuccessCount(),
+		report.SuccessCount()+report.ErrorCount(),
+		nullable(string(consensus.Granularity)),
+	)
+}
+
+// nullable returns s, or nil for null when it is empty.
+func nullable(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
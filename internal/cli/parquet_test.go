@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestParquetSchema(t *testing.T) {
+	inputColumns := []string{"user"}
+	header := csvHeader(inputColumns)
+	schema := parquetSchema(inputColumns)
+	if len(schema) != len(header) {
+		t.Fatalf("schema has %d columns, CSV output %d", len(schema), len(header))
+	}
+	for i, column := range schema {
+		if column.Name != header[i] {
+			t.Errorf("column %d = %q, want %q as in CSV output", i, column.Name, header[i])
+		}
+	}
+}
+
+func TestParquetRow(t *testing.T) {
+	report := makeTestReport()
+	report.Input = model.Fields{
+		{Name: "user", Value: json.RawMessage(`"alice"`)},
+		{Name: "attempts", Value: json.RawMessage(`null`)},
+	}
+
+	row := parquetRow(report, []string{"user", "attempts", "session"})
+	if row[0] != "alice" || row[1] != nil || row[2] != nil {
+		t.Errorf("input values = %v, want alice and nulls", row[:3])
+	}
+
+	values := row[3:]
+	if values[0] != "8.8.8.8" || values[1] != "United States" {
+		t.Errorf("ip and country = %v, %v", values[0], values[1])
+	}
+	if _, ok := values[5].(float64); !ok {
+		t.Errorf("latitude = %#v, want a float64", values[5])
+	}
+	if values[11] != false || values[12] != 2 || values[13] != 2 || values[14] != "city" {
+		t.Errorf("is_anycast, provider counts and granularity = %v", values[11:])
+	}
+
+	// Skipped and shadow providers are left out of the total, as in text
+	// output
+	report.Results = append(report.Results,
+		model.ProviderResult{Provider: "ripestat", Error: "not announced in BGP", Skipped: true},
+		model.ProviderResult{Provider: "candidate", Result: &model.Geolocation{Country: "France"}, Shadow: true},
+	)
+	if values := parquetRow(report, nil); values[12] != 2 || values[13] != 2 {
+		t.Errorf("provider counts = %v, want 2 of 2", values[12:14])
+	}
+
+	// Values the consensus lacks are null
+	for i, v := range parquetRow(makeTestReportWithError(), nil) {
+		if v == "" {
+			t.Errorf("column %s is empty, want null", parquetColumns[i].Name)
+		}
+	}
+}
+
+func TestFormatter_FormatBatch_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFormatter(&buf)
+
+	if err := f.FormatBatch([]model.Report{makeTestReport(), makeTestReportWithError()}, FormatParquet); err != nil {
+		t.Fatalf("FormatBatch() error = %v", err)
+	}
+
+	out := buf.Bytes()
+	if !bytes.HasPrefix(out, []byte("PAR1")) || !bytes.HasSuffix(out, []byte("PAR1")) {
+		t.Errorf("output is not a Parquet file: %q", out)
+	}
+	if !bytes.Contains(out, []byte("providers_succeeded")) {
+		t.Error("output lacks the schema")
+	}
+}
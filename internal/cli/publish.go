@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"api-client/internal/model"
+)
+
+// PublishFormat selects how reports are serialized into the messages of
+// --publish.
+type PublishFormat string
+
+const (
+	// PublishJSON is the report as in JSON output, one per message
+	PublishJSON PublishFormat = "json"
+
+	// PublishFlat is a flat JSON object with the columns of CSV output,
+	// typed as in Parquet output, for stream processors mapping messages
+	// to table rows
+	PublishFlat PublishFormat = "flat"
+)
+
+// ParsePublishFormat converts a serialization name into a PublishFormat.
+func ParsePublishFormat(format string) (PublishFormat, error) {
+	switch format {
+	case "json", "":
+		return PublishJSON, nil
+	case "flat":
+		return PublishFlat, nil
+	default:
+		return "", fmt.Errorf("invalid publish format %q: must be 'json' or 'flat'", format)
+	}
+}
+
+// Message returns the key and value of the message publishing report: its
+// address, so that the messages of an address are kept in order, and the
+// report serialized as format. Flat messages have a field for each of
+// inputColumns, the passthrough fields of the batch input.
+func (f *Formatter) Message(report model.Report, format PublishFormat, inputColumns []string) (key, value []byte, err error) {
+	key = []byte(report.IP.String())
+	if format != PublishFlat {
+		value, err = f.signedJSON(f.jsonReport(report))
+		return key, value, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	row := parquetRow(report, inputColumns)
+	for i, column := range parquetSchema(inputColumns) {
+		name, err := json.Marshal(column.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return key, buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestParsePublishFormat(t *testing.T) {
+	if format, err := ParsePublishFormat("flat"); err != nil || format != PublishFlat {
+		t.Errorf("ParsePublishFormat(flat) = %v, %v", format, err)
+	}
+
+	if format, err := ParsePublishFormat(""); err != nil || format != PublishJSON {
+		t.Errorf("ParsePublishFormat(\"\") = %v, %v", format, err)
+	}
+
+	if _, err := ParsePublishFormat("avro"); err == nil {
+		t.Error("ParsePublishFormat(avro) expected error")
+	}
+}
+
+func TestFormatter_Message(t *testing.T) {
+	f := NewFormatter(&bytes.Buffer{})
+	report := makeTestReport()
+
+	key, value, err := f.Message(report, PublishJSON, nil)
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+	if string(key) != "8.8.8.8" {
+		t.Errorf("key = %q, want the address", key)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		t.Fatalf("value is not JSON: %v", err)
+	}
+	if _, ok := decoded["results"]; !ok {
+		t.Errorf("value = %s, want the report as in JSON output", value)
+	}
+}
+
+func TestFormatter_Message_Flat(t *testing.T) {
+	f := NewFormatter(&bytes.Buffer{})
+	report := makeTestReport()
+	report.Input = model.Fields{{Name: "user", Value: json.RawMessage(`"alice"`)}}
+
+	_, value, err := f.Message(report, PublishFlat, []string{"user"})
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+	if !strings.HasPrefix(string(value), `{"user":"alice","ip":"8.8.8.8","country":"United States"`) {
+		t.Errorf("value = %s, want the input fields, then the CSV columns", value)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		t.Fatalf("value is not JSON: %v", err)
+	}
+	if _, ok := decoded["latitude"].(float64); !ok || decoded["is_anycast"] != false || decoded["hostname"] != nil {
+		t.Errorf("value = %s, want typed columns, null when unknown", value)
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"api-client/internal/model"
+)
+
+// timelineWidth is the number of columns of the bars of the timeline.
+const timelineWidth = 32
+
+// formatTimeline renders when each provider was queried and for how long,
+// relative to the whole lookup, followed by how much of the provider time
+// overlapped and which provider the lookup waited for.
+func (f *Formatter) formatTimeline(sb *strings.Builder, report model.Report) {
+	sb.WriteString("\nTIMELINE:\n")
+	sb.WriteString(sectionRule + "\n")
+
+	// The scale spans the lookup, and any provider outlasting it
+	scale := report.TotalDuration
+	nameWidth := 0
+	for _, pr := range report.Results {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(pr.Provider))
+		if !pr.Start.IsZero() {
+			scale = max(scale, pr.Start.Sub(report.Timestamp)+pr.Duration)
+		}
+	}
+	scale = max(scale, time.Nanosecond)
+
+	var sum time.Duration
+	var slowest *model.ProviderResult
+	for i, pr := range report.Results {
+		name := pr.Provider + strings.Repeat(" ", nameWidth-utf8.RuneCountInString(pr.Provider))
+		if pr.Start.IsZero() {
+			f.writeLine(sb, fmt.Sprintf("  %s  not queried", name))
+			continue
+		}
+
+		offset := pr.Start.Sub(report.Timestamp)
+		from := int(int64(offset) * timelineWidth / int64(scale))
+		to := int((int64(offset+pr.Duration)*timelineWidth + int64(scale) - 1) / int64(scale))
+		to = min(max(to, from+1), timelineWidth)
+		from = min(from, to-1)
+		bar := strings.Repeat(" ", from) + strings.Repeat("█", to-from) + strings.Repeat(" ", timelineWidth-to)
+
+		status := ""
+		if !pr.Success() {
+			status = " failed"
+			if pr.Skipped {
+				status = " skipped"
+			}
+		}
+		f.writeLine(sb, fmt.Sprintf("  %s  +%-7s %8s  |%s|%s", name, formatMillis(offset), formatMillis(pr.Duration), bar, status))
+
+		sum += pr.Duration
+		if slowest == nil || pr.Duration > slowest.Duration {
+			slowest = &report.Results[i]
+		}
+	}
+
+	if slowest == nil {
+		return
+	}
+	if report.TotalDuration > 0 {
+		sb.WriteString(fmt.Sprintf("\nParallelism: %.1fx (%s of provider time in %s)\n",
+			float64(sum)/float64(report.TotalDuration), formatMillis(sum), formatMillis(report.TotalDuration)))
+		sb.WriteString(fmt.Sprintf("Slowest:     %s (%s, %.0f%% of the lookup)\n",
+			slowest.Provider, formatMillis(slowest.Duration), 100*float64(slowest.Duration)/float64(report.TotalDuration)))
+	}
+}
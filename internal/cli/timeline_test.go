@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestFormatter_FormatText_Timeline(t *testing.T) {
+	report := makeTestReport()
+	report.TotalDuration = 160 * time.Millisecond
+	report.Results[0].Start = report.Timestamp
+	report.Results[0].Duration = 80 * time.Millisecond
+	report.Results[1].Start = report.Timestamp.Add(80 * time.Millisecond)
+	report.Results[1].Duration = 80 * time.Millisecond
+	report.Results = append(report.Results, model.ProviderResult{Provider: "ipwhois", Error: "enough providers answered", Skipped: true})
+
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithVerbose(true)).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	out := buf.String()
+
+	half := strings.Repeat("█", timelineWidth/2)
+	blank := strings.Repeat(" ", timelineWidth/2)
+	for _, want := range []string{
+		"TIMELINE:",
+		"  provider1  +0ms         80ms  |" + half + blank + "|\n",
+		"  provider2  +80ms        80ms  |" + blank + half + "|\n",
+		"  ipwhois    not queried\n",
+		"Parallelism: 1.0x (160ms of provider time in 160ms)",
+		"Slowest:     provider1 (80ms, 50% of the lookup)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := NewFormatter(&buf).Format(report, FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "TIMELINE") {
+		t.Errorf("timeline shown without WithVerbose:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"api-client/internal/verify"
+)
+
+// PrintVerifyResult writes the outcome of verifying the report named name:
+// PASS with who signed it and when, or FAIL followed by the problems found.
+func PrintVerifyResult(w io.Writer, name string, res verify.Result) error {
+	var sb strings.Builder
+
+	status := "PASS"
+	if !res.OK() {
+		status = "FAIL"
+	}
+	line := fmt.Sprintf("%s  %s  %s", status, name, res.IP)
+	if sig := res.Signature; sig != nil {
+		line += fmt.Sprintf("  signed %s by ipintel %s", sig.Timestamp.Format(time.RFC3339), sig.Version)
+	} else if res.OK() {
+		line += "  unsigned"
+	}
+	sb.WriteString(strings.TrimRight(line, " ") + "\n")
+
+	for _, problem := range res.Problems {
+		sb.WriteString("      " + problem + "\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// PrintVerifySummary writes the number of reports verified and failed.
+func PrintVerifySummary(w io.Writer, total, failed int) error {
+	var err error
+	if failed == 0 {
+		_, err = fmt.Fprintf(w, "\n%d report(s) verified.\n", total)
+	} else {
+		_, err = fmt.Fprintf(w, "\n%d of %d report(s) FAILED verification.\n", failed, total)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/sign"
+	"api-client/internal/verify"
+)
+
+func TestParser_ParseVerifyCommand(t *testing.T) {
+	p := NewParser()
+	p.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+
+	cmd, err := p.ParseVerifyCommand([]string{"--key", "ipintel.pub", "--allow-unsigned", "a.json", "b.json"})
+	if err != nil {
+		t.Fatalf("ParseVerifyCommand() error = %v", err)
+	}
+	if cmd.KeyFile != "ipintel.pub" || !cmd.AllowUnsigned || strings.Join(cmd.Files, ",") != "a.json,b.json" {
+		t.Errorf("ParseVerifyCommand() = %+v", cmd)
+	}
+
+	if _, err := p.ParseVerifyCommand([]string{"--key", "ipintel.pub"}); err == nil {
+		t.Error("ParseVerifyCommand() without a file expected error")
+	}
+}
+
+func TestPrintVerifyResult(t *testing.T) {
+	signed := &sign.Signature{Version: "1.2.3", Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name string
+		res  verify.Result
+		want string
+	}{
+		{
+			name: "signed",
+			res:  verify.Result{IP: "8.8.8.8", Signature: signed},
+			want: "PASS  r.json  8.8.8.8  signed 2024-05-01T12:00:00Z by ipintel 1.2.3\n",
+		},
+		{
+			name: "unsigned",
+			res:  verify.Result{IP: "8.8.8.8"},
+			want: "PASS  r.json  8.8.8.8  unsigned\n",
+		},
+		{
+			name: "failed",
+			res:  verify.Result{Problems: []string{"not signed", "invalid JSON: unexpected EOF"}},
+			want: "FAIL  r.json\n      not signed\n      invalid JSON: unexpected EOF\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := PrintVerifyResult(&buf, "r.json", tt.res); err != nil {
+				t.Fatalf("PrintVerifyResult() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("PrintVerifyResult() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintVerifySummary(t *testing.T) {
+	var buf bytes.Buffer
+	_ = PrintVerifySummary(&buf, 3, 0)
+	_ = PrintVerifySummary(&buf, 3, 1)
+
+	want := "\n3 report(s) verified.\n\n1 of 3 report(s) FAILED verification.\n"
+	if buf.String() != want {
+		t.Errorf("PrintVerifySummary() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// mapWidth and mapHeight are the size of worldMap in characters: one
+	// column per 5° of longitude and one row per 7.5° of latitude, from
+	// 80°N to 55°S
+	mapWidth  = 72
+	mapHeight = 18
+	mapNorth  = 80.0
+	mapSouth  = -55.0
+
+	// mapMarker marks the consensus coordinates on the map
+	mapMarker = '@'
+)
+
+// worldMap is a land mask of the world in equirectangular projection,
+// rasterized from coarse coastlines: enough to tell continents and large
+// countries apart, not more.
+var worldMap = strings.Split(`
+            ....................       ..      .     ......
+   ....................  .......       .................................
+   ....................   ..         ..................................
+          ...............         ...............................  .
+           .............           ..............................
+           ...........            .... ....................... ..
+            ........              ...........................
+              ...  ..            ...........................
+                ...             ...............   ...  ...  .
+                   ......        .............     .   ...  .
+                    .......           .......          .. ..
+                    .........         ......            . .    ...
+                    .........         ...... .               ....
+                      ......          .....  .             .......
+                      ....             ....                ........
+                     ....               .                  .   ...    .
+                     ..                                               .
+                     ..
+`[1:], "\n")
+
+// formatMap draws the world map with a marker at lat, lon.
+func formatMap(sb *strings.Builder, lat, lon float64) {
+	col := min(max(int((lon+180)/360*mapWidth), 0), mapWidth-1)
+	row := min(max(int((mapNorth-lat)/(mapNorth-mapSouth)*mapHeight), 0), mapHeight-1)
+
+	border := "  +" + strings.Repeat("-", mapWidth) + "+\n"
+	sb.WriteString(border)
+	for i, line := range worldMap[:mapHeight] {
+		cells := []rune(line + strings.Repeat(" ", mapWidth-len(line)))
+		if i == row {
+			cells[col] = mapMarker
+		}
+		sb.WriteString("  |" + string(cells) + "|\n")
+	}
+	sb.WriteString(border)
+	_, _ = fmt.Fprintf(sb, "  %c %.4f, %.4f\n", mapMarker, lat, lon)
+}
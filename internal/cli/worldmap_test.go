@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// markerAt returns the row and column of the marker in a map drawn by
+// formatMap.
+func markerAt(t *testing.T, out string) (row, col int) {
+	t.Helper()
+	lines := strings.Split(out, "\n")[1 : mapHeight+1]
+	for i, line := range lines {
+		if j := strings.IndexRune(line, mapMarker); j >= 0 {
+			return i, j - len("  |")
+		}
+	}
+	t.Fatalf("no marker in:\n%s", out)
+	return 0, 0
+}
+
+func TestFormatMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		row, col int
+	}{
+		{"Mountain View", 37.386, -122.084, 5, 11},
+		{"Sydney", -33.87, 151.21, 15, 66},
+		{"null island", 0, 0, 10, 36},
+		{"north pole", 90, 180, 0, 71},
+		{"south pole", -90, -180, 17, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			formatMap(&sb, tt.lat, tt.lon)
+			out := sb.String()
+
+			if row, col := markerAt(t, out); row != tt.row || col != tt.col {
+				t.Errorf("marker at row %d, column %d, want %d, %d:\n%s", row, col, tt.row, tt.col, out)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+				if len(line) > compactWidth {
+					t.Errorf("line wider than %d columns: %q", compactWidth, line)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatter_FormatText_Map(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewFormatter(&buf, WithMap(true)).Format(makeTestReport(), FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "MAP:\n") || !strings.Contains(out, "  @ 37.3930, -122.0920\n") {
+		t.Errorf("output missing the map:\n%s", out)
+	}
+	if strings.Index(out, "MAP:") > strings.Index(out, "PROVIDER DETAILS:") {
+		t.Errorf("the map should follow the consensus:\n%s", out)
+	}
+
+	// Without coordinates, there is nothing to show
+	buf.Reset()
+	if err := NewFormatter(&buf, WithMap(true)).Format(makeTestReportWithError(), FormatText); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "MAP:") {
+		t.Errorf("map shown without coordinates:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,711 @@
+// Package config resolves the effective ipintel configuration by merging
+// defaults, the configuration file, environment variables and command-line
+// flags, recording where each value came from.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+// EnvPrefix prefixes every environment variable read by ipintel.
+const EnvPrefix = "IPINTEL_"
+
+// Sources of configuration values, from lowest to highest precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Duration is a time.Duration that is written as a string such as "5s"
+// in configuration files and JSON output.
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a Go duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a Go duration string such as "1500ms".
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// Quota is a number of requests a provider allows per period, written as a
+// string such as "45/min" or "1000/day". The zero Quota is no quota. Rate
+// limits are written alike.
+type Quota struct {
+	Requests int
+	Per      time.Duration
+}
+
+// quotaPeriods are the periods of quotas by name.
+var quotaPeriods = map[string]time.Duration{
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"day": 24 * time.Hour,
+}
+
+// ParseQuota parses a quota "N/PERIOD": N requests per PERIOD, which is s,
+// min, h, day or a Go duration such as "10s".
+func ParseQuota(s string) (Quota, error) {
+	n, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	requests, err := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || err != nil || requests < 1 {
+		return Quota{}, fmt.Errorf("%q is not a number of requests per period, such as \"45/min\"", s)
+	}
+	period = strings.TrimSpace(period)
+	per, ok := quotaPeriods[period]
+	if !ok {
+		if per, err = time.ParseDuration(period); err != nil || per <= 0 {
+			return Quota{}, fmt.Errorf("%q: the period must be s, min, h, day or a duration such as \"10s\"", s)
+		}
+	}
+	return Quota{Requests: requests, Per: per}, nil
+}
+
+// String returns the quota as ParseQuota reads it, or "" for no quota.
+func (q Quota) String() string {
+	if q.Requests == 0 {
+		return ""
+	}
+	for _, name := range []string{"s", "min", "h", "day"} {
+		if quotaPeriods[name] == q.Per {
+			return fmt.Sprintf("%d/%s", q.Requests, name)
+		}
+	}
+	return fmt.Sprintf("%d/%s", q.Requests, q.Per)
+}
+
+// MarshalJSON encodes the quota as a string such as "45/min".
+func (q Quota) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+// Value is a resolved configuration value together with its source, e.g.
+// "default", "file:/home/me/.config/ipintel/config.json", "env:IPINTEL_TIMEOUT"
+// or "flag:--timeout".
+type Value[T any] struct {
+	Value  T      `json:"value"`
+	Source string `json:"source"`
+}
+
+func (v *Value[T]) set(value T, source string) {
+	v.Value = value
+	v.Source = source
+}
+
+// ProviderConfig is the effective configuration of a single provider.
+type ProviderConfig struct {
+	APIKey  Value[string]   `json:"api_key"`
+	BaseURL Value[string]   `json:"base_url"`
+	Timeout Value[Duration] `json:"timeout"`
+
+	// Quota paces the queries of the provider so as not to exceed it
+	Quota Value[Quota] `json:"quota"`
+
+	// RateLimit spaces the queries of the provider evenly, at most its
+	// number of requests per period
+	RateLimit Value[Quota] `json:"rate_limit"`
+
+	// Retries is how many times a query failing transiently is retried
+	Retries Value[int] `json:"retries"`
+
+	// Custom is set for the providers defined in the configuration file
+	// rather than built in.
+	Custom *Value[CustomProvider] `json:"custom,omitempty"`
+}
+
+// CustomProvider defines a provider in the configuration file: the URL
+// template it is queried at, where "{ip}" is replaced by the address and
+// "{key}" by the API key, the headers sent with each request, and the
+// mapping of geolocation fields to JMESPath expressions extracting them
+// from its JSON responses.
+type CustomProvider struct {
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// DialerConfig is the effective configuration of the dialer all provider
+// connections are made with.
+type DialerConfig struct {
+	// Timeout bounds establishing a connection to each address tried
+	Timeout Value[Duration] `json:"timeout"`
+
+	// FallbackDelay is how long the first address family, usually IPv6,
+	// is tried before the other is raced against it (Happy Eyeballs); a
+	// negative delay tries the addresses in turn
+	FallbackDelay Value[Duration] `json:"fallback_delay"`
+
+	// KeepAlive is the interval of TCP keep-alive probes; a negative
+	// interval disables them
+	KeepAlive Value[Duration] `json:"keep_alive"`
+}
+
+// SMTPConfig is the effective configuration of the mail server reports
+// are sent through with --email-to.
+type SMTPConfig struct {
+	// Host and Port are the address of the server; port 465 is spoken
+	// over TLS, others upgraded with STARTTLS when the server offers it
+	Host Value[string] `json:"host"`
+	Port Value[int]    `json:"port"`
+
+	// Username and Password authenticate, if set, with PLAIN, which
+	// requires TLS unless the server is local
+	Username Value[string] `json:"username"`
+	Password Value[string] `json:"password"`
+
+	// From is the sender of the messages
+	From Value[string] `json:"from"`
+}
+
+// DefaultSMTPPort is the port of the mail server when none is configured,
+// that of message submission.
+const DefaultSMTPPort = 587
+
+// Config is the fully merged effective configuration.
+type Config struct {
+	File      string                    `json:"config_file,omitempty"`
+	Format    Value[string]             `json:"format"`
+	Timeout   Value[Duration]           `json:"timeout"`
+	Providers Value[[]string]           `json:"providers"`
+	Secondary Value[[]string]           `json:"secondary"`
+	Shadow    Value[[]string]           `json:"shadow"`
+	Provider  map[string]ProviderConfig `json:"provider"`
+	Dialer    DialerConfig              `json:"dialer"`
+	SMTP      SMTPConfig                `json:"smtp"`
+	Policy    Value[[]PolicyRule]       `json:"policy"`
+	Hooks     Value[[]Hook]             `json:"hooks"`
+	Alerts    Value[[]AlertRule]        `json:"alerts"`
+}
+
+// File is the on-disk configuration file format.
+type File struct {
+	Format    string                  `json:"format,omitempty"`
+	Timeout   Duration                `json:"timeout,omitempty"`
+	Providers []string                `json:"providers,omitempty"`
+	Secondary []string                `json:"secondary,omitempty"`
+	Shadow    []string                `json:"shadow,omitempty"`
+	Provider  map[string]ProviderFile `json:"provider,omitempty"`
+	Dialer    DialerFile              `json:"dialer"`
+	SMTP      SMTPFile                `json:"smtp"`
+	Policy    []PolicyRule            `json:"policy,omitempty"`
+	Hooks     []Hook                  `json:"hooks,omitempty"`
+	Alerts    []AlertRule             `json:"alerts,omitempty"`
+}
+
+// DialerFile is the dialer section of the configuration file.
+type DialerFile struct {
+	Timeout       Duration `json:"timeout,omitempty"`
+	FallbackDelay Duration `json:"fallback_delay,omitempty"`
+	KeepAlive     Duration `json:"keep_alive,omitempty"`
+}
+
+// SMTPFile is the smtp section of the configuration file.
+type SMTPFile struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from,omitempty"`
+}
+
+// PolicyRule is a rule of the policy section of the configuration file: the
+// action to take on the reports matching a condition, such as
+// "country not in [US, CA] and is_vpn". The first matching rule applies.
+type PolicyRule struct {
+	Name   string `json:"name,omitempty"`
+	When   string `json:"when,omitempty"`
+	Action string `json:"action"`
+}
+
+// Hook is a command of the hooks section of the configuration file, run
+// before or after each lookup with the address or the report on its
+// standard input. OnFailure is "ignore", "warn" or "fail".
+type Hook struct {
+	Name      string   `json:"name,omitempty"`
+	Stage     string   `json:"stage"`
+	Command   []string `json:"command"`
+	Timeout   Duration `json:"timeout,omitempty"`
+	OnFailure string   `json:"on_failure,omitempty"`
+}
+
+// AlertRule is a rule of the alerts section of the configuration file: a
+// change between two reports of an address, of one of the Changed fields
+// or into or out of a condition, and where to notify it.
+type AlertRule struct {
+	Name    string   `json:"name,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	Enters  string   `json:"enters,omitempty"`
+	Leaves  string   `json:"leaves,omitempty"`
+	Webhook string   `json:"webhook,omitempty"`
+	Exec    []string `json:"exec,omitempty"`
+	Syslog  string   `json:"syslog,omitempty"`
+}
+
+// ProviderFile is the per-provider section of the configuration file. A
+// section with a url defines a custom provider.
+type ProviderFile struct {
+	APIKey  string   `json:"api_key,omitempty"`
+	BaseURL string   `json:"base_url,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
+	Quota   string   `json:"quota,omitempty"`
+
+	RateLimit string `json:"rate_limit,omitempty"`
+	Retries   int    `json:"retries,omitempty"`
+	CustomProvider
+}
+
+// Defaults are the built-in values used when nothing else is configured.
+type Defaults struct {
+	Format    string
+	Timeout   time.Duration
+	Providers []string
+}
+
+// Overrides holds the values set explicitly on the command line.
+// Nil fields were not set.
+type Overrides struct {
+	Format  *string
+	Timeout *time.Duration
+}
+
+// Options controls how the configuration is loaded.
+type Options struct {
+	// Path is an explicit configuration file. When empty, the file at
+	// DefaultPath is used if it exists.
+	Path string
+
+	// Getenv looks up environment variables; os.Getenv when nil.
+	Getenv func(string) string
+
+	Defaults  Defaults
+	Overrides Overrides
+}
+
+// DefaultPath returns the default configuration file location inside the
+// user's configuration directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ipintel", "config.json"), nil
+}
+
+// ReadFile parses the configuration file at path.
+func ReadFile(path string) (File, error) {
+	var f File
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return f, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return f, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return f, nil
+}
+
+// Load resolves the effective configuration. Values are applied in order of
+// increasing precedence: defaults, configuration file, environment, flags.
+func Load(opts Options) (Config, error) {
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	path := opts.Path
+	explicit := path != ""
+	if !explicit {
+		path = getenv(EnvPrefix + "CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		if p, err := DefaultPath(); err == nil {
+			path = p
+		}
+	}
+
+	var file File
+	if path != "" {
+		f, err := ReadFile(path)
+		switch {
+		case err == nil:
+			file = f
+		case !explicit && errors.Is(err, fs.ErrNotExist):
+			path = ""
+		default:
+			return Config{}, err
+		}
+	}
+
+	cfg := Config{File: path}
+	if err := cfg.apply(opts.Defaults, file, path, getenv, opts.Overrides); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) apply(d Defaults, file File, path string, getenv func(string) string, o Overrides) error {
+	fileSource := SourceFile + ":" + path
+
+	// Defaults
+	c.Format.set(d.Format, SourceDefault)
+	c.Timeout.set(Duration(d.Timeout), SourceDefault)
+	c.Providers.set(d.Providers, SourceDefault)
+	c.Dialer.Timeout.set(Duration(provider.DefaultDialTimeout), SourceDefault)
+	c.Dialer.FallbackDelay.set(Duration(provider.DefaultFallbackDelay), SourceDefault)
+	c.Dialer.KeepAlive.set(Duration(provider.DefaultKeepAlive), SourceDefault)
+	c.SMTP.Host.Source = SourceDefault
+	c.SMTP.Port.set(DefaultSMTPPort, SourceDefault)
+	c.SMTP.Username.Source = SourceDefault
+	c.SMTP.Password.Source = SourceDefault
+	c.SMTP.From.Source = SourceDefault
+	c.Secondary.Source = SourceDefault
+	c.Shadow.Source = SourceDefault
+	c.Policy.Source = SourceDefault
+	c.Hooks.Source = SourceDefault
+	c.Alerts.Source = SourceDefault
+	c.Provider = make(map[string]ProviderConfig)
+	for _, name := range d.Providers {
+		c.Provider[name] = defaultProviderConfig()
+	}
+
+	// Configuration file
+	if file.Format != "" {
+		c.Format.set(file.Format, fileSource)
+	}
+	if file.Timeout != 0 {
+		c.Timeout.set(file.Timeout, fileSource)
+	}
+	if len(file.Providers) > 0 {
+		c.Providers.set(file.Providers, fileSource)
+	}
+	if len(file.Secondary) > 0 {
+		c.Secondary.set(file.Secondary, fileSource)
+	}
+	if len(file.Shadow) > 0 {
+		c.Shadow.set(file.Shadow, fileSource)
+	}
+	for name, pf := range file.Provider {
+		pc, ok := c.Provider[name]
+		if !ok {
+			pc = defaultProviderConfig()
+		}
+		if pf.APIKey != "" {
+			pc.APIKey.set(pf.APIKey, fileSource)
+		}
+		if pf.BaseURL != "" {
+			pc.BaseURL.set(pf.BaseURL, fileSource)
+		}
+		if pf.Timeout != 0 {
+			pc.Timeout.set(pf.Timeout, fileSource)
+		}
+		if pf.Quota != "" {
+			quota, err := ParseQuota(pf.Quota)
+			if err != nil {
+				return fmt.Errorf("provider %q: quota: %w", name, err)
+			}
+			pc.Quota.set(quota, fileSource)
+		}
+		if pf.RateLimit != "" {
+			limit, err := ParseQuota(pf.RateLimit)
+			if err != nil {
+				return fmt.Errorf("provider %q: rate_limit: %w", name, err)
+			}
+			pc.RateLimit.set(limit, fileSource)
+		}
+		if pf.Retries < 0 {
+			return fmt.Errorf("provider %q: retries must not be negative", name)
+		} else if pf.Retries > 0 {
+			pc.Retries.set(pf.Retries, fileSource)
+		}
+		if pf.URL != "" {
+			pc.Custom = &Value[CustomProvider]{}
+			pc.Custom.set(pf.CustomProvider, fileSource)
+		} else if len(pf.Headers) > 0 || len(pf.Fields) > 0 {
+			return fmt.Errorf("provider %q: headers and fields require a url", name)
+		}
+		c.Provider[name] = pc
+	}
+	if file.Dialer.Timeout != 0 {
+		c.Dialer.Timeout.set(file.Dialer.Timeout, fileSource)
+	}
+	if file.Dialer.FallbackDelay != 0 {
+		c.Dialer.FallbackDelay.set(file.Dialer.FallbackDelay, fileSource)
+	}
+	if file.Dialer.KeepAlive != 0 {
+		c.Dialer.KeepAlive.set(file.Dialer.KeepAlive, fileSource)
+	}
+	for _, s := range []struct {
+		value *Value[string]
+		file  string
+	}{
+		{&c.SMTP.Host, file.SMTP.Host},
+		{&c.SMTP.Username, file.SMTP.Username},
+		{&c.SMTP.Password, file.SMTP.Password},
+		{&c.SMTP.From, file.SMTP.From},
+	} {
+		if s.file != "" {
+			s.value.set(s.file, fileSource)
+		}
+	}
+	if file.SMTP.Port != 0 {
+		c.SMTP.Port.set(file.SMTP.Port, fileSource)
+	}
+	if len(file.Policy) > 0 {
+		c.Policy.set(file.Policy, fileSource)
+	}
+	if len(file.Hooks) > 0 {
+		c.Hooks.set(file.Hooks, fileSource)
+	}
+	if len(file.Alerts) > 0 {
+		c.Alerts.set(file.Alerts, fileSource)
+	}
+
+	// Environment
+	if v, key := lookupEnv(getenv, "FORMAT"); v != "" {
+		c.Format.set(v, SourceEnv+":"+key)
+	}
+	if v, key := lookupEnv(getenv, "TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		c.Timeout.set(Duration(timeout), SourceEnv+":"+key)
+	}
+	if v, key := lookupEnv(getenv, "PROVIDERS"); v != "" {
+		c.Providers.set(splitList(v), SourceEnv+":"+key)
+	}
+	if v, key := lookupEnv(getenv, "SECONDARY"); v != "" {
+		c.Secondary.set(splitList(v), SourceEnv+":"+key)
+	}
+	if v, key := lookupEnv(getenv, "SHADOW"); v != "" {
+		c.Shadow.set(splitList(v), SourceEnv+":"+key)
+	}
+	for _, env := range []struct {
+		name  string
+		value *Value[Duration]
+	}{
+		{"DIALER_TIMEOUT", &c.Dialer.Timeout},
+		{"DIALER_FALLBACK_DELAY", &c.Dialer.FallbackDelay},
+		{"DIALER_KEEP_ALIVE", &c.Dialer.KeepAlive},
+	} {
+		if v, key := lookupEnv(getenv, env.name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			env.value.set(Duration(d), SourceEnv+":"+key)
+		}
+	}
+	for _, env := range []struct {
+		name  string
+		value *Value[string]
+	}{
+		{"SMTP_HOST", &c.SMTP.Host},
+		{"SMTP_USERNAME", &c.SMTP.Username},
+		{"SMTP_PASSWORD", &c.SMTP.Password},
+		{"SMTP_FROM", &c.SMTP.From},
+	} {
+		if v, key := lookupEnv(getenv, env.name); v != "" {
+			env.value.set(v, SourceEnv+":"+key)
+		}
+	}
+	if v, key := lookupEnv(getenv, "SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid %s: %q", key, v)
+		}
+		c.SMTP.Port.set(port, SourceEnv+":"+key)
+	}
+	for _, name := range c.providerNames() {
+		pc := c.Provider[name]
+		prefix := envName(name) + "_"
+		if v, key := lookupEnv(getenv, prefix+"API_KEY"); v != "" {
+			pc.APIKey.set(v, SourceEnv+":"+key)
+		}
+		if v, key := lookupEnv(getenv, prefix+"BASE_URL"); v != "" {
+			pc.BaseURL.set(v, SourceEnv+":"+key)
+		}
+		if v, key := lookupEnv(getenv, prefix+"TIMEOUT"); v != "" {
+			timeout, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			pc.Timeout.set(Duration(timeout), SourceEnv+":"+key)
+		}
+		if v, key := lookupEnv(getenv, prefix+"QUOTA"); v != "" {
+			quota, err := ParseQuota(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			pc.Quota.set(quota, SourceEnv+":"+key)
+		}
+		if v, key := lookupEnv(getenv, prefix+"RATE_LIMIT"); v != "" {
+			limit, err := ParseQuota(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+			pc.RateLimit.set(limit, SourceEnv+":"+key)
+		}
+		if v, key := lookupEnv(getenv, prefix+"RETRIES"); v != "" {
+			retries, err := strconv.Atoi(v)
+			if err != nil || retries < 0 {
+				return fmt.Errorf("invalid %s: %q is not a number of retries", key, v)
+			}
+			pc.Retries.set(retries, SourceEnv+":"+key)
+		}
+		c.Provider[name] = pc
+	}
+
+	// Flags
+	if o.Format != nil {
+		c.Format.set(*o.Format, SourceFlag+":--format")
+	}
+	if o.Timeout != nil {
+		c.Timeout.set(Duration(*o.Timeout), SourceFlag+":--timeout")
+	}
+
+	if c.Dialer.Timeout.Value < 0 {
+		return fmt.Errorf("dialer timeout must not be negative (%s)", c.Dialer.Timeout.Source)
+	}
+
+	for _, name := range c.Secondary.Value {
+		if !slices.Contains(c.Providers.Value, name) {
+			return fmt.Errorf("secondary provider %q is not enabled (%s); add it to providers", name, c.Secondary.Source)
+		}
+	}
+	for _, name := range c.Shadow.Value {
+		if !slices.Contains(c.Providers.Value, name) {
+			return fmt.Errorf("shadow provider %q is not enabled (%s); add it to providers", name, c.Shadow.Source)
+		}
+		if slices.Contains(c.Secondary.Value, name) {
+			return fmt.Errorf("provider %q cannot be both secondary and shadow", name)
+		}
+	}
+
+	// Make sure every enabled provider has an entry.
+	for _, name := range c.Providers.Value {
+		if _, ok := c.Provider[name]; !ok {
+			c.Provider[name] = defaultProviderConfig()
+		}
+	}
+
+	return nil
+}
+
+// Redacted returns a copy of c with all API keys and the SMTP password
+// masked, suitable for display. The headers of custom providers are masked
+// too, but for those taking the API key from its placeholder.
+func (c Config) Redacted() Config {
+	redacted := c
+	redacted.Provider = make(map[string]ProviderConfig, len(c.Provider))
+	for name, pc := range c.Provider {
+		pc.APIKey.Value = provider.RedactKey(pc.APIKey.Value)
+		if pc.Custom != nil && len(pc.Custom.Value.Headers) > 0 {
+			custom := *pc.Custom
+			custom.Value.Headers = make(map[string]string, len(pc.Custom.Value.Headers))
+			for header, value := range pc.Custom.Value.Headers {
+				if !strings.Contains(value, "{key}") {
+					value = provider.RedactKey(value)
+				}
+				custom.Value.Headers[header] = value
+			}
+			pc.Custom = &custom
+		}
+		redacted.Provider[name] = pc
+	}
+	// Unlike keys, passwords are not told apart by their end
+	if c.SMTP.Password.Value != "" {
+		redacted.SMTP.Password.Value = "****"
+	}
+	return redacted
+}
+
+// providerNames returns the names of all configured providers, sorted.
+func (c *Config) providerNames() []string {
+	seen := make(map[string]bool, len(c.Provider))
+	for name := range c.Provider {
+		seen[name] = true
+	}
+	for _, name := range c.Providers.Value {
+		seen[name] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func defaultProviderConfig() ProviderConfig {
+	pc := ProviderConfig{}
+	pc.APIKey.Source = SourceDefault
+	pc.BaseURL.Source = SourceDefault
+	pc.Timeout.Source = SourceDefault
+	pc.Quota.Source = SourceDefault
+	pc.RateLimit.Source = SourceDefault
+	pc.Retries.Source = SourceDefault
+	return pc
+}
+
+func lookupEnv(getenv func(string) string, name string) (value, key string) {
+	key = EnvPrefix + name
+	return strings.TrimSpace(getenv(key)), key
+}
+
+// envName converts a provider name such as "ip-api" into its environment
+// variable form, "IP_API".
+func envName(provider string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(provider))
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,477 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDefaults = Defaults{
+	Format:    "text",
+	Timeout:   10 * time.Second,
+	Providers: []string{"ip-api", "ipinfo"},
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	// Point the user config directory somewhere empty.
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := Load(Options{
+		Path:     "",
+		Getenv:   env(map[string]string{EnvPrefix + "CONFIG": ""}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Format.Value != "text" || cfg.Format.Source != SourceDefault {
+		t.Errorf("Format = %+v, want text from default", cfg.Format)
+	}
+
+	if time.Duration(cfg.Timeout.Value) != 10*time.Second || cfg.Timeout.Source != SourceDefault {
+		t.Errorf("Timeout = %+v, want 10s from default", cfg.Timeout)
+	}
+
+	if len(cfg.Providers.Value) != 2 {
+		t.Errorf("Providers = %v, want 2 providers", cfg.Providers.Value)
+	}
+
+	if _, ok := cfg.Provider["ipinfo"]; !ok {
+		t.Error("Provider should contain an entry for every default provider")
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, `{
+		"format": "json",
+		"timeout": "3s",
+		"providers": ["ipinfo"],
+		"provider": {"ipinfo": {"api_key": "from-file", "timeout": "1s"}}
+	}`)
+
+	flagTimeout := 7 * time.Second
+	cfg, err := Load(Options{
+		Path: path,
+		Getenv: env(map[string]string{
+			"IPINTEL_TIMEOUT":        "5s",
+			"IPINTEL_IPINFO_API_KEY": "from-env",
+		}),
+		Defaults:  testDefaults,
+		Overrides: Overrides{Timeout: &flagTimeout},
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Format.Value != "json" || cfg.Format.Source != "file:"+path {
+		t.Errorf("Format = %+v, want json from file", cfg.Format)
+	}
+
+	if time.Duration(cfg.Timeout.Value) != 7*time.Second || cfg.Timeout.Source != "flag:--timeout" {
+		t.Errorf("Timeout = %+v, want 7s from flag", cfg.Timeout)
+	}
+
+	if len(cfg.Providers.Value) != 1 || cfg.Providers.Value[0] != "ipinfo" {
+		t.Errorf("Providers = %v, want [ipinfo]", cfg.Providers.Value)
+	}
+
+	pc := cfg.Provider["ipinfo"]
+	if pc.APIKey.Value != "from-env" || pc.APIKey.Source != "env:IPINTEL_IPINFO_API_KEY" {
+		t.Errorf("APIKey = %+v, want from-env from environment", pc.APIKey)
+	}
+
+	if time.Duration(pc.Timeout.Value) != time.Second || pc.Timeout.Source != "file:"+path {
+		t.Errorf("provider Timeout = %+v, want 1s from file", pc.Timeout)
+	}
+}
+
+func TestLoad_EnvProviderName(t *testing.T) {
+	cfg, err := Load(Options{
+		Path:     writeConfig(t, `{}`),
+		Getenv:   env(map[string]string{"IPINTEL_IP_API_BASE_URL": "http://localhost/"}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := cfg.Provider["ip-api"].BaseURL.Value; got != "http://localhost/" {
+		t.Errorf("ip-api BaseURL = %q, want http://localhost/", got)
+	}
+}
+
+func TestLoad_Policy(t *testing.T) {
+	path := writeConfig(t, `{
+		"policy": [
+			{"name": "foreign-vpn", "when": "country not in [US, CA] and is_vpn", "action": "block"},
+			{"when": "verdict == 'suspicious'", "action": "review"}
+		]
+	}`)
+
+	cfg, err := Load(Options{Path: path, Getenv: env(nil), Defaults: testDefaults})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(cfg.Policy.Value) != 2 || cfg.Policy.Source != "file:"+path {
+		t.Fatalf("Policy = %+v, want 2 rules from file", cfg.Policy)
+	}
+	if r := cfg.Policy.Value[0]; r.Name != "foreign-vpn" || r.Action != "block" {
+		t.Errorf("Policy[0] = %+v", r)
+	}
+}
+
+func TestLoad_Hooks(t *testing.T) {
+	path := writeConfig(t, `{
+		"hooks": [
+			{"name": "notify", "stage": "after", "command": ["notify-send", "ipintel"], "timeout": "5s", "on_failure": "ignore"}
+		]
+	}`)
+
+	cfg, err := Load(Options{Path: path, Getenv: env(nil), Defaults: testDefaults})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(cfg.Hooks.Value) != 1 || cfg.Hooks.Source != "file:"+path {
+		t.Fatalf("Hooks = %+v, want 1 hook from file", cfg.Hooks)
+	}
+	if h := cfg.Hooks.Value[0]; h.Stage != "after" || len(h.Command) != 2 || h.Timeout != Duration(5*time.Second) || h.OnFailure != "ignore" {
+		t.Errorf("Hooks[0] = %+v", h)
+	}
+}
+
+func TestLoad_Alerts(t *testing.T) {
+	path := writeConfig(t, `{
+		"alerts": [
+			{"name": "asn", "changed": ["asn"], "webhook": "https://hooks.example.com/T0"},
+			{"leaves": "country in [DE, FR]", "exec": ["notify-send", "ipintel"], "syslog": "udp://siem:514"}
+		]
+	}`)
+
+	cfg, err := Load(Options{Path: path, Getenv: env(nil), Defaults: testDefaults})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(cfg.Alerts.Value) != 2 || cfg.Alerts.Source != "file:"+path {
+		t.Fatalf("Alerts = %+v, want 2 rules from file", cfg.Alerts)
+	}
+	if r := cfg.Alerts.Value[1]; r.Leaves != "country in [DE, FR]" || len(r.Exec) != 2 || r.Syslog != "udp://siem:514" {
+		t.Errorf("Alerts[1] = %+v", r)
+	}
+}
+
+func TestLoad_Dialer(t *testing.T) {
+	path := writeConfig(t, `{"dialer": {"timeout": "3s", "fallback_delay": "50ms"}}`)
+
+	cfg, err := Load(Options{
+		Path:     path,
+		Getenv:   env(map[string]string{"IPINTEL_DIALER_FALLBACK_DELAY": "-1ns"}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	d := cfg.Dialer
+	if d.Timeout.Value != Duration(3*time.Second) || d.Timeout.Source != "file:"+path {
+		t.Errorf("Dialer.Timeout = %+v, want 3s from file", d.Timeout)
+	}
+	if d.FallbackDelay.Value != -1 || d.FallbackDelay.Source != "env:IPINTEL_DIALER_FALLBACK_DELAY" {
+		t.Errorf("Dialer.FallbackDelay = %+v, want -1ns from env", d.FallbackDelay)
+	}
+	if d.KeepAlive.Value != Duration(30*time.Second) || d.KeepAlive.Source != SourceDefault {
+		t.Errorf("Dialer.KeepAlive = %+v, want 30s by default", d.KeepAlive)
+	}
+}
+
+func TestLoad_SMTP(t *testing.T) {
+	path := writeConfig(t, `{"smtp": {"host": "mail.example.com", "username": "reports", "from": "ipintel@example.com"}}`)
+
+	cfg, err := Load(Options{
+		Path:     path,
+		Getenv:   env(map[string]string{"IPINTEL_SMTP_PASSWORD": "hunter2hunter2", "IPINTEL_SMTP_PORT": "465"}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	s := cfg.SMTP
+	if s.Host.Value != "mail.example.com" || s.Host.Source != "file:"+path {
+		t.Errorf("SMTP.Host = %+v, want from file", s.Host)
+	}
+	if s.Port.Value != 465 || s.Port.Source != "env:IPINTEL_SMTP_PORT" {
+		t.Errorf("SMTP.Port = %+v, want 465 from env", s.Port)
+	}
+	if s.Password.Value != "hunter2hunter2" || s.Password.Source != "env:IPINTEL_SMTP_PASSWORD" {
+		t.Errorf("SMTP.Password = %+v, want from env", s.Password)
+	}
+	if got := cfg.Redacted().SMTP.Password.Value; got != "****" {
+		t.Errorf("redacted password = %q", got)
+	}
+
+	if _, err := Load(Options{Getenv: env(map[string]string{"IPINTEL_SMTP_PORT": "smtp"}), Defaults: testDefaults}); err == nil {
+		t.Error("Load() with an invalid port expected error")
+	}
+}
+
+func TestLoad_Quota(t *testing.T) {
+	path := writeConfig(t, `{"provider": {"ip-api": {"quota": "45/min"}, "ipinfo": {"quota": "1000/day"}}}`)
+
+	cfg, err := Load(Options{
+		Path:     path,
+		Getenv:   env(map[string]string{"IPINTEL_IPINFO_QUOTA": "100/10s"}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if q := cfg.Provider["ip-api"].Quota; q.Value != (Quota{45, time.Minute}) || q.Source != "file:"+path {
+		t.Errorf("ip-api quota = %+v, want 45/min from file", q)
+	}
+	if q := cfg.Provider["ipinfo"].Quota; q.Value.String() != "100/10s" || q.Source != "env:IPINTEL_IPINFO_QUOTA" {
+		t.Errorf("ipinfo quota = %+v, want 100/10s from env", q)
+	}
+
+	cfg, err = Load(Options{Getenv: env(nil), Defaults: testDefaults})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := cfg.Provider["ipinfo"].Quota; q.Value != (Quota{}) || q.Source != SourceDefault {
+		t.Errorf("default quota = %+v, want none", q)
+	}
+
+	for _, s := range []string{"45", "0/min", "45/week", "45/-1s", "many/min"} {
+		if _, err := ParseQuota(s); err == nil {
+			t.Errorf("ParseQuota(%q) expected error", s)
+		}
+	}
+	if q, err := ParseQuota(" 1000 / day "); err != nil || q.String() != "1000/day" {
+		t.Errorf("ParseQuota() = %v, %v, want 1000/day", q, err)
+	}
+}
+
+func TestLoad_RateLimitRetries(t *testing.T) {
+	path := writeConfig(t, `{"provider": {"ipinfo": {"rate_limit": "10/s", "retries": 2}}}`)
+
+	cfg, err := Load(Options{
+		Path:     path,
+		Getenv:   env(map[string]string{"IPINTEL_IPINFO_RETRIES": "0"}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	pc := cfg.Provider["ipinfo"]
+	if pc.RateLimit.Value != (Quota{10, time.Second}) || pc.RateLimit.Source != "file:"+path {
+		t.Errorf("RateLimit = %+v, want 10/s from file", pc.RateLimit)
+	}
+	if pc.Retries.Value != 0 || pc.Retries.Source != "env:IPINTEL_IPINFO_RETRIES" {
+		t.Errorf("Retries = %+v, want 0 from env", pc.Retries)
+	}
+
+	for _, file := range []string{
+		`{"provider": {"ipinfo": {"retries": -1}}}`,
+		`{"provider": {"ipinfo": {"rate_limit": "fast"}}}`,
+	} {
+		if _, err := Load(Options{Path: writeConfig(t, file), Getenv: env(nil), Defaults: testDefaults}); err == nil {
+			t.Errorf("Load(%s) expected error", file)
+		}
+	}
+}
+
+func TestLoad_Secondary(t *testing.T) {
+	path := writeConfig(t, `{"secondary": ["ipinfo"]}`)
+
+	cfg, err := Load(Options{Path: path, Getenv: env(nil), Defaults: testDefaults})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Secondary.Value) != 1 || cfg.Secondary.Value[0] != "ipinfo" || cfg.Secondary.Source != "file:"+path {
+		t.Errorf("Secondary = %+v, want ipinfo from file", cfg.Secondary)
+	}
+
+	_, err = Load(Options{
+		Path:     path,
+		Getenv:   env(map[string]string{EnvPrefix + "SECONDARY": "ipwhois"}),
+		Defaults: testDefaults,
+	})
+	if err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Errorf("Load() error = %v, want a secondary provider that is not enabled", err)
+	}
+
+	_, err = Load(Options{
+		Path:     path,
+		Getenv:   env(map[string]string{EnvPrefix + "SHADOW": "ipinfo"}),
+		Defaults: testDefaults,
+	})
+	if err == nil || !strings.Contains(err.Error(), "both secondary and shadow") {
+		t.Errorf("Load() error = %v, want a provider both secondary and shadow", err)
+	}
+}
+
+func TestLoad_EmptyFile(t *testing.T) {
+	cfg, err := Load(Options{
+		Path:     writeConfig(t, "\n"),
+		Getenv:   env(nil),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Format.Source != SourceDefault {
+		t.Errorf("Format source = %q, want default", cfg.Format.Source)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{
+			name: "missing explicit file",
+			opts: Options{Path: filepath.Join(t.TempDir(), "missing.json")},
+		},
+		{
+			name: "unknown field",
+			opts: Options{Path: writeConfig(t, `{"colour": "blue"}`)},
+		},
+		{
+			name: "invalid duration in file",
+			opts: Options{Path: writeConfig(t, `{"timeout": "soon"}`)},
+		},
+		{
+			name: "invalid duration in env",
+			opts: Options{
+				Path:   writeConfig(t, `{}`),
+				Getenv: env(map[string]string{"IPINTEL_TIMEOUT": "soon"}),
+			},
+		},
+		{
+			name: "negative dialer timeout",
+			opts: Options{Path: writeConfig(t, `{"dialer": {"timeout": "-1s"}}`)},
+		},
+		{
+			name: "custom fields without url",
+			opts: Options{Path: writeConfig(t, `{"provider": {"my-geo": {"fields": {"country_code": "cc"}}}}`)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.Getenv == nil {
+				tt.opts.Getenv = env(nil)
+			}
+			tt.opts.Defaults = testDefaults
+
+			if _, err := Load(tt.opts); err == nil {
+				t.Error("Load() expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_CustomProvider(t *testing.T) {
+	cfg, err := Load(Options{
+		Path: writeConfig(t, `{
+			"providers": ["ipinfo", "my-geo"],
+			"provider": {"my-geo": {
+				"url": "https://my-geo.internal/{ip}",
+				"api_key": "0123456789abcdef",
+				"headers": {"Authorization": "Bearer {key}", "X-Tenant": "tenant-secret"},
+				"fields": {"country_code": "cc"}
+			}}
+		}`),
+		Getenv:   env(map[string]string{"IPINTEL_MY_GEO_TIMEOUT": "2s"}),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	pc := cfg.Provider["my-geo"]
+	if pc.Custom == nil || pc.Custom.Value.URL != "https://my-geo.internal/{ip}" || pc.Custom.Value.Fields["country_code"] != "cc" {
+		t.Fatalf("Custom = %+v, want the definition from the file", pc.Custom)
+	}
+	if !strings.HasPrefix(pc.Custom.Source, SourceFile) {
+		t.Errorf("Custom source = %q, want file", pc.Custom.Source)
+	}
+	if pc.Timeout.Value != Duration(2*time.Second) {
+		t.Errorf("Timeout = %v, want 2s from the environment", pc.Timeout.Value)
+	}
+	if cfg.Provider["ipinfo"].Custom != nil {
+		t.Error("built-in provider should not be custom")
+	}
+
+	headers := cfg.Redacted().Provider["my-geo"].Custom.Value.Headers
+	if headers["Authorization"] != "Bearer {key}" || headers["X-Tenant"] != "****cret" {
+		t.Errorf("redacted headers = %v", headers)
+	}
+	if pc.Custom.Value.Headers["X-Tenant"] != "tenant-secret" {
+		t.Error("Redacted() modified the original headers")
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg, err := Load(Options{
+		Path:     writeConfig(t, `{"provider": {"ipinfo": {"api_key": "0123456789abcdef"}}}`),
+		Getenv:   env(nil),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	redacted := cfg.Redacted()
+	if got := redacted.Provider["ipinfo"].APIKey.Value; got != "****cdef" {
+		t.Errorf("redacted APIKey = %q, want ****cdef", got)
+	}
+
+	if got := cfg.Provider["ipinfo"].APIKey.Value; got != "0123456789abcdef" {
+		t.Errorf("Redacted() modified the original config: %q", got)
+	}
+}
+
+func TestConfig_JSONMarshal(t *testing.T) {
+	cfg, err := Load(Options{
+		Path:     writeConfig(t, `{"timeout": "2s"}`),
+		Getenv:   env(nil),
+		Defaults: testDefaults,
+	})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if !strings.Contains(string(data), `"timeout":{"value":"2s","source":"file:`) {
+		t.Errorf("JSON should annotate timeout with its source, got %s", data)
+	}
+}
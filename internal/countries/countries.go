@@ -0,0 +1,108 @@
+// Package countries tallies the reports of a batch run by country, for maps
+// of where the addresses come from.
+package countries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"api-client/internal/model"
+)
+
+// Count is the number of addresses located in a country. The country is
+// identified by each of its ISO 3166-1 codes, to be joined with whichever
+// one a map uses, such as the numeric identifiers of the world-atlas
+// TopoJSON files or the ISO_A2 property of Natural Earth.
+type Count struct {
+	// Code is the alpha-2 code, e.g. "US"
+	Code string `json:"iso_a2"`
+
+	// ISO3 is the alpha-3 code, e.g. "USA"
+	ISO3 string `json:"iso_a3"`
+
+	// Numeric is the numeric code, e.g. "840"
+	Numeric string `json:"iso_n3"`
+
+	// Name is the English name of the country
+	Name string `json:"name"`
+
+	Count int `json:"count"`
+}
+
+// Tally counts reports by the country of their consensus.
+type Tally struct {
+	counts  map[string]*Count
+	total   int
+	unknown int
+}
+
+// NewTally returns an empty Tally.
+func NewTally() *Tally {
+	return &Tally{counts: make(map[string]*Count)}
+}
+
+// Add counts report in the country of its consensus, or as unknown when
+// the providers agreed on none.
+func (t *Tally) Add(report model.Report) {
+	t.total++
+
+	consensus := report.Consensus()
+	code := strings.ToUpper(consensus.CountryCode)
+	if code == "" {
+		t.unknown++
+		return
+	}
+
+	c, ok := t.counts[code]
+	if !ok {
+		c = newCount(code, consensus.Country)
+		t.counts[code] = c
+	}
+	c.Count++
+}
+
+// newCount returns an empty Count for the alpha-2 code, named after name
+// if the code is not one of a known country.
+func newCount(code, name string) *Count {
+	c := &Count{Code: code, Name: name}
+
+	region, err := language.ParseRegion(code)
+	if err != nil || !region.IsCountry() {
+		return c
+	}
+	c.ISO3 = region.ISO3()
+	c.Numeric = fmt.Sprintf("%03d", region.M49())
+	if english := display.English.Regions().Name(region); english != "" {
+		c.Name = english
+	}
+	return c
+}
+
+// Counts returns the count of each country, most frequent first.
+func (t *Tally) Counts() []Count {
+	counts := make([]Count, 0, len(t.counts))
+	for _, c := range t.counts {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Code < counts[j].Code
+	})
+	return counts
+}
+
+// Total returns the number of reports counted.
+func (t *Tally) Total() int {
+	return t.total
+}
+
+// Unknown returns the number of reports without a consensus country.
+func (t *Tally) Unknown() int {
+	return t.unknown
+}
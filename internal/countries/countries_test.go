@@ -0,0 +1,46 @@
+package countries
+
+import (
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func reportIn(countryCode, country string) model.Report {
+	return model.Report{Results: []model.ProviderResult{
+		{Provider: "ipinfo", Result: &model.Geolocation{CountryCode: countryCode, Country: country}},
+	}}
+}
+
+func TestTally(t *testing.T) {
+	tally := NewTally()
+	for _, report := range []model.Report{
+		reportIn("US", "United States of America"),
+		reportIn("DE", "Germany"),
+		reportIn("us", "USA"),
+		reportIn("", ""),
+		reportIn("XX", "Nowhere"),
+		{Results: []model.ProviderResult{{Provider: "ipinfo", Error: "timeout"}}},
+	} {
+		tally.Add(report)
+	}
+
+	want := []Count{
+		{Code: "US", ISO3: "USA", Numeric: "840", Name: "United States", Count: 2},
+		{Code: "DE", ISO3: "DEU", Numeric: "276", Name: "Germany", Count: 1},
+		{Code: "XX", Name: "Nowhere", Count: 1},
+	}
+	got := tally.Counts()
+	if len(got) != len(want) {
+		t.Fatalf("Counts() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Counts()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if tally.Total() != 6 || tally.Unknown() != 2 {
+		t.Errorf("Total(), Unknown() = %d, %d, want 6, 2", tally.Total(), tally.Unknown())
+	}
+}
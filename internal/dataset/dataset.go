@@ -0,0 +1,144 @@
+// Package dataset downloads the offline datasets used by local providers
+// into a data directory, verifies them before they replace the current
+// copies, and tracks when each was last refreshed.
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Dataset describes a downloadable dataset.
+type Dataset struct {
+	// Name identifies the dataset on the command line and in the manifest.
+	Name string
+
+	// File is the name the dataset is stored under in the data directory.
+	File string
+
+	// URL is where the dataset is downloaded from.
+	URL string
+
+	// Extract, if set, turns the download into the stored content, e.g.
+	// by unpacking an archive.
+	Extract func(data []byte) ([]byte, error)
+
+	// Verify checks the content before it replaces the current copy.
+	Verify func(data []byte) error
+
+	// MaxAge is how old the dataset may get before it is reported stale.
+	MaxAge time.Duration
+}
+
+// Builtin returns the datasets "ipintel update-data" downloads: the
+// ip2country tables the country provider answers from.
+func Builtin() []Dataset {
+	return []Dataset{
+		{
+			Name:    "ip2country-v4",
+			File:    "ip2country-v4.tsv",
+			URL:     "https://iptoasn.com/data/ip2country-v4.tsv.gz",
+			Extract: gunzip,
+			Verify:  verifyCountryTable,
+			MaxAge:  30 * 24 * time.Hour,
+		},
+		{
+			Name:    "ip2country-v6",
+			File:    "ip2country-v6.tsv",
+			URL:     "https://iptoasn.com/data/ip2country-v6.tsv.gz",
+			Extract: gunzip,
+			Verify:  verifyCountryTable,
+			MaxAge:  30 * 24 * time.Hour,
+		},
+	}
+}
+
+// Find returns the builtin dataset with the given name.
+func Find(name string) (Dataset, bool) {
+	for _, d := range Builtin() {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Dataset{}, false
+}
+
+// Dir returns the data directory: dir if set, else the IPINTEL_DATA_DIR
+// environment variable, else DefaultDir.
+func Dir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	if dir = os.Getenv("IPINTEL_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	return DefaultDir()
+}
+
+// DefaultDir returns the default data directory inside the user's cache
+// directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ipintel", "data"), nil
+}
+
+func verifyLines(data []byte, parse func(string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum, entries := 0, 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		entries++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if entries == 0 {
+		return errors.New("no entries")
+	}
+	return nil
+}
+
+// verifyCountryTable checks for one range per line: first address, last
+// address and country code.
+func verifyCountryTable(data []byte) error {
+	return verifyLines(data, func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return errors.New("want first address, last address and country code")
+		}
+		for _, field := range fields[:2] {
+			if _, err := netip.ParseAddr(field); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// gunzip decompresses a gzip file.
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = gz.Close() }()
+	return readLimited(gz)
+}
@@ -0,0 +1,244 @@
+package dataset
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+// ManifestFile records, in the data directory, when each dataset was
+// downloaded and the checksum of the stored file.
+const ManifestFile = "manifest.json"
+
+// maxDownloadSize bounds every download, as a guard against runaway or
+// hostile responses.
+const maxDownloadSize = 512 << 20
+
+// Entry is the manifest record of a dataset.
+type Entry struct {
+	File    string    `json:"file"`
+	URL     string    `json:"url"`
+	SHA256  string    `json:"sha256"`
+	Size    int64     `json:"size"`
+	Updated time.Time `json:"updated"`
+}
+
+// State summarises the health of a stored dataset.
+type State string
+
+const (
+	StateOK      State = "ok"
+	StateStale   State = "stale"
+	StateMissing State = "missing"
+	StateCorrupt State = "corrupt"
+)
+
+// Status is the state of a dataset in the data directory.
+type Status struct {
+	Name  string
+	Entry Entry
+	Age   time.Duration
+	State State
+	// Err explains a corrupt state.
+	Err error
+}
+
+// Manager downloads datasets into a data directory.
+type Manager struct {
+	dir       string
+	requester provider.HttpRequester
+	now       func() time.Time
+}
+
+// NewManager returns a Manager storing datasets in dir, created on the
+// first update, and downloading them with requester.
+func NewManager(dir string, requester provider.HttpRequester) *Manager {
+	return &Manager{dir: dir, requester: requester, now: time.Now}
+}
+
+// Dir returns the data directory.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Path returns the location of the stored copy of d.
+func (m *Manager) Path(d Dataset) string {
+	return filepath.Join(m.dir, d.File)
+}
+
+// Update downloads d, verifies it and atomically replaces the stored copy.
+// On any error the stored copy, if any, is left untouched.
+func (m *Manager) Update(ctx context.Context, d Dataset) (Status, error) {
+	data, err := m.fetch(ctx, d.URL)
+	if err != nil {
+		return Status{}, fmt.Errorf("downloading %s: %w", d.Name, err)
+	}
+
+	if d.Extract != nil {
+		if data, err = d.Extract(data); err != nil {
+			return Status{}, fmt.Errorf("extracting %s: %w", d.Name, err)
+		}
+	}
+
+	if d.Verify != nil {
+		if err := d.Verify(data); err != nil {
+			return Status{}, fmt.Errorf("verifying %s: %w", d.Name, err)
+		}
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return Status{}, err
+	}
+	if err := writeFileAtomic(m.Path(d), data); err != nil {
+		return Status{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	entry := Entry{
+		File:    d.File,
+		URL:     d.URL,
+		SHA256:  hex.EncodeToString(sum[:]),
+		Size:    int64(len(data)),
+		Updated: m.now().UTC(),
+	}
+
+	manifest, err := m.readManifest()
+	if err != nil {
+		return Status{}, err
+	}
+	manifest[d.Name] = entry
+	if err := m.writeManifest(manifest); err != nil {
+		return Status{}, err
+	}
+
+	return Status{Name: d.Name, Entry: entry, State: StateOK}, nil
+}
+
+// Status reports the state of the stored copy of d: missing if it was never
+// downloaded, corrupt if it no longer matches its recorded checksum, and
+// stale if it is older than d.MaxAge.
+func (m *Manager) Status(d Dataset) (Status, error) {
+	manifest, err := m.readManifest()
+	if err != nil {
+		return Status{}, err
+	}
+
+	st := Status{Name: d.Name, State: StateMissing}
+	entry, ok := manifest[d.Name]
+	if !ok {
+		return st, nil
+	}
+	st.Entry = entry
+	st.Age = m.now().Sub(entry.Updated)
+
+	data, err := os.ReadFile(m.Path(d))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return st, nil
+	case err != nil:
+		return Status{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	switch {
+	case hex.EncodeToString(sum[:]) != entry.SHA256:
+		st.State, st.Err = StateCorrupt, errors.New("checksum mismatch")
+	case d.MaxAge > 0 && st.Age > d.MaxAge:
+		st.State = StateStale
+	default:
+		st.State = StateOK
+	}
+	return st, nil
+}
+
+func (m *Manager) fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.requester.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return readLimited(resp.Body)
+}
+
+// readLimited reads r to the end, failing beyond maxDownloadSize bytes.
+// It also bounds decompressed archives.
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("data exceeds %d bytes", maxDownloadSize)
+	}
+	return data, nil
+}
+
+func (m *Manager) readManifest() (map[string]Entry, error) {
+	manifest := make(map[string]Entry)
+
+	data, err := os.ReadFile(filepath.Join(m.dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ManifestFile, err)
+	}
+	return manifest, nil
+}
+
+func (m *Manager) writeManifest(manifest map[string]Entry) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(m.dir, ManifestFile), append(data, '\n'))
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := io.Copy(f, bytes.NewReader(data)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,134 @@
+package dataset
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve returns a server answering each path with its body.
+func serve(t *testing.T, bodies map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestManager_Update(t *testing.T) {
+	server := serve(t, map[string]string{"/table": "# table\n192.0.2.0 192.0.2.255 US\n"})
+	d := Dataset{Name: "ip2country-v4", File: "ip2country-v4.tsv", URL: server.URL + "/table", Verify: verifyCountryTable, MaxAge: time.Hour}
+
+	m := NewManager(t.TempDir(), http.DefaultClient)
+	st, err := m.Update(context.Background(), d)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if st.State != StateOK || st.Entry.Size != 33 {
+		t.Errorf("Update() = %+v, want ok with 33 bytes", st)
+	}
+
+	data, err := os.ReadFile(m.Path(d))
+	if err != nil || !strings.Contains(string(data), "192.0.2.0") {
+		t.Fatalf("stored copy = %q, %v", data, err)
+	}
+
+	if st, err = m.Status(d); err != nil || st.State != StateOK {
+		t.Errorf("Status() = %+v, %v; want ok", st, err)
+	}
+}
+
+func TestManager_Update_KeepsCopyOnFailure(t *testing.T) {
+	server := serve(t, map[string]string{
+		"/good": "192.0.2.0 192.0.2.255 US\n",
+		"/bad":  "<html>maintenance</html>\n",
+	})
+	d := Dataset{Name: "ip2country-v4", File: "ip2country-v4.tsv", URL: server.URL + "/good", Verify: verifyCountryTable}
+
+	m := NewManager(t.TempDir(), http.DefaultClient)
+	if _, err := m.Update(context.Background(), d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	for _, path := range []string{"/bad", "/missing"} {
+		d.URL = server.URL + path
+		if _, err := m.Update(context.Background(), d); err == nil {
+			t.Errorf("Update(%s) should fail", path)
+		}
+	}
+
+	data, _ := os.ReadFile(m.Path(d))
+	if string(data) != "192.0.2.0 192.0.2.255 US\n" {
+		t.Errorf("stored copy = %q, want the previous download", data)
+	}
+	if st, _ := m.Status(d); st.State != StateOK {
+		t.Errorf("Status() = %+v, want ok", st)
+	}
+}
+
+func TestManager_Update_Extract(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte("192.0.2.0\t192.0.2.255\tUS\n"))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := serve(t, map[string]string{"/table.gz": buf.String(), "/plain": "192.0.2.0\t192.0.2.255\tUS\n"})
+	d, _ := Find("ip2country-v4")
+	d.URL = server.URL + "/plain"
+
+	m := NewManager(t.TempDir(), http.DefaultClient)
+	if _, err := m.Update(context.Background(), d); err == nil {
+		t.Fatal("Update() of an uncompressed table should fail")
+	}
+
+	d.URL = server.URL + "/table.gz"
+	if _, err := m.Update(context.Background(), d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	data, _ := os.ReadFile(m.Path(d))
+	if string(data) != "192.0.2.0\t192.0.2.255\tUS\n" {
+		t.Errorf("stored copy = %q, want the decompressed table", data)
+	}
+}
+
+func TestManager_Status(t *testing.T) {
+	server := serve(t, map[string]string{"/table": "192.0.2.0 192.0.2.255 US\n"})
+	d := Dataset{Name: "ip2country-v4", File: "ip2country-v4.tsv", URL: server.URL + "/table", Verify: verifyCountryTable, MaxAge: 24 * time.Hour}
+
+	m := NewManager(t.TempDir(), http.DefaultClient)
+	if st, err := m.Status(d); err != nil || st.State != StateMissing {
+		t.Fatalf("Status() = %+v, %v; want missing", st, err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	if _, err := m.Update(context.Background(), d); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	now = now.Add(25 * time.Hour)
+	if st, _ := m.Status(d); st.State != StateStale || st.Age != 25*time.Hour {
+		t.Errorf("Status() = %+v, want stale after 25h", st)
+	}
+
+	if err := os.WriteFile(m.Path(d), []byte("192.0.2.0 192.0.2.127 US\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := m.Status(d); st.State != StateCorrupt {
+		t.Errorf("Status() = %+v, want corrupt after modification", st)
+	}
+}
@@ -0,0 +1,272 @@
+// Package dnscache resolves the host names of provider endpoints once and
+// reuses their addresses for a while, so that a batch run opening many
+// connections neither pays for nor depends on a DNS query for each.
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long resolved addresses are reused by default.
+const DefaultTTL = 5 * time.Minute
+
+// lookupTimeout bounds a resolution, which is shared by all the
+// connections waiting for it rather than bound to any of their contexts.
+const lookupTimeout = 10 * time.Second
+
+// defaultFallbackDelay is the head start of the first address family when
+// the dialer does not set FallbackDelay, as in package net.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// Resolver caches the addresses of host names for its TTL, whatever the
+// TTL of their DNS records. Concurrent resolutions of a name are made
+// once, and when resolving a name again fails, its previous addresses are
+// reused for another TTL, so that a DNS outage during a run does not fail
+// its lookups. It is safe for concurrent use.
+type Resolver struct {
+	ttl    time.Duration
+	dialer *net.Dialer
+
+	// lookup, dial and now are replaced in tests
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is the resolution of a host name, complete once ready is closed.
+type entry struct {
+	ready   chan struct{}
+	addrs   []netip.Addr
+	err     error
+	expires time.Time
+}
+
+// New returns a Resolver reusing addresses for ttl and connecting to them
+// with dialer, whose Timeout applies to each address tried.
+func New(ttl time.Duration, dialer *net.Dialer) *Resolver {
+	return &Resolver{
+		ttl:     ttl,
+		dialer:  dialer,
+		lookup:  lookupNetIP,
+		dial:    dialer.DialContext,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+func lookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// Resolve returns the addresses of host, from the cache while they are
+// fresh. An IP address is returned as is.
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+
+	r.mu.Lock()
+	e, ok := r.entries[host]
+	if !ok || r.expired(e) {
+		stale := e
+		e = &entry{ready: make(chan struct{})}
+		r.entries[host] = e
+		go r.resolve(host, e, stale)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// expired reports whether e is complete and past its expiry; r.mu must be
+// held.
+func (r *Resolver) expired(e *entry) bool {
+	select {
+	case <-e.ready:
+		return !r.now().Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// resolve completes e with the addresses of host, or with those of the
+// stale entry it replaces when the lookup fails. Failures are not cached.
+func (r *Resolver) resolve(host string, e, stale *entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	addrs, err := r.lookup(ctx, host)
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+	}
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no addresses for %s", host)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case err == nil:
+		e.addrs, e.expires = addrs, r.now().Add(r.ttl)
+	case stale != nil && stale.err == nil:
+		e.addrs, e.expires = stale.addrs, r.now().Add(r.ttl)
+	default:
+		e.err, e.expires = err, r.now()
+	}
+	close(e.ready)
+}
+
+// Prime resolves hosts concurrently ahead of their first connection, so
+// that names failing to resolve are known at once. It returns the errors
+// of those that failed, joined.
+func (r *Resolver) Prime(ctx context.Context, hosts ...string) error {
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Resolve(ctx, host); err != nil {
+				errs[i] = fmt.Errorf("resolving %s: %w", host, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// DialContext connects to address, a host and port, through the cached
+// addresses of the host, trying those of each family in turn until one
+// accepts. As in package net, addresses of the other family than the first
+// are raced against it after the FallbackDelay of the dialer (Happy
+// Eyeballs), so that a broken IPv6 network costs that delay rather than a
+// dial timeout. It can be used as the DialContext of an http.Transport.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := r.Resolve(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	var primaries, fallbacks []netip.Addr
+	for _, addr := range addrs {
+		switch {
+		case network == "tcp4" && !addr.Is4() || network == "tcp6" && !addr.Is6():
+		case len(primaries) == 0 || addr.Is4() == primaries[0].Is4():
+			primaries = append(primaries, addr)
+		default:
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	if len(primaries) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("no %s address for %s", network, host)}
+	}
+	if len(fallbacks) == 0 || r.dialer.FallbackDelay < 0 {
+		return r.dialSerial(ctx, network, append(primaries, fallbacks...), port)
+	}
+	return r.dialParallel(ctx, network, primaries, fallbacks, port)
+}
+
+// dialSerial connects to the first of addrs accepting a connection on port.
+func (r *Resolver) dialSerial(ctx context.Context, network string, addrs []netip.Addr, port string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := r.dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// dialParallel dials primaries, and fallbacks once the fallback delay has
+// passed or the primaries have failed, returning the first connection
+// made and closing any other. The error of the primaries is preferred.
+func (r *Resolver) dialParallel(ctx context.Context, network string, primaries, fallbacks []netip.Addr, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan result, 2)
+	dial := func(addrs []netip.Addr, primary bool) {
+		conn, err := r.dialSerial(ctx, network, addrs, port)
+		results <- result{conn, err, primary}
+	}
+
+	delay := r.dialer.FallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	fallback := time.NewTimer(delay)
+	defer fallback.Stop()
+
+	go dial(primaries, true)
+	pending, fallbackStarted := 1, false
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-fallback.C:
+			if !fallbackStarted {
+				go dial(fallbacks, false)
+				pending, fallbackStarted = pending+1, true
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					// The other dial is canceled on return
+					go func() {
+						if res := <-results; res.conn != nil {
+							_ = res.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			if !fallbackStarted {
+				go dial(fallbacks, false)
+				pending, fallbackStarted = pending+1, true
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolver returns a Resolver whose lookups are answered by answer,
+// counting them, at a clock advanced by hand.
+func fakeResolver(answer func(host string) ([]netip.Addr, error)) (*Resolver, *atomic.Int32, *time.Time) {
+	var lookups atomic.Int32
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := New(time.Minute, &net.Dialer{Timeout: time.Second})
+	r.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		lookups.Add(1)
+		return answer(host)
+	}
+	r.now = func() time.Time { return now }
+	return r, &lookups, &now
+}
+
+func TestResolver_Resolve_Cached(t *testing.T) {
+	r, lookups, now := fakeResolver(func(string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("::ffff:192.0.2.1")}, nil
+	})
+	ctx := context.Background()
+
+	for range 3 {
+		addrs, err := r.Resolve(ctx, "api.example.com")
+		if err != nil || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.1") {
+			t.Fatalf("Resolve() = %v, %v, want 192.0.2.1", addrs, err)
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("lookups = %d within the TTL, want 1", n)
+	}
+
+	*now = now.Add(time.Minute)
+	if _, err := r.Resolve(ctx, "api.example.com"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if n := lookups.Load(); n != 2 {
+		t.Errorf("lookups = %d after the TTL, want 2", n)
+	}
+
+	if addrs, _ := r.Resolve(ctx, "198.51.100.1"); len(addrs) != 1 || lookups.Load() != 2 {
+		t.Errorf("Resolve() of an IP address = %v, looked up %d times", addrs, lookups.Load())
+	}
+}
+
+func TestResolver_Resolve_Stale(t *testing.T) {
+	var down atomic.Bool
+	r, lookups, now := fakeResolver(func(string) ([]netip.Addr, error) {
+		if down.Load() {
+			return nil, errors.New("server misbehaving")
+		}
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil
+	})
+	ctx := context.Background()
+
+	if _, err := r.Resolve(ctx, "api.example.com"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	// The previous addresses outlive a failing resolver
+	down.Store(true)
+	*now = now.Add(time.Minute)
+	if addrs, err := r.Resolve(ctx, "api.example.com"); err != nil || len(addrs) != 1 {
+		t.Errorf("Resolve() with the resolver down = %v, %v, want the stale address", addrs, err)
+	}
+
+	// Names never resolved fail, and are retried at the next call
+	for range 2 {
+		if _, err := r.Resolve(ctx, "other.example.com"); err == nil {
+			t.Error("Resolve() of an unresolvable name expected error")
+		}
+	}
+	if n := lookups.Load(); n != 4 {
+		t.Errorf("lookups = %d, want 4", n)
+	}
+}
+
+func TestResolver_Prime(t *testing.T) {
+	r, lookups, _ := fakeResolver(func(host string) ([]netip.Addr, error) {
+		if host == "down.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil
+	})
+
+	err := r.Prime(context.Background(), "a.example.com", "b.example.com", "down.example.com")
+	if err == nil || err.Error() != "resolving down.example.com: no such host" {
+		t.Errorf("Prime() error = %v", err)
+	}
+
+	if _, err := r.Resolve(context.Background(), "a.example.com"); err != nil || lookups.Load() != 3 {
+		t.Errorf("Resolve() after Prime() = %v, %d lookups, want 3", err, lookups.Load())
+	}
+}
+
+func TestResolver_DialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	r, lookups, _ := fakeResolver(func(string) ([]netip.Addr, error) {
+		// An unreachable address first, then the server
+		return []netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("127.0.0.1")}, nil
+	})
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = r.DialContext
+	client := &http.Client{Transport: transport}
+	for range 2 {
+		resp, err := client.Get("http://api.example.com:" + u.Port() + "/")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		_ = resp.Body.Close()
+		client.CloseIdleConnections()
+	}
+
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("lookups = %d for two connections, want 1", n)
+	}
+}
+
+func TestResolver_DialContext_Fallback(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	r, _, _ := fakeResolver(func(string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2"), netip.MustParseAddr("127.0.0.1")}, nil
+	})
+	r.dialer.FallbackDelay = 20 * time.Millisecond
+
+	// IPv6 is broken: its connections hang until canceled
+	var dialed atomic.Int32
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed.Add(1)
+		if host, _, _ := net.SplitHostPort(address); host != "127.0.0.1" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return r.dialer.DialContext(ctx, network, address)
+	}
+
+	start := time.Now()
+	conn, err := r.DialContext(context.Background(), "tcp", "api.example.com:"+u.Port())
+	if err != nil {
+		t.Fatalf("DialContext() error = %v", err)
+	}
+	_ = conn.Close()
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("DialContext() took %v, expected the IPv4 fallback after 20ms", elapsed)
+	}
+	if n := dialed.Load(); n != 2 {
+		t.Errorf("dialed %d addresses, want the first IPv6 and the IPv4 one", n)
+	}
+
+	// Without fast fallback, the addresses are tried in turn
+	r.dialer.FallbackDelay = -1
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.DialContext(ctx, "tcp", "api.example.com:"+u.Port()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DialContext() without fallback error = %v, want the IPv6 timeout", err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package evaluate scores the accuracy of providers against a reference
+// provider, to help decide which providers to trust and how much.
+package evaluate
+
+import (
+	"math"
+	"sort"
+
+	"api-client/internal/model"
+)
+
+// Agreement counts how often a provider agreed with the reference on a
+// field, among the lookups where both reported it.
+type Agreement struct {
+	Compared int     `json:"compared"`
+	Agreed   int     `json:"agreed"`
+	Rate     float64 `json:"rate"`
+}
+
+// add records the outcome of a comparison, if there was one.
+func (a *Agreement) add(match *bool) {
+	if match == nil {
+		return
+	}
+	a.Compared++
+	if *match {
+		a.Agreed++
+	}
+	a.Rate = float64(a.Agreed) / float64(a.Compared)
+}
+
+// Distance summarizes the distances between the coordinates reported by a
+// provider and by the reference, in kilometres.
+type Distance struct {
+	Compared int     `json:"compared"`
+	MeanKm   float64 `json:"mean_km"`
+	MedianKm float64 `json:"median_km"`
+	P90Km    float64 `json:"p90_km"`
+}
+
+// ProviderScore is the scorecard of one provider.
+type ProviderScore struct {
+	Provider string `json:"provider"`
+
+	// Lookups is the number of lookups the reference answered, and
+	// Answered the number of those the provider answered too
+	Lookups  int `json:"lookups"`
+	Answered int `json:"answered"`
+
+	// Coverage is Answered out of Lookups
+	Coverage float64 `json:"coverage"`
+
+	Country  Agreement `json:"country"`
+	Region   Agreement `json:"region"`
+	City     Agreement `json:"city"`
+	ASN      Agreement `json:"asn"`
+	Distance Distance  `json:"distance"`
+
+	// Weight is a suggested weight, from 0 to 1: the coverage times the
+	// mean of the agreement rates over the fields that were compared
+	Weight float64 `json:"weight"`
+
+	distances []float64
+}
+
+// Scorecard accumulates the scores of every provider against the reference.
+type Scorecard struct {
+	Reference string `json:"reference"`
+
+	// Lookups is the number of reports added, and ReferenceFailed the
+	// number of those the reference did not answer, which are not scored
+	Lookups         int `json:"lookups"`
+	ReferenceFailed int `json:"reference_failed"`
+
+	scores map[string]*ProviderScore
+	order  []string
+}
+
+// New returns an empty scorecard against the named reference provider.
+func New(reference string) *Scorecard {
+	return &Scorecard{Reference: reference, scores: make(map[string]*ProviderScore)}
+}
+
+// Add scores the results of a report against the reference result. Shadow
+// providers are scored like the others; skipped ones are not.
+func (s *Scorecard) Add(report model.Report) {
+	s.Lookups++
+
+	ref, ok := report.Result(s.Reference)
+	if !ok {
+		s.ReferenceFailed++
+		return
+	}
+
+	for _, pr := range report.Results {
+		// Skipped providers were not asked, so they are not scored
+		if pr.Provider == s.Reference || pr.Skipped {
+			continue
+		}
+
+		score := s.score(pr.Provider)
+		score.Lookups++
+		if pr.Success() {
+			score.Answered++
+			c := model.Compare(*pr.Result, ref)
+			score.Country.add(c.CountryMatch)
+			score.Region.add(c.RegionMatch)
+			score.City.add(c.CityMatch)
+			score.ASN.add(c.ASNMatch)
+			if c.DistanceKm != nil {
+				score.distances = append(score.distances, *c.DistanceKm)
+			}
+		}
+		score.Coverage = float64(score.Answered) / float64(score.Lookups)
+	}
+}
+
+// score returns the score of the named provider, creating it as needed.
+func (s *Scorecard) score(provider string) *ProviderScore {
+	score, ok := s.scores[provider]
+	if !ok {
+		score = &ProviderScore{Provider: provider}
+		s.scores[provider] = score
+		s.order = append(s.order, provider)
+	}
+	return score
+}
+
+// Scores returns the score of each provider other than the reference, in
+// the order they were first seen, with their distances and weights
+// computed.
+func (s *Scorecard) Scores() []ProviderScore {
+	scores := make([]ProviderScore, len(s.order))
+	for i, name := range s.order {
+		score := *s.scores[name]
+		score.Distance = summarize(score.distances)
+		score.Weight = weight(score)
+		score.distances = nil
+		scores[i] = score
+	}
+	return scores
+}
+
+// summarize computes the mean, median and 90th percentile of distances.
+func summarize(distances []float64) Distance {
+	d := Distance{Compared: len(distances)}
+	if len(distances) == 0 {
+		return d
+	}
+
+	sorted := append([]float64(nil), distances...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, km := range sorted {
+		sum += km
+	}
+	d.MeanKm = sum / float64(len(sorted))
+	d.MedianKm = percentile(sorted, 0.5)
+	d.P90Km = percentile(sorted, 0.9)
+	return d
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating
+// linearly between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := min(lo+1, len(sorted)-1)
+	return sorted[lo] + (rank-float64(lo))*(sorted[hi]-sorted[lo])
+}
+
+// weight derives the suggested weight of a provider from its coverage and
+// agreement rates.
+func weight(score ProviderScore) float64 {
+	var sum float64
+	n := 0
+	for _, a := range []Agreement{score.Country, score.Region, score.City, score.ASN} {
+		if a.Compared > 0 {
+			sum += a.Rate
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return score.Coverage * sum / float64(n)
+}
@@ -0,0 +1,77 @@
+package evaluate
+
+import (
+	"math"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func result(provider string, g *model.Geolocation) model.ProviderResult {
+	if g == nil {
+		return model.ProviderResult{Provider: provider, Error: "timeout"}
+	}
+	return model.ProviderResult{Provider: provider, Result: g}
+}
+
+func place(country, city string, lat, lon float64) *model.Geolocation {
+	return &model.Geolocation{CountryCode: country, City: city, Latitude: model.Float64(lat), Longitude: model.Float64(lon)}
+}
+
+func TestScorecard(t *testing.T) {
+	paris := place("FR", "Paris", 48.8566, 2.3522)
+	london := place("GB", "London", 51.5074, -0.1278)
+
+	s := New("ref")
+	s.Add(model.Report{Results: []model.ProviderResult{
+		result("ref", paris),
+		result("good", paris),
+		result("bad", london),
+	}})
+	s.Add(model.Report{Results: []model.ProviderResult{
+		result("ref", london),
+		result("good", london),
+		result("bad", nil),
+		{Provider: "skipped", Error: "quorum reached", Skipped: true},
+	}})
+	s.Add(model.Report{Results: []model.ProviderResult{
+		result("ref", nil),
+		result("good", paris),
+		result("bad", paris),
+	}})
+
+	if s.Lookups != 3 || s.ReferenceFailed != 1 {
+		t.Errorf("Lookups = %d, ReferenceFailed = %d, want 3, 1", s.Lookups, s.ReferenceFailed)
+	}
+
+	scores := s.Scores()
+	if len(scores) != 2 || scores[0].Provider != "good" || scores[1].Provider != "bad" {
+		t.Fatalf("Scores() = %+v, want good and bad", scores)
+	}
+
+	good, bad := scores[0], scores[1]
+	if good.Coverage != 1 || good.Country.Rate != 1 || good.City.Rate != 1 || good.Distance.P90Km != 0 || good.Weight != 1 {
+		t.Errorf("good = %+v, want full coverage and agreement", good)
+	}
+	if bad.Lookups != 2 || bad.Answered != 1 || bad.Coverage != 0.5 {
+		t.Errorf("bad coverage = %d/%d = %v, want 1/2", bad.Answered, bad.Lookups, bad.Coverage)
+	}
+	if bad.Country.Compared != 1 || bad.Country.Rate != 0 || bad.Weight != 0 {
+		t.Errorf("bad = %+v, want no agreement", bad)
+	}
+	if math.Abs(bad.Distance.MedianKm-343.5) > 1 {
+		t.Errorf("bad median distance = %v km, want about 343.5", bad.Distance.MedianKm)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	for p, want := range map[float64]float64{0: 0, 0.5: 50, 0.9: 90, 0.95: 95, 1: 100} {
+		if got := percentile(sorted, p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := percentile([]float64{7}, 0.9); got != 7 {
+		t.Errorf("percentile of one value = %v, want 7", got)
+	}
+}
@@ -0,0 +1,214 @@
+// Package hook runs the commands configured to be executed around each
+// lookup: before it, with the address on their standard input, and after
+// it, with the report, so that users can chain their own enrichment or
+// notifications without modifying ipintel.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// Stages a hook can run at.
+const (
+	StageBefore = "before"
+	StageAfter  = "after"
+)
+
+// Failure policies, deciding what a failing hook, one exiting with a
+// non-zero status or running past its timeout, does to the lookup.
+const (
+	// FailureIgnore carries on silently
+	FailureIgnore = "ignore"
+
+	// FailureWarn carries on after printing a warning
+	FailureWarn = "warn"
+
+	// FailureFail fails the lookup: before it, the address is not looked
+	// up and its report holds the error instead; after it, the exit code
+	// is 1
+	FailureFail = "fail"
+)
+
+// DefaultTimeout bounds the hooks configured without a timeout.
+const DefaultTimeout = 10 * time.Second
+
+// Hook is a command run at Stage of every lookup. Its standard output and
+// error are copied to the standard error of ipintel, so as not to mix with
+// the reports; its environment has IPINTEL_HOOK_STAGE and IPINTEL_IP set.
+type Hook struct {
+	Name      string
+	Stage     string
+	Command   []string
+	Timeout   time.Duration
+	OnFailure string
+}
+
+// Runner runs the hooks of each stage in the configured order.
+type Runner struct {
+	before []Hook
+	after  []Hook
+
+	// mu serializes the writes to stderr of hooks run concurrently
+	mu     sync.Mutex
+	stderr io.Writer
+}
+
+// New validates hooks, failing on the first invalid one. Their output and
+// the warnings about their failures are written to stderr.
+func New(hooks []Hook, stderr io.Writer) (*Runner, error) {
+	r := &Runner{stderr: stderr}
+	for i, h := range hooks {
+		if h.Name == "" {
+			h.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(h.Command) == 0 || h.Command[0] == "" {
+			return nil, fmt.Errorf("hook %s: no command", h.Name)
+		}
+		if h.Timeout <= 0 {
+			h.Timeout = DefaultTimeout
+		}
+		switch h.OnFailure {
+		case "":
+			h.OnFailure = FailureWarn
+		case FailureIgnore, FailureWarn, FailureFail:
+		default:
+			return nil, fmt.Errorf("hook %s: unknown on_failure %q; must be %s, %s or %s",
+				h.Name, h.OnFailure, FailureIgnore, FailureWarn, FailureFail)
+		}
+
+		switch h.Stage {
+		case StageBefore:
+			r.before = append(r.before, h)
+		case StageAfter:
+			r.after = append(r.after, h)
+		default:
+			return nil, fmt.Errorf("hook %s: unknown stage %q; must be %s or %s", h.Name, h.Stage, StageBefore, StageAfter)
+		}
+	}
+	return r, nil
+}
+
+// Before runs the before hooks for ip, which they read on their standard
+// input as {"ip": "..."}. It returns the error of the first failing hook
+// whose policy is FailureFail; the address should then not be looked up.
+func (r *Runner) Before(ctx context.Context, ip model.IPAddress) error {
+	if len(r.before) == 0 {
+		return nil
+	}
+	input, err := json.Marshal(struct {
+		IP model.IPAddress `json:"ip"`
+	}{ip})
+	if err != nil {
+		return err
+	}
+	return r.run(ctx, r.before, ip, input)
+}
+
+// After runs the after hooks for report, which they read on their standard
+// input as JSON. It returns the error of the first failing hook whose
+// policy is FailureFail.
+func (r *Runner) After(ctx context.Context, report model.Report) error {
+	if len(r.after) == 0 {
+		return nil
+	}
+	input, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return r.run(ctx, r.after, report.IP, input)
+}
+
+func (r *Runner) run(ctx context.Context, hooks []Hook, ip model.IPAddress, input []byte) error {
+	for _, h := range hooks {
+		err := r.exec(ctx, h, ip, input)
+		if err == nil {
+			continue
+		}
+
+		err = fmt.Errorf("%s hook %s: %w", h.Stage, h.Name, err)
+		switch h.OnFailure {
+		case FailureIgnore:
+		case FailureFail:
+			return err
+		default:
+			r.write(fmt.Appendf(nil, "Warning: %v\n", err))
+		}
+	}
+	return nil
+}
+
+// exec runs h with input on its standard input.
+func (r *Runner) exec(ctx context.Context, h Hook, ip model.IPAddress, input []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env = append(os.Environ(), "IPINTEL_HOOK_STAGE="+h.Stage, "IPINTEL_IP="+ip.String())
+	// Do not wait for the children of a killed hook to close its output
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	r.write(out.Bytes())
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", h.Timeout)
+	}
+	return err
+}
+
+// write copies the output of a hook, at once so that that of hooks run
+// concurrently does not interleave.
+func (r *Runner) write(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.stderr.Write(p)
+}
+
+// Looker performs a lookup for a single address.
+type Looker interface {
+	Lookup(ctx context.Context, ip model.IPAddress) model.Report
+}
+
+// Wrap returns a Looker running the before hooks ahead of each lookup of
+// looker. When one fails with FailureFail, the address is not looked up
+// and the report holds the error as that of a "hook" provider, so that it
+// counts as a failed lookup.
+func (r *Runner) Wrap(looker Looker) Looker {
+	return &hookedLooker{looker: looker, runner: r}
+}
+
+type hookedLooker struct {
+	looker Looker
+	runner *Runner
+}
+
+func (l *hookedLooker) Lookup(ctx context.Context, ip model.IPAddress) model.Report {
+	if err := l.runner.Before(ctx, ip); err != nil {
+		report := model.Report{
+			IP:        ip,
+			Timestamp: time.Now(),
+			Results:   []model.ProviderResult{{Provider: "hook", Error: err.Error()}},
+		}
+		report.Recompute()
+		return report
+	}
+	return l.looker.Lookup(ctx, ip)
+}
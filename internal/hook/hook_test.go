@@ -0,0 +1,160 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// lookerFunc adapts a function to the Looker interface.
+type lookerFunc func(ctx context.Context, ip model.IPAddress) model.Report
+
+func (f lookerFunc) Lookup(ctx context.Context, ip model.IPAddress) model.Report {
+	return f(ctx, ip)
+}
+
+func newRunner(t *testing.T, hooks ...Hook) (*Runner, *bytes.Buffer) {
+	t.Helper()
+	var stderr bytes.Buffer
+	r, err := New(hooks, &stderr)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return r, &stderr
+}
+
+func sh(script string) []string {
+	return []string{"sh", "-c", script}
+}
+
+func TestRunner_Before(t *testing.T) {
+	r, stderr := newRunner(t, Hook{Stage: StageBefore, Command: sh(`echo "$IPINTEL_HOOK_STAGE $IPINTEL_IP $(cat)"`)})
+
+	if err := r.Before(context.Background(), model.MustParseAddr("8.8.8.8")); err != nil {
+		t.Fatalf("Before() error = %v", err)
+	}
+	if got, want := stderr.String(), "before 8.8.8.8 {\"ip\":\"8.8.8.8\"}\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestRunner_After(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "report.json")
+	r, _ := newRunner(t,
+		Hook{Stage: StageBefore, Command: sh("exit 1"), OnFailure: FailureFail},
+		Hook{Stage: StageAfter, Command: append(sh(`cat > "$0"`), out), OnFailure: FailureFail},
+	)
+
+	report := model.Report{IP: model.MustParseAddr("8.8.8.8"), Results: []model.ProviderResult{{Provider: "ipinfo", Result: &model.Geolocation{CountryCode: "US"}}}}
+	if err := r.After(context.Background(), report); err != nil {
+		t.Fatalf("After() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		IP      string `json:"ip"`
+		Results []struct {
+			Provider string `json:"provider"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("hook input is not JSON: %v\n%s", err, data)
+	}
+	if got.IP != "8.8.8.8" || len(got.Results) != 1 || got.Results[0].Provider != "ipinfo" {
+		t.Errorf("hook input = %s", data)
+	}
+}
+
+func TestRunner_FailurePolicies(t *testing.T) {
+	tests := []struct {
+		onFailure string
+		wantErr   bool
+		wantOut   string
+	}{
+		{"", false, "oops\nWarning: before hook check: exit status 3\n"},
+		{FailureWarn, false, "oops\nWarning: before hook check: exit status 3\n"},
+		{FailureIgnore, false, "oops\n"},
+		{FailureFail, true, "oops\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.onFailure, func(t *testing.T) {
+			r, stderr := newRunner(t, Hook{Name: "check", Stage: StageBefore, Command: sh("echo oops; exit 3"), OnFailure: tt.onFailure})
+
+			err := r.Before(context.Background(), model.MustParseAddr("8.8.8.8"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Before() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stderr.String() != tt.wantOut {
+				t.Errorf("output = %q, want %q", stderr.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	r, _ := newRunner(t, Hook{Stage: StageAfter, Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond, OnFailure: FailureFail})
+
+	start := time.Now()
+	err := r.After(context.Background(), model.Report{IP: model.MustParseAddr("8.8.8.8")})
+	if err == nil || !strings.Contains(err.Error(), "after hook #1: timed out after 50ms") {
+		t.Errorf("After() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("After() took %v, want the hook killed at its timeout", elapsed)
+	}
+}
+
+func TestRunner_Wrap(t *testing.T) {
+	looked := 0
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		looked++
+		return model.Report{IP: ip, Results: []model.ProviderResult{{Provider: "ipinfo", Result: &model.Geolocation{}}}}
+	})
+
+	// Only 8.8.8.8 is allowed
+	r, _ := newRunner(t, Hook{Name: "allow", Stage: StageBefore, Command: sh(`test "$IPINTEL_IP" = 8.8.8.8`), OnFailure: FailureFail})
+	wrapped := r.Wrap(looker)
+
+	if report := wrapped.Lookup(context.Background(), model.MustParseAddr("8.8.8.8")); report.AllFailed() || looked != 1 {
+		t.Errorf("allowed lookup: AllFailed() = %v, looked up %d times", report.AllFailed(), looked)
+	}
+
+	report := wrapped.Lookup(context.Background(), model.MustParseAddr("1.1.1.1"))
+	if looked != 1 {
+		t.Error("the address should not be looked up when a before hook fails")
+	}
+	if !report.AllFailed() || report.IP.String() != "1.1.1.1" || !strings.Contains(report.Results[0].Error, "before hook allow") {
+		t.Errorf("report = %+v, want a failed lookup", report)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		hook Hook
+		want string
+	}{
+		{"no command", Hook{Stage: StageAfter}, "hook #1: no command"},
+		{"unknown stage", Hook{Stage: "during", Command: []string{"true"}}, `unknown stage "during"`},
+		{"unknown policy", Hook{Stage: StageAfter, Command: []string{"true"}, OnFailure: "retry"}, `unknown on_failure "retry"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New([]Hook{tt.hook}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package ipinteltest provides a configurable fake Provider so that code
+// built on the providers, such as the aggregator or the batch runner, can
+// be unit-tested without real HTTP servers. The provider and model types
+// are internal, so the package is too: ipintel is a command, not a library.
+package ipinteltest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+// Response is a single canned outcome of a Check call.
+type Response struct {
+	Result  model.Geolocation
+	Err     error
+	Latency time.Duration
+}
+
+// Provider is a fake provider.Provider. Responses are chosen in this order:
+// the next scripted response, the canned response for the queried IP, and
+// finally the default response. It is safe for concurrent use.
+type Provider struct {
+	name string
+
+	mu       sync.Mutex
+	fallback Response
+	byIP     map[model.IPAddress]Response
+	script   []Response
+	calls    []model.IPAddress
+}
+
+var _ provider.Provider = &Provider{}
+
+// Option configures a fake Provider.
+type Option func(*Provider)
+
+// WithResult sets the default geolocation returned for any IP.
+func WithResult(geo model.Geolocation) Option {
+	return func(p *Provider) {
+		p.fallback.Result = geo
+		p.fallback.Err = nil
+	}
+}
+
+// WithError makes every unscripted Check fail with err.
+func WithError(err error) Option {
+	return func(p *Provider) {
+		p.fallback.Err = err
+	}
+}
+
+// WithLatency delays every unscripted Check by d, honouring context cancellation.
+func WithLatency(d time.Duration) Option {
+	return func(p *Provider) {
+		p.fallback.Latency = d
+	}
+}
+
+// WithResultFor sets the response returned for a specific IP.
+func WithResultFor(ip model.IPAddress, resp Response) Option {
+	return func(p *Provider) {
+		p.byIP[ip] = resp
+	}
+}
+
+// WithScript queues responses that are returned, in order, by the next
+// calls to Check before falling back to the canned responses.
+func WithScript(responses ...Response) Option {
+	return func(p *Provider) {
+		p.script = append(p.script, responses...)
+	}
+}
+
+// NewProvider creates a fake provider with the given name.
+func NewProvider(name string, opts ...Option) *Provider {
+	p := &Provider{
+		name: name,
+		byIP: make(map[model.IPAddress]Response),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Check records the call and returns the configured response.
+func (p *Provider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	resp := p.next(ip)
+
+	if resp.Latency > 0 {
+		timer := time.NewTimer(resp.Latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return model.Geolocation{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return model.Geolocation{}, err
+	}
+
+	if resp.Err != nil {
+		return model.Geolocation{}, resp.Err
+	}
+
+	geo := resp.Result
+	if !geo.IP.IsValid() {
+		geo.IP = ip
+	}
+
+	return geo, nil
+}
+
+func (p *Provider) next(ip model.IPAddress) Response {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, ip)
+
+	if len(p.script) > 0 {
+		resp := p.script[0]
+		p.script = p.script[1:]
+		return resp
+	}
+
+	if resp, ok := p.byIP[ip]; ok {
+		return resp
+	}
+
+	return p.fallback
+}
+
+// Calls returns the IPs passed to Check, in call order.
+func (p *Provider) Calls() []model.IPAddress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	calls := make([]model.IPAddress, len(p.calls))
+	copy(calls, p.calls)
+	return calls
+}
+
+// CallCount returns the number of times Check has been called.
+func (p *Provider) CallCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.calls)
+}
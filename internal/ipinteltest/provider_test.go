@@ -0,0 +1,119 @@
+package ipinteltest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestProvider_DefaultResult(t *testing.T) {
+	p := NewProvider("fake", WithResult(model.Geolocation{Country: "United States"}))
+	ip := model.MustParseAddr("8.8.8.8")
+
+	geo, err := p.Check(context.Background(), ip)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.Country != "United States" {
+		t.Errorf("Country = %q, want United States", geo.Country)
+	}
+
+	if geo.IP != ip {
+		t.Errorf("IP = %v, want %v", geo.IP, ip)
+	}
+
+	if p.Name() != "fake" {
+		t.Errorf("Name() = %q, want fake", p.Name())
+	}
+}
+
+func TestProvider_Error(t *testing.T) {
+	wantErr := errors.New("rate limited")
+	p := NewProvider("fake", WithError(wantErr))
+
+	_, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Check() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestProvider_ResultFor(t *testing.T) {
+	v4 := model.MustParseAddr("1.1.1.1")
+	p := NewProvider("fake",
+		WithResult(model.Geolocation{Country: "Default"}),
+		WithResultFor(v4, Response{Result: model.Geolocation{Country: "Australia"}}),
+	)
+
+	geo, _ := p.Check(context.Background(), v4)
+	if geo.Country != "Australia" {
+		t.Errorf("Country = %q, want Australia", geo.Country)
+	}
+
+	geo, _ = p.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if geo.Country != "Default" {
+		t.Errorf("Country = %q, want Default", geo.Country)
+	}
+}
+
+func TestProvider_Script(t *testing.T) {
+	p := NewProvider("fake",
+		WithResult(model.Geolocation{Country: "Canned"}),
+		WithScript(
+			Response{Err: errors.New("first fails")},
+			Response{Result: model.Geolocation{Country: "Second"}},
+		),
+	)
+	ip := model.MustParseAddr("8.8.8.8")
+
+	if _, err := p.Check(context.Background(), ip); err == nil {
+		t.Error("first Check() expected scripted error")
+	}
+
+	wants := []string{"Second", "Canned"}
+	for _, want := range wants {
+		geo, err := p.Check(context.Background(), ip)
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		if geo.Country != want {
+			t.Errorf("Country = %q, want %q", geo.Country, want)
+		}
+	}
+
+	if p.CallCount() != 3 {
+		t.Errorf("CallCount() = %d, want 3", p.CallCount())
+	}
+
+	if calls := p.Calls(); len(calls) != 3 || calls[0] != ip {
+		t.Errorf("Calls() = %v, want 3 calls for %v", calls, ip)
+	}
+}
+
+func TestProvider_Latency(t *testing.T) {
+	p := NewProvider("fake", WithLatency(50*time.Millisecond))
+
+	start := time.Now()
+	if _, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Check() returned after %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestProvider_LatencyContextCancellation(t *testing.T) {
+	p := NewProvider("fake", WithLatency(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := p.Check(ctx, model.MustParseAddr("8.8.8.8"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,76 @@
+package latency
+
+import (
+	"math"
+	"time"
+)
+
+// bucketGrowth is the ratio between the bounds of successive buckets of a
+// Histogram, and so the relative error of its percentiles.
+const bucketGrowth = 1.05
+
+// Histogram counts latencies in buckets growing by 5%, from 1ms, so that
+// the percentiles of any number of latencies are estimated within 5% in
+// constant memory. Estimates are kept within the smallest and largest
+// latency observed, so that identical latencies are reported exactly. The
+// zero Histogram is empty and ready to use; it is not safe for concurrent
+// use.
+type Histogram struct {
+	buckets  []int
+	count    int
+	min, max time.Duration
+}
+
+// bucket returns the index of the bucket of d: 0 up to 1ms, then i for
+// latencies up to 1ms times bucketGrowth to the power i.
+func bucket(d time.Duration) int {
+	ms := float64(d) / float64(time.Millisecond)
+	if ms <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(ms) / math.Log(bucketGrowth)))
+}
+
+// upperBound returns the largest latency of bucket i.
+func upperBound(i int) time.Duration {
+	return time.Duration(math.Pow(bucketGrowth, float64(i)) * float64(time.Millisecond))
+}
+
+// Observe records the latency d.
+func (h *Histogram) Observe(d time.Duration) {
+	i := bucket(d)
+	if i >= len(h.buckets) {
+		h.buckets = append(h.buckets, make([]int, i+1-len(h.buckets))...)
+	}
+	h.buckets[i]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if h.count == 0 || d > h.max {
+		h.max = d
+	}
+	h.count++
+}
+
+// Count returns the number of latencies observed.
+func (h *Histogram) Count() int {
+	return h.count
+}
+
+// Percentile returns the estimated p-th percentile of the latencies
+// observed, for p from 0 to 100, or 0 if there are none.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := max(int(math.Ceil(p/100*float64(h.count))), 1)
+	seen := 0
+	for i, n := range h.buckets {
+		if seen += n; seen >= rank {
+			return min(max(upperBound(i), h.min), h.max)
+		}
+	}
+	return h.max
+}
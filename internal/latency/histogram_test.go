@@ -0,0 +1,47 @@
+package latency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogram_Percentile(t *testing.T) {
+	var h Histogram
+	if got := h.Percentile(50); got != 0 {
+		t.Errorf("Percentile() of an empty histogram = %v, want 0", got)
+	}
+
+	for i := 1; i <= 1000; i++ {
+		h.Observe(time.Duration(i) * time.Millisecond)
+	}
+	if h.Count() != 1000 {
+		t.Errorf("Count() = %d, want 1000", h.Count())
+	}
+
+	for _, p := range []float64{50, 90, 99} {
+		want := time.Duration(p*10) * time.Millisecond
+		got := h.Percentile(p)
+		if got < want || float64(got) > bucketGrowth*float64(want) {
+			t.Errorf("Percentile(%g) = %v, want %v within 5%%", p, got, want)
+		}
+	}
+	if got := h.Percentile(100); got != time.Second {
+		t.Errorf("Percentile(100) = %v, want the largest latency", got)
+	}
+}
+
+func TestHistogram_Exact(t *testing.T) {
+	var h Histogram
+	for range 5 {
+		h.Observe(183 * time.Millisecond)
+	}
+	h.Observe(500 * time.Microsecond)
+
+	if got := h.Percentile(90); got != 183*time.Millisecond {
+		t.Errorf("Percentile(90) = %v, want 183ms", got)
+	}
+	// Latencies up to 1ms share a bucket
+	if got := h.Percentile(1); got != time.Millisecond {
+		t.Errorf("Percentile(1) = %v, want 1ms", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Package latency keeps a history of provider latencies across runs and
+// derives a timeout for each provider from it, so that fast providers are
+// not given as long as the slowest one.
+package latency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSamples is the number of most recent latencies kept per provider.
+	maxSamples = 200
+
+	// MinSamples is the number of latencies needed before a timeout is
+	// derived for a provider.
+	MinSamples = 20
+
+	// Factor multiplies the 99th percentile latency into the timeout.
+	Factor = 1.5
+
+	// MinTimeout is the shortest timeout derived, so that a provider that
+	// has always been fast still gets room for the odd slow answer.
+	MinTimeout = time.Second
+)
+
+// Store is the latency history of each provider, by name. It is safe for
+// concurrent use.
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	dirty   bool
+}
+
+// file is the on-disk format of a Store, with latencies in milliseconds.
+type file struct {
+	Providers map[string][]int64 `json:"providers"`
+}
+
+// DefaultPath returns the default location of the history inside the
+// user's cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ipintel", "latency.json"), nil
+}
+
+// Load reads the history at path. A missing file yields an empty Store,
+// created by the first Save.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, samples: make(map[string][]time.Duration)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing latency history %s: %w", path, err)
+	}
+	for name, ms := range f.Providers {
+		samples := make([]time.Duration, len(ms))
+		for i, v := range ms {
+			samples[i] = time.Duration(v) * time.Millisecond
+		}
+		s.samples[name] = samples
+	}
+
+	return s, nil
+}
+
+// Observe records the latency of a successful check of the named provider.
+func (s *Store) Observe(name string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := append(s.samples[name], d)
+	if len(samples) > maxSamples {
+		samples = samples[len(samples)-maxSamples:]
+	}
+	s.samples[name] = samples
+	s.dirty = true
+}
+
+// Timeout returns the timeout derived for the named provider: Factor times
+// its 99th percentile latency, and at least MinTimeout. It reports false
+// while fewer than MinSamples latencies are known.
+func (s *Store) Timeout(name string) (time.Duration, bool) {
+	s.mu.Lock()
+	samples := append([]time.Duration(nil), s.samples[name]...)
+	s.mu.Unlock()
+
+	if len(samples) < MinSamples {
+		return 0, false
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	p99 := samples[int(math.Ceil(0.99*float64(len(samples))))-1]
+
+	return max(time.Duration(Factor*float64(p99)), MinTimeout), true
+}
+
+// Save writes the history back if anything was observed since it was loaded.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	f := file{Providers: make(map[string][]int64, len(s.samples))}
+	for name, samples := range s.samples {
+		ms := make([]int64, len(samples))
+		for i, d := range samples {
+			ms[i] = d.Milliseconds()
+		}
+		f.Providers[name] = ms
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
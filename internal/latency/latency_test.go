@@ -0,0 +1,93 @@
+package latency
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_Timeout(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "latency.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for i := 1; i < MinSamples; i++ {
+		s.Observe("fast", 100*time.Millisecond)
+	}
+	if _, ok := s.Timeout("fast"); ok {
+		t.Errorf("Timeout() should not be derived from %d samples", MinSamples-1)
+	}
+
+	s.Observe("fast", 100*time.Millisecond)
+	if got, ok := s.Timeout("fast"); !ok || got != MinTimeout {
+		t.Errorf("Timeout() = %v, %v; want the %v floor", got, ok, MinTimeout)
+	}
+
+	// 99 answers in 2s and one outlier: the 99th percentile ignores it
+	for i := 0; i < 99; i++ {
+		s.Observe("slow", 2*time.Second)
+	}
+	s.Observe("slow", 30*time.Second)
+	if got, _ := s.Timeout("slow"); got != 3*time.Second {
+		t.Errorf("Timeout() = %v, want 1.5 × 2s", got)
+	}
+
+	if _, ok := s.Timeout("unknown"); ok {
+		t.Error("Timeout() of an unknown provider should not be derived")
+	}
+}
+
+func TestStore_KeepsRecentSamples(t *testing.T) {
+	s, _ := Load(filepath.Join(t.TempDir(), "latency.json"))
+
+	for i := 0; i < maxSamples; i++ {
+		s.Observe("p", 10*time.Second)
+	}
+	for i := 0; i < maxSamples; i++ {
+		s.Observe("p", time.Second)
+	}
+
+	if got, _ := s.Timeout("p"); got != 1500*time.Millisecond {
+		t.Errorf("Timeout() = %v, want only the recent samples to count", got)
+	}
+}
+
+func TestStore_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipintel", "latency.json")
+
+	s, _ := Load(path)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Save() without observations should not write anything")
+	}
+
+	for i := 0; i < MinSamples; i++ {
+		s.Observe("ip-api", 2*time.Second)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got, ok := loaded.Timeout("ip-api"); !ok || got != 3*time.Second {
+		t.Errorf("Timeout() after reload = %v, %v; want 3s", got, ok)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latency.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load() expected error for invalid JSON")
+	}
+}
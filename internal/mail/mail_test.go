@@ -0,0 +1,148 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is an SMTP server without TLS recording the commands and the
+// message it receives, which rejects the recipients of reject.
+type fakeSMTP struct {
+	listener net.Listener
+	reject   string
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: l}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 fake ESMTP")
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch {
+		case verb == "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case verb == "AUTH":
+			reply("235 ok")
+		case verb == "RCPT" && s.reject != "" && strings.Contains(line, s.reject):
+			reply("550 no such user")
+		case verb == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 queued")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTP) server() Server {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return Server{Host: "127.0.0.1", Port: addr.Port}
+}
+
+func TestSend(t *testing.T) {
+	s := newFakeSMTP(t)
+	server := s.server()
+	server.Username, server.Password = "reports", "secret"
+
+	msg := "Subject: test\r\n\r\nHello\r\n"
+	if err := Send(server, "ipintel@example.com", []string{"a@example.com", "b@example.com"}, []byte(msg)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00reports\x00secret"))
+	for _, want := range []string{auth, "MAIL FROM:<ipintel@example.com>", "RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>"} {
+		found := false
+		for _, c := range s.commands {
+			found = found || strings.HasPrefix(c, want)
+		}
+		if !found {
+			t.Errorf("commands = %q, want %q", s.commands, want)
+		}
+	}
+	if s.data != msg {
+		t.Errorf("message = %q, want %q", s.data, msg)
+	}
+}
+
+func TestSend_Rejected(t *testing.T) {
+	s := newFakeSMTP(t)
+	s.reject = "nobody@"
+
+	err := Send(s.server(), "ipintel@example.com", []string{"a@example.com", "nobody@example.com"}, []byte("\r\n"))
+	if err == nil || !strings.Contains(err.Error(), "nobody@example.com") || !strings.Contains(err.Error(), "550") {
+		t.Errorf("Send() error = %v, want the recipient rejected", err)
+	}
+}
+
+func TestSend_Unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	if err := Send(Server{Host: "127.0.0.1", Port: port}, "a@example.com", []string{"b@example.com"}, nil); err == nil {
+		t.Error("Send() to a closed port expected error")
+	}
+}
@@ -0,0 +1,323 @@
+package model
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// The binary encoding of a report is a compact alternative to JSON for
+// message queues. It starts with binaryVersion and the fingerprint of the
+// layout of Report, followed by the exported fields of Report in
+// declaration order, and then its consensus options:
+//
+//   - booleans are one byte, integers varints and floats 8 bytes
+//   - strings and byte slices are prefixed with their length
+//   - pointers, slices and maps are prefixed with a presence byte, or
+//     their length plus one, so that nil survives a round trip
+//   - map entries are sorted by key
+//   - types with their own binary form, such as IPAddress and time.Time,
+//     are encoded as such, prefixed with its length
+//
+// Fields are identified by position rather than by name, so reports only
+// decode with the layout they were encoded with: the fingerprint changes
+// with any field added, removed, renamed or retyped, and a report of
+// another layout is rejected rather than misread.
+const binaryVersion = 1
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	// reportLayout is the fingerprint of the encoded form of reports
+	reportLayout = layoutFingerprint(reflect.TypeOf(binaryReport{}))
+)
+
+// binaryReport is the encoded form of a Report, whose consensus options are
+// unexported.
+type binaryReport struct {
+	Report           reportFields
+	ConsensusOptions ConsensusOptions
+}
+
+// reportFields has the fields of Report but not its methods, which would
+// otherwise encode it recursively.
+type reportFields Report
+
+// errInvalidBinary is returned for binary reports that are truncated or
+// hold out of range values.
+var errInvalidBinary = errors.New("invalid binary report")
+
+// MarshalBinary implements encoding.BinaryMarshaler. The consensus options
+// are kept; the cached consensus is not, since it is computed again on
+// demand.
+func (r Report) MarshalBinary() ([]byte, error) {
+	buf := []byte{binaryVersion}
+	buf = binary.BigEndian.AppendUint32(buf, reportLayout)
+
+	v := binaryReport{Report: reportFields(r), ConsensusOptions: r.consensusOptions}
+	return appendBinary(buf, reflect.ValueOf(v))
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, decoding a report
+// encoded by MarshalBinary with the same layout.
+func (r *Report) UnmarshalBinary(data []byte) error {
+	if len(data) < 5 {
+		return errInvalidBinary
+	}
+	if data[0] != binaryVersion {
+		return fmt.Errorf("unsupported binary report version %d", data[0])
+	}
+	if binary.BigEndian.Uint32(data[1:5]) != reportLayout {
+		return errors.New("binary report encoded with another report layout")
+	}
+
+	var v binaryReport
+	rest, err := readBinary(data[5:], reflect.ValueOf(&v).Elem())
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d trailing bytes after binary report", len(rest))
+	}
+
+	*r = Report(v.Report)
+	r.SetConsensusOptions(v.ConsensusOptions)
+	return nil
+}
+
+// appendBinary appends the encoding of v to buf.
+func appendBinary(buf []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+	if marshalsItself(t) {
+		data, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+		return append(buf, data...), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		buf = binary.AppendUvarint(buf, uint64(v.Len()))
+		return append(buf, v.String()...), nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendBinary(append(buf, 1), v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+		if t.Elem().Kind() == reflect.Uint8 {
+			return append(buf, v.Bytes()...), nil
+		}
+		var err error
+		for i := 0; i < v.Len() && err == nil; i++ {
+			buf, err = appendBinary(buf, v.Index(i))
+		}
+		return buf, err
+	case reflect.Map:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		var err error
+		for _, k := range keys {
+			if buf, err = appendBinary(buf, k); err != nil {
+				return nil, err
+			}
+			if buf, err = appendBinary(buf, v.MapIndex(k)); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < t.NumField() && err == nil; i++ {
+			if t.Field(i).IsExported() {
+				buf, err = appendBinary(buf, v.Field(i))
+			}
+		}
+		return buf, err
+	default:
+		return nil, fmt.Errorf("cannot encode %s in a binary report", t)
+	}
+}
+
+// readBinary decodes data into v, which must be settable, and returns the
+// bytes left over.
+func readBinary(data []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+	if marshalsItself(t) {
+		data, b, err := readBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		return data, v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if len(data) < 1 {
+			return nil, errInvalidBinary
+		}
+		v.SetBool(data[0] != 0)
+		return data[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, size := binary.Varint(data)
+		if size <= 0 || v.OverflowInt(n) {
+			return nil, errInvalidBinary
+		}
+		v.SetInt(n)
+		return data[size:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, size := binary.Uvarint(data)
+		if size <= 0 || v.OverflowUint(n) {
+			return nil, errInvalidBinary
+		}
+		v.SetUint(n)
+		return data[size:], nil
+	case reflect.Float32, reflect.Float64:
+		if len(data) < 8 {
+			return nil, errInvalidBinary
+		}
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		return data[8:], nil
+	case reflect.String:
+		data, b, err := readBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		v.SetString(string(b))
+		return data, nil
+	case reflect.Pointer:
+		if len(data) < 1 {
+			return nil, errInvalidBinary
+		}
+		if data[0] == 0 {
+			return data[1:], nil
+		}
+		v.Set(reflect.New(t.Elem()))
+		return readBinary(data[1:], v.Elem())
+	case reflect.Slice:
+		data, n, err := readLength(data)
+		if err != nil || n < 0 {
+			return data, err
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte{}, data[:n]...))
+			return data[n:], nil
+		}
+		v.Set(reflect.MakeSlice(t, n, n))
+		for i := 0; i < n && err == nil; i++ {
+			data, err = readBinary(data, v.Index(i))
+		}
+		return data, err
+	case reflect.Map:
+		data, n, err := readLength(data)
+		if err != nil || n < 0 {
+			return data, err
+		}
+		v.Set(reflect.MakeMapWithSize(t, n))
+		for i := 0; i < n; i++ {
+			k, e := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+			if data, err = readBinary(data, k); err != nil {
+				return nil, err
+			}
+			if data, err = readBinary(data, e); err != nil {
+				return nil, err
+			}
+			v.SetMapIndex(k, e)
+		}
+		return data, nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < t.NumField() && err == nil; i++ {
+			if t.Field(i).IsExported() {
+				data, err = readBinary(data, v.Field(i))
+			}
+		}
+		return data, err
+	default:
+		return nil, fmt.Errorf("cannot decode %s from a binary report", t)
+	}
+}
+
+// marshalsItself reports whether values of t have a binary form of their
+// own. Pointers to such values are encoded as pointers, so that nil
+// survives a round trip.
+func marshalsItself(t reflect.Type) bool {
+	return t.Kind() != reflect.Pointer && t.Implements(binaryMarshalerType) && reflect.PointerTo(t).Implements(binaryUnmarshalerType)
+}
+
+// readBytes reads a length-prefixed byte string.
+func readBytes(data []byte) (rest, b []byte, err error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return nil, nil, errInvalidBinary
+	}
+	data = data[size:]
+	return data[n:], data[:n], nil
+}
+
+// readLength reads the length of a slice or map, or -1 if it is nil. The
+// length is bounded by the bytes left, since every element takes at least
+// one, so that corrupt input cannot cause huge allocations.
+func readLength(data []byte) (rest []byte, n int, err error) {
+	u, size := binary.Uvarint(data)
+	if size <= 0 || u > uint64(len(data)-size)+1 {
+		return nil, 0, errInvalidBinary
+	}
+	return data[size:], int(u) - 1, nil
+}
+
+// layoutFingerprint hashes the names and types of the fields encoded for
+// t, recursively.
+func layoutFingerprint(t reflect.Type) uint32 {
+	h := fnv.New32a()
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		_, _ = fmt.Fprintf(h, "%s(", t.Kind())
+		switch {
+		case marshalsItself(t):
+			_, _ = h.Write([]byte(t.String()))
+		case t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice:
+			walk(t.Elem())
+		case t.Kind() == reflect.Map:
+			walk(t.Key())
+			walk(t.Elem())
+		case t.Kind() == reflect.Struct:
+			for i := 0; i < t.NumField(); i++ {
+				if f := t.Field(i); f.IsExported() {
+					_, _ = fmt.Fprintf(h, "%s:", f.Name)
+					walk(f.Type)
+				}
+			}
+		}
+		_, _ = h.Write([]byte(")"))
+	}
+	walk(t)
+	return h.Sum32()
+}
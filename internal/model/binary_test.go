@@ -0,0 +1,134 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// binaryTestReport returns a report setting every kind of field.
+func binaryTestReport() Report {
+	ip := MustParseAddr("2002:c000:204::1")
+	prefix := MustParsePrefix("8.8.8.0/24")
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := Report{
+		IP:        ip,
+		Timestamp: start,
+		Results: []ProviderResult{
+			{
+				Provider: "ipinfo",
+				Result: &Geolocation{
+					IP: ip, Country: "United States", CountryCode: "US", City: "Mountain View",
+					Latitude: Float64(37.386), Longitude: Float64(0),
+					ASN: "AS15169", Prefix: &prefix,
+					Security:     &Security{VPN: true, Service: "Example VPN"},
+					Registration: &Registration{Network: "8.8.8.0/24"},
+					Reputation:   &Reputation{Score: 12.5, Categories: []string{}},
+				},
+				Duration: 183 * time.Millisecond,
+				Start:    start,
+				Quota:    &Quota{Limit: 1000, Remaining: -1, ResetIn: time.Hour},
+			},
+			{Provider: "ipwhois", Error: "timeout", Shadow: true, Comparison: &Comparison{CountryMatch: new(bool)}},
+			{Provider: "bogon", Error: "not a special-use address", Skipped: true},
+		},
+		IsAnycast:     true,
+		Transition:    &Transition{Mechanism: "6to4", IPv4: MustParseAddr("192.0.2.4")},
+		TotalDuration: 190 * time.Millisecond,
+		Meta:          &Meta{Version: "1.2.3", Providers: []string{"ipinfo", "ipwhois"}, Timeout: 5 * time.Second},
+		Input:         Fields{{Name: "user", Value: json.RawMessage(`"alice"`)}},
+		Policy:        &PolicyDecision{Action: "review", Rule: "vpn"},
+	}
+	r.SetConsensusOptions(ConsensusOptions{
+		MinAgreement: 0.5,
+		Risk:         RiskOptions{Weights: map[string]float64{"ipinfo": 2, "abuseipdb": 0.5}},
+	})
+	return r
+}
+
+func TestReport_BinaryRoundTrip(t *testing.T) {
+	r := binaryTestReport()
+
+	data, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	var got Report
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() error = %v", err)
+	}
+
+	// Neither consensus is computed yet, so their caches compare equal
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", got, r)
+	}
+	if !reflect.DeepEqual(got.ConsensusOptions(), r.ConsensusOptions()) {
+		t.Errorf("ConsensusOptions() = %+v, want %+v", got.ConsensusOptions(), r.ConsensusOptions())
+	}
+	if got.Results[1].Result != nil || got.Results[0].Result.Reputation.Categories == nil {
+		t.Error("UnmarshalBinary() did not keep nil and empty values apart")
+	}
+
+	want, _ := json.Marshal(r)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(gotJSON, want) {
+		t.Errorf("JSON of the decoded report = %s, want %s", gotJSON, want)
+	}
+
+	again, _ := got.MarshalBinary()
+	if !bytes.Equal(again, data) {
+		t.Error("MarshalBinary() of the decoded report differs")
+	}
+
+	if len(data) >= len(want) {
+		t.Errorf("binary report is %d bytes, JSON %d", len(data), len(want))
+	}
+}
+
+func TestReport_UnmarshalBinary_Invalid(t *testing.T) {
+	data, err := binaryTestReport().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() error = %v", err)
+	}
+
+	// Every truncation must fail cleanly
+	for n := range len(data) {
+		var r Report
+		if err := r.UnmarshalBinary(data[:n]); err == nil {
+			t.Fatalf("UnmarshalBinary() of %d of %d bytes expected error", n, len(data))
+		}
+	}
+
+	var r Report
+	if err := r.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("UnmarshalBinary() with a trailing byte expected error")
+	}
+
+	other := bytes.Clone(data)
+	other[4] ^= 1
+	if err := r.UnmarshalBinary(other); err == nil || err.Error() != "binary report encoded with another report layout" {
+		t.Errorf("UnmarshalBinary() of another layout error = %v", err)
+	}
+}
+
+func FuzzReport_UnmarshalBinary(f *testing.F) {
+	data, _ := binaryTestReport().MarshalBinary()
+	f.Add(data)
+	empty, _ := Report{}.MarshalBinary()
+	f.Add(empty)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var r Report
+		if err := r.UnmarshalBinary(data); err != nil {
+			return
+		}
+
+		// Whatever decodes must encode back
+		if _, err := r.MarshalBinary(); err != nil {
+			t.Errorf("MarshalBinary() of a decoded report error = %v", err)
+		}
+	})
+}
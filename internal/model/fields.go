@@ -0,0 +1,63 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Field is a named value carried through a lookup unchanged, such as a
+// column of batch input that accompanied the address.
+type Field struct {
+	Name  string
+	Value json.RawMessage
+}
+
+// Fields is an ordered list of passthrough fields. It marshals as a JSON
+// object, keeping the order in which the fields were read.
+type Fields []Field
+
+// Get returns the value of the named field.
+func (f Fields) Get(name string) (json.RawMessage, bool) {
+	for _, field := range f {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f Fields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range f {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+
+		if len(field.Value) == 0 {
+			buf.WriteString("null")
+			continue
+		}
+		buf.Write(field.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Text returns the value of a field as plain text: strings are unquoted and
+// any other JSON value is returned as written.
+func (f Field) Text() string {
+	var s string
+	if err := json.Unmarshal(f.Value, &s); err == nil {
+		return s
+	}
+	return string(f.Value)
+}
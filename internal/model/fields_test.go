@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFields_MarshalJSON_KeepsOrder(t *testing.T) {
+	fields := Fields{
+		{Name: "zeta", Value: json.RawMessage(`1`)},
+		{Name: "alpha", Value: json.RawMessage(`{"b":2,"a":1}`)},
+		{Name: "empty"},
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"zeta":1,"alpha":{"b":2,"a":1},"empty":null}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
+
+func TestField_Text(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`"alice"`, "alice"},
+		{`42`, "42"},
+		{`[1,2]`, "[1,2]"},
+		{``, ""},
+	}
+
+	for _, tt := range tests {
+		if got := (Field{Value: json.RawMessage(tt.value)}).Text(); got != tt.want {
+			t.Errorf("Text(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "math"
+
 // Geolocation represents the geographic and network information
 // associated with an IP address. This is the normalised result type
 // that all checkers map their responses to.
@@ -7,27 +9,106 @@ type Geolocation struct {
 	IP IPAddress `json:"ip"`
 
 	// Geographic information
-	Country     string  `json:"country"`
-	CountryCode string  `json:"country_code"`
-	Region      string  `json:"region"`
-	City        string  `json:"city"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+	Region      string `json:"region"`
+	City        string `json:"city"`
+
+	// Language of the place names above when the provider localized them,
+	// e.g. "de"; empty for the provider's default, usually English
+	Language string `json:"language,omitempty"`
+
+	// Coordinates are nil when the provider did not report them, since
+	// 0, 0 is a valid location
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
 
 	// Network information
-	ISP string `json:"isp"`
-	Org string `json:"org"`
-	ASN string `json:"asn"`
+	ISP      string `json:"isp"`
+	Org      string `json:"org"`
+	ASN      string `json:"asn"`
+	Hostname string `json:"hostname"` // reverse DNS (PTR) name
+
+	// Prefix is the BGP prefix announcing the address, e.g. "8.8.8.0/24";
+	// it is nil when the provider did not report one
+	Prefix *Prefix `json:"prefix,omitempty"`
+
+	// Extended information, only reported by some providers or plans
+	Security     *Security     `json:"security,omitempty"`
+	Registration *Registration `json:"registration,omitempty"`
+	SpecialUse   *SpecialUse   `json:"special_use,omitempty"`
+	Reputation   *Reputation   `json:"reputation,omitempty"`
+
+	// Granularity is the most precise level of location kept in a
+	// consensus. Provider results leave it empty.
+	Granularity Granularity `json:"granularity,omitempty"`
+}
+
+// Granularity is the precision of a location.
+type Granularity string
+
+const (
+	GranularityCity    Granularity = "city"
+	GranularityRegion  Granularity = "region"
+	GranularityCountry Granularity = "country"
+)
+
+// granularity returns the most precise level of location set in g, or ""
+// when it has no location.
+func (g Geolocation) granularity() Granularity {
+	switch {
+	case g.City != "":
+		return GranularityCity
+	case g.Region != "":
+		return GranularityRegion
+	case g.Country != "" || g.CountryCode != "":
+		return GranularityCountry
+	default:
+		return ""
+	}
 }
 
-// HasLocation reports whether the geolocation has valid coordinates.
+// HasLocation reports whether the geolocation has both coordinates.
 func (g Geolocation) HasLocation() bool {
-	return g.Latitude != 0 || g.Longitude != 0
+	return g.Latitude != nil && g.Longitude != nil
+}
+
+// Coordinates returns the latitude and longitude, and whether both are set.
+func (g Geolocation) Coordinates() (lat, lon float64, ok bool) {
+	if !g.HasLocation() {
+		return 0, 0, false
+	}
+	return *g.Latitude, *g.Longitude, true
+}
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// DistanceKm returns the great-circle distance between the coordinates of
+// a and b, in kilometres, and whether both have coordinates.
+func DistanceKm(a, b Geolocation) (float64, bool) {
+	lat1, lon1, ok1 := a.Coordinates()
+	lat2, lon2, ok2 := b.Coordinates()
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(min(1, h))), true
+}
+
+// Float64 returns a pointer to v, for setting optional numeric fields.
+func Float64(v float64) *float64 {
+	return &v
 }
 
 // HasNetworkInfo reports whether the geolocation has any network information.
 func (g Geolocation) HasNetworkInfo() bool {
-	return g.ISP != "" || g.Org != "" || g.ASN != ""
+	return g.ISP != "" || g.Org != "" || g.ASN != "" || g.Hostname != "" || g.Prefix != nil
 }
 
 func (g Geolocation) IsEmpty() bool {
@@ -35,9 +116,11 @@ func (g Geolocation) IsEmpty() bool {
 		g.CountryCode == "" &&
 		g.Region == "" &&
 		g.City == "" &&
-		g.Latitude == 0 &&
-		g.Longitude == 0 &&
+		g.Latitude == nil &&
+		g.Longitude == nil &&
 		g.ISP == "" &&
 		g.Org == "" &&
-		g.ASN == ""
+		g.ASN == "" &&
+		g.Hostname == "" &&
+		g.Prefix == nil
 }
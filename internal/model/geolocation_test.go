@@ -2,6 +2,8 @@ package model
 
 import (
 	"encoding/json"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -13,18 +15,18 @@ func TestGeolocation_HasLocation(t *testing.T) {
 	}{
 		{
 			name: "has latitude and longitude",
-			geo:  Geolocation{Latitude: 37.7749, Longitude: -122.4194},
+			geo:  Geolocation{Latitude: Float64(37.7749), Longitude: Float64(-122.4194)},
 			want: true,
 		},
 		{
 			name: "has only latitude",
-			geo:  Geolocation{Latitude: 37.7749},
-			want: true,
+			geo:  Geolocation{Latitude: Float64(37.7749)},
+			want: false,
 		},
 		{
 			name: "has only longitude",
-			geo:  Geolocation{Longitude: -122.4194},
-			want: true,
+			geo:  Geolocation{Longitude: Float64(-122.4194)},
+			want: false,
 		},
 		{
 			name: "zero coordinates",
@@ -32,9 +34,9 @@ func TestGeolocation_HasLocation(t *testing.T) {
 			want: false,
 		},
 		{
-			name: "explicit zero coordinates",
-			geo:  Geolocation{Latitude: 0, Longitude: 0},
-			want: false,
+			name: "null island",
+			geo:  Geolocation{Latitude: Float64(0), Longitude: Float64(0)},
+			want: true,
 		},
 	}
 
@@ -117,7 +119,7 @@ func TestGeolocation_IsEmpty(t *testing.T) {
 		},
 		{
 			name: "has coordinates",
-			geo:  Geolocation{Latitude: 1.0},
+			geo:  Geolocation{Latitude: Float64(1.0)},
 			want: false,
 		},
 		{
@@ -133,8 +135,8 @@ func TestGeolocation_IsEmpty(t *testing.T) {
 				CountryCode: "US",
 				Region:      "California",
 				City:        "Mountain View",
-				Latitude:    37.386,
-				Longitude:   -122.084,
+				Latitude:    Float64(37.386),
+				Longitude:   Float64(-122.084),
 				ISP:         "Google LLC",
 				Org:         "Google",
 				ASN:         "AS15169",
@@ -159,8 +161,8 @@ func TestGeolocation_JSONMarshal(t *testing.T) {
 		CountryCode: "US",
 		Region:      "California",
 		City:        "Mountain View",
-		Latitude:    37.386,
-		Longitude:   -122.084,
+		Latitude:    Float64(37.386),
+		Longitude:   Float64(-122.084),
 		ISP:         "Google LLC",
 		Org:         "Google",
 		ASN:         "AS15169",
@@ -223,11 +225,8 @@ func TestGeolocation_JSONUnmarshal(t *testing.T) {
 	if geo.City != "Mountain View" {
 		t.Errorf("City = %v, want Mountain View", geo.City)
 	}
-	if geo.Latitude != 37.386 {
-		t.Errorf("Latitude = %v, want 37.386", geo.Latitude)
-	}
-	if geo.Longitude != -122.084 {
-		t.Errorf("Longitude = %v, want -122.084", geo.Longitude)
+	if lat, lon, ok := geo.Coordinates(); !ok || lat != 37.386 || lon != -122.084 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37.386, -122.084", lat, lon, ok)
 	}
 	if geo.ISP != "Google LLC" {
 		t.Errorf("ISP = %v, want Google LLC", geo.ISP)
@@ -247,8 +246,8 @@ func TestGeolocation_JSONRoundTrip(t *testing.T) {
 		CountryCode: "US",
 		Region:      "California",
 		City:        "Mountain View",
-		Latitude:    37.386,
-		Longitude:   -122.084,
+		Latitude:    Float64(37.386),
+		Longitude:   Float64(-122.084),
 		ISP:         "Google LLC",
 		Org:         "Google",
 		ASN:         "AS15169",
@@ -280,11 +279,9 @@ func TestGeolocation_JSONRoundTrip(t *testing.T) {
 	if original.City != decoded.City {
 		t.Errorf("City mismatch: got %v, want %v", decoded.City, original.City)
 	}
-	if original.Latitude != decoded.Latitude {
-		t.Errorf("Latitude mismatch: got %v, want %v", decoded.Latitude, original.Latitude)
-	}
-	if original.Longitude != decoded.Longitude {
-		t.Errorf("Longitude mismatch: got %v, want %v", decoded.Longitude, original.Longitude)
+	lat, lon, _ := original.Coordinates()
+	if gotLat, gotLon, ok := decoded.Coordinates(); !ok || gotLat != lat || gotLon != lon {
+		t.Errorf("Coordinates mismatch: got %v, %v (%v), want %v, %v", gotLat, gotLon, ok, lat, lon)
 	}
 	if original.ISP != decoded.ISP {
 		t.Errorf("ISP mismatch: got %v, want %v", decoded.ISP, original.ISP)
@@ -297,6 +294,26 @@ func TestGeolocation_JSONRoundTrip(t *testing.T) {
 	}
 }
 
+func TestGeolocation_JSONNullIsland(t *testing.T) {
+	geo := Geolocation{Latitude: Float64(0), Longitude: Float64(0)}
+
+	data, err := json.Marshal(geo)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"latitude":0,"longitude":0`) {
+		t.Errorf("JSON = %s, want zero coordinates to be written", data)
+	}
+
+	data, err = json.Marshal(Geolocation{})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"latitude":null,"longitude":null`) {
+		t.Errorf("JSON = %s, want absent coordinates to be null", data)
+	}
+}
+
 func TestGeolocation_JSONEmptyValues(t *testing.T) {
 	// Test that empty/zero values serialize correctly
 	geo := Geolocation{
@@ -318,7 +335,36 @@ func TestGeolocation_JSONEmptyValues(t *testing.T) {
 	if decoded.City != "" {
 		t.Errorf("City should be empty, got %q", decoded.City)
 	}
-	if decoded.Latitude != 0 {
-		t.Errorf("Latitude should be 0, got %v", decoded.Latitude)
+	if decoded.Latitude != nil {
+		t.Errorf("Latitude should be absent, got %v", *decoded.Latitude)
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	paris := Geolocation{Latitude: Float64(48.8566), Longitude: Float64(2.3522)}
+	london := Geolocation{Latitude: Float64(51.5074), Longitude: Float64(-0.1278)}
+
+	d, ok := DistanceKm(paris, london)
+	if !ok || math.Abs(d-343.5) > 1 {
+		t.Errorf("DistanceKm(Paris, London) = %v, %v, want about 343.5 km", d, ok)
+	}
+	if d, ok := DistanceKm(paris, paris); !ok || d != 0 {
+		t.Errorf("DistanceKm(Paris, Paris) = %v, %v, want 0", d, ok)
+	}
+	if _, ok := DistanceKm(paris, Geolocation{}); ok {
+		t.Error("DistanceKm() without coordinates should not be ok")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	g := Geolocation{CountryCode: "US", City: "mountain view", ASN: "AS15169"}
+	ref := Geolocation{CountryCode: "US", City: "Mountain View", Region: "California", ASN: "AS15169"}
+
+	c := Compare(g, ref)
+	if c.CountryMatch == nil || !*c.CountryMatch || c.CityMatch == nil || !*c.CityMatch {
+		t.Errorf("Compare() = %+v, want country and city matching", c)
+	}
+	if c.RegionMatch != nil || c.DistanceKm != nil {
+		t.Errorf("Compare() = %+v, want no region or distance without values on both sides", c)
 	}
 }
@@ -1,6 +1,11 @@
 package model
 
-import "net/netip"
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/netip"
+)
 
 type IPAddress = netip.Addr
 
@@ -11,3 +16,86 @@ func ParseAddr(ipAddr string) (IPAddress, error) {
 func MustParseAddr(ipAddr string) IPAddress {
 	return netip.MustParseAddr(ipAddr)
 }
+
+// Prefix is an IP network in CIDR notation, e.g. "8.8.8.0/24". Like
+// IPAddress, it marshals to and from its text form in JSON.
+type Prefix = netip.Prefix
+
+func ParsePrefix(prefix string) (Prefix, error) {
+	return netip.ParsePrefix(prefix)
+}
+
+func MustParsePrefix(prefix string) Prefix {
+	return netip.MustParsePrefix(prefix)
+}
+
+// Ptr returns a pointer to ip, for setting optional address fields.
+func Ptr(ip IPAddress) *IPAddress {
+	return &ip
+}
+
+// SQLAddress stores an IPAddress in a database column, which IPAddress
+// cannot do itself since it is an alias of netip.Addr. It is written as
+// text, which suits the inet type of Postgres as well as character
+// columns, and scanned from text or from the 4 or 16 bytes of a binary
+// column, such as MySQL's INET6_ATON. A null column scans as the zero
+// address, which is written back as null.
+//
+//	var ip model.IPAddress
+//	err := row.Scan((*model.SQLAddress)(&ip))
+//	_, err = db.Exec("INSERT INTO hits (ip) VALUES ($1)", model.SQLAddress(ip))
+type SQLAddress IPAddress
+
+var (
+	_ driver.Valuer = SQLAddress{}
+	_ sql.Scanner   = (*SQLAddress)(nil)
+)
+
+// Value implements driver.Valuer.
+func (a SQLAddress) Value() (driver.Value, error) {
+	ip := IPAddress(a)
+	if !ip.IsValid() {
+		return nil, nil
+	}
+	return ip.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (a *SQLAddress) Scan(src any) error {
+	var text []byte
+	switch v := src.(type) {
+	case nil:
+		*a = SQLAddress{}
+		return nil
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return fmt.Errorf("cannot scan %T into an IP address", src)
+	}
+
+	ip, err := parseColumn(text)
+	if err != nil {
+		return err
+	}
+	*a = SQLAddress(ip)
+	return nil
+}
+
+// parseColumn parses the value of an address column: an address, a host
+// prefix as Postgres renders inet values with a mask, e.g.
+// "192.0.2.1/24", or else the raw bytes of the address.
+func parseColumn(b []byte) (IPAddress, error) {
+	var ip IPAddress
+	if err := ip.UnmarshalText(b); err == nil {
+		return ip, nil
+	}
+	if prefix, err := netip.ParsePrefix(string(b)); err == nil {
+		return prefix.Addr(), nil
+	}
+	if ip, ok := netip.AddrFromSlice(b); ok {
+		return ip, nil
+	}
+	return IPAddress{}, fmt.Errorf("invalid IP address %q", b)
+}
@@ -164,3 +164,76 @@ func TestIPAddress_JSONInStruct(t *testing.T) {
 		t.Errorf("Name mismatch: got %v, want %v", decoded.Name, original.Name)
 	}
 }
+
+func TestSQLAddress_Scan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  any
+		want string
+	}{
+		{name: "text", src: "2001:db8::1", want: "2001:db8::1"},
+		{name: "bytes", src: []byte("192.0.2.1"), want: "192.0.2.1"},
+		{name: "inet with mask", src: "192.0.2.1/24", want: "192.0.2.1"},
+		{name: "binary IPv4", src: []byte{192, 0, 2, 1}, want: "192.0.2.1"},
+		{name: "binary IPv6", src: MustParseAddr("2001:db8::1").AsSlice(), want: "2001:db8::1"},
+		{name: "null", src: nil, want: "invalid IP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip := MustParseAddr("8.8.8.8")
+			if err := (*SQLAddress)(&ip).Scan(tt.src); err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if ip.String() != tt.want {
+				t.Errorf("Scan() = %v, want %v", ip, tt.want)
+			}
+		})
+	}
+
+	var ip IPAddress
+	for _, src := range []any{"not an address", []byte{1, 2, 3}, int64(42)} {
+		if err := (*SQLAddress)(&ip).Scan(src); err == nil {
+			t.Errorf("Scan(%v) expected error", src)
+		}
+	}
+}
+
+func TestSQLAddress_Value(t *testing.T) {
+	v, err := SQLAddress(MustParseAddr("2001:db8::1")).Value()
+	if err != nil || v != "2001:db8::1" {
+		t.Errorf("Value() = %v, %v, want 2001:db8::1", v, err)
+	}
+
+	if v, err := (SQLAddress{}).Value(); err != nil || v != nil {
+		t.Errorf("Value() of the zero address = %v, %v, want nil", v, err)
+	}
+}
+
+func TestPtr(t *testing.T) {
+	ip := MustParseAddr("192.0.2.1")
+	p := Ptr(ip)
+	if *p != ip {
+		t.Errorf("*Ptr() = %v, want %v", *p, ip)
+	}
+}
+
+func FuzzParseAddr(f *testing.F) {
+	for _, s := range []string{"8.8.8.8", "2001:4860:4860::8888", "::ffff:1.2.3.4", "fe80::1%eth0", "1.2.3", "", "01.02.03.04"} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		ip, err := ParseAddr(s)
+		if err != nil {
+			return
+		}
+
+		// Every address that parses must survive a round trip through its
+		// text form, which is what reports and checkpoints store
+		again, err := ParseAddr(ip.String())
+		if err != nil || again != ip {
+			t.Errorf("ParseAddr(%q) = %v, but ParseAddr(%q) = %v, %v", s, ip, ip.String(), again, err)
+		}
+	})
+}
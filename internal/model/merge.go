@@ -0,0 +1,101 @@
+package model
+
+// MergePolicy decides which value Geolocation.Merge keeps for a field set
+// on both sides.
+type MergePolicy int
+
+const (
+	// MergePreferLeft keeps every field of the receiver, filling only
+	// those it lacks from the other geolocation
+	MergePreferLeft MergePolicy = iota
+
+	// MergePreferNonEmpty takes every field the other geolocation sets,
+	// keeping those of the receiver elsewhere, as when applying a newer
+	// answer over an older one
+	MergePreferNonEmpty
+
+	// MergePreferConsensus keeps the fields of the receiver, usually a
+	// consensus, like MergePreferLeft, but only fills its gaps from a
+	// geolocation that does not contradict it: the region, city and
+	// coordinates only from the same country, region and city, and the
+	// ISP, organization, hostname and prefix only from the same AS, so
+	// that a merge never yields a place that does not exist
+	MergePreferConsensus
+)
+
+// Merge combines g with other field by field, as decided by policy, and
+// returns the result. The coordinates are merged as a pair, and the
+// language follows the country name. Extended sections are taken whole
+// from the receiver when set, or else from other, and are shared rather
+// than copied.
+func (g Geolocation) Merge(other Geolocation, policy MergePolicy) Geolocation {
+	if policy == MergePreferNonEmpty {
+		// Other wins wherever it is set: fill it from g
+		g, other = other, g
+	}
+
+	// Which gaps other may fill
+	country, place, network := true, true, true
+	if policy == MergePreferConsensus {
+		country = !contradicts(g.CountryCode, other.CountryCode)
+		// A region from a source placing the address in another city is
+		// not trusted either
+		place = country && !contradicts(g.Region, other.Region) && !contradicts(g.City, other.City)
+		network = !contradicts(g.ASN, other.ASN)
+	}
+
+	fill := func(dst *string, src string, ok bool) {
+		if *dst == "" && ok {
+			*dst = src
+		}
+	}
+
+	if !g.IP.IsValid() {
+		g.IP = other.IP
+	}
+
+	if g.Country == "" && country {
+		g.Country, g.Language = other.Country, other.Language
+	}
+	fill(&g.CountryCode, other.CountryCode, country)
+	fill(&g.Region, other.Region, place)
+	fill(&g.City, other.City, place)
+	if !g.HasLocation() && other.HasLocation() && place {
+		g.Latitude, g.Longitude = other.Latitude, other.Longitude
+	}
+
+	fill(&g.ASN, other.ASN, true)
+	fill(&g.ISP, other.ISP, network)
+	fill(&g.Org, other.Org, network)
+	fill(&g.Hostname, other.Hostname, network)
+	if g.Prefix == nil && network {
+		g.Prefix = other.Prefix
+	}
+
+	if g.Security == nil {
+		g.Security = other.Security
+	}
+	if g.Registration == nil {
+		g.Registration = other.Registration
+	}
+	if g.SpecialUse == nil {
+		g.SpecialUse = other.SpecialUse
+	}
+	if g.Reputation == nil {
+		g.Reputation = other.Reputation
+	}
+
+	// The granularity of a consensus follows the fields it now has
+	if g.Granularity != "" || other.Granularity != "" {
+		g.Granularity = g.granularity()
+	}
+
+	return g
+}
+
+// contradicts reports whether a and b are both set and differ, ignoring
+// case.
+func contradicts(a, b string) bool {
+	equal := match(a, b)
+	return equal != nil && !*equal
+}
@@ -0,0 +1,62 @@
+package model
+
+import "testing"
+
+func TestGeolocation_Merge(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	prefix := MustParsePrefix("8.8.8.0/24")
+	left := Geolocation{
+		IP: ip, Country: "Deutschland", Language: "de", CountryCode: "DE", City: "Berlin",
+		ASN: "AS3320",
+	}
+	right := Geolocation{
+		IP: ip, Country: "Germany", CountryCode: "DE", Region: "Bavaria", City: "Munich",
+		Latitude: Float64(48.1), Longitude: Float64(11.6),
+		ISP: "Deutsche Telekom AG", ASN: "AS3320", Prefix: &prefix,
+		Security: &Security{VPN: true},
+	}
+
+	got := left.Merge(right, MergePreferLeft)
+	if got.Country != "Deutschland" || got.Language != "de" || got.City != "Berlin" || got.Region != "Bavaria" {
+		t.Errorf("Merge(MergePreferLeft) place = %q (%s), %q, %q", got.Country, got.Language, got.Region, got.City)
+	}
+	if !got.HasLocation() || got.ISP != "Deutsche Telekom AG" || got.Prefix != &prefix || got.Security != right.Security {
+		t.Errorf("Merge(MergePreferLeft) did not fill the gaps: %+v", got)
+	}
+
+	got = left.Merge(right, MergePreferNonEmpty)
+	if got.Country != "Germany" || got.Language != "" || got.City != "Munich" || got.Region != "Bavaria" {
+		t.Errorf("Merge(MergePreferNonEmpty) place = %q (%s), %q, %q", got.Country, got.Language, got.Region, got.City)
+	}
+
+	// Neither the region nor the coordinates of Munich apply to Berlin
+	got = left.Merge(right, MergePreferConsensus)
+	if got.City != "Berlin" || got.Region != "" || got.HasLocation() {
+		t.Errorf("Merge(MergePreferConsensus) = %q, %q, coordinates %v", got.Region, got.City, got.HasLocation())
+	}
+	if got.ISP != "Deutsche Telekom AG" || got.Prefix == nil {
+		t.Errorf("Merge(MergePreferConsensus) did not fill the network of the same AS: %+v", got)
+	}
+
+	// Nothing of another country or AS is taken
+	elsewhere := Geolocation{CountryCode: "AT", Region: "Vienna", ASN: "AS8447", ISP: "A1 Telekom", Security: &Security{Tor: true}}
+	got = Geolocation{CountryCode: "DE", ASN: "AS3320"}.Merge(elsewhere, MergePreferConsensus)
+	if got.Region != "" || got.ISP != "" {
+		t.Errorf("Merge(MergePreferConsensus) took from a contradicting source: %+v", got)
+	}
+	if got.Security == nil {
+		t.Error("Merge(MergePreferConsensus) did not fill the extended sections")
+	}
+}
+
+func TestGeolocation_Merge_Granularity(t *testing.T) {
+	consensus := Geolocation{CountryCode: "US", Granularity: GranularityCountry}
+	got := consensus.Merge(Geolocation{CountryCode: "us", Region: "California"}, MergePreferConsensus)
+	if got.Granularity != GranularityRegion {
+		t.Errorf("Granularity = %q, want %q", got.Granularity, GranularityRegion)
+	}
+
+	if got := (Geolocation{}).Merge(Geolocation{City: "Paris"}, MergePreferLeft); got.Granularity != "" {
+		t.Errorf("Granularity of merged provider results = %q, want none", got.Granularity)
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Quota is the rate-limit state a provider reported with its response.
+type Quota struct {
+	// Limit is the number of requests allowed per window, if reported
+	Limit int `json:"limit,omitempty"`
+
+	// Remaining is the number of requests left in the current window
+	Remaining int `json:"remaining"`
+
+	// ResetIn is how long until the window resets
+	ResetIn time.Duration `json:"-"`
+}
+
+// MarshalJSON implements custom JSON marshalling to output the reset time as milliseconds.
+func (q Quota) MarshalJSON() ([]byte, error) {
+	type Alias Quota
+	return json.Marshal(struct {
+		Alias
+		ResetIn int64 `json:"reset_in_ms"`
+	}{
+		Alias:   Alias(q),
+		ResetIn: q.ResetIn.Milliseconds(),
+	})
+}
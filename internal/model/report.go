@@ -2,6 +2,8 @@ package model
 
 import (
 	"encoding/json"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,6 +14,39 @@ type ProviderResult struct {
 	Result   *Geolocation  `json:"result,omitempty"`
 	Error    string        `json:"error,omitempty"`
 	Duration time.Duration `json:"-"`
+	Quota    *Quota        `json:"quota,omitempty"`
+
+	// Timeout is the phase of the request a failed lookup timed out in,
+	// "connect" when establishing the connection or "read" when waiting
+	// for the response; it is empty for other failures
+	Timeout string `json:"timeout,omitempty"`
+
+	// Start is when the provider was queried; it is zero when it was not
+	Start time.Time `json:"-"`
+
+	// Timing details Start and Duration; it is only set in detailed
+	// timing output, see Report.DetailedTiming
+	Timing *ProviderTiming `json:"timing,omitempty"`
+
+	// Skipped is set when the provider was not queried because enough
+	// other providers had already answered, or had nothing to report for
+	// the address by design. Error then holds the reason.
+	Skipped bool `json:"skipped,omitempty"`
+
+	// Shadow is set for the providers queried for evaluation only: their
+	// results are left out of the consensus, the risk and the counts of
+	// the report, and compared with the consensus instead
+	Shadow bool `json:"shadow,omitempty"`
+
+	// Advisory is set for the providers whose answers only advise those of
+	// the others, such as an offline country table: their results are left
+	// out of the counts of the report, and only vote on the country to
+	// break a tie between the others; text output flags a disagreement
+	Advisory bool `json:"advisory,omitempty"`
+
+	// Comparison compares a successful shadow result with the consensus;
+	// see Report.CompareShadows
+	Comparison *Comparison `json:"comparison,omitempty"`
 }
 
 // Success reports whether this provider lookup succeeded.
@@ -19,6 +54,12 @@ func (pr ProviderResult) Success() bool {
 	return pr.Error == "" && pr.Result != nil
 }
 
+// counted reports whether the result counts towards those of the report,
+// as the results of shadow and advisory providers do not.
+func (pr ProviderResult) counted() bool {
+	return !pr.Shadow && !pr.Advisory
+}
+
 // MarshalJSON implements custom JSON marshalling to output duration as milliseconds.
 func (pr ProviderResult) MarshalJSON() ([]byte, error) {
 	type Alias ProviderResult
@@ -31,6 +72,77 @@ func (pr ProviderResult) MarshalJSON() ([]byte, error) {
 	})
 }
 
+// ConsensusMajority is the consensus strategy implemented by Report.Consensus:
+// majority voting for text fields and averaging for coordinates.
+const ConsensusMajority = "majority"
+
+// ConsensusNone is the consensus strategy of reports whose consensus is
+// disabled; see ConsensusOptions.Disabled.
+const ConsensusNone = "none"
+
+// ConsensusOptions tunes how Report.Consensus combines provider results.
+type ConsensusOptions struct {
+	// MinAgreement is the share of the providers reporting a city that
+	// must agree on the winning one for it to be kept, from 0 to 1. Below
+	// it, the consensus falls back to the region, and to the country when
+	// the regions disagree as well, dropping the averaged coordinates, so
+	// that conflicting data never yields a misleadingly precise location.
+	// Zero disables the fallback.
+	MinAgreement float64
+
+	// Language is the language place names were requested in, e.g. "de".
+	// Providers that cannot localize still answer in English, so the
+	// country is voted on by country code rather than by name, and named
+	// after a result localized in Language when there is one.
+	Language string
+
+	// Risk tunes how the reputation scores of the providers are combined;
+	// see Report.Risk.
+	Risk RiskOptions
+
+	// Disabled skips the consensus, for callers using the provider results
+	// alone: Consensus returns an empty geolocation, which costs nothing,
+	// and shadow results are not compared.
+	Disabled bool
+}
+
+// Meta describes how a Report was produced, so that archived reports are
+// self-describing and reproducible.
+type Meta struct {
+	// Version of the tool that produced the report
+	Version string `json:"version"`
+
+	// Providers that were enabled for the lookup
+	Providers []string `json:"providers"`
+
+	// ConsensusStrategy used to compute the consensus
+	ConsensusStrategy string `json:"consensus_strategy"`
+
+	// CacheHits is the number of provider results served from cache
+	CacheHits int `json:"cache_hits"`
+
+	// Timeout applied to each provider lookup
+	Timeout time.Duration `json:"-"`
+
+	// MinAgreement is the ConsensusOptions.MinAgreement used, if any
+	MinAgreement float64 `json:"min_agreement,omitempty"`
+
+	// Language place names were requested in, if any
+	Language string `json:"language,omitempty"`
+}
+
+// MarshalJSON implements custom JSON marshalling to output the timeout as milliseconds.
+func (m Meta) MarshalJSON() ([]byte, error) {
+	type Alias Meta
+	return json.Marshal(struct {
+		Alias
+		Timeout int64 `json:"timeout_ms"`
+	}{
+		Alias:   Alias(m),
+		Timeout: m.Timeout.Milliseconds(),
+	})
+}
+
 // Report is the aggregated result of querying multiple providers
 // for information about an IP address.
 type Report struct {
@@ -43,102 +155,329 @@ type Report struct {
 	// Results from each provider
 	Results []ProviderResult `json:"results"`
 
+	// IsAnycast is set when the address is in a well-known anycast prefix,
+	// so its geolocation only describes one of many sites
+	IsAnycast bool `json:"is_anycast"`
+
+	// Transition is set when the address embeds an IPv4 address
+	Transition *Transition `json:"transition,omitempty"`
+
 	// TotalDuration is how long the entire lookup took
 	TotalDuration time.Duration `json:"-"`
+
+	// Meta describes how the report was produced
+	Meta *Meta `json:"meta,omitempty"`
+
+	// Input holds the fields that accompanied the address in batch input,
+	// passed through unchanged
+	Input Fields `json:"input,omitempty"`
+
+	// Policy is the decision of the configured policy rules, if any
+	Policy *PolicyDecision `json:"policy,omitempty"`
+
+	// Timing details Timestamp and TotalDuration; it is only set in
+	// detailed timing output, see DetailedTiming
+	Timing *Timing `json:"timing,omitempty"`
+
+	// consensus caches the result of Consensus once Recompute has been
+	// called. It is shared by copies of the report and never serialized.
+	consensus *consensusCache
+
+	// consensusOptions tune Consensus; see SetConsensusOptions.
+	consensusOptions ConsensusOptions
+}
+
+// PolicyDecision is the outcome of evaluating a report against the
+// configured policy rules.
+type PolicyDecision struct {
+	// Action is "allow", "review" or "block"
+	Action string `json:"action"`
+
+	// Rule names the rule that matched; it is empty when none did
+	Rule string `json:"rule,omitempty"`
+}
+
+// consensusCache holds a consensus computed at most once.
+type consensusCache struct {
+	once  sync.Once
+	value Geolocation
 }
 
 // MarshalJSON implements custom JSON marshalling for Report.
 func (r Report) MarshalJSON() ([]byte, error) {
 	type Alias Report
+	// The granularity is only meaningful when the consensus may fall back
+	var granularity Granularity
+	if r.consensusOptions.MinAgreement > 0 {
+		granularity = r.Consensus().Granularity
+	}
+
 	return json.Marshal(struct {
 		Alias
-		TotalDuration int64 `json:"total_duration_ms"`
+		TotalDuration int64            `json:"total_duration_ms"`
+		Quota         map[string]Quota `json:"quota,omitempty"`
+		Granularity   Granularity      `json:"granularity,omitempty"`
+		Risk          *Risk            `json:"risk,omitempty"`
 	}{
 		Alias:         Alias(r),
 		TotalDuration: r.TotalDuration.Milliseconds(),
+		Quota:         r.Quota(),
+		Granularity:   granularity,
+		Risk:          r.Risk(),
 	})
 }
 
+// Quota returns the rate-limit state reported by each provider, by name.
+// Providers that did not report one are omitted.
+func (r Report) Quota() map[string]Quota {
+	var quota map[string]Quota
+	for _, pr := range r.Results {
+		if pr.Quota == nil {
+			continue
+		}
+		if quota == nil {
+			quota = make(map[string]Quota)
+		}
+		quota[pr.Provider] = *pr.Quota
+	}
+	return quota
+}
+
 // SuccessCount returns the number of providers that returned successfully.
+// Shadow and advisory providers are not counted.
 func (r Report) SuccessCount() int {
 	count := 0
 	for _, pr := range r.Results {
-		if pr.Success() {
+		if pr.Success() && pr.counted() {
+			count++
+		}
+	}
+	return count
+}
+
+// SkippedCount returns the number of providers that were skipped. Shadow
+// and advisory providers are not counted.
+func (r Report) SkippedCount() int {
+	count := 0
+	for _, pr := range r.Results {
+		if pr.Skipped && pr.counted() {
 			count++
 		}
 	}
 	return count
 }
 
-// ErrorCount returns the number of providers that failed.
+// ErrorCount returns the number of providers that failed. Skipped, shadow
+// and advisory providers are not counted.
 func (r Report) ErrorCount() int {
-	return len(r.Results) - r.SuccessCount()
+	count := 0
+	for _, pr := range r.Results {
+		if !pr.Success() && !pr.Skipped && pr.counted() {
+			count++
+		}
+	}
+	return count
+}
+
+// AllFailed reports whether providers were queried and every one of them
+// failed. Shadow and advisory providers do not count.
+func (r Report) AllFailed() bool {
+	return r.SuccessCount() == 0 && r.SkippedCount()+r.ErrorCount() > 0
+}
+
+// AdvisoryCount returns the number of advisory providers.
+func (r Report) AdvisoryCount() int {
+	count := 0
+	for _, pr := range r.Results {
+		if pr.Advisory {
+			count++
+		}
+	}
+	return count
+}
+
+// ShadowCount returns the number of shadow providers.
+func (r Report) ShadowCount() int {
+	count := 0
+	for _, pr := range r.Results {
+		if pr.Shadow {
+			count++
+		}
+	}
+	return count
 }
 
-// SuccessfulResults returns only the successful provider results.
+// SuccessfulResults returns only the successful provider results, leaving
+// out those of shadow and advisory providers.
 func (r Report) SuccessfulResults() []ProviderResult {
 	results := make([]ProviderResult, 0, len(r.Results))
 	for _, pr := range r.Results {
-		if pr.Success() {
+		if pr.Success() && pr.counted() {
 			results = append(results, pr)
 		}
 	}
 	return results
 }
 
+// For returns the outcome of the named provider, and whether it was among
+// the providers of the lookup.
+func (r Report) For(name string) (ProviderResult, bool) {
+	for _, pr := range r.Results {
+		if pr.Provider == name {
+			return pr, true
+		}
+	}
+	return ProviderResult{}, false
+}
+
+// Result returns the geolocation the named provider answered, and whether
+// it answered successfully.
+func (r Report) Result(name string) (Geolocation, bool) {
+	pr, ok := r.For(name)
+	if !ok || !pr.Success() {
+		return Geolocation{}, false
+	}
+	return *pr.Result, true
+}
+
+// ProviderError is the failure of a provider lookup.
+type ProviderError struct {
+	Provider string
+	Message  string
+}
+
+func (e ProviderError) Error() string {
+	return e.Provider + ": " + e.Message
+}
+
+// Errors returns the failures of the providers, in provider order. Like
+// ErrorCount, it leaves out skipped, shadow and advisory providers.
+func (r Report) Errors() []ProviderError {
+	var errs []ProviderError
+	for _, pr := range r.Results {
+		if !pr.Success() && !pr.Skipped && pr.counted() {
+			errs = append(errs, ProviderError{Provider: pr.Provider, Message: pr.Error})
+		}
+	}
+	return errs
+}
+
+// Providers returns the names of the providers of the lookup, in provider
+// order, including skipped and shadow ones.
+func (r Report) Providers() []string {
+	names := make([]string, len(r.Results))
+	for i, pr := range r.Results {
+		names[i] = pr.Provider
+	}
+	return names
+}
+
 // Consensus returns the most commonly agreed-upon values across providers.
 // This is useful when providers return slightly different data.
+//
+// After Recompute has been called, the consensus is computed on first use
+// and cached, so later calls are cheap; otherwise it is computed on every
+// call. It is empty when disabled by ConsensusOptions.Disabled.
 func (r Report) Consensus() Geolocation {
+	if r.consensusOptions.Disabled {
+		return Geolocation{}
+	}
+	if r.consensus == nil {
+		return r.computeConsensus()
+	}
+
+	r.consensus.once.Do(func() {
+		r.consensus.value = r.computeConsensus()
+	})
+	return r.consensus.value
+}
+
+// Recompute discards the cached consensus. It is computed again from the
+// current results on the next call to Consensus and cached from then on,
+// in r and in copies of r made afterwards. Call it whenever IP or Results
+// change.
+func (r *Report) Recompute() {
+	r.consensus = &consensusCache{}
+}
+
+// SetConsensusOptions changes how the consensus is computed and discards
+// the cached one.
+func (r *Report) SetConsensusOptions(opts ConsensusOptions) {
+	r.consensusOptions = opts
+	r.Recompute()
+}
+
+// ConsensusOptions returns the options the consensus is computed with.
+func (r Report) ConsensusOptions() ConsensusOptions {
+	return r.consensusOptions
+}
+
+func (r Report) computeConsensus() Geolocation {
 	successful := r.SuccessfulResults()
 	if len(successful) == 0 {
 		return Geolocation{IP: r.IP}
 	}
 
 	// For simplicity, we use voting for string fields
-	// and averaging for numeric fields
-	countryVotes := make(map[string]int)
-	countryCodeVotes := make(map[string]int)
-	cityVotes := make(map[string]int)
-	regionVotes := make(map[string]int)
-	ispVotes := make(map[string]int)
-	orgVotes := make(map[string]int)
-	asnVotes := make(map[string]int)
+	// and averaging for numeric fields. A field gets at most one vote per
+	// result, so the tallies of all fields share a single allocation.
+	n := len(successful)
+	backing := make(tally, 9*n)
+	field := func(i int) tally { return backing[i*n : i*n : (i+1)*n] }
+	countryVotes, countryCodeVotes := field(0), field(1)
+	cityVotes, regionVotes := field(2), field(3)
+	ispVotes, orgVotes := field(4), field(5)
+	asnVotes, hostnameVotes := field(6), field(7)
+	prefixVotes := field(8)
 
 	var latSum, lonSum float64
 	var coordCount int
 
+	var extended Geolocation
+
 	for _, pr := range successful {
 		if pr.Result == nil {
 			continue
 		}
 		g := pr.Result
 
-		if g.Country != "" {
-			countryVotes[g.Country]++
+		countryVotes.add(g.Country)
+		countryCodeVotes.add(g.CountryCode)
+		cityVotes.add(g.City)
+		regionVotes.add(g.Region)
+		ispVotes.add(g.ISP)
+		orgVotes.add(g.Org)
+		asnVotes.add(g.ASN)
+		hostnameVotes.add(g.Hostname)
+		if g.Prefix != nil && g.Prefix.IsValid() {
+			prefixVotes.add(g.Prefix.String())
 		}
-		if g.CountryCode != "" {
-			countryCodeVotes[g.CountryCode]++
-		}
-		if g.City != "" {
-			cityVotes[g.City]++
-		}
-		if g.Region != "" {
-			regionVotes[g.Region]++
+
+		if lat, lon, ok := g.Coordinates(); ok {
+			latSum += lat
+			lonSum += lon
+			coordCount++
 		}
-		if g.ISP != "" {
-			ispVotes[g.ISP]++
+
+		// Extended sections are rarely reported by more than one provider,
+		// so the first one in provider order is used. Reputations are
+		// combined by Risk instead.
+		if g.Security != nil || g.Registration != nil || g.SpecialUse != nil {
+			sections := Geolocation{Security: g.Security, Registration: g.Registration, SpecialUse: g.SpecialUse}
+			extended = extended.Merge(sections, MergePreferLeft)
 		}
-		if g.Org != "" {
-			orgVotes[g.Org]++
+	}
+
+	// Advisory results only vote on the country, to break a tie
+	for _, pr := range r.Results {
+		if !pr.Advisory || !pr.Success() {
+			continue
 		}
-		if g.ASN != "" {
-			asnVotes[g.ASN]++
+		if tied(countryCodeVotes) {
+			countryCodeVotes.add(pr.Result.CountryCode)
 		}
-
-		if g.HasLocation() {
-			latSum += g.Latitude
-			lonSum += g.Longitude
-			coordCount++
+		if tied(countryVotes) {
+			countryVotes.add(pr.Result.Country)
 		}
 	}
 
@@ -151,26 +490,126 @@ func (r Report) Consensus() Geolocation {
 		ISP:         mostVoted(ispVotes),
 		Org:         mostVoted(orgVotes),
 		ASN:         mostVoted(asnVotes),
+		Hostname:    mostVoted(hostnameVotes),
+
+		Security:     extended.Security,
+		Registration: extended.Registration,
+		SpecialUse:   extended.SpecialUse,
+	}
+
+	if prefix, err := ParsePrefix(mostVoted(prefixVotes)); err == nil {
+		consensus.Prefix = &prefix
 	}
 
 	if coordCount > 0 {
-		consensus.Latitude = latSum / float64(coordCount)
-		consensus.Longitude = lonSum / float64(coordCount)
+		consensus.Latitude = Float64(latSum / float64(coordCount))
+		consensus.Longitude = Float64(lonSum / float64(coordCount))
+	}
+
+	if lang := r.consensusOptions.Language; lang != "" && consensus.CountryCode != "" {
+		consensus.Country = countryName(successful, consensus.CountryCode, lang)
+	}
+
+	if threshold := r.consensusOptions.MinAgreement; threshold > 0 && consensus.City != "" && agreement(cityVotes) < threshold {
+		consensus.City = ""
+		consensus.Latitude = nil
+		consensus.Longitude = nil
+
+		if consensus.Region != "" && agreement(regionVotes) < threshold {
+			consensus.Region = ""
+		}
 	}
+	consensus.Granularity = consensus.granularity()
 
 	return consensus
 }
 
-// mostVoted returns the key with the highest vote count.
+// countryName returns the name of the country with the given code, as
+// reported by the first result localized in lang, or else the name most
+// voted for by the results with that code.
+func countryName(results []ProviderResult, code, lang string) string {
+	var votes tally
+	for _, pr := range results {
+		g := pr.Result
+		if g == nil || g.CountryCode != code || g.Country == "" {
+			continue
+		}
+		if sameLanguage(g.Language, lang) {
+			return g.Country
+		}
+		votes.add(g.Country)
+	}
+	return mostVoted(votes)
+}
+
+// sameLanguage reports whether the language tags a and b share their
+// primary language subtag, so that "pt-BR" matches "pt".
+func sameLanguage(a, b string) bool {
+	a, _, _ = strings.Cut(a, "-")
+	b, _, _ = strings.Cut(b, "-")
+	return a != "" && strings.EqualFold(a, b)
+}
+
+// tally counts the votes cast for each value of a field. Results are few,
+// so a slice searched linearly is cheaper than a map.
+type tally []vote
+
+type vote struct {
+	value string
+	count int
+}
+
+// add casts a vote for value, unless it is empty.
+func (t *tally) add(value string) {
+	if value == "" {
+		return
+	}
+	for i := range *t {
+		if (*t)[i].value == value {
+			(*t)[i].count++
+			return
+		}
+	}
+	*t = append(*t, vote{value: value, count: 1})
+}
+
+// agreement returns the share of votes cast for the most voted value.
+func agreement(votes tally) float64 {
+	total, best := 0, 0
+	for _, v := range votes {
+		total += v.count
+		best = max(best, v.count)
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(best) / float64(total)
+}
+
+// tied reports whether several values share the highest vote count.
+func tied(votes tally) bool {
+	best, n := 0, 0
+	for _, v := range votes {
+		switch {
+		case v.count > best:
+			best, n = v.count, 1
+		case v.count == best:
+			n++
+		}
+	}
+	return n > 1
+}
+
+// mostVoted returns the value with the highest vote count.
 // In case of a tie, the result is deterministic but arbitrary.
-func mostVoted(votes map[string]int) string {
+func mostVoted(votes tally) string {
 	var best string
 	var bestCount int
 
-	for k, count := range votes {
-		if count > bestCount || (count == bestCount && k < best) {
-			best = k
-			bestCount = count
+	for _, v := range votes {
+		if v.count > bestCount || (v.count == bestCount && v.value < best) {
+			best = v.value
+			bestCount = v.count
 		}
 	}
 
@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -115,6 +116,27 @@ func TestReport_ErrorCount(t *testing.T) {
 	}
 }
 
+func TestReport_AllFailed(t *testing.T) {
+	tests := []struct {
+		name   string
+		report Report
+		want   bool
+	}{
+		{"no providers", Report{}, false},
+		{"all failed", Report{Results: []ProviderResult{{Error: "a"}, {Error: "b"}}}, true},
+		{"partial failure", Report{Results: []ProviderResult{{Error: "a"}, {Result: &Geolocation{}}}}, false},
+		{"only advisory succeeded", Report{Results: []ProviderResult{{Error: "a"}, {Result: &Geolocation{}, Advisory: true}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.AllFailed(); got != tt.want {
+				t.Errorf("AllFailed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestReport_SuccessfulResults(t *testing.T) {
 	report := Report{
 		IP: MustParseAddr("8.8.8.8"),
@@ -138,6 +160,44 @@ func TestReport_SuccessfulResults(t *testing.T) {
 	}
 }
 
+func TestReport_Lookups(t *testing.T) {
+	report := Report{
+		IP: MustParseAddr("8.8.8.8"),
+		Results: []ProviderResult{
+			{Provider: "ipinfo", Result: &Geolocation{Country: "US"}},
+			{Provider: "ipwhois", Error: "timeout"},
+			{Provider: "bogon", Error: "not a special-use address", Skipped: true},
+			{Provider: "ip-api", Error: "HTTP 429", Shadow: true},
+		},
+	}
+
+	if pr, ok := report.For("ipwhois"); !ok || pr.Error != "timeout" {
+		t.Errorf("For(ipwhois) = %+v, %v", pr, ok)
+	}
+	if _, ok := report.For("whois"); ok {
+		t.Error("For(whois) found a provider that was not queried")
+	}
+
+	if geo, ok := report.Result("ipinfo"); !ok || geo.Country != "US" {
+		t.Errorf("Result(ipinfo) = %+v, %v", geo, ok)
+	}
+	if _, ok := report.Result("ipwhois"); ok {
+		t.Error("Result(ipwhois) returned the result of a failed provider")
+	}
+
+	errs := report.Errors()
+	if len(errs) != 1 || errs[0] != (ProviderError{Provider: "ipwhois", Message: "timeout"}) {
+		t.Fatalf("Errors() = %+v, want the ipwhois timeout only", errs)
+	}
+	if errs[0].Error() != "ipwhois: timeout" {
+		t.Errorf("Error() = %q", errs[0].Error())
+	}
+
+	if got := strings.Join(report.Providers(), ","); got != "ipinfo,ipwhois,bogon,ip-api" {
+		t.Errorf("Providers() = %s", got)
+	}
+}
+
 func TestReport_Consensus_AllAgree(t *testing.T) {
 	ip := MustParseAddr("8.8.8.8")
 	report := Report{
@@ -148,7 +208,7 @@ func TestReport_Consensus_AllAgree(t *testing.T) {
 				Result: &Geolocation{
 					IP: ip, Country: "United States", CountryCode: "US",
 					City: "Mountain View", ISP: "Google",
-					Latitude: 37.0, Longitude: -122.0,
+					Latitude: Float64(37.0), Longitude: Float64(-122.0),
 				},
 			},
 			{
@@ -156,7 +216,7 @@ func TestReport_Consensus_AllAgree(t *testing.T) {
 				Result: &Geolocation{
 					IP: ip, Country: "United States", CountryCode: "US",
 					City: "Mountain View", ISP: "Google",
-					Latitude: 37.0, Longitude: -122.0,
+					Latitude: Float64(37.0), Longitude: Float64(-122.0),
 				},
 			},
 		},
@@ -176,11 +236,8 @@ func TestReport_Consensus_AllAgree(t *testing.T) {
 	if consensus.ISP != "Google" {
 		t.Errorf("ISP = %v, want Google", consensus.ISP)
 	}
-	if consensus.Latitude != 37.0 {
-		t.Errorf("Latitude = %v, want 37.0", consensus.Latitude)
-	}
-	if consensus.Longitude != -122.0 {
-		t.Errorf("Longitude = %v, want -122.0", consensus.Longitude)
+	if lat, lon, ok := consensus.Coordinates(); !ok || lat != 37.0 || lon != -122.0 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37.0, -122.0", lat, lon, ok)
 	}
 }
 
@@ -208,26 +265,350 @@ func TestReport_Consensus_Voting(t *testing.T) {
 	}
 }
 
+func TestReport_Consensus_Advisory(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	tests := []struct {
+		name     string
+		codes    []string
+		advisory string
+		want     string
+	}{
+		{"breaks a tie", []string{"US", "CA"}, "CA", "CA"},
+		{"outvoted", []string{"US", "US", "CA"}, "CA", "US"},
+		{"no other answer", nil, "CA", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report Report
+			for _, code := range tt.codes {
+				report.Results = append(report.Results, ProviderResult{Result: &Geolocation{IP: ip, CountryCode: code}})
+			}
+			report.Results = append(report.Results, ProviderResult{
+				Result:   &Geolocation{IP: ip, CountryCode: tt.advisory},
+				Advisory: true,
+			})
+
+			if got := report.Consensus().CountryCode; got != tt.want {
+				t.Errorf("CountryCode = %q, want %q", got, tt.want)
+			}
+			if got := report.SuccessCount(); got != len(tt.codes) {
+				t.Errorf("SuccessCount() = %d, want %d", got, len(tt.codes))
+			}
+		})
+	}
+}
+
+func TestReport_Consensus_GranularityFallback(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	result := func(name, region, city string) ProviderResult {
+		return ProviderResult{Provider: name, Result: &Geolocation{
+			IP: ip, Country: "United States", Region: region, City: city,
+			Latitude: Float64(37.4), Longitude: Float64(-122.1),
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		results     []ProviderResult
+		threshold   float64
+		wantCity    string
+		wantRegion  string
+		granularity Granularity
+	}{
+		{
+			name: "cities agree",
+			results: []ProviderResult{
+				result("a", "California", "Mountain View"),
+				result("b", "California", "Mountain View"),
+				result("c", "California", "San Jose"),
+			},
+			threshold:   0.6,
+			wantCity:    "Mountain View",
+			wantRegion:  "California",
+			granularity: GranularityCity,
+		},
+		{
+			name: "cities disagree",
+			results: []ProviderResult{
+				result("a", "California", "Mountain View"),
+				result("b", "California", "San Jose"),
+				result("c", "California", "Santa Clara"),
+			},
+			threshold:   0.6,
+			wantRegion:  "California",
+			granularity: GranularityRegion,
+		},
+		{
+			name: "regions disagree too",
+			results: []ProviderResult{
+				result("a", "California", "Mountain View"),
+				result("b", "Oregon", "Portland"),
+			},
+			threshold:   0.6,
+			granularity: GranularityCountry,
+		},
+		{
+			name: "disabled",
+			results: []ProviderResult{
+				result("a", "California", "Mountain View"),
+				result("b", "Oregon", "Portland"),
+			},
+			wantCity:    "Mountain View",
+			wantRegion:  "California",
+			granularity: GranularityCity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Report{IP: ip, Results: tt.results}
+			report.SetConsensusOptions(ConsensusOptions{MinAgreement: tt.threshold})
+
+			consensus := report.Consensus()
+			if consensus.City != tt.wantCity {
+				t.Errorf("City = %q, want %q", consensus.City, tt.wantCity)
+			}
+			if consensus.Region != tt.wantRegion {
+				t.Errorf("Region = %q, want %q", consensus.Region, tt.wantRegion)
+			}
+			if consensus.Country != "United States" {
+				t.Errorf("Country = %q, want United States", consensus.Country)
+			}
+			if consensus.Granularity != tt.granularity {
+				t.Errorf("Granularity = %q, want %q", consensus.Granularity, tt.granularity)
+			}
+			if hasLocation := consensus.HasLocation(); hasLocation != (tt.wantCity != "") {
+				t.Errorf("HasLocation() = %v, want coordinates only with a city", hasLocation)
+			}
+		})
+	}
+}
+
+func TestReport_Consensus_Language(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, Country: "United States", CountryCode: "US"}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Country: "United States of America", CountryCode: "US"}},
+			{Provider: "c", Result: &Geolocation{IP: ip, Country: "Vereinigte Staaten", CountryCode: "US", Language: "de"}},
+			{Provider: "d", Result: &Geolocation{IP: ip, Country: "United States", CountryCode: "US"}},
+		},
+	}
+
+	if got := report.Consensus().Country; got != "United States" {
+		t.Errorf("Country without language = %q, want United States", got)
+	}
+
+	report.SetConsensusOptions(ConsensusOptions{Language: "de-DE"})
+	if got := report.Consensus().Country; got != "Vereinigte Staaten" {
+		t.Errorf("Country in German = %q, want Vereinigte Staaten", got)
+	}
+
+	report.SetConsensusOptions(ConsensusOptions{Language: "fr"})
+	if got := report.Consensus().Country; got != "United States" {
+		t.Errorf("Country without French result = %q, want United States", got)
+	}
+}
+
+func TestReport_JSONMarshal_Granularity(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, Country: "United States", City: "Mountain View"}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Country: "United States", City: "San Jose"}},
+		},
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), `"granularity"`) {
+		t.Errorf("granularity should be omitted without a fallback: %s", data)
+	}
+
+	report.SetConsensusOptions(ConsensusOptions{MinAgreement: 0.75})
+	data, err = json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"granularity":"country"`) {
+		t.Errorf("granularity = country missing: %s", data)
+	}
+}
+
+func TestReport_Consensus_Cached(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, City: "Mountain View"}},
+		},
+	}
+
+	// Without Recompute every call sees the current results
+	report.Results[0].Result.City = "San Jose"
+	if got := report.Consensus().City; got != "San Jose" {
+		t.Errorf("uncached City = %v, want San Jose", got)
+	}
+
+	report.Recompute()
+	if got := report.Consensus().City; got != "San Jose" {
+		t.Errorf("City = %v, want San Jose", got)
+	}
+
+	// Copies share the cached value, which is stale until Recompute
+	cp := report
+	cp.Results[0].Result.City = "Mountain View"
+	if got := cp.Consensus().City; got != "San Jose" {
+		t.Errorf("cached City = %v, want San Jose", got)
+	}
+
+	cp.Recompute()
+	if got := cp.Consensus().City; got != "Mountain View" {
+		t.Errorf("recomputed City = %v, want Mountain View", got)
+	}
+}
+
+func TestReport_Consensus_Disabled(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, CountryCode: "US"}},
+			{Provider: "b", Result: &Geolocation{IP: ip, CountryCode: "DE"}, Shadow: true},
+		},
+	}
+	report.SetConsensusOptions(ConsensusOptions{Disabled: true})
+	report.CompareShadows()
+
+	if got := report.Consensus(); !got.IsEmpty() {
+		t.Errorf("Consensus() = %+v, want it empty", got)
+	}
+	if c := report.Results[1].Comparison; c != nil {
+		t.Errorf("shadow compared with a disabled consensus: %+v", c)
+	}
+}
+
+func TestReport_Consensus_CacheNotSerialized(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP:      ip,
+		Results: []ProviderResult{{Provider: "a", Result: &Geolocation{IP: ip, Country: "US"}}},
+	}
+
+	before, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	report.Recompute()
+	_ = report.Consensus()
+
+	after, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if string(before) != string(after) {
+		t.Errorf("caching changed the JSON:\nbefore %s\nafter  %s", before, after)
+	}
+}
+
+func BenchmarkReport_Consensus(b *testing.B) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{IP: ip}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		report.Results = append(report.Results, ProviderResult{
+			Provider: name,
+			Result:   &Geolocation{IP: ip, Country: "United States", City: name, Latitude: Float64(37.4), Longitude: Float64(-122.1)},
+		})
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = report.Consensus()
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		cached := report
+		cached.Recompute()
+		for i := 0; i < b.N; i++ {
+			_ = cached.Consensus()
+		}
+	})
+}
+
+func BenchmarkReport_MarshalJSON(b *testing.B) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP:        ip,
+		Timestamp: time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		Meta:      &Meta{Version: "1.0.0", Providers: []string{"a", "b", "c"}, ConsensusStrategy: ConsensusMajority},
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		report.Results = append(report.Results, ProviderResult{
+			Provider: name,
+			Result: &Geolocation{
+				IP: ip, Country: "United States", CountryCode: "US", Region: "California", City: "Mountain View",
+				Latitude: Float64(37.4), Longitude: Float64(-122.1), ISP: "Google LLC", Org: "Google LLC", ASN: "AS15169",
+			},
+			Duration: 120 * time.Millisecond,
+			Quota:    &Quota{Limit: 45, Remaining: 44},
+		})
+	}
+	report.Recompute()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(report); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestReport_Consensus_AverageCoordinates(t *testing.T) {
 	ip := MustParseAddr("8.8.8.8")
 	report := Report{
 		IP: ip,
 		Results: []ProviderResult{
-			{Provider: "a", Result: &Geolocation{IP: ip, Latitude: 36.0, Longitude: -120.0}},
-			{Provider: "b", Result: &Geolocation{IP: ip, Latitude: 38.0, Longitude: -124.0}},
+			{Provider: "a", Result: &Geolocation{IP: ip, Latitude: Float64(36.0), Longitude: Float64(-120.0)}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Latitude: Float64(38.0), Longitude: Float64(-124.0)}},
 		},
 	}
 
 	consensus := report.Consensus()
 
-	// Average of 36 and 38
-	if consensus.Latitude != 37.0 {
-		t.Errorf("Latitude = %v, want 37.0", consensus.Latitude)
+	// Average of 36 and 38, and of -120 and -124
+	if lat, lon, ok := consensus.Coordinates(); !ok || lat != 37.0 || lon != -122.0 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37, -122", lat, lon, ok)
+	}
+}
+
+func TestReport_Consensus_AbsentVersusZeroCoordinates(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, Latitude: Float64(0), Longitude: Float64(2.0)}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Country: "United States"}},
+			{Provider: "c", Result: &Geolocation{IP: ip, Latitude: Float64(2.0), Longitude: Float64(0)}},
+		},
+	}
+
+	// The zero coordinates count, the missing ones do not
+	if lat, lon, ok := report.Consensus().Coordinates(); !ok || lat != 1.0 || lon != 1.0 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 1, 1", lat, lon, ok)
 	}
 
-	// Average of -120 and -124
-	if consensus.Longitude != -122.0 {
-		t.Errorf("Longitude = %v, want -122.0", consensus.Longitude)
+	report.Results = report.Results[1:2]
+	if report.Consensus().HasLocation() {
+		t.Error("consensus should have no location when no provider reported one")
 	}
 }
 
@@ -286,6 +667,93 @@ func TestReport_Consensus_IgnoresErrors(t *testing.T) {
 	}
 }
 
+func TestReport_Consensus_Hostname(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, Hostname: "dns.google"}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Hostname: "dns.google"}},
+			{Provider: "c", Result: &Geolocation{IP: ip, Hostname: "google-public-dns-a.google.com"}},
+		},
+	}
+
+	if got := report.Consensus().Hostname; got != "dns.google" {
+		t.Errorf("Hostname = %v, want dns.google", got)
+	}
+}
+
+func TestReport_Consensus_Prefix(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	announced, covering := MustParsePrefix("8.8.8.0/24"), MustParsePrefix("8.0.0.0/9")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, Prefix: &covering}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Prefix: &announced}},
+			{Provider: "c", Result: &Geolocation{IP: ip, Country: "US"}},
+			{Provider: "d", Result: &Geolocation{IP: ip, Prefix: &announced}},
+		},
+	}
+
+	got := report.Consensus()
+	if got.Prefix == nil || *got.Prefix != announced {
+		t.Errorf("Prefix = %v, want %v", got.Prefix, announced)
+	}
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(data), `"prefix":"8.8.8.0/24"`) {
+		t.Errorf("Marshal() = %s, want the prefix as text", data)
+	}
+
+	data, _ = json.Marshal(Geolocation{IP: ip})
+	if strings.Contains(string(data), "prefix") {
+		t.Errorf("Marshal() without a prefix = %s", data)
+	}
+}
+
+func TestReport_Consensus_ExtendedSections(t *testing.T) {
+	ip := MustParseAddr("8.8.8.8")
+	report := Report{
+		IP: ip,
+		Results: []ProviderResult{
+			{Provider: "a", Result: &Geolocation{IP: ip, Country: "Germany"}},
+			{Provider: "b", Result: &Geolocation{IP: ip, Security: &Security{Tor: true}}},
+			{Provider: "c", Result: &Geolocation{IP: ip, Security: &Security{VPN: true}, Registration: &Registration{Company: "Acme"}}},
+		},
+	}
+
+	consensus := report.Consensus()
+
+	if consensus.Security == nil || !consensus.Security.Tor {
+		t.Errorf("Security = %v, want the first reported section", consensus.Security)
+	}
+
+	if consensus.Registration == nil || consensus.Registration.Company != "Acme" {
+		t.Errorf("Registration = %v, want Acme", consensus.Registration)
+	}
+}
+
+func TestSecurity_String(t *testing.T) {
+	tests := []struct {
+		security Security
+		want     string
+	}{
+		{Security{}, "none"},
+		{Security{VPN: true, Tor: true}, "vpn, tor"},
+		{Security{Proxy: true, Service: "ExampleVPN"}, "proxy (ExampleVPN)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.security.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
 func TestReport_JSONMarshal(t *testing.T) {
 	report := Report{
 		IP:            MustParseAddr("8.8.8.8"),
@@ -319,30 +787,125 @@ func TestReport_JSONMarshal(t *testing.T) {
 	}
 }
 
+func TestReport_JSONMarshal_Meta(t *testing.T) {
+	report := Report{
+		IP: MustParseAddr("8.8.8.8"),
+		Meta: &Meta{
+			Version:           "1.2.3",
+			Providers:         []string{"ip-api", "ipinfo"},
+			ConsensusStrategy: ConsensusMajority,
+			CacheHits:         1,
+			Timeout:           5 * time.Second,
+		},
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var m struct {
+		Meta map[string]interface{} `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("result is not valid JSON: %v", err)
+	}
+
+	if m.Meta == nil {
+		t.Fatal("meta should be present")
+	}
+
+	if m.Meta["version"] != "1.2.3" {
+		t.Errorf("version = %v, want 1.2.3", m.Meta["version"])
+	}
+
+	if m.Meta["consensus_strategy"] != "majority" {
+		t.Errorf("consensus_strategy = %v, want majority", m.Meta["consensus_strategy"])
+	}
+
+	if m.Meta["cache_hits"] != float64(1) {
+		t.Errorf("cache_hits = %v, want 1", m.Meta["cache_hits"])
+	}
+
+	if m.Meta["timeout_ms"] != float64(5000) {
+		t.Errorf("timeout_ms = %v, want 5000", m.Meta["timeout_ms"])
+	}
+
+	providers, ok := m.Meta["providers"].([]interface{})
+	if !ok || len(providers) != 2 {
+		t.Errorf("providers = %v, want 2 entries", m.Meta["providers"])
+	}
+}
+
+func TestReport_JSONMarshal_Quota(t *testing.T) {
+	report := Report{
+		IP: MustParseAddr("8.8.8.8"),
+		Results: []ProviderResult{
+			{Provider: "a", Error: "rate limited", Quota: &Quota{Limit: 45, Remaining: 0, ResetIn: 30 * time.Second}},
+			{Provider: "b", Result: &Geolocation{}},
+		},
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var parsed struct {
+		Quota map[string]struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			ResetIn   int64 `json:"reset_in_ms"`
+		} `json:"quota"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if len(parsed.Quota) != 1 {
+		t.Fatalf("quota = %v, want only the provider that reported one", parsed.Quota)
+	}
+
+	if q := parsed.Quota["a"]; q.Limit != 45 || q.Remaining != 0 || q.ResetIn != 30000 {
+		t.Errorf("quota[a] = %+v, want 0/45 resetting in 30000ms", q)
+	}
+}
+
+func TestReport_JSONMarshal_NoMeta(t *testing.T) {
+	data, err := json.Marshal(Report{IP: MustParseAddr("8.8.8.8")})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if strings.Contains(string(data), `"meta"`) {
+		t.Errorf("meta should be omitted when nil, got %s", data)
+	}
+}
+
 func TestMostVoted(t *testing.T) {
 	tests := []struct {
 		name  string
-		votes map[string]int
+		votes tally
 		want  string
 	}{
 		{
-			name:  "empty map",
-			votes: map[string]int{},
+			name:  "no votes",
+			votes: tally{},
 			want:  "",
 		},
 		{
 			name:  "single entry",
-			votes: map[string]int{"US": 1},
+			votes: tally{{"US", 1}},
 			want:  "US",
 		},
 		{
 			name:  "clear winner",
-			votes: map[string]int{"US": 3, "DE": 1},
+			votes: tally{{"US", 3}, {"DE", 1}},
 			want:  "US",
 		},
 		{
 			name:  "tie breaks alphabetically",
-			votes: map[string]int{"US": 2, "DE": 2},
+			votes: tally{{"US", 2}, {"DE", 2}},
 			want:  "DE", // D < U
 		},
 	}
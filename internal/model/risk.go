@@ -0,0 +1,133 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// Verdict classifies an address by its combined risk score.
+type Verdict string
+
+const (
+	VerdictClean      Verdict = "clean"
+	VerdictSuspicious Verdict = "suspicious"
+	VerdictMalicious  Verdict = "malicious"
+)
+
+// Default risk thresholds, on the 0 to 100 scale of reputation scores.
+const (
+	DefaultSuspiciousScore = 25
+	DefaultMaliciousScore  = 75
+)
+
+// RiskOptions tunes how Report.Risk combines reputation scores.
+type RiskOptions struct {
+	// Suspicious and Malicious are the combined scores from which an
+	// address is judged suspicious and malicious. Zero values select
+	// DefaultSuspiciousScore and DefaultMaliciousScore.
+	Suspicious float64
+	Malicious  float64
+
+	// Weights of the reputation sources, by provider name. Sources
+	// without a weight count once.
+	Weights map[string]float64
+}
+
+// thresholds returns the configured thresholds, or their defaults.
+func (o RiskOptions) thresholds() (suspicious, malicious float64) {
+	suspicious, malicious = o.Suspicious, o.Malicious
+	if suspicious == 0 {
+		suspicious = DefaultSuspiciousScore
+	}
+	if malicious == 0 {
+		malicious = DefaultMaliciousScore
+	}
+	return suspicious, malicious
+}
+
+// Validate checks that the thresholds are in range and ordered.
+func (o RiskOptions) Validate() error {
+	suspicious, malicious := o.thresholds()
+	if suspicious < 0 || malicious > 100 || suspicious >= malicious {
+		return fmt.Errorf("risk thresholds must satisfy 0 <= suspicious < malicious <= 100, got %g and %g", suspicious, malicious)
+	}
+	for name, w := range o.Weights {
+		if w < 0 {
+			return fmt.Errorf("risk weight of %s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// RiskContribution is the part of a combined risk score due to one
+// reputation source.
+type RiskContribution struct {
+	Provider string  `json:"provider"`
+	Score    float64 `json:"score"`
+	Weight   float64 `json:"weight"`
+
+	// Contribution is the number of points of the combined score due to
+	// this source; the contributions add up to the combined score
+	Contribution float64 `json:"contribution"`
+}
+
+// Risk is the combined assessment of the reputation sources of a report.
+type Risk struct {
+	// Score is the weighted average of the source scores, from 0 to 100
+	Score         float64            `json:"score"`
+	Verdict       Verdict            `json:"verdict"`
+	Contributions []RiskContribution `json:"contributions"`
+}
+
+// Risk combines the reputation scores reported by the providers, weighted
+// as configured in the consensus options, into a score and a verdict. It
+// returns nil when no provider reported a reputation.
+func (r Report) Risk() *Risk {
+	opts := r.consensusOptions.Risk
+
+	var contributions []RiskContribution
+	var total, weighted float64
+	for _, pr := range r.Results {
+		if !pr.Success() || pr.Shadow || pr.Result.Reputation == nil {
+			continue
+		}
+
+		weight := 1.0
+		if w, ok := opts.Weights[pr.Provider]; ok {
+			weight = w
+		}
+		score := math.Max(0, math.Min(100, pr.Result.Reputation.Score))
+
+		contributions = append(contributions, RiskContribution{Provider: pr.Provider, Score: score, Weight: weight})
+		total += weight
+		weighted += weight * score
+	}
+	if contributions == nil {
+		return nil
+	}
+
+	risk := &Risk{Contributions: contributions}
+	if total > 0 {
+		risk.Score = round1(weighted / total)
+		for i := range contributions {
+			c := &contributions[i]
+			c.Contribution = round1(c.Weight * c.Score / total)
+		}
+	}
+
+	suspicious, malicious := opts.thresholds()
+	switch {
+	case risk.Score >= malicious:
+		risk.Verdict = VerdictMalicious
+	case risk.Score >= suspicious:
+		risk.Verdict = VerdictSuspicious
+	default:
+		risk.Verdict = VerdictClean
+	}
+	return risk
+}
+
+// round1 rounds v to one decimal place, the precision scores are shown at.
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
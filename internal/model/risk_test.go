@@ -0,0 +1,133 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func reputationResult(provider string, score float64) ProviderResult {
+	return ProviderResult{
+		Provider: provider,
+		Result:   &Geolocation{Reputation: &Reputation{Score: score}},
+	}
+}
+
+func TestReport_Risk(t *testing.T) {
+	tests := []struct {
+		name        string
+		results     []ProviderResult
+		opts        RiskOptions
+		wantScore   float64
+		wantVerdict Verdict
+	}{
+		{
+			name:        "clean",
+			results:     []ProviderResult{reputationResult("abuseipdb", 0), reputationResult("dnsbl", 10)},
+			wantScore:   5,
+			wantVerdict: VerdictClean,
+		},
+		{
+			name:        "suspicious",
+			results:     []ProviderResult{reputationResult("abuseipdb", 80), reputationResult("dnsbl", 0)},
+			wantScore:   40,
+			wantVerdict: VerdictSuspicious,
+		},
+		{
+			name:        "weighted",
+			results:     []ProviderResult{reputationResult("abuseipdb", 90), reputationResult("dnsbl", 0)},
+			opts:        RiskOptions{Weights: map[string]float64{"abuseipdb": 3}},
+			wantScore:   67.5,
+			wantVerdict: VerdictSuspicious,
+		},
+		{
+			name:        "custom thresholds",
+			results:     []ProviderResult{reputationResult("abuseipdb", 60)},
+			opts:        RiskOptions{Suspicious: 10, Malicious: 50},
+			wantScore:   60,
+			wantVerdict: VerdictMalicious,
+		},
+		{
+			name:        "out of range scores are clamped",
+			results:     []ProviderResult{reputationResult("dnsbl", 250)},
+			wantScore:   100,
+			wantVerdict: VerdictMalicious,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Report{Results: tt.results}
+			report.SetConsensusOptions(ConsensusOptions{Risk: tt.opts})
+
+			risk := report.Risk()
+			if risk == nil {
+				t.Fatal("Risk() = nil")
+			}
+			if risk.Score != tt.wantScore || risk.Verdict != tt.wantVerdict {
+				t.Errorf("Risk() = %v, %s; want %v, %s", risk.Score, risk.Verdict, tt.wantScore, tt.wantVerdict)
+			}
+
+			var sum float64
+			for _, c := range risk.Contributions {
+				sum += c.Contribution
+			}
+			if sum != risk.Score {
+				t.Errorf("contributions add up to %v, want %v", sum, risk.Score)
+			}
+		})
+	}
+}
+
+func TestReport_Risk_NoReputation(t *testing.T) {
+	report := Report{Results: []ProviderResult{
+		{Provider: "ipinfo", Result: &Geolocation{Country: "US"}},
+		{Provider: "abuseipdb", Error: "timeout"},
+	}}
+	if risk := report.Risk(); risk != nil {
+		t.Errorf("Risk() = %+v, want nil", risk)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"risk"`) {
+		t.Errorf("JSON has a risk without reputation sources: %s", data)
+	}
+}
+
+func TestReport_MarshalJSON_Risk(t *testing.T) {
+	report := Report{Results: []ProviderResult{reputationResult("abuseipdb", 80)}}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"risk":{"score":80,"verdict":"malicious"`, `"contributions":[{"provider":"abuseipdb","score":80,"weight":1,"contribution":80}]`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON missing %s: %s", want, data)
+		}
+	}
+}
+
+func TestRiskOptions_Validate(t *testing.T) {
+	valid := []RiskOptions{{}, {Suspicious: 10, Malicious: 90}}
+	for _, o := range valid {
+		if err := o.Validate(); err != nil {
+			t.Errorf("Validate(%+v) error = %v", o, err)
+		}
+	}
+
+	invalid := []RiskOptions{
+		{Suspicious: 80, Malicious: 50},
+		{Suspicious: -1},
+		{Malicious: 101},
+		{Weights: map[string]float64{"dnsbl": -1}},
+	}
+	for _, o := range invalid {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected error", o)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package model
+
+import "strings"
+
+// Security describes anonymisation services detected for an address.
+// Providers only report it on plans that include privacy detection.
+type Security struct {
+	VPN     bool `json:"vpn"`
+	Proxy   bool `json:"proxy"`
+	Tor     bool `json:"tor"`
+	Relay   bool `json:"relay"`
+	Hosting bool `json:"hosting"`
+
+	// Service names the detected VPN or proxy service, if known
+	Service string `json:"service,omitempty"`
+}
+
+// Flags returns the names of the detected services, e.g. ["vpn", "tor"].
+func (s Security) Flags() []string {
+	var flags []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{s.VPN, "vpn"},
+		{s.Proxy, "proxy"},
+		{s.Tor, "tor"},
+		{s.Relay, "relay"},
+		{s.Hosting, "hosting"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return flags
+}
+
+// String summarises the detected services, or "none".
+func (s Security) String() string {
+	flags := s.Flags()
+	if len(flags) == 0 {
+		return "none"
+	}
+	if s.Service != "" {
+		return strings.Join(flags, ", ") + " (" + s.Service + ")"
+	}
+	return strings.Join(flags, ", ")
+}
+
+// Reputation is the assessment of an address by a threat intelligence
+// source, such as an abuse database or a DNS blocklist.
+type Reputation struct {
+	// Score rates how likely the address is to be malicious, from 0 to
+	// 100; listings on blocklists without a score count as 100
+	Score float64 `json:"score"`
+
+	// Categories of the reported activity, e.g. "ssh-bruteforce"
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Registration describes who an address is registered to and where to
+// report abuse.
+type Registration struct {
+	// Company that operates the address
+	Company       string `json:"company,omitempty"`
+	CompanyDomain string `json:"company_domain,omitempty"`
+	CompanyType   string `json:"company_type,omitempty"`
+
+	// Network is the registered range containing the address, and NetName
+	// its name in the registry, e.g. "GOGL"
+	Network string `json:"network,omitempty"`
+	NetName string `json:"net_name,omitempty"`
+
+	// Registry holding the record, e.g. "RIPE NCC", and the country the
+	// network is registered in, which need not be where it is used
+	Registry string `json:"registry,omitempty"`
+	Country  string `json:"country,omitempty"`
+
+	// Abuse contact for the network
+	AbuseName    string `json:"abuse_name,omitempty"`
+	AbuseEmail   string `json:"abuse_email,omitempty"`
+	AbusePhone   string `json:"abuse_phone,omitempty"`
+	AbuseAddress string `json:"abuse_address,omitempty"`
+}
+
+// IsEmpty reports whether no registration details are set.
+func (r Registration) IsEmpty() bool {
+	return r == Registration{}
+}
+
+// SpecialUse describes an address in a special-purpose range of the IANA
+// registries, such as private, documentation or shared (CGNAT) space,
+// which has no meaningful geolocation.
+type SpecialUse struct {
+	// Prefix is the registered range containing the address
+	Prefix string `json:"prefix"`
+
+	// Category classifies the range, e.g. "private", "documentation" or "cgnat"
+	Category string `json:"category"`
+
+	// Name is the registry name of the range, e.g. "Shared Address Space"
+	Name string `json:"name"`
+
+	// RFC defines the range, e.g. "RFC6598"
+	RFC string `json:"rfc"`
+
+	// GloballyReachable is nil when the registry does not say
+	GloballyReachable *bool `json:"globally_reachable,omitempty"`
+}
+
+// String summarises the range, e.g. "Shared Address Space (100.64.0.0/10, RFC6598)".
+func (s SpecialUse) String() string {
+	return s.Name + " (" + s.Prefix + ", " + s.RFC + ")"
+}
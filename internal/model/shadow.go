@@ -0,0 +1,52 @@
+package model
+
+import "strings"
+
+// Comparison compares the result of a shadow provider with the consensus of
+// the other providers. Fields are nil when either side lacks the value.
+type Comparison struct {
+	CountryMatch *bool    `json:"country_match,omitempty"`
+	RegionMatch  *bool    `json:"region_match,omitempty"`
+	CityMatch    *bool    `json:"city_match,omitempty"`
+	ASNMatch     *bool    `json:"asn_match,omitempty"`
+	DistanceKm   *float64 `json:"distance_km,omitempty"`
+}
+
+// Compare compares g with the reference geolocation ref. Place names are
+// compared case-insensitively.
+func Compare(g, ref Geolocation) Comparison {
+	var c Comparison
+	c.CountryMatch = match(g.CountryCode, ref.CountryCode)
+	c.RegionMatch = match(g.Region, ref.Region)
+	c.CityMatch = match(g.City, ref.City)
+	c.ASNMatch = match(g.ASN, ref.ASN)
+	if d, ok := DistanceKm(g, ref); ok {
+		c.DistanceKm = Float64(d)
+	}
+	return c
+}
+
+// match reports whether a and b are equal, or nil when either is empty.
+func match(a, b string) *bool {
+	if a == "" || b == "" {
+		return nil
+	}
+	equal := strings.EqualFold(a, b)
+	return &equal
+}
+
+// CompareShadows sets the comparison of every successful shadow result
+// with the consensus. It does nothing when no other provider succeeded or
+// the consensus is disabled.
+func (r *Report) CompareShadows() {
+	if r.SuccessCount() == 0 || r.consensusOptions.Disabled {
+		return
+	}
+	consensus := r.Consensus()
+	for i := range r.Results {
+		if pr := &r.Results[i]; pr.Shadow && pr.Success() {
+			c := Compare(*pr.Result, consensus)
+			pr.Comparison = &c
+		}
+	}
+}
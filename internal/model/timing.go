@@ -0,0 +1,81 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timing is the precise timing of a lookup, for performance analysis
+// beyond the millisecond durations always reported.
+type Timing struct {
+	Start time.Time `json:"start"`
+
+	// Duration is the ISO 8601 duration, e.g. "PT0.183204511S"
+	Duration   string `json:"duration"`
+	DurationNS int64  `json:"duration_ns"`
+}
+
+// ProviderTiming is the Timing of a provider query.
+type ProviderTiming struct {
+	Timing
+
+	// StartOffsetNS is how long after the start of the lookup the provider
+	// was queried, in nanoseconds
+	StartOffsetNS int64 `json:"start_offset_ns"`
+}
+
+// NewTiming returns the Timing of an operation started at start that took d.
+func NewTiming(start time.Time, d time.Duration) *Timing {
+	return &Timing{Start: start, Duration: ISODuration(d), DurationNS: d.Nanoseconds()}
+}
+
+// DetailedTiming returns a copy of the report with its Timing, and that of
+// each provider query, filled in. Providers that were not queried have no
+// timing.
+func (r Report) DetailedTiming() Report {
+	r.Timing = NewTiming(r.Timestamp, r.TotalDuration)
+
+	results := make([]ProviderResult, len(r.Results))
+	for i, pr := range r.Results {
+		if !pr.Start.IsZero() {
+			pr.Timing = &ProviderTiming{
+				Timing:        *NewTiming(pr.Start, pr.Duration),
+				StartOffsetNS: pr.Start.Sub(r.Timestamp).Nanoseconds(),
+			}
+		}
+		results[i] = pr
+	}
+	r.Results = results
+	return r
+}
+
+// ISODuration formats d as an ISO 8601 duration, such as "PT1M30.5S", with
+// up to nanosecond precision.
+func ISODuration(d time.Duration) string {
+	var sb strings.Builder
+	if d < 0 {
+		sb.WriteByte('-')
+		d = -d
+	}
+	sb.WriteString("PT")
+
+	if h := d / time.Hour; h > 0 {
+		sb.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		sb.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+		d -= m * time.Minute
+	}
+	if d > 0 || strings.HasSuffix(sb.String(), "PT") {
+		secs := strconv.FormatInt(int64(d/time.Second), 10)
+		if frac := d % time.Second; frac > 0 {
+			// Padded to 9 digits by the leading 1, which is dropped
+			digits := strconv.FormatInt(int64(frac+time.Second), 10)[1:]
+			secs += "." + strings.TrimRight(digits, "0")
+		}
+		sb.WriteString(secs + "S")
+	}
+	return sb.String()
+}
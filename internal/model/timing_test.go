@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestISODuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{183204511 * time.Nanosecond, "PT0.183204511S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{2 * time.Second, "PT2S"},
+		{90*time.Second + 500*time.Millisecond, "PT1M30.5S"},
+		{time.Hour, "PT1H"},
+		{time.Hour + 5*time.Nanosecond, "PT1H0.000000005S"},
+		{-250 * time.Millisecond, "-PT0.25S"},
+	}
+	for _, tt := range tests {
+		if got := ISODuration(tt.d); got != tt.want {
+			t.Errorf("ISODuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestReport_DetailedTiming(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	report := Report{
+		IP:            MustParseAddr("192.0.2.1"),
+		Timestamp:     start,
+		TotalDuration: 180 * time.Millisecond,
+		Results: []ProviderResult{
+			{Provider: "ipinfo", Start: start.Add(2 * time.Millisecond), Duration: 120 * time.Millisecond},
+			{Provider: "ip-api", Error: "enough providers answered", Skipped: true},
+		},
+	}
+
+	detailed := report.DetailedTiming()
+	if report.Timing != nil || report.Results[0].Timing != nil {
+		t.Error("DetailedTiming() modified the report")
+	}
+
+	data, err := json.Marshal(detailed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"timing":{"start":"2024-01-15T10:30:00Z","duration":"PT0.18S","duration_ns":180000000}`,
+		`"timing":{"start":"2024-01-15T10:30:00.002Z","duration":"PT0.12S","duration_ns":120000000,"start_offset_ns":2000000}`,
+		`"duration_ms":120`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON missing %s:\n%s", want, data)
+		}
+	}
+	if strings.Count(string(data), `"timing"`) != 2 {
+		t.Errorf("skipped provider has a timing:\n%s", data)
+	}
+}
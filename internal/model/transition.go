@@ -0,0 +1,15 @@
+package model
+
+// Transition describes an IPv6 address that embeds an IPv4 address through
+// a transition mechanism such as 6to4 or Teredo.
+type Transition struct {
+	// Mechanism names the transition mechanism, e.g. "6to4"
+	Mechanism string `json:"mechanism"`
+
+	// IPv4 is the embedded IPv4 address
+	IPv4 IPAddress `json:"ipv4"`
+
+	// LookedUp is set when the embedded address was looked up in place of
+	// the IPv6 address
+	LookedUp bool `json:"looked_up"`
+}
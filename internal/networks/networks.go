@@ -0,0 +1,116 @@
+// Package networks tallies the reports of a batch run by the network
+// announcing their address, so that abuse can be triaged per network
+// rather than per address.
+package networks
+
+import (
+	"sort"
+
+	"api-client/internal/model"
+)
+
+// Network is a network holding some of the addresses of a run.
+type Network struct {
+	// Prefix is the announced prefix, e.g. "8.8.8.0/24", or else the
+	// registered range when no provider reported the announced one
+	Prefix model.Prefix `json:"prefix"`
+
+	// ASN is the origin AS of the prefix, e.g. "AS15169"
+	ASN string `json:"asn"`
+
+	// Holder is the ISP or, failing that, the organization of the network
+	Holder string `json:"holder"`
+
+	Count int `json:"count"`
+
+	// Addresses are the addresses in the network, in the order they were
+	// looked up
+	Addresses []string `json:"addresses"`
+}
+
+// Tally counts reports by the network of their consensus.
+type Tally struct {
+	networks map[model.Prefix]*Network
+	total    int
+	unknown  int
+}
+
+// NewTally returns an empty Tally.
+func NewTally() *Tally {
+	return &Tally{networks: make(map[model.Prefix]*Network)}
+}
+
+// Add counts report in the network of its consensus, or as unknown when
+// the providers reported none.
+func (t *Tally) Add(report model.Report) {
+	t.total++
+
+	consensus := report.Consensus()
+	prefix, ok := networkOf(consensus)
+	if !ok {
+		t.unknown++
+		return
+	}
+
+	n, ok := t.networks[prefix]
+	if !ok {
+		n = &Network{Prefix: prefix, ASN: consensus.ASN, Holder: consensus.ISP}
+		if n.Holder == "" {
+			n.Holder = consensus.Org
+		}
+		t.networks[prefix] = n
+	}
+	n.Count++
+	n.Addresses = append(n.Addresses, report.IP.String())
+}
+
+// networkOf returns the announced prefix of geo, or else its registered
+// range when that is a CIDR prefix, as reported by ipinfo and some whois
+// servers. Ranges such as "8.8.8.0 - 8.8.8.255" are not used, since they
+// need not match any announcement.
+func networkOf(geo model.Geolocation) (model.Prefix, bool) {
+	if geo.Prefix != nil && geo.Prefix.IsValid() {
+		return geo.Prefix.Masked(), true
+	}
+	if geo.Registration == nil {
+		return model.Prefix{}, false
+	}
+	prefix, err := model.ParsePrefix(geo.Registration.Network)
+	if err != nil {
+		return model.Prefix{}, false
+	}
+	return prefix.Masked(), true
+}
+
+// Networks returns each network, the one holding the most addresses first.
+func (t *Tally) Networks() []Network {
+	networks := make([]Network, 0, len(t.networks))
+	for _, n := range t.networks {
+		networks = append(networks, *n)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		if networks[i].Count != networks[j].Count {
+			return networks[i].Count > networks[j].Count
+		}
+		return comparePrefixes(networks[i].Prefix, networks[j].Prefix) < 0
+	})
+	return networks
+}
+
+// comparePrefixes orders prefixes by address, then by length.
+func comparePrefixes(a, b model.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// Total returns the number of reports counted.
+func (t *Tally) Total() int {
+	return t.total
+}
+
+// Unknown returns the number of reports without a network.
+func (t *Tally) Unknown() int {
+	return t.unknown
+}
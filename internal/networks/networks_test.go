@@ -0,0 +1,57 @@
+package networks
+
+import (
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func reportIn(ip, prefix, asn, isp string) model.Report {
+	p := model.MustParsePrefix(prefix)
+	return model.Report{IP: model.MustParseAddr(ip), Results: []model.ProviderResult{
+		{Provider: "ripestat", Result: &model.Geolocation{Prefix: &p, ASN: asn, ISP: isp}},
+	}}
+}
+
+func TestTally(t *testing.T) {
+	registered := model.Report{IP: model.MustParseAddr("203.0.113.9"), Results: []model.ProviderResult{
+		{Provider: "ipinfo", Result: &model.Geolocation{Org: "Example Net", Registration: &model.Registration{Network: "203.0.113.7/24"}}},
+	}}
+	ranged := model.Report{IP: model.MustParseAddr("198.51.100.1"), Results: []model.ProviderResult{
+		{Provider: "whois", Result: &model.Geolocation{Registration: &model.Registration{Network: "198.51.100.0 - 198.51.100.255"}}},
+	}}
+
+	tally := NewTally()
+	for _, report := range []model.Report{
+		reportIn("8.8.8.8", "8.8.8.0/24", "AS15169", "Google LLC"),
+		reportIn("1.1.1.1", "1.1.1.0/24", "AS13335", "Cloudflare"),
+		reportIn("8.8.8.4", "8.8.8.0/24", "AS15169", "Google LLC"),
+		registered,
+		ranged,
+		{IP: model.MustParseAddr("192.0.2.1"), Results: []model.ProviderResult{{Provider: "ripestat", Error: "timeout"}}},
+	} {
+		tally.Add(report)
+	}
+
+	want := []Network{
+		{Prefix: model.MustParsePrefix("8.8.8.0/24"), ASN: "AS15169", Holder: "Google LLC", Count: 2, Addresses: []string{"8.8.8.8", "8.8.8.4"}},
+		{Prefix: model.MustParsePrefix("1.1.1.0/24"), ASN: "AS13335", Holder: "Cloudflare", Count: 1, Addresses: []string{"1.1.1.1"}},
+		{Prefix: model.MustParsePrefix("203.0.113.0/24"), Holder: "Example Net", Count: 1, Addresses: []string{"203.0.113.9"}},
+	}
+	got := tally.Networks()
+	if len(got) != len(want) {
+		t.Fatalf("Networks() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Prefix != w.Prefix || g.ASN != w.ASN || g.Holder != w.Holder || g.Count != w.Count ||
+			strings.Join(g.Addresses, " ") != strings.Join(w.Addresses, " ") {
+			t.Errorf("Networks()[%d] = %+v, want %+v", i, g, w)
+		}
+	}
+
+	if tally.Total() != 6 || tally.Unknown() != 2 {
+		t.Errorf("Total(), Unknown() = %d, %d, want 6, 2", tally.Total(), tally.Unknown())
+	}
+}
@@ -0,0 +1,389 @@
+// Package parquet writes flat records as Apache Parquet files, the
+// columnar format read by DuckDB, Spark and Athena. Only what a table of
+// strings, numbers and booleans needs is supported: values are PLAIN
+// encoded and uncompressed, in one page per column of each row group.
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// magic starts and ends a Parquet file.
+const magic = "PAR1"
+
+// createdBy identifies the writer in the file metadata.
+const createdBy = "ipintel"
+
+// defaultRowGroupRows is the number of rows buffered before they are
+// written as a row group, bounding the memory of a Writer.
+const defaultRowGroupRows = 100_000
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	// String is a UTF-8 string: a byte array annotated as such
+	String Type = iota
+	// Double is a 64-bit floating-point number
+	Double
+	// Int32 is a 32-bit signed integer
+	Int32
+	// Boolean is true or false
+	Boolean
+)
+
+// Physical types, encodings and other enumerations of the Parquet format.
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeDouble    = 5
+	typeByteArray = 6
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8 = 0
+
+	pageData = 0
+
+	codecUncompressed = 0
+)
+
+func (t Type) physical() int32 {
+	switch t {
+	case Double:
+		return typeDouble
+	case Int32:
+		return typeInt32
+	case Boolean:
+		return typeBoolean
+	default:
+		return typeByteArray
+	}
+}
+
+// Column describes a column of a file. Values of an optional column may be
+// null; those of other columns may not.
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+// Writer writes rows to a Parquet file. Rows are buffered and written by
+// row group, and the file is only complete once the Writer is closed.
+type Writer struct {
+	w       io.Writer
+	offset  int64
+	columns []Column
+
+	rowGroupRows int
+	rows         int
+	chunks       []chunk
+
+	numRows int64
+	groups  []rowGroup
+}
+
+// chunk buffers the values of a column in the current row group.
+type chunk struct {
+	// set tells, for each row of an optional column, whether its value is
+	// not null
+	set []bool
+
+	// values are the values not null, PLAIN encoded, except booleans
+	values []byte
+	bools  []bool
+}
+
+// rowGroup is the metadata of a row group written.
+type rowGroup struct {
+	rows   int
+	size   int64
+	chunks []chunkMeta
+}
+
+// chunkMeta is the metadata of a column chunk written: its page starts at
+// offset and spans size bytes, header included.
+type chunkMeta struct {
+	offset int64
+	size   int64
+}
+
+// NewWriter returns a Writer of a file of the given columns to w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{
+		w:            w,
+		columns:      columns,
+		rowGroupRows: defaultRowGroupRows,
+		chunks:       make([]chunk, len(columns)),
+	}
+}
+
+// Write adds a row with a value for each column, in order: a string, a
+// float64, an int or a bool as the type of the column requires, or nil
+// for null in an optional column.
+func (w *Writer) Write(row ...any) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values for %d columns", len(row), len(w.columns))
+	}
+	for i, value := range row {
+		if err := check(w.columns[i], value); err != nil {
+			return err
+		}
+	}
+	for i, value := range row {
+		w.chunks[i].append(w.columns[i], value)
+	}
+
+	w.rows++
+	if w.rows >= w.rowGroupRows {
+		return w.flush()
+	}
+	return nil
+}
+
+// check returns an error unless value can be written to column.
+func check(column Column, value any) error {
+	var ok bool
+	switch v := value.(type) {
+	case nil:
+		if !column.Optional {
+			return fmt.Errorf("column %s: null value in a required column", column.Name)
+		}
+		return nil
+	case string:
+		ok = column.Type == String
+	case float64:
+		ok = column.Type == Double
+	case int:
+		if column.Type == Int32 && (v < math.MinInt32 || v > math.MaxInt32) {
+			return fmt.Errorf("column %s: %d out of range", column.Name, v)
+		}
+		ok = column.Type == Int32
+	case bool:
+		ok = column.Type == Boolean
+	}
+	if !ok {
+		return fmt.Errorf("column %s: unexpected %T value", column.Name, value)
+	}
+	return nil
+}
+
+// append adds value, checked, to the chunk of column.
+func (c *chunk) append(column Column, value any) {
+	if column.Optional {
+		c.set = append(c.set, value != nil)
+	}
+
+	switch v := value.(type) {
+	case string:
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(len(v)))
+		c.values = append(c.values, v...)
+	case float64:
+		c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(v))
+	case int:
+		c.values = binary.LittleEndian.AppendUint32(c.values, uint32(int32(v)))
+	case bool:
+		c.bools = append(c.bools, v)
+	}
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+// start writes the magic number opening the file, unless already written.
+func (w *Writer) start() error {
+	if w.offset > 0 {
+		return nil
+	}
+	return w.write([]byte(magic))
+}
+
+// flush writes the buffered rows as a row group, with one data page per
+// column.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	group := rowGroup{rows: w.rows}
+	for i, column := range w.columns {
+		c := &w.chunks[i]
+
+		var data []byte
+		if column.Optional {
+			levels := definitionLevels(c.set)
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(levels)))
+			data = append(data, levels...)
+		}
+		if column.Type == Boolean {
+			data = append(data, packBools(c.bools)...)
+		} else {
+			data = append(data, c.values...)
+		}
+
+		header := pageHeader(w.rows, len(data))
+		meta := chunkMeta{offset: w.offset, size: int64(len(header) + len(data))}
+		if err := w.write(header); err != nil {
+			return err
+		}
+		if err := w.write(data); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, meta)
+		group.size += meta.size
+
+		*c = chunk{}
+	}
+
+	w.groups = append(w.groups, group)
+	w.numRows += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// Close writes the buffered rows and the footer of the file, with its
+// metadata. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	metadata := w.fileMetadata()
+	footer := binary.LittleEndian.AppendUint32(metadata, uint32(len(metadata)))
+	return w.write(append(footer, magic...))
+}
+
+// definitionLevels encodes whether each value is set as the definition
+// levels of an optional column, 1 when set and 0 when null, in the RLE
+// hybrid encoding with runs of repeated levels only.
+func definitionLevels(set []bool) []byte {
+	var levels []byte
+	for i := 0; i < len(set); {
+		run := 1
+		for i+run < len(set) && set[i+run] == set[i] {
+			run++
+		}
+		levels = binary.AppendUvarint(levels, uint64(run)<<1)
+		if set[i] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		i += run
+	}
+	return levels
+}
+
+// packBools encodes booleans as PLAIN, one bit each from the least
+// significant.
+func packBools(bools []bool) []byte {
+	packed := make([]byte, (len(bools)+7)/8)
+	for i, b := range bools {
+		if b {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
+
+// pageHeader returns the PageHeader of a data page of rows values, nulls
+// included, in size bytes.
+func pageHeader(rows, size int) []byte {
+	var t thriftWriter
+	t.beginStruct(0)
+	t.i32(1, pageData)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.beginStruct(5)
+	t.i32(1, int32(rows))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.endStruct()
+	t.endStruct()
+	return t.buf
+}
+
+// fileMetadata returns the FileMetaData of the file: its schema, a root
+// group of the columns, and its row groups.
+func (w *Writer) fileMetadata() []byte {
+	var t thriftWriter
+	t.beginStruct(0)
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(w.columns)+1)
+	t.beginStruct(0)
+	t.string(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.endStruct()
+	for _, column := range w.columns {
+		t.beginStruct(0)
+		t.i32(1, column.Type.physical())
+		if column.Optional {
+			t.i32(3, repetitionOptional)
+		} else {
+			t.i32(3, repetitionRequired)
+		}
+		t.string(4, column.Name)
+		if column.Type == String {
+			t.i32(6, convertedUTF8)
+			// LogicalType, a union, set to its STRING member
+			t.beginStruct(10)
+			t.beginStruct(1)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endStruct()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.list(4, thriftStruct, len(w.groups))
+	for _, group := range w.groups {
+		t.beginStruct(0)
+		t.list(1, thriftStruct, len(group.chunks))
+		for i, meta := range group.chunks {
+			column := w.columns[i]
+			t.beginStruct(0)
+			t.i64(2, meta.offset)
+			t.beginStruct(3)
+			t.i32(1, column.Type.physical())
+			t.list(2, thriftI32, 2)
+			t.i32Element(encodingPlain)
+			t.i32Element(encodingRLE)
+			t.list(3, thriftBinary, 1)
+			t.stringElement(column.Name)
+			t.i32(4, codecUncompressed)
+			t.i64(5, int64(group.rows))
+			t.i64(6, meta.size)
+			t.i64(7, meta.size)
+			t.i64(9, meta.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.size)
+		t.i64(3, int64(group.rows))
+		t.endStruct()
+	}
+
+	t.string(6, createdBy)
+	t.endStruct()
+	return t.buf
+}
@@ -0,0 +1,258 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// thriftReader decodes compact-protocol structs into maps of field ids to
+// values: int64 for integers, string for binaries, []any for lists and
+// map[int16]any for structs.
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.t.Fatal("invalid varint")
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := r.varint()
+		v := string(r.data[:n])
+		r.data = r.data[n:]
+		return v
+	case thriftList:
+		header := r.data[0]
+		r.data = r.data[1:]
+		n := uint64(header >> 4)
+		if n == 15 {
+			n = r.varint()
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	default:
+		r.t.Fatalf("unexpected type %d", typ)
+		return nil
+	}
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		header := r.data[0]
+		r.data = r.data[1:]
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// readFile decodes the metadata of a Parquet file and the values of its
+// columns, nil for nulls, across row groups.
+func readFile(t *testing.T, file []byte) (map[int16]any, [][]any) {
+	t.Helper()
+	if !bytes.HasPrefix(file, []byte(magic)) || !bytes.HasSuffix(file, []byte(magic)) {
+		t.Fatalf("file does not start and end with %s", magic)
+	}
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	r := &thriftReader{t: t, data: file[len(file)-8-size : len(file)-8]}
+	metadata := r.structure()
+	if len(r.data) != 0 {
+		t.Fatalf("%d bytes left after the metadata", len(r.data))
+	}
+
+	schema := metadata[2].([]any)[1:]
+	columns := make([][]any, len(schema))
+	for _, group := range metadata[4].([]any) {
+		for i, cc := range group.(map[int16]any)[1].([]any) {
+			meta := cc.(map[int16]any)[3].(map[int16]any)
+			element := schema[i].(map[int16]any)
+			offset, size := meta[9].(int64), meta[7].(int64)
+
+			r := &thriftReader{t: t, data: file[offset : offset+size]}
+			header := r.structure()
+			page := r.data
+			if int64(len(page)) != header[3].(int64) {
+				t.Fatalf("page of %d bytes, header says %d", len(page), header[3])
+			}
+			rows := int(header[5].(map[int16]any)[1].(int64))
+
+			set := make([]bool, rows)
+			for j := range set {
+				set[j] = true
+			}
+			if element[3].(int64) == repetitionOptional {
+				n := binary.LittleEndian.Uint32(page)
+				levels := &thriftReader{t: t, data: page[4 : 4+n]}
+				page = page[4+n:]
+				for j := 0; j < rows; {
+					run := int(levels.varint() >> 1)
+					level := levels.data[0]
+					levels.data = levels.data[1:]
+					for k := range run {
+						set[j+k] = level == 1
+					}
+					j += run
+				}
+			}
+
+			bit := 0
+			for _, ok := range set {
+				if !ok {
+					columns[i] = append(columns[i], nil)
+					continue
+				}
+				var v any
+				switch element[1].(int64) {
+				case typeByteArray:
+					n := binary.LittleEndian.Uint32(page)
+					v, page = string(page[4:4+n]), page[4+n:]
+				case typeDouble:
+					v, page = math.Float64frombits(binary.LittleEndian.Uint64(page)), page[8:]
+				case typeInt32:
+					v, page = int(int32(binary.LittleEndian.Uint32(page))), page[4:]
+				case typeBoolean:
+					v = page[bit/8]&(1<<(bit%8)) != 0
+					bit++
+				}
+				columns[i] = append(columns[i], v)
+			}
+		}
+	}
+	return metadata, columns
+}
+
+func TestWriter(t *testing.T) {
+	columns := []Column{
+		{Name: "ip", Type: String},
+		{Name: "city", Type: String, Optional: true},
+		{Name: "latitude", Type: Double, Optional: true},
+		{Name: "providers", Type: Int32},
+		{Name: "is_anycast", Type: Boolean},
+	}
+	rows := [][]any{
+		{"8.8.8.8", "Mountain View", 37.4056, 3, true},
+		{"1.1.1.1", nil, nil, 2, false},
+		{"192.0.2.1", "", -33.8688, 0, true},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, columns)
+	w.rowGroupRows = 2
+	for _, row := range rows {
+		if err := w.Write(row...); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	metadata, got := readFile(t, buf.Bytes())
+	if metadata[3] != int64(len(rows)) {
+		t.Errorf("num_rows = %v, want %d", metadata[3], len(rows))
+	}
+	if groups := len(metadata[4].([]any)); groups != 2 {
+		t.Errorf("%d row groups, want 2", groups)
+	}
+	schema := metadata[2].([]any)
+	if root := schema[0].(map[int16]any); root[5] != int64(len(columns)) {
+		t.Errorf("root num_children = %v, want %d", root[5], len(columns))
+	}
+	for i, column := range columns {
+		if name := schema[i+1].(map[int16]any)[4]; name != column.Name {
+			t.Errorf("column %d name = %v, want %s", i, name, column.Name)
+		}
+
+		var want []any
+		for _, row := range rows {
+			want = append(want, row[i])
+		}
+		if !reflect.DeepEqual(got[i], want) {
+			t.Errorf("column %s = %v, want %v", column.Name, got[i], want)
+		}
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []Column{{Name: "ip", Type: String}})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	metadata, _ := readFile(t, buf.Bytes())
+	if metadata[3] != int64(0) || len(metadata[4].([]any)) != 0 {
+		t.Errorf("num_rows = %v, row groups = %v, want none", metadata[3], metadata[4])
+	}
+}
+
+func TestWriter_Write_Invalid(t *testing.T) {
+	columns := []Column{
+		{Name: "ip", Type: String},
+		{Name: "providers", Type: Int32},
+	}
+	tests := []struct {
+		name string
+		row  []any
+	}{
+		{"null in required column", []any{"8.8.8.8", nil}},
+		{"wrong type", []any{"8.8.8.8", 1.5}},
+		{"out of range", []any{"8.8.8.8", math.MaxInt32 + 1}},
+		{"too few values", []any{"8.8.8.8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, columns)
+			if err := w.Write(tt.row...); err == nil {
+				t.Fatal("Write() error = nil, want an error")
+			}
+			// The row is not partly written
+			if err := w.Write("1.1.1.1", 2); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if _, got := readFile(t, buf.Bytes()); !reflect.DeepEqual(got, [][]any{{"1.1.1.1"}, {2}}) {
+				t.Errorf("columns = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package parquet
+
+import "encoding/binary"
+
+// Types of the Thrift compact protocol, in which Parquet encodes its page
+// headers and file metadata.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Thrift structs in the compact protocol. Fields are
+// written in increasing order of their ids within each struct.
+type thriftWriter struct {
+	buf []byte
+
+	// last is the id of the latest field written, by open struct
+	last []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// field writes the header of field id of type typ, as a delta from the
+// previous field of the struct when small enough.
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) string(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// list writes the header of field id, a list of n elements of type typ,
+// which are written next: structs with beginStruct and endStruct, other
+// values with element.
+func (t *thriftWriter) list(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	t.listHeader(typ, n)
+}
+
+func (t *thriftWriter) listHeader(typ byte, n int) {
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|typ)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|typ)
+	t.varint(uint64(n))
+}
+
+// i32Element and stringElement write an element of a list.
+func (t *thriftWriter) i32Element(v int32) {
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) stringElement(v string) {
+	t.varint(uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// beginStruct opens a struct: field id of the enclosing struct, or an
+// element of a list when id is 0, or the top-level struct when no struct
+// is open.
+func (t *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		t.field(id, thriftStruct)
+	}
+	t.last = append(t.last, 0)
+}
+
+// endStruct closes the struct opened last.
+func (t *thriftWriter) endStruct() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
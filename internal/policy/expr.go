@@ -0,0 +1,375 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// kind is the type of a field or an expression.
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindBool
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	default:
+		return "boolean"
+	}
+}
+
+// expr is a compiled condition, evaluated against the fields of a report.
+type expr interface {
+	eval(f fields) bool
+}
+
+type (
+	andExpr struct{ left, right expr }
+	orExpr  struct{ left, right expr }
+	notExpr struct{ e expr }
+
+	// boolField is a boolean field used as a condition, e.g. "is_vpn"
+	boolField struct{ name string }
+
+	// compareExpr compares a field with a literal
+	compareExpr struct {
+		field string
+		kind  kind
+		op    string
+		value any
+	}
+
+	// inExpr tests whether a string field is one of a list of values
+	inExpr struct {
+		field  string
+		values []string
+	}
+)
+
+func (e andExpr) eval(f fields) bool { return e.left.eval(f) && e.right.eval(f) }
+func (e orExpr) eval(f fields) bool  { return e.left.eval(f) || e.right.eval(f) }
+func (e notExpr) eval(f fields) bool { return !e.e.eval(f) }
+
+func (e boolField) eval(f fields) bool {
+	b, _ := f[e.name].(bool)
+	return b
+}
+
+func (e compareExpr) eval(f fields) bool {
+	switch e.kind {
+	case kindString:
+		s, _ := f[e.field].(string)
+		equal := strings.EqualFold(s, e.value.(string))
+		return equal == (e.op == "==")
+	case kindBool:
+		b, _ := f[e.field].(bool)
+		return (b == e.value.(bool)) == (e.op == "==")
+	default:
+		n, _ := f[e.field].(float64)
+		v := e.value.(float64)
+		switch e.op {
+		case "==":
+			return n == v
+		case "!=":
+			return n != v
+		case "<":
+			return n < v
+		case "<=":
+			return n <= v
+		case ">":
+			return n > v
+		default:
+			return n >= v
+		}
+	}
+}
+
+func (e inExpr) eval(f fields) bool {
+	s, _ := f[e.field].(string)
+	for _, v := range e.values {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// comparisons are the comparison operators.
+var comparisons = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// token is a lexical token of a condition.
+type token struct {
+	kind string // "ident", "string", "number", "op" or "eof"
+	text string
+	pos  int
+}
+
+// lex splits a condition into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexRune(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i+1)
+			}
+			tokens = append(tokens, token{"string", src[i+1 : i+1+end], i})
+			i += end + 2
+		case strings.ContainsRune("()[],", c):
+			tokens = append(tokens, token{"op", string(c), i})
+			i++
+		case strings.ContainsRune("=!<>", c):
+			op := string(c)
+			if i+1 < len(src) && src[i+1] == '=' {
+				op += "="
+			}
+			switch op {
+			case "=":
+				return nil, fmt.Errorf("unexpected %q at %d: use == or !=", op, i+1)
+			case "!":
+				tokens = append(tokens, token{"ident", "not", i})
+			default:
+				tokens = append(tokens, token{"op", op, i})
+			}
+			i += len(op)
+		case c == '&' || c == '|':
+			if i+1 >= len(src) || rune(src[i+1]) != c {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i+1)
+			}
+			op := "and"
+			if c == '|' {
+				op = "or"
+			}
+			tokens = append(tokens, token{"ident", op, i})
+			i += 2
+		case c == '-' || c == '.' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '.' || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{"number", src[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '-' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{"ident", src[i:j], i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i+1)
+		}
+	}
+	return append(tokens, token{kind: "eof", pos: len(src)}), nil
+}
+
+// parser is a recursive descent parser of conditions:
+//
+//	or      = and { "or" and }
+//	and     = not { "and" not }
+//	not     = "not" not | primary
+//	primary = "(" or ")" | field [ cmp literal | [ "not" ] "in" list ]
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse compiles a condition.
+func parse(src string) (expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether t is the keyword kw, in any case.
+func keyword(t token, kw string) bool {
+	return t.kind == "ident" && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	if t.kind == "eof" {
+		return fmt.Errorf("unexpected end of condition")
+	}
+	return fmt.Errorf("at %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for keyword(p.peek(), "or") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for keyword(p.peek(), "and") {
+		p.next()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if keyword(p.peek(), "not") {
+		p.next()
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	if t.kind == "op" && t.text == "(" {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != "op" || t.text != ")" {
+			return nil, p.errorf(t, "expected ')'")
+		}
+		return e, nil
+	}
+
+	if t.kind != "ident" {
+		return nil, p.errorf(t, "expected a field, got %q", t.text)
+	}
+	name := strings.ToLower(t.text)
+	k, ok := fieldKinds[name]
+	if !ok {
+		return nil, p.errorf(t, "unknown field %q", t.text)
+	}
+
+	op := p.peek()
+	switch {
+	case op.kind == "op" && comparisons[op.text]:
+		p.next()
+		return p.compare(name, k, op)
+	case keyword(op, "in"):
+		p.next()
+		return p.in(name, k)
+	case keyword(op, "not") && keyword(p.tokens[p.pos+1], "in"):
+		p.next()
+		p.next()
+		e, err := p.in(name, k)
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+
+	if k != kindBool {
+		return nil, p.errorf(t, "%s is a %s, not a condition: compare it with a value", name, k)
+	}
+	return boolField{name}, nil
+}
+
+func (p *parser) compare(name string, k kind, op token) (expr, error) {
+	if k != kindNumber && op.text != "==" && op.text != "!=" {
+		return nil, p.errorf(op, "%s is a %s: only == and != apply", name, k)
+	}
+
+	t := p.next()
+	switch k {
+	case kindString:
+		if t.kind != "string" {
+			return nil, p.errorf(t, "%s is compared with a quoted string", name)
+		}
+		return compareExpr{name, k, op.text, t.text}, nil
+	case kindNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if t.kind != "number" || err != nil {
+			return nil, p.errorf(t, "%s is compared with a number", name)
+		}
+		return compareExpr{name, k, op.text, n}, nil
+	default:
+		if !keyword(t, "true") && !keyword(t, "false") {
+			return nil, p.errorf(t, "%s is compared with true or false", name)
+		}
+		return compareExpr{name, k, op.text, keyword(t, "true")}, nil
+	}
+}
+
+// in parses a list of strings, quoted or bare such as [US, CA].
+func (p *parser) in(name string, k kind) (expr, error) {
+	if k != kindString {
+		return nil, fmt.Errorf("%s is a %s: only strings can be tested with in", name, k)
+	}
+	if t := p.next(); t.kind != "op" || t.text != "[" {
+		return nil, p.errorf(t, "expected '[' after in")
+	}
+
+	e := inExpr{field: name}
+	for {
+		t := p.next()
+		if t.kind == "op" && t.text == "]" && len(e.values) == 0 {
+			return e, nil
+		}
+		if t.kind != "string" && t.kind != "ident" && t.kind != "number" {
+			return nil, p.errorf(t, "expected a value in the list of %s", name)
+		}
+		e.values = append(e.values, t.text)
+
+		switch t := p.next(); {
+		case t.kind == "op" && t.text == "]":
+			return e, nil
+		case t.kind != "op" || t.text != ",":
+			return nil, p.errorf(t, "expected ',' or ']'")
+		}
+	}
+}
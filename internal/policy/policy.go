@@ -0,0 +1,206 @@
+// Package policy evaluates reports against configured rules, such as
+// "country not in [US, CA] and is_vpn", to reach an automated decision:
+// allow, review or block. The decision is reported and sets the exit code,
+// so that ipintel can gate automation directly.
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"api-client/internal/model"
+)
+
+// Actions a rule can decide on, from the least to the most severe.
+const (
+	ActionAllow  = "allow"
+	ActionReview = "review"
+	ActionBlock  = "block"
+)
+
+// exitCodes are the exit codes of the actions. 1 is taken by errors.
+var exitCodes = map[string]int{
+	ActionAllow:  0,
+	ActionReview: 2,
+	ActionBlock:  3,
+}
+
+// ExitCode returns the exit code for a decision on action.
+func ExitCode(action string) int {
+	return exitCodes[action]
+}
+
+// Rule decides on Action for the reports matching When. A rule without a
+// condition matches every report.
+type Rule struct {
+	Name   string
+	When   string
+	Action string
+}
+
+type compiledRule struct {
+	Rule
+	cond expr
+}
+
+// Engine evaluates reports against an ordered list of rules.
+type Engine struct {
+	rules []compiledRule
+}
+
+// New compiles rules, failing on the first invalid one.
+func New(rules []Rule) (*Engine, error) {
+	e := &Engine{rules: make([]compiledRule, len(rules))}
+	for i, r := range rules {
+		label := r.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		if _, ok := exitCodes[r.Action]; !ok {
+			return nil, fmt.Errorf("policy rule %s: invalid action %q: must be 'allow', 'review' or 'block'", label, r.Action)
+		}
+
+		e.rules[i] = compiledRule{Rule: r}
+		if strings.TrimSpace(r.When) == "" {
+			continue
+		}
+		cond, err := parse(r.When)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", label, err)
+		}
+		e.rules[i].cond = cond
+	}
+	return e, nil
+}
+
+// Evaluate returns the decision of the first rule matching report, or to
+// allow it when none does.
+func (e *Engine) Evaluate(report model.Report) model.PolicyDecision {
+	f := reportFields(report)
+	for i, r := range e.rules {
+		if r.cond == nil || r.cond.eval(f) {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return model.PolicyDecision{Action: r.Action, Rule: name}
+		}
+	}
+	return model.PolicyDecision{Action: ActionAllow}
+}
+
+// Condition is a compiled condition, such as the "when" of a rule.
+type Condition struct {
+	e expr
+}
+
+// Compile compiles the condition src.
+func Compile(src string) (*Condition, error) {
+	e, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Condition{e: e}, nil
+}
+
+// Match reports whether report satisfies the condition.
+func (c *Condition) Match(report model.Report) bool {
+	return c.e.eval(reportFields(report))
+}
+
+// MatchValues reports whether the values of a report, as returned by
+// Values, satisfy the condition.
+func (c *Condition) MatchValues(values map[string]any) bool {
+	return c.e.eval(values)
+}
+
+// Values returns the values of report that conditions refer to, by name:
+// strings, booleans and float64 numbers, which survive a round trip
+// through JSON, so that they can be stored and compared with those of a
+// later report. Those the report lacks, such as security flags when no
+// provider reports them, are left out.
+func Values(report model.Report) map[string]any {
+	return reportFields(report)
+}
+
+// Fields returns the names of the fields conditions can refer to, sorted.
+func Fields() []string {
+	names := make([]string, 0, len(fieldKinds))
+	for name := range fieldKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fields are the values of a report that conditions refer to, by name.
+type fields map[string]any
+
+// fieldKinds are the types of the fields of reportFields.
+var fieldKinds = map[string]kind{
+	"ip":                  kindString,
+	"country":             kindString,
+	"country_code":        kindString,
+	"country_name":        kindString,
+	"region":              kindString,
+	"city":                kindString,
+	"isp":                 kindString,
+	"org":                 kindString,
+	"asn":                 kindString,
+	"hostname":            kindString,
+	"prefix":              kindString,
+	"special_use":         kindString,
+	"verdict":             kindString,
+	"is_anycast":          kindBool,
+	"is_vpn":              kindBool,
+	"is_proxy":            kindBool,
+	"is_tor":              kindBool,
+	"is_relay":            kindBool,
+	"is_hosting":          kindBool,
+	"is_special_use":      kindBool,
+	"risk_score":          kindNumber,
+	"providers_succeeded": kindNumber,
+	"providers_failed":    kindNumber,
+}
+
+// reportFields extracts the fields of report from its consensus.
+func reportFields(report model.Report) fields {
+	c := report.Consensus()
+	f := fields{
+		"ip":                  report.IP.String(),
+		"country":             c.CountryCode,
+		"country_code":        c.CountryCode,
+		"country_name":        c.Country,
+		"region":              c.Region,
+		"city":                c.City,
+		"isp":                 c.ISP,
+		"org":                 c.Org,
+		"asn":                 c.ASN,
+		"hostname":            c.Hostname,
+		"is_anycast":          report.IsAnycast,
+		"providers_succeeded": float64(report.SuccessCount()),
+		"providers_failed":    float64(report.ErrorCount()),
+	}
+
+	if c.Prefix != nil {
+		f["prefix"] = c.Prefix.String()
+	}
+
+	if s := c.Security; s != nil {
+		f["is_vpn"] = s.VPN
+		f["is_proxy"] = s.Proxy
+		f["is_tor"] = s.Tor
+		f["is_relay"] = s.Relay
+		f["is_hosting"] = s.Hosting
+	}
+	if su := c.SpecialUse; su != nil {
+		f["special_use"] = su.Category
+		f["is_special_use"] = true
+	}
+	if risk := report.Risk(); risk != nil {
+		f["risk_score"] = risk.Score
+		f["verdict"] = string(risk.Verdict)
+	}
+	return f
+}
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func makeReport(country string, vpn bool, score float64) model.Report {
+	ip := model.MustParseAddr("192.0.2.1")
+	return model.Report{
+		IP: ip,
+		Results: []model.ProviderResult{
+			{Provider: "ipinfo", Result: &model.Geolocation{
+				IP:          ip,
+				CountryCode: country,
+				City:        "Somewhere",
+				ASN:         "AS64500",
+				Security:    &model.Security{VPN: vpn},
+				Reputation:  &model.Reputation{Score: score},
+			}},
+			{Provider: "ip-api", Error: "timeout"},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	valid := []string{
+		"is_vpn",
+		"not is_vpn",
+		"country not in [US, CA] and is_vpn",
+		"country in ['US', \"CA\"] && not (is_tor || is_proxy)",
+		"risk_score >= 50.5 or verdict == 'malicious'",
+		"(city == 'Paris' OR city == 'Lyon') AND NOT is_hosting",
+		"asn != 'AS64500' and providers_failed > 0",
+		"is_vpn == true",
+		"country in []",
+		"country_code != \"US\" && is_vpn",
+		"!is_vpn && !(risk_score > 10)",
+	}
+	for _, src := range valid {
+		if _, err := parse(src); err != nil {
+			t.Errorf("parse(%q) error = %v", src, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                         "unexpected end",
+		"is_vpn and":               "unexpected end",
+		"country":                  "not a condition",
+		"colour == 'red'":          "unknown field",
+		"country == US":            "quoted string",
+		"country < 'US'":           "only == and !=",
+		"risk_score == 'high'":     "number",
+		"is_vpn == maybe":          "true or false",
+		"risk_score in [1, 2]":     "only strings",
+		"country in [US CA]":       "expected ',' or ']'",
+		"country = 'US'":           "use == or !=",
+		"(is_vpn":                  "unexpected end",
+		"is_vpn is_tor":            "unexpected \"is_tor\"",
+		"country == 'US":           "unterminated string",
+		"is_vpn & is_tor":          "unexpected",
+		"country not 'US'":         "not a condition",
+		"country in 'US'":          "expected '['",
+		"is_vpn or risk_score > x": "number",
+	}
+	for src, want := range invalid {
+		_, err := parse(src)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parse(%q) error = %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	engine, err := New([]Rule{
+		{Name: "foreign-vpn", When: "country not in [US, CA] and is_vpn", Action: ActionBlock},
+		{Name: "risky", When: "risk_score >= 50 or verdict == 'malicious'", Action: ActionReview},
+		{Name: "partial", When: "providers_failed > 0 and country == 'fr'", Action: ActionReview},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		report model.Report
+		want   model.PolicyDecision
+	}{
+		{"foreign vpn", makeReport("DE", true, 0), model.PolicyDecision{Action: ActionBlock, Rule: "foreign-vpn"}},
+		{"domestic vpn", makeReport("us", true, 0), model.PolicyDecision{Action: ActionAllow}},
+		{"risky", makeReport("US", false, 60), model.PolicyDecision{Action: ActionReview, Rule: "risky"}},
+		{"case-insensitive strings", makeReport("FR", false, 0), model.PolicyDecision{Action: ActionReview, Rule: "partial"}},
+		{"no match", makeReport("DE", false, 10), model.PolicyDecision{Action: ActionAllow}},
+		{"no data", model.Report{IP: model.MustParseAddr("192.0.2.1")}, model.PolicyDecision{Action: ActionAllow}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := engine.Evaluate(tt.report); got != tt.want {
+				t.Errorf("Evaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCondition_Match(t *testing.T) {
+	cond, err := Compile(`country_code != "US" && !is_vpn`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	for _, tt := range []struct {
+		report model.Report
+		want   bool
+	}{
+		{makeReport("DE", false, 0), true},
+		{makeReport("DE", true, 0), false},
+		{makeReport("us", false, 0), false},
+	} {
+		if got := cond.Match(tt.report); got != tt.want {
+			t.Errorf("Match(%s) = %v, want %v", tt.report.Consensus().CountryCode, got, tt.want)
+		}
+	}
+
+	if _, err := Compile("country_code ="); err == nil {
+		t.Error("Compile() of an invalid condition expected error")
+	}
+}
+
+func TestEngine_DefaultRule(t *testing.T) {
+	engine, err := New([]Rule{
+		{When: "is_tor", Action: ActionBlock},
+		{Action: ActionReview},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	want := model.PolicyDecision{Action: ActionReview, Rule: "#2"}
+	if got := engine.Evaluate(makeReport("US", false, 0)); got != want {
+		t.Errorf("Evaluate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		rules []Rule
+		want  string
+	}{
+		{[]Rule{{Name: "x", When: "is_vpn", Action: "deny"}}, `policy rule x: invalid action "deny"`},
+		{[]Rule{{When: "is_vpn", Action: ActionAllow}, {When: "country", Action: ActionBlock}}, "policy rule #2:"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.rules)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("New(%+v) error = %v, want %q", tt.rules, err, tt.want)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for action, want := range map[string]int{ActionAllow: 0, ActionReview: 2, ActionBlock: 3} {
+		if got := ExitCode(action); got != want {
+			t.Errorf("ExitCode(%q) = %d, want %d", action, got, want)
+		}
+	}
+}
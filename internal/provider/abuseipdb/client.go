@@ -0,0 +1,141 @@
+// Package abuseipdb provides a client for the check endpoint of AbuseIPDB,
+// a database of addresses reported for abuse, whose confidence score is
+// reported as the reputation of an address.
+package abuseipdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+)
+
+const (
+	// ProviderName identifies this provider in reports.
+	ProviderName = "abuseipdb"
+
+	// BaseURL is the API endpoint.
+	BaseURL = "https://api.abuseipdb.com/api/v2/check"
+
+	// maxAgeInDays is how far back reports are taken into account, the
+	// longest the API allows without a paid plan.
+	maxAgeInDays = "90"
+)
+
+var _ provider.Provider = &Client{}
+
+// response represents the JSON structure returned by the check endpoint.
+type response struct {
+	Data struct {
+		AbuseConfidenceScore *float64 `json:"abuseConfidenceScore"`
+		IsTor                bool     `json:"isTor"`
+		TotalReports         int      `json:"totalReports"`
+	} `json:"data"`
+	Errors []struct {
+		Detail string `json:"detail"`
+	} `json:"errors"`
+}
+
+func (r response) toGeoLocation(ip model.IPAddress) model.Geolocation {
+	geo := model.Geolocation{
+		IP:         ip,
+		Reputation: &model.Reputation{Score: *r.Data.AbuseConfidenceScore},
+	}
+	if r.Data.IsTor {
+		geo.Security = &model.Security{Tor: true}
+	}
+	return geo
+}
+
+// Client looks addresses up in AbuseIPDB.
+type Client struct {
+	requester provider.HttpRequester
+	baseURL   string
+	apiKey    string
+	timeout   time.Duration
+}
+
+// New creates a new AbuseIPDB client. The API needs a key, set with
+// option.WithAPIKey.
+func New(opts ...option.Option) *Client {
+	s := option.Apply(option.Settings{BaseURL: BaseURL}, opts...)
+
+	return &Client{
+		requester: provider.WithCompression(s.Requester),
+		baseURL:   s.BaseURL,
+		apiKey:    s.APIKey,
+		timeout:   s.Timeout,
+	}
+}
+
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return ProviderName
+}
+
+// Describe reports the request Check would make for ip.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	return provider.Description{
+		Name:    ProviderName,
+		URL:     c.url(ip),
+		Timeout: c.timeout,
+		APIKey:  provider.RedactKey(c.apiKey),
+	}
+}
+
+// url builds the request URL for ip; the key is sent in a header.
+func (c *Client) url(ip model.IPAddress) string {
+	return c.baseURL + "?ipAddress=" + url.QueryEscape(ip.String()) + "&maxAgeInDays=" + maxAgeInDays
+}
+
+// Check looks up the abuse confidence score of the given IP address.
+func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if c.apiKey == "" {
+		return model.Geolocation{}, errors.New("no API key configured")
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ip), nil)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Key", c.apiKey)
+
+	resp, err := c.requester.Do(req)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("executing request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	provider.RecordQuota(ctx, resp.Header)
+
+	var apiResp response
+	decodeErr := json.NewDecoder(resp.Body).Decode(&apiResp)
+
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && len(apiResp.Errors) > 0 {
+			return model.Geolocation{}, fmt.Errorf("API error: %s", apiResp.Errors[0].Detail)
+		}
+		return model.Geolocation{}, provider.StatusError{StatusCode: resp.StatusCode}
+	}
+	if decodeErr != nil {
+		return model.Geolocation{}, fmt.Errorf("decoding response: %w", decodeErr)
+	}
+	if apiResp.Data.AbuseConfidenceScore == nil {
+		return model.Geolocation{}, errors.New("decoding response: no abuse confidence score")
+	}
+
+	return apiResp.toGeoLocation(ip), nil
+}
@@ -0,0 +1,96 @@
+package abuseipdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+)
+
+func TestClient_Check_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("ipAddress"); got != "192.0.2.1" {
+			t.Errorf("ipAddress = %q, want 192.0.2.1", got)
+		}
+		if got := r.Header.Get("Key"); got != "secret" {
+			t.Errorf("Key = %q, want secret", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"data": {"ipAddress": "192.0.2.1", "abuseConfidenceScore": 87, "isTor": true, "totalReports": 12}
+		}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL), option.WithAPIKey("secret"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if geo.Reputation == nil || geo.Reputation.Score != 87 {
+		t.Errorf("Reputation = %+v, want a score of 87", geo.Reputation)
+	}
+	if geo.Security == nil || !geo.Security.Tor {
+		t.Errorf("Security = %+v, want tor", geo.Security)
+	}
+	if geo.HasLocation() {
+		t.Errorf("Check() reported a location: %+v", geo)
+	}
+}
+
+func TestClient_Check_Clean(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"abuseConfidenceScore": 0, "isTor": false}}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL), option.WithAPIKey("secret"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if geo.Reputation == nil || geo.Reputation.Score != 0 || geo.Security != nil {
+		t.Errorf("Check() = %+v, want a clean reputation", geo)
+	}
+}
+
+func TestClient_Check_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"errors": [{"detail": "Authentication failed.", "status": 401}]}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL), option.WithAPIKey("wrong"))
+	if _, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err == nil || err.Error() != "API error: Authentication failed." {
+		t.Errorf("Check() error = %v, want the API error", err)
+	}
+
+	client = New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL))
+	if _, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err == nil {
+		t.Error("Check() without an API key expected error")
+	}
+}
+
+func TestClient_Check_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL), option.WithAPIKey("secret"))
+
+	_, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	var statusErr provider.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Check() error = %v, want status 429", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package bogon classifies special-purpose addresses, such as private,
+// documentation and shared (CGNAT) ranges, from an embedded copy of the
+// IANA special-purpose address registries. Remote providers often return
+// misleading locations for these addresses, or fail on them.
+package bogon
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+// ProviderName is the name of the bogon provider.
+const ProviderName = "bogon"
+
+//go:embed special-use.txt
+var bundled string
+
+// errNotSpecialUse is returned for addresses outside every special-purpose range.
+var errNotSpecialUse = provider.NotApplicableError{Reason: "not a special-use address"}
+
+var _ provider.Provider = &Client{}
+
+// entry is a special-purpose range.
+type entry struct {
+	prefix netip.Prefix
+	use    model.SpecialUse
+}
+
+// Client is a Provider answering from the embedded registry, without any
+// network request. Addresses outside every special-purpose range are
+// reported with a provider.NotApplicableError.
+type Client struct {
+	entries []entry
+}
+
+// New creates a Client using the registry bundled with the binary.
+func New() *Client {
+	c, err := Parse(strings.NewReader(bundled))
+	if err != nil {
+		panic(fmt.Sprintf("bogon: invalid bundled registry: %v", err))
+	}
+	return c
+}
+
+// Parse reads a registry of one range per line: prefix, category, whether
+// it is globally reachable ("yes", "no" or "n/a"), the defining RFC and a
+// name. Blank lines and lines starting with '#' are ignored.
+func Parse(r io.Reader) (*Client, error) {
+	c := &Client{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("line %d: want prefix, category, reachability, RFC and name", line)
+		}
+
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var global *bool
+		switch fields[2] {
+		case "yes", "no":
+			reachable := fields[2] == "yes"
+			global = &reachable
+		case "n/a":
+		default:
+			return nil, fmt.Errorf("line %d: reachability must be 'yes', 'no' or 'n/a', got %q", line, fields[2])
+		}
+
+		prefix = prefix.Masked()
+		c.entries = append(c.entries, entry{
+			prefix: prefix,
+			use: model.SpecialUse{
+				Prefix:            prefix.String(),
+				Category:          fields[1],
+				Name:              strings.Join(fields[4:], " "),
+				RFC:               fields[3],
+				GloballyReachable: global,
+			},
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Name implements provider.Provider.
+func (c *Client) Name() string {
+	return ProviderName
+}
+
+// Classify returns the most specific special-purpose range containing ip.
+func (c *Client) Classify(ip model.IPAddress) (model.SpecialUse, bool) {
+	var best *entry
+	for i, e := range c.entries {
+		if e.prefix.Contains(ip) && (best == nil || e.prefix.Bits() > best.prefix.Bits()) {
+			best = &c.entries[i]
+		}
+	}
+	if best == nil {
+		return model.SpecialUse{}, false
+	}
+	return best.use, true
+}
+
+// Check implements provider.Checker.
+func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	use, ok := c.Classify(ip)
+	if !ok {
+		return model.Geolocation{}, errNotSpecialUse
+	}
+	return model.Geolocation{IP: ip, SpecialUse: &use}, nil
+}
+
+// Describe implements provider.Describer.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	return provider.Description{Name: ProviderName, Local: true, Authoritative: true}
+}
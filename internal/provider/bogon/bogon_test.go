@@ -0,0 +1,119 @@
+package bogon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+func TestClient_Classify(t *testing.T) {
+	tests := []struct {
+		ip       string
+		category string
+		prefix   string
+	}{
+		{"10.1.2.3", "private", "10.0.0.0/8"},
+		{"172.31.255.255", "private", "172.16.0.0/12"},
+		{"100.64.12.34", "cgnat", "100.64.0.0/10"},
+		{"127.0.0.1", "loopback", "127.0.0.0/8"},
+		{"169.254.169.254", "link-local", "169.254.0.0/16"},
+		{"192.0.2.10", "documentation", "192.0.2.0/24"},
+		{"198.51.100.1", "documentation", "198.51.100.0/24"},
+		{"203.0.113.99", "documentation", "203.0.113.0/24"},
+		{"198.19.0.1", "benchmarking", "198.18.0.0/15"},
+		{"0.0.0.0", "this-network", "0.0.0.0/32"},
+		{"192.0.0.9", "reserved", "192.0.0.9/32"},
+		{"239.1.1.1", "multicast", "224.0.0.0/4"},
+		{"250.0.0.1", "reserved", "240.0.0.0/4"},
+		{"255.255.255.255", "broadcast", "255.255.255.255/32"},
+		{"::", "unspecified", "::/128"},
+		{"::1", "loopback", "::1/128"},
+		{"2001:db8::1", "documentation", "2001:db8::/32"},
+		{"2001:0:4136:e378::1", "translation", "2001::/32"},
+		{"fd12:3456::1", "private", "fc00::/7"},
+		{"fe80::1", "link-local", "fe80::/10"},
+		{"ff02::1", "multicast", "ff00::/8"},
+	}
+
+	c := New()
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			use, ok := c.Classify(model.MustParseAddr(tt.ip))
+			if !ok {
+				t.Fatalf("Classify(%s) found no range", tt.ip)
+			}
+			if use.Category != tt.category || use.Prefix != tt.prefix {
+				t.Errorf("Classify(%s) = %s %s, want %s %s", tt.ip, use.Category, use.Prefix, tt.category, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestClient_Classify_Public(t *testing.T) {
+	c := New()
+	for _, ip := range []string{"8.8.8.8", "1.1.1.1", "100.128.0.1", "2606:4700:4700::1111"} {
+		if use, ok := c.Classify(model.MustParseAddr(ip)); ok {
+			t.Errorf("Classify(%s) = %v, want no range", ip, use)
+		}
+	}
+}
+
+func TestClient_Check(t *testing.T) {
+	c := New()
+	ip := model.MustParseAddr("100.64.0.1")
+
+	geo, err := c.Check(context.Background(), ip)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if geo.SpecialUse == nil || geo.SpecialUse.Name != "Shared Address Space" || geo.SpecialUse.RFC != "RFC6598" {
+		t.Errorf("SpecialUse = %+v, want Shared Address Space (RFC6598)", geo.SpecialUse)
+	}
+	if geo.SpecialUse.GloballyReachable == nil || *geo.SpecialUse.GloballyReachable {
+		t.Error("shared address space should not be globally reachable")
+	}
+	if geo.IP != ip {
+		t.Errorf("IP = %v, want %v", geo.IP, ip)
+	}
+}
+
+func TestClient_Check_NotApplicable(t *testing.T) {
+	_, err := New().Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+
+	var notApplicable provider.NotApplicableError
+	if !errors.As(err, &notApplicable) {
+		t.Fatalf("Check() error = %v, want a NotApplicableError", err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"too few fields", "10.0.0.0/8 private no\n", "line 1"},
+		{"bad prefix", "# comment\n10.0.0.0/33 private no RFC1918 Private-Use\n", "line 2"},
+		{"bad reachability", "10.0.0.0/8 private maybe RFC1918 Private-Use\n", "reachability"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Describe(t *testing.T) {
+	d := provider.Describe(New(), model.MustParseAddr("10.0.0.1"))
+	if d.Name != ProviderName || !d.Local || !d.Authoritative {
+		t.Errorf("Describe() = %+v, want a local, authoritative description", d)
+	}
+}
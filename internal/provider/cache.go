@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// cacheProvider keeps the successful results of the wrapped Provider in
+// memory.
+type cacheProvider struct {
+	wrapped
+	cache *resultCache
+}
+
+// WithCache wraps p so that its successful results are kept in memory for
+// ttl, and reused instead of checking the same address again. At most size
+// addresses are kept, the least recently used ones making room for new
+// ones. Failures are not cached. Unlike the HTTP cache of --cache-dir, it
+// caches any provider, local or not, but only for the life of the process.
+// A non-positive ttl or size returns p unchanged.
+func WithCache(p Provider, ttl time.Duration, size int) Provider {
+	if ttl <= 0 || size <= 0 {
+		return p
+	}
+	return cacheProvider{
+		wrapped: wrapped{p},
+		cache:   &resultCache{ttl: ttl, size: size, entries: make(map[model.IPAddress]*list.Element), order: list.New()},
+	}
+}
+
+func (cp cacheProvider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if geo, ok := cp.cache.get(ip, time.Now()); ok {
+		return geo, nil
+	}
+
+	geo, err := cp.Provider.Check(ctx, ip)
+	if err == nil {
+		cp.cache.put(ip, geo, time.Now())
+	}
+	return geo, err
+}
+
+// resultCache is a least recently used cache of results by address.
+type resultCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[model.IPAddress]*list.Element
+	// order holds the cached entries, the most recently used first
+	order *list.List
+}
+
+type cacheEntry struct {
+	ip      model.IPAddress
+	geo     model.Geolocation
+	expires time.Time
+}
+
+// get returns the result cached for ip, unless it has expired at now.
+func (c *resultCache) get(ip model.IPAddress, now time.Time) (model.Geolocation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[ip]
+	if !ok {
+		return model.Geolocation{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, ip)
+		return model.Geolocation{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.geo, true
+}
+
+// put caches geo as the result for ip from now, evicting the least
+// recently used entry when the cache is full.
+func (c *resultCache) put(ip model.IPAddress, geo model.Geolocation, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{ip: ip, geo: geo, expires: now.Add(c.ttl)}
+	if elem, ok := c.entries[ip]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).ip)
+	}
+	c.entries[ip] = c.order.PushFront(entry)
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestWithCache(t *testing.T) {
+	calls := 0
+	fail := false
+	p := WithCache(NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		calls++
+		if fail {
+			return model.Geolocation{}, errors.New("boom")
+		}
+		return model.Geolocation{IP: ip, Country: "United States"}, nil
+	})), time.Minute, 10)
+
+	ip := model.MustParseAddr("8.8.8.8")
+	for i := 0; i < 3; i++ {
+		if geo, err := p.Check(context.Background(), ip); err != nil || geo.Country != "United States" {
+			t.Fatalf("Check() = %+v, %v", geo, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	// Failures are not cached
+	fail = true
+	other := model.MustParseAddr("1.1.1.1")
+	for i := 0; i < 2; i++ {
+		if _, err := p.Check(context.Background(), other); err == nil {
+			t.Error("Check() should fail")
+		}
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestResultCache_Expiry(t *testing.T) {
+	c := WithCache(NewTestProvider("p", nil), time.Minute, 10).(cacheProvider).cache
+	ip := model.MustParseAddr("8.8.8.8")
+	now := time.Now()
+
+	c.put(ip, model.Geolocation{IP: ip}, now)
+	if _, ok := c.get(ip, now.Add(59*time.Second)); !ok {
+		t.Error("entry should still be cached")
+	}
+	if _, ok := c.get(ip, now.Add(time.Minute)); ok {
+		t.Error("entry should have expired")
+	}
+	if len(c.entries) != 0 || c.order.Len() != 0 {
+		t.Errorf("expired entry kept: %d entries", len(c.entries))
+	}
+}
+
+func TestResultCache_Eviction(t *testing.T) {
+	c := WithCache(NewTestProvider("p", nil), time.Minute, 2).(cacheProvider).cache
+	a, b, d := model.MustParseAddr("10.0.0.1"), model.MustParseAddr("10.0.0.2"), model.MustParseAddr("10.0.0.3")
+	now := time.Now()
+
+	c.put(a, model.Geolocation{IP: a}, now)
+	c.put(b, model.Geolocation{IP: b}, now)
+	_, _ = c.get(a, now) // b is now the least recently used
+	c.put(d, model.Geolocation{IP: d}, now)
+
+	if _, ok := c.get(b, now); ok {
+		t.Error("least recently used entry should have been evicted")
+	}
+	for _, ip := range []model.IPAddress{a, d} {
+		if _, ok := c.get(ip, now); !ok {
+			t.Errorf("%s should be cached", ip)
+		}
+	}
+}
+
+func TestWithCache_Disabled(t *testing.T) {
+	p := NewTestProvider("p", nil)
+	if _, wrapped := WithCache(p, 0, 10).(cacheProvider); wrapped {
+		t.Error("WithCache(p, 0, n) should return p unchanged")
+	}
+	if _, wrapped := WithCache(p, time.Minute, 0).(cacheProvider); wrapped {
+		t.Error("WithCache(p, ttl, 0) should return p unchanged")
+	}
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AcceptEncoding is the Accept-Encoding header sent by WithCompression.
+const AcceptEncoding = "gzip, deflate"
+
+// compressionRequester negotiates compressed responses on behalf of the
+// wrapped HttpRequester and decompresses them.
+type compressionRequester struct {
+	next HttpRequester
+}
+
+// WithCompression wraps r so that requests ask for gzip or deflate encoded
+// responses, which are decompressed before being returned. Unlike the
+// transparent compression of http.Transport, this works for any
+// HttpRequester. Requests that already set Accept-Encoding are passed
+// through unchanged.
+func WithCompression(r HttpRequester) HttpRequester {
+	if _, ok := r.(compressionRequester); ok {
+		return r
+	}
+	return compressionRequester{next: r}
+}
+
+func (c compressionRequester) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" {
+		return c.next.Do(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
+
+	resp, err := c.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("decompressing response: %w", err)
+		}
+		body = gz
+	case "deflate":
+		body = newDeflateReader(resp.Body)
+	default:
+		return resp, nil
+	}
+
+	resp.Body = decompressedBody{Reader: body, Closer: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return resp, nil
+}
+
+// newDeflateReader decodes a "deflate" body. The encoding is specified as
+// zlib-wrapped, but some servers send a raw deflate stream, so the zlib
+// header is checked first.
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
+
+type decompressedBody struct {
+	io.Reader
+	io.Closer
+}
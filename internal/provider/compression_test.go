@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const payload = `{"country":"United States","city":"Mountain View"}`
+
+func compress(t *testing.T, encoding string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatalf("flate.NewWriter() error = %v", err)
+		}
+		w = fw
+	default:
+		return []byte(payload)
+	}
+
+	if _, err := w.Write([]byte(payload)); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestWithCompression(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentEncoding string
+	}{
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"raw-deflate", "deflate"},
+		{"identity", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := compress(t, tt.name)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != AcceptEncoding {
+					t.Errorf("Accept-Encoding = %q, want %q", got, AcceptEncoding)
+				}
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				_, _ = w.Write(body)
+			}))
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			resp, err := WithCompression(http.DefaultClient).Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+
+			if string(got) != payload {
+				t.Errorf("body = %q, want %q", got, payload)
+			}
+
+			if resp.Header.Get("Content-Encoding") != "" {
+				t.Error("Content-Encoding should be removed once decoded")
+			}
+		})
+	}
+}
+
+func TestWithCompression_CustomRequester(t *testing.T) {
+	body := compress(t, "gzip")
+
+	requester := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": []string{"gzip"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	resp, err := WithCompression(requester).Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	got, _ := io.ReadAll(resp.Body)
+	if string(got) != payload {
+		t.Errorf("body = %q, want %q", got, payload)
+	}
+}
+
+func TestWithCompression_InvalidGzip(t *testing.T) {
+	requester := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": []string{"gzip"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte("not gzip"))),
+		}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if _, err := WithCompression(requester).Do(req); err == nil {
+		t.Error("Do() expected error for a corrupt gzip body")
+	}
+}
+
+func TestWithCompression_KeepsExplicitAcceptEncoding(t *testing.T) {
+	requester := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		if got := req.Header.Get("Accept-Encoding"); got != "identity" {
+			t.Errorf("Accept-Encoding = %q, want identity", got)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	if _, err := WithCompression(requester).Do(req); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+}
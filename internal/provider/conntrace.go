@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// Phases of a request a timeout can happen in, see TimeoutError.
+const (
+	// TimeoutConnect is a timeout establishing the connection, TLS
+	// handshake included: the host is unreachable or filtered
+	TimeoutConnect = "connect"
+
+	// TimeoutRead is a timeout waiting for or reading the response once
+	// connected: the API is slow
+	TimeoutRead = "read"
+)
+
+// TimeoutError is a timeout classified by the phase of the request it
+// happened in, TimeoutConnect or TimeoutRead, since the remedy differs: a
+// firewall rule or proxy for the former, a longer timeout or another
+// provider for the latter.
+type TimeoutError struct {
+	Phase string
+	Err   error
+}
+
+func (e TimeoutError) Error() string {
+	if e.Phase == TimeoutConnect {
+		return fmt.Sprintf("connect timeout: %v", e.Err)
+	}
+	return fmt.Sprintf("read timeout: %v", e.Err)
+}
+
+func (e TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// ConnTracker follows, through httptrace, whether the latest HTTP request
+// of a check got a connection, so that a timeout can be told apart from one
+// reading the response. The zero ConnTracker is ready to use.
+type ConnTracker struct {
+	requested atomic.Bool
+	connected atomic.Bool
+}
+
+// WithConnTracker returns a context whose HTTP requests are followed by t.
+func WithConnTracker(ctx context.Context, t *ConnTracker) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.requested.Store(true)
+			t.connected.Store(false)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			t.connected.Store(true)
+		},
+	})
+}
+
+// Classify returns err as a TimeoutError when it is a timeout whose phase
+// is known: from the operation of a network error, such as the dial of a
+// WHOIS query, or else from whether the latest HTTP request followed by t
+// got a connection. Other errors are returned unchanged.
+func (t *ConnTracker) Classify(err error) error {
+	var nerr net.Error
+	timeout := errors.Is(err, context.DeadlineExceeded) || errors.As(err, &nerr) && nerr.Timeout()
+	if !timeout || errors.As(err, new(TimeoutError)) {
+		return err
+	}
+
+	var operr *net.OpError
+	switch {
+	case errors.As(err, &operr) && operr.Op == "dial":
+		return TimeoutError{Phase: TimeoutConnect, Err: err}
+	case errors.As(err, &operr) && operr.Op == "read":
+		return TimeoutError{Phase: TimeoutRead, Err: err}
+	case t.connected.Load():
+		return TimeoutError{Phase: TimeoutRead, Err: err}
+	case t.requested.Load():
+		return TimeoutError{Phase: TimeoutConnect, Err: err}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConnTracker_Classify_Read(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var conns ConnTracker
+	ctx, cancel := context.WithTimeout(WithConnTracker(context.Background(), &conns), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err := http.DefaultClient.Do(req)
+
+	var timeout TimeoutError
+	if !errors.As(conns.Classify(err), &timeout) || timeout.Phase != TimeoutRead {
+		t.Errorf("Classify(%v) = %v, want a read timeout", err, conns.Classify(err))
+	}
+}
+
+func TestConnTracker_Classify_Connect(t *testing.T) {
+	// A dial that never completes, as to a filtered port
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}}
+
+	var conns ConnTracker
+	ctx, cancel := context.WithTimeout(WithConnTracker(context.Background(), &conns), 50*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://192.0.2.1/", nil)
+	_, err := client.Do(req)
+
+	var timeout TimeoutError
+	if !errors.As(conns.Classify(err), &timeout) || timeout.Phase != TimeoutConnect {
+		t.Errorf("Classify(%v) = %v, want a connect timeout", err, conns.Classify(err))
+	}
+	if !errors.Is(conns.Classify(err), context.DeadlineExceeded) {
+		t.Error("Classify() does not wrap the original error")
+	}
+}
+
+func TestConnTracker_Classify_Other(t *testing.T) {
+	var conns ConnTracker
+
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}
+	if err := conns.Classify(dial); !errors.As(err, new(TimeoutError)) || err.(TimeoutError).Phase != TimeoutConnect {
+		t.Errorf("Classify(dial timeout) = %v, want a connect timeout", err)
+	}
+
+	// Without a request or network operation, the phase is unknown
+	if err := conns.Classify(context.DeadlineExceeded); err != context.DeadlineExceeded {
+		t.Errorf("Classify(unknown phase) = %v, want it unchanged", err)
+	}
+	if err := conns.Classify(StatusError{StatusCode: 500}); errors.As(err, new(TimeoutError)) {
+		t.Errorf("Classify(status error) = %v, want it unchanged", err)
+	}
+	if err := conns.Classify(nil); err != nil {
+		t.Errorf("Classify(nil) = %v", err)
+	}
+}
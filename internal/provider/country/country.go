@@ -0,0 +1,153 @@
+// Package country answers which country an address is in from an offline
+// IP-to-country table, such as the one "ipintel update-data" downloads or
+// the compact one bundled with the binary.
+// It is coarse but needs no network access, and serves as a tie-breaker
+// and sanity check for the country reported by remote providers.
+package country
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+// ProviderName is the name of the country provider.
+const ProviderName = "country"
+
+//go:embed countries.tsv
+var bundled string
+
+// errNotCovered is returned for addresses outside every range of the table.
+var errNotCovered = provider.NotApplicableError{Reason: "not in the country table"}
+
+var _ provider.Provider = &Client{}
+
+// span is a range of addresses allocated to a country.
+type span struct {
+	first, last netip.Addr
+	code        string
+}
+
+// Client is a Provider answering from an in-memory IP-to-country table,
+// without any network request.
+type Client struct {
+	spans []span
+}
+
+// New creates a Client using the compact table bundled with the binary,
+// which only covers a few single-country ranges.
+func New() *Client {
+	c, err := Parse(strings.NewReader(bundled))
+	if err != nil {
+		panic(fmt.Sprintf("country: invalid bundled table: %v", err))
+	}
+	return c
+}
+
+// Parse reads IP-to-country tables of one range per line: first address,
+// last address and ISO 3166 country code, separated by whitespace, as in the
+// iptoasn.com ip2country files. Ranges with the code "None" are unallocated
+// and skipped, as are blank lines and lines starting with '#'.
+func Parse(readers ...io.Reader) (*Client, error) {
+	c := &Client{}
+
+	for _, r := range readers {
+		scanner := bufio.NewScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+
+			fields := strings.Fields(text)
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: want first address, last address and country code", line)
+			}
+			if fields[2] == "None" {
+				continue
+			}
+
+			first, err := netip.ParseAddr(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			last, err := netip.ParseAddr(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if first.BitLen() != last.BitLen() || last.Less(first) {
+				return nil, fmt.Errorf("line %d: invalid range %s-%s", line, first, last)
+			}
+			if len(fields[2]) != 2 {
+				return nil, fmt.Errorf("line %d: invalid country code %q", line, fields[2])
+			}
+
+			c.spans = append(c.spans, span{first: first, last: last, code: strings.ToUpper(fields[2])})
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(c.spans, func(i, j int) bool {
+		return c.spans[i].first.Less(c.spans[j].first)
+	})
+
+	return c, nil
+}
+
+// Len returns the number of ranges in the table.
+func (c *Client) Len() int {
+	return len(c.spans)
+}
+
+// Name implements provider.Provider.
+func (c *Client) Name() string {
+	return ProviderName
+}
+
+// Lookup returns the country code of ip.
+func (c *Client) Lookup(ip model.IPAddress) (string, bool) {
+	ip = ip.Unmap()
+
+	// The last range starting at or before ip is the only one that can
+	// contain it
+	i := sort.Search(len(c.spans), func(i int) bool {
+		return ip.Less(c.spans[i].first)
+	}) - 1
+	if i < 0 || c.spans[i].last.Less(ip) || c.spans[i].first.BitLen() != ip.BitLen() {
+		return "", false
+	}
+	return c.spans[i].code, true
+}
+
+// Check implements provider.Checker.
+func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	code, ok := c.Lookup(ip)
+	if !ok {
+		return model.Geolocation{}, errNotCovered
+	}
+
+	geo := model.Geolocation{IP: ip, CountryCode: code}
+	if region, err := language.ParseRegion(code); err == nil {
+		geo.Country = display.English.Regions().Name(region)
+	}
+	return geo, nil
+}
+
+// Describe implements provider.Describer.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	return provider.Description{Name: ProviderName, Local: true, Advisory: true}
+}
@@ -0,0 +1,110 @@
+package country
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+)
+
+const (
+	v4Table = `# first	last	country
+1.0.0.0	1.0.0.255	AU
+1.0.1.0	1.0.3.255	CN
+8.8.8.0	8.8.8.255	US
+9.0.0.0	9.255.255.255	None
+`
+	v6Table = `2001:4860::	2001:4860:ffff:ffff:ffff:ffff:ffff:ffff	US
+2a00:1450::	2a00:1450:ffff:ffff:ffff:ffff:ffff:ffff	IE
+`
+	table = v4Table + v6Table
+)
+
+func TestClient_Lookup(t *testing.T) {
+	c, err := Parse(strings.NewReader(v6Table), strings.NewReader(v4Table))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if c.Len() != 5 {
+		t.Errorf("Len() = %d, want 5 allocated ranges", c.Len())
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.2.200", "CN"},
+		{"8.8.8.8", "US"},
+		{"::ffff:8.8.4.4", ""},
+		{"::ffff:8.8.8.8", "US"},
+		{"9.1.1.1", ""},
+		{"0.0.0.1", ""},
+		{"255.255.255.255", ""},
+		{"2001:4860:4860::8888", "US"},
+		{"2a00:1450:4001::1", "IE"},
+		{"2a00:1451::1", ""},
+		{"::1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, ok := c.Lookup(model.MustParseAddr(tt.ip))
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Lookup() = %q, %v; want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Check(t *testing.T) {
+	c, err := Parse(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	geo, err := c.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if geo.CountryCode != "US" || geo.Country != "United States" {
+		t.Errorf("Check() = %q (%q), want United States (US)", geo.Country, geo.CountryCode)
+	}
+
+	var notApplicable provider.NotApplicableError
+	if _, err := c.Check(context.Background(), model.MustParseAddr("9.9.9.9")); !errors.As(err, &notApplicable) {
+		t.Errorf("Check() error = %v, want NotApplicableError", err)
+	}
+
+	if d := c.Describe(model.MustParseAddr("8.8.8.8")); !d.Local || !d.Advisory {
+		t.Error("Describe() should report a local, advisory provider")
+	}
+}
+
+func TestNew(t *testing.T) {
+	c := New()
+	for ip, want := range map[string]string{"8.8.8.8": "US", "2001:4860:4860::8888": "US", "3.5.0.1": "", "10.0.0.1": ""} {
+		if got, ok := c.Lookup(model.MustParseAddr(ip)); got != want || ok != (want != "") {
+			t.Errorf("Lookup(%s) = %q, %v; want %q", ip, got, ok, want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []string{
+		"1.0.0.0 1.0.0.255",
+		"1.0.0.0 1.0.0.255 AUS",
+		"1.0.0.x 1.0.0.255 AU",
+		"1.0.0.255 1.0.0.0 AU",
+		"1.0.0.0 ::1 AU",
+	}
+
+	for _, line := range tests {
+		if _, err := Parse(strings.NewReader(line)); err == nil || !strings.HasPrefix(err.Error(), "line 1:") {
+			t.Errorf("Parse(%q) error = %v, want a line 1 error", line, err)
+		}
+	}
+}
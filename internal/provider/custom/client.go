@@ -0,0 +1,283 @@
+// Package custom provides clients for the providers defined in the
+// configuration file rather than in code: HTTP APIs answering with JSON,
+// such as in-house geolocation services, queried at a URL template and
+// whose response fields are mapped onto a geolocation by JMESPath
+// expressions, from a plain field name such as "lat" to a path into nested
+// objects such as "data.location.lat".
+package custom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+	"api-client/internal/query"
+)
+
+// Placeholders replaced in the URL template and the header values.
+const (
+	// PlaceholderIP is replaced by the address looked up
+	PlaceholderIP = "{ip}"
+
+	// PlaceholderKey is replaced by the API key
+	PlaceholderKey = "{key}"
+)
+
+// Fields lists the geolocation fields responses can be mapped to.
+var Fields = []string{"country", "country_code", "region", "city", "latitude", "longitude", "isp", "org", "asn", "hostname", "reputation"}
+
+// Definition describes a custom provider.
+type Definition struct {
+	// URL is the request URL, such as "https://geo.example/{ip}?key={key}"
+	URL string
+
+	// Headers are sent with every request, such as an Authorization header
+	// of "Bearer {key}"
+	Headers map[string]string
+
+	// Fields maps geolocation fields, from Fields, to the JMESPath
+	// expressions extracting their values from the response, e.g.
+	// "country_code" to "cc" or "latitude" to "data.location.lat"
+	Fields map[string]string
+}
+
+var _ provider.Provider = &Client{}
+
+// Client queries a custom provider.
+type Client struct {
+	name      string
+	requester provider.HttpRequester
+	url       string
+	headers   map[string]string
+	fields    map[string]*query.Query
+	apiKey    string
+	timeout   time.Duration
+}
+
+// New creates a client for the custom provider name defined by def.
+// option.WithBaseURL replaces the URL template of def; the language is
+// ignored.
+func New(name string, def Definition, opts ...option.Option) (*Client, error) {
+	s := option.Apply(option.Settings{BaseURL: def.URL}, opts...)
+
+	if !strings.Contains(s.BaseURL, PlaceholderIP) {
+		return nil, fmt.Errorf("provider %q: url %q lacks the %s placeholder", name, s.BaseURL, PlaceholderIP)
+	}
+	u, err := url.Parse(strings.ReplaceAll(s.BaseURL, PlaceholderIP, "192.0.2.1"))
+	if err != nil {
+		return nil, fmt.Errorf("provider %q: %w", name, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("provider %q: url %q must be http or https", name, s.BaseURL)
+	}
+
+	if len(def.Fields) == 0 {
+		return nil, fmt.Errorf("provider %q: no fields mapped; map some of %s", name, strings.Join(Fields, ", "))
+	}
+	fields := make(map[string]*query.Query, len(def.Fields))
+	for field, expr := range def.Fields {
+		if !slices.Contains(Fields, field) {
+			return nil, fmt.Errorf("provider %q: unknown field %q; must be one of %s", name, field, strings.Join(Fields, ", "))
+		}
+		q, err := query.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: field %q: %w", name, field, err)
+		}
+		fields[field] = q
+	}
+
+	return &Client{
+		name:      name,
+		requester: provider.WithCompression(s.Requester),
+		url:       s.BaseURL,
+		headers:   def.Headers,
+		fields:    fields,
+		apiKey:    s.APIKey,
+		timeout:   s.Timeout,
+	}, nil
+}
+
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Describe reports the request Check would make for ip.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	key := provider.RedactKey(c.apiKey)
+	return provider.Description{
+		Name:    c.name,
+		URL:     c.requestURL(ip, key),
+		Timeout: c.timeout,
+		APIKey:  key,
+	}
+}
+
+// requestURL fills in the URL template for ip and key.
+func (c *Client) requestURL(ip model.IPAddress, key string) string {
+	return strings.NewReplacer(
+		PlaceholderIP, url.PathEscape(ip.String()),
+		PlaceholderKey, url.QueryEscape(key),
+	).Replace(c.url)
+}
+
+// Check looks up geolocation data for the given IP address.
+func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.requestURL(ip, c.apiKey), nil)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range c.headers {
+		req.Header.Set(name, strings.ReplaceAll(value, PlaceholderKey, c.apiKey))
+	}
+
+	resp, err := c.requester.Do(req)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("executing request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	provider.RecordQuota(ctx, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		return model.Geolocation{}, provider.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var body any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return model.Geolocation{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return c.toGeolocation(ip, body)
+}
+
+// toGeolocation maps a response onto a geolocation. It fails when none of
+// the mapped fields is present, as in error responses, so that they are not
+// taken for an empty location.
+func (c *Client) toGeolocation(ip model.IPAddress, body any) (model.Geolocation, error) {
+	geo := model.Geolocation{IP: ip}
+	found := false
+
+	for _, field := range Fields {
+		q, ok := c.fields[field]
+		if !ok {
+			continue
+		}
+		v, err := q.Search(body)
+		if err != nil {
+			return model.Geolocation{}, fmt.Errorf("response field %q: %w", q, err)
+		}
+		if v == nil {
+			continue
+		}
+
+		if err := set(&geo, field, v); err != nil {
+			return model.Geolocation{}, fmt.Errorf("response field %q: %w", q, err)
+		}
+		found = true
+	}
+
+	if !found {
+		return model.Geolocation{}, errors.New("response has none of the mapped fields")
+	}
+	return geo, nil
+}
+
+// set stores v, the JSON value extracted from the response, into field of
+// geo.
+func set(geo *model.Geolocation, field string, v any) error {
+	switch field {
+	case "latitude", "longitude", "reputation":
+		f, err := number(v)
+		if err != nil {
+			return err
+		}
+		switch field {
+		case "latitude":
+			geo.Latitude = &f
+		case "longitude":
+			geo.Longitude = &f
+		default:
+			geo.Reputation = &model.Reputation{Score: f}
+		}
+		return nil
+	}
+
+	s, err := text(v)
+	if err != nil {
+		return err
+	}
+	switch field {
+	case "country":
+		geo.Country = s
+	case "country_code":
+		geo.CountryCode = strings.ToUpper(s)
+	case "region":
+		geo.Region = s
+	case "city":
+		geo.City = s
+	case "isp":
+		geo.ISP = s
+	case "org":
+		geo.Org = s
+	case "asn":
+		geo.ASN = asn(s)
+	case "hostname":
+		geo.Hostname = s
+	}
+	return nil
+}
+
+// number converts a JSON number, or a string holding one.
+func number(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+}
+
+// text converts a JSON string, or a number as written.
+func text(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("%v is not a string", v)
+	}
+}
+
+// asn writes a bare AS number, such as 15169, as "AS15169", like the other
+// providers do.
+func asn(s string) string {
+	if _, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return "AS" + s
+	}
+	return s
+}
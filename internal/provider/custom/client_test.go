@@ -0,0 +1,232 @@
+package custom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+)
+
+var testFields = map[string]string{
+	"country":      "country_name",
+	"country_code": "cc",
+	"city":         "city",
+	"latitude":     "lat",
+	"longitude":    "lon",
+	"asn":          "as_number",
+}
+
+func newTestClient(t *testing.T, url string, def Definition, opts ...option.Option) *Client {
+	t.Helper()
+	if def.URL == "" {
+		def.URL = url + "/geo/{ip}?token={key}"
+	}
+	if def.Fields == nil {
+		def.Fields = testFields
+	}
+	c, err := New("in-house", def, append([]option.Option{option.WithRequester(http.DefaultClient)}, opts...)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestClient_Check_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/geo/8.8.8.8" || r.URL.Query().Get("token") != "secret" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want Bearer secret", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"country_name": "United States",
+			"cc": "us",
+			"city": "Mountain View",
+			"lat": "37.386",
+			"lon": -122.084,
+			"as_number": 15169,
+			"unmapped": {"ignored": true}
+		}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, Definition{Headers: map[string]string{"Authorization": "Bearer {key}"}},
+		option.WithAPIKey("secret"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.Country != "United States" || geo.CountryCode != "US" || geo.City != "Mountain View" {
+		t.Errorf("location = %q, %q, %q", geo.Country, geo.CountryCode, geo.City)
+	}
+	if lat, lon, ok := geo.Coordinates(); !ok || lat != 37.386 || lon != -122.084 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37.386, -122.084", lat, lon, ok)
+	}
+	if geo.ASN != "AS15169" {
+		t.Errorf("ASN = %q, want AS15169", geo.ASN)
+	}
+	if geo.Region != "" {
+		t.Errorf("Region = %q, want it empty as it is not mapped", geo.Region)
+	}
+	if client.Name() != "in-house" {
+		t.Errorf("Name() = %q, want in-house", client.Name())
+	}
+}
+
+func TestClient_Check_IPv6(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/geo/2001:db8::1" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"cc": "NL"}`))
+	}))
+	defer server.Close()
+
+	geo, err := newTestClient(t, server.URL, Definition{}).Check(context.Background(), model.MustParseAddr("2001:db8::1"))
+	if err != nil || geo.CountryCode != "NL" {
+		t.Errorf("Check() = %+v, %v", geo, err)
+	}
+}
+
+func TestClient_Check_Nested(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"data": {
+				"location": {"lat": 52.37, "lng": 4.89, "country": {"iso": "nl"}},
+				"network": {"asn": {"number": 1136, "name": "KPN"}}
+			},
+			"hosts": ["host-1.example", "host-2.example"],
+			"ip-range": {"owner": "KPN B.V."}
+		}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, Definition{Fields: map[string]string{
+		"country_code": "data.location.country.iso",
+		"latitude":     "data.location.lat",
+		"longitude":    "data.location.lng",
+		"isp":          "data.network.asn.name",
+		"asn":          "data.network.asn.number",
+		"hostname":     "hosts[0]",
+		"org":          `"ip-range".owner`,
+		"city":         "data.location.city",
+	}})
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.CountryCode != "NL" || geo.ISP != "KPN" || geo.ASN != "AS1136" || geo.Hostname != "host-1.example" || geo.Org != "KPN B.V." {
+		t.Errorf("Check() = %+v", geo)
+	}
+	if lat, lon, ok := geo.Coordinates(); !ok || lat != 52.37 || lon != 4.89 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 52.37, 4.89", lat, lon, ok)
+	}
+	if geo.City != "" {
+		t.Errorf("City = %q, want it empty as the path is missing", geo.City)
+	}
+}
+
+func TestClient_Check_Reputation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"abuseConfidenceScore": 87}}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, Definition{Fields: map[string]string{"reputation": "data.abuseConfidenceScore"}})
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if geo.Reputation == nil || geo.Reputation.Score != 87 {
+		t.Errorf("Reputation = %+v, want a score of 87", geo.Reputation)
+	}
+}
+
+func TestClient_Check_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"status", http.StatusUnauthorized, `{}`, "unexpected status code: 401"},
+		{"no mapped field", http.StatusOK, `{"error": "quota exceeded"}`, "none of the mapped fields"},
+		{"bad coordinate", http.StatusOK, `{"cc": "US", "lat": "north"}`, `response field "lat": "north" is not a number`},
+		{"not json", http.StatusOK, `<html>`, "decoding response"},
+		{"object coordinate", http.StatusOK, `{"lat": {"value": 1}}`, `response field "lat": map[value:1] is not a number`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := newTestClient(t, server.URL, Definition{}).Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Check() error = %v, want %q", err, tt.want)
+			}
+			if tt.status == http.StatusUnauthorized {
+				var serr provider.StatusError
+				if !errors.As(err, &serr) || !serr.Unauthorized() {
+					t.Errorf("Check() error = %v, want an unauthorized StatusError", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		def  Definition
+		want string
+	}{
+		{"no placeholder", Definition{URL: "https://geo.example/lookup", Fields: testFields}, "lacks the {ip} placeholder"},
+		{"not http", Definition{URL: "ftp://geo.example/{ip}", Fields: testFields}, "must be http or https"},
+		{"no fields", Definition{URL: "https://geo.example/{ip}"}, "no fields mapped"},
+		{"unknown field", Definition{URL: "https://geo.example/{ip}", Fields: map[string]string{"zip": "postal"}}, `unknown field "zip"`},
+		{"invalid expression", Definition{URL: "https://geo.example/{ip}", Fields: map[string]string{"city": "data.["}}, `field "city": invalid query`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New("in-house", tt.def); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Describe(t *testing.T) {
+	client := newTestClient(t, "https://geo.example", Definition{}, option.WithAPIKey("0123456789abcdef"))
+
+	d := client.Describe(model.MustParseAddr("8.8.8.8"))
+	if d.Name != "in-house" || d.URL != "https://geo.example/geo/8.8.8.8?token=%2A%2A%2A%2Acdef" || d.APIKey != "****cdef" {
+		t.Errorf("Describe() = %+v", d)
+	}
+}
+
+func TestClient_BaseURLReplacesTemplate(t *testing.T) {
+	client := newTestClient(t, "https://geo.example", Definition{}, option.WithBaseURL("http://localhost:8080/{ip}"))
+
+	if d := client.Describe(model.MustParseAddr("8.8.8.8")); d.URL != "http://localhost:8080/8.8.8.8" {
+		t.Errorf("URL = %q, want the base URL filled in", d.URL)
+	}
+}
@@ -0,0 +1,21 @@
+package provider
+
+import "api-client/internal/model"
+
+// wrapped is embedded by the Provider decorators, such as WithRetry or
+// WithCache, that describe the Provider they wrap as is. Decorators
+// compose by nesting, the outermost one seeing each Check first:
+//
+//	p = WithLogging(WithRetry(WithTimeout(p, time.Second), 3, 100*time.Millisecond), logger)
+//
+// gives each attempt its own timeout and logs the outcome of all of them.
+type wrapped struct {
+	Provider
+}
+
+// Describe reports the Description of the wrapped Provider, so that
+// decorating a provider does not change how it is shown or whether it is
+// local.
+func (w wrapped) Describe(ip model.IPAddress) Description {
+	return Describe(w.Provider, ip)
+}
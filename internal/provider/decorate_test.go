@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// localProvider is a local Provider, as told by its Description.
+type localProvider struct {
+	Provider
+}
+
+func (localProvider) Describe(ip model.IPAddress) Description {
+	return Description{Name: "local", Local: true}
+}
+
+func TestDecorators_Describe(t *testing.T) {
+	p := localProvider{NewTestProvider("local", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{IP: ip}, nil
+	}))}
+
+	decorators := map[string]Provider{
+		"retry":     WithRetry(p, 3, time.Millisecond),
+		"ratelimit": WithRateLimit(p, 10, time.Second),
+		"quota":     WithQuota(p, 10, time.Second),
+		"cache":     WithCache(p, time.Minute, 10),
+		"logging":   WithLogging(p, slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}
+	for name, d := range decorators {
+		if _, unchanged := d.(localProvider); unchanged {
+			t.Errorf("%s: provider was not wrapped", name)
+		}
+		if d.Name() != "local" {
+			t.Errorf("%s: Name() = %q, want local", name, d.Name())
+		}
+		if !IsLocal(d) {
+			t.Errorf("%s: IsLocal() = false, want the description of the wrapped provider", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"net/url"
+	"slices"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// Description summarises how a provider would be queried for an IP,
+// without performing any request.
+type Description struct {
+	Name    string
+	URL     string
+	Timeout time.Duration
+	// APIKey is the redacted API key, or empty if none is configured.
+	APIKey string
+	// Local is set for providers answering without any request.
+	Local bool
+	// Authoritative is set for local providers whose answers settle an
+	// address on their own, such as the special-use classification of
+	// bogons, so that no other provider needs to be asked.
+	Authoritative bool
+	// Advisory is set for providers whose answers only break ties between
+	// those of the others and sanity-check them, such as an offline
+	// country table, rather than count as answers of their own.
+	Advisory bool
+}
+
+// Describer is implemented by providers that can describe the request
+// they would make for an IP.
+type Describer interface {
+	Describe(ip model.IPAddress) Description
+}
+
+// Describe returns the Description of p for ip. Providers that do not
+// implement Describer are described by their name alone.
+func Describe(p Provider, ip model.IPAddress) Description {
+	if d, ok := p.(Describer); ok {
+		return d.Describe(ip)
+	}
+	return Description{Name: p.Name()}
+}
+
+// probeAddr is the documentation address providers are described for when
+// the description does not depend on the address queried.
+var probeAddr = model.MustParseAddr("192.0.2.1")
+
+// IsLocal reports whether p answers without any request, as told by its
+// Description.
+func IsLocal(p Provider) bool {
+	return Describe(p, probeAddr).Local
+}
+
+// IsAuthoritative reports whether the answers of p settle an address on
+// their own, as told by its Description.
+func IsAuthoritative(p Provider) bool {
+	return Describe(p, probeAddr).Authoritative
+}
+
+// IsAdvisory reports whether the answers of p only advise those of the
+// other providers, as told by its Description.
+func IsAdvisory(p Provider) bool {
+	return Describe(p, probeAddr).Advisory
+}
+
+// Hosts returns the host names of the HTTP endpoints of providers, as far
+// as their Descriptions tell, each once and in order.
+func Hosts(providers []Provider) []string {
+	var hosts []string
+	for _, p := range providers {
+		d := Describe(p, probeAddr)
+		u, err := url.Parse(d.URL)
+		if d.Local || err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		if host := u.Hostname(); host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// RedactKey masks an API key so it can be displayed, keeping only the last
+// four characters of long keys.
+func RedactKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) < 12 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestDescribe_Fallback(t *testing.T) {
+	p := NewTestProvider("plain", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, nil
+	}))
+
+	d := Describe(p, model.MustParseAddr("8.8.8.8"))
+	if d.Name != "plain" {
+		t.Errorf("Name = %q, want plain", d.Name)
+	}
+
+	if d.URL != "" {
+		t.Errorf("URL = %q, want empty", d.URL)
+	}
+}
+
+func TestDescribe_WithTimeout(t *testing.T) {
+	p := NewTestProvider("plain", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, nil
+	}))
+
+	d := Describe(WithTimeout(p, 3*time.Second), model.MustParseAddr("8.8.8.8"))
+	if d.Name != "plain" {
+		t.Errorf("Name = %q, want plain", d.Name)
+	}
+
+	if d.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v, want 3s", d.Timeout)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	plain := NewTestProvider("plain", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, nil
+	}))
+
+	providers := []Provider{
+		describedProvider{plain, Description{URL: "https://ipinfo.io/192.0.2.1/json"}},
+		describedProvider{plain, Description{URL: "http://ip-api.com/json/192.0.2.1"}},
+		describedProvider{plain, Description{URL: "https://ipinfo.io/192.0.2.1/privacy"}},
+		describedProvider{plain, Description{URL: "whois://whois.iana.org:43/192.0.2.1"}},
+		describedProvider{plain, Description{Local: true}},
+		plain,
+	}
+
+	if got, want := Hosts(providers), []string{"ipinfo.io", "ip-api.com"}; !slices.Equal(got, want) {
+		t.Errorf("Hosts() = %q, want %q", got, want)
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", ""},
+		{"short", "****"},
+		{"0123456789abcdef", "****cdef"},
+	}
+
+	for _, tt := range tests {
+		if got := RedactKey(tt.key); got != tt.want {
+			t.Errorf("RedactKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
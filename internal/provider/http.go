@@ -16,3 +16,11 @@ func (f HttpGetterFunc) Do(req *http.Request) (*http.Response, error) {
 }
 
 const DefaultRequestTimeout = 10 * time.Second
+
+// Defaults of the dialer provider connections are made with, those of
+// http.DefaultTransport.
+const (
+	DefaultDialTimeout   = 30 * time.Second
+	DefaultFallbackDelay = 300 * time.Millisecond
+	DefaultKeepAlive     = 30 * time.Second
+)
@@ -0,0 +1,206 @@
+// Package httpcache caches provider responses and revalidates them with
+// conditional requests, so that unchanged results cost neither bandwidth
+// nor quota.
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+// Entry is a cached response together with its cache validators.
+type Entry struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Expires      time.Time   `json:"expires"`
+}
+
+// Store holds cache entries by key.
+type Store interface {
+	Get(key string) (Entry, bool)
+	Set(key string, entry Entry) error
+}
+
+// Requester is a provider.HttpRequester that serves fresh responses from a
+// Store and revalidates stale ones with If-None-Match and
+// If-Modified-Since, reusing the stored body on 304 Not Modified. The
+// rate-limit headers of provider.QuotaHeaders are never replayed: a fresh
+// response has none, and a revalidated one those of the 304, so that
+// quotas are only reported as providers last told them.
+type Requester struct {
+	next  provider.HttpRequester
+	store Store
+	now   func() time.Time
+	hits  atomic.Int64
+}
+
+// Option configures a Requester.
+type Option func(*Requester)
+
+// WithClock sets the function used to read the current time.
+func WithClock(now func() time.Time) Option {
+	return func(r *Requester) {
+		r.now = now
+	}
+}
+
+// New wraps next with a cache backed by store.
+func New(next provider.HttpRequester, store Store, opts ...Option) *Requester {
+	r := &Requester{next: next, store: store, now: time.Now}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+var _ provider.HttpRequester = &Requester{}
+
+// Hits returns the number of responses served from the cache, either fresh
+// or after revalidation.
+func (r *Requester) Hits() int {
+	return int(r.hits.Load())
+}
+
+// Do implements provider.HttpRequester. Only GET requests are cached.
+func (r *Requester) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return r.next.Do(req)
+	}
+
+	key := cacheKey(req)
+	entry, cached := r.store.Get(key)
+	if cached && r.now().Before(entry.Expires) {
+		r.hits.Add(1)
+		return entry.response(req), nil
+	}
+
+	if cached {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := r.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		entry.Expires = r.expires(resp.Header)
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			entry.ETag = etag
+		}
+		_ = r.store.Set(key, entry)
+		r.hits.Add(1)
+		revalidated := entry.response(req)
+		for _, name := range provider.QuotaHeaders {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				revalidated.Header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		return revalidated, nil
+	}
+
+	if resp.StatusCode != http.StatusOK || noStore(resp.Header) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry = Entry{
+		Status:       resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Expires:      r.expires(resp.Header),
+	}
+
+	// Without validators or a lifetime the entry could never be reused
+	if entry.ETag != "" || entry.LastModified != "" || entry.Expires.After(r.now()) {
+		_ = r.store.Set(key, entry)
+	}
+
+	return resp, nil
+}
+
+// expires returns when a response with the given headers becomes stale,
+// from its Cache-Control max-age. Responses without one are revalidated
+// on every use.
+func (r *Requester) expires(h http.Header) time.Time {
+	now := r.now()
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "no-cache") {
+			return now
+		}
+		if strings.EqualFold(name, "max-age") {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	return now
+}
+
+func noStore(h http.Header) bool {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return true
+		}
+	}
+	return false
+}
+
+// response rebuilds an HTTP response from the entry, without the
+// rate-limit headers it was stored with.
+func (e Entry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	for _, name := range provider.QuotaHeaders {
+		header.Del(name)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(e.Status) + " " + http.StatusText(e.Status),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheKey identifies a request by its URL and credentials, since results
+// may depend on the plan of the API key used.
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		key += " " + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
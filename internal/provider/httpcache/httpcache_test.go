@@ -0,0 +1,306 @@
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, r *Requester, url string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	resp, err := r.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestRequester_RevalidatesWithETag(t *testing.T) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"country":"US"}`))
+	}))
+	defer server.Close()
+
+	r := New(http.DefaultClient, NewMemoryStore())
+
+	for i := 0; i < 2; i++ {
+		status, body := get(t, r, server.URL+"/8.8.8.8")
+		if status != http.StatusOK || body != `{"country":"US"}` {
+			t.Errorf("request %d = %d %q, want the cached body with 200", i, status, body)
+		}
+	}
+
+	if requests != 2 || notModified != 1 {
+		t.Errorf("requests = %d, not modified = %d, want a conditional second request", requests, notModified)
+	}
+
+	if r.Hits() != 1 {
+		t.Errorf("Hits() = %d, want 1", r.Hits())
+	}
+}
+
+func TestRequester_RevalidatesWithLastModified(t *testing.T) {
+	const modified = "Mon, 15 Jan 2024 10:00:00 GMT"
+
+	var conditional int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == modified {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", modified)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	r := New(http.DefaultClient, NewMemoryStore())
+	get(t, r, server.URL)
+	if _, body := get(t, r, server.URL); body != "ok" {
+		t.Errorf("body = %q, want ok", body)
+	}
+
+	if conditional != 1 {
+		t.Errorf("conditional requests = %d, want 1", conditional)
+	}
+}
+
+func TestRequester_ServesFreshEntries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	r := New(http.DefaultClient, NewMemoryStore(), WithClock(func() time.Time { return now }))
+
+	get(t, r, server.URL)
+	get(t, r, server.URL)
+	if requests != 1 {
+		t.Errorf("requests = %d, want the fresh entry served without a request", requests)
+	}
+
+	now = now.Add(2 * time.Minute)
+	get(t, r, server.URL)
+	if requests != 2 {
+		t.Errorf("requests = %d, want a new request once the entry is stale", requests)
+	}
+}
+
+func TestRequester_DoesNotReplayQuota(t *testing.T) {
+	var remaining int32 = 45
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Rl", strconv.Itoa(int(atomic.AddInt32(&remaining, -1))))
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	r := New(http.DefaultClient, NewMemoryStore(), WithClock(func() time.Time { return now }))
+	quota := func() string {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := r.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.Header.Get("X-Rl")
+	}
+
+	if got := quota(); got != "44" {
+		t.Errorf("X-Rl of the first response = %q, want 44", got)
+	}
+	if got := quota(); got != "" {
+		t.Errorf("X-Rl of a fresh cached response = %q, want none", got)
+	}
+	now = now.Add(2 * time.Minute)
+	if got := quota(); got != "43" {
+		t.Errorf("X-Rl of a revalidated response = %q, want 43 from the 304", got)
+	}
+}
+
+func TestRequester_DoesNotCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+	}{
+		{"no validators", http.StatusOK, nil},
+		{"no-store", http.StatusOK, map[string]string{"ETag": `"v1"`, "Cache-Control": "no-store"}},
+		{"error status", http.StatusTooManyRequests, map[string]string{"ETag": `"v1"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("If-None-Match") != "" {
+					t.Error("unexpected conditional request")
+				}
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			store := NewMemoryStore()
+			r := New(http.DefaultClient, store)
+			get(t, r, server.URL)
+			get(t, r, server.URL)
+
+			if len(store.entries) != 0 {
+				t.Errorf("store has %d entries, want none", len(store.entries))
+			}
+		})
+	}
+}
+
+func TestRequester_KeysByCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	r := New(http.DefaultClient, NewMemoryStore())
+
+	for _, token := range []string{"Bearer a", "Bearer b"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Authorization", token)
+		resp, err := r.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+
+		if string(body) != token {
+			t.Errorf("body = %q, want the response for %q", body, token)
+		}
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	store, err := NewDirStore(t.TempDir() + "/cache")
+	if err != nil {
+		t.Fatalf("NewDirStore() error = %v", err)
+	}
+
+	if _, ok := store.Get("missing"); ok {
+		t.Error("Get() found a missing entry")
+	}
+
+	entry := Entry{Status: 200, Body: []byte("ok"), ETag: `"v1"`}
+	if err := store.Set("http://example.com/1.1.1.1", entry); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	got, ok := store.Get("http://example.com/1.1.1.1")
+	if !ok {
+		t.Fatal("Get() did not find the stored entry")
+	}
+	if string(got.Body) != "ok" || got.ETag != `"v1"` {
+		t.Errorf("Get() = %+v, want the stored entry", got)
+	}
+}
+
+func TestDirStore_Encrypted(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{1}, KeySize)
+
+	store, err := NewDirStore(dir, WithKey(key))
+	if err != nil {
+		t.Fatalf("NewDirStore() error = %v", err)
+	}
+	entry := Entry{Status: 200, Body: []byte(`{"query":"1.1.1.1"}`)}
+	if err := store.Set("http://example.com/1.1.1.1", entry); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || filepath.Ext(files[0]) != ".enc" {
+		t.Fatalf("files = %v, want one encrypted entry", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if bytes.Contains(data, []byte("1.1.1.1")) {
+		t.Error("the entry is stored in cleartext")
+	}
+
+	if got, ok := store.Get("http://example.com/1.1.1.1"); !ok || string(got.Body) != string(entry.Body) {
+		t.Errorf("Get() = %+v, %v, want the stored entry", got, ok)
+	}
+
+	// Neither another key nor no key reads the entry
+	other, _ := NewDirStore(dir, WithKey(bytes.Repeat([]byte{2}, KeySize)))
+	plain, _ := NewDirStore(dir)
+	for name, s := range map[string]*DirStore{"other key": other, "no key": plain} {
+		if _, ok := s.Get("http://example.com/1.1.1.1"); ok {
+			t.Errorf("Get() with %s found the entry", name)
+		}
+	}
+
+	// Nor does the entry of another key, renamed
+	if err := store.Set("http://example.com/8.8.8.8", entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(store.path("http://example.com/8.8.8.8"), files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("http://example.com/1.1.1.1"); ok {
+		t.Error("Get() accepted the entry of another key")
+	}
+
+	if _, err := NewDirStore(dir, WithKey([]byte("short"))); err == nil {
+		t.Error("NewDirStore() with a short key expected error")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey([]byte("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\n"))
+	if err != nil || !bytes.Equal(key, bytes.Repeat([]byte{1}, KeySize)) {
+		t.Errorf("ParseKey() = %x, %v", key, err)
+	}
+
+	for _, data := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := ParseKey([]byte(data)); err == nil {
+			t.Errorf("ParseKey(%q) expected error", data)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// KeySize is the size of the keys encrypting a DirStore.
+const KeySize = 32
+
+// MemoryStore keeps entries in memory for the lifetime of the process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(key string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(key string, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry
+	return nil
+}
+
+// DirStore keeps one JSON file per entry in a directory, so that cached
+// responses survive between runs.
+//
+// Encrypted with WithKey, entries are sealed with AES-256-GCM and named
+// after an HMAC of their key rather than its hash, which anyone could
+// recompute for every address to learn which ones were looked up.
+type DirStore struct {
+	dir string
+	key []byte
+
+	aead    cipher.AEAD
+	nameKey []byte
+}
+
+// DirOption configures a DirStore.
+type DirOption func(*DirStore)
+
+// WithKey encrypts the entries with key, KeySize random bytes. Entries
+// written without it, or with another key, are treated as missing.
+func WithKey(key []byte) DirOption {
+	return func(s *DirStore) {
+		s.key = key
+	}
+}
+
+// NewDirStore creates a store in dir, creating the directory if needed.
+func NewDirStore(dir string, opts ...DirOption) (*DirStore, error) {
+	s := &DirStore{dir: dir}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.key != nil {
+		if len(s.key) != KeySize {
+			return nil, fmt.Errorf("cache key must be %d bytes, got %d", KeySize, len(s.key))
+		}
+		var err error
+		if s.aead, err = NewAEAD(s.key, "ipintel cache encryption"); err != nil {
+			return nil, err
+		}
+		s.nameKey = deriveKey(s.key, "ipintel cache names")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return s, nil
+}
+
+// NewAEAD returns the AES-256-GCM cipher of key for purpose, so that other
+// files can be encrypted with the cache key without reusing the key of the
+// cache entries themselves.
+func NewAEAD(key []byte, purpose string) (cipher.AEAD, error) {
+	// The key is not used directly, but to derive one key per purpose
+	block, err := aes.NewCipher(deriveKey(key, purpose))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// ParseKey decodes a key for WithKey written in base64, as generated by
+// "openssl rand -base64 32".
+func ParseKey(data []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, errors.New("cache key is not valid base64")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("cache key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func (s *DirStore) path(key string) string {
+	if s.aead != nil {
+		mac := hmac.New(sha256.New, s.nameKey)
+		mac.Write([]byte(key))
+		return filepath.Join(s.dir, hex.EncodeToString(mac.Sum(nil))+".enc")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get implements Store. Unreadable entries are treated as missing.
+func (s *DirStore) Get(key string) (Entry, bool) {
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	if s.aead != nil {
+		n := s.aead.NonceSize()
+		if len(data) < n {
+			return Entry{}, false
+		}
+		// The file name is authenticated so that entries cannot be swapped
+		data, err = s.aead.Open(nil, data[:n], data[n:], []byte(filepath.Base(path)))
+		if err != nil {
+			return Entry{}, false
+		}
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Set implements Store. The entry is written to a temporary file first so
+// that concurrent readers never see a partial entry.
+func (s *DirStore) Set(key string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(key)
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(data)+s.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		data = s.aead.Seal(nonce, nonce, data, []byte(filepath.Base(path)))
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrPlainHTTP is returned by requesters built with WithHTTPSOnly for
+// requests that would be sent in cleartext.
+var ErrPlainHTTP = errors.New("refusing plain HTTP request")
+
+// httpsOnlyRequester refuses requests that are not sent over HTTPS.
+type httpsOnlyRequester struct {
+	next HttpRequester
+}
+
+// WithHTTPSOnly wraps r so that only HTTPS requests are executed; any other
+// request fails with ErrPlainHTTP before anything is sent, so the queried
+// address never leaves the machine in cleartext.
+func WithHTTPSOnly(r HttpRequester) HttpRequester {
+	return httpsOnlyRequester{next: r}
+}
+
+func (h httpsOnlyRequester) Do(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%w to %s://%s", ErrPlainHTTP, req.URL.Scheme, req.URL.Host)
+	}
+	return h.next.Do(req)
+}
+
+// UsesPlainHTTP reports whether p queries an endpoint in cleartext, over
+// plain HTTP or whois, as far as its Description tells. Local providers
+// and providers that do not describe their requests are assumed not to.
+func UsesPlainHTTP(p Provider) bool {
+	d := Describe(p, probeAddr)
+	if d.Local || d.URL == "" {
+		return false
+	}
+	u, err := url.Parse(d.URL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "whois")
+}
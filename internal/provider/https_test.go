@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestWithHTTPSOnly(t *testing.T) {
+	var calls int
+	next := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})
+	r := WithHTTPSOnly(next)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://ip-api.com/json/8.8.8.8", nil)
+	if _, err := r.Do(req); !errors.Is(err, ErrPlainHTTP) {
+		t.Errorf("Do(http) error = %v, want ErrPlainHTTP", err)
+	}
+	if calls != 0 {
+		t.Fatal("plain HTTP request was sent")
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://ipinfo.io/8.8.8.8/json", nil)
+	resp, err := r.Do(req)
+	if err != nil {
+		t.Fatalf("Do(https) error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+type describedProvider struct {
+	Provider
+	d Description
+}
+
+func (p describedProvider) Describe(model.IPAddress) Description {
+	return p.d
+}
+
+func TestUsesPlainHTTP(t *testing.T) {
+	plain := NewTestProvider("plain", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, nil
+	}))
+
+	tests := []struct {
+		name string
+		p    Provider
+		want bool
+	}{
+		{"http", describedProvider{plain, Description{URL: "http://ip-api.com/json/192.0.2.1"}}, true},
+		{"https", describedProvider{plain, Description{URL: "https://ipinfo.io/192.0.2.1/json"}}, false},
+		{"whois", describedProvider{plain, Description{URL: "whois://whois.iana.org:43/192.0.2.1"}}, true},
+		{"local", describedProvider{plain, Description{Local: true}}, false},
+		{"undescribed", plain, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UsesPlainHTTP(tt.p); got != tt.want {
+				t.Errorf("UsesPlainHTTP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
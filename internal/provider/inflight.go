@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// inflightRequester limits the number of requests the wrapped HttpRequester
+// executes at the same time.
+type inflightRequester struct {
+	next  HttpRequester
+	slots chan struct{}
+}
+
+// WithMaxInflight wraps r so that at most n requests are outstanding at any
+// time, however many goroutines share it. A request holds its slot until
+// its response body is closed, since the connection stays busy until then.
+// Requests waiting for a slot give up when their context is done. A
+// non-positive n returns r unchanged.
+func WithMaxInflight(r HttpRequester, n int) HttpRequester {
+	if n <= 0 {
+		return r
+	}
+	return inflightRequester{next: r, slots: make(chan struct{}, n)}
+}
+
+func (l inflightRequester) Do(req *http.Request) (*http.Response, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	resp, err := l.next.Do(req)
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-l.slots }}
+	return resp, nil
+}
+
+// releasingBody frees a slot the first time the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxInflight(t *testing.T) {
+	var current, peak int32
+	next := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		c := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if c <= p || atomic.CompareAndSwapInt32(&peak, p, c) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})
+
+	r := WithMaxInflight(next, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			resp, err := r.Do(req)
+			if err != nil {
+				t.Errorf("Do() error = %v", err)
+				return
+			}
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&peak); got > 2 {
+		t.Errorf("peak in-flight requests = %d, want at most 2", got)
+	}
+}
+
+func TestWithMaxInflight_HoldsSlotUntilBodyClosed(t *testing.T) {
+	next := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})
+	r := WithMaxInflight(next, 1)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	resp, err := r.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.Do(req.WithContext(ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Do() with open body error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Closing twice must release the slot only once
+	_ = resp.Body.Close()
+	_ = resp.Body.Close()
+
+	resp, err = r.Do(req)
+	if err != nil {
+		t.Fatalf("Do() after close error = %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestWithMaxInflight_ReleasesOnError(t *testing.T) {
+	next := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	r := WithMaxInflight(next, 1)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Do(req); err == nil || err.Error() != "connection refused" {
+			t.Fatalf("Do() error = %v, want connection refused", err)
+		}
+	}
+}
+
+func TestWithMaxInflight_Unlimited(t *testing.T) {
+	next := HttpGetterFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, nil
+	})
+
+	if _, ok := WithMaxInflight(next, 0).(HttpGetterFunc); !ok {
+		t.Error("WithMaxInflight(r, 0) should return r unchanged")
+	}
+}
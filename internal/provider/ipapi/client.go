@@ -6,78 +6,123 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/text/language"
 
 	"api-client/internal/model"
 	"api-client/internal/provider"
+	"api-client/internal/provider/option"
 )
 
 const (
 	// ProviderName identifies this provider in reports.
 	ProviderName = "ip-api"
 
-	// BaseURL is the API endpoint. HTTP is used for the free tier.
+	// BaseURL is the API endpoint. The free tier is only served over HTTP.
 	BaseURL = "http://ip-api.com/json/"
+
+	// ProBaseURL is the HTTPS endpoint of the paid pro plan, used instead
+	// of BaseURL when an API key is configured.
+	ProBaseURL = "https://pro.ip-api.com/json/"
+
+	// fields selects the response fields; the reverse DNS name is only
+	// returned when requested explicitly.
+	fields = "status,message,country,countryCode,region,regionName,city,lat,lon,isp,org,as,reverse,query"
 )
 
+// languages are the values of the lang parameter ip-api.com accepts, in
+// order of preference; English is the default.
+var languages = []string{"en", "de", "es", "pt-BR", "fr", "ja", "zh-CN", "ru"}
+
+var languageMatcher = language.NewMatcher(func() []language.Tag {
+	tags := make([]language.Tag, len(languages))
+	for i, lang := range languages {
+		tags[i] = language.MustParse(lang)
+	}
+	return tags
+}())
+
+// matchLanguage returns the supported language closest to lang, or "" when
+// none is close enough and the default English names must do.
+func matchLanguage(lang string) string {
+	if lang == "" {
+		return ""
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ""
+	}
+	_, idx, confidence := languageMatcher.Match(tag)
+	if confidence == language.No || languages[idx] == "en" {
+		return ""
+	}
+	return languages[idx]
+}
+
+var _ provider.Provider = &Client{}
+
 // response represents the JSON structure returned by ip-api.com.
 type response struct {
-	Status      string  `json:"status"`
-	Message     string  `json:"message,omitempty"`
-	Country     string  `json:"country"`
-	CountryCode string  `json:"countryCode"`
-	Region      string  `json:"region"`
-	RegionName  string  `json:"regionName"`
-	City        string  `json:"city"`
-	Lat         float64 `json:"lat"`
-	Lon         float64 `json:"lon"`
-	ISP         string  `json:"isp"`
-	Org         string  `json:"org"`
-	AS          string  `json:"as"`
-	Query       string  `json:"query"`
+	Status      string   `json:"status"`
+	Message     string   `json:"message,omitempty"`
+	Country     string   `json:"country"`
+	CountryCode string   `json:"countryCode"`
+	Region      string   `json:"region"`
+	RegionName  string   `json:"regionName"`
+	City        string   `json:"city"`
+	Lat         *float64 `json:"lat"`
+	Lon         *float64 `json:"lon"`
+	ISP         string   `json:"isp"`
+	Org         string   `json:"org"`
+	AS          string   `json:"as"`
+	Reverse     string   `json:"reverse"`
+	Query       string   `json:"query"`
 }
 
-func (r response) toGeoLocation(ip model.IPAddress) model.Geolocation {
+func (r response) toGeoLocation(ip model.IPAddress, lang string) model.Geolocation {
 	return model.Geolocation{
 		IP:          ip,
 		Country:     r.Country,
 		CountryCode: r.CountryCode,
 		Region:      r.RegionName,
 		City:        r.City,
+		Language:    lang,
 		Latitude:    r.Lat,
 		Longitude:   r.Lon,
 		ISP:         r.ISP,
 		Org:         r.Org,
 		ASN:         r.AS,
+		Hostname:    r.Reverse,
 	}
 }
 
 type Client struct {
 	requester provider.HttpRequester
 	baseURL   string
+	apiKey    string
+	timeout   time.Duration
+	lang      string
 }
 
-// Option configures a Client.
-type Option func(*Client)
-
-// WithBaseURL sets a custom base URL (useful for testing).
-func WithBaseURL(url string) Option {
-	return func(client *Client) {
-		client.baseURL = url
-	}
-}
-
-// New creates a new ip-api.com client.
-func New(requester provider.HttpRequester, opts ...Option) *Client {
-	c := &Client{
-		requester: requester,
-		baseURL:   BaseURL,
+// New creates a new ip-api.com client. With option.WithAPIKey, the pro plan
+// is queried over HTTPS at ProBaseURL unless another base URL is set;
+// without a key, the free tier is queried over HTTP. Place names are localized into
+// the supported language closest to option.WithLanguage, if any.
+func New(opts ...option.Option) *Client {
+	s := option.Apply(option.Settings{BaseURL: BaseURL}, opts...)
+	if s.APIKey != "" && s.BaseURL == BaseURL {
+		s.BaseURL = ProBaseURL
 	}
 
-	for _, opt := range opts {
-		opt(c)
+	return &Client{
+		requester: provider.WithCompression(s.Requester),
+		baseURL:   s.BaseURL,
+		apiKey:    s.APIKey,
+		timeout:   s.Timeout,
+		lang:      matchLanguage(s.Language),
 	}
-
-	return c
 }
 
 // Name returns the provider name.
@@ -85,11 +130,38 @@ func (c *Client) Name() string {
 	return ProviderName
 }
 
+// Describe reports the request Check would make for ip.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	key := provider.RedactKey(c.apiKey)
+	return provider.Description{
+		Name:    ProviderName,
+		URL:     c.url(ip, key),
+		Timeout: c.timeout,
+		APIKey:  key,
+	}
+}
+
+// url builds the request URL, passing key as a query parameter when set.
+func (c *Client) url(ip model.IPAddress, key string) string {
+	u := c.baseURL + ip.String() + "?fields=" + fields
+	if c.lang != "" {
+		u += "&lang=" + c.lang
+	}
+	if key != "" {
+		u += "&key=" + url.QueryEscape(key)
+	}
+	return u
+}
+
 // Check looks up geolocation data for the given IP address.
 func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
-	url := c.baseURL + ip.String()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ip, c.apiKey), nil)
 	if err != nil {
 		return model.Geolocation{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -100,8 +172,10 @@ func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocati
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	provider.RecordQuota(ctx, resp.Header)
+
 	if resp.StatusCode != http.StatusOK {
-		return model.Geolocation{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return model.Geolocation{}, provider.StatusError{StatusCode: resp.StatusCode}
 	}
 
 	var apiResp response
@@ -117,5 +191,5 @@ func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocati
 		return model.Geolocation{}, fmt.Errorf("API error: %s", msg)
 	}
 
-	return apiResp.toGeoLocation(ip), nil
+	return apiResp.toGeoLocation(ip, c.lang), nil
 }
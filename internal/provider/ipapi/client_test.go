@@ -4,10 +4,13 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
 )
 
 func TestClient_Check_Success(t *testing.T) {
@@ -16,6 +19,10 @@ func TestClient_Check_Success(t *testing.T) {
 			t.Errorf("unexpected path: %s", r.URL.Path)
 		}
 
+		if !strings.Contains(r.URL.Query().Get("fields"), "reverse") {
+			t.Errorf("fields = %q, should request the reverse DNS name", r.URL.Query().Get("fields"))
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte(`{
@@ -30,12 +37,13 @@ func TestClient_Check_Success(t *testing.T) {
 			"isp": "Google LLC",
 			"org": "Google Public DNS",
 			"as": "AS15169 Google LLC",
+			"reverse": "dns.google",
 			"query": "8.8.8.8"
 		}`))
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	geo, err := client.Check(context.Background(), ip)
@@ -55,11 +63,8 @@ func TestClient_Check_Success(t *testing.T) {
 	if geo.City != "Mountain View" {
 		t.Errorf("City = %v, want Mountain View", geo.City)
 	}
-	if geo.Latitude != 37.386 {
-		t.Errorf("Latitude = %v, want 37.386", geo.Latitude)
-	}
-	if geo.Longitude != -122.084 {
-		t.Errorf("Longitude = %v, want -122.084", geo.Longitude)
+	if lat, lon, ok := geo.Coordinates(); !ok || lat != 37.386 || lon != -122.084 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37.386, -122.084", lat, lon, ok)
 	}
 	if geo.ISP != "Google LLC" {
 		t.Errorf("ISP = %v, want Google LLC", geo.ISP)
@@ -70,6 +75,9 @@ func TestClient_Check_Success(t *testing.T) {
 	if geo.ASN != "AS15169 Google LLC" {
 		t.Errorf("ASN = %v, want AS15169 Google LLC", geo.ASN)
 	}
+	if geo.Hostname != "dns.google" {
+		t.Errorf("Hostname = %v, want dns.google", geo.Hostname)
+	}
 }
 
 func TestClient_Check_IPv6(t *testing.T) {
@@ -97,7 +105,7 @@ func TestClient_Check_IPv6(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("2001:4860:4860::8888")
 
 	geo, err := client.Check(context.Background(), ip)
@@ -122,7 +130,7 @@ func TestClient_Check_APIError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("127.0.0.1")
 
 	_, err := client.Check(context.Background(), ip)
@@ -141,7 +149,7 @@ func TestClient_Check_HTTPError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -158,7 +166,7 @@ func TestClient_Check_InvalidJSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -174,7 +182,7 @@ func TestClient_Check_ContextCancellation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
@@ -188,7 +196,7 @@ func TestClient_Check_ContextCancellation(t *testing.T) {
 
 func TestClient_Check_ConnectionError(t *testing.T) {
 	// Use an invalid URL to simulate connection error
-	client := New(http.DefaultClient, WithBaseURL("http://localhost:1/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL("http://localhost:1/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -196,3 +204,130 @@ func TestClient_Check_ConnectionError(t *testing.T) {
 		t.Fatal("Check() expected error for connection failure")
 	}
 }
+
+func TestClient_Check_RecordsQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Rl", "0")
+		w.Header().Set("X-Ttl", "42")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
+
+	var quota *model.Quota
+	ctx := provider.WithQuotaRecorder(context.Background(), &quota)
+	if _, err := client.Check(ctx, model.MustParseAddr("8.8.8.8")); err == nil {
+		t.Fatal("Check() expected error for HTTP 429")
+	}
+
+	if quota == nil || quota.Remaining != 0 || quota.ResetIn != 42*time.Second {
+		t.Errorf("quota = %v, want 0 remaining resetting in 42s", quota)
+	}
+}
+
+func TestClient_Check_Language(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("lang"); got != "de" {
+			t.Errorf("lang = %q, want de", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "country": "Vereinigte Staaten", "countryCode": "US", "city": "Mountain View"}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"), option.WithLanguage("de-AT"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.Country != "Vereinigte Staaten" || geo.Language != "de" {
+		t.Errorf("Country, Language = %q, %q; want Vereinigte Staaten, de", geo.Country, geo.Language)
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"", ""},
+		{"en", ""},
+		{"en-GB", ""},
+		{"de", "de"},
+		{"pt", "pt-BR"},
+		{"zh", "zh-CN"},
+		{"nl", ""},
+		{"not a tag", ""},
+	}
+
+	for _, tt := range tests {
+		if got := matchLanguage(tt.lang); got != tt.want {
+			t.Errorf("matchLanguage(%q) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestClient_Check_APIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("key"); got != "secret-key" {
+			t.Errorf("key = %q, want secret-key", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "success", "country": "United States", "countryCode": "US"}`))
+	}))
+	defer server.Close()
+
+	client := New(
+		option.WithRequester(http.DefaultClient),
+		option.WithBaseURL(server.URL+"/"),
+		option.WithAPIKey("secret-key"),
+	)
+
+	if _, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+}
+
+func TestClient_Describe(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []option.Option
+		wantURL string
+		wantKey string
+	}{
+		{
+			name:    "free",
+			wantURL: "http://ip-api.com/json/8.8.8.8?fields=" + fields,
+		},
+		{
+			name:    "pro",
+			opts:    []option.Option{option.WithAPIKey("0123456789abcdef")},
+			wantURL: "https://pro.ip-api.com/json/8.8.8.8?fields=" + fields + "&key=%2A%2A%2A%2Acdef",
+			wantKey: "****cdef",
+		},
+		{
+			name:    "pro with base URL",
+			opts:    []option.Option{option.WithAPIKey("0123456789abcdef"), option.WithBaseURL("https://proxy.example/json/")},
+			wantURL: "https://proxy.example/json/8.8.8.8?fields=" + fields + "&key=%2A%2A%2A%2Acdef",
+			wantKey: "****cdef",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(tt.opts...).Describe(model.MustParseAddr("8.8.8.8"))
+
+			if d.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", d.URL, tt.wantURL)
+			}
+			if d.APIKey != tt.wantKey {
+				t.Errorf("APIKey = %q, want %q", d.APIKey, tt.wantKey)
+			}
+		})
+	}
+}
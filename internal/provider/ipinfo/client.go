@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"api-client/internal/model"
 	"api-client/internal/provider"
+	"api-client/internal/provider/option"
 )
 
 const (
@@ -26,12 +28,17 @@ var _ provider.Provider = &Client{}
 // response represents the JSON structure returned by ipinfo.io.
 type response struct {
 	IP       string `json:"ip"`
+	Hostname string `json:"hostname"`
 	City     string `json:"city"`
 	Region   string `json:"region"`
 	Country  string `json:"country"` // Two-letter country code
 	Loc      string `json:"loc"`     // "latitude,longitude"
 	Org      string `json:"org"`     // "AS12345 Organization Name"
 	Timezone string `json:"timezone"`
+	// Paid plan fields
+	Privacy *privacyResponse `json:"privacy,omitempty"`
+	Abuse   *abuseResponse   `json:"abuse,omitempty"`
+	Company *companyResponse `json:"company,omitempty"`
 	// Error response fields
 	Error *errorResponse `json:"error,omitempty"`
 }
@@ -42,14 +49,15 @@ func (r response) toGeoLocation(ip model.IPAddress) model.Geolocation {
 		CountryCode: r.Country,
 		Region:      r.Region,
 		City:        r.City,
+		Hostname:    r.Hostname,
 	}
 
 	// Parse location "lat,lon"
 	if r.Loc != "" {
 		lat, lon, err := parseLocation(r.Loc)
 		if err == nil {
-			geo.Latitude = lat
-			geo.Longitude = lon
+			geo.Latitude = model.Float64(lat)
+			geo.Longitude = model.Float64(lon)
 		}
 	}
 
@@ -61,9 +69,64 @@ func (r response) toGeoLocation(ip model.IPAddress) model.Geolocation {
 		geo.ISP = org
 	}
 
+	if r.Privacy != nil {
+		geo.Security = &model.Security{
+			VPN:     r.Privacy.VPN,
+			Proxy:   r.Privacy.Proxy,
+			Tor:     r.Privacy.Tor,
+			Relay:   r.Privacy.Relay,
+			Hosting: r.Privacy.Hosting,
+			Service: r.Privacy.Service,
+		}
+	}
+
+	var reg model.Registration
+	if r.Company != nil {
+		reg.Company = r.Company.Name
+		reg.CompanyDomain = r.Company.Domain
+		reg.CompanyType = r.Company.Type
+	}
+	if r.Abuse != nil {
+		reg.Network = r.Abuse.Network
+		reg.AbuseName = r.Abuse.Name
+		reg.AbuseEmail = r.Abuse.Email
+		reg.AbusePhone = r.Abuse.Phone
+		reg.AbuseAddress = r.Abuse.Address
+	}
+	if !reg.IsEmpty() {
+		geo.Registration = &reg
+	}
+
 	return geo
 }
 
+// privacyResponse is the privacy detection object of paid plans.
+type privacyResponse struct {
+	VPN     bool   `json:"vpn"`
+	Proxy   bool   `json:"proxy"`
+	Tor     bool   `json:"tor"`
+	Relay   bool   `json:"relay"`
+	Hosting bool   `json:"hosting"`
+	Service string `json:"service"`
+}
+
+// abuseResponse is the abuse contact object of paid plans.
+type abuseResponse struct {
+	Address string `json:"address"`
+	Country string `json:"country"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Phone   string `json:"phone"`
+}
+
+// companyResponse is the company object of paid plans.
+type companyResponse struct {
+	Name   string `json:"name"`
+	Domain string `json:"domain"`
+	Type   string `json:"type"`
+}
+
 type errorResponse struct {
 	Title   string `json:"title"`
 	Message string `json:"message"`
@@ -72,48 +135,59 @@ type errorResponse struct {
 type Client struct {
 	requester provider.HttpRequester
 	baseURL   string
+	apiKey    string
+	timeout   time.Duration
 }
 
-// Option configures a Client.
-type Option func(*Client)
+// New creates a new ipinfo.io client.
+func New(opts ...option.Option) *Client {
+	s := option.Apply(option.Settings{BaseURL: BaseURL}, opts...)
 
-// WithBaseURL sets a custom base URL (useful for testing).
-func WithBaseURL(url string) Option {
-	return func(client *Client) {
-		client.baseURL = url
+	return &Client{
+		requester: provider.WithCompression(s.Requester),
+		baseURL:   s.BaseURL,
+		apiKey:    s.APIKey,
+		timeout:   s.Timeout,
 	}
 }
 
-// New creates a new ip-api.com client.
-func New(requester provider.HttpRequester, opts ...Option) *Client {
-	c := &Client{
-		requester: requester,
-		baseURL:   BaseURL,
-	}
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return ProviderName
+}
 
-	for _, opt := range opts {
-		opt(c)
+// Describe reports the request Check would make for ip.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	return provider.Description{
+		Name:    ProviderName,
+		URL:     c.url(ip),
+		Timeout: c.timeout,
+		APIKey:  provider.RedactKey(c.apiKey),
 	}
-
-	return c
 }
 
-// Name returns the provider name.
-func (c *Client) Name() string {
-	return ProviderName
+func (c *Client) url(ip model.IPAddress) string {
+	return c.baseURL + ip.String() + "/json"
 }
 
 // Check looks up geolocation data for the given IP address.
 func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
-	url := c.baseURL + ip.String() + "/json"
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ip), nil)
 	if err != nil {
 		return model.Geolocation{}, fmt.Errorf("creating request: %w", err)
 	}
 
 	// ipinfo.io recommends setting Accept header
 	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 
 	resp, err := c.requester.Do(req)
 	if err != nil {
@@ -121,8 +195,10 @@ func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocati
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	provider.RecordQuota(ctx, resp.Header)
+
 	if resp.StatusCode != http.StatusOK {
-		return model.Geolocation{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return model.Geolocation{}, provider.StatusError{StatusCode: resp.StatusCode}
 	}
 
 	var apiResp response
@@ -4,10 +4,12 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"api-client/internal/model"
+	"api-client/internal/provider/option"
 )
 
 func TestClient_Check_Success(t *testing.T) {
@@ -24,6 +26,7 @@ func TestClient_Check_Success(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte(`{
 			"ip": "8.8.8.8",
+			"hostname": "dns.google",
 			"city": "Mountain View",
 			"region": "California",
 			"country": "US",
@@ -34,7 +37,7 @@ func TestClient_Check_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	geo, err := client.Check(context.Background(), ip)
@@ -51,11 +54,8 @@ func TestClient_Check_Success(t *testing.T) {
 	if geo.City != "Mountain View" {
 		t.Errorf("City = %v, want Mountain View", geo.City)
 	}
-	if geo.Latitude != 37.386 {
-		t.Errorf("Latitude = %v, want 37.386", geo.Latitude)
-	}
-	if geo.Longitude != -122.084 {
-		t.Errorf("Longitude = %v, want -122.084", geo.Longitude)
+	if lat, lon, ok := geo.Coordinates(); !ok || lat != 37.386 || lon != -122.084 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37.386, -122.084", lat, lon, ok)
 	}
 	if geo.ASN != "AS15169" {
 		t.Errorf("ASN = %v, want AS15169", geo.ASN)
@@ -63,6 +63,9 @@ func TestClient_Check_Success(t *testing.T) {
 	if geo.Org != "Google LLC" {
 		t.Errorf("Org = %v, want Google LLC", geo.Org)
 	}
+	if geo.Hostname != "dns.google" {
+		t.Errorf("Hostname = %v, want dns.google", geo.Hostname)
+	}
 }
 
 func TestClient_Check_IPv6(t *testing.T) {
@@ -84,7 +87,7 @@ func TestClient_Check_IPv6(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("2001:4860:4860::8888")
 
 	geo, err := client.Check(context.Background(), ip)
@@ -110,7 +113,7 @@ func TestClient_Check_APIError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("127.0.0.1")
 
 	_, err := client.Check(context.Background(), ip)
@@ -130,7 +133,7 @@ func TestClient_Check_HTTPError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -147,7 +150,7 @@ func TestClient_Check_InvalidJSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -163,7 +166,7 @@ func TestClient_Check_ContextCancellation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
@@ -175,6 +178,118 @@ func TestClient_Check_ContextCancellation(t *testing.T) {
 	}
 }
 
+func TestClient_Check_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New(
+		option.WithRequester(http.DefaultClient),
+		option.WithBaseURL(server.URL+"/"),
+		option.WithTimeout(10*time.Millisecond),
+	)
+	ip := model.MustParseAddr("8.8.8.8")
+
+	_, err := client.Check(context.Background(), ip)
+	if err == nil {
+		t.Fatal("Check() expected error due to client timeout")
+	}
+}
+
+func TestClient_Check_APIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret-token" {
+			t.Errorf("Authorization = %q, want 'Bearer secret-token'", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ip": "8.8.8.8", "country": "US"}`))
+	}))
+	defer server.Close()
+
+	client := New(
+		option.WithRequester(http.DefaultClient),
+		option.WithBaseURL(server.URL+"/"),
+		option.WithAPIKey("secret-token"),
+	)
+	ip := model.MustParseAddr("8.8.8.8")
+
+	if _, err := client.Check(context.Background(), ip); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+}
+
+func TestClient_Check_PaidPlanData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{
+			"ip": "185.220.101.1",
+			"country": "DE",
+			"org": "AS60729 Stiftung Erneuerbare Freiheit",
+			"privacy": {"vpn": false, "proxy": false, "tor": true, "relay": false, "hosting": true, "service": ""},
+			"abuse": {
+				"address": "Berlin, Germany",
+				"country": "DE",
+				"email": "abuse@example.org",
+				"name": "Abuse Desk",
+				"network": "185.220.101.0/24",
+				"phone": "+49 30 1234567"
+			},
+			"company": {"name": "Stiftung Erneuerbare Freiheit", "domain": "example.org", "type": "hosting"}
+		}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("185.220.101.1"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.Security == nil {
+		t.Fatal("Security should be set from the privacy object")
+	}
+	if !geo.Security.Tor || !geo.Security.Hosting || geo.Security.VPN {
+		t.Errorf("Security = %+v, want tor and hosting", *geo.Security)
+	}
+
+	reg := geo.Registration
+	if reg == nil {
+		t.Fatal("Registration should be set from the abuse and company objects")
+	}
+	if reg.Company != "Stiftung Erneuerbare Freiheit" || reg.CompanyDomain != "example.org" || reg.CompanyType != "hosting" {
+		t.Errorf("company = %q %q %q", reg.Company, reg.CompanyDomain, reg.CompanyType)
+	}
+	if reg.AbuseEmail != "abuse@example.org" || reg.Network != "185.220.101.0/24" || reg.AbusePhone != "+49 30 1234567" {
+		t.Errorf("abuse = %+v", *reg)
+	}
+}
+
+func TestClient_Check_FreePlanHasNoExtendedData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"ip": "8.8.8.8", "country": "US", "org": "AS15169 Google LLC"}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.Security != nil || geo.Registration != nil {
+		t.Errorf("Security = %v, Registration = %v, want nil without paid data", geo.Security, geo.Registration)
+	}
+}
+
 func TestParseLocation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -300,3 +415,41 @@ func TestParseOrg(t *testing.T) {
 		})
 	}
 }
+
+func FuzzParseLocation(f *testing.F) {
+	for _, s := range []string{"37.4056,-122.0775", " 1.5 , 2.5 ", "1,2,3", ",", "NaN,Inf", ""} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, loc string) {
+		lat, lon, err := parseLocation(loc)
+		if err != nil && (lat != 0 || lon != 0) {
+			t.Errorf("parseLocation(%q) = %v, %v with error %v, want zero coordinates", loc, lat, lon, err)
+		}
+	})
+}
+
+func FuzzParseOrg(f *testing.F) {
+	for _, s := range []string{"AS15169 Google LLC", "AS15169", "Google LLC", "AS", " AS1 x", ""} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		asn, org := parseOrg(s)
+		if asn == "" {
+			if org != s {
+				t.Errorf("parseOrg(%q) = %q, %q, want the whole string as org", s, asn, org)
+			}
+			return
+		}
+
+		// Nothing of the input is lost or invented
+		joined := asn
+		if org != "" || strings.HasSuffix(s, " ") {
+			joined += " " + org
+		}
+		if !strings.HasPrefix(asn, "AS") || joined != s {
+			t.Errorf("parseOrg(%q) = %q, %q", s, asn, org)
+		}
+	})
+}
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"api-client/internal/model"
 	"api-client/internal/provider"
+	"api-client/internal/provider/option"
 )
 
 const (
@@ -23,18 +26,18 @@ var _ provider.Provider = &Client{}
 
 // response represents the JSON structure returned by ipwhois.app.
 type response struct {
-	Success     bool    `json:"success"`
-	Message     string  `json:"message,omitempty"`
-	IP          string  `json:"ip"`
-	Country     string  `json:"country"`
-	CountryCode string  `json:"country_code"`
-	Region      string  `json:"region"`
-	City        string  `json:"city"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
-	ISP         string  `json:"isp"`
-	Org         string  `json:"org"`
-	ASN         string  `json:"asn"`
+	Success     bool     `json:"success"`
+	Message     string   `json:"message,omitempty"`
+	IP          string   `json:"ip"`
+	Country     string   `json:"country"`
+	CountryCode string   `json:"country_code"`
+	Region      string   `json:"region"`
+	City        string   `json:"city"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	ISP         string   `json:"isp"`
+	Org         string   `json:"org"`
+	ASN         string   `json:"asn"`
 }
 
 func (r response) toGeoLocation(ip model.IPAddress) model.Geolocation {
@@ -55,42 +58,56 @@ func (r response) toGeoLocation(ip model.IPAddress) model.Geolocation {
 type Client struct {
 	requester provider.HttpRequester
 	baseURL   string
+	apiKey    string
+	timeout   time.Duration
 }
 
-// Option configures a Client.
-type Option func(*Client)
+// New creates a new ipwhois.app client.
+func New(opts ...option.Option) *Client {
+	s := option.Apply(option.Settings{BaseURL: BaseURL}, opts...)
 
-// WithBaseURL sets a custom base URL (useful for testing).
-func WithBaseURL(url string) Option {
-	return func(client *Client) {
-		client.baseURL = url
+	return &Client{
+		requester: provider.WithCompression(s.Requester),
+		baseURL:   s.BaseURL,
+		apiKey:    s.APIKey,
+		timeout:   s.Timeout,
 	}
 }
 
-// New creates a new ip-api.com client.
-func New(requester provider.HttpRequester, opts ...Option) *Client {
-	c := &Client{
-		requester: requester,
-		baseURL:   BaseURL,
-	}
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return ProviderName
+}
 
-	for _, opt := range opts {
-		opt(c)
+// Describe reports the request Check would make for ip.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	key := provider.RedactKey(c.apiKey)
+	return provider.Description{
+		Name:    ProviderName,
+		URL:     c.url(ip, key),
+		Timeout: c.timeout,
+		APIKey:  key,
 	}
-
-	return c
 }
 
-// Name returns the provider name.
-func (c *Client) Name() string {
-	return ProviderName
+// url builds the request URL, passing key as a query parameter when set.
+func (c *Client) url(ip model.IPAddress, key string) string {
+	u := c.baseURL + ip.String()
+	if key != "" {
+		u += "?key=" + url.QueryEscape(key)
+	}
+	return u
 }
 
 // Check looks up geolocation data for the given IP address.
 func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
-	url := c.baseURL + ip.String()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ip, c.apiKey), nil)
 	if err != nil {
 		return model.Geolocation{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -101,8 +118,10 @@ func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocati
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	provider.RecordQuota(ctx, resp.Header)
+
 	if resp.StatusCode != http.StatusOK {
-		return model.Geolocation{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return model.Geolocation{}, provider.StatusError{StatusCode: resp.StatusCode}
 	}
 
 	var apiResp response
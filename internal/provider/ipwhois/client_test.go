@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"api-client/internal/model"
+	"api-client/internal/provider/option"
 )
 
 func TestClient_Check_Success(t *testing.T) {
@@ -34,7 +35,7 @@ func TestClient_Check_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	geo, err := client.Check(context.Background(), ip)
@@ -54,11 +55,8 @@ func TestClient_Check_Success(t *testing.T) {
 	if geo.City != "Mountain View" {
 		t.Errorf("City = %v, want Mountain View", geo.City)
 	}
-	if geo.Latitude != 37.386 {
-		t.Errorf("Latitude = %v, want 37.386", geo.Latitude)
-	}
-	if geo.Longitude != -122.084 {
-		t.Errorf("Longitude = %v, want -122.084", geo.Longitude)
+	if lat, lon, ok := geo.Coordinates(); !ok || lat != 37.386 || lon != -122.084 {
+		t.Errorf("Coordinates() = %v, %v, %v; want 37.386, -122.084", lat, lon, ok)
 	}
 	if geo.ISP != "Google LLC" {
 		t.Errorf("ISP = %v, want Google LLC", geo.ISP)
@@ -95,7 +93,7 @@ func TestClient_Check_IPv6(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("2001:4860:4860::8888")
 
 	geo, err := client.Check(context.Background(), ip)
@@ -119,7 +117,7 @@ func TestClient_Check_APIError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("127.0.0.1")
 
 	_, err := client.Check(context.Background(), ip)
@@ -142,7 +140,7 @@ func TestClient_Check_APIErrorNoMessage(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("127.0.0.1")
 
 	_, err := client.Check(context.Background(), ip)
@@ -161,7 +159,7 @@ func TestClient_Check_HTTPError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -178,7 +176,7 @@ func TestClient_Check_InvalidJSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -194,7 +192,7 @@ func TestClient_Check_ContextCancellation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := New(http.DefaultClient, WithBaseURL(server.URL+"/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
@@ -206,8 +204,55 @@ func TestClient_Check_ContextCancellation(t *testing.T) {
 	}
 }
 
+func TestClient_Check_APIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("key"); got != "secret-key" {
+			t.Errorf("key = %q, want secret-key", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"success": true, "ip": "8.8.8.8", "country_code": "US"}`))
+	}))
+	defer server.Close()
+
+	client := New(
+		option.WithRequester(http.DefaultClient),
+		option.WithBaseURL(server.URL+"/"),
+		option.WithAPIKey("secret-key"),
+	)
+	ip := model.MustParseAddr("8.8.8.8")
+
+	if _, err := client.Check(context.Background(), ip); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+}
+
+func TestClient_Describe(t *testing.T) {
+	client := New(
+		option.WithBaseURL("https://ipwhois.example/json/"),
+		option.WithAPIKey("0123456789abcdef"),
+		option.WithTimeout(3*time.Second),
+	)
+
+	d := client.Describe(model.MustParseAddr("8.8.8.8"))
+
+	if d.Name != ProviderName {
+		t.Errorf("Name = %q, want %q", d.Name, ProviderName)
+	}
+	if d.URL != "https://ipwhois.example/json/8.8.8.8?key=%2A%2A%2A%2Acdef" {
+		t.Errorf("URL = %q, want key redacted", d.URL)
+	}
+	if d.APIKey != "****cdef" {
+		t.Errorf("APIKey = %q, want ****cdef", d.APIKey)
+	}
+	if d.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v, want 3s", d.Timeout)
+	}
+}
+
 func TestClient_Check_ConnectionError(t *testing.T) {
-	client := New(http.DefaultClient, WithBaseURL("http://localhost:1/"))
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL("http://localhost:1/"))
 	ip := model.MustParseAddr("8.8.8.8")
 
 	_, err := client.Check(context.Background(), ip)
@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// loggingProvider logs the checks of the wrapped Provider.
+type loggingProvider struct {
+	wrapped
+	logger *slog.Logger
+}
+
+// WithLogging wraps p so that each of its checks is logged to logger, with
+// the provider name, the address and the duration: successes at debug
+// level and failures, with their error, at warning level. A nil logger
+// returns p unchanged.
+func WithLogging(p Provider, logger *slog.Logger) Provider {
+	if logger == nil {
+		return p
+	}
+	return loggingProvider{wrapped: wrapped{p}, logger: logger}
+}
+
+func (lp loggingProvider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	start := time.Now()
+	geo, err := lp.Provider.Check(ctx, ip)
+
+	attrs := []slog.Attr{
+		slog.String("provider", lp.Name()),
+		slog.String("ip", ip.String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		lp.logger.LogAttrs(ctx, slog.LevelWarn, "check failed", append(attrs, slog.String("error", err.Error()))...)
+	} else {
+		lp.logger.LogAttrs(ctx, slog.LevelDebug, "check succeeded", attrs...)
+	}
+	return geo, err
+}
@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+func TestWithLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	p := WithLogging(NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		if ip.Is6() {
+			return model.Geolocation{}, errors.New("boom")
+		}
+		return model.Geolocation{IP: ip}, nil
+	})), logger)
+
+	_, _ = p.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	_, _ = p.Check(context.Background(), model.MustParseAddr("2001:db8::1"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"level=DEBUG", `msg="check succeeded"`, "provider=p", "ip=8.8.8.8", "duration="} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("success line %q lacks %q", lines[0], want)
+		}
+	}
+	for _, want := range []string{"level=WARN", `msg="check failed"`, "ip=2001:db8::1", "error=boom"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("failure line %q lacks %q", lines[1], want)
+		}
+	}
+}
+
+func TestWithLogging_Nil(t *testing.T) {
+	if _, wrapped := WithLogging(NewTestProvider("p", nil), nil).(loggingProvider); wrapped {
+		t.Error("WithLogging(p, nil) should return p unchanged")
+	}
+}
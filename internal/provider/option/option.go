@@ -0,0 +1,75 @@
+// Package option provides the functional options shared by all provider clients.
+package option
+
+import (
+	"net/http"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+// Settings holds the configuration a provider client is built from.
+type Settings struct {
+	Requester provider.HttpRequester
+	BaseURL   string
+	APIKey    string
+	Timeout   time.Duration
+	Language  string
+}
+
+// Option configures the Settings of a provider client.
+type Option func(*Settings)
+
+// WithRequester sets the HttpRequester used to execute requests.
+func WithRequester(requester provider.HttpRequester) Option {
+	return func(s *Settings) {
+		s.Requester = requester
+	}
+}
+
+// WithBaseURL sets a custom base URL (useful for testing).
+func WithBaseURL(url string) Option {
+	return func(s *Settings) {
+		s.BaseURL = url
+	}
+}
+
+// WithAPIKey sets the API key or token used to authenticate with the provider.
+// Providers without authenticated access ignore it.
+func WithAPIKey(key string) Option {
+	return func(s *Settings) {
+		s.APIKey = key
+	}
+}
+
+// WithTimeout bounds every Check call of the client to the given duration.
+// A zero duration leaves the caller's context deadline as the only limit.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Settings) {
+		s.Timeout = d
+	}
+}
+
+// WithLanguage requests place names in the given language, as a BCP 47
+// tag such as "de" or "pt-BR". Providers that cannot localize ignore it.
+func WithLanguage(lang string) Option {
+	return func(s *Settings) {
+		s.Language = lang
+	}
+}
+
+// Apply applies opts on top of defaults and returns the resulting Settings.
+// If no requester was provided, an http.Client with the default request
+// timeout is used.
+func Apply(defaults Settings, opts ...Option) Settings {
+	s := defaults
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if s.Requester == nil {
+		s.Requester = &http.Client{Timeout: provider.DefaultRequestTimeout}
+	}
+
+	return s
+}
@@ -0,0 +1,54 @@
+package option
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestApply_Defaults(t *testing.T) {
+	s := Apply(Settings{BaseURL: "https://example.com/"})
+
+	if s.BaseURL != "https://example.com/" {
+		t.Errorf("BaseURL = %q, want default", s.BaseURL)
+	}
+
+	if s.Requester == nil {
+		t.Error("Requester should default to an http.Client")
+	}
+
+	if s.APIKey != "" {
+		t.Errorf("APIKey = %q, want empty", s.APIKey)
+	}
+
+	if s.Timeout != 0 {
+		t.Errorf("Timeout = %v, want 0", s.Timeout)
+	}
+}
+
+func TestApply_Options(t *testing.T) {
+	requester := &http.Client{}
+
+	s := Apply(Settings{BaseURL: "https://example.com/"},
+		WithRequester(requester),
+		WithBaseURL("http://localhost:8080/"),
+		WithAPIKey("secret"),
+		WithTimeout(2*time.Second),
+	)
+
+	if s.Requester != requester {
+		t.Error("Requester was not applied")
+	}
+
+	if s.BaseURL != "http://localhost:8080/" {
+		t.Errorf("BaseURL = %q, want http://localhost:8080/", s.BaseURL)
+	}
+
+	if s.APIKey != "secret" {
+		t.Errorf("APIKey = %q, want secret", s.APIKey)
+	}
+
+	if s.Timeout != 2*time.Second {
+		t.Errorf("Timeout = %v, want 2s", s.Timeout)
+	}
+}
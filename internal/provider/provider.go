@@ -2,6 +2,8 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 
 	"api-client/internal/model"
 )
@@ -41,3 +43,30 @@ func NewTestProvider(name string, checker Checker) Provider {
 }
 
 var _ Checker = CheckerFunc(nil)
+
+// NotApplicableError is returned by providers that have nothing to report
+// for an address by design, such as local datasets covering only some
+// ranges. Such results are reported as skipped rather than failed.
+type NotApplicableError struct {
+	Reason string
+}
+
+func (e NotApplicableError) Error() string {
+	return e.Reason
+}
+
+// StatusError is returned by providers when the API answers with an
+// unexpected HTTP status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// Unauthorized reports whether the status code means the credentials were
+// missing or rejected.
+func (e StatusError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
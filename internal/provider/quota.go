@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-client/internal/model"
+)
+
+type quotaKey struct{}
+
+// WithQuotaRecorder returns a context in which RecordQuota stores the quota
+// reported by a provider into q. Recording through the context lets quota
+// pass through provider decorators and keeps concurrent checks apart.
+func WithQuotaRecorder(ctx context.Context, q **model.Quota) context.Context {
+	return context.WithValue(ctx, quotaKey{}, q)
+}
+
+// RecordQuota parses the rate-limit headers of a provider response and
+// stores the quota in the recorder of ctx, if any.
+func RecordQuota(ctx context.Context, h http.Header) {
+	q, ok := ctx.Value(quotaKey{}).(**model.Quota)
+	if !ok {
+		return
+	}
+
+	if quota, ok := ParseQuota(h, time.Now()); ok {
+		*q = &quota
+	}
+}
+
+// QuotaHeaders are the rate-limit headers ParseQuota reads. They describe
+// the quota at the time of a response, so caches must not replay them.
+var QuotaHeaders = []string{"X-Rl", "X-Ttl", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// ParseQuota reads rate-limit headers: ip-api's X-Rl (remaining requests)
+// and X-Ttl (seconds until reset), or the common X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset, whose reset may be given in
+// seconds or as a Unix timestamp.
+func ParseQuota(h http.Header, now time.Time) (model.Quota, bool) {
+	if remaining, ok := headerInt(h, "X-Rl"); ok {
+		q := model.Quota{Remaining: remaining}
+		if ttl, ok := headerInt(h, "X-Ttl"); ok {
+			q.ResetIn = time.Duration(ttl) * time.Second
+		}
+		return q, true
+	}
+
+	remaining, ok := headerInt(h, "X-RateLimit-Remaining")
+	if !ok {
+		return model.Quota{}, false
+	}
+
+	q := model.Quota{Remaining: remaining}
+	if limit, ok := headerInt(h, "X-RateLimit-Limit"); ok {
+		q.Limit = limit
+	}
+	if reset, ok := headerInt(h, "X-RateLimit-Reset"); ok {
+		// Values this large are timestamps rather than durations
+		if reset > 1_000_000_000 {
+			q.ResetIn = max(time.Unix(int64(reset), 0).Sub(now), 0)
+		} else {
+			q.ResetIn = time.Duration(reset) * time.Second
+		}
+	}
+
+	return q, true
+}
+
+func headerInt(h http.Header, name string) (int, bool) {
+	value := strings.TrimSpace(h.Get(name))
+	if value == "" {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
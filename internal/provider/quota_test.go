@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestParseQuota(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    model.Quota
+		wantOK  bool
+	}{
+		{
+			name:    "ip-api",
+			headers: map[string]string{"X-Rl": "44", "X-Ttl": "60"},
+			want:    model.Quota{Remaining: 44, ResetIn: time.Minute},
+			wantOK:  true,
+		},
+		{
+			name:    "rate limit seconds",
+			headers: map[string]string{"X-RateLimit-Limit": "1000", "X-RateLimit-Remaining": "12", "X-RateLimit-Reset": "30"},
+			want:    model.Quota{Limit: 1000, Remaining: 12, ResetIn: 30 * time.Second},
+			wantOK:  true,
+		},
+		{
+			name:    "rate limit timestamp",
+			headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000090"},
+			want:    model.Quota{Remaining: 0, ResetIn: 90 * time.Second},
+			wantOK:  true,
+		},
+		{
+			name:    "none",
+			headers: map[string]string{"Content-Type": "application/json"},
+		},
+		{
+			name:    "malformed",
+			headers: map[string]string{"X-RateLimit-Remaining": "many"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+
+			got, ok := ParseQuota(h, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseQuota() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("ParseQuota() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordQuota(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Rl", "3")
+
+	// Without a recorder the call is a no-op
+	RecordQuota(context.Background(), h)
+
+	var quota *model.Quota
+	RecordQuota(WithQuotaRecorder(context.Background(), &quota), h)
+
+	if quota == nil || quota.Remaining != 3 {
+		t.Errorf("recorded quota = %v, want 3 remaining", quota)
+	}
+}
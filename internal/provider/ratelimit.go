@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"api-client/internal/model"
+)
+
+type waitKey struct{}
+
+// WithWaitRecorder returns a context in which WithRateLimit and WithQuota
+// add the time a check waited for its turn to d, so that callers timing
+// the check can tell the wait apart from the latency of the provider.
+func WithWaitRecorder(ctx context.Context, d *time.Duration) context.Context {
+	return context.WithValue(ctx, waitKey{}, d)
+}
+
+// recordWait adds d to the wait recorder of ctx, if any.
+func recordWait(ctx context.Context, d time.Duration) {
+	if w, ok := ctx.Value(waitKey{}).(*time.Duration); ok {
+		*w += d
+	}
+}
+
+// rateLimitProvider spaces out the checks of the wrapped Provider.
+type rateLimitProvider struct {
+	wrapped
+	limiter *limiter
+}
+
+// WithRateLimit wraps p so that at most n checks start in any period of
+// per, evenly spaced, however many goroutines share it. A check waiting
+// for its turn gives up when its context is done, without giving its turn
+// back; the wait is recorded with WithWaitRecorder. A non-positive n or
+// per returns p unchanged.
+func WithRateLimit(p Provider, n int, per time.Duration) Provider {
+	if n <= 0 || per <= 0 {
+		return p
+	}
+	return rateLimitProvider{wrapped: wrapped{p}, limiter: &limiter{interval: per / time.Duration(n)}}
+}
+
+func (rp rateLimitProvider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if err := rp.limiter.wait(ctx); err != nil {
+		return model.Geolocation{}, err
+	}
+	return rp.Provider.Check(ctx, ip)
+}
+
+// limiter hands out turns at least interval apart.
+type limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the next turn, or until ctx is done.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	turn := l.next
+	if turn.Before(now) {
+		turn = now
+	}
+	l.next = turn.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, turn.Sub(now))
+}
+
+// quotaProvider holds the checks of the wrapped Provider to its quota.
+type quotaProvider struct {
+	wrapped
+	window *window
+}
+
+// WithQuota wraps p so that at most n checks start in any period of per,
+// as early as that allows: the first n at once, and each next one as soon
+// as the one n before it is per old. Unlike WithRateLimit, a quota that is
+// not used up, such as 1000 per day for a batch of 500, does not slow the
+// checks down. A check waiting for its turn gives up when its context is
+// done, without giving its turn back; the wait is recorded with
+// WithWaitRecorder. A non-positive n or per returns p unchanged.
+func WithQuota(p Provider, n int, per time.Duration) Provider {
+	if n <= 0 || per <= 0 {
+		return p
+	}
+	return quotaProvider{wrapped: wrapped{p}, window: &window{n: n, per: per}}
+}
+
+func (qp quotaProvider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if err := qp.window.wait(ctx); err != nil {
+		return model.Geolocation{}, err
+	}
+	return qp.Provider.Check(ctx, ip)
+}
+
+// QuotaDuration returns how long starting checks takes at most n per per:
+// the time the last one waits for its turn, the first n starting at once.
+func QuotaDuration(checks, n int, per time.Duration) time.Duration {
+	if checks <= 0 || n <= 0 {
+		return 0
+	}
+	return time.Duration((checks-1)/n) * per
+}
+
+// window hands out turns of which at most n fall in any period of per.
+type window struct {
+	n   int
+	per time.Duration
+
+	mu    sync.Mutex
+	turns []time.Time // the last n turns, oldest at next once full
+	next  int
+}
+
+// wait blocks until the next turn, or until ctx is done.
+func (w *window) wait(ctx context.Context) error {
+	w.mu.Lock()
+	now := time.Now()
+	turn := now
+	if len(w.turns) < w.n {
+		w.turns = append(w.turns, turn)
+	} else {
+		if earliest := w.turns[w.next].Add(w.per); earliest.After(turn) {
+			turn = earliest
+		}
+		w.turns[w.next] = turn
+		w.next = (w.next + 1) % w.n
+	}
+	w.mu.Unlock()
+
+	return sleep(ctx, turn.Sub(now))
+}
+
+// sleep blocks for delay, or until ctx is done, recording the time waited.
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() { recordWait(ctx, time.Since(start)) }()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
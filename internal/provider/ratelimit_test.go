@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestWithRateLimit_Spacing(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	p := WithRateLimit(NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return model.Geolocation{IP: ip}, nil
+	})), 2, 100*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Four checks at two per 100ms start over at least 150ms
+	first, last := starts[0], starts[0]
+	for _, s := range starts {
+		if s.Before(first) {
+			first = s
+		}
+		if s.After(last) {
+			last = s
+		}
+	}
+	if spread := last.Sub(first); spread < 140*time.Millisecond {
+		t.Errorf("checks started within %s, want them spaced 50ms apart", spread)
+	}
+}
+
+func TestWithRateLimit_Cancelled(t *testing.T) {
+	p := WithRateLimit(NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{IP: ip}, nil
+	})), 1, time.Hour)
+
+	ip := model.MustParseAddr("8.8.8.8")
+	if _, err := p.Check(context.Background(), ip); err != nil {
+		t.Fatalf("first Check() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Check(ctx, ip); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second Check() error = %v, want to give up waiting", err)
+	}
+}
+
+func TestWithQuota(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	p := WithQuota(NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return model.Geolocation{IP: ip}, nil
+	})), 3, 200*time.Millisecond)
+
+	begin := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first three start at once, the last two a period later
+	var early, late int
+	for _, s := range starts {
+		switch d := s.Sub(begin); {
+		case d < 100*time.Millisecond:
+			early++
+		case d >= 190*time.Millisecond:
+			late++
+		}
+	}
+	if early != 3 || late != 2 {
+		t.Errorf("%d checks started at once and %d a period later, want 3 and 2", early, late)
+	}
+}
+
+func TestQuotaDuration(t *testing.T) {
+	tests := []struct {
+		checks, n int
+		per       time.Duration
+		want      time.Duration
+	}{
+		{0, 45, time.Minute, 0},
+		{45, 45, time.Minute, 0},
+		{46, 45, time.Minute, time.Minute},
+		{1000, 45, time.Minute, 22 * time.Minute},
+		{500, 1000, 24 * time.Hour, 0},
+	}
+	for _, tt := range tests {
+		if got := QuotaDuration(tt.checks, tt.n, tt.per); got != tt.want {
+			t.Errorf("QuotaDuration(%d, %d, %s) = %s, want %s", tt.checks, tt.n, tt.per, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package registry maps provider names to their constructors so that
+// providers can be built generically from configuration.
+package registry
+
+import (
+	"fmt"
+
+	"api-client/internal/provider"
+	"api-client/internal/provider/abuseipdb"
+	"api-client/internal/provider/ipapi"
+	"api-client/internal/provider/ipinfo"
+	"api-client/internal/provider/ipwhois"
+	"api-client/internal/provider/option"
+	"api-client/internal/provider/ripestat"
+	"api-client/internal/provider/whois"
+)
+
+// Factory builds a Provider from the shared client options.
+type Factory func(opts ...option.Option) provider.Provider
+
+type entry struct {
+	name    string
+	factory Factory
+	// optIn providers are only queried when enabled explicitly.
+	optIn bool
+}
+
+// entries lists the known providers in their default query order.
+var entries = []entry{
+	{ipapi.ProviderName, func(opts ...option.Option) provider.Provider { return ipapi.New(opts...) }, false},
+	{ipinfo.ProviderName, func(opts ...option.Option) provider.Provider { return ipinfo.New(opts...) }, false},
+	{ipwhois.ProviderName, func(opts ...option.Option) provider.Provider { return ipwhois.New(opts...) }, false},
+	{whois.ProviderName, func(opts ...option.Option) provider.Provider { return whois.New(opts...) }, true},
+	{ripestat.ProviderName, func(opts ...option.Option) provider.Provider { return ripestat.New(opts...) }, true},
+	{abuseipdb.ProviderName, func(opts ...option.Option) provider.Provider { return abuseipdb.New(opts...) }, true},
+}
+
+// Names returns the names of all registered providers in default order.
+func Names() []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.name
+	}
+	return names
+}
+
+// Defaults returns the names of the providers enabled when none are
+// configured, in default order. Opt-in providers, such as whois, which
+// speaks a cleartext protocol, and ripestat, which reports no location,
+// are left out.
+func Defaults() []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.optIn {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// Lookup returns the Factory registered under name.
+func Lookup(name string) (Factory, bool) {
+	for _, e := range entries {
+		if e.name == name {
+			return e.factory, true
+		}
+	}
+	return nil, false
+}
+
+// New builds the named provider with the given options.
+func New(name string, opts ...option.Option) (provider.Provider, error) {
+	factory, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+	return factory(opts...), nil
+}
+
+// All builds every registered provider with the same options.
+func All(opts ...option.Option) []provider.Provider {
+	providers := make([]provider.Provider, len(entries))
+	for i, e := range entries {
+		providers[i] = e.factory(opts...)
+	}
+	return providers
+}
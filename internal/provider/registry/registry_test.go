@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"testing"
+
+	"api-client/internal/provider/option"
+)
+
+func TestNames(t *testing.T) {
+	names := Names()
+	want := []string{"ip-api", "ipinfo", "ipwhois", "whois", "ripestat", "abuseipdb"}
+
+	if len(names) != len(want) {
+		t.Fatalf("Names() = %v, want %v", names, want)
+	}
+
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Names()[%d] = %q, want %q", i, names[i], want[i])
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	names := Defaults()
+	want := []string{"ip-api", "ipinfo", "ipwhois"}
+
+	if len(names) != len(want) {
+		t.Fatalf("Defaults() = %v, want %v", names, want)
+	}
+
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Defaults()[%d] = %q, want %q", i, names[i], want[i])
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			p, err := New(name, option.WithBaseURL("http://localhost:1/"))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			if p.Name() != name {
+				t.Errorf("Name() = %q, want %q", p.Name(), name)
+			}
+		})
+	}
+}
+
+func TestNew_Unknown(t *testing.T) {
+	if _, err := New("nope"); err == nil {
+		t.Fatal("New() expected error for unknown provider")
+	}
+}
+
+func TestAll(t *testing.T) {
+	providers := All()
+	if len(providers) != len(Names()) {
+		t.Fatalf("All() returned %d providers, want %d", len(providers), len(Names()))
+	}
+
+	for i, p := range providers {
+		if p.Name() != Names()[i] {
+			t.Errorf("All()[%d].Name() = %q, want %q", i, p.Name(), Names()[i])
+		}
+	}
+}
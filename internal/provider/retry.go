@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// retryProvider retries the checks of the wrapped Provider that fail
+// transiently.
+type retryProvider struct {
+	wrapped
+	attempts int
+	backoff  time.Duration
+}
+
+// WithRetry wraps p so that a check failing transiently, on a network
+// error, a timeout of its own, a 429 or a 5xx status, is attempted up to
+// attempts times in all, waiting backoff before the second attempt and
+// twice as long before each further one. Other failures, and those after
+// the caller's context is done, are returned at once. Fewer than two
+// attempts return p unchanged.
+func WithRetry(p Provider, attempts int, backoff time.Duration) Provider {
+	if attempts < 2 {
+		return p
+	}
+	return retryProvider{wrapped: wrapped{p}, attempts: attempts, backoff: backoff}
+}
+
+func (rp retryProvider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	delay := rp.backoff
+	for attempt := 1; ; attempt++ {
+		geo, err := rp.Provider.Check(ctx, ip)
+		if err == nil || attempt == rp.attempts || ctx.Err() != nil || !transient(err) {
+			return geo, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return geo, err
+		}
+		delay *= 2
+	}
+}
+
+// transient reports whether err is a failure that may not happen again:
+// a network error, a timeout or a status asking to come back later.
+func transient(err error) bool {
+	var serr StatusError
+	if errors.As(err, &serr) {
+		return serr.StatusCode == http.StatusTooManyRequests || serr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var nerr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &nerr)
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// failingProvider fails its first checks with the given errors, then
+// succeeds, counting its checks.
+func failingProvider(calls *int, errs ...error) Provider {
+	return NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		*calls++
+		if *calls <= len(errs) {
+			return model.Geolocation{}, errs[*calls-1]
+		}
+		return model.Geolocation{IP: ip, Country: "United States"}, nil
+	}))
+}
+
+func TestWithRetry_Transient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"throttled", StatusError{StatusCode: http.StatusTooManyRequests}},
+		{"server error", StatusError{StatusCode: http.StatusBadGateway}},
+		{"timeout", context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			p := WithRetry(failingProvider(&calls, tt.err, tt.err), 3, time.Millisecond)
+
+			geo, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+			if err != nil || geo.Country != "United States" {
+				t.Errorf("Check() = %+v, %v, want success on the third attempt", geo, err)
+			}
+			if calls != 3 {
+				t.Errorf("calls = %d, want 3", calls)
+			}
+		})
+	}
+}
+
+func TestWithRetry_Permanent(t *testing.T) {
+	for _, err := range []error{StatusError{StatusCode: http.StatusForbidden}, NotApplicableError{Reason: "n/a"}, errors.New("API error")} {
+		calls := 0
+		_, got := WithRetry(failingProvider(&calls, err), 3, time.Millisecond).Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+		if got != err || calls != 1 {
+			t.Errorf("Check() error = %v after %d calls, want %v after 1", got, calls, err)
+		}
+	}
+}
+
+func TestWithRetry_GivesUp(t *testing.T) {
+	calls := 0
+	unavailable := StatusError{StatusCode: http.StatusServiceUnavailable}
+	p := WithRetry(failingProvider(&calls, unavailable, unavailable, unavailable), 2, time.Millisecond)
+
+	if _, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8")); err != unavailable {
+		t.Errorf("Check() error = %v, want %v", err, unavailable)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestWithRetry_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	p := WithRetry(NewTestProvider("p", CheckerFunc(func(context.Context, model.IPAddress) (model.Geolocation, error) {
+		calls++
+		cancel()
+		return model.Geolocation{}, StatusError{StatusCode: http.StatusServiceUnavailable}
+	})), 5, time.Hour)
+
+	start := time.Now()
+	if _, err := p.Check(ctx, model.MustParseAddr("8.8.8.8")); err == nil {
+		t.Error("Check() should fail")
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("calls = %d after %s, want 1 without waiting", calls, time.Since(start))
+	}
+}
+
+func TestWithRetry_SingleAttempt(t *testing.T) {
+	calls := 0
+	if _, wrapped := WithRetry(failingProvider(&calls), 1, time.Second).(retryProvider); wrapped {
+		t.Error("WithRetry(p, 1, d) should return p unchanged")
+	}
+}
@@ -0,0 +1,146 @@
+// Package ripestat provides a client for the network-info data call of
+// RIPEstat, which reports the BGP prefix announcing an address and its
+// origin AS, as seen by the RIPE NCC route collectors.
+package ripestat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+)
+
+const (
+	// ProviderName identifies this provider in reports.
+	ProviderName = "ripestat"
+
+	// BaseURL is the API endpoint.
+	BaseURL = "https://stat.ripe.net/data/network-info/data.json"
+
+	// sourceApp identifies the client to RIPEstat, as its fair use policy
+	// asks of regular users.
+	sourceApp = "ipintel"
+)
+
+var _ provider.Provider = &Client{}
+
+// response represents the JSON structure returned by the network-info
+// data call.
+type response struct {
+	Status   string     `json:"status"`
+	Messages [][]string `json:"messages"`
+	Data     struct {
+		ASNs   []string `json:"asns"`
+		Prefix string   `json:"prefix"`
+	} `json:"data"`
+}
+
+// message returns the first error message of r.
+func (r response) message() string {
+	for _, m := range r.Messages {
+		if len(m) == 2 && m[0] == "error" {
+			return m[1]
+		}
+	}
+	return "unknown error"
+}
+
+func (r response) toGeoLocation(ip model.IPAddress, prefix model.Prefix) model.Geolocation {
+	geo := model.Geolocation{IP: ip, Prefix: &prefix}
+	// A prefix announced by several origins (MOAS) is reported under the
+	// first one
+	if len(r.Data.ASNs) > 0 {
+		geo.ASN = "AS" + strings.TrimPrefix(r.Data.ASNs[0], "AS")
+	}
+	return geo
+}
+
+// Client looks addresses up in RIPEstat.
+type Client struct {
+	requester provider.HttpRequester
+	baseURL   string
+	timeout   time.Duration
+}
+
+// New creates a new RIPEstat client. The API needs no key.
+func New(opts ...option.Option) *Client {
+	s := option.Apply(option.Settings{BaseURL: BaseURL}, opts...)
+
+	return &Client{
+		requester: provider.WithCompression(s.Requester),
+		baseURL:   s.BaseURL,
+		timeout:   s.Timeout,
+	}
+}
+
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return ProviderName
+}
+
+// Describe reports the request Check would make for ip.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	return provider.Description{
+		Name:    ProviderName,
+		URL:     c.url(ip),
+		Timeout: c.timeout,
+	}
+}
+
+// url builds the request URL for ip.
+func (c *Client) url(ip model.IPAddress) string {
+	return c.baseURL + "?resource=" + url.QueryEscape(ip.String()) + "&sourceapp=" + sourceApp
+}
+
+// Check looks up the announced prefix and origin AS of the given IP address.
+// Addresses that are not announced are reported as not applicable.
+func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ip), nil)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.requester.Do(req)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("executing request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	provider.RecordQuota(ctx, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		return model.Geolocation{}, provider.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var apiResp response
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return model.Geolocation{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	if apiResp.Status != "ok" {
+		return model.Geolocation{}, fmt.Errorf("API error: %s", apiResp.message())
+	}
+
+	if apiResp.Data.Prefix == "" {
+		return model.Geolocation{}, provider.NotApplicableError{Reason: "not announced in BGP"}
+	}
+	prefix, err := model.ParsePrefix(apiResp.Data.Prefix)
+	if err != nil {
+		return model.Geolocation{}, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return apiResp.toGeoLocation(ip, prefix.Masked()), nil
+}
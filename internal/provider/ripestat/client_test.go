@@ -0,0 +1,108 @@
+package ripestat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+)
+
+func TestClient_Check_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("resource"); got != "8.8.8.8" {
+			t.Errorf("resource = %q, want 8.8.8.8", got)
+		}
+		if got := r.URL.Query().Get("sourceapp"); got != "ipintel" {
+			t.Errorf("sourceapp = %q, want ipintel", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"status": "ok",
+			"messages": [],
+			"data": {"asns": ["15169"], "prefix": "8.8.8.0/24"}
+		}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL+"/data.json"))
+
+	geo, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if geo.Prefix == nil || *geo.Prefix != model.MustParsePrefix("8.8.8.0/24") {
+		t.Errorf("Prefix = %v, want 8.8.8.0/24", geo.Prefix)
+	}
+	if geo.ASN != "AS15169" {
+		t.Errorf("ASN = %q, want AS15169", geo.ASN)
+	}
+	if geo.Country != "" || geo.HasLocation() {
+		t.Errorf("Check() reported a location: %+v", geo)
+	}
+}
+
+func TestClient_Check_NotAnnounced(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status": "ok", "data": {"asns": [], "prefix": null}}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL))
+
+	_, err := client.Check(context.Background(), model.MustParseAddr("192.0.2.1"))
+	var notApplicable provider.NotApplicableError
+	if !errors.As(err, &notApplicable) {
+		t.Errorf("Check() error = %v, want a NotApplicableError", err)
+	}
+}
+
+func TestClient_Check_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"status": "error",
+			"messages": [["info", "ignored"], ["error", "Invalid resource"]]
+		}`))
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL))
+
+	_, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err == nil || err.Error() != "API error: Invalid resource" {
+		t.Errorf("Check() error = %v, want 'API error: Invalid resource'", err)
+	}
+}
+
+func TestClient_Check_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New(option.WithRequester(http.DefaultClient), option.WithBaseURL(server.URL))
+
+	_, err := client.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	var statusErr provider.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Check() error = %v, want HTTP 429", err)
+	}
+}
+
+func TestClient_Describe(t *testing.T) {
+	client := New()
+	d := client.Describe(model.MustParseAddr("2001:4860:4860::8888"))
+
+	want := BaseURL + "?resource=2001%3A4860%3A4860%3A%3A8888&sourceapp=ipintel"
+	if d.URL != want {
+		t.Errorf("Describe().URL = %q, want %q", d.URL, want)
+	}
+	if d.Name != ProviderName || d.APIKey != "" {
+		t.Errorf("Describe() = %+v", d)
+	}
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// timeoutProvider bounds every Check of the wrapped Provider by its own deadline.
+type timeoutProvider struct {
+	Provider
+	timeout time.Duration
+}
+
+// WithTimeout wraps p so that each Check runs with a context deadline of d,
+// independent of (but never exceeding) the deadline of the caller's context.
+// A non-positive d returns p unchanged.
+func WithTimeout(p Provider, d time.Duration) Provider {
+	if d <= 0 {
+		return p
+	}
+	return timeoutProvider{Provider: p, timeout: d}
+}
+
+func (tp timeoutProvider) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	ctx, cancel := context.WithTimeout(ctx, tp.timeout)
+	defer cancel()
+
+	return tp.Provider.Check(ctx, ip)
+}
+
+// Describe reports the wrapped provider's Description with the effective timeout.
+func (tp timeoutProvider) Describe(ip model.IPAddress) Description {
+	d := Describe(tp.Provider, ip)
+	if d.Timeout == 0 || tp.timeout < d.Timeout {
+		d.Timeout = tp.timeout
+	}
+	return d
+}
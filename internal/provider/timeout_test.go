@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func TestWithTimeout_Deadline(t *testing.T) {
+	slow := NewTestProvider("slow", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		select {
+		case <-ctx.Done():
+			return model.Geolocation{}, ctx.Err()
+		case <-time.After(time.Second):
+			return model.Geolocation{IP: ip}, nil
+		}
+	}))
+
+	p := WithTimeout(slow, 10*time.Millisecond)
+
+	if p.Name() != "slow" {
+		t.Errorf("Name() = %q, want slow", p.Name())
+	}
+
+	start := time.Now()
+	_, err := p.Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Check() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Check() took %v, expected to be bounded by the timeout", elapsed)
+	}
+}
+
+func TestWithTimeout_Success(t *testing.T) {
+	fast := NewTestProvider("fast", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected context to carry a deadline")
+		}
+		return model.Geolocation{IP: ip, Country: "United States"}, nil
+	}))
+
+	geo, err := WithTimeout(fast, time.Second).Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if geo.Country != "United States" {
+		t.Errorf("Country = %q, want United States", geo.Country)
+	}
+}
+
+func TestWithTimeout_NonPositive(t *testing.T) {
+	p := NewTestProvider("p", CheckerFunc(func(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+		return model.Geolocation{}, nil
+	}))
+
+	if _, wrapped := WithTimeout(p, 0).(timeoutProvider); wrapped {
+		t.Error("WithTimeout(p, 0) should return p unchanged")
+	}
+}
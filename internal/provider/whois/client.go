@@ -0,0 +1,254 @@
+// Package whois provides a provider querying the classic whois service
+// (RFC 3912, TCP port 43) of the regional internet registries. It starts at
+// IANA, follows referrals to the registry holding the address, and reports
+// who the address is registered to rather than where it is.
+package whois
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+)
+
+const (
+	// ProviderName identifies this provider in reports.
+	ProviderName = "whois"
+
+	// BaseURL is the server queried first, which refers to the registry
+	// holding the address.
+	BaseURL = "whois://whois.iana.org"
+
+	// maxReferrals bounds the chain of referrals followed from BaseURL.
+	maxReferrals = 3
+
+	// maxResponseSize bounds each response, as a guard against runaway
+	// servers.
+	maxResponseSize = 1 << 20
+)
+
+var _ provider.Provider = &Client{}
+
+// registries names the registry behind each well-known whois server.
+var registries = map[string]string{
+	"whois.iana.org":    "IANA",
+	"whois.arin.net":    "ARIN",
+	"whois.ripe.net":    "RIPE NCC",
+	"whois.apnic.net":   "APNIC",
+	"whois.lacnic.net":  "LACNIC",
+	"whois.afrinic.net": "AFRINIC",
+}
+
+// Client looks addresses up in the whois databases of the registries.
+type Client struct {
+	dialer  net.Dialer
+	server  string
+	timeout time.Duration
+}
+
+// New creates a new whois client. The base URL is the first server
+// queried, as "whois://host[:port]" or "host[:port]".
+func New(opts ...option.Option) *Client {
+	s := option.Apply(option.Settings{BaseURL: BaseURL}, opts...)
+
+	return &Client{
+		server:  serverAddr(s.BaseURL),
+		timeout: s.Timeout,
+	}
+}
+
+// Name returns the provider name.
+func (c *Client) Name() string {
+	return ProviderName
+}
+
+// Describe reports the first query Check would make for ip. Whois is a
+// cleartext protocol.
+func (c *Client) Describe(ip model.IPAddress) provider.Description {
+	return provider.Description{
+		Name:    ProviderName,
+		URL:     "whois://" + c.server + "/" + ip.String(),
+		Timeout: c.timeout,
+	}
+}
+
+// Check queries the first server for ip, follows its referrals to the
+// registry holding the address and returns what that registry reports.
+func (c *Client) Check(ctx context.Context, ip model.IPAddress) (model.Geolocation, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	server := c.server
+	for i := 0; ; i++ {
+		text, err := c.query(ctx, server, ip)
+		if err != nil {
+			return model.Geolocation{}, fmt.Errorf("querying %s: %w", server, err)
+		}
+
+		rec := parse(text)
+		if rec.referral != "" && rec.referral != server && i < maxReferrals {
+			server = rec.referral
+			continue
+		}
+
+		reg := rec.registration(server)
+		if reg.IsEmpty() {
+			return model.Geolocation{}, fmt.Errorf("%s has no record of %s", server, ip)
+		}
+		return model.Geolocation{IP: ip, Registration: &reg}, nil
+	}
+}
+
+// query sends a single whois query to server and reads the response.
+func (c *Client) query(ctx context.Context, server string, ip model.IPAddress) (string, error) {
+	conn, err := c.dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// Closing the connection unblocks reads when ctx is cancelled early
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	q := ip.String()
+	if host, _, _ := net.SplitHostPort(server); host == "whois.arin.net" {
+		// Networks only, with the organization and contacts in full
+		q = "n + " + q
+	}
+	if _, err := io.WriteString(conn, q+"\r\n"); err != nil {
+		return "", err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(conn, maxResponseSize+1))
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxResponseSize {
+		return "", fmt.Errorf("response exceeds %d bytes", maxResponseSize)
+	}
+	return string(data), nil
+}
+
+// serverAddr turns a base URL into a host:port address, on port 43 unless
+// another is given.
+func serverAddr(base string) string {
+	base = strings.TrimPrefix(base, "whois://")
+	base = strings.TrimPrefix(base, "rwhois://")
+	base = strings.TrimSuffix(base, "/")
+	if _, _, err := net.SplitHostPort(base); err == nil {
+		return base
+	}
+	return net.JoinHostPort(base, "43")
+}
+
+// abuseComment matches the note RIPE NCC and APNIC add when they filter
+// contact details from the response.
+var abuseComment = regexp.MustCompile(`(?i)^%\s*abuse contact for .* is '([^']+)'`)
+
+// record holds the attributes of a whois response. Keys are kept as
+// written: ARIN spells them in CamelCase, the other registries in lower
+// case with dashes.
+type record struct {
+	values   map[string][]string
+	abuse    string
+	referral string
+}
+
+// parse reads the "key: value" attributes of a whois response, skipping
+// comments.
+func parse(text string) record {
+	rec := record{values: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := abuseComment.FindStringSubmatch(line); m != nil {
+			rec.abuse = m[1]
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" || strings.Contains(key, " ") {
+			continue
+		}
+		rec.values[key] = append(rec.values[key], value)
+	}
+
+	// IANA refers with "refer", ARIN with "ReferralServer"; referrals to
+	// rwhois servers speak another protocol and are not followed
+	for _, key := range []string{"refer", "whois", "ReferralServer"} {
+		if v := rec.first(key); v != "" && !strings.HasPrefix(v, "rwhois://") {
+			rec.referral = serverAddr(v)
+			break
+		}
+	}
+	return rec
+}
+
+// first returns the first value of the first of keys present.
+func (r record) first(keys ...string) string {
+	for _, key := range keys {
+		if values := r.values[key]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// last returns the last value of the first of keys present. ARIN lists
+// the networks containing an address from the least to the most specific.
+func (r record) last(keys ...string) string {
+	for _, key := range keys {
+		if values := r.values[key]; len(values) > 0 {
+			return values[len(values)-1]
+		}
+	}
+	return ""
+}
+
+// registration maps the record returned by server to a Registration.
+func (r record) registration(server string) model.Registration {
+	host, _, _ := net.SplitHostPort(server)
+
+	return model.Registration{
+		Registry: registries[host],
+		Company:  r.first("OrgName", "org-name", "owner", "organisation", "descr"),
+		NetName:  firstNonEmpty(r.last("NetName"), r.first("netname")),
+		Network:  firstNonEmpty(r.last("CIDR"), r.first("inetnum", "inet6num")),
+		Country:  strings.ToUpper(firstNonEmpty(r.last("Country"), r.first("country"))),
+
+		AbuseEmail: firstNonEmpty(r.first("OrgAbuseEmail", "abuse-mailbox"), r.abuse),
+		AbusePhone: r.first("OrgAbusePhone"),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
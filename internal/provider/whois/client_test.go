@@ -0,0 +1,175 @@
+package whois
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/provider/option"
+)
+
+// serve starts a whois server answering each query with respond(query),
+// and returns its address.
+func serve(t *testing.T, respond func(query string) string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				query, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				_, _ = io.WriteString(conn, respond(strings.TrimSpace(query)))
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+const ripeResponse = `% This is the RIPE Database query service.
+% Abuse contact for '193.0.0.0 - 193.0.7.255' is 'abuse@ripe.net'
+
+inetnum:        193.0.0.0 - 193.0.7.255
+netname:        RIPE-NCC
+descr:          RIPE Network Coordination Centre
+org:            ORG-RIEN1-RIPE
+country:        nl
+
+organisation:   ORG-RIEN1-RIPE
+org-name:       Reseaux IP Europeens Network Coordination Centre (RIPE NCC)
+country:        NL
+`
+
+func TestClient_Check_FollowsReferral(t *testing.T) {
+	registry := serve(t, func(query string) string {
+		if query != "193.0.6.139" {
+			t.Errorf("registry query = %q", query)
+		}
+		return ripeResponse
+	})
+	iana := serve(t, func(query string) string {
+		return "% IANA WHOIS server\n\nrefer:        " + registry + "\n\ninetnum:      193.0.0.0 - 193.255.255.255\norganisation: RIPE NCC\n"
+	})
+
+	client := New(option.WithBaseURL("whois://" + iana))
+	geo, err := client.Check(context.Background(), model.MustParseAddr("193.0.6.139"))
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	want := model.Registration{
+		Company:    "Reseaux IP Europeens Network Coordination Centre (RIPE NCC)",
+		Network:    "193.0.0.0 - 193.0.7.255",
+		NetName:    "RIPE-NCC",
+		Country:    "NL",
+		AbuseEmail: "abuse@ripe.net",
+	}
+	if geo.Registration == nil || *geo.Registration != want {
+		t.Errorf("Registration = %+v, want %+v", geo.Registration, want)
+	}
+	if geo.Country != "" || geo.Org != "" {
+		t.Errorf("whois should not vote on the location: %+v", geo)
+	}
+}
+
+func TestParse_ARIN(t *testing.T) {
+	rec := parse(`
+NetRange:       8.0.0.0 - 8.127.255.255
+CIDR:           8.0.0.0/9
+NetName:        LVLT-ORG-8-8
+
+NetRange:       8.8.8.0 - 8.8.8.255
+CIDR:           8.8.8.0/24
+NetName:        GOGL
+
+OrgName:        Google LLC
+Country:        US
+Comment:        Contact details: see below
+
+OrgAbuseEmail:  network-abuse@google.com
+OrgAbusePhone:  +1-650-253-0000
+`)
+
+	got := rec.registration("whois.arin.net:43")
+	want := model.Registration{
+		Registry:   "ARIN",
+		Company:    "Google LLC",
+		Network:    "8.8.8.0/24",
+		NetName:    "GOGL",
+		Country:    "US",
+		AbuseEmail: "network-abuse@google.com",
+		AbusePhone: "+1-650-253-0000",
+	}
+	if got != want {
+		t.Errorf("registration() = %+v, want %+v", got, want)
+	}
+	if rec.referral != "" {
+		t.Errorf("referral = %q, want none", rec.referral)
+	}
+}
+
+func TestParse_Referral(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"refer: whois.ripe.net\n", "whois.ripe.net:43"},
+		{"ReferralServer: whois://whois.apnic.net\n", "whois.apnic.net:43"},
+		{"ReferralServer: rwhois://rwhois.example.net:4321\n", ""},
+		{"netname: EXAMPLE\n", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parse(tt.text).referral; got != tt.want {
+			t.Errorf("parse(%q).referral = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestClient_Check_NoRecord(t *testing.T) {
+	server := serve(t, func(string) string { return "% No entries found\n" })
+
+	client := New(option.WithBaseURL(server))
+	if _, err := client.Check(context.Background(), model.MustParseAddr("192.0.2.1")); err == nil {
+		t.Fatal("Check() expected error for an empty response")
+	}
+}
+
+func TestClient_Check_Timeout(t *testing.T) {
+	server := serve(t, func(string) string {
+		time.Sleep(500 * time.Millisecond)
+		return ripeResponse
+	})
+
+	client := New(option.WithBaseURL(server), option.WithTimeout(50*time.Millisecond))
+	start := time.Now()
+	if _, err := client.Check(context.Background(), model.MustParseAddr("193.0.6.139")); err == nil {
+		t.Fatal("Check() expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Check() took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestClient_Describe(t *testing.T) {
+	d := New().Describe(model.MustParseAddr("8.8.8.8"))
+	if d.URL != "whois://whois.iana.org:43/8.8.8.8" {
+		t.Errorf("Describe().URL = %q", d.URL)
+	}
+}
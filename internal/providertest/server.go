@@ -0,0 +1,339 @@
+// Package providertest provides a local HTTP server emulating the APIs of
+// the supported providers, so that the clients and the ipintel binary can
+// be tested end to end against realistic responses, failures, throttling
+// and slow answers without network access.
+//
+// Whois is not emulated: it is not an HTTP API.
+package providertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"api-client/internal/config"
+	"api-client/internal/model"
+)
+
+// Names of the emulated providers, as in the provider registry.
+const (
+	IPAPI   = "ip-api"
+	IPInfo  = "ipinfo"
+	IPWhois = "ipwhois"
+)
+
+// Providers lists the emulated providers.
+var Providers = []string{IPAPI, IPInfo, IPWhois}
+
+// Mode is how the server answers the requests for a provider.
+type Mode string
+
+const (
+	// ModeSuccess answers with the place of the address
+	ModeSuccess Mode = "success"
+
+	// ModeError answers with the provider's own error response, such as
+	// ip-api's {"status": "fail"}
+	ModeError Mode = "error"
+
+	// ModeThrottle answers 429 Too Many Requests with an exhausted quota
+	ModeThrottle Mode = "throttle"
+
+	// ModeSlow answers successfully after the delay set with WithDelay
+	ModeSlow Mode = "slow"
+)
+
+// DefaultDelay is the delay of ModeSlow unless set with WithDelay.
+const DefaultDelay = 2 * time.Second
+
+// quotaLimit is the number of requests the emulated quotas allow.
+const quotaLimit = 45
+
+// Place is what the server reports for an address.
+type Place struct {
+	Country     string
+	CountryCode string
+	Region      string
+	City        string
+	Latitude    float64
+	Longitude   float64
+	ASN         string
+	Org         string
+	Hostname    string
+}
+
+// DefaultPlaces are the places reported for some well-known addresses.
+var DefaultPlaces = map[model.IPAddress]Place{
+	model.MustParseAddr("8.8.8.8"): {
+		Country: "United States", CountryCode: "US", Region: "California", City: "Mountain View",
+		Latitude: 37.4056, Longitude: -122.0775, ASN: "AS15169", Org: "Google LLC", Hostname: "dns.google",
+	},
+	model.MustParseAddr("1.1.1.1"): {
+		Country: "Australia", CountryCode: "AU", Region: "Queensland", City: "South Brisbane",
+		Latitude: -27.4766, Longitude: 153.0166, ASN: "AS13335", Org: "Cloudflare, Inc.", Hostname: "one.one.one.one",
+	},
+	model.MustParseAddr("2001:4860:4860::8888"): {
+		Country: "United States", CountryCode: "US", Region: "California", City: "Mountain View",
+		Latitude: 37.4056, Longitude: -122.0775, ASN: "AS15169", Org: "Google LLC", Hostname: "dns.google",
+	},
+}
+
+// FallbackPlace is reported for addresses without a place.
+var FallbackPlace = Place{
+	Country: "Netherlands", CountryCode: "NL", Region: "North Holland", City: "Amsterdam",
+	Latitude: 52.3740, Longitude: 4.8897, ASN: "AS64496", Org: "Example Networks B.V.",
+}
+
+// Server is a running fake provider server. It is safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	modes    map[string]Mode
+	delay    time.Duration
+	places   map[model.IPAddress]Place
+	requests map[string]int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMode sets how the server answers the requests for provider.
+func WithMode(provider string, mode Mode) Option {
+	return func(s *Server) {
+		s.modes[provider] = mode
+	}
+}
+
+// WithDelay sets the delay of the providers in ModeSlow.
+func WithDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.delay = d
+	}
+}
+
+// WithPlace sets the place every provider reports for ip.
+func WithPlace(ip model.IPAddress, place Place) Option {
+	return func(s *Server) {
+		s.places[ip] = place
+	}
+}
+
+// NewServer starts a server answering every provider in ModeSuccess unless
+// configured otherwise. Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		modes:    make(map[string]Mode),
+		delay:    DefaultDelay,
+		places:   make(map[model.IPAddress]Place, len(DefaultPlaces)),
+		requests: make(map[string]int),
+	}
+	for ip, place := range DefaultPlaces {
+		s.places[ip] = place
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ip-api/json/{ip}", s.handle(IPAPI, writeIPAPI))
+	mux.HandleFunc("GET /ipinfo/{ip}/json", s.handle(IPInfo, writeIPInfo))
+	mux.HandleFunc("GET /ipwhois/json/{ip}", s.handle(IPWhois, writeIPWhois))
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// Close shuts the server down, waiting for the requests in flight.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// URL returns the root URL of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// BaseURL returns the base URL to configure provider with.
+func (s *Server) BaseURL(provider string) string {
+	if provider == IPInfo {
+		return s.server.URL + "/ipinfo/"
+	}
+	return s.server.URL + "/" + provider + "/json/"
+}
+
+// Config returns a configuration file enabling the emulated providers,
+// pointed at the server.
+func (s *Server) Config() config.File {
+	f := config.File{
+		Providers: append([]string(nil), Providers...),
+		Provider:  make(map[string]config.ProviderFile, len(Providers)),
+	}
+	for _, name := range Providers {
+		f.Provider[name] = config.ProviderFile{BaseURL: s.BaseURL(name)}
+	}
+	return f
+}
+
+// SetMode changes how the server answers the requests for provider.
+func (s *Server) SetMode(provider string, mode Mode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modes[provider] = mode
+}
+
+// Requests returns the number of requests received for provider.
+func (s *Server) Requests(provider string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[provider]
+}
+
+// answer is what a provider handler writes.
+type answer struct {
+	ip    model.IPAddress
+	place Place
+	mode  Mode
+}
+
+// handle returns the handler of provider, which counts the request, applies
+// the mode of the provider and writes the response with write.
+func (s *Server) handle(provider string, write func(http.ResponseWriter, answer)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip, err := model.ParseAddr(r.PathValue("ip"))
+		if err != nil {
+			http.Error(w, "invalid IP address", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		s.requests[provider]++
+		remaining := max(quotaLimit-s.requests[provider], 0)
+		a := answer{ip: ip, mode: s.modes[provider]}
+		place, ok := s.places[ip]
+		if !ok {
+			place = FallbackPlace
+		}
+		a.place = place
+		delay := s.delay
+		s.mu.Unlock()
+
+		switch a.mode {
+		case ModeThrottle:
+			writeQuota(w, provider, 0)
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		case ModeSlow:
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		writeQuota(w, provider, remaining)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		write(w, a)
+	}
+}
+
+// writeQuota sets the rate-limit headers of provider: ip-api's X-Rl and
+// X-Ttl, the common X-RateLimit-* headers for the others.
+func writeQuota(w http.ResponseWriter, provider string, remaining int) {
+	h := w.Header()
+	if provider == IPAPI {
+		h.Set("X-Rl", strconv.Itoa(remaining))
+		h.Set("X-Ttl", "60")
+		return
+	}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(quotaLimit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset", "60")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeIPAPI answers as ip-api.com does, with 200 OK even for errors.
+func writeIPAPI(w http.ResponseWriter, a answer) {
+	if a.mode == ModeError {
+		writeJSON(w, map[string]any{"status": "fail", "message": "reserved range", "query": a.ip.String()})
+		return
+	}
+	p := a.place
+	writeJSON(w, map[string]any{
+		"status":      "success",
+		"country":     p.Country,
+		"countryCode": p.CountryCode,
+		"region":      regionCode(p.Region),
+		"regionName":  p.Region,
+		"city":        p.City,
+		"lat":         p.Latitude,
+		"lon":         p.Longitude,
+		"isp":         p.Org,
+		"org":         p.Org,
+		"as":          p.ASN + " " + p.Org,
+		"reverse":     p.Hostname,
+		"query":       a.ip.String(),
+	})
+}
+
+// writeIPInfo answers as ipinfo.io does.
+func writeIPInfo(w http.ResponseWriter, a answer) {
+	if a.mode == ModeError {
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(w, map[string]any{"error": map[string]string{
+			"title":   "Unknown token",
+			"message": "Please ensure you've entered your token correctly.",
+		}})
+		return
+	}
+	p := a.place
+	writeJSON(w, map[string]any{
+		"ip":       a.ip.String(),
+		"hostname": p.Hostname,
+		"city":     p.City,
+		"region":   p.Region,
+		"country":  p.CountryCode,
+		"loc":      fmt.Sprintf("%.4f,%.4f", p.Latitude, p.Longitude),
+		"org":      p.ASN + " " + p.Org,
+		"timezone": "UTC",
+	})
+}
+
+// writeIPWhois answers as ipwhois.app does, with 200 OK even for errors.
+func writeIPWhois(w http.ResponseWriter, a answer) {
+	if a.mode == ModeError {
+		writeJSON(w, map[string]any{"success": false, "message": "Invalid IP address", "ip": a.ip.String()})
+		return
+	}
+	p := a.place
+	writeJSON(w, map[string]any{
+		"success":      true,
+		"ip":           a.ip.String(),
+		"country":      p.Country,
+		"country_code": p.CountryCode,
+		"region":       p.Region,
+		"city":         p.City,
+		"latitude":     p.Latitude,
+		"longitude":    p.Longitude,
+		"isp":          p.Org,
+		"org":          p.Org,
+		"asn":          p.ASN,
+	})
+}
+
+// regionCode abbreviates a region name, as the region field of ip-api does.
+func regionCode(region string) string {
+	var code strings.Builder
+	for _, word := range strings.Fields(region) {
+		code.WriteString(strings.ToUpper(word[:1]))
+	}
+	return code.String()
+}
@@ -0,0 +1,150 @@
+package providertest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/config"
+	"api-client/internal/model"
+	"api-client/internal/provider"
+	"api-client/internal/provider/option"
+	"api-client/internal/provider/registry"
+	"api-client/internal/providertest"
+)
+
+// newClient returns the real client of name, pointed at s.
+func newClient(t *testing.T, s *providertest.Server, name string, opts ...option.Option) provider.Provider {
+	t.Helper()
+	opts = append([]option.Option{option.WithRequester(http.DefaultClient), option.WithBaseURL(s.BaseURL(name))}, opts...)
+	p, err := registry.New(name, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestServer_Success(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	ip := model.MustParseAddr("8.8.8.8")
+	want := providertest.DefaultPlaces[ip]
+	for _, name := range providertest.Providers {
+		t.Run(name, func(t *testing.T) {
+			var quota *model.Quota
+			geo, err := newClient(t, s, name).Check(provider.WithQuotaRecorder(context.Background(), &quota), ip)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if geo.CountryCode != want.CountryCode || geo.City != want.City || !strings.HasPrefix(geo.ASN, want.ASN) {
+				t.Errorf("Check() = %+v, want %+v", geo, want)
+			}
+			if lat, lon, ok := geo.Coordinates(); !ok || lat != want.Latitude || lon != want.Longitude {
+				t.Errorf("coordinates = %v, %v, want %v, %v", lat, lon, want.Latitude, want.Longitude)
+			}
+			if quota == nil || quota.Remaining <= 0 {
+				t.Errorf("quota = %+v, want some requests left", quota)
+			}
+			if s.Requests(name) != 1 {
+				t.Errorf("Requests() = %d, want 1", s.Requests(name))
+			}
+		})
+	}
+}
+
+func TestServer_FallbackPlace(t *testing.T) {
+	ip := model.MustParseAddr("192.0.2.1")
+	s := providertest.NewServer(providertest.WithPlace(ip, providertest.Place{CountryCode: "FR", City: "Paris"}))
+	defer s.Close()
+
+	geo, err := newClient(t, s, providertest.IPWhois).Check(context.Background(), ip)
+	if err != nil || geo.City != "Paris" {
+		t.Errorf("Check() = %+v, %v, want Paris", geo, err)
+	}
+	geo, err = newClient(t, s, providertest.IPWhois).Check(context.Background(), model.MustParseAddr("192.0.2.2"))
+	if err != nil || geo.City != providertest.FallbackPlace.City {
+		t.Errorf("Check() = %+v, %v, want the fallback place", geo, err)
+	}
+}
+
+func TestServer_Error(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	for _, name := range providertest.Providers {
+		s.SetMode(name, providertest.ModeError)
+		if _, err := newClient(t, s, name).Check(context.Background(), model.MustParseAddr("8.8.8.8")); err == nil {
+			t.Errorf("%s: Check() should fail", name)
+		}
+	}
+}
+
+func TestServer_Throttle(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	for _, name := range providertest.Providers {
+		s.SetMode(name, providertest.ModeThrottle)
+
+		var quota *model.Quota
+		_, err := newClient(t, s, name).Check(provider.WithQuotaRecorder(context.Background(), &quota), model.MustParseAddr("8.8.8.8"))
+
+		var serr provider.StatusError
+		if !errors.As(err, &serr) || serr.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("%s: Check() error = %v, want 429", name, err)
+		}
+		if quota == nil || quota.Remaining != 0 {
+			t.Errorf("%s: quota = %+v, want exhausted", name, quota)
+		}
+	}
+}
+
+func TestServer_Slow(t *testing.T) {
+	s := providertest.NewServer(
+		providertest.WithMode(providertest.IPInfo, providertest.ModeSlow),
+		providertest.WithDelay(time.Second),
+	)
+	defer s.Close()
+
+	start := time.Now()
+	_, err := newClient(t, s, providertest.IPInfo, option.WithTimeout(50*time.Millisecond)).
+		Check(context.Background(), model.MustParseAddr("8.8.8.8"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Check() took %s, want it cut short by the timeout", elapsed)
+	}
+}
+
+func TestServer_Config(t *testing.T) {
+	s := providertest.NewServer()
+	defer s.Close()
+
+	data, err := json.Marshal(s.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(config.Options{Path: path, Getenv: func(string) string { return "" }})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Providers.Value) != len(providertest.Providers) {
+		t.Errorf("Providers = %v, want %v", cfg.Providers.Value, providertest.Providers)
+	}
+	if got := cfg.Provider[providertest.IPAPI].BaseURL.Value; got != s.BaseURL(providertest.IPAPI) {
+		t.Errorf("ip-api base_url = %q, want %q", got, s.BaseURL(providertest.IPAPI))
+	}
+}
@@ -0,0 +1,586 @@
+package publish
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Kafka requests, in the oldest versions with what is needed, so that
+// brokers from 1.0 on are supported: Produce v3, the first with record
+// batches, and Metadata v4, the first letting the broker create the topic.
+const (
+	kafkaProduce         = 0
+	kafkaProduceVersion  = 3
+	kafkaMetadata        = 3
+	kafkaMetadataVersion = 4
+
+	kafkaClientID = "ipintel"
+)
+
+const (
+	// kafkaBatchMessages and kafkaBatchBytes bound the messages buffered
+	// before they are produced, whatever their age
+	kafkaBatchMessages = 500
+	kafkaBatchBytes    = 512 << 10
+
+	// kafkaAttempts is how many times messages are produced in all when
+	// their broker fails transiently, refreshing the metadata in between
+	kafkaAttempts = 3
+	kafkaBackoff  = 500 * time.Millisecond
+)
+
+// kafkaError is an error code of the Kafka protocol.
+type kafkaError int16
+
+// kafkaErrors names the error codes a producer may be answered with.
+var kafkaErrors = map[kafkaError]string{
+	2:  "corrupt message",
+	3:  "unknown topic or partition",
+	5:  "leader not available",
+	6:  "not leader for partition",
+	7:  "request timed out",
+	10: "message too large",
+	17: "invalid topic",
+	18: "record list too large",
+	19: "not enough replicas",
+	20: "not enough replicas after append",
+	29: "topic authorization failed",
+	31: "cluster authorization failed",
+	87: "invalid record",
+}
+
+func (e kafkaError) Error() string {
+	if name, ok := kafkaErrors[e]; ok {
+		return name
+	}
+	return "error code " + strconv.Itoa(int(e))
+}
+
+// transient reports whether err may not happen again: a network error, or
+// an error of the broker that a metadata refresh or a retry can resolve.
+func transient(err error) bool {
+	var kerr kafkaError
+	if errors.As(err, &kerr) {
+		switch kerr {
+		case 5, 6, 7, 19, 20:
+			return true
+		}
+		return false
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// kafkaPublisher produces to the partitions of a Kafka topic, choosing
+// them by key as the Java client does, so that the messages of a key land
+// in the same partition whichever client produced them. Messages are
+// produced in batches, one request per leader, acknowledged by all
+// in-sync replicas.
+type kafkaPublisher struct {
+	topic     string
+	bootstrap []string
+	backoff   time.Duration
+
+	brokers map[int32]string
+	conns   map[int32]*kafkaConn
+	leaders []int32
+
+	batches map[int32][]kafkaMessage
+	pending int
+	size    int
+	first   time.Time
+	err     error
+}
+
+type kafkaMessage struct {
+	key, value []byte
+	time       time.Time
+}
+
+func newKafka(bootstrap []string, topic string) (*kafkaPublisher, error) {
+	p := &kafkaPublisher{
+		topic:     topic,
+		bootstrap: bootstrap,
+		backoff:   kafkaBackoff,
+		conns:     make(map[int32]*kafkaConn),
+		batches:   make(map[int32][]kafkaMessage),
+	}
+	if err := p.refresh(); err != nil {
+		return nil, fmt.Errorf("kafka: %w", err)
+	}
+	return p, nil
+}
+
+func (p *kafkaPublisher) Publish(key, value []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	if p.pending == 0 {
+		p.first = time.Now()
+	}
+	partition := p.partition(key)
+	p.batches[partition] = append(p.batches[partition], kafkaMessage{key: key, value: value, time: time.Now()})
+	p.pending++
+	p.size += len(key) + len(value)
+
+	if p.pending >= kafkaBatchMessages || p.size >= kafkaBatchBytes || time.Since(p.first) >= linger {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *kafkaPublisher) Close() error {
+	err := p.err
+	if err == nil {
+		err = p.flush()
+	}
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+	return err
+}
+
+// partition returns the partition of key: its murmur2 hash, made positive,
+// modulo the number of partitions.
+func (p *kafkaPublisher) partition(key []byte) int32 {
+	return int32(murmur2(key)&0x7fffffff) % int32(len(p.leaders))
+}
+
+// murmur2 is the hash the Java client partitions keys by.
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	h := uint32(seed) ^ uint32(len(data))
+	n := len(data) &^ 3
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(data) & 3 {
+	case 3:
+		h ^= uint32(data[n+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[n+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[n])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+// flush produces the buffered messages, retrying those failing
+// transiently after refreshing the metadata. An error is final: later
+// messages are not produced.
+func (p *kafkaPublisher) flush() error {
+	batches := p.batches
+	p.batches, p.pending, p.size = make(map[int32][]kafkaMessage), 0, 0
+
+	for attempt := 1; len(batches) > 0; attempt++ {
+		failed, err := p.produce(batches)
+		if err == nil {
+			return nil
+		}
+		if attempt == kafkaAttempts || !transient(err) {
+			p.err = fmt.Errorf("kafka: %w", err)
+			return p.err
+		}
+
+		time.Sleep(p.backoff)
+		if err := p.refresh(); err != nil {
+			p.err = fmt.Errorf("kafka: %w", err)
+			return p.err
+		}
+		batches = failed
+	}
+	return nil
+}
+
+// produce sends batches, by partition, to the leaders of their partitions,
+// and returns those that failed, with the first error; a failure that is
+// not transient is returned at once.
+func (p *kafkaPublisher) produce(batches map[int32][]kafkaMessage) (map[int32][]kafkaMessage, error) {
+	byLeader := make(map[int32][]int32)
+	for partition := range batches {
+		leader := p.leaders[partition]
+		byLeader[leader] = append(byLeader[leader], partition)
+	}
+
+	failed := make(map[int32][]kafkaMessage)
+	var firstErr error
+	fail := func(partitions []int32, err error) {
+		for _, partition := range partitions {
+			failed[partition] = batches[partition]
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for leader, partitions := range byLeader {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+		errs, err := p.produceTo(leader, partitions, batches)
+		if err != nil {
+			if !transient(err) {
+				return nil, err
+			}
+			fail(partitions, err)
+			continue
+		}
+		for partition, err := range errs {
+			if !transient(err) {
+				return nil, fmt.Errorf("partition %d: %w", partition, err)
+			}
+			fail([]int32{partition}, fmt.Errorf("partition %d: %w", partition, err))
+		}
+	}
+	return failed, firstErr
+}
+
+// produceTo sends the batches of partitions to their leader, and returns
+// the errors of the partitions the broker rejected.
+func (p *kafkaPublisher) produceTo(leader int32, partitions []int32, batches map[int32][]kafkaMessage) (map[int32]error, error) {
+	conn, err := p.conn(leader)
+	if err != nil {
+		return nil, err
+	}
+
+	body := appendInt16(nil, -1) // no transactional id
+	body = appendInt16(body, -1) // acks from all in-sync replicas
+	body = appendInt32(body, int32(requestTimeout/time.Millisecond))
+	body = appendInt32(body, 1)
+	body = appendString(body, p.topic)
+	body = appendInt32(body, int32(len(partitions)))
+	for _, partition := range partitions {
+		batch := recordBatch(batches[partition])
+		body = appendInt32(body, partition)
+		body = appendInt32(body, int32(len(batch)))
+		body = append(body, batch...)
+	}
+
+	resp, err := conn.roundTrip(kafkaProduce, kafkaProduceVersion, body)
+	if err != nil {
+		p.drop(leader)
+		return nil, err
+	}
+
+	errs := make(map[int32]error)
+	d := &kafkaDecoder{data: resp}
+	for range d.arrayLen() {
+		d.string()
+		for range d.arrayLen() {
+			partition := d.int32()
+			code := kafkaError(d.int16())
+			d.int64() // base offset
+			d.int64() // log append time
+			if code != 0 {
+				errs[partition] = code
+			}
+		}
+	}
+	return errs, d.err
+}
+
+// recordBatch encodes messages as a record batch, of magic 2, without
+// compression.
+func recordBatch(messages []kafkaMessage) []byte {
+	first := messages[0].time.UnixMilli()
+	last := first
+
+	var records []byte
+	for i, msg := range messages {
+		ts := msg.time.UnixMilli()
+		last = max(last, ts)
+
+		record := []byte{0} // attributes
+		record = binary.AppendVarint(record, ts-first)
+		record = binary.AppendVarint(record, int64(i))
+		record = binary.AppendVarint(record, int64(len(msg.key)))
+		record = append(record, msg.key...)
+		record = binary.AppendVarint(record, int64(len(msg.value)))
+		record = append(record, msg.value...)
+		record = binary.AppendVarint(record, 0) // headers
+
+		records = binary.AppendVarint(records, int64(len(record)))
+		records = append(records, record...)
+	}
+
+	// The part of the batch covered by its CRC
+	crcd := appendInt16(nil, 0) // attributes
+	crcd = appendInt32(crcd, int32(len(messages)-1))
+	crcd = appendInt64(crcd, first)
+	crcd = appendInt64(crcd, last)
+	crcd = appendInt64(crcd, -1) // producer id
+	crcd = appendInt16(crcd, -1) // producer epoch
+	crcd = appendInt32(crcd, -1) // base sequence
+	crcd = appendInt32(crcd, int32(len(messages)))
+	crcd = append(crcd, records...)
+
+	batch := appendInt64(nil, 0)                       // base offset
+	batch = appendInt32(batch, int32(4+1+4+len(crcd))) // length after this field
+	batch = appendInt32(batch, -1)                     // partition leader epoch
+	batch = append(batch, 2)                           // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(crcd, crc32.MakeTable(crc32.Castagnoli)))
+	return append(batch, crcd...)
+}
+
+// refresh fetches the brokers of the cluster and the leaders of the
+// partitions of the topic from the first bootstrap broker that answers,
+// waiting for the leaders of a topic being created to be elected.
+func (p *kafkaPublisher) refresh() error {
+	var err error
+	for attempt := 1; attempt <= kafkaAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(p.backoff)
+		}
+		for _, address := range p.bootstrap {
+			if err = p.metadata(address); err == nil {
+				return nil
+			}
+			if !transient(err) {
+				return err
+			}
+		}
+	}
+	return err
+}
+
+func (p *kafkaPublisher) metadata(address string) error {
+	conn, err := dialKafka(address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	body := appendInt32(nil, 1)
+	body = appendString(body, p.topic)
+	body = append(body, 1) // allow the topic to be created
+	resp, err := conn.roundTrip(kafkaMetadata, kafkaMetadataVersion, body)
+	if err != nil {
+		return err
+	}
+
+	d := &kafkaDecoder{data: resp}
+	d.int32() // throttle time
+	brokers := make(map[int32]string)
+	for range d.arrayLen() {
+		node := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[node] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster id
+	d.int32()  // controller id
+
+	var leaders []int32
+	var topicErr error
+	for range d.arrayLen() {
+		code := kafkaError(d.int16())
+		name := d.string()
+		d.int8() // internal
+		for range d.arrayLen() {
+			partitionCode := kafkaError(d.int16())
+			partition := d.int32()
+			leader := d.int32()
+			d.int32Array() // replicas
+			d.int32Array() // in-sync replicas
+			if name != p.topic || partition < 0 {
+				continue
+			}
+			if int(partition) >= len(leaders) {
+				leaders = append(leaders, make([]int32, int(partition)+1-len(leaders))...)
+			}
+			leaders[partition] = leader
+			if leader < 0 && topicErr == nil {
+				topicErr = kafkaError(5)
+				if partitionCode != 0 {
+					topicErr = partitionCode
+				}
+			}
+		}
+		if name == p.topic && code != 0 {
+			topicErr = code
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if topicErr != nil {
+		return fmt.Errorf("topic %s: %w", p.topic, topicErr)
+	}
+	if len(leaders) == 0 {
+		return fmt.Errorf("topic %s: %w", p.topic, kafkaError(5))
+	}
+
+	p.brokers, p.leaders = brokers, leaders
+	return nil
+}
+
+// conn returns the connection to broker node, connecting if needed.
+func (p *kafkaPublisher) conn(node int32) (*kafkaConn, error) {
+	if conn, ok := p.conns[node]; ok {
+		return conn, nil
+	}
+	address, ok := p.brokers[node]
+	if !ok {
+		return nil, fmt.Errorf("broker %d: %w", node, kafkaError(5))
+	}
+	conn, err := dialKafka(address)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[node] = conn
+	return conn, nil
+}
+
+// drop closes the connection to broker node, after an error, so that the
+// next request reconnects.
+func (p *kafkaPublisher) drop(node int32) {
+	if conn, ok := p.conns[node]; ok {
+		_ = conn.Close()
+		delete(p.conns, node)
+	}
+}
+
+// kafkaConn is a connection to a broker, on which requests are sent one
+// at a time.
+type kafkaConn struct {
+	net.Conn
+	r           *bufio.Reader
+	correlation int32
+}
+
+func dialKafka(address string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// roundTrip sends a request with body and returns the body of the
+// response.
+func (c *kafkaConn) roundTrip(apiKey, version int16, body []byte) ([]byte, error) {
+	c.correlation++
+	header := appendInt16(nil, apiKey)
+	header = appendInt16(header, version)
+	header = appendInt32(header, c.correlation)
+	header = appendString(header, kafkaClientID)
+
+	req := appendInt32(nil, int32(len(header)+len(body)))
+	req = append(append(req, header...), body...)
+
+	_ = c.SetDeadline(time.Now().Add(requestTimeout))
+	if _, err := c.Write(req); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != c.correlation {
+		return nil, errors.New("response out of sequence")
+	}
+	return resp[4:], nil
+}
+
+func appendInt16(b []byte, v int16) []byte {
+	return binary.BigEndian.AppendUint16(b, uint16(v))
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return binary.BigEndian.AppendUint32(b, uint32(v))
+}
+
+func appendInt64(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendInt16(b, int16(len(s))), s...)
+}
+
+// kafkaDecoder decodes a response, recording the first field that did not
+// fit in the data, after which every field is zero.
+type kafkaDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.data) {
+		if d.err == nil {
+			d.err = errors.New("truncated response")
+		}
+		return make([]byte, max(n, 0))
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	return int8(d.take(1)[0])
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	return int16(binary.BigEndian.Uint16(d.take(2)))
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.take(4)))
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.take(8)))
+}
+
+// string decodes a string, or a null one as empty.
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+// arrayLen decodes the length of an array, 0 for a null one.
+func (d *kafkaDecoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *kafkaDecoder) int32Array() {
+	for range d.arrayLen() {
+		d.int32()
+	}
+}
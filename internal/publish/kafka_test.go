@@ -0,0 +1,301 @@
+package publish
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// The values of the Java client for the same keys.
+func TestMurmur2(t *testing.T) {
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, want := range tests {
+		if got := int32(murmur2([]byte(key))); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+// fakeKafka is a broker leading every partition of its topics, which
+// records the messages produced to it.
+type fakeKafka struct {
+	t          *testing.T
+	listener   net.Listener
+	partitions int
+
+	mu       sync.Mutex
+	messages map[int32][]string // "key=value" by partition
+	errors   []int16            // error codes of the next produce requests
+	produces int
+}
+
+func newFakeKafka(t *testing.T, partitions int) *fakeKafka {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &fakeKafka{t: t, listener: l, partitions: partitions, messages: make(map[int32][]string)}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go k.serve(conn)
+		}
+	}()
+	return k
+}
+
+func (k *fakeKafka) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		req := make([]byte, size)
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+
+		d := &kafkaDecoder{data: req}
+		apiKey, version, correlation := d.int16(), d.int16(), d.int32()
+		d.string() // client id
+
+		var resp []byte
+		switch {
+		case apiKey == kafkaMetadata && version == kafkaMetadataVersion:
+			resp = k.metadata(d)
+		case apiKey == kafkaProduce && version == kafkaProduceVersion:
+			resp = k.produce(d)
+		default:
+			k.t.Errorf("unexpected request %d v%d", apiKey, version)
+			return
+		}
+
+		frame := appendInt32(nil, int32(4+len(resp)))
+		frame = appendInt32(frame, correlation)
+		if _, err := conn.Write(append(frame, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func (k *fakeKafka) metadata(d *kafkaDecoder) []byte {
+	var topics []string
+	for range d.arrayLen() {
+		topics = append(topics, d.string())
+	}
+
+	host, port, _ := net.SplitHostPort(k.listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	resp := appendInt32(nil, 0) // throttle time
+	resp = appendInt32(resp, 1)
+	resp = appendInt32(resp, 0)
+	resp = appendString(resp, host)
+	resp = appendInt32(resp, int32(portNum))
+	resp = appendInt16(resp, -1) // rack
+	resp = appendInt16(resp, -1) // cluster id
+	resp = appendInt32(resp, 0)  // controller id
+	resp = appendInt32(resp, int32(len(topics)))
+	for _, topic := range topics {
+		resp = appendInt16(resp, 0)
+		resp = appendString(resp, topic)
+		resp = append(resp, 0)
+		resp = appendInt32(resp, int32(k.partitions))
+		for i := range k.partitions {
+			resp = appendInt16(resp, 0)
+			resp = appendInt32(resp, int32(i))
+			resp = appendInt32(resp, 0)                 // leader
+			resp = appendInt32(appendInt32(resp, 1), 0) // replicas
+			resp = appendInt32(appendInt32(resp, 1), 0) // in-sync replicas
+		}
+	}
+	return resp
+}
+
+func (k *fakeKafka) produce(d *kafkaDecoder) []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.produces++
+	var code int16
+	if len(k.errors) > 0 {
+		code, k.errors = k.errors[0], k.errors[1:]
+	}
+
+	d.string() // transactional id
+	if acks := d.int16(); acks != -1 {
+		k.t.Errorf("acks = %d, want -1", acks)
+	}
+	d.int32() // timeout
+
+	resp := appendInt32(nil, int32(d.arrayLen()))
+	topic := d.string()
+	resp = appendString(resp, topic)
+	n := d.arrayLen()
+	resp = appendInt32(resp, int32(n))
+	for range n {
+		partition := d.int32()
+		batch := d.take(int(d.int32()))
+		if code == 0 {
+			k.messages[partition] = append(k.messages[partition], k.records(batch)...)
+		}
+		resp = appendInt32(resp, partition)
+		resp = appendInt16(resp, code)
+		resp = appendInt64(resp, 0)
+		resp = appendInt64(resp, -1)
+	}
+	return appendInt32(resp, 0)
+}
+
+// records decodes a record batch, checking its CRC.
+func (k *fakeKafka) records(batch []byte) []string {
+	d := &kafkaDecoder{data: batch}
+	d.int64() // base offset
+	if length := d.int32(); int(length) != len(d.data) {
+		k.t.Errorf("batch length = %d, want %d", length, len(d.data))
+	}
+	d.int32() // partition leader epoch
+	if magic := d.int8(); magic != 2 {
+		k.t.Errorf("magic = %d, want 2", magic)
+	}
+	if crc := uint32(d.int32()); crc != crc32.Checksum(d.data, crc32.MakeTable(crc32.Castagnoli)) {
+		k.t.Error("invalid batch CRC")
+	}
+	d.int16() // attributes
+	lastOffsetDelta := d.int32()
+	d.take(8 + 8 + 8 + 2 + 4)
+	count := d.int32()
+	if lastOffsetDelta != count-1 {
+		k.t.Errorf("last offset delta = %d for %d records", lastOffsetDelta, count)
+	}
+
+	var records []string
+	for i := range count {
+		r := &varintReader{data: d.data}
+		length := r.varint()
+		start := len(r.data)
+		r.data = r.data[1:] // attributes
+		r.varint()          // timestamp delta
+		if delta := r.varint(); delta != int64(i) {
+			k.t.Errorf("offset delta = %d, want %d", delta, i)
+		}
+		key := r.bytes()
+		value := r.bytes()
+		r.varint() // headers
+		if consumed := start - len(r.data); int64(consumed) != length {
+			k.t.Errorf("record length = %d, read %d", length, consumed)
+		}
+		d.data = r.data
+		records = append(records, fmt.Sprintf("%s=%s", key, value))
+	}
+	return records
+}
+
+type varintReader struct {
+	data []byte
+}
+
+func (r *varintReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *varintReader) bytes() []byte {
+	n := r.varint()
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func TestKafka_Publish(t *testing.T) {
+	k := newFakeKafka(t, 3)
+
+	p, err := New("kafka://" + k.listener.Addr().String() + "/reports")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	keys := []string{"8.8.8.8", "1.1.1.1", "9.9.9.9", "8.8.8.8"}
+	for i, key := range keys {
+		if err := p.Publish([]byte(key), []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.produces != 1 {
+		t.Errorf("%d produce requests, want one for all the messages", k.produces)
+	}
+	want := make(map[int32][]string)
+	for i, key := range keys {
+		partition := int32(murmur2([]byte(key))&0x7fffffff) % 3
+		want[partition] = append(want[partition], fmt.Sprintf("%s=%d", key, i))
+	}
+	if !reflect.DeepEqual(k.messages, want) {
+		t.Errorf("messages by partition = %q, want %q", k.messages, want)
+	}
+}
+
+func TestKafka_Publish_Retry(t *testing.T) {
+	k := newFakeKafka(t, 1)
+	k.errors = []int16{6} // not leader for partition
+
+	p, err := newKafka([]string{k.listener.Addr().String()}, "reports")
+	if err != nil {
+		t.Fatalf("newKafka() error = %v", err)
+	}
+	p.backoff = 0
+	if err := p.Publish([]byte("8.8.8.8"), []byte("{}")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.produces != 2 || len(k.messages[0]) != 1 {
+		t.Errorf("%d requests, messages %q; want the message produced on the second", k.produces, k.messages)
+	}
+}
+
+func TestKafka_Publish_Error(t *testing.T) {
+	k := newFakeKafka(t, 1)
+	k.errors = []int16{10} // message too large
+
+	p, err := newKafka([]string{k.listener.Addr().String()}, "reports")
+	if err != nil {
+		t.Fatalf("newKafka() error = %v", err)
+	}
+	_ = p.Publish([]byte("8.8.8.8"), []byte("{}"))
+	err = p.Close()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err == nil || k.produces != 1 {
+		t.Errorf("Close() error = %v after %d requests, want message too large at once", err, k.produces)
+	}
+}
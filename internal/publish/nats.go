@@ -0,0 +1,212 @@
+package publish
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsBufferSize is the size of the messages buffered before they are sent
+// to the NATS server, whatever their age.
+const natsBufferSize = 64 << 10
+
+// natsPublisher publishes to a subject of a NATS server, in its text
+// protocol. The server does not acknowledge messages, so Close waits for
+// the answer to a PING sent after the last one, which the server sends
+// once it has processed them.
+type natsPublisher struct {
+	subject string
+	conn    net.Conn
+
+	// mu guards w and err, also used by the goroutine reading the server
+	mu    sync.Mutex
+	w     *bufio.Writer
+	err   error
+	first time.Time
+
+	pongs chan struct{}
+	done  chan struct{}
+}
+
+// natsInfo is the part of the INFO message of a server that matters.
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+func newNATS(address string, user *url.Userinfo, subject string) (*natsPublisher, error) {
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("invalid NATS subject %q", subject)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	p := &natsPublisher{
+		subject: subject,
+		conn:    conn,
+		w:       bufio.NewWriterSize(conn, natsBufferSize),
+		pongs:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := p.connect(user); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("nats: %s: %w", address, err)
+	}
+	return p, nil
+}
+
+// connect reads the INFO of the server, authenticates and checks that the
+// server accepted the connection with a PING, then starts reading the
+// server in the background.
+func (p *natsPublisher) connect(user *url.Userinfo) error {
+	_ = p.conn.SetDeadline(time.Now().Add(requestTimeout))
+	r := bufio.NewReader(p.conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	op, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if op != "INFO" {
+		return fmt.Errorf("unexpected %q instead of INFO", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(args), &info); err != nil {
+		return fmt.Errorf("invalid INFO: %w", err)
+	}
+	if info.TLSRequired {
+		return errors.New("the server requires TLS, which is not supported")
+	}
+
+	options := map[string]any{"verbose": false, "pedantic": false, "lang": "go", "version": "1", "name": "ipintel"}
+	if user != nil {
+		if pass, ok := user.Password(); ok {
+			options["user"], options["pass"] = user.Username(), pass
+		} else {
+			options["auth_token"] = user.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			_ = p.conn.SetDeadline(time.Time{})
+			go p.read(r)
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// read answers the PINGs of the server, so that it does not drop the
+// connection as stale, and records its errors, until the connection is
+// closed.
+func (p *natsPublisher) read(r *bufio.Reader) {
+	defer close(p.done)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			p.fail(err)
+			return
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PING":
+			p.mu.Lock()
+			_, _ = p.w.WriteString("PONG\r\n")
+			_ = p.w.Flush()
+			p.mu.Unlock()
+		case line == "PONG":
+			select {
+			case p.pongs <- struct{}{}:
+			default:
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			p.fail(errors.New(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'")))
+		}
+	}
+}
+
+// fail records the first error of the connection.
+func (p *natsPublisher) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = fmt.Errorf("nats: %w", err)
+	}
+}
+
+func (p *natsPublisher) Publish(_, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+
+	if p.w.Buffered() == 0 {
+		p.first = time.Now()
+	}
+	_, _ = fmt.Fprintf(p.w, "PUB %s %d\r\n", p.subject, len(value))
+	_, _ = p.w.Write(value)
+	_, _ = p.w.WriteString("\r\n")
+
+	if time.Since(p.first) >= linger {
+		if err := p.w.Flush(); err != nil {
+			p.err = fmt.Errorf("nats: %w", err)
+		}
+	}
+	return p.err
+}
+
+func (p *natsPublisher) Close() error {
+	p.mu.Lock()
+	err := p.err
+	if err == nil {
+		_, _ = p.w.WriteString("PING\r\n")
+		if err = p.w.Flush(); err != nil {
+			err = fmt.Errorf("nats: %w", err)
+		}
+	}
+	p.mu.Unlock()
+
+	if err == nil {
+		select {
+		case <-p.pongs:
+		case <-p.done:
+		case <-time.After(requestTimeout):
+			err = errors.New("nats: no answer from the server")
+		}
+	}
+
+	_ = p.conn.Close()
+	<-p.done
+
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// An error of the server on the last messages precedes the PONG
+	if p.err != nil && !errors.Is(p.err, net.ErrClosed) {
+		return p.err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package publish
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeNATS is a NATS server recording the CONNECT options and the messages
+// published to it, which may reject them with reject.
+type fakeNATS struct {
+	listener net.Listener
+	reject   string
+
+	mu       sync.Mutex
+	connect  string
+	messages []string // "subject payload"
+}
+
+func newFakeNATS(t *testing.T, info string) *fakeNATS {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{listener: l}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, info)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATS) serve(conn net.Conn, info string) {
+	defer func() { _ = conn.Close() }()
+	_, _ = fmt.Fprintf(conn, "INFO %s\r\n", info)
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		op, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch op {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connect = args
+			s.mu.Unlock()
+			// The server checks the liveness of its clients
+			_, _ = io.WriteString(conn, "PING\r\n")
+		case "PING":
+			_, _ = io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			subject, size, _ := strings.Cut(args, " ")
+			n, _ := strconv.Atoi(size)
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if s.reject != "" {
+				_, _ = fmt.Fprintf(conn, "-ERR '%s'\r\n", s.reject)
+				continue
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, subject+" "+string(payload[:n]))
+			s.mu.Unlock()
+		}
+	}
+}
+
+func TestNATS_Publish(t *testing.T) {
+	s := newFakeNATS(t, `{"server_id":"test","max_payload":1048576}`)
+
+	p, err := New("nats://secret@" + s.listener.Addr().String() + "/ipintel.reports")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, value := range []string{`{"ip":"8.8.8.8"}`, `{"ip":"1.1.1.1"}`} {
+		if err := p.Publish([]byte("ignored"), []byte(value)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{`ipintel.reports {"ip":"8.8.8.8"}`, `ipintel.reports {"ip":"1.1.1.1"}`}
+	if strings.Join(s.messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("messages = %q, want %q", s.messages, want)
+	}
+	if !strings.Contains(s.connect, `"auth_token":"secret"`) {
+		t.Errorf("CONNECT %s, want the token", s.connect)
+	}
+}
+
+func TestNATS_Publish_Rejected(t *testing.T) {
+	s := newFakeNATS(t, `{"server_id":"test"}`)
+	s.reject = "Permissions Violation for Publish to ipintel.reports"
+
+	p, err := New("nats://" + s.listener.Addr().String() + "/ipintel.reports")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_ = p.Publish(nil, []byte("{}"))
+	if err := p.Close(); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("Close() error = %v, want the error of the server", err)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	s := newFakeNATS(t, `{"tls_required":true}`)
+	for _, target := range []string{
+		"localhost:9092",
+		"kafka://localhost:9092",
+		"nats:///subject",
+		"amqp://localhost/queue",
+		"udp://",
+		"tls://localhost:6514?facility=nope",
+		"nats://" + s.listener.Addr().String() + "/subject",
+	} {
+		if _, err := New(target); err == nil {
+			t.Errorf("New(%q) expected error", target)
+		}
+	}
+}
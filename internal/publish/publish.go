@@ -0,0 +1,83 @@
+// Package publish sends the reports of a run, as they complete, as
+// messages to a Kafka topic, a NATS subject or a syslog server, so that
+// ipintel can feed streaming enrichment pipelines and syslog collectors.
+// The Kafka and NATS clients implement just what publishing needs of each
+// protocol, without TLS or SASL.
+package publish
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// dialTimeout bounds connecting to a broker or server
+	dialTimeout = 10 * time.Second
+
+	// requestTimeout bounds each exchange with a broker or server
+	requestTimeout = 30 * time.Second
+
+	// linger is how long a message may be buffered, waiting for others to
+	// be sent with, before it is sent on the next publish
+	linger = 100 * time.Millisecond
+)
+
+// Publisher sends messages, in order, buffering them to send several at a
+// time. A message is only known to be delivered once Close succeeds.
+type Publisher interface {
+	// Publish sends a message with key, which Kafka partitions by, and
+	// value
+	Publish(key, value []byte) error
+
+	// Close sends the buffered messages, waiting for them to be
+	// acknowledged, and closes the connections.
+	Close() error
+}
+
+// New connects to target: kafka://host:port[,host:port...]/topic, the
+// bootstrap brokers of a Kafka cluster, 9092 by default,
+// nats://[user:password@]host:port/subject, a NATS server, 4222 by
+// default, which a user without password authenticates to as a token, or
+// udp://, tcp:// or tls://host:port, a syslog server as syslog.Dial takes.
+func New(target string) (Publisher, error) {
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok {
+		return nil, fmt.Errorf("%q: expected a kafka://, nats://, udp://, tcp:// or tls:// URL", target)
+	}
+	switch scheme {
+	case "udp", "tcp", "tls":
+		return newSyslog(target)
+	}
+	hosts, name, _ := strings.Cut(rest, "/")
+	if hosts == "" || name == "" {
+		return nil, fmt.Errorf("%q: expected kafka://host/topic or nats://host/subject", target)
+	}
+
+	switch scheme {
+	case "kafka":
+		var brokers []string
+		for _, host := range strings.Split(hosts, ",") {
+			brokers = append(brokers, withPort(host, "9092"))
+		}
+		return newKafka(brokers, name)
+	case "nats":
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+		return newNATS(withPort(u.Host, "4222"), u.User, name)
+	default:
+		return nil, fmt.Errorf("%q: unsupported scheme %q; expected kafka, nats, udp, tcp or tls", target, scheme)
+	}
+}
+
+// withPort returns host with port appended unless it has one.
+func withPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
@@ -0,0 +1,28 @@
+package publish
+
+import "api-client/internal/syslog"
+
+// syslogPublisher sends each message as an informational syslog message of
+// ID "report". Messages are sent as they are published, without a key,
+// which the reports hold anyway.
+type syslogPublisher struct {
+	w *syslog.Writer
+}
+
+func newSyslog(target string) (*syslogPublisher, error) {
+	w, err := syslog.Dial(target)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogPublisher{w: w}, nil
+}
+
+// Publish sends value.
+func (p *syslogPublisher) Publish(_, value []byte) error {
+	return p.w.Send(syslog.Info, "report", string(value))
+}
+
+// Close closes the connection.
+func (p *syslogPublisher) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,39 @@
+package publish
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslog_Publish(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	p, err := New("udp://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.Publish([]byte("8.8.8.8"), []byte(`{"ip":"8.8.8.8"}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// user is 1: 1*8 + 6, informational
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<14>1 ") || !strings.HasSuffix(msg, ` report - {"ip":"8.8.8.8"}`) {
+		t.Errorf("message = %q", msg)
+	}
+}
@@ -0,0 +1,103 @@
+// Package push sends the metrics of a batch run, once it completes, to a
+// Prometheus Pushgateway or a statsd server, so that batch jobs run from
+// cron are observable without a long-running process to scrape.
+package push
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"api-client/internal/model"
+)
+
+// Metrics summarizes a batch run.
+type Metrics struct {
+	// Records is the number of addresses looked up
+	Records int
+
+	// Failed is the number of lookups that failed on every provider
+	Failed int
+
+	// Duration is how long the run took
+	Duration time.Duration
+
+	// Providers holds the queries and errors of each provider, by name
+	Providers map[string]ProviderStats
+}
+
+// ProviderStats counts the queries of a provider and how many failed.
+// Providers skipped for an address are not counted.
+type ProviderStats struct {
+	Queries int
+	Errors  int
+}
+
+// ErrorRate returns the share of the queries that failed, from 0 to 1.
+func (s ProviderStats) ErrorRate() float64 {
+	if s.Queries == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Queries)
+}
+
+// Add counts report.
+func (m *Metrics) Add(report model.Report) {
+	m.Records++
+	if report.AllFailed() {
+		m.Failed++
+	}
+
+	for _, pr := range report.Results {
+		if pr.Skipped {
+			continue
+		}
+		if m.Providers == nil {
+			m.Providers = make(map[string]ProviderStats)
+		}
+		s := m.Providers[pr.Provider]
+		s.Queries++
+		if !pr.Success() {
+			s.Errors++
+		}
+		m.Providers[pr.Provider] = s
+	}
+}
+
+// providerNames returns the names of the providers counted, sorted.
+func (m Metrics) providerNames() []string {
+	names := make([]string, 0, len(m.Providers))
+	for name := range m.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pusher sends metrics to a monitoring system.
+type Pusher interface {
+	Push(ctx context.Context, m Metrics) error
+}
+
+// New returns the Pusher for target: an http or https URL is that of a
+// Pushgateway and statsd://host:port that of a statsd server.
+func New(target string) (Pusher, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q: expected an http(s):// Pushgateway or statsd:// URL", target)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return newPushgateway(u), nil
+	case "statsd":
+		return newStatsd(u), nil
+	default:
+		return nil, fmt.Errorf("%q: unsupported scheme %q; expected http, https or statsd", target, u.Scheme)
+	}
+}
@@ -0,0 +1,169 @@
+package push
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+)
+
+func testMetrics() Metrics {
+	var m Metrics
+	geo := &model.Geolocation{CountryCode: "US"}
+	m.Add(model.Report{Results: []model.ProviderResult{
+		{Provider: "ipinfo", Result: geo},
+		{Provider: "ip-api", Error: "timeout"},
+		{Provider: "ipwhois", Skipped: true, Error: "quorum reached"},
+	}})
+	m.Add(model.Report{Results: []model.ProviderResult{
+		{Provider: "ipinfo", Error: "unexpected status code: 429"},
+		{Provider: "ip-api", Error: "timeout"},
+	}})
+	m.Duration = 1500 * time.Millisecond
+	return m
+}
+
+func TestMetrics_Add(t *testing.T) {
+	m := testMetrics()
+
+	if m.Records != 2 || m.Failed != 1 {
+		t.Errorf("Records, Failed = %d, %d; want 2, 1", m.Records, m.Failed)
+	}
+	if s := m.Providers["ipinfo"]; s.Queries != 2 || s.Errors != 1 || s.ErrorRate() != 0.5 {
+		t.Errorf("ipinfo = %+v, rate %v", s, s.ErrorRate())
+	}
+	if _, ok := m.Providers["ipwhois"]; ok {
+		t.Error("skipped providers should not be counted")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, target := range []string{"localhost:9091", "udp://localhost:8125", "http://"} {
+		if _, err := New(target); err == nil {
+			t.Errorf("New(%q) expected error", target)
+		}
+	}
+}
+
+func TestPushgateway_Push(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		target, wantPath string
+	}{
+		{server.URL, "/metrics/job/ipintel"},
+		{server.URL + "/metrics/job/geo/instance/nightly", "/metrics/job/geo/instance/nightly"},
+	}
+	for _, tt := range tests {
+		p, err := New(tt.target)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if err := p.Push(context.Background(), testMetrics()); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+		if path != tt.wantPath {
+			t.Errorf("path = %q, want %q", path, tt.wantPath)
+		}
+	}
+
+	for _, want := range []string{
+		"# TYPE ipintel_batch_records gauge\nipintel_batch_records 2\n",
+		"ipintel_batch_failed 1\n",
+		"ipintel_batch_duration_seconds 1.5\n",
+		`ipintel_batch_provider_queries{provider="ip-api"} 2`,
+		`ipintel_batch_provider_errors{provider="ipinfo"} 1`,
+		`ipintel_batch_provider_error_ratio{provider="ipinfo"} 0.5`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body should contain %q\nGot:\n%s", want, body)
+		}
+	}
+}
+
+func TestPushgateway_PushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p, _ := New(server.URL)
+	if err := p.Push(context.Background(), testMetrics()); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Push() error = %v, want status 400", err)
+	}
+}
+
+func TestStatsd_Push(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	p, err := New("statsd://" + conn.LocalAddr().String() + "/jobs.geo")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.Push(context.Background(), testMetrics()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"jobs.geo.records:2|g",
+		"jobs.geo.failed:1|g",
+		"jobs.geo.duration:1500|ms",
+		"jobs.geo.provider.ip-api.queries:2|g",
+		"jobs.geo.provider.ip-api.errors:2|g",
+		"jobs.geo.provider.ip-api.error_ratio:1|g",
+		"jobs.geo.provider.ipinfo.queries:2|g",
+		"jobs.geo.provider.ipinfo.errors:1|g",
+		"jobs.geo.provider.ipinfo.error_ratio:0.5|g",
+	}, "\n")
+	if got := string(buf[:n]); got != want {
+		t.Errorf("datagram = %q, want %q", got, want)
+	}
+}
+
+func TestStatsd_Datagrams(t *testing.T) {
+	m := Metrics{Providers: make(map[string]ProviderStats)}
+	for _, name := range strings.Split("abcdefghijklmnopqrstuvwxyz", "") {
+		m.Providers[strings.Repeat(name, 40)] = ProviderStats{Queries: 1}
+	}
+
+	datagrams := (&statsd{prefix: DefaultPrefix}).datagrams(m)
+	if len(datagrams) < 2 {
+		t.Fatalf("got %d datagrams, want the metrics split", len(datagrams))
+	}
+
+	lines := 0
+	for _, d := range datagrams {
+		if len(d) > maxDatagram {
+			t.Errorf("datagram of %d bytes exceeds %d", len(d), maxDatagram)
+		}
+		lines += strings.Count(string(d), "\n") + 1
+	}
+	if want := 3 + 3*26; lines != want {
+		t.Errorf("got %d lines, want %d", lines, want)
+	}
+}
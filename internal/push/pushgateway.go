@@ -0,0 +1,98 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+// DefaultJob is the Pushgateway job the metrics are grouped under when the
+// URL names none.
+const DefaultJob = "ipintel"
+
+// pushgateway replaces the metrics of its group on a Prometheus
+// Pushgateway with those of each run.
+type pushgateway struct {
+	url string
+}
+
+// newPushgateway pushes to u, which may name the group, as in
+// http://host:9091/metrics/job/geo/instance/nightly; otherwise the metrics
+// are grouped under DefaultJob.
+func newPushgateway(u *url.URL) *pushgateway {
+	if !strings.Contains(u.Path, "/metrics/job/") {
+		u = u.JoinPath("metrics", "job", DefaultJob)
+	}
+	return &pushgateway{url: u.String()}
+}
+
+func (p *pushgateway) Push(ctx context.Context, m Metrics) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.url, bytes.NewReader(m.exposition(time.Now())))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway: %w", provider.StatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
+
+// exposition writes m in the Prometheus text exposition format, with the
+// time the run completed at as now.
+func (m Metrics) exposition(now time.Time) []byte {
+	var buf bytes.Buffer
+	gauge := func(name, help string) {
+		_, _ = fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	value := func(name, labels string, v float64) {
+		_, _ = fmt.Fprintf(&buf, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	gauge("ipintel_batch_records", "Addresses looked up by the last batch run.")
+	value("ipintel_batch_records", "", float64(m.Records))
+	gauge("ipintel_batch_failed", "Lookups of the last batch run that failed on every provider.")
+	value("ipintel_batch_failed", "", float64(m.Failed))
+	gauge("ipintel_batch_duration_seconds", "Duration of the last batch run.")
+	value("ipintel_batch_duration_seconds", "", m.Duration.Seconds())
+	gauge("ipintel_batch_last_completion_timestamp_seconds", "Time the last batch run completed at.")
+	value("ipintel_batch_last_completion_timestamp_seconds", "", float64(now.Unix()))
+
+	names := m.providerNames()
+	if len(names) == 0 {
+		return buf.Bytes()
+	}
+
+	gauge("ipintel_batch_provider_queries", "Queries of each provider in the last batch run.")
+	for _, name := range names {
+		value("ipintel_batch_provider_queries", providerLabel(name), float64(m.Providers[name].Queries))
+	}
+	gauge("ipintel_batch_provider_errors", "Failed queries of each provider in the last batch run.")
+	for _, name := range names {
+		value("ipintel_batch_provider_errors", providerLabel(name), float64(m.Providers[name].Errors))
+	}
+	gauge("ipintel_batch_provider_error_ratio", "Share of the queries of each provider that failed in the last batch run.")
+	for _, name := range names {
+		value("ipintel_batch_provider_error_ratio", providerLabel(name), m.Providers[name].ErrorRate())
+	}
+	return buf.Bytes()
+}
+
+// providerLabel returns the label set naming a provider.
+func providerLabel(name string) string {
+	return `{provider="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(name) + `"}`
+}
@@ -0,0 +1,94 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultPrefix prefixes the statsd metric names when the URL sets none.
+const DefaultPrefix = "ipintel.batch"
+
+// maxDatagram bounds the size of the statsd datagrams, so that they fit in
+// the MTU of common networks.
+const maxDatagram = 1432
+
+// statsd sends the metrics of each run as gauges, and the duration as a
+// timer, to a statsd server over UDP.
+type statsd struct {
+	addr   string
+	prefix string
+}
+
+// newStatsd sends to the server of u, naming the metrics after the path of
+// u, as in statsd://host:8125/jobs.geo, or DefaultPrefix.
+func newStatsd(u *url.URL) *statsd {
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &statsd{addr: u.Host, prefix: prefix}
+}
+
+func (s *statsd) Push(ctx context.Context, m Metrics) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, datagram := range s.datagrams(m) {
+		if _, err := conn.Write(datagram); err != nil {
+			return fmt.Errorf("statsd: %w", err)
+		}
+	}
+	return nil
+}
+
+// datagrams writes m as statsd lines, packed into as few datagrams as
+// maxDatagram allows.
+func (s *statsd) datagrams(m Metrics) [][]byte {
+	lines := []string{
+		s.line("records", float64(m.Records), "g"),
+		s.line("failed", float64(m.Failed), "g"),
+		s.line("duration", float64(m.Duration.Milliseconds()), "ms"),
+	}
+	for _, name := range m.providerNames() {
+		stats := m.Providers[name]
+		key := "provider." + metricName(name) + "."
+		lines = append(lines,
+			s.line(key+"queries", float64(stats.Queries), "g"),
+			s.line(key+"errors", float64(stats.Errors), "g"),
+			s.line(key+"error_ratio", stats.ErrorRate(), "g"),
+		)
+	}
+
+	var datagrams [][]byte
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > maxDatagram {
+			datagrams = append(datagrams, bytes.Clone(buf.Bytes()))
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	return append(datagrams, buf.Bytes())
+}
+
+func (s *statsd) line(name string, v float64, kind string) string {
+	return s.prefix + "." + name + ":" + strconv.FormatFloat(v, 'f', -1, 64) + "|" + kind
+}
+
+// metricName replaces the characters statsd gives a meaning to in a
+// provider name.
+func metricName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_").Replace(name)
+}
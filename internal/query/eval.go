@@ -0,0 +1,363 @@
+package query
+
+import "sort"
+
+// node is a parsed expression, evaluated against a JSON value decoded into
+// nil, bool, float64, string, []any or map[string]any.
+type node interface {
+	search(v any) (any, error)
+}
+
+type (
+	identity struct{}
+	literal  struct{ value any }
+	field    struct{ name string }
+	index    struct{ n int }
+
+	// slice is [start:stop:step]; nil parts take their defaults
+	slice struct{ start, stop, step *int }
+
+	subexpression struct{ left, right node }
+	pipe          struct{ left, right node }
+	or            struct{ left, right node }
+	and           struct{ left, right node }
+	not           struct{ e node }
+
+	comparison struct {
+		op          tokenKind
+		left, right node
+	}
+
+	// projection applies right to each element of the list left evaluates to
+	projection struct{ left, right node }
+
+	// valueProjection applies right to each value of the object left
+	// evaluates to
+	valueProjection struct{ left, right node }
+
+	// filterProjection applies right to each element of the list left
+	// evaluates to for which cond is true
+	filterProjection struct{ left, cond, right node }
+
+	flatten struct{ e node }
+
+	multiSelectList []node
+	multiSelectHash []keyValue
+	keyValue        struct {
+		key   string
+		value node
+	}
+
+	// expref is an expression passed to a function, such as sort_by, that
+	// evaluates it itself
+	expref struct{ e node }
+
+	call struct {
+		name string
+		fn   function
+		args []node
+	}
+)
+
+func (identity) search(v any) (any, error) { return v, nil }
+func (n literal) search(any) (any, error)  { return n.value, nil }
+func (n expref) search(any) (any, error)   { return n, nil }
+
+func (n field) search(v any) (any, error) {
+	if m, ok := v.(map[string]any); ok {
+		return m[n.name], nil
+	}
+	return nil, nil
+}
+
+func (n index) search(v any) (any, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, nil
+	}
+	i := n.n
+	if i < 0 {
+		i += len(list)
+	}
+	if i < 0 || i >= len(list) {
+		return nil, nil
+	}
+	return list[i], nil
+}
+
+func (n slice) search(v any) (any, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	step := 1
+	if n.step != nil {
+		step = *n.step
+	}
+	// bound clamps a slice bound into the list, as Python does
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += len(list)
+		}
+		lo, hi := 0, len(list)
+		if step < 0 {
+			lo, hi = -1, len(list)-1
+		}
+		return max(lo, min(hi, i))
+	}
+
+	result := []any{}
+	if step > 0 {
+		for i := bound(n.start, 0); i < bound(n.stop, len(list)); i += step {
+			result = append(result, list[i])
+		}
+	} else {
+		for i := bound(n.start, len(list)-1); i > bound(n.stop, -1); i += step {
+			result = append(result, list[i])
+		}
+	}
+	return result, nil
+}
+
+func (n subexpression) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil || left == nil {
+		return nil, err
+	}
+	return n.right.search(left)
+}
+
+func (n pipe) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil {
+		return nil, err
+	}
+	return n.right.search(left)
+}
+
+func (n or) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil || truthy(left) {
+		return left, err
+	}
+	return n.right.search(v)
+}
+
+func (n and) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil || !truthy(left) {
+		return left, err
+	}
+	return n.right.search(v)
+}
+
+func (n not) search(v any) (any, error) {
+	e, err := n.e.search(v)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(e), nil
+}
+
+func (n comparison) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.search(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case tEQ:
+		return equal(left, right), nil
+	case tNE:
+		return !equal(left, right), nil
+	}
+
+	// Ordering is only defined between numbers
+	a, ok := left.(float64)
+	b, ok2 := right.(float64)
+	if !ok || !ok2 {
+		return nil, nil
+	}
+	switch n.op {
+	case tLT:
+		return a < b, nil
+	case tLTE:
+		return a <= b, nil
+	case tGT:
+		return a > b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+// project collects the non-null results of right over elements.
+func project(elements []any, right node) (any, error) {
+	result := []any{}
+	for _, e := range elements {
+		r, err := right.search(e)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+func (n projection) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := left.([]any)
+	if !ok {
+		return nil, nil
+	}
+	return project(list, n.right)
+}
+
+func (n valueProjection) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := left.(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	return project(sortedValues(m), n.right)
+}
+
+func (n filterProjection) search(v any) (any, error) {
+	left, err := n.left.search(v)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := left.([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	var matches []any
+	for _, e := range list {
+		ok, err := n.cond.search(e)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(ok) {
+			matches = append(matches, e)
+		}
+	}
+	return project(matches, n.right)
+}
+
+func (n flatten) search(v any) (any, error) {
+	e, err := n.e.search(v)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := e.([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	result := []any{}
+	for _, elem := range list {
+		if inner, ok := elem.([]any); ok {
+			result = append(result, inner...)
+		} else {
+			result = append(result, elem)
+		}
+	}
+	return result, nil
+}
+
+func (n multiSelectList) search(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	result := make([]any, len(n))
+	for i, e := range n {
+		r, err := e.search(v)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+func (n multiSelectHash) search(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	result := make(map[string]any, len(n))
+	for _, kv := range n {
+		r, err := kv.value.search(v)
+		if err != nil {
+			return nil, err
+		}
+		result[kv.key] = r
+	}
+	return result, nil
+}
+
+func (n call) search(v any) (any, error) {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		a, err := arg.search(v)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = a
+	}
+	if err := n.fn.check(n.name, args); err != nil {
+		return nil, err
+	}
+	return n.fn.call(args)
+}
+
+// truthy reports whether v counts as true: false, null, empty strings,
+// lists and objects are false, everything else, including 0, is true.
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// sortedValues returns the values of m in key order, so that projections
+// over objects are deterministic.
+func sortedValues(m map[string]any) []any {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return values
+}
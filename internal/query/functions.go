@@ -0,0 +1,392 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// argType is a set of the JSON types a function argument accepts.
+type argType int
+
+const (
+	typeNumber argType = 1 << iota
+	typeString
+	typeBool
+	typeNull
+	typeArray
+	typeObject
+	typeExpref
+
+	typeAny = typeNumber | typeString | typeBool | typeNull | typeArray | typeObject
+)
+
+// function is a built-in function. Variadic functions take any number of
+// arguments of their last type beyond those of args.
+type function struct {
+	args     []argType
+	variadic bool
+	call     func(args []any) (any, error)
+}
+
+// typeOf returns the type of a value.
+func typeOf(v any) argType {
+	switch v.(type) {
+	case float64:
+		return typeNumber
+	case string:
+		return typeString
+	case bool:
+		return typeBool
+	case []any:
+		return typeArray
+	case map[string]any:
+		return typeObject
+	case expref:
+		return typeExpref
+	default:
+		return typeNull
+	}
+}
+
+// typeName returns the JMESPath name of the type of v.
+func typeName(v any) string {
+	switch typeOf(v) {
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	case typeBool:
+		return "boolean"
+	case typeArray:
+		return "array"
+	case typeObject:
+		return "object"
+	case typeExpref:
+		return "expref"
+	default:
+		return "null"
+	}
+}
+
+// check verifies the types of the arguments of a call to fn.
+func (fn function) check(name string, args []any) error {
+	for i, arg := range args {
+		want := fn.args[min(i, len(fn.args)-1)]
+		if typeOf(arg)&want == 0 {
+			return &Error{Msg: fmt.Sprintf("%s(): argument %d is a %s", name, i+1, typeName(arg))}
+		}
+	}
+	return nil
+}
+
+// functions are the built-in functions, by name.
+var functions = map[string]function{
+	"abs":         {args: []argType{typeNumber}, call: math1(math.Abs)},
+	"avg":         {args: []argType{typeArray}, call: fnAvg},
+	"ceil":        {args: []argType{typeNumber}, call: math1(math.Ceil)},
+	"contains":    {args: []argType{typeArray | typeString, typeAny}, call: fnContains},
+	"ends_with":   {args: []argType{typeString, typeString}, call: fnEndsWith},
+	"floor":       {args: []argType{typeNumber}, call: math1(math.Floor)},
+	"join":        {args: []argType{typeString, typeArray}, call: fnJoin},
+	"keys":        {args: []argType{typeObject}, call: fnKeys},
+	"length":      {args: []argType{typeString | typeArray | typeObject}, call: fnLength},
+	"map":         {args: []argType{typeExpref, typeArray}, call: fnMap},
+	"max":         {args: []argType{typeArray}, call: extremum(1)},
+	"max_by":      {args: []argType{typeArray, typeExpref}, call: extremumBy(1)},
+	"min":         {args: []argType{typeArray}, call: extremum(-1)},
+	"min_by":      {args: []argType{typeArray, typeExpref}, call: extremumBy(-1)},
+	"not_null":    {args: []argType{typeAny}, variadic: true, call: fnNotNull},
+	"reverse":     {args: []argType{typeString | typeArray}, call: fnReverse},
+	"sort":        {args: []argType{typeArray}, call: fnSort},
+	"sort_by":     {args: []argType{typeArray, typeExpref}, call: fnSortBy},
+	"starts_with": {args: []argType{typeString, typeString}, call: fnStartsWith},
+	"sum":         {args: []argType{typeArray}, call: fnSum},
+	"to_array":    {args: []argType{typeAny}, call: fnToArray},
+	"to_number":   {args: []argType{typeAny}, call: fnToNumber},
+	"to_string":   {args: []argType{typeAny}, call: fnToString},
+	"type":        {args: []argType{typeAny}, call: func(args []any) (any, error) { return typeName(args[0]), nil }},
+	"values":      {args: []argType{typeObject}, call: func(args []any) (any, error) { return sortedValues(args[0].(map[string]any)), nil }},
+}
+
+func math1(f func(float64) float64) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		return f(args[0].(float64)), nil
+	}
+}
+
+// numbers returns the elements of list, which must all be numbers.
+func numbers(name string, list []any) ([]float64, error) {
+	nums := make([]float64, len(list))
+	for i, v := range list {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, &Error{Msg: fmt.Sprintf("%s(): element %d is a %s, not a number", name, i, typeName(v))}
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+func fnAvg(args []any) (any, error) {
+	nums, err := numbers("avg", args[0].([]any))
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+	var sum float64
+	for _, n := range nums {
+		sum += n
+	}
+	return sum / float64(len(nums)), nil
+}
+
+func fnSum(args []any) (any, error) {
+	nums, err := numbers("sum", args[0].([]any))
+	if err != nil {
+		return nil, err
+	}
+	var sum float64
+	for _, n := range nums {
+		sum += n
+	}
+	return sum, nil
+}
+
+func fnContains(args []any) (any, error) {
+	if s, ok := args[0].(string); ok {
+		sub, ok := args[1].(string)
+		return ok && strings.Contains(s, sub), nil
+	}
+	for _, v := range args[0].([]any) {
+		if equal(v, args[1]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func fnStartsWith(args []any) (any, error) {
+	return strings.HasPrefix(args[0].(string), args[1].(string)), nil
+}
+
+func fnEndsWith(args []any) (any, error) {
+	return strings.HasSuffix(args[0].(string), args[1].(string)), nil
+}
+
+func fnJoin(args []any) (any, error) {
+	list := args[1].([]any)
+	parts := make([]string, len(list))
+	for i, v := range list {
+		s, ok := v.(string)
+		if !ok {
+			return nil, &Error{Msg: fmt.Sprintf("join(): element %d is a %s, not a string", i, typeName(v))}
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, args[0].(string)), nil
+}
+
+func fnKeys(args []any) (any, error) {
+	m := args[0].(map[string]any)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]any, len(keys))
+	for i, k := range keys {
+		result[i] = k
+	}
+	return result, nil
+}
+
+func fnLength(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case string:
+		return float64(len([]rune(v))), nil
+	case []any:
+		return float64(len(v)), nil
+	default:
+		return float64(len(v.(map[string]any))), nil
+	}
+}
+
+func fnMap(args []any) (any, error) {
+	e := args[0].(expref).e
+	list := args[1].([]any)
+	result := make([]any, len(list))
+	for i, v := range list {
+		r, err := e.search(v)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+func fnNotNull(args []any) (any, error) {
+	for _, v := range args {
+		if v != nil {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+func fnReverse(args []any) (any, error) {
+	if s, ok := args[0].(string); ok {
+		runes := []rune(s)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	}
+
+	list := args[0].([]any)
+	result := make([]any, len(list))
+	for i, v := range list {
+		result[len(list)-1-i] = v
+	}
+	return result, nil
+}
+
+// checkSortable verifies that keys are all numbers or all strings.
+func checkSortable(name string, keys []any) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	first := typeOf(keys[0])
+	for i, k := range keys {
+		if t := typeOf(k); t != first || t != typeNumber && t != typeString {
+			return &Error{Msg: fmt.Sprintf("%s(): element %d is a %s; elements must all be numbers or all be strings", name, i, typeName(k))}
+		}
+	}
+	return nil
+}
+
+// less orders two numbers or two strings.
+func less(a, b any) bool {
+	if x, ok := a.(float64); ok {
+		return x < b.(float64)
+	}
+	return a.(string) < b.(string)
+}
+
+func fnSort(args []any) (any, error) {
+	list := append([]any(nil), args[0].([]any)...)
+	if err := checkSortable("sort", list); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(list, func(i, j int) bool { return less(list[i], list[j]) })
+	return list, nil
+}
+
+// keysBy evaluates e against each element of list.
+func keysBy(name string, list []any, e node) ([]any, error) {
+	keys := make([]any, len(list))
+	for i, v := range list {
+		k, err := e.search(v)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, checkSortable(name, keys)
+}
+
+func fnSortBy(args []any) (any, error) {
+	list := args[0].([]any)
+	keys, err := keysBy("sort_by", list, args[1].(expref).e)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]int, len(list))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return less(keys[order[i]], keys[order[j]]) })
+
+	result := make([]any, len(list))
+	for i, o := range order {
+		result[i] = list[o]
+	}
+	return result, nil
+}
+
+// extremum returns max (sign 1) or min (sign -1) of a list of numbers or
+// strings.
+func extremum(sign int) func([]any) (any, error) {
+	name := map[int]string{1: "max", -1: "min"}[sign]
+	return func(args []any) (any, error) {
+		list := args[0].([]any)
+		if err := checkSortable(name, list); err != nil || len(list) == 0 {
+			return nil, err
+		}
+		best := list[0]
+		for _, v := range list[1:] {
+			if sign > 0 && less(best, v) || sign < 0 && less(v, best) {
+				best = v
+			}
+		}
+		return best, nil
+	}
+}
+
+// extremumBy returns the element of a list with the max (sign 1) or min
+// (sign -1) key.
+func extremumBy(sign int) func([]any) (any, error) {
+	name := map[int]string{1: "max_by", -1: "min_by"}[sign]
+	return func(args []any) (any, error) {
+		list := args[0].([]any)
+		keys, err := keysBy(name, list, args[1].(expref).e)
+		if err != nil || len(list) == 0 {
+			return nil, err
+		}
+		best := 0
+		for i := 1; i < len(list); i++ {
+			if sign > 0 && less(keys[best], keys[i]) || sign < 0 && less(keys[i], keys[best]) {
+				best = i
+			}
+		}
+		return list[best], nil
+	}
+}
+
+func fnToArray(args []any) (any, error) {
+	if list, ok := args[0].([]any); ok {
+		return list, nil
+	}
+	return []any{args[0]}, nil
+}
+
+func fnToNumber(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case float64:
+		return v, nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, nil
+		}
+		return n, nil
+	default:
+		return nil, nil
+	}
+}
+
+func fnToString(args []any) (any, error) {
+	if s, ok := args[0].(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,249 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind identifies a lexical token of a query.
+type tokenKind int
+
+const (
+	tEOF tokenKind = iota
+	tUnquotedIdentifier
+	tQuotedIdentifier
+	tJSONLiteral
+	tStringLiteral
+	tNumber
+	tDot
+	tStar
+	tCurrent
+	tExpref
+	tComma
+	tColon
+	tPipe
+	tOr
+	tAnd
+	tNot
+	tEQ
+	tNE
+	tLT
+	tLTE
+	tGT
+	tGTE
+	tLbracket
+	tRbracket
+	tFlatten
+	tFilter
+	tLbrace
+	tRbrace
+	tLparen
+	tRparen
+)
+
+var tokenNames = map[tokenKind]string{
+	tEOF:                "end of query",
+	tUnquotedIdentifier: "identifier",
+	tQuotedIdentifier:   "quoted identifier",
+	tJSONLiteral:        "literal",
+	tStringLiteral:      "raw string",
+	tNumber:             "number",
+	tDot:                "'.'",
+	tStar:               "'*'",
+	tCurrent:            "'@'",
+	tExpref:             "'&'",
+	tComma:              "','",
+	tColon:              "':'",
+	tPipe:               "'|'",
+	tOr:                 "'||'",
+	tAnd:                "'&&'",
+	tNot:                "'!'",
+	tEQ:                 "'=='",
+	tNE:                 "'!='",
+	tLT:                 "'<'",
+	tLTE:                "'<='",
+	tGT:                 "'>'",
+	tGTE:                "'>='",
+	tLbracket:           "'['",
+	tRbracket:           "']'",
+	tFlatten:            "'[]'",
+	tFilter:             "'[?'",
+	tLbrace:             "'{'",
+	tRbrace:             "'}'",
+	tLparen:             "'('",
+	tRparen:             "')'",
+}
+
+func (k tokenKind) String() string {
+	return tokenNames[k]
+}
+
+// token is a lexical token of a query. value holds the decoded value of
+// identifiers, numbers and literals.
+type token struct {
+	kind  tokenKind
+	text  string
+	value any
+	pos   int
+}
+
+// simpleTokens are the tokens made of a single character.
+var simpleTokens = map[byte]tokenKind{
+	'.': tDot,
+	'*': tStar,
+	'@': tCurrent,
+	',': tComma,
+	':': tColon,
+	']': tRbracket,
+	'{': tLbrace,
+	'}': tRbrace,
+	'(': tLparen,
+	')': tRparen,
+}
+
+// lex splits a query into tokens, ending with tEOF.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		if kind, ok := simpleTokens[c]; ok {
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i})
+			i++
+			continue
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '[':
+			kind, n := tLbracket, 1
+			if i+1 < len(src) && src[i+1] == ']' {
+				kind, n = tFlatten, 2
+			} else if i+1 < len(src) && src[i+1] == '?' {
+				kind, n = tFilter, 2
+			}
+			tokens = append(tokens, token{kind: kind, text: src[i : i+n], pos: i})
+			i += n
+		case c == '|' || c == '&':
+			kind, n := tPipe, 1
+			if c == '&' {
+				kind = tExpref
+			}
+			if i+1 < len(src) && src[i+1] == c {
+				kind, n = tOr, 2
+				if c == '&' {
+					kind = tAnd
+				}
+			}
+			tokens = append(tokens, token{kind: kind, text: src[i : i+n], pos: i})
+			i += n
+		case c == '!' || c == '=' || c == '<' || c == '>':
+			t := token{text: string(c), pos: i}
+			eq := i+1 < len(src) && src[i+1] == '='
+			if eq {
+				t.text += "="
+			}
+			switch {
+			case c == '!' && eq:
+				t.kind = tNE
+			case c == '!':
+				t.kind = tNot
+			case c == '=' && eq:
+				t.kind = tEQ
+			case c == '=':
+				return nil, &SyntaxError{Pos: i, Msg: "unexpected '=': use '=='"}
+			case c == '<' && eq:
+				t.kind = tLTE
+			case c == '<':
+				t.kind = tLT
+			case eq:
+				t.kind = tGTE
+			default:
+				t.kind = tGT
+			}
+			tokens = append(tokens, t)
+			i += len(t.text)
+		case c == '-' || isDigit(c):
+			j := i + 1
+			for j < len(src) && isDigit(src[j]) {
+				j++
+			}
+			if j == i+1 && c == '-' {
+				return nil, &SyntaxError{Pos: i, Msg: "expected a digit after '-'"}
+			}
+			n, err := strconv.Atoi(src[i:j])
+			if err != nil {
+				return nil, &SyntaxError{Pos: i, Msg: "number out of range"}
+			}
+			tokens = append(tokens, token{kind: tNumber, text: src[i:j], value: n, pos: i})
+			i = j
+		case c == '_' || isLetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || isLetter(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tUnquotedIdentifier, text: src[i:j], value: src[i:j], pos: i})
+			i = j
+		case c == '"' || c == '\'' || c == '`':
+			t, err := lexQuoted(src, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i += len(t.text)
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{kind: tEOF, pos: len(src)}), nil
+}
+
+// lexQuoted lexes the quoted identifier, raw string or JSON literal
+// starting at src[start].
+func lexQuoted(src string, start int) (token, error) {
+	quote := src[start]
+	end := -1
+	for j := start + 1; j < len(src); j++ {
+		if src[j] == '\\' {
+			j++
+			continue
+		}
+		if src[j] == quote {
+			end = j
+			break
+		}
+	}
+	if end < 0 {
+		return token{}, &SyntaxError{Pos: start, Msg: "unterminated " + map[byte]string{'"': "quoted identifier", '\'': "raw string", '`': "literal"}[quote]}
+	}
+
+	t := token{text: src[start : end+1], pos: start}
+	body := src[start+1 : end]
+	switch quote {
+	case '"':
+		var s string
+		if err := json.Unmarshal([]byte(t.text), &s); err != nil {
+			return token{}, &SyntaxError{Pos: start, Msg: "invalid quoted identifier"}
+		}
+		t.kind, t.value = tQuotedIdentifier, s
+	case '\'':
+		t.kind, t.value = tStringLiteral, strings.ReplaceAll(body, `\'`, `'`)
+	default:
+		var v any
+		if err := json.Unmarshal([]byte(strings.ReplaceAll(body, "\\`", "`")), &v); err != nil {
+			return token{}, &SyntaxError{Pos: start, Msg: "invalid JSON literal"}
+		}
+		t.kind, t.value = tJSONLiteral, v
+	}
+	return t, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
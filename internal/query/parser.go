@@ -0,0 +1,400 @@
+package query
+
+import "fmt"
+
+// bindingPowers are the precedences of the tokens in the Pratt parser: an
+// expression extends over the tokens binding tighter than its context.
+var bindingPowers = map[tokenKind]int{
+	tPipe:     1,
+	tOr:       2,
+	tAnd:      3,
+	tEQ:       5,
+	tNE:       5,
+	tLT:       5,
+	tLTE:      5,
+	tGT:       5,
+	tGTE:      5,
+	tFlatten:  9,
+	tStar:     20,
+	tFilter:   21,
+	tDot:      40,
+	tNot:      45,
+	tLbrace:   50,
+	tLbracket: 55,
+	tLparen:   60,
+}
+
+// projectionStop is the binding power below which tokens end the right-hand
+// side of a projection.
+const projectionStop = 10
+
+// parser is a top-down operator precedence parser of queries.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) current() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) lookahead(n int) tokenKind {
+	if p.pos+n >= len(p.tokens) {
+		return tEOF
+	}
+	return p.tokens[p.pos+n].kind
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if t := p.current(); t.kind != kind {
+		return p.unexpected(t, "expected "+kind.String())
+	}
+	p.advance()
+	return nil
+}
+
+func (p *parser) unexpected(t token, msg string) error {
+	if t.kind == tEOF {
+		return &SyntaxError{Pos: t.pos, Msg: "unexpected end of query: " + msg}
+	}
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s: %s", t.text, msg)}
+}
+
+// expression parses the expression extending over the tokens that bind
+// tighter than bp.
+func (p *parser) expression(bp int) (node, error) {
+	left, err := p.nud(p.advance())
+	if err != nil {
+		return nil, err
+	}
+	for bp < bindingPowers[p.current().kind] {
+		if left, err = p.led(p.advance(), left); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// nud parses the expression starting with t.
+func (p *parser) nud(t token) (node, error) {
+	switch t.kind {
+	case tJSONLiteral, tStringLiteral:
+		return literal{t.value}, nil
+	case tUnquotedIdentifier:
+		return field{t.value.(string)}, nil
+	case tQuotedIdentifier:
+		if p.current().kind == tLparen {
+			return nil, p.unexpected(p.current(), "function names cannot be quoted")
+		}
+		return field{t.value.(string)}, nil
+	case tCurrent:
+		return identity{}, nil
+	case tStar:
+		right, err := p.projectionRHS(bindingPowers[tStar])
+		if err != nil {
+			return nil, err
+		}
+		return valueProjection{identity{}, right}, nil
+	case tFilter:
+		return p.filter(identity{})
+	case tFlatten:
+		right, err := p.projectionRHS(bindingPowers[tFlatten])
+		if err != nil {
+			return nil, err
+		}
+		return projection{flatten{identity{}}, right}, nil
+	case tLbracket:
+		switch {
+		case p.current().kind == tNumber || p.current().kind == tColon:
+			index, err := p.index()
+			if err != nil {
+				return nil, err
+			}
+			return p.projectIfSlice(identity{}, index)
+		case p.current().kind == tStar && p.lookahead(1) == tRbracket:
+			p.advance()
+			p.advance()
+			right, err := p.projectionRHS(bindingPowers[tStar])
+			if err != nil {
+				return nil, err
+			}
+			return projection{identity{}, right}, nil
+		}
+		return p.multiSelectList()
+	case tLbrace:
+		return p.multiSelectHash()
+	case tExpref:
+		e, err := p.expression(bindingPowers[tExpref])
+		if err != nil {
+			return nil, err
+		}
+		return expref{e}, nil
+	case tNot:
+		e, err := p.expression(bindingPowers[tNot])
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	case tLparen:
+		e, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tRparen); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return nil, p.unexpected(t, "expected an expression")
+}
+
+// led parses the expression continuing left with t.
+func (p *parser) led(t token, left node) (node, error) {
+	switch t.kind {
+	case tDot:
+		if p.current().kind != tStar {
+			right, err := p.dotRHS(bindingPowers[tDot])
+			if err != nil {
+				return nil, err
+			}
+			return subexpression{left, right}, nil
+		}
+		p.advance()
+		right, err := p.projectionRHS(bindingPowers[tDot])
+		if err != nil {
+			return nil, err
+		}
+		return valueProjection{left, right}, nil
+	case tPipe, tOr, tAnd:
+		right, err := p.expression(bindingPowers[t.kind])
+		if err != nil {
+			return nil, err
+		}
+		switch t.kind {
+		case tPipe:
+			return pipe{left, right}, nil
+		case tOr:
+			return or{left, right}, nil
+		}
+		return and{left, right}, nil
+	case tEQ, tNE, tLT, tLTE, tGT, tGTE:
+		right, err := p.expression(bindingPowers[t.kind])
+		if err != nil {
+			return nil, err
+		}
+		return comparison{t.kind, left, right}, nil
+	case tLparen:
+		name, ok := left.(field)
+		if !ok {
+			return nil, p.unexpected(t, "only functions can be called")
+		}
+		return p.call(t, name.name)
+	case tFilter:
+		return p.filter(left)
+	case tFlatten:
+		right, err := p.projectionRHS(bindingPowers[tFlatten])
+		if err != nil {
+			return nil, err
+		}
+		return projection{flatten{left}, right}, nil
+	case tLbracket:
+		switch p.current().kind {
+		case tNumber, tColon:
+			index, err := p.index()
+			if err != nil {
+				return nil, err
+			}
+			return p.projectIfSlice(left, index)
+		case tStar:
+			p.advance()
+			if err := p.expect(tRbracket); err != nil {
+				return nil, err
+			}
+			right, err := p.projectionRHS(bindingPowers[tStar])
+			if err != nil {
+				return nil, err
+			}
+			return projection{left, right}, nil
+		}
+		return nil, p.unexpected(p.current(), "expected an index, a slice or '*'")
+	}
+	return nil, p.unexpected(t, "expected an operator")
+}
+
+// index parses an index or slice, after its opening bracket.
+func (p *parser) index() (node, error) {
+	if p.current().kind == tNumber && p.lookahead(1) == tRbracket {
+		n := p.advance().value.(int)
+		p.advance()
+		return index{n}, nil
+	}
+
+	var parts [3]*int
+	for i := 0; ; {
+		switch t := p.current(); t.kind {
+		case tNumber:
+			n := t.value.(int)
+			parts[i] = &n
+			p.advance()
+		case tColon:
+			if i++; i > 2 {
+				return nil, p.unexpected(t, "too many colons in slice")
+			}
+			p.advance()
+		case tRbracket:
+			p.advance()
+			if parts[2] != nil && *parts[2] == 0 {
+				return nil, &SyntaxError{Pos: t.pos, Msg: "slice step cannot be 0"}
+			}
+			return slice{parts[0], parts[1], parts[2]}, nil
+		default:
+			return nil, p.unexpected(t, "expected a number, ':' or ']'")
+		}
+	}
+}
+
+// projectIfSlice applies index to left, projecting what follows over the
+// elements of slices.
+func (p *parser) projectIfSlice(left, index node) (node, error) {
+	e := subexpression{left, index}
+	if _, ok := index.(slice); !ok {
+		return e, nil
+	}
+	right, err := p.projectionRHS(bindingPowers[tStar])
+	if err != nil {
+		return nil, err
+	}
+	return projection{e, right}, nil
+}
+
+// projectionRHS parses the expression applied to each element of a
+// projection, which may be empty.
+func (p *parser) projectionRHS(bp int) (node, error) {
+	switch t := p.current(); {
+	case bindingPowers[t.kind] < projectionStop:
+		return identity{}, nil
+	case t.kind == tLbracket, t.kind == tFilter:
+		return p.expression(bp)
+	case t.kind == tDot:
+		p.advance()
+		return p.dotRHS(bp)
+	default:
+		return nil, p.unexpected(t, "expected '.', '[' or '[?'")
+	}
+}
+
+// dotRHS parses the expression following a dot.
+func (p *parser) dotRHS(bp int) (node, error) {
+	switch t := p.current(); t.kind {
+	case tUnquotedIdentifier, tQuotedIdentifier, tStar:
+		return p.expression(bp)
+	case tLbracket:
+		p.advance()
+		return p.multiSelectList()
+	case tLbrace:
+		p.advance()
+		return p.multiSelectHash()
+	default:
+		return nil, p.unexpected(t, "expected an identifier, '*', '[' or '{'")
+	}
+}
+
+// filter parses a filter projection over left, after its opening "[?".
+func (p *parser) filter(left node) (node, error) {
+	cond, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tRbracket); err != nil {
+		return nil, err
+	}
+	right, err := p.projectionRHS(bindingPowers[tFilter])
+	if err != nil {
+		return nil, err
+	}
+	return filterProjection{left, cond, right}, nil
+}
+
+// multiSelectList parses "[a, b]", after its opening bracket.
+func (p *parser) multiSelectList() (node, error) {
+	var list multiSelectList
+	for {
+		e, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+
+		switch t := p.advance(); t.kind {
+		case tRbracket:
+			return list, nil
+		case tComma:
+		default:
+			return nil, p.unexpected(t, "expected ',' or ']'")
+		}
+	}
+}
+
+// multiSelectHash parses "{key: a, other: b}", after its opening brace.
+func (p *parser) multiSelectHash() (node, error) {
+	var hash multiSelectHash
+	for {
+		key := p.advance()
+		if key.kind != tUnquotedIdentifier && key.kind != tQuotedIdentifier {
+			return nil, p.unexpected(key, "expected a key")
+		}
+		if err := p.expect(tColon); err != nil {
+			return nil, err
+		}
+		e, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		hash = append(hash, keyValue{key.value.(string), e})
+
+		switch t := p.advance(); t.kind {
+		case tRbrace:
+			return hash, nil
+		case tComma:
+		default:
+			return nil, p.unexpected(t, "expected ',' or '}'")
+		}
+	}
+}
+
+// call parses the arguments of a call to the function name, after the
+// opening parenthesis t.
+func (p *parser) call(t token, name string) (node, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unknown function %s()", name)}
+	}
+
+	var args []node
+	for p.current().kind != tRparen {
+		if len(args) > 0 {
+			if err := p.expect(tComma); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.advance()
+
+	if len(args) < len(fn.args) || len(args) > len(fn.args) && !fn.variadic {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("%s() takes %d argument(s), got %d", name, len(fn.args), len(args))}
+	}
+	return call{name, fn, args}, nil
+}
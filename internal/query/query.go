@@ -0,0 +1,86 @@
+// Package query evaluates JMESPath expressions (https://jmespath.org)
+// against JSON documents, such as "results[?error == null].provider" or
+// "{ip: ip, country: results[0].result.country_code}", so that values can be
+// extracted from reports without piping them through jq.
+//
+// The full JMESPath grammar is supported: identifiers, sub-expressions,
+// indexes and slices, list, object and filter projections, flattening,
+// multi-select lists and objects, pipes, boolean and comparison operators,
+// literals and the built-in functions.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// SyntaxError reports an invalid query.
+type SyntaxError struct {
+	// Pos is the byte offset in the query at which the error was found
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid query at %d: %s", e.Pos+1, e.Msg)
+}
+
+// Error reports a query that failed to evaluate, such as a function
+// applied to an argument of the wrong type.
+type Error struct {
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return "query: " + e.Msg
+}
+
+// Query is a compiled JMESPath expression. It is safe for concurrent use.
+type Query struct {
+	src  string
+	root node
+}
+
+// Compile parses a JMESPath expression.
+func Compile(src string) (*Query, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.current(); t.kind != tEOF {
+		return nil, p.unexpected(t, "expected end of query")
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Search evaluates the query against data, a JSON value as decoded by
+// encoding/json into an any.
+func (q *Query) Search(data any) (any, error) {
+	return q.root.search(data)
+}
+
+// SearchJSON evaluates the query against a JSON document.
+func (q *Query) SearchJSON(data []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return q.Search(v)
+}
+
+// equal reports whether two JSON values are equal.
+func equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,145 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+	"ip": "192.0.2.1",
+	"is_anycast": false,
+	"results": [
+		{"provider": "ipinfo", "duration_ms": 120, "result": {"country_code": "US", "city": "Mountain View", "tags": ["a", "b"]}},
+		{"provider": "ip-api", "duration_ms": 80, "result": {"country_code": "US", "city": "Palo Alto", "tags": ["c"]}},
+		{"provider": "ipwhois", "duration_ms": 300, "error": "timeout"}
+	],
+	"quota": {"ipinfo": {"remaining": 10}, "ip-api": {"remaining": 40}},
+	"weird key": 1
+}`
+
+func TestQuery_Search(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"ip", `"192.0.2.1"`},
+		{"missing", `null`},
+		{"ip.nested", `null`},
+		{`"weird key"`, `1`},
+		{"@.ip", `"192.0.2.1"`},
+		{"results[0].provider", `"ipinfo"`},
+		{"results[-1].provider", `"ipwhois"`},
+		{"results[5]", `null`},
+		{"results[*].provider", `["ipinfo", "ip-api", "ipwhois"]`},
+		{"results[].result.city", `["Mountain View", "Palo Alto"]`},
+		{"results[*].result.tags[]", `["a", "b", "c"]`},
+		{"results[*].result.tags", `[["a", "b"], ["c"]]`},
+		{"results[:2].provider", `["ipinfo", "ip-api"]`},
+		{"results[::-1].provider", `["ipwhois", "ip-api", "ipinfo"]`},
+		{"results[1:].duration_ms", `[80, 300]`},
+		{"quota.*.remaining", `[40, 10]`},
+		{"results[?error == null].provider", `["ipinfo", "ip-api"]`},
+		{"results[?duration_ms > `100`].provider", `["ipinfo", "ipwhois"]`},
+		{"results[?result.city == 'Palo Alto'] | [0].provider", `"ip-api"`},
+		{"results[?error].provider | [0]", `"ipwhois"`},
+		{"results[?!error && duration_ms < `100`].provider", `["ip-api"]`},
+		{"results[*].[provider, duration_ms]", `[["ipinfo", 120], ["ip-api", 80], ["ipwhois", 300]]`},
+		{"{ip: ip, first: results[0].provider}", `{"ip": "192.0.2.1", "first": "ipinfo"}`},
+		{"results[*].{p: provider}", `[{"p": "ipinfo"}, {"p": "ip-api"}, {"p": "ipwhois"}]`},
+		{"missing || ip", `"192.0.2.1"`},
+		{"is_anycast || `\"no\"`", `"no"`},
+		{"ip && is_anycast", `false`},
+		{"(results[0].provider)", `"ipinfo"`},
+		{"length(results)", `3`},
+		{"length(ip)", `9`},
+		{"keys(quota)", `["ip-api", "ipinfo"]`},
+		{"sum(results[*].duration_ms)", `500`},
+		{"avg(results[*].duration_ms)", `166.66666666666666`},
+		{"max(results[*].duration_ms)", `300`},
+		{"min_by(results, &duration_ms).provider", `"ip-api"`},
+		{"sort_by(results, &duration_ms)[*].provider", `["ip-api", "ipinfo", "ipwhois"]`},
+		{"sort(results[*].provider)", `["ip-api", "ipinfo", "ipwhois"]`},
+		{"join(', ', results[*].provider)", `"ipinfo, ip-api, ipwhois"`},
+		{"contains(results[*].provider, 'ipinfo')", `true`},
+		{"starts_with(ip, '192.')", `true`},
+		{"map(&provider, results)", `["ipinfo", "ip-api", "ipwhois"]`},
+		{"not_null(missing, ip)", `"192.0.2.1"`},
+		{"to_string(results[0].duration_ms)", `"120"`},
+		{"to_number('42')", `42`},
+		{"type(quota)", `"object"`},
+		{"reverse(ip)", `"1.2.0.291"`},
+		{`'it\'s'`, `"it's"`},
+		{"`[1, 2]`", `[1, 2]`},
+		{"'raw'", `"raw"`},
+	}
+
+	var data any
+	if err := json.Unmarshal([]byte(testDocument), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Compile(tt.query)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := q.Search(data)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+
+			var want any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Search() = %#v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"":                     "unexpected end of query",
+		"results[":             "unexpected end of query",
+		"results[0":            "expected a number, ':' or ']'",
+		"a.":                   "unexpected end of query",
+		"a = b":                "use '=='",
+		"a b":                  "expected end of query",
+		"'open":                "unterminated raw string",
+		"`{bad`":               "invalid JSON literal",
+		"nope(a)":              "unknown function nope()",
+		"length(a, b)":         "takes 1 argument(s), got 2",
+		"\"length\"(a)":        "cannot be quoted",
+		"a[::0]":               "step cannot be 0",
+		"{a b}":                "expected ':'",
+		"#":                    "unexpected character",
+		"'a' 'b'":              "expected end of query",
+		"results[*].provider)": "expected end of query",
+	}
+	for src, want := range tests {
+		_, err := Compile(src)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Compile(%q) error = %v, want a syntax error containing %q", src, err, want)
+		}
+	}
+}
+
+func TestQuery_SearchErrors(t *testing.T) {
+	for _, src := range []string{"length(`1`)", "sum(results[*].provider)", "sort(`[1, \"a\"]`)"} {
+		q, err := Compile(src)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", src, err)
+		}
+		var qerr *Error
+		if _, err := q.SearchJSON([]byte(testDocument)); !errors.As(err, &qerr) {
+			t.Errorf("Search(%q) error = %v, want a query error", src, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+// Open returns a reader streaming source: s3://bucket/key, or an http or
+// https URL. The input is not downloaded first: it is read as it is
+// consumed, resuming where it stopped if the connection fails, and read
+// again from the start by Seek(0, io.SeekStart), which fails if the object
+// has changed since it was opened.
+func Open(source string) (io.ReadSeekCloser, error) {
+	u, err := parseTarget(source)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{url: u.String(), sender: newSender()}
+	if u.Scheme == "s3" {
+		if r.url, r.sender, err = s3Object(u); err != nil {
+			return nil, err
+		}
+	}
+	// A large input takes longer than any request timeout to read: a
+	// connection is instead given up after requestTimeout without data
+	r.sender.client = &http.Client{}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reader streams a remote object from offset, with the validators of the
+// version first read so that it is never read from another.
+type reader struct {
+	sender *sender
+	url    string
+
+	etag         string
+	lastModified string
+
+	body   io.ReadCloser
+	offset int64
+	cancel context.CancelFunc
+	idle   *time.Timer
+}
+
+// open requests the object from offset, attempting it up to attempts
+// times.
+func (r *reader) open() error {
+	r.release()
+
+	header := make(http.Header)
+	if r.offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+	if r.etag != "" {
+		header.Set("If-Match", r.etag)
+	} else if r.lastModified != "" {
+		header.Set("If-Unmodified-Since", r.lastModified)
+	}
+
+	delay := r.sender.backoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		r.idle = time.AfterFunc(requestTimeout, cancel)
+		resp, err := r.sender.do(ctx, http.MethodGet, r.url, nil, header)
+		if err == nil {
+			r.body, r.cancel = resp.Body, cancel
+			return r.start(resp)
+		}
+		r.idle.Stop()
+		cancel()
+
+		var serr provider.StatusError
+		if errors.As(err, &serr) && serr.StatusCode == http.StatusPreconditionFailed {
+			return fmt.Errorf("%s: changed while being read", Redact(r.url))
+		}
+		if attempt == attempts || !transient(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// start records the validators of the first response, and skips to offset
+// in those of servers ignoring the range.
+func (r *reader) start(resp *http.Response) error {
+	if r.etag == "" && r.lastModified == "" {
+		// A weak ETag does not identify the bytes of a version
+		if etag := resp.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+			r.etag = etag
+		}
+		r.lastModified = resp.Header.Get("Last-Modified")
+	}
+
+	if r.offset > 0 && resp.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, r.body, r.offset); err != nil {
+			return fmt.Errorf("%s: %w", Redact(r.url), err)
+		}
+	}
+	return nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.body == nil {
+		return 0, errors.New("read after close")
+	}
+
+	for failures := 0; ; failures++ {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		r.idle.Reset(requestTimeout)
+		switch {
+		case err == nil || err == io.EOF:
+			return n, err
+		case n > 0:
+			// The failure is met again by the next read
+			return n, nil
+		}
+
+		// The connection failed: the rest is requested again
+		if failures == attempts-1 {
+			return 0, fmt.Errorf("%s: %w", Redact(r.url), err)
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Seek rewinds to the start, the only offset supported, so that the input
+// can be read twice.
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errors.New("remote input can only be read again from the start")
+	}
+	r.offset = 0
+	return 0, r.open()
+}
+
+func (r *reader) Close() error {
+	r.release()
+	return nil
+}
+
+// release closes the current response, if any.
+func (r *reader) release() {
+	if r.body == nil {
+		return
+	}
+	r.idle.Stop()
+	_ = r.body.Close()
+	r.cancel()
+	r.body = nil
+}
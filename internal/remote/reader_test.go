@@ -0,0 +1,124 @@
+package remote
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer serves content, versioned by etag, breaking the connection
+// of the first response after breakAfter bytes if set.
+type fakeServer struct {
+	mu         sync.Mutex
+	content    string
+	etag       string
+	breakAfter int
+	ranges     []string
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, etag, breakAfter := s.content, s.etag, s.breakAfter
+	s.breakAfter = 0
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	if breakAfter > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		_, _ = io.WriteString(w, content[:breakAfter])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+}
+
+func TestOpen_HTTP(t *testing.T) {
+	s := &fakeServer{content: strings.Repeat("203.0.113.7\n", 8), etag: `"v1"`, breakAfter: 30}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	r, err := Open(server.URL + "/ips.txt")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	r.(*reader).sender.backoff = 0
+
+	for pass := 1; pass <= 2; pass++ {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("pass %d: ReadAll() error = %v", pass, err)
+		}
+		if string(data) != s.content {
+			t.Errorf("pass %d: read %q, want %q", pass, data, s.content)
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("Seek() error = %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.ranges) < 2 || s.ranges[1] != "bytes=30-" {
+		t.Errorf("ranges requested = %q, want the rest after the broken connection", s.ranges)
+	}
+}
+
+func TestOpen_HTTP_Changed(t *testing.T) {
+	s := &fakeServer{content: "203.0.113.7\n", etag: `"v1"`}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	r, err := Open(server.URL + "/ips.txt?X-Amz-Signature=secret")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	_, _ = io.ReadAll(r)
+
+	s.mu.Lock()
+	s.etag = `"v2"`
+	s.mu.Unlock()
+	_, err = r.Seek(0, io.SeekStart)
+	if err == nil || !strings.Contains(err.Error(), "changed") {
+		t.Fatalf("Seek() error = %v, want the input changed", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Seek() error = %v, reveals the query", err)
+	}
+}
+
+func TestOpen_HTTP_Error(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	if _, err := Open(server.URL + "/ips.txt"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Open() error = %v, want the status", err)
+	}
+}
+
+func TestOpen_S3(t *testing.T) {
+	server := newFakeS3(t)
+	s := server.Config.Handler.(*fakeS3)
+	s.objects["/bucket/lists/ips.txt"] = "8.8.8.8\n1.1.1.1\n"
+
+	r, err := Open("s3://bucket/lists/ips.txt")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "8.8.8.8\n1.1.1.1\n" {
+		t.Errorf("ReadAll() = %q, %v; want the object", data, err)
+	}
+
+	if _, err := Open("s3://bucket/lists/missing.txt"); err == nil || !strings.Contains(err.Error(), "NoSuchKey") {
+		t.Errorf("Open() error = %v, want NoSuchKey", err)
+	}
+}
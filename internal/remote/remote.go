@@ -0,0 +1,199 @@
+// Package remote reads the input of a run from, and writes its output to,
+// object storage or an HTTP server instead of local files, so that
+// scheduled jobs need no intermediate disk step. Output is written to S3,
+// or any service compatible with its API, in a multipart upload as it is
+// produced, or with a single HTTP PUT, as to a presigned URL, once it is
+// complete. Input is streamed from S3 or an HTTP GET.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"api-client/internal/provider"
+)
+
+const (
+	// attempts is how many times a request failing transiently, on a
+	// network error, a 429 or a 5xx status, is sent in all
+	attempts = 4
+
+	// backoff is the wait before the second attempt, doubled before each
+	// further one
+	backoff = time.Second
+
+	// requestTimeout bounds each attempt, the transfer of a part included
+	requestTimeout = 5 * time.Minute
+)
+
+// IsURL reports whether name is a location of this package, an s3://,
+// http:// or https:// URL, rather than a file.
+func IsURL(name string) bool {
+	for _, scheme := range []string{"s3://", "http://", "https://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns target without its query and credentials, which a
+// presigned URL holds, for messages.
+func Redact(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	return redact(u)
+}
+
+// Create returns a writer uploading to target: s3://bucket/key, or an http
+// or https URL the output is PUT to. The upload completes when the writer
+// is closed, and the output is only stored if Close succeeds.
+func Create(target string) (io.WriteCloser, error) {
+	u, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "s3" {
+		return newS3Writer(u)
+	}
+	return &putWriter{url: u.String(), sender: newSender()}, nil
+}
+
+// parseTarget parses an s3, http or https URL.
+func parseTarget(target string) (*url.URL, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q: no host or bucket", target)
+	}
+	switch u.Scheme {
+	case "s3", "http", "https":
+		return u, nil
+	default:
+		return nil, fmt.Errorf("%q: unsupported scheme %q; expected s3, http or https", target, u.Scheme)
+	}
+}
+
+// sender sends requests, retrying those failing transiently.
+type sender struct {
+	client  *http.Client
+	backoff time.Duration
+
+	// sign, when set, signs each attempt of a request with the body
+	sign func(req *http.Request, body []byte)
+}
+
+func newSender() *sender {
+	return &sender{client: &http.Client{Timeout: requestTimeout}, backoff: backoff}
+}
+
+// send sends a request with body, attempting it up to attempts times, and
+// returns the headers and body of the response, which must have a 2xx
+// status.
+func (s *sender) send(method, rawURL string, body []byte) (http.Header, []byte, error) {
+	delay := s.backoff
+	for attempt := 1; ; attempt++ {
+		header, data, err := s.sendOnce(method, rawURL, body)
+		if err == nil || attempt == attempts || !transient(err) {
+			return header, data, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (s *sender) sendOnce(method, rawURL string, body []byte) (http.Header, []byte, error) {
+	resp, err := s.do(context.Background(), method, rawURL, body, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s %s: %w", method, redact(resp.Request.URL), err)
+	}
+	return resp.Header, data, nil
+}
+
+// do sends a request with body and header once, and returns the response
+// if it has a 2xx status, its body left to read.
+func (s *sender) do(ctx context.Context, method, rawURL string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if s.sign != nil {
+		s.sign(req, body)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The URL may hold credentials, as a presigned one does
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return nil, fmt.Errorf("%s %s: %w", method, redact(req.URL), err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer func() { _ = resp.Body.Close() }()
+		data, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("%s %s: %w", method, redact(req.URL), provider.StatusError{StatusCode: resp.StatusCode})
+		if code := s3ErrorCode(data); code != "" {
+			err = fmt.Errorf("%w (%s)", err, code)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// redact returns u without its query and credentials.
+func redact(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
+
+// transient reports whether err is a failure that may not happen again:
+// a network error, a timeout or a status asking to come back later.
+func transient(err error) bool {
+	var serr provider.StatusError
+	if errors.As(err, &serr) {
+		return serr.StatusCode == http.StatusTooManyRequests || serr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var nerr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &nerr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// putWriter holds the output until it is closed, then PUTs it whole: a
+// presigned URL, or a server reading the Content-Length, does not accept
+// a body of unknown length.
+type putWriter struct {
+	url    string
+	sender *sender
+	buf    bytes.Buffer
+}
+
+func (w *putWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *putWriter) Close() error {
+	_, _, err := w.sender.send(http.MethodPut, w.url, w.buf.Bytes())
+	return err
+}
@@ -0,0 +1,210 @@
+package remote
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// partSize is the size of the parts of a multipart upload, above the
+	// minimum of S3, 5 MiB; the output is held in memory a part at a time
+	partSize = 8 << 20
+
+	// defaultRegion is the region of buckets when the environment sets
+	// none.
+	defaultRegion = "us-east-1"
+)
+
+// s3Writer uploads to an S3 object: in a single PUT if the output fits in
+// a part, or else in a multipart upload started with the first part, so
+// that the object only appears once complete.
+type s3Writer struct {
+	sender   *sender
+	url      string
+	partSize int
+
+	buf      []byte
+	uploadID string
+	parts    []completedPart
+	err      error
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// newS3Writer returns an s3Writer of the object named by u, s3://bucket/key.
+func newS3Writer(u *url.URL) (*s3Writer, error) {
+	object, s, err := s3Object(u)
+	if err != nil {
+		return nil, err
+	}
+	if s.sign == nil {
+		return nil, errors.New("uploading to S3 requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return &s3Writer{sender: s, url: object, partSize: partSize}, nil
+}
+
+// s3Object returns the URL of the object named by u, s3://bucket/key, and a
+// sender signing its requests, with the credentials, region and endpoint
+// of the environment, as in the AWS CLI: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN; AWS_REGION or
+// AWS_DEFAULT_REGION; AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL for another
+// service compatible with S3, addressed in path style. Without credentials,
+// requests are sent unsigned, as to a public object.
+func s3Object(u *url.URL) (string, *sender, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return "", nil, fmt.Errorf("%q: no object key", u)
+	}
+
+	creds := credentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = defaultRegion
+	}
+
+	var object string
+	switch endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); {
+	case endpoint != "":
+		object = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + uriEncode(key, false)
+	case strings.Contains(bucket, "."):
+		// Such a bucket does not match the certificate of its virtual host
+		object = "https://s3." + region + ".amazonaws.com/" + bucket + "/" + uriEncode(key, false)
+	default:
+		object = "https://" + bucket + ".s3." + region + ".amazonaws.com/" + uriEncode(key, false)
+	}
+	if _, err := url.Parse(object); err != nil {
+		return "", nil, err
+	}
+
+	s := newSender()
+	if creds.accessKeyID != "" && creds.secretAccessKey != "" {
+		s.sign = func(req *http.Request, body []byte) {
+			signV4(req, creds, region, time.Now(), hashHex(body))
+		}
+	}
+	return object, s, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= w.partSize {
+		if err := w.uploadPart(w.buf[:w.partSize]); err != nil {
+			w.fail(err)
+			return 0, w.err
+		}
+		w.buf = append([]byte(nil), w.buf[w.partSize:]...)
+	}
+	return len(p), nil
+}
+
+// uploadPart uploads the next part, starting the multipart upload first.
+func (w *s3Writer) uploadPart(data []byte) error {
+	if w.uploadID == "" {
+		_, body, err := w.sender.send(http.MethodPost, w.url+"?uploads", nil)
+		if err != nil {
+			return fmt.Errorf("starting the upload: %w", err)
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+			return fmt.Errorf("starting the upload: no upload ID in %q", body)
+		}
+		w.uploadID = result.UploadID
+	}
+
+	number := len(w.parts) + 1
+	header, _, err := w.sender.send(http.MethodPut,
+		fmt.Sprintf("%s?partNumber=%d&uploadId=%s", w.url, number, url.QueryEscape(w.uploadID)), data)
+	if err != nil {
+		return fmt.Errorf("uploading part %d: %w", number, err)
+	}
+	w.parts = append(w.parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	return nil
+}
+
+// fail records err, aborting the multipart upload, if any, so that its
+// parts are not kept, and billed, by the bucket.
+func (w *s3Writer) fail(err error) {
+	w.err = err
+	if w.uploadID != "" {
+		_, _, _ = w.sender.send(http.MethodDelete, w.url+"?uploadId="+url.QueryEscape(w.uploadID), nil)
+	}
+}
+
+// Close uploads the rest of the output and completes the upload.
+func (w *s3Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.uploadID == "" {
+		_, _, err := w.sender.send(http.MethodPut, w.url, w.buf)
+		return err
+	}
+
+	if len(w.buf) > 0 {
+		if err := w.uploadPart(w.buf); err != nil {
+			w.fail(err)
+			return w.err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: w.parts})
+	if err != nil {
+		return err
+	}
+	_, resp, err := w.sender.send(http.MethodPost, w.url+"?uploadId="+url.QueryEscape(w.uploadID), body)
+	if err == nil {
+		// Completing can fail after the status has been sent
+		if code := s3ErrorCode(resp); code != "" {
+			err = fmt.Errorf("%s", code)
+		}
+	}
+	if err != nil {
+		w.fail(fmt.Errorf("completing the upload: %w", err))
+		return w.err
+	}
+	return nil
+}
+
+// s3ErrorCode returns the code of the S3 error response data, if it is one.
+func s3ErrorCode(data []byte) string {
+	var resp struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+	}
+	if xml.Unmarshal(data, &resp) != nil {
+		return ""
+	}
+	return resp.Code
+}
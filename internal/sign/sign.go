@@ -0,0 +1,229 @@
+// Package sign signs JSON reports with an Ed25519 key, embedding the
+// signature, along with the version of ipintel and the time of signing, so
+// that reports kept as incident evidence can later be verified as
+// untampered.
+//
+// The signature covers the canonical form of the report: its JSON with the
+// signature value left out, object keys sorted and no insignificant
+// whitespace, as encoding/json writes it. Reports can thus be indented or
+// reordered without breaking the signature, but not altered.
+package sign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Algorithm is the only signature algorithm, Ed25519.
+const Algorithm = "ed25519"
+
+// SchemaVersion is the version of the report format signed reports declare.
+// It changes whenever fields are renamed or change meaning.
+const SchemaVersion = 1
+
+// Field is the report field the signature is embedded in.
+const Field = "signature"
+
+// ErrUnsigned is returned by Verify for reports without a signature.
+var ErrUnsigned = errors.New("report is not signed")
+
+// Signature is embedded in signed reports. Its keys are single words, so
+// that they read the same in every JSON style.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+
+	// Key is the public key, base64-encoded
+	Key string `json:"key"`
+
+	// Version is that of ipintel
+	Version string `json:"version"`
+
+	// Schema is the SchemaVersion of the report
+	Schema int `json:"schema"`
+
+	Timestamp time.Time `json:"timestamp"`
+
+	// Value is the signature, base64-encoded
+	Value string `json:"value"`
+}
+
+// Signer signs reports.
+type Signer struct {
+	key     ed25519.PrivateKey
+	version string
+	now     func() time.Time
+}
+
+// NewSigner returns a Signer signing with key as the given version of
+// ipintel.
+func NewSigner(key ed25519.PrivateKey, version string) *Signer {
+	return &Signer{key: key, version: version, now: time.Now}
+}
+
+// Sign embeds a signature into report, a JSON object, under Field, and
+// returns it in compact form.
+func (s *Signer) Sign(report []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, report); err != nil {
+		return nil, err
+	}
+	doc := compact.Bytes()
+	if len(doc) < 2 || doc[0] != '{' || doc[len(doc)-1] != '}' {
+		return nil, errors.New("only JSON objects can be signed")
+	}
+
+	sig := Signature{
+		Algorithm: Algorithm,
+		Key:       base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+		Version:   s.version,
+		Schema:    SchemaVersion,
+		Timestamp: s.now().UTC(),
+	}
+
+	unsigned, err := embed(doc, sig)
+	if err != nil {
+		return nil, err
+	}
+	message, err := canonical(unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	sig.Value = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, message))
+	return embed(doc, sig)
+}
+
+// embed appends sig to doc, a compact JSON object without it.
+func embed(doc []byte, sig Signature) ([]byte, error) {
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(doc)+len(Field)+len(data)+4)
+	out = append(out, doc[:len(doc)-1]...)
+	if len(doc) > 2 {
+		out = append(out, ',')
+	}
+	out = append(out, `"`+Field+`":`...)
+	out = append(out, data...)
+	return append(out, '}'), nil
+}
+
+// Verify checks the signature embedded in report against the public key it
+// names, and returns it. Whether that key is trusted is up to the caller.
+func Verify(report []byte) (Signature, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(report, &fields); err != nil {
+		return Signature{}, err
+	}
+	raw, ok := fields[Field]
+	if !ok {
+		return Signature{}, ErrUnsigned
+	}
+
+	var sig Signature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return Signature{}, fmt.Errorf("invalid signature: %w", err)
+	}
+	if sig.Algorithm != Algorithm {
+		return sig, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	key, err := ParsePublicKey([]byte(sig.Key))
+	if err != nil {
+		return sig, err
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil || len(value) != ed25519.SignatureSize {
+		return sig, errors.New("invalid signature: malformed value")
+	}
+
+	message, err := canonical(report)
+	if err != nil {
+		return sig, err
+	}
+	if !ed25519.Verify(key, message, value) {
+		return sig, errors.New("signature mismatch: the report was modified after it was signed")
+	}
+	return sig, nil
+}
+
+// canonical returns the form of report that is signed: with the value of
+// its signature removed, its keys sorted and no insignificant whitespace.
+func canonical(report []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(report))
+	// Numbers are kept as written rather than rounded through float64
+	dec.UseNumber()
+
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after the report")
+	}
+	if sig, ok := doc[Field].(map[string]any); ok {
+		delete(sig, "value")
+	}
+	return json.Marshal(doc)
+}
+
+// LoadPrivateKey reads an Ed25519 private key from a PEM file in PKCS #8
+// form, as written by "openssl genpkey -algorithm ed25519".
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: expected a PEM \"PRIVATE KEY\" block", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// ParsePublicKey decodes an Ed25519 public key, either base64-encoded or
+// a PEM "PUBLIC KEY" block, as written by "openssl pkey -pubout".
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "PUBLIC KEY" {
+			return nil, fmt.Errorf("invalid public key: unexpected PEM %q block", block.Type)
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("invalid public key: not an Ed25519 key")
+		}
+		return pub, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
@@ -0,0 +1,144 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{7}, ed25519.SeedSize))
+
+const testReport = `{"ip": "8.8.8.8", "results": [{"provider": "ipinfo", "result": {"country_code": "US", "latitude": 37.386000000000003}}], "total_duration_ms": 120}`
+
+func newTestSigner() *Signer {
+	s := NewSigner(testKey, "1.2.3")
+	s.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	return s
+}
+
+func TestSign_Verify(t *testing.T) {
+	signed, err := newTestSigner().Sign([]byte(testReport))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if !bytes.HasPrefix(signed, []byte(`{"ip":"8.8.8.8","results":`)) {
+		t.Errorf("Sign() should keep the report as is, got %s", signed)
+	}
+
+	sig, err := Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if sig.Version != "1.2.3" || sig.Schema != SchemaVersion || !sig.Timestamp.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Verify() = %+v", sig)
+	}
+	if sig.Key != base64.StdEncoding.EncodeToString(testKey.Public().(ed25519.PublicKey)) {
+		t.Errorf("Key = %q, want the signer's public key", sig.Key)
+	}
+
+	// Reformatting does not break the signature
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, signed, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(indented.Bytes()); err != nil {
+		t.Errorf("Verify() of the indented report error = %v", err)
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	signed, err := newTestSigner().Sign([]byte(testReport))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	for _, tt := range []struct{ name, old, new string }{
+		{"field", `"country_code":"US"`, `"country_code":"FR"`},
+		{"number precision", `37.386000000000003`, `37.386`},
+		{"version", `"version":"1.2.3"`, `"version":"1.2.4"`},
+		{"timestamp", `2024-05-01`, `2024-05-02`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := strings.Replace(string(signed), tt.old, tt.new, 1)
+			if tampered == string(signed) {
+				t.Fatalf("%q not found in %s", tt.old, signed)
+			}
+			if _, err := Verify([]byte(tampered)); err == nil || !strings.Contains(err.Error(), "signature mismatch") {
+				t.Errorf("Verify() error = %v, want a mismatch", err)
+			}
+		})
+	}
+}
+
+func TestVerify_Errors(t *testing.T) {
+	if _, err := Verify([]byte(testReport)); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Verify() of an unsigned report error = %v, want ErrUnsigned", err)
+	}
+
+	signed, _ := newTestSigner().Sign([]byte(testReport))
+	other := strings.Replace(string(signed), `"algorithm":"ed25519"`, `"algorithm":"rsa"`, 1)
+	if _, err := Verify([]byte(other)); err == nil || !strings.Contains(err.Error(), "unsupported signature algorithm") {
+		t.Errorf("Verify() error = %v, want an unsupported algorithm", err)
+	}
+
+	if _, err := newTestSigner().Sign([]byte(`["8.8.8.8"]`)); err == nil {
+		t.Error("Sign() of an array expected error")
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	der, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ipintel.key")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := LoadPrivateKey(path)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey() error = %v", err)
+	}
+	if !key.Equal(testKey) {
+		t.Error("LoadPrivateKey() returned another key")
+	}
+
+	if err := os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPrivateKey(path); err == nil {
+		t.Error("LoadPrivateKey() expected error")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub := testKey.Public().(ed25519.PublicKey)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"pem":    pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		"base64": []byte(base64.StdEncoding.EncodeToString(pub) + "\n"),
+	} {
+		key, err := ParsePublicKey(data)
+		if err != nil || !key.Equal(pub) {
+			t.Errorf("ParsePublicKey(%s) = %v, %v", name, key, err)
+		}
+	}
+
+	if _, err := ParsePublicKey([]byte("c2hvcnQ=")); err == nil {
+		t.Error("ParsePublicKey() of a short key expected error")
+	}
+}
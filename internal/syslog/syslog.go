@@ -0,0 +1,173 @@
+// Package syslog sends RFC 5424 messages to a syslog server over UDP, TCP
+// or TLS, for environments collecting everything through syslog. Unlike
+// log/syslog, it works on every platform and speaks the structured,
+// timestamped format of RFC 5424, framed by octet counting on streams as
+// RFC 5425 and 6587 describe.
+package syslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity is the severity of a message.
+type Severity int
+
+// Severities, from the most to the least severe.
+const (
+	Emergency Severity = iota
+	Alert
+	Critical
+	Error
+	Warning
+	Notice
+	Info
+	Debug
+)
+
+// facilities are the facilities of messages, by name.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+const (
+	// DefaultFacility is that of the messages of a URL setting none.
+	DefaultFacility = "user"
+
+	// appName identifies the messages of ipintel.
+	appName = "ipintel"
+
+	// timeout bounds connecting and sending each message.
+	timeout = 10 * time.Second
+)
+
+// Writer sends messages to a syslog server. It is safe for concurrent use.
+type Writer struct {
+	network  string // "udp", "tcp" or "tls"
+	addr     string
+	facility int
+	hostname string
+	tls      *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Dial connects to the server of target: udp://host[:port],
+// tcp://host[:port] (port 514 by default) or tls://host[:port] (port 6514
+// by default), with the facility of ?facility=, such as local0, if set.
+func Dial(target string) (*Writer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%q: no host", target)
+	}
+
+	w := &Writer{network: u.Scheme, facility: facilities[DefaultFacility], hostname: "-"}
+	port := "514"
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		port = "6514"
+		w.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("%q: unsupported scheme %q; expected udp, tcp or tls", target, u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	w.addr = net.JoinHostPort(u.Hostname(), port)
+
+	if name := u.Query().Get("facility"); name != "" {
+		facility, ok := facilities[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("%q: unknown facility %q", target, name)
+		}
+		w.facility = facility
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		w.hostname = hostname
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) connect() error {
+	dialer := &net.Dialer{Timeout: timeout}
+	var err error
+	switch w.network {
+	case "tls":
+		w.conn, err = tls.DialWithDialer(dialer, "tcp", w.addr, w.tls)
+	default:
+		w.conn, err = dialer.Dial(w.network, w.addr)
+	}
+	return err
+}
+
+// Send sends msg with severity, and msgID, which identifies the type of
+// the message, or "-". A stream connection failing is opened again once.
+func (w *Writer) Send(severity Severity, msgID, msg string) error {
+	return w.SendAt(time.Now(), severity, msgID, msg)
+}
+
+// SendAt sends msg as Send does, timestamped with t.
+func (w *Writer) SendAt(t time.Time, severity Severity, msgID, msg string) error {
+	if msgID == "" {
+		msgID = "-"
+	}
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		w.facility*8+int(severity), t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		w.hostname, appName, os.Getpid(), msgID, msg)
+
+	// Messages on a stream are preceded by their length
+	frame := []byte(line)
+	if w.network != "udp" {
+		frame = append([]byte(strconv.Itoa(len(line))+" "), line...)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for attempt := 1; ; attempt++ {
+		if w.conn == nil {
+			if err := w.connect(); err != nil {
+				return err
+			}
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(timeout))
+		_, err := w.conn.Write(frame)
+		if err == nil || w.network == "udp" || attempt == 2 {
+			return err
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// Close closes the connection.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
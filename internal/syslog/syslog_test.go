@@ -0,0 +1,108 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter_Send_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	w, err := Dial("udp://" + conn.LocalAddr().String() + "?facility=local0")
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer func() { _ = w.Close() }()
+	at := time.Date(2024, 1, 15, 10, 30, 0, 123456000, time.UTC)
+	if err := w.SendAt(at, Warning, "alert", `{"ip":"8.8.8.8"}`); err != nil {
+		t.Fatalf("SendAt() error = %v", err)
+	}
+
+	buf := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0 is 16: 16*8 + 4
+	want := regexp.MustCompile(`^<132>1 2024-01-15T10:30:00\.123456Z \S+ ipintel ` + strconv.Itoa(os.Getpid()) + ` alert - \{"ip":"8\.8\.8\.8"\}$`)
+	if !want.Match(buf[:n]) {
+		t.Errorf("message = %q", buf[:n])
+	}
+}
+
+func TestWriter_Send_TCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		for {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	w, err := Dial("tcp://" + l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer func() { _ = w.Close() }()
+	for _, msg := range []string{"first", "second message"} {
+		if err := w.Send(Info, "", msg); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	for _, want := range []string{"first", "second message"} {
+		select {
+		case msg := <-received:
+			// user is 1: 1*8 + 6
+			if !strings.HasPrefix(msg, "<14>1 ") || !strings.HasSuffix(msg, " - - "+want) {
+				t.Errorf("message = %q, want %q framed by its length", msg, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message received")
+		}
+	}
+}
+
+func TestDial_Invalid(t *testing.T) {
+	for _, target := range []string{
+		"localhost:514",
+		"udp://",
+		"http://localhost",
+		"udp://localhost?facility=nope",
+	} {
+		if _, err := Dial(target); err == nil {
+			t.Errorf("Dial(%q) expected error", target)
+		}
+	}
+}
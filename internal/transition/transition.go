@@ -0,0 +1,102 @@
+// Package transition recognises IPv6 addresses that embed an IPv4 address,
+// whose own geolocation is usually meaningless, and can look up the
+// embedded address instead.
+package transition
+
+import (
+	"context"
+	"net/netip"
+
+	"api-client/internal/model"
+)
+
+// Transition mechanisms recognised by Detect.
+const (
+	SixToFour  = "6to4"
+	Teredo     = "teredo"
+	IPv4Mapped = "ipv4-mapped"
+	NAT64      = "nat64"
+)
+
+var (
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+	teredoPrefix    = netip.MustParsePrefix("2001::/32")
+	// nat64Prefix is the well-known NAT64/DNS64 prefix of RFC 6052
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+// Detect reports the transition mechanism and embedded IPv4 address of ip,
+// if it has one.
+func Detect(ip model.IPAddress) (model.Transition, bool) {
+	if ip.Is4In6() {
+		return model.Transition{Mechanism: IPv4Mapped, IPv4: ip.Unmap()}, true
+	}
+
+	if !ip.Is6() {
+		return model.Transition{}, false
+	}
+
+	b := ip.As16()
+	switch {
+	case sixToFourPrefix.Contains(ip):
+		// 2002:AABB:CCDD::/48 embeds AA.BB.CC.DD
+		return model.Transition{
+			Mechanism: SixToFour,
+			IPv4:      netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}),
+		}, true
+	case nat64Prefix.Contains(ip):
+		// 64:ff9b::AABB:CCDD embeds AA.BB.CC.DD
+		return model.Transition{
+			Mechanism: NAT64,
+			IPv4:      netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}),
+		}, true
+	case teredoPrefix.Contains(ip):
+		// The client's public address is stored inverted in the last 32 bits
+		return model.Transition{
+			Mechanism: Teredo,
+			IPv4:      netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}),
+		}, true
+	}
+
+	return model.Transition{}, false
+}
+
+// Looker performs a lookup for a single address.
+type Looker interface {
+	Lookup(ctx context.Context, ip model.IPAddress) model.Report
+}
+
+// Resolver decorates a Looker, recording the transition mechanism of each
+// address in its report.
+type Resolver struct {
+	looker         Looker
+	lookupEmbedded bool
+}
+
+// NewResolver wraps looker. When lookupEmbedded is set, the embedded IPv4
+// address is looked up in place of the IPv6 address; the report still
+// names the address that was asked for. NAT64 addresses are always looked
+// up by their IPv4 address, since the IPv6 address only names a translator.
+func NewResolver(looker Looker, lookupEmbedded bool) *Resolver {
+	return &Resolver{looker: looker, lookupEmbedded: lookupEmbedded}
+}
+
+// Lookup implements Looker.
+func (r *Resolver) Lookup(ctx context.Context, ip model.IPAddress) model.Report {
+	t, ok := Detect(ip)
+	if !ok {
+		return r.looker.Lookup(ctx, ip)
+	}
+
+	target := ip
+	if r.lookupEmbedded || t.Mechanism == NAT64 {
+		target = t.IPv4
+		t.LookedUp = true
+	}
+
+	report := r.looker.Lookup(ctx, target)
+	report.IP = ip
+	report.Transition = &t
+	report.Recompute()
+	return report
+}
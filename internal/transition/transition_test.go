@@ -0,0 +1,113 @@
+package transition
+
+import (
+	"context"
+	"testing"
+
+	"api-client/internal/model"
+)
+
+// lookerFunc adapts a function to the Looker interface.
+type lookerFunc func(ctx context.Context, ip model.IPAddress) model.Report
+
+func (f lookerFunc) Lookup(ctx context.Context, ip model.IPAddress) model.Report {
+	return f(ctx, ip)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		ip            string
+		wantMechanism string
+		wantIPv4      string
+	}{
+		{"2002:c000:0204::1", SixToFour, "192.0.2.4"},
+		{"2002:0808:0808:1::", SixToFour, "8.8.8.8"},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", Teredo, "192.0.2.45"},
+		{"::ffff:8.8.4.4", IPv4Mapped, "8.8.4.4"},
+		{"64:ff9b::808:808", NAT64, "8.8.8.8"},
+		{"64:ff9b::192.0.2.33", NAT64, "192.0.2.33"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, ok := Detect(model.MustParseAddr(tt.ip))
+			if !ok {
+				t.Fatalf("Detect(%s) found no transition mechanism", tt.ip)
+			}
+
+			if got.Mechanism != tt.wantMechanism {
+				t.Errorf("Mechanism = %q, want %q", got.Mechanism, tt.wantMechanism)
+			}
+
+			if got.IPv4 != model.MustParseAddr(tt.wantIPv4) {
+				t.Errorf("IPv4 = %v, want %v", got.IPv4, tt.wantIPv4)
+			}
+		})
+	}
+}
+
+func TestDetect_None(t *testing.T) {
+	for _, ip := range []string{"8.8.8.8", "2001:4860:4860::8888", "::1", "2001:db8::1", "64:ff9b:1::808:808"} {
+		if _, ok := Detect(model.MustParseAddr(ip)); ok {
+			t.Errorf("Detect(%s) reported a transition mechanism", ip)
+		}
+	}
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	var looked model.IPAddress
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		looked = ip
+		return model.Report{IP: ip}
+	})
+
+	ip := model.MustParseAddr("2002:0808:0808::1")
+
+	report := NewResolver(looker, false).Lookup(context.Background(), ip)
+	if looked != ip {
+		t.Errorf("looked up %v, want the IPv6 address by default", looked)
+	}
+	if report.Transition == nil || report.Transition.LookedUp {
+		t.Errorf("Transition = %+v, want the mechanism recorded without lookup", report.Transition)
+	}
+
+	report = NewResolver(looker, true).Lookup(context.Background(), ip)
+	if looked != model.MustParseAddr("8.8.8.8") {
+		t.Errorf("looked up %v, want the embedded 8.8.8.8", looked)
+	}
+	if report.IP != ip {
+		t.Errorf("report.IP = %v, want the requested %v", report.IP, ip)
+	}
+	if report.Transition == nil || !report.Transition.LookedUp {
+		t.Errorf("Transition = %+v, want LookedUp", report.Transition)
+	}
+}
+
+func TestResolver_Lookup_PlainAddress(t *testing.T) {
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		return model.Report{IP: ip}
+	})
+
+	report := NewResolver(looker, true).Lookup(context.Background(), model.MustParseAddr("1.1.1.1"))
+	if report.Transition != nil {
+		t.Errorf("Transition = %+v, want nil", report.Transition)
+	}
+}
+
+func TestResolver_Lookup_NAT64(t *testing.T) {
+	var looked model.IPAddress
+	looker := lookerFunc(func(ctx context.Context, ip model.IPAddress) model.Report {
+		looked = ip
+		return model.Report{IP: ip}
+	})
+
+	ip := model.MustParseAddr("64:ff9b::1.1.1.1")
+	report := NewResolver(looker, false).Lookup(context.Background(), ip)
+
+	if looked != model.MustParseAddr("1.1.1.1") {
+		t.Errorf("looked up %v, want the translated 1.1.1.1 without --lookup-embedded", looked)
+	}
+	if report.Transition == nil || report.Transition.Mechanism != NAT64 || !report.Transition.LookedUp {
+		t.Errorf("Transition = %+v, want a NAT64 translation that was looked up", report.Transition)
+	}
+}
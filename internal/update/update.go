@@ -0,0 +1,325 @@
+// Package update finds the latest ipintel release on GitHub, downloads the
+// binary for the running platform, verifies it against the release
+// checksums and replaces the running executable with it.
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"api-client/internal/provider"
+)
+
+const (
+	// DefaultBaseURL is the GitHub API.
+	DefaultBaseURL = "https://api.github.com"
+
+	// Repository is the GitHub repository releases are published to.
+	Repository = "lotabytes/api-client"
+
+	// ChecksumsAsset lists the SHA-256 of every binary of a release, one
+	// "<hex>  <name>" line each, and ChecksumsAsset+".sig" holds its
+	// Ed25519 signature.
+	ChecksumsAsset = "checksums.txt"
+
+	// maxBinarySize bounds downloads, as a guard against runaway responses.
+	maxBinarySize = 128 << 20
+)
+
+// ErrNoSignature is returned by Download when the release is not signed
+// but a public key was given.
+var ErrNoSignature = errors.New("release is not signed")
+
+// Release is a published release.
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Version returns the release version, without its "v" prefix.
+func (r Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+func (r Release) asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Client queries and downloads releases.
+type Client struct {
+	baseURL   string
+	requester provider.HttpRequester
+}
+
+// NewClient returns a Client using requester, against DefaultBaseURL
+// unless baseURL is set.
+func NewClient(requester provider.HttpRequester, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), requester: requester}
+}
+
+// Latest returns the latest release, excluding drafts and pre-releases.
+func (c *Client) Latest(ctx context.Context) (Release, error) {
+	data, err := c.fetch(ctx, c.baseURL+"/repos/"+Repository+"/releases/latest", 1<<20)
+	if err != nil {
+		return Release{}, fmt.Errorf("checking the latest release: %w", err)
+	}
+
+	var r Release
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Release{}, fmt.Errorf("parsing the latest release: %w", err)
+	}
+	if r.Tag == "" {
+		return Release{}, errors.New("latest release has no tag")
+	}
+	return r, nil
+}
+
+// Download returns the binary of r for the running platform, once its
+// SHA-256 matches the release checksums. When publicKey is set, the
+// checksums must also carry a valid signature by it.
+func (c *Client) Download(ctx context.Context, r Release, publicKey ed25519.PublicKey) ([]byte, error) {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	bin, ok := r.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no binary for %s/%s", r.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := r.asset(ChecksumsAsset)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s", r.Tag, ChecksumsAsset)
+	}
+
+	checksums, err := c.fetch(ctx, sums.URL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", ChecksumsAsset, err)
+	}
+
+	if publicKey != nil {
+		sig, ok := r.asset(ChecksumsAsset + ".sig")
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", r.Tag, ErrNoSignature)
+		}
+		data, err := c.fetch(ctx, sig.URL, 4<<10)
+		if err != nil {
+			return nil, fmt.Errorf("downloading the signature: %w", err)
+		}
+		if err := verifySignature(publicKey, checksums, data); err != nil {
+			return nil, err
+		}
+	}
+
+	want, err := checksumFor(checksums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := c.fetch(ctx, bin.URL, maxBinarySize)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", name, err)
+	}
+
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return nil, fmt.Errorf("%s: checksum mismatch: got %s, want %s", name, got, want)
+	}
+	return data, nil
+}
+
+func (c *Client) fetch(ctx context.Context, u string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.requester.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, provider.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// AssetName is the name of the release binary for a platform, e.g.
+// "ipintel_linux_amd64" or "ipintel_windows_amd64.exe".
+func AssetName(goos, goarch string) string {
+	name := "ipintel_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// verifySignature checks sig, raw or base64, over checksums.
+func verifySignature(publicKey ed25519.PublicKey, checksums, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil {
+			return fmt.Errorf("invalid signature: %w", err)
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(publicKey, checksums, sig) {
+		return fmt.Errorf("%s: signature verification failed", ChecksumsAsset)
+	}
+	return nil
+}
+
+// checksumFor returns the checksum listed for name.
+func checksumFor(checksums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		// sha256sum marks binary mode with a '*' before the name
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("%s lists no checksum for %s", ChecksumsAsset, name)
+}
+
+// Newer reports whether version latest is more recent than current. Both
+// are dotted versions with an optional "v" prefix; a development build,
+// or any version that does not parse, is never considered out of date.
+func Newer(current, latest string) bool {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	lat, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+
+	for i := 0; i < max(len(cur), len(lat)); i++ {
+		var a, b int
+		if i < len(cur) {
+			a = cur[i]
+		}
+		if i < len(lat) {
+			b = lat[i]
+		}
+		if a != b {
+			return b > a
+		}
+	}
+	return false
+}
+
+// IsRelease reports whether version is a release version rather than a
+// development build such as "dev".
+func IsRelease(version string) bool {
+	_, ok := parseVersion(version)
+	return ok
+}
+
+// parseVersion splits "v1.2.3" into its numbers, ignoring any pre-release
+// or build suffix.
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
+
+// Replace atomically replaces the executable at path with data, keeping
+// its permissions. On Windows, where a running executable cannot be
+// overwritten, the old one is first moved aside to path+".old".
+func Replace(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot write next to %s: %w", path, err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(f.Name(), path)
+}
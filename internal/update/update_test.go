@@ -0,0 +1,163 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// release serves a latest release with the given binary, checksums and,
+// if sig is set, signature.
+func release(t *testing.T, binary, checksums, sig string) *Client {
+	t.Helper()
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/" + Repository + "/releases/latest":
+			assets := `{"name":"` + name + `","browser_download_url":"` + server.URL + `/bin"},` +
+				`{"name":"checksums.txt","browser_download_url":"` + server.URL + `/sums"}`
+			if sig != "" {
+				assets += `,{"name":"checksums.txt.sig","browser_download_url":"` + server.URL + `/sig"}`
+			}
+			_, _ = w.Write([]byte(`{"tag_name":"v1.4.0","html_url":"https://example.com/v1.4.0","assets":[` + assets + `]}`))
+		case "/bin":
+			_, _ = w.Write([]byte(binary))
+		case "/sums":
+			_, _ = w.Write([]byte(checksums))
+		case "/sig":
+			_, _ = w.Write([]byte(sig))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(http.DefaultClient, server.URL)
+}
+
+func checksums(binary string) string {
+	sum := sha256.Sum256([]byte(binary))
+	return hex.EncodeToString(sum[:]) + "  " + AssetName(runtime.GOOS, runtime.GOARCH) + "\n" +
+		strings.Repeat("0", 64) + "  ipintel_plan9_386\n"
+}
+
+func TestClient_Latest(t *testing.T) {
+	c := release(t, "", "", "")
+
+	r, err := c.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if r.Version() != "1.4.0" || len(r.Assets) != 2 {
+		t.Errorf("Latest() = %+v", r)
+	}
+}
+
+func TestClient_Download(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const binary = "new binary"
+	sums := checksums(binary)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(sums)))
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name      string
+		binary    string
+		sig       string
+		publicKey ed25519.PublicKey
+		wantErr   string
+	}{
+		{name: "checksum only", binary: binary},
+		{name: "signed", binary: binary, sig: sig, publicKey: pub},
+		{name: "tampered binary", binary: "evil binary", sig: sig, publicKey: pub, wantErr: "checksum mismatch"},
+		{name: "wrong key", binary: binary, sig: sig, publicKey: otherPub, wantErr: "signature verification failed"},
+		{name: "unsigned", binary: binary, publicKey: pub, wantErr: ErrNoSignature.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := release(t, tt.binary, sums, tt.sig)
+			r, err := c.Latest(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := c.Download(context.Background(), r, tt.publicKey)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Download() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if string(data) != binary {
+				t.Errorf("Download() = %q, want %q", data, binary)
+			}
+		})
+	}
+}
+
+func TestClient_Download_NoBinaryForPlatform(t *testing.T) {
+	c := NewClient(http.DefaultClient, "")
+	_, err := c.Download(context.Background(), Release{Tag: "v1.4.0"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no binary for") {
+		t.Errorf("Download() error = %v", err)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"1.2.3", "1.2.4", true},
+		{"v1.2.3", "v1.10.0", true},
+		{"1.2", "1.2.1", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.3.0", "1.2.9", false},
+		{"1.2.3-rc.1", "1.2.3", false},
+		{"dev", "1.2.3", false},
+		{"1.2.3", "nightly", false},
+	}
+
+	for _, tt := range tests {
+		if got := Newer(tt.current, tt.latest); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipintel")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []byte("new")); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Fatalf("replaced file = %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("mode = %v, %v; want 0755", info.Mode().Perm(), err)
+	}
+}
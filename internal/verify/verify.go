@@ -0,0 +1,258 @@
+// Package verify checks reports read back from JSON, such as those kept as
+// incident evidence: that their signature is valid and made with a trusted
+// key, that their schema version is understood, and that they are
+// internally consistent, their derived fields matching their results.
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+
+	"api-client/internal/model"
+	"api-client/internal/sign"
+)
+
+// Options controls the checks.
+type Options struct {
+	// Key is the public key reports must be signed with. When nil, any
+	// key is accepted, which only proves that the report is intact.
+	Key ed25519.PublicKey
+
+	// AllowUnsigned checks the consistency of unsigned reports rather than
+	// failing them.
+	AllowUnsigned bool
+}
+
+// Result is the outcome of verifying a report.
+type Result struct {
+	// IP is the address of the report, if it could be read
+	IP string
+
+	// Signature is the signature of the report, nil when unsigned
+	Signature *sign.Signature
+
+	// Problems lists the checks that failed
+	Problems []string
+}
+
+// OK reports whether every check passed.
+func (r Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Result) problem(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// Check verifies data, a JSON report in either key style.
+func Check(data []byte, opts Options) Result {
+	var res Result
+
+	sig, err := sign.Verify(data)
+	switch {
+	case errors.Is(err, sign.ErrUnsigned):
+		if !opts.AllowUnsigned {
+			res.problem("not signed")
+		}
+	case err != nil:
+		res.problem("%v", err)
+	default:
+		res.Signature = &sig
+		if opts.Key != nil && sig.Key != base64.StdEncoding.EncodeToString(opts.Key) {
+			res.problem("signed with an untrusted key %s", sig.Key)
+		}
+		if sig.Schema != sign.SchemaVersion {
+			res.problem("unsupported schema version %d; this version of ipintel reads version %d", sig.Schema, sign.SchemaVersion)
+		}
+	}
+
+	data, err = snakeKeys(data)
+	if err != nil {
+		res.problem("invalid JSON: %v", err)
+		return res
+	}
+
+	var report model.Report
+	var derived struct {
+		Quota       map[string]model.Quota `json:"quota"`
+		Granularity model.Granularity      `json:"granularity"`
+		Risk        *model.Risk            `json:"risk"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		res.problem("not a report: %v", err)
+		return res
+	}
+	if err := json.Unmarshal(data, &derived); err != nil {
+		res.problem("not a report: %v", err)
+		return res
+	}
+	if !report.IP.IsValid() {
+		res.problem("no ip")
+		return res
+	}
+	res.IP = report.IP.String()
+
+	if res.Signature != nil && report.Meta != nil && report.Meta.Version != res.Signature.Version {
+		res.problem("made by version %s but signed by version %s", report.Meta.Version, res.Signature.Version)
+	}
+	checkResults(&res, report)
+	checkDerived(&res, report, derived.Quota, derived.Granularity, derived.Risk)
+	return res
+}
+
+// checkResults checks that each provider answered once, with either a
+// result for the address looked up or an error.
+func checkResults(res *Result, report model.Report) {
+	target := report.IP
+	if t := report.Transition; t != nil && t.LookedUp {
+		target = t.IPv4
+	}
+
+	seen := make(map[string]bool, len(report.Results))
+	for _, pr := range report.Results {
+		if seen[pr.Provider] {
+			res.problem("provider %s answered more than once", pr.Provider)
+		}
+		seen[pr.Provider] = true
+
+		switch {
+		case pr.Result != nil && pr.Error != "":
+			res.problem("provider %s has both a result and an error", pr.Provider)
+		case pr.Result == nil && pr.Error == "":
+			res.problem("provider %s has neither a result nor an error", pr.Provider)
+		case pr.Result != nil && pr.Result.IP.IsValid() && pr.Result.IP != target:
+			res.problem("the result of provider %s is for %s, not %s", pr.Provider, pr.Result.IP, target)
+		}
+	}
+}
+
+// checkDerived checks that the fields derived from the results, as they
+// appear in the report, are those the results yield.
+func checkDerived(res *Result, report model.Report, quota map[string]model.Quota, granularity model.Granularity, risk *model.Risk) {
+	if want := report.Quota(); len(want) != 0 || len(quota) != 0 {
+		for name, q := range want {
+			if got, ok := quota[name]; !ok || got != q {
+				res.problem("quota of provider %s does not match its result", name)
+			}
+		}
+		for name := range quota {
+			if _, ok := want[name]; !ok {
+				res.problem("quota of provider %s does not match its result", name)
+			}
+		}
+	}
+
+	// The weights of the reputation sources are not part of the report
+	// but of each contribution
+	opts := model.ConsensusOptions{}
+	if report.Meta != nil {
+		opts.MinAgreement = report.Meta.MinAgreement
+		opts.Language = report.Meta.Language
+	}
+	if risk != nil {
+		opts.Risk.Weights = make(map[string]float64, len(risk.Contributions))
+		for _, c := range risk.Contributions {
+			opts.Risk.Weights[c.Provider] = c.Weight
+		}
+	}
+	report.SetConsensusOptions(opts)
+
+	if opts.MinAgreement > 0 {
+		if want := report.Consensus().Granularity; granularity != want {
+			res.problem("granularity is %q, but the results yield %q", granularity, want)
+		}
+	}
+
+	want := report.Risk()
+	switch {
+	case want == nil && risk != nil:
+		res.problem("risk is reported, but no result has a reputation")
+	case want != nil && risk == nil:
+		res.problem("risk is missing, but results have a reputation")
+	case want != nil:
+		if !equalContributions(want.Contributions, risk.Contributions) {
+			res.problem("risk contributions do not match the reputations of the results")
+		} else if !near(want.Score, risk.Score) {
+			res.problem("risk score is %g, but the results yield %g", risk.Score, want.Score)
+		}
+	}
+}
+
+func equalContributions(a, b []model.RiskContribution) bool {
+	return slices.EqualFunc(a, b, func(x, y model.RiskContribution) bool {
+		return x.Provider == y.Provider && near(x.Score, y.Score) && near(x.Weight, y.Weight) && near(x.Contribution, y.Contribution)
+	})
+}
+
+// near compares numbers that went through a JSON round trip.
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(a))
+}
+
+// snakeKeys re-encodes data with camelCase keys, as written with
+// --json-style camel, in snake_case, except those of the passthrough input
+// fields.
+func snakeKeys(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	doc, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("not a JSON object")
+	}
+	renamed := make(map[string]any, len(doc))
+	for k, val := range doc {
+		if k == "input" {
+			renamed[k] = val
+			continue
+		}
+		renamed[camelToSnake(k)] = renameKeys(val)
+	}
+	return json.Marshal(renamed)
+}
+
+func renameKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		renamed := make(map[string]any, len(v))
+		for k, val := range v {
+			renamed[camelToSnake(k)] = renameKeys(val)
+		}
+		return renamed
+	case []any:
+		for i, val := range v {
+			v[i] = renameKeys(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// camelToSnake converts a key such as "totalDurationMs" to
+// "total_duration_ms".
+func camelToSnake(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,221 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"api-client/internal/model"
+	"api-client/internal/sign"
+)
+
+var testKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+
+func makeReport() model.Report {
+	ip := model.MustParseAddr("8.8.8.8")
+	report := model.Report{
+		IP:        ip,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Results: []model.ProviderResult{
+			{
+				Provider: "ipinfo",
+				Result:   &model.Geolocation{IP: ip, CountryCode: "US", City: "Mountain View", Reputation: &model.Reputation{Score: 20}},
+				Quota:    &model.Quota{Limit: 1000, Remaining: 10},
+			},
+			{
+				Provider: "abuseipdb",
+				Result:   &model.Geolocation{IP: ip, Reputation: &model.Reputation{Score: 80}},
+			},
+			{Provider: "ip-api", Error: "timeout"},
+		},
+		Meta: &model.Meta{Version: "1.2.3", Providers: []string{"ipinfo", "abuseipdb", "ip-api"}, MinAgreement: 0.5},
+	}
+	report.SetConsensusOptions(model.ConsensusOptions{
+		MinAgreement: 0.5,
+		Risk:         model.RiskOptions{Weights: map[string]float64{"abuseipdb": 3}},
+	})
+	return report
+}
+
+// signed returns report as JSON, altered by edit, then signed.
+func signed(t *testing.T, report model.Report, edit func(map[string]any)) []byte {
+	t.Helper()
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		edit(doc)
+		if data, err = json.Marshal(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if data, err = sign.NewSigner(testKey, "1.2.3").Sign(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCheck_Valid(t *testing.T) {
+	res := Check(signed(t, makeReport(), nil), Options{Key: testKey.Public().(ed25519.PublicKey)})
+	if !res.OK() {
+		t.Fatalf("Check() problems = %v", res.Problems)
+	}
+	if res.IP != "8.8.8.8" || res.Signature == nil || res.Signature.Version != "1.2.3" {
+		t.Errorf("Check() = %+v", res)
+	}
+}
+
+func TestCheck_CamelCase(t *testing.T) {
+	data := signed(t, makeReport(), func(doc map[string]any) {
+		doc["totalDurationMs"] = doc["total_duration_ms"]
+		delete(doc, "total_duration_ms")
+		meta := doc["meta"].(map[string]any)
+		meta["minAgreement"] = meta["min_agreement"]
+		delete(meta, "min_agreement")
+	})
+
+	if res := Check(data, Options{}); !res.OK() {
+		t.Errorf("Check() problems = %v", res.Problems)
+	}
+}
+
+func TestCheck_Problems(t *testing.T) {
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+		opts Options
+		want string
+	}{
+		{
+			name: "unsigned",
+			data: func(t *testing.T) []byte { data, _ := json.Marshal(makeReport()); return data },
+			want: "not signed",
+		},
+		{
+			name: "untrusted key",
+			data: func(t *testing.T) []byte { return signed(t, makeReport(), nil) },
+			opts: Options{Key: other.Public().(ed25519.PublicKey)},
+			want: "signed with an untrusted key",
+		},
+		{
+			name: "tampered",
+			data: func(t *testing.T) []byte {
+				return bytes.Replace(signed(t, makeReport(), nil), []byte(`"Mountain View"`), []byte(`"Paris"`), 1)
+			},
+			want: "signature mismatch",
+		},
+		{
+			name: "risk score",
+			data: func(t *testing.T) []byte {
+				return signed(t, makeReport(), func(doc map[string]any) { doc["risk"].(map[string]any)["score"] = 10 })
+			},
+			want: "risk score is 10, but the results yield 65",
+		},
+		{
+			name: "quota",
+			data: func(t *testing.T) []byte {
+				return signed(t, makeReport(), func(doc map[string]any) { delete(doc, "quota") })
+			},
+			want: "quota of provider ipinfo does not match its result",
+		},
+		{
+			name: "granularity",
+			data: func(t *testing.T) []byte {
+				return signed(t, makeReport(), func(doc map[string]any) { doc["granularity"] = "country" })
+			},
+			want: `granularity is "country", but the results yield "city"`,
+		},
+		{
+			name: "result for another address",
+			data: func(t *testing.T) []byte {
+				report := makeReport()
+				report.Results[1].Result.IP = model.MustParseAddr("1.1.1.1")
+				return signed(t, report, nil)
+			},
+			want: "the result of provider abuseipdb is for 1.1.1.1, not 8.8.8.8",
+		},
+		{
+			name: "duplicate provider",
+			data: func(t *testing.T) []byte {
+				report := makeReport()
+				report.Results = append(report.Results, model.ProviderResult{Provider: "ip-api", Error: "timeout"})
+				return signed(t, report, nil)
+			},
+			want: "provider ip-api answered more than once",
+		},
+		{
+			name: "schema",
+			data: func(t *testing.T) []byte {
+				// A future schema version, signed as that version would
+				var doc map[string]any
+				dec := json.NewDecoder(bytes.NewReader(signed(t, makeReport(), nil)))
+				dec.UseNumber()
+				if err := dec.Decode(&doc); err != nil {
+					t.Fatal(err)
+				}
+				sig := doc[sign.Field].(map[string]any)
+				sig["schema"] = 99
+				delete(sig, "value")
+				message, _ := json.Marshal(doc)
+				sig["value"] = base64.StdEncoding.EncodeToString(ed25519.Sign(testKey, message))
+				data, _ := json.Marshal(doc)
+				return data
+			},
+			want: "unsupported schema version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Check(tt.data(t), tt.opts)
+			if res.OK() || !strings.Contains(strings.Join(res.Problems, "\n"), tt.want) {
+				t.Errorf("Check() problems = %q, want %q", res.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck_AllowUnsigned(t *testing.T) {
+	data, err := json.Marshal(makeReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := Check(data, Options{AllowUnsigned: true})
+	if !res.OK() || res.Signature != nil {
+		t.Errorf("Check() = %+v, want an unsigned pass", res)
+	}
+}
+
+func TestCheck_NotAReport(t *testing.T) {
+	for _, data := range []string{`[1, 2]`, `{"ip": "not an address"}`, `{"results": []}`} {
+		if res := Check([]byte(data), Options{AllowUnsigned: true}); res.OK() {
+			t.Errorf("Check(%s) passed", data)
+		}
+	}
+}
+
+func TestCamelToSnake(t *testing.T) {
+	for key, want := range map[string]string{
+		"totalDurationMs": "total_duration_ms",
+		"ip":              "ip",
+		"country_code":    "country_code",
+		"ip-api":          "ip-api",
+	} {
+		if got := camelToSnake(key); got != want {
+			t.Errorf("camelToSnake(%q) = %q, want %q", key, got, want)
+		}
+	}
+}